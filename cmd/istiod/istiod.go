@@ -97,9 +97,16 @@ func main() {
 
 	// Options based on the current 'defaults' in istio.
 	// If adjustments are needed - env or mesh.config ( if of general interest ).
-	istiod.RunCA(istiods.SecureGRPCServer, client, &istiod.CAOptions{
-		TrustDomain: istiods.Mesh.TrustDomain,
+	caServer := istiod.RunCA(stop, istiods.SecureGRPCServer, client, &istiod.CAOptions{
+		TrustDomain:        istiods.Mesh.TrustDomain,
+		TrustDomainAliases: istiods.Mesh.TrustDomainAliases,
 	})
+	if caServer != nil {
+		istiods.AddCAHealthCheck(caServer)
+		istiods.AddCATrustBundle(caServer)
+		istiods.AddCACRL(caServer)
+		istiods.AddCARootRotation(caServer)
+	}
 
 	istiods.Serve(stop)
 	istiods.WaitStop(stop)
@@ -0,0 +1,709 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s_labels "k8s.io/apimachinery/pkg/labels"
+
+	authn "istio.io/api/authentication/v1alpha1"
+	"istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+var (
+	explainFrom string
+	explainTo   string
+	explainPath string
+)
+
+func explainCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "explain",
+		Short: "Explains how a request of a pod to a service is handled [kube-only]",
+		Long: `Walks the Istio configuration applying to a request of a pod to a service, in the
+order the sidecar of the pod applies it: the Sidecar scope of the pod, the
+VirtualService routes, the DestinationRules of the destinations and the
+authentication policy of the service. It prints the decision made at each step
+and the resources causing the request to fail, if any.
+
+Header, method and query parameter matches can't be evaluated without the request,
+the routes with such matches are reported as conditional.
+
+THIS COMMAND IS STILL UNDER ACTIVE DEVELOPMENT AND NOT READY FOR PRODUCTION USE.
+`,
+		Example: `istioctl experimental explain --from pod/productpage-v1-c7765c886-7zzd4 --to svc/reviews:9080 --path /reviews/0`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if explainFrom == "" || explainTo == "" {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("explain requires --from and --to")
+			}
+			ns := handlers.HandleNamespace(namespace, defaultNamespace)
+			podName, podNs, err := parseExplainResource(explainFrom, "pod", ns)
+			if err != nil {
+				return err
+			}
+			to := explainTo
+			portName := ""
+			if i := strings.LastIndex(to, ":"); i >= 0 {
+				to, portName = to[:i], to[i+1:]
+			}
+			svcName, svcNs, err := parseExplainResource(to, "svc", ns)
+			if err != nil {
+				return err
+			}
+
+			client, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return err
+			}
+			pod, err := client.CoreV1().Pods(podNs).Get(podName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			svc, err := client.CoreV1().Services(svcNs).Get(svcName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			port, err := explainServicePort(svc, portName)
+			if err != nil {
+				return err
+			}
+
+			configClient, err := clientFactory()
+			if err != nil {
+				return err
+			}
+
+			e := &requestExplainer{
+				writer:       cmd.OutOrStdout(),
+				configClient: configClient,
+				pod:          pod,
+				svc:          svc,
+				port:         port,
+				path:         explainPath,
+			}
+			return e.explain()
+		},
+	}
+
+	cmd.PersistentFlags().StringVar(&explainFrom, "from", "",
+		"The pod sending the request, as pod/<name>[.<namespace>]")
+	cmd.PersistentFlags().StringVar(&explainTo, "to", "",
+		"The service receiving the request, as svc/<name>[.<namespace>][:<port>]")
+	cmd.PersistentFlags().StringVar(&explainPath, "path", "/",
+		"The path of the HTTP request")
+
+	return cmd
+}
+
+// parseExplainResource parses <kind>/<name>[.<namespace>], where the kind is optional.
+func parseExplainResource(resource, kind, defaultNs string) (string, string, error) {
+	if i := strings.Index(resource, "/"); i >= 0 {
+		k := resource[:i]
+		if k != kind && !(kind == "svc" && k == "service") {
+			return "", "", fmt.Errorf("expecting a %s rather than %q", kind, resource)
+		}
+		resource = resource[i+1:]
+	}
+	if resource == "" {
+		return "", "", fmt.Errorf("missing the %s name", kind)
+	}
+	name, ns := handlers.InferPodInfo(resource, defaultNs)
+	return name, ns, nil
+}
+
+// explainServicePort returns the port of the service with the given number or name, or its only port.
+func explainServicePort(svc *v1.Service, port string) (v1.ServicePort, error) {
+	if port == "" {
+		if len(svc.Spec.Ports) != 1 {
+			return v1.ServicePort{}, fmt.Errorf("service %s has %d ports, specify one of them", kname(svc.ObjectMeta),
+				len(svc.Spec.Ports))
+		}
+		return svc.Spec.Ports[0], nil
+	}
+	number, _ := strconv.Atoi(port)
+	for _, p := range svc.Spec.Ports {
+		if p.Name == port || int(p.Port) == number {
+			return p, nil
+		}
+	}
+	return v1.ServicePort{}, fmt.Errorf("service %s has no port %s", kname(svc.ObjectMeta), port)
+}
+
+// explainedDestination is a destination of the request, after the routing.
+type explainedDestination struct {
+	host   host.Name
+	subset string
+	weight int32
+}
+
+func (d explainedDestination) String() string {
+	out := string(d.host)
+	if d.subset != "" {
+		out += " subset " + d.subset
+	}
+	if d.weight > 0 && d.weight < 100 {
+		out += fmt.Sprintf(" (%d%%)", d.weight)
+	}
+	return out
+}
+
+// requestExplainer walks the configuration applying to a request of a pod to a service port.
+type requestExplainer struct {
+	writer       io.Writer
+	configClient model.ConfigStore
+	pod          *v1.Pod
+	svc          *v1.Service
+	port         v1.ServicePort
+	path         string
+
+	steps    int
+	problems []string
+}
+
+func (e *requestExplainer) step(format string, a ...interface{}) {
+	e.steps++
+	fmt.Fprintf(e.writer, "%d. %s\n", e.steps, fmt.Sprintf(format, a...))
+}
+
+func (e *requestExplainer) detail(format string, a ...interface{}) {
+	fmt.Fprintf(e.writer, "   %s\n", fmt.Sprintf(format, a...))
+}
+
+// problem records the resource causing the request to fail.
+func (e *requestExplainer) problem(format string, a ...interface{}) {
+	p := fmt.Sprintf(format, a...)
+	e.problems = append(e.problems, p)
+	fmt.Fprintf(e.writer, "   Warning: %s\n", p)
+}
+
+func (e *requestExplainer) svcHost() host.Name {
+	return host.Name(svcFQDN(*e.svc))
+}
+
+func (e *requestExplainer) explain() error {
+	fmt.Fprintf(e.writer, "Request from pod %s to service %s:%d path %s\n",
+		kname(e.pod.ObjectMeta), kname(e.svc.ObjectMeta), e.port.Port, e.path)
+	if !isMeshed(e.pod) {
+		fmt.Fprintf(e.writer, "Pod %s is not in the mesh, the request is not handled by Istio.\n", kname(e.pod.ObjectMeta))
+		return nil
+	}
+
+	var destinations []explainedDestination
+	if e.explainSidecar() {
+		destinations = e.explainVirtualService()
+	}
+	clientTLS := ""
+	for _, dest := range destinations {
+		tlsMode := e.explainDestinationRule(dest)
+		if dest.host == e.svcHost() {
+			clientTLS = tlsMode
+		}
+	}
+	for _, dest := range destinations {
+		if dest.host == e.svcHost() {
+			e.explainAuthentication(clientTLS)
+			break
+		}
+	}
+
+	if len(e.problems) > 0 {
+		fmt.Fprintf(e.writer, "Decision: the request fails or is not handled as expected, caused by:\n")
+		for _, p := range e.problems {
+			fmt.Fprintf(e.writer, "   %s\n", p)
+		}
+		return nil
+	}
+	if len(destinations) == 0 {
+		fmt.Fprintf(e.writer, "Decision: the request is redirected\n")
+		return nil
+	}
+	fmt.Fprintf(e.writer, "Decision: the request is sent to %s\n", renderDestinations(destinations))
+	return nil
+}
+
+// explainSidecar returns whether the service is visible to the pod, given its Sidecar scope.
+func (e *requestExplainer) explainSidecar() bool {
+	sidecar := e.selectSidecar()
+	if sidecar == nil {
+		e.step("Sidecar: none, all the services of the mesh are visible to the pod")
+		return true
+	}
+	e.step("Sidecar: %s", name(*sidecar))
+	spec := sidecar.Spec.(*v1alpha3.Sidecar)
+	for _, listener := range spec.Egress {
+		if listener.Port != nil && listener.Port.Number != uint32(e.port.Port) {
+			continue
+		}
+		for _, h := range listener.Hosts {
+			if e.egressHostMatches(h) {
+				e.detail("Service %s is visible through the egress host %q", e.svcHost(), h)
+				return true
+			}
+		}
+	}
+	e.problem("Sidecar %s doesn't expose service %s to the pod, the request is handled by the outbound traffic policy",
+		name(*sidecar), e.svcHost())
+	return false
+}
+
+// selectSidecar returns the Sidecar of the pod: the one selecting the pod, the default one of its
+// namespace, or the default one of the root namespace.
+func (e *requestExplainer) selectSidecar() *model.Config {
+	podLabels := k8s_labels.Set(e.pod.ObjectMeta.Labels)
+	var nsDefault, rootDefault *model.Config
+	for _, ns := range []string{e.pod.ObjectMeta.Namespace, istioNamespace} {
+		sidecars, err := e.configClient.List(schemas.Sidecar.Type, ns)
+		if err != nil {
+			continue
+		}
+		for i := range sidecars {
+			sidecar := &sidecars[i]
+			spec := sidecar.Spec.(*v1alpha3.Sidecar)
+			if spec.WorkloadSelector == nil || len(spec.WorkloadSelector.Labels) == 0 {
+				if ns == e.pod.ObjectMeta.Namespace && nsDefault == nil {
+					nsDefault = sidecar
+				} else if rootDefault == nil {
+					rootDefault = sidecar
+				}
+				continue
+			}
+			if ns == e.pod.ObjectMeta.Namespace &&
+				k8s_labels.SelectorFromSet(spec.WorkloadSelector.Labels).Matches(podLabels) {
+				return sidecar
+			}
+		}
+	}
+	if nsDefault != nil {
+		return nsDefault
+	}
+	return rootDefault
+}
+
+// egressHostMatches returns whether the egress host, in namespace/dnsName format, matches the service.
+func (e *requestExplainer) egressHostMatches(egressHost string) bool {
+	ns, dnsName := "*", egressHost
+	if parts := strings.SplitN(egressHost, "/", 2); len(parts) == 2 {
+		ns, dnsName = parts[0], parts[1]
+	}
+	switch ns {
+	case "*":
+	case ".":
+		if e.svc.ObjectMeta.Namespace != e.pod.ObjectMeta.Namespace {
+			return false
+		}
+	default:
+		if ns != e.svc.ObjectMeta.Namespace {
+			return false
+		}
+	}
+	return host.Name(dnsName).Matches(e.svcHost())
+}
+
+// exportedTo returns whether the config is exported to the namespace of the pod.
+func (e *requestExplainer) exportedTo(config model.Config, exportTo []string) bool {
+	if len(exportTo) == 0 {
+		return true
+	}
+	for _, to := range exportTo {
+		if to == "*" || (to == "." && config.Namespace == e.pod.ObjectMeta.Namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// explainVirtualService returns the destinations of the request, given the VirtualService of the service.
+func (e *requestExplainer) explainVirtualService() []explainedDestination {
+	vss, err := e.configClient.List(schemas.VirtualService.Type, "")
+	if err != nil {
+		e.step("VirtualService: failed to list the VirtualServices: %v", err)
+		return nil
+	}
+	matching := []model.Config{}
+	for _, vs := range vss {
+		spec := vs.Spec.(*v1alpha3.VirtualService)
+		if len(spec.Gateways) > 0 && !contains(spec.Gateways, "mesh") {
+			continue
+		}
+		if !e.exportedTo(vs, spec.ExportTo) {
+			continue
+		}
+		for _, h := range spec.Hosts {
+			if resolveExplainHost(h, vs.ConfigMeta).Matches(e.svcHost()) {
+				matching = append(matching, vs)
+				break
+			}
+		}
+	}
+	if len(matching) == 0 {
+		e.step("VirtualService: none, the request is load balanced to all the endpoints of the service")
+		return []explainedDestination{{host: e.svcHost()}}
+	}
+
+	// The oldest VirtualService of a host is used by the sidecars.
+	sort.SliceStable(matching, func(i, j int) bool {
+		return matching[i].CreationTimestamp.Before(matching[j].CreationTimestamp)
+	})
+	vs := matching[0]
+	e.step("VirtualService: %s", name(vs))
+	for _, other := range matching[1:] {
+		e.problem("VirtualService %s also defines the host of the service and is ignored, VirtualService %s is used",
+			name(other), name(vs))
+	}
+
+	spec := vs.Spec.(*v1alpha3.VirtualService)
+	proto := servicePortProtocol(e.port.Name)
+	if proto.IsHTTP() || proto == protocol.Unsupported {
+		for i, route := range spec.Http {
+			matched, conditions := e.httpRouteMatches(route)
+			if !matched {
+				if conditions != "" {
+					e.detail("Route %s only matches %s, not evaluated", explainRouteName(i, route.Name), conditions)
+				}
+				continue
+			}
+			e.detail("Route %s matches %s", explainRouteName(i, route.Name), renderMatches(route.Match))
+			return e.explainHTTPRoute(vs, route)
+		}
+		if len(spec.Http) > 0 {
+			e.problem("No HTTP route of VirtualService %s matches the request, it fails with 404", name(vs))
+			return nil
+		}
+	}
+	for i, route := range spec.Tcp {
+		if !e.tcpRouteMatches(route) {
+			continue
+		}
+		e.detail("TCP route %s matches", explainRouteName(i, ""))
+		destinations := make([]explainedDestination, 0, len(route.Route))
+		for _, dest := range route.Route {
+			destinations = append(destinations, explainedDestination{
+				host:   resolveExplainHost(dest.Destination.Host, vs.ConfigMeta),
+				subset: dest.Destination.Subset,
+				weight: dest.Weight,
+			})
+		}
+		e.detail("Destinations: %s", renderDestinations(destinations))
+		return destinations
+	}
+	e.problem("No route of VirtualService %s matches the request", name(vs))
+	return nil
+}
+
+// resolveExplainHost resolves the short host names of the config, in the cluster.local domain if the config
+// has none.
+func resolveExplainHost(h string, meta model.ConfigMeta) host.Name {
+	if meta.Domain == "" {
+		meta.Domain = strings.TrimPrefix(k8sSuffix, ".svc.")
+	}
+	return model.ResolveShortnameToFQDN(h, meta)
+}
+
+func explainRouteName(i int, name string) string {
+	if name != "" {
+		return fmt.Sprintf("%d (%s)", i+1, name)
+	}
+	return strconv.Itoa(i + 1)
+}
+
+func renderDestinations(destinations []explainedDestination) string {
+	out := make([]string, 0, len(destinations))
+	for _, dest := range destinations {
+		out = append(out, dest.String())
+	}
+	return strings.Join(out, ", ")
+}
+
+// httpRouteMatches returns whether the route matches the request. The conditions of the route which
+// can't be evaluated are returned, when the route matches the request otherwise.
+func (e *requestExplainer) httpRouteMatches(route *v1alpha3.HTTPRoute) (bool, string) {
+	if len(route.Match) == 0 {
+		return true, ""
+	}
+	conditions := []string{}
+	for _, match := range route.Match {
+		if match.Port != 0 && match.Port != uint32(e.port.Port) {
+			continue
+		}
+		if !e.sourceLabelsMatch(match.SourceLabels) {
+			continue
+		}
+		if len(match.Gateways) > 0 && !contains(match.Gateways, "mesh") {
+			continue
+		}
+		if match.Uri != nil && !stringMatches(match.Uri, e.path, match.IgnoreUriCase) {
+			continue
+		}
+		if match.Method == nil && match.Authority == nil && match.Scheme == nil &&
+			len(match.Headers) == 0 && len(match.QueryParams) == 0 {
+			return true, ""
+		}
+		conditions = append(conditions, renderMatch(match))
+	}
+	return false, strings.Join(conditions, ", ")
+}
+
+func (e *requestExplainer) tcpRouteMatches(route *v1alpha3.TCPRoute) bool {
+	if len(route.Match) == 0 {
+		return true
+	}
+	for _, match := range route.Match {
+		if match.Port != 0 && match.Port != uint32(e.port.Port) {
+			continue
+		}
+		if !e.sourceLabelsMatch(match.SourceLabels) {
+			continue
+		}
+		if len(match.Gateways) > 0 && !contains(match.Gateways, "mesh") {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func (e *requestExplainer) sourceLabelsMatch(sourceLabels map[string]string) bool {
+	return len(sourceLabels) == 0 ||
+		k8s_labels.SelectorFromSet(sourceLabels).Matches(k8s_labels.Set(e.pod.ObjectMeta.Labels))
+}
+
+func stringMatches(sm *v1alpha3.StringMatch, value string, ignoreCase bool) bool {
+	switch x := sm.MatchType.(type) {
+	case *v1alpha3.StringMatch_Exact:
+		if ignoreCase {
+			return strings.EqualFold(x.Exact, value)
+		}
+		return x.Exact == value
+	case *v1alpha3.StringMatch_Prefix:
+		if ignoreCase {
+			return strings.HasPrefix(strings.ToLower(value), strings.ToLower(x.Prefix))
+		}
+		return strings.HasPrefix(value, x.Prefix)
+	case *v1alpha3.StringMatch_Regex:
+		re, err := regexp.Compile("^(?:" + x.Regex + ")$")
+		return err == nil && re.MatchString(value)
+	}
+	return true
+}
+
+func (e *requestExplainer) explainHTTPRoute(vs model.Config, route *v1alpha3.HTTPRoute) []explainedDestination {
+	if route.Redirect != nil {
+		e.detail("Redirected to %s%s", route.Redirect.Authority, route.Redirect.Uri)
+		return nil
+	}
+	if route.Rewrite != nil {
+		e.detail("Rewritten to %s%s", route.Rewrite.Authority, route.Rewrite.Uri)
+	}
+	if route.Fault != nil {
+		e.detail("Fault injection %s", route.Fault.String())
+	}
+	if route.Timeout != nil {
+		e.detail("Timeout %s", route.Timeout.String())
+	}
+	if route.Retries != nil {
+		e.detail("Retries %d", route.Retries.Attempts)
+	}
+	if route.Mirror != nil {
+		e.detail("Mirrored to %s", route.Mirror.Host)
+	}
+
+	destinations := make([]explainedDestination, 0, len(route.Route))
+	for _, dest := range route.Route {
+		destinations = append(destinations, explainedDestination{
+			host:   resolveExplainHost(dest.Destination.Host, vs.ConfigMeta),
+			subset: dest.Destination.Subset,
+			weight: dest.Weight,
+		})
+	}
+	e.detail("Destinations: %s", renderDestinations(destinations))
+	return destinations
+}
+
+// explainDestinationRule explains the DestinationRule of the destination, returning the TLS mode of the
+// client.
+func (e *requestExplainer) explainDestinationRule(dest explainedDestination) string {
+	dr := e.findDestinationRule(dest.host)
+	if dr == nil {
+		e.step("DestinationRule: none for %q", dest.host)
+		if dest.subset != "" {
+			e.problem("No DestinationRule defines subset %s of %s, the request fails with 503", dest.subset, dest.host)
+		}
+		return ""
+	}
+	spec := dr.Spec.(*v1alpha3.DestinationRule)
+	e.step("DestinationRule: %s for %q", name(*dr), spec.Host)
+
+	policy := spec.TrafficPolicy
+	tlsMode := tlsModeOf(policy, e.port.Port, "")
+	if dest.subset != "" {
+		var subset *v1alpha3.Subset
+		for _, s := range spec.Subsets {
+			if s.Name == dest.subset {
+				subset = s
+				break
+			}
+		}
+		if subset == nil {
+			e.problem("DestinationRule %s doesn't define subset %s, the request fails with 503", name(*dr), dest.subset)
+			return tlsMode
+		}
+		e.detail("Subset %s selects %s", subset.Name, k8s_labels.SelectorFromSet(subset.Labels).String())
+		tlsMode = tlsModeOf(subset.TrafficPolicy, e.port.Port, tlsMode)
+	}
+	if tlsMode != "" {
+		e.detail("Traffic Policy TLS Mode: %s", tlsMode)
+	}
+	return tlsMode
+}
+
+// tlsModeOf returns the TLS mode of the traffic policy for the port, or the inherited mode.
+func tlsModeOf(policy *v1alpha3.TrafficPolicy, port int32, inherited string) string {
+	if policy == nil {
+		return inherited
+	}
+	mode := inherited
+	if policy.Tls != nil {
+		mode = policy.Tls.Mode.String()
+	}
+	for _, pls := range policy.PortLevelSettings {
+		if pls.Port != nil && pls.Port.Number == uint32(port) && pls.Tls != nil {
+			mode = pls.Tls.Mode.String()
+		}
+	}
+	return mode
+}
+
+// findDestinationRule returns the DestinationRule of the host visible to the pod, looked up in the
+// namespace of the pod, then the namespace of the host and the root namespace.
+func (e *requestExplainer) findDestinationRule(h host.Name) *model.Config {
+	namespaces := []string{e.pod.ObjectMeta.Namespace}
+	if parts := strings.Split(string(h), "."); len(parts) > 1 {
+		namespaces = append(namespaces, parts[1])
+	}
+	namespaces = append(namespaces, istioNamespace)
+	for _, ns := range namespaces {
+		drs, err := e.configClient.List(schemas.DestinationRule.Type, ns)
+		if err != nil {
+			continue
+		}
+		for i := range drs {
+			spec := drs[i].Spec.(*v1alpha3.DestinationRule)
+			if ns != e.pod.ObjectMeta.Namespace && !e.exportedTo(drs[i], spec.ExportTo) {
+				continue
+			}
+			if resolveExplainHost(spec.Host, drs[i].ConfigMeta).Matches(h) {
+				return &drs[i]
+			}
+		}
+	}
+	return nil
+}
+
+// explainAuthentication explains the authentication policy of the service, given the TLS mode of the client.
+func (e *requestExplainer) explainAuthentication(clientTLS string) {
+	policy := e.findAuthenticationPolicy()
+	if policy == nil {
+		e.step("Authentication Policy: none, the service doesn't require mTLS")
+		if clientTLS == v1alpha3.TLSSettings_ISTIO_MUTUAL.String() {
+			e.problem("The DestinationRule of the service sends mTLS but the service doesn't accept it, " +
+				"the request fails with 503")
+		}
+		return
+	}
+	e.step("Authentication Policy: %s", name(*policy))
+
+	var mtls *authn.MutualTls
+	for _, peer := range policy.Spec.(*authn.Policy).Peers {
+		if m := peer.GetMtls(); m != nil {
+			mtls = m
+			break
+		}
+	}
+	switch {
+	case mtls == nil:
+		e.detail("Peer authentication: mTLS disabled")
+		if clientTLS == v1alpha3.TLSSettings_ISTIO_MUTUAL.String() {
+			e.problem("Authentication Policy %s doesn't accept the mTLS of the client, the request fails with 503",
+				name(*policy))
+		}
+	case mtls.Mode == authn.MutualTls_PERMISSIVE:
+		e.detail("Peer authentication: mTLS PERMISSIVE, the service accepts both mTLS and plain text")
+	default:
+		e.detail("Peer authentication: mTLS STRICT")
+		switch clientTLS {
+		case v1alpha3.TLSSettings_ISTIO_MUTUAL.String():
+		case "":
+			e.detail("No DestinationRule sets the TLS mode of the client, the request uses mTLS only if auto mTLS is enabled")
+		default:
+			e.problem("Authentication Policy %s requires mTLS but the client TLS mode is %s, the request fails with 503",
+				name(*policy), clientTLS)
+		}
+	}
+}
+
+// findAuthenticationPolicy returns the authentication policy of the service port: the one targeting the
+// service, the default one of its namespace, or the default MeshPolicy.
+func (e *requestExplainer) findAuthenticationPolicy() *model.Config {
+	policies, err := e.configClient.List(schemas.AuthenticationPolicy.Type, e.svc.ObjectMeta.Namespace)
+	if err == nil {
+		var nsDefault *model.Config
+		for i := range policies {
+			spec := policies[i].Spec.(*authn.Policy)
+			if len(spec.Targets) == 0 {
+				if policies[i].Name == "default" {
+					nsDefault = &policies[i]
+				}
+				continue
+			}
+			for _, target := range spec.Targets {
+				if target.Name == e.svc.ObjectMeta.Name && e.portSelected(target.Ports) {
+					return &policies[i]
+				}
+			}
+		}
+		if nsDefault != nil {
+			return nsDefault
+		}
+	}
+	return e.configClient.Get(schemas.AuthenticationMeshPolicy.Type, "default", "")
+}
+
+func (e *requestExplainer) portSelected(ports []*authn.PortSelector) bool {
+	if len(ports) == 0 {
+		return true
+	}
+	for _, port := range ports {
+		if port.GetNumber() == uint32(e.port.Port) || (port.GetName() != "" && port.GetName() == e.port.Name) {
+			return true
+		}
+	}
+	return false
+}
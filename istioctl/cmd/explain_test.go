@@ -0,0 +1,243 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	authn "istio.io/api/authentication/v1alpha1"
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+var (
+	explainK8sConfigs = []runtime.Object{
+		&coreV1.Pod{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:      "productpage-v1-c7765c886-7zzd4",
+				Namespace: "default",
+				Labels:    map[string]string{"app": "productpage"},
+			},
+			Spec: coreV1.PodSpec{
+				Containers: []coreV1.Container{{Name: "productpage"}, {Name: "istio-proxy"}},
+			},
+		},
+		&coreV1.Service{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:      "ratings",
+				Namespace: "bookinfo",
+			},
+			Spec: coreV1.ServiceSpec{
+				Ports:    []coreV1.ServicePort{{Name: "http", Port: 9080}},
+				Selector: map[string]string{"app": "ratings"},
+			},
+		},
+	}
+
+	explainIstioConfigs = []model.Config{
+		{
+			ConfigMeta: model.ConfigMeta{
+				Name:      "ratings",
+				Namespace: "bookinfo",
+				Type:      schemas.VirtualService.Type,
+				Group:     schemas.VirtualService.Group,
+				Version:   schemas.VirtualService.Version,
+			},
+			Spec: &networking.VirtualService{
+				Hosts: []string{"ratings"},
+				Http: []*networking.HTTPRoute{
+					{
+						Match: []*networking.HTTPMatchRequest{{
+							Uri:     &networking.StringMatch{MatchType: &networking.StringMatch_Prefix{Prefix: "/api"}},
+							Headers: map[string]*networking.StringMatch{"end-user": {MatchType: &networking.StringMatch_Exact{Exact: "jason"}}},
+						}},
+						Route: []*networking.HTTPRouteDestination{{
+							Destination: &networking.Destination{Host: "ratings", Subset: "v3"},
+						}},
+					},
+					{
+						Match: []*networking.HTTPMatchRequest{{
+							Uri: &networking.StringMatch{MatchType: &networking.StringMatch_Prefix{Prefix: "/api"}},
+						}},
+						Route: []*networking.HTTPRouteDestination{{
+							Destination: &networking.Destination{Host: "ratings", Subset: "v2"},
+						}},
+					},
+					{
+						Route: []*networking.HTTPRouteDestination{{
+							Destination: &networking.Destination{Host: "ratings", Subset: "v1"},
+						}},
+					},
+				},
+			},
+		},
+		{
+			ConfigMeta: model.ConfigMeta{
+				Name:      "ratings",
+				Namespace: "bookinfo",
+				Type:      schemas.DestinationRule.Type,
+				Group:     schemas.DestinationRule.Group,
+				Version:   schemas.DestinationRule.Version,
+			},
+			Spec: &networking.DestinationRule{
+				Host: "ratings",
+				Subsets: []*networking.Subset{{
+					Name:   "v1",
+					Labels: map[string]string{"version": "v1"},
+				}},
+				TrafficPolicy: &networking.TrafficPolicy{
+					Tls: &networking.TLSSettings{Mode: networking.TLSSettings_DISABLE},
+				},
+			},
+		},
+		{
+			ConfigMeta: model.ConfigMeta{
+				Name:      "default",
+				Namespace: "bookinfo",
+				Type:      schemas.AuthenticationPolicy.Type,
+				Group:     schemas.AuthenticationPolicy.Group,
+				Version:   schemas.AuthenticationPolicy.Version,
+			},
+			Spec: &authn.Policy{
+				Peers: []*authn.PeerAuthenticationMethod{{
+					Params: &authn.PeerAuthenticationMethod_Mtls{Mtls: &authn.MutualTls{Mode: authn.MutualTls_PERMISSIVE}},
+				}},
+			},
+		},
+	}
+
+	explainSidecarConfig = model.Config{
+		ConfigMeta: model.ConfigMeta{
+			Name:      "default",
+			Namespace: "default",
+			Type:      schemas.Sidecar.Type,
+			Group:     schemas.Sidecar.Group,
+			Version:   schemas.Sidecar.Version,
+		},
+		Spec: &networking.Sidecar{
+			Egress: []*networking.IstioEgressListener{{Hosts: []string{"./*", "istio-system/*"}}},
+		},
+	}
+)
+
+func TestExplain(t *testing.T) {
+	cases := []execAndK8sConfigTestCase{
+		{ // case 0 missing flags
+			args:           strings.Split("x explain --to svc/ratings.bookinfo", " "),
+			expectedString: "explain requires --from and --to",
+			wantException:  true,
+		},
+		{ // case 1 default route
+			configs:    explainIstioConfigs,
+			k8sConfigs: explainK8sConfigs,
+			namespace:  "default",
+			args:       strings.Split("x explain --from pod/productpage-v1-c7765c886-7zzd4 --to svc/ratings.bookinfo:9080", " "),
+			expectedOutput: `Request from pod productpage-v1-c7765c886-7zzd4 to service ratings.bookinfo:9080 path /
+1. Sidecar: none, all the services of the mesh are visible to the pod
+2. VirtualService: ratings.bookinfo
+   Route 3 matches everything
+   Destinations: ratings.bookinfo.svc.cluster.local subset v1
+3. DestinationRule: ratings.bookinfo for "ratings"
+   Subset v1 selects version=v1
+   Traffic Policy TLS Mode: DISABLE
+4. Authentication Policy: default.bookinfo
+   Peer authentication: mTLS PERMISSIVE, the service accepts both mTLS and plain text
+Decision: the request is sent to ratings.bookinfo.svc.cluster.local subset v1
+`,
+		},
+		{ // case 2 route to an undefined subset
+			configs:    explainIstioConfigs,
+			k8sConfigs: explainK8sConfigs,
+			namespace:  "default",
+			args: strings.Split("x explain --from productpage-v1-c7765c886-7zzd4 --to ratings.bookinfo:http "+
+				"--path /api/v1", " "),
+			expectedOutput: `Request from pod productpage-v1-c7765c886-7zzd4 to service ratings.bookinfo:9080 path /api/v1
+1. Sidecar: none, all the services of the mesh are visible to the pod
+2. VirtualService: ratings.bookinfo
+   Route 1 only matches /api* when headers are end-user=jason, not evaluated
+   Route 2 matches /api*
+   Destinations: ratings.bookinfo.svc.cluster.local subset v2
+3. DestinationRule: ratings.bookinfo for "ratings"
+   Warning: DestinationRule ratings.bookinfo doesn't define subset v2, the request fails with 503
+4. Authentication Policy: default.bookinfo
+   Peer authentication: mTLS PERMISSIVE, the service accepts both mTLS and plain text
+Decision: the request fails or is not handled as expected, caused by:
+   DestinationRule ratings.bookinfo doesn't define subset v2, the request fails with 503
+`,
+		},
+		{ // case 3 service hidden by the Sidecar
+			configs:    append([]model.Config{explainSidecarConfig}, explainIstioConfigs...),
+			k8sConfigs: explainK8sConfigs,
+			namespace:  "default",
+			args:       strings.Split("x explain --from pod/productpage-v1-c7765c886-7zzd4 --to svc/ratings.bookinfo", " "),
+			expectedOutput: `Request from pod productpage-v1-c7765c886-7zzd4 to service ratings.bookinfo:9080 path /
+1. Sidecar: default
+   Warning: Sidecar default doesn't expose service ratings.bookinfo.svc.cluster.local to the pod, the request is handled by the outbound traffic policy
+Decision: the request fails or is not handled as expected, caused by:
+   Sidecar default doesn't expose service ratings.bookinfo.svc.cluster.local to the pod, the request is handled by the outbound traffic policy
+`,
+		},
+		{ // case 4 unknown port
+			configs:        explainIstioConfigs,
+			k8sConfigs:     explainK8sConfigs,
+			namespace:      "default",
+			args:           strings.Split("x explain --from pod/productpage-v1-c7765c886-7zzd4 --to svc/ratings.bookinfo:8080", " "),
+			expectedString: "service ratings.bookinfo has no port 8080",
+			wantException:  true,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("case %d %s", i, strings.Join(c.args, " ")), func(t *testing.T) {
+			verifyExecAndK8sConfigTestCaseTestOutput(t, c)
+		})
+	}
+}
+
+func TestExplainTLSConflict(t *testing.T) {
+	configs := []model.Config{
+		explainIstioConfigs[1],
+		{
+			ConfigMeta: model.ConfigMeta{
+				Name:      "default",
+				Namespace: "bookinfo",
+				Type:      schemas.AuthenticationPolicy.Type,
+				Group:     schemas.AuthenticationPolicy.Group,
+				Version:   schemas.AuthenticationPolicy.Version,
+			},
+			Spec: &authn.Policy{
+				Peers: []*authn.PeerAuthenticationMethod{{
+					Params: &authn.PeerAuthenticationMethod_Mtls{Mtls: &authn.MutualTls{}},
+				}},
+			},
+		},
+	}
+	verifyExecAndK8sConfigTestCaseTestOutput(t, execAndK8sConfigTestCase{
+		configs:    configs,
+		k8sConfigs: explainK8sConfigs,
+		namespace:  "default",
+		args:       strings.Split("x explain --from pod/productpage-v1-c7765c886-7zzd4 --to svc/ratings.bookinfo", " "),
+		expectedString: "Authentication Policy default.bookinfo requires mTLS but the client TLS mode is DISABLE, " +
+			"the request fails with 503",
+	})
+}
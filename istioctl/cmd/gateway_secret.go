@@ -0,0 +1,88 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/spf13/cobra"
+)
+
+var (
+	gatewaySecretSelector string
+	gatewaySecretPod      string
+)
+
+func refreshGatewaySecretCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "refresh-gateway-secret <secret-name>",
+		Short: "Pushes the current kubernetes secret to the ingress gateways [kube only]",
+		Long: `
+Makes the node agent of each ingress gateway reload the kubernetes secret from the API server and push it
+to the gateway, for when a certificate was rotated but the gateways still serve the old one. The secrets
+of the other namespaces watched by the gateways are named <namespace>/<name>.
+
+The latency from the update of a secret to its push is reported by the gateway_secret_push_latency metric
+of the node agent.
+`,
+		Example: `# Refresh the secret bookinfo-cert of the ingress gateways
+	istioctl experimental refresh-gateway-secret bookinfo-cert
+
+# Refresh the secret of a single ingress gateway pod
+	istioctl experimental refresh-gateway-secret bookinfo-cert --pod istio-ingressgateway-59585c5b9c-ndc59
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			kubeClient, err := clientExecSdsFactory(kubeconfig, configContext)
+			if err != nil {
+				return err
+			}
+
+			pods := []string{gatewaySecretPod}
+			if gatewaySecretPod == "" {
+				pl, err := kubeClient.PodsForSelector(istioNamespace, gatewaySecretSelector)
+				if err != nil {
+					return fmt.Errorf("not able to locate the ingress gateway pods: %v", err)
+				}
+				if len(pl.Items) == 0 {
+					return fmt.Errorf("no ingress gateway pods found in %s with selector %q", istioNamespace, gatewaySecretSelector)
+				}
+				pods = pods[:0]
+				for _, pod := range pl.Items {
+					pods = append(pods, pod.Name)
+				}
+			}
+
+			var errs error
+			for _, pod := range pods {
+				out, err := kubeClient.RefreshGatewaySecret(pod, istioNamespace, args[0])
+				if err != nil {
+					errs = multierror.Append(errs, fmt.Errorf("%s.%s: %v", pod, istioNamespace, err))
+					continue
+				}
+				fmt.Fprintf(c.OutOrStdout(), "%s.%s: %s", pod, istioNamespace, out)
+			}
+			return errs
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&gatewaySecretSelector, "selector", "l", "istio=ingressgateway",
+		"Label selector of the ingress gateway pods in the Istio system namespace")
+	cmd.PersistentFlags().StringVar(&gatewaySecretPod, "pod", "",
+		"Name of a single ingress gateway pod in the Istio system namespace, instead of the selected pods")
+
+	return cmd
+}
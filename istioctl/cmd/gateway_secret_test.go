@@ -0,0 +1,56 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRefreshGatewaySecret(t *testing.T) {
+	cannedResponse := map[string][]byte{
+		"istio-ingressgateway-59585c5b9c-ndc59": []byte("secret bookinfo-cert refreshed\n"),
+	}
+	cases := []execTestCase{
+		{ // case 0: a single pod
+			execClientConfig: cannedResponse,
+			args:             strings.Split("x refresh-gateway-secret bookinfo-cert --pod istio-ingressgateway-59585c5b9c-ndc59", " "),
+			expectedOutput:   "istio-ingressgateway-59585c5b9c-ndc59.istio-system: secret bookinfo-cert refreshed\n",
+		},
+		{ // case 1: the node agent of the pod fails to refresh
+			args:           strings.Split("x refresh-gateway-secret bookinfo-cert --pod istio-ingressgateway-59585c5b9c-ndc59", " "),
+			expectedString: "istio-ingressgateway-59585c5b9c-ndc59.istio-system",
+			wantException:  true,
+		},
+		{ // case 2: no gateway pod selected
+			execClientConfig: cannedResponse,
+			args:             strings.Split("x refresh-gateway-secret bookinfo-cert", " "),
+			expectedString:   `no ingress gateway pods found in istio-system with selector "istio=ingressgateway"`,
+			wantException:    true,
+		},
+		{ // case 3: the secret name is required
+			args:          strings.Split("x refresh-gateway-secret", " "),
+			wantException: true,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("case %d %s", i, strings.Join(c.args, " ")), func(t *testing.T) {
+			clientExecSdsFactory = mockClientExecSDSFactoryGenerator(c.execClientConfig)
+			verifyExecTestOutput(t, c)
+		})
+	}
+}
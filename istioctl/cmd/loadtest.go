@@ -0,0 +1,315 @@
+// Copyright 2019 Istio Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pstruct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/spf13/cobra"
+
+	v2 "istio.io/istio/pilot/pkg/proxy/envoy/v2"
+	"istio.io/istio/pkg/adsc"
+)
+
+var (
+	loadTestAddress   string
+	loadTestCertDir   string
+	loadTestClients   int
+	loadTestNamespace string
+	loadTestMeta      map[string]string
+	loadTestDuration  time.Duration
+	loadTestChurnRate float64
+	loadTestTimeout   time.Duration
+
+	// xdsClientFactory connects a simulated proxy to the control plane. It is overridden by the tests.
+	xdsClientFactory = dialXDSClient
+)
+
+// xdsClient is the ADS connection of a simulated proxy.
+type xdsClient interface {
+	Watch()
+	Wait(to time.Duration, updates ...string) ([]string, error)
+	Close()
+}
+
+func dialXDSClient(address string, config *adsc.Config) (xdsClient, error) {
+	client, err := adsc.Dial(address, loadTestCertDir, config)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func loadTestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "loadtest",
+		Short: "Commands to load test the control plane",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.HelpFunc()(cmd, args)
+			if len(args) != 0 {
+				return fmt.Errorf("unknown subcommand %q", args[0])
+			}
+			return nil
+		},
+	}
+	cmd.AddCommand(loadTestXDSCmd())
+	return cmd
+}
+
+func loadTestXDSCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "xds",
+		Short: "Load test the xDS server of the control plane with simulated proxies",
+		Long: `istioctl experimental loadtest xds connects simulated proxies to the xDS server of the control
+plane, and reports the time they take to get their initial configuration, the pushes they receive and
+the latency of the pushes, from the first simulated proxy receiving a version of the configuration to the
+ACK of its listeners by each proxy. It validates the capacity of the control plane before scaling up the
+mesh.
+
+The proxies reconnect at the churn rate, randomly spread over the proxies, as they would when the
+pods are rescheduled. Each proxy gets a distinct IP and workload name, with the given node metadata.
+THIS COMMAND IS STILL UNDER ACTIVE DEVELOPMENT AND NOT READY FOR PRODUCTION USE.
+`,
+		Example: `# Connect 500 proxies to istiod for 5 minutes
+istioctl experimental loadtest xds --address localhost:15010 --clients 500 --duration 5m
+
+# Reconnect 10 proxies per second, with the node metadata of a 1.4 sidecar
+istioctl experimental loadtest xds --clients 500 --churn-rate 10 --meta ISTIO_VERSION=1.4.0`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("unexpected arguments %v", args)
+			}
+			if loadTestClients <= 0 {
+				return fmt.Errorf("--clients must be positive, got %d", loadTestClients)
+			}
+			if loadTestDuration <= 0 {
+				return fmt.Errorf("--duration must be positive, got %v", loadTestDuration)
+			}
+			if loadTestChurnRate < 0 {
+				return fmt.Errorf("--churn-rate must not be negative, got %v", loadTestChurnRate)
+			}
+			return runXDSLoadTest(cmd.OutOrStdout())
+		},
+	}
+	cmd.PersistentFlags().StringVar(&loadTestAddress, "address", "istiod.istio-system:15010",
+		"Address of the xDS server")
+	cmd.PersistentFlags().StringVar(&loadTestCertDir, "cert-dir", "",
+		"Directory with the certificates for mTLS to the xDS server, plain text if empty")
+	cmd.PersistentFlags().IntVar(&loadTestClients, "clients", 100, "Number of simulated proxies")
+	cmd.PersistentFlags().StringVar(&loadTestNamespace, "proxy-namespace", "default",
+		"Namespace of the simulated proxies")
+	cmd.PersistentFlags().StringToStringVar(&loadTestMeta, "meta", nil,
+		"Node metadata of the simulated proxies, as KEY=VALUE pairs")
+	cmd.PersistentFlags().DurationVar(&loadTestDuration, "duration", time.Minute, "Duration of the load test")
+	cmd.PersistentFlags().Float64Var(&loadTestChurnRate, "churn-rate", 0,
+		"Number of proxies reconnecting per second, 0 to keep the connections for the whole test")
+	cmd.PersistentFlags().DurationVar(&loadTestTimeout, "timeout", 30*time.Second,
+		"Maximum time for a proxy to get its initial configuration")
+	return cmd
+}
+
+// xdsLoadTestResult collects the measures of the simulated proxies.
+type xdsLoadTestResult struct {
+	mutex sync.Mutex
+	// latencies are the times from the connection to the listeners of the initial configuration.
+	latencies   []time.Duration
+	failures    int
+	disconnects int
+	// pushes is the number of updates after the initial configuration, by type.
+	pushes map[string]int
+	// pushStarts are the times the first simulated proxy acknowledged a response of each version.
+	pushStarts map[string]time.Time
+	// pushLatencies are the times from the start of the pushes to the ACK of the listeners pushed to
+	// each proxy after its initial configuration.
+	pushLatencies []time.Duration
+}
+
+func newXDSLoadTestResult() *xdsLoadTestResult {
+	return &xdsLoadTestResult{pushes: map[string]int{}, pushStarts: map[string]time.Time{}}
+}
+
+func (r *xdsLoadTestResult) connected(latency time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.latencies = append(r.latencies, latency)
+}
+
+func (r *xdsLoadTestResult) failed() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.failures++
+}
+
+// pushed records the updates received by a proxy, and returns true if the connection was closed.
+func (r *xdsLoadTestResult) pushed(updates []string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, update := range updates {
+		if update == "close" {
+			r.disconnects++
+			return true
+		}
+		r.pushes[update]++
+	}
+	return false
+}
+
+// acked records the ACK of a response of the version by a proxy. The listeners are pushed in each full
+// push, with a new version, so the push latency is measured until their ACK.
+func (r *xdsLoadTestResult) acked(typeURL, version string, initial bool) {
+	now := time.Now()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	start, f := r.pushStarts[version]
+	if !f {
+		r.pushStarts[version] = now
+		start = now
+	}
+	if typeURL == v2.ListenerType && !initial {
+		r.pushLatencies = append(r.pushLatencies, now.Sub(start))
+	}
+}
+
+func (r *xdsLoadTestResult) print(writer io.Writer) {
+	sort.Slice(r.latencies, func(i, j int) bool { return r.latencies[i] < r.latencies[j] })
+	sort.Slice(r.pushLatencies, func(i, j int) bool { return r.pushLatencies[i] < r.pushLatencies[j] })
+	fmt.Fprintf(writer, "Connections: %d (%d failed), closed by the server: %d\n",
+		len(r.latencies)+r.failures, r.failures, r.disconnects)
+	fmt.Fprintf(writer, "Time to the initial configuration: p50 %v, p90 %v, p99 %v, max %v\n",
+		percentile(r.latencies, 50), percentile(r.latencies, 90), percentile(r.latencies, 99),
+		percentile(r.latencies, 100))
+	types := make([]string, 0, len(r.pushes))
+	for t := range r.pushes {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	pushes := make([]string, 0, len(types))
+	for _, t := range types {
+		pushes = append(pushes, fmt.Sprintf("%s %d", t, r.pushes[t]))
+	}
+	fmt.Fprintf(writer, "Pushes received: %s\n", strings.Join(pushes, ", "))
+	fmt.Fprintf(writer, "Push to ACK latency of %d listener pushes: p50 %v, p90 %v, p99 %v, max %v\n",
+		len(r.pushLatencies), percentile(r.pushLatencies, 50), percentile(r.pushLatencies, 90),
+		percentile(r.pushLatencies, 99), percentile(r.pushLatencies, 100))
+}
+
+// percentile returns the p-th percentile of the sorted durations, 0 if there is none.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i].Round(time.Millisecond)
+}
+
+// runXDSLoadTest runs the simulated proxies for the duration of the load test, and prints the result.
+func runXDSLoadTest(writer io.Writer) error {
+	fmt.Fprintf(writer, "Connecting %d simulated proxies to %s for %v\n", loadTestClients, loadTestAddress,
+		loadTestDuration)
+	result := newXDSLoadTestResult()
+	deadline := time.Now().Add(loadTestDuration)
+	var wg sync.WaitGroup
+	for i := 0; i < loadTestClients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			runXDSClient(i, deadline, result)
+		}(i)
+	}
+	wg.Wait()
+
+	result.print(writer)
+	if len(result.latencies) == 0 {
+		return errors.New("no simulated proxy got its initial configuration")
+	}
+	return nil
+}
+
+// runXDSClient connects the i-th simulated proxy until the deadline, reconnecting at the churn rate.
+func runXDSClient(i int, deadline time.Time, result *xdsLoadTestResult) {
+	for time.Now().Before(deadline) {
+		start := time.Now()
+		end := deadline
+		if loadTestChurnRate > 0 {
+			// The lifetimes of the connections are exponentially distributed, so that the proxies
+			// reconnect at the churn rate overall.
+			mean := float64(loadTestClients) / loadTestChurnRate * float64(time.Second)
+			if e := start.Add(time.Duration(rand.ExpFloat64() * mean)); e.Before(end) {
+				end = e
+			}
+		}
+
+		// The first listeners acknowledged are the ones of the initial configuration. The handler is
+		// called by the receiving goroutine of the connection, one response at a time.
+		listenersAcked := 0
+		client, err := xdsClientFactory(loadTestAddress, &adsc.Config{
+			Namespace: loadTestNamespace,
+			Workload:  fmt.Sprintf("loadtest-%d", i),
+			IP:        fmt.Sprintf("10.%d.%d.%d", (i>>16)&0xff, (i>>8)&0xff, i&0xff),
+			Meta:      loadTestMetadata(),
+			ACKHandler: func(typeURL, version string) {
+				if typeURL == v2.ListenerType {
+					listenersAcked++
+				}
+				result.acked(typeURL, version, listenersAcked <= 1)
+			},
+		})
+		if err != nil {
+			result.failed()
+			time.Sleep(time.Until(end))
+			continue
+		}
+		client.Watch()
+		if _, err := client.Wait(loadTestTimeout, "lds"); err != nil {
+			result.failed()
+			client.Close()
+			time.Sleep(time.Until(end))
+			continue
+		}
+		result.connected(time.Since(start))
+
+		for remaining := time.Until(end); remaining > 0; remaining = time.Until(end) {
+			// Without a type, Wait returns the first update or nothing on timeout.
+			updates, _ := client.Wait(remaining)
+			if result.pushed(updates) {
+				break
+			}
+		}
+		client.Close()
+	}
+}
+
+// loadTestMetadata returns the node metadata of the simulated proxies, with a default ISTIO_VERSION.
+func loadTestMetadata() *pstruct.Struct {
+	meta := &pstruct.Struct{Fields: map[string]*pstruct.Value{
+		"ISTIO_VERSION": {Kind: &pstruct.Value_StringValue{StringValue: "65536.65536.65536"}},
+	}}
+	for k, v := range loadTestMeta {
+		meta.Fields[k] = &pstruct.Value{Kind: &pstruct.Value_StringValue{StringValue: v}}
+	}
+	return meta
+}
@@ -0,0 +1,152 @@
+// Copyright 2019 Istio Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	v2 "istio.io/istio/pilot/pkg/proxy/envoy/v2"
+	"istio.io/istio/pkg/adsc"
+)
+
+// fakeResponses are the responses of a version received by a fake client.
+type fakeResponses struct {
+	version string
+	updates []string
+}
+
+// fakeXDSClient receives its responses, acknowledging them, one version at a time.
+type fakeXDSClient struct {
+	config    *adsc.Config
+	responses []fakeResponses
+}
+
+func (c *fakeXDSClient) Watch() {}
+
+func (c *fakeXDSClient) Wait(to time.Duration, _ ...string) ([]string, error) {
+	if len(c.responses) == 0 {
+		time.Sleep(to)
+		return nil, errors.New("timeout")
+	}
+	responses := c.responses[0]
+	c.responses = c.responses[1:]
+	for _, update := range responses.updates {
+		c.config.ACKHandler(typeURLs[update], responses.version)
+	}
+	return responses.updates, nil
+}
+
+var typeURLs = map[string]string{"cds": v2.ClusterType, "eds": v2.EndpointType, "lds": v2.ListenerType}
+
+func (c *fakeXDSClient) Close() {}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{}
+	for i := 1; i <= 10; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Second)
+	}
+	cases := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{p: 0, want: time.Second},
+		{p: 50, want: 5 * time.Second},
+		{p: 90, want: 9 * time.Second},
+		{p: 99, want: 10 * time.Second},
+		{p: 100, want: 10 * time.Second},
+	}
+	for _, c := range cases {
+		if got := percentile(sorted, c.p); got != c.want {
+			t.Errorf("percentile(%v) => %v, want %v", c.p, got, c.want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("percentile() without durations => %v, want 0", got)
+	}
+}
+
+func TestLoadTestXDS(t *testing.T) {
+	defer func(f func(string, *adsc.Config) (xdsClient, error)) { xdsClientFactory = f }(xdsClientFactory)
+	var mutex sync.Mutex
+	configs := []*adsc.Config{}
+	xdsClientFactory = func(_ string, config *adsc.Config) (xdsClient, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		configs = append(configs, config)
+		return &fakeXDSClient{config: config, responses: []fakeResponses{
+			{version: "v1", updates: []string{"cds", "eds", "lds"}},
+			{version: "v1", updates: []string{"eds"}},
+			{version: "v2", updates: []string{"cds", "lds"}},
+		}}, nil
+	}
+
+	out, err := runLoadTestXDS("--clients 3 --duration 100ms --meta CLUSTER_ID=foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Connections: 3 (0 failed)",
+		"Pushes received: cds 3, eds 3, lds 3",
+		"Push to ACK latency of 3 listener pushes: p50",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output %q does not contain %q", out, want)
+		}
+	}
+	if len(configs) != 3 || configs[0].IP == configs[1].IP {
+		t.Fatalf("unexpected simulated proxies %v", configs)
+	}
+	if meta := configs[0].Meta.Fields; meta["CLUSTER_ID"].GetStringValue() != "foo" || meta["ISTIO_VERSION"] == nil {
+		t.Errorf("unexpected node metadata %v", meta)
+	}
+
+	xdsClientFactory = func(string, *adsc.Config) (xdsClient, error) {
+		return nil, errors.New("connection refused")
+	}
+	out, err = runLoadTestXDS("--clients 2 --duration 10ms")
+	if err == nil {
+		t.Fatal("expected an error without connected proxies")
+	}
+	if !strings.Contains(out, "Connections: 2 (2 failed)") {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestXDSLoadTestResultAcked(t *testing.T) {
+	r := newXDSLoadTestResult()
+	r.pushStarts["v2"] = time.Now().Add(-time.Second)
+	r.acked(v2.ListenerType, "v1", true)
+	r.acked(v2.ClusterType, "v2", false)
+	r.acked(v2.ListenerType, "v2", false)
+	if len(r.pushLatencies) != 1 || r.pushLatencies[0] < time.Second {
+		t.Errorf("got push latencies %v, want a single one of at least 1s", r.pushLatencies)
+	}
+	if _, f := r.pushStarts["v1"]; !f {
+		t.Error("the version of the initial configuration should start the push")
+	}
+}
+
+func runLoadTestXDS(args string) (string, error) {
+	var out bytes.Buffer
+	rootCmd := GetRootCmd(strings.Split("experimental loadtest xds "+args, " "))
+	rootCmd.SetOutput(&out)
+	err := rootCmd.Execute()
+	return out.String(), err
+}
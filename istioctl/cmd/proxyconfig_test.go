@@ -348,3 +348,11 @@ func (client mockExecConfig) GetPodNodeAgentSecrets(podName, ns, istioNamespace
 func (client mockExecConfig) NodeAgentDebugEndpointOutput(podName, ns, secretType, container string) (sds.Debug, error) {
 	return sds.Debug{}, nil
 }
+
+func (client mockExecConfig) RefreshGatewaySecret(podName, ns, secretName string) ([]byte, error) {
+	results, ok := client.results[podName]
+	if !ok {
+		return nil, fmt.Errorf("unable to retrieve Pod: pods %q not found", podName)
+	}
+	return results, nil
+}
@@ -16,6 +16,7 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -27,10 +28,22 @@ import (
 )
 
 var (
-	sdsDump bool
-	sdsJSON bool
+	sdsDump      bool
+	sdsJSON      bool
+	statusOutput string
+	failOnStale  bool
 )
 
+// ProxiesNotSyncedError is returned by proxy-status --fail-on-stale when proxies did not acknowledge the
+// last configuration sent by Pilot, or are not connected to Pilot.
+type ProxiesNotSyncedError struct {
+	Proxies []string
+}
+
+func (e ProxiesNotSyncedError) Error() string {
+	return fmt.Sprintf("proxies not synced: %s", strings.Join(e.Proxies, ", "))
+}
+
 func statusCommand() *cobra.Command {
 	statusCmd := &cobra.Command{
 		Use:   "proxy-status [<pod-name[.namespace]>]",
@@ -44,6 +57,12 @@ Retrieves last sent and last acknowledged xDS sync from Pilot to each Envoy in t
 
 # Retrieve sync diff for a single Envoy and Pilot
 	istioctl proxy-status istio-egressgateway-59585c5b9c-ndc59.istio-system
+
+# Retrieve sync status for all Envoys in JSON, with the time since the last push and ACK
+	istioctl proxy-status -o json
+
+# Exit with a non-zero code if a single Envoy is out of sync, for automation
+	istioctl proxy-status istio-egressgateway-59585c5b9c-ndc59.istio-system --fail-on-stale
 `,
 		Aliases: []string{"ps"},
 		RunE: func(c *cobra.Command, args []string) error {
@@ -51,7 +70,10 @@ Retrieves last sent and last acknowledged xDS sync from Pilot to each Envoy in t
 			if err != nil {
 				return err
 			}
-			if len(args) > 0 {
+			if statusOutput != summaryOutput && statusOutput != jsonOutput {
+				return fmt.Errorf("output format %q not supported", statusOutput)
+			}
+			if len(args) > 0 && statusOutput == summaryOutput && !failOnStale {
 				podName, ns := handlers.InferPodInfo(args[0], handlers.HandleNamespace(namespace, defaultNamespace))
 				path := fmt.Sprintf("config_dump")
 				envoyDump, err := kubeClient.EnvoyDo(podName, ns, "GET", path, nil)
@@ -87,7 +109,31 @@ Retrieves last sent and last acknowledged xDS sync from Pilot to each Envoy in t
 				return err
 			}
 			sw := pilot.StatusWriter{Writer: c.OutOrStdout()}
-			return sw.PrintAll(statuses)
+			if statusOutput == summaryOutput && !failOnStale {
+				return sw.PrintAll(statuses)
+			}
+
+			var proxyName string
+			if len(args) > 0 {
+				podName, ns := handlers.InferPodInfo(args[0], handlers.HandleNamespace(namespace, defaultNamespace))
+				proxyName = fmt.Sprintf("%s.%s", podName, ns)
+			}
+			proxies, err := pilot.ProxyStatuses(statuses, proxyName)
+			if err != nil {
+				return err
+			}
+			switch {
+			case statusOutput == jsonOutput:
+				err = sw.PrintJSON(proxies)
+			case proxyName != "":
+				err = sw.PrintSingle(statuses, proxyName)
+			default:
+				err = sw.PrintAll(statuses)
+			}
+			if err != nil || !failOnStale {
+				return err
+			}
+			return staleProxies(proxies, proxyName)
 		},
 	}
 
@@ -95,10 +141,34 @@ Retrieves last sent and last acknowledged xDS sync from Pilot to each Envoy in t
 		"(experimental) Retrieve synchronization between active secrets on Envoy instance with those on corresponding node agents")
 	statusCmd.Flags().BoolVar(&sdsJSON, "sds-json", false,
 		"Determines whether SDS dump outputs JSON")
+	statusCmd.Flags().StringVarP(&statusOutput, "output", "o", summaryOutput,
+		"Output format of the sync status: one of json|short. The json output includes the time since the last "+
+			"push and ACK of each proxy, and is printed instead of the config diff of a single proxy")
+	statusCmd.Flags().BoolVar(&failOnStale, "fail-on-stale", false,
+		"Exit with a non-zero code if any of the proxies is out of sync, or if the given proxy is not connected "+
+			"to Pilot. The sync status is printed instead of the config diff of a single proxy")
 
 	return statusCmd
 }
 
+// staleProxies returns a ProxiesNotSyncedError if any of the proxies is out of sync, or if the given proxy
+// is not connected to any Pilot.
+func staleProxies(proxies []pilot.ProxyStatus, proxyName string) error {
+	if proxyName != "" && len(proxies) == 0 {
+		return ProxiesNotSyncedError{Proxies: []string{proxyName}}
+	}
+	var stale []string
+	for _, p := range proxies {
+		if !p.Synced {
+			stale = append(stale, p.Proxy)
+		}
+	}
+	if len(stale) > 0 {
+		return ProxiesNotSyncedError{Proxies: stale}
+	}
+	return nil
+}
+
 // sdsDiff diffs pod secrets with corresponding node agent secrets
 func sdsDiff(
 	c kubernetes.ExecClientSDS, w sdscompare.SDSWriter, podName, namespace string) error {
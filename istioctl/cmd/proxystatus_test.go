@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"strings"
 	"testing"
@@ -27,6 +28,12 @@ func TestProxyStatus(t *testing.T) {
 	cannedConfig := map[string][]byte{
 		"details-v1-5b7f94f9bc-wp5tb": util.ReadFile("../pkg/writer/compare/testdata/envoyconfigdump.json", t),
 	}
+	cannedSyncz := map[string][]byte{
+		"istio-pilot-1": []byte(`[{"proxy":"details-v1-5b7f94f9bc-wp5tb.default","istio_version":"1.4",` +
+			`"cluster_sent":"1","cluster_acked":"1","listener_sent":"2","listener_acked":"2"},` +
+			`{"proxy":"productpage-v1-7bbdd59459-w6nwq.default","istio_version":"1.4",` +
+			`"cluster_sent":"3","cluster_acked":"4"}]`),
+	}
 	cases := []execTestCase{
 		{ // case 0
 			args:           strings.Split("proxy-status", " "),
@@ -65,6 +72,34 @@ default           Cert Chain     true                          WARMING     10224
 			args:          strings.Split("proxy-status random-gibberish-podname-61789237418234", " "),
 			wantException: true,
 		},
+		{ // case 6: proxy-status -o json
+			execClientConfig: cannedSyncz,
+			args:             strings.Split("proxy-status -o json", " "),
+			expectedString: `"proxy": "productpage-v1-7bbdd59459-w6nwq.default",
+    "pilot": "istio-pilot-1",
+    "version": "1.4",
+    "cds": "STALE",`,
+		},
+		{ // case 7: proxy-status --fail-on-stale with a proxy out of sync
+			execClientConfig: cannedSyncz,
+			args:             strings.Split("proxy-status --fail-on-stale", " "),
+			expectedString:   "productpage-v1-7bbdd59459-w6nwq.default     STALE",
+			wantException:    true,
+		},
+		{ // case 8: proxy-status podName --fail-on-stale with the proxy in sync
+			execClientConfig: cannedSyncz,
+			args:             strings.Split("proxy-status details-v1-5b7f94f9bc-wp5tb --fail-on-stale", " "),
+			expectedString:   "details-v1-5b7f94f9bc-wp5tb.default     SYNCED",
+		},
+		{ // case 9: proxy-status podName --fail-on-stale with the proxy not connected
+			execClientConfig: cannedSyncz,
+			args:             strings.Split("proxy-status reviews-v1-5b7f94f9bc-abcde --fail-on-stale", " "),
+			wantException:    true,
+		},
+		{ // case 10: unsupported output format
+			args:          strings.Split("proxy-status -o yaml", " "),
+			wantException: true,
+		},
 	}
 
 	for i, c := range cases {
@@ -75,6 +110,21 @@ default           Cert Chain     true                          WARMING     10224
 	}
 }
 
+func TestProxyStatusExitCode(t *testing.T) {
+	clientExecSdsFactory = mockClientExecSDSFactoryGenerator(map[string][]byte{
+		"istio-pilot-1": []byte(`[{"proxy":"details-v1-5b7f94f9bc-wp5tb.default","cluster_sent":"1"}]`),
+	})
+	rootCmd := GetRootCmd(strings.Split("proxy-status --fail-on-stale", " "))
+	rootCmd.SetOutput(&bytes.Buffer{})
+	err := rootCmd.Execute()
+	if _, ok := err.(ProxiesNotSyncedError); !ok {
+		t.Fatalf("Expected a ProxiesNotSyncedError, but got %v", err)
+	}
+	if got := GetExitCode(err); got != ExitProxiesNotSynced {
+		t.Errorf("GetExitCode() = %d, want %d", got, ExitProxiesNotSynced)
+	}
+}
+
 // mockClientExecFactoryGenerator generates a function with the same signature as
 // kubernetes.NewExecClient() that returns a mock client.
 func mockClientExecSDSFactoryGenerator(testResults map[string][]byte) func(kubeconfig, configContext string) (kubernetes.ExecClientSDS, error) {
@@ -0,0 +1,403 @@
+// Copyright 2019 Istio Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"time"
+
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s_labels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	k8s_kubernetes "k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/istioctl/pkg/kubernetes"
+	"istio.io/pkg/log"
+)
+
+const (
+	// revisionLabel selects the control plane revision injecting the pods of a namespace.
+	revisionLabel = "istio.io/rev"
+	// injectionLabel enables the injection by the default control plane.
+	injectionLabel = "istio-injection"
+	// restartedAtAnnotation is the annotation set by `kubectl rollout restart`.
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+)
+
+var (
+	migrateRevision     string
+	migratePercent      int
+	migrateDryRun       bool
+	migrateTimeout      time.Duration
+	migrateMaxNackRate  float64
+	migrateMaxErrorRate float64
+
+	// migrationHealthFactory returns the health checker of the migrated namespaces. It is
+	// overridden by the tests.
+	migrationHealthFactory = prometheusMigrationHealth
+)
+
+// migrationHealth returns the rate of xDS responses rejected by the proxies of the namespace, and
+// the ratio of the requests to the namespace failing with 5xx.
+type migrationHealth func(namespace string) (nackRate, errorRate float64, err error)
+
+func revisionCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "revision",
+		Short: "Commands to manage the control plane revisions of the proxies",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.HelpFunc()(cmd, args)
+			if len(args) != 0 {
+				return fmt.Errorf("unknown subcommand %q", args[0])
+			}
+			return nil
+		},
+	}
+	cmd.AddCommand(revisionMigrateCmd())
+	return cmd
+}
+
+func revisionMigrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate a percentage of the injected namespaces to a control plane revision",
+		Long: `istioctl experimental revision migrate moves a percentage of the namespaces with sidecar
+injection to a canary control plane revision. The selected namespaces are labeled with
+istio.io/rev=<revision> in place of istio-injection, and their deployments are restarted one
+namespace at a time, so that the pods are injected by the revision.
+
+The migration pauses, with an error, when the proxies reject the xDS responses or the requests to a
+migrated namespace fail above the given rates. The migration is resumed by running the command
+again: the namespaces already on the revision count towards the percentage.
+
+The sidecar injector of the revision must select the namespaces with the istio.io/rev label: the
+command fails without changing the namespaces if no mutating webhook selects them.
+THIS COMMAND IS STILL UNDER ACTIVE DEVELOPMENT AND NOT READY FOR PRODUCTION USE.
+`,
+		Example: `# Migrate 10% of the injected namespaces to the revision 1-5
+istioctl experimental revision migrate --to 1-5 --percent 10
+
+# Show the namespaces which would be migrated
+istioctl experimental revision migrate --to 1-5 --percent 50 --dry-run`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("unexpected arguments %v", args)
+			}
+			if migrateRevision == "" {
+				return errors.New("the revision is required, set --to")
+			}
+			if migratePercent < 0 || migratePercent > 100 {
+				return fmt.Errorf("--percent must be between 0 and 100, got %d", migratePercent)
+			}
+			client, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return err
+			}
+			var health migrationHealth
+			if !migrateDryRun && (migrateMaxNackRate > 0 || migrateMaxErrorRate > 0) {
+				var done func()
+				health, done, err = migrationHealthFactory()
+				if err != nil {
+					return err
+				}
+				defer done()
+			}
+			return migrateNamespaces(client, health, cmd.OutOrStdout())
+		},
+	}
+	cmd.PersistentFlags().StringVar(&migrateRevision, "to", "", "Control plane revision to migrate to")
+	cmd.PersistentFlags().IntVar(&migratePercent, "percent", 100,
+		"Percentage of the injected namespaces to run on the revision")
+	cmd.PersistentFlags().BoolVar(&migrateDryRun, "dry-run", false,
+		"Print the namespaces to migrate without changing them")
+	cmd.PersistentFlags().DurationVar(&migrateTimeout, "timeout", 5*time.Minute,
+		"Maximum time to wait for the deployments of a namespace to be rolled out")
+	cmd.PersistentFlags().Float64Var(&migrateMaxNackRate, "max-nack-rate", 0.1,
+		"Rate of xDS rejects per second by the proxies of a migrated namespace above which the migration is paused, 0 to disable")
+	cmd.PersistentFlags().Float64Var(&migrateMaxErrorRate, "max-error-rate", 0.05,
+		"Ratio of requests to a migrated namespace failing with 5xx above which the migration is paused, 0 to disable")
+	return cmd
+}
+
+// migrateNamespaces migrates the namespaces selected by namespacesToMigrate, checking their health
+// after each rollout.
+func migrateNamespaces(client k8s_kubernetes.Interface, health migrationHealth, writer io.Writer) error {
+	nsList, err := client.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	migrated, selected := namespacesToMigrate(nsList.Items, migrateRevision, migratePercent)
+	// The pods restarted in a namespace no webhook injects for the revision would lose their sidecar.
+	if err := checkRevisionWebhooks(client, selected, migrateRevision); err != nil {
+		return err
+	}
+	fmt.Fprintf(writer, "%d namespace(s) on revision %s, migrating %d namespace(s)\n",
+		len(migrated), migrateRevision, len(selected))
+	if migrateDryRun {
+		for _, ns := range selected {
+			fmt.Fprintf(writer, "namespace %s would be migrated\n", ns.Name)
+		}
+		return nil
+	}
+
+	for i, ns := range selected {
+		if err := relabelNamespace(client, ns.Name, migrateRevision); err != nil {
+			return fmt.Errorf("failed to label namespace %s: %v", ns.Name, err)
+		}
+		if err := restartDeployments(client, ns.Name, writer); err != nil {
+			return err
+		}
+		if health != nil {
+			if err := checkMigrationHealth(health, ns.Name); err != nil {
+				return fmt.Errorf("migration paused after namespace %s: %v", ns.Name, err)
+			}
+		}
+		fmt.Fprintf(writer, "namespace %s migrated to revision %s (%d/%d)\n",
+			ns.Name, migrateRevision, i+1, len(selected))
+	}
+	return nil
+}
+
+// namespacesToMigrate returns the injected namespaces already on the revision, and the ones to
+// migrate so that percent of the injected namespaces are on the revision. The namespaces are
+// selected by name, so that successive runs migrate the same ones.
+func namespacesToMigrate(namespaces []corev1.Namespace, revision string, percent int) (migrated, selected []corev1.Namespace) {
+	var candidates []corev1.Namespace
+	for _, ns := range namespaces {
+		rev, hasRev := ns.Labels[revisionLabel]
+		switch {
+		case hasRev && rev == revision:
+			migrated = append(migrated, ns)
+		case hasRev || ns.Labels[injectionLabel] == "enabled":
+			candidates = append(candidates, ns)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Name < candidates[j].Name
+	})
+
+	target := int(math.Ceil(float64(len(migrated)+len(candidates)) * float64(percent) / 100))
+	if n := target - len(migrated); n > 0 {
+		selected = candidates[:n]
+	}
+	return migrated, selected
+}
+
+// revisionLabels returns the labels of the namespace once its injection is moved to the revision.
+func revisionLabels(ns corev1.Namespace, revision string) k8s_labels.Set {
+	out := k8s_labels.Set{}
+	for k, v := range ns.Labels {
+		out[k] = v
+	}
+	delete(out, injectionLabel)
+	out[revisionLabel] = revision
+	return out
+}
+
+// checkRevisionWebhooks returns an error if a namespace to migrate wouldn't be selected by the
+// namespaceSelector of any injection webhook once moved to the revision.
+func checkRevisionWebhooks(client k8s_kubernetes.Interface, namespaces []corev1.Namespace, revision string) error {
+	if len(namespaces) == 0 {
+		return nil
+	}
+	configs, err := client.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list the mutating webhook configurations: %v", err)
+	}
+	var selectors []k8s_labels.Selector
+	for _, config := range configs.Items {
+		for _, webhook := range config.Webhooks {
+			if webhook.NamespaceSelector == nil {
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(webhook.NamespaceSelector)
+			if err != nil {
+				continue
+			}
+			selectors = append(selectors, selector)
+		}
+	}
+	for _, ns := range namespaces {
+		nsLabels := revisionLabels(ns, revision)
+		selected := false
+		for _, selector := range selectors {
+			if selector.Matches(nsLabels) {
+				selected = true
+				break
+			}
+		}
+		if !selected {
+			return fmt.Errorf("no injection webhook selects namespace %s labeled %s=%s, install the revision %s first",
+				ns.Name, revisionLabel, revision, revision)
+		}
+	}
+	return nil
+}
+
+// relabelNamespace moves the injection of the namespace to the revision.
+func relabelNamespace(client k8s_kubernetes.Interface, name, revision string) error {
+	patch := fmt.Sprintf(`{"metadata":{"labels":{%q:null,%q:%q}}}`, injectionLabel, revisionLabel, revision)
+	_, err := client.CoreV1().Namespaces().Patch(name, types.MergePatchType, []byte(patch))
+	return err
+}
+
+// restartDeployments restarts the deployments of the namespace, as `kubectl rollout restart`
+// does, and waits for them to be rolled out.
+func restartDeployments(client k8s_kubernetes.Interface, ns string, writer io.Writer) error {
+	deployments, err := client.AppsV1().Deployments(ns).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`,
+		restartedAtAnnotation, time.Now().Format(time.RFC3339))
+	for _, dep := range deployments.Items {
+		if _, err := client.AppsV1().Deployments(ns).Patch(dep.Name, types.StrategicMergePatchType, []byte(patch)); err != nil {
+			return fmt.Errorf("failed to restart deployment %s.%s: %v", dep.Name, ns, err)
+		}
+	}
+	for _, dep := range deployments.Items {
+		if err := waitForRollout(client, ns, dep.Name); err != nil {
+			return err
+		}
+		fmt.Fprintf(writer, "deployment %s.%s rolled out\n", dep.Name, ns)
+	}
+	return nil
+}
+
+func waitForRollout(client k8s_kubernetes.Interface, ns, name string) error {
+	deadline := time.Now().Add(migrateTimeout)
+	for {
+		dep, err := client.AppsV1().Deployments(ns).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if rolledOut(dep) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for deployment %s.%s to be rolled out", name, ns)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// rolledOut returns whether all the replicas of the deployment are updated and available.
+func rolledOut(dep *appsv1.Deployment) bool {
+	if dep.Status.ObservedGeneration < dep.Generation {
+		return false
+	}
+	replicas := int32(1)
+	if dep.Spec.Replicas != nil {
+		replicas = *dep.Spec.Replicas
+	}
+	return dep.Status.UpdatedReplicas >= replicas &&
+		dep.Status.Replicas == dep.Status.UpdatedReplicas &&
+		dep.Status.AvailableReplicas == dep.Status.UpdatedReplicas
+}
+
+func checkMigrationHealth(health migrationHealth, ns string) error {
+	nackRate, errorRate, err := health(ns)
+	if err != nil {
+		return fmt.Errorf("could not check the health of the migration: %v", err)
+	}
+	if migrateMaxNackRate > 0 && nackRate > migrateMaxNackRate {
+		return fmt.Errorf("xDS reject rate %.3f/s is above %.3f/s", nackRate, migrateMaxNackRate)
+	}
+	if migrateMaxErrorRate > 0 && errorRate > migrateMaxErrorRate {
+		return fmt.Errorf("5xx ratio %.3f is above %.3f", errorRate, migrateMaxErrorRate)
+	}
+	return nil
+}
+
+// proxyNodeRegex returns the regular expression matching the IDs of the proxies of the namespace,
+// sidecar~<ip>~<pod>.<namespace>~<namespace>.svc.<domain>, labeling their xDS rejects.
+func proxyNodeRegex(ns string) string {
+	ns = regexp.QuoteMeta(ns)
+	return fmt.Sprintf(`.+~.+~.+\.%s~%s\..+`, ns, ns)
+}
+
+// prometheusMigrationHealth checks the health of the migration with the metrics of the Prometheus
+// of the control plane. The returned function closes the port forwarding.
+func prometheusMigrationHealth() (migrationHealth, func(), error) {
+	client, err := clientExecFactory(kubeconfig, configContext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create k8s client: %v", err)
+	}
+	pl, err := client.PodsForSelector(istioNamespace, "app=prometheus")
+	if err != nil {
+		return nil, nil, fmt.Errorf("not able to locate Prometheus pod: %v", err)
+	}
+	if len(pl.Items) < 1 {
+		return nil, nil, errors.New("no Prometheus pods found, set --max-nack-rate=0 --max-error-rate=0 to migrate without health checks")
+	}
+	fw, err := client.BuildPortForwarder(pl.Items[0].Name, istioNamespace, 0, 9090)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not build port forwarder for prometheus: %v", err)
+	}
+
+	ready := make(chan promv1.API, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- kubernetes.RunPortForwarder(fw, func(fw *kubernetes.PortForward) error {
+			log.Debugf("port-forward to prometheus pod ready")
+			promAPI, err := prometheusAPI(fw.LocalPort)
+			if err != nil {
+				return err
+			}
+			ready <- promAPI
+			return nil
+		})
+	}()
+
+	var promAPI promv1.API
+	select {
+	case promAPI = <-ready:
+	case err := <-errCh:
+		return nil, nil, fmt.Errorf("failure running port forward process: %v", err)
+	}
+	health := func(ns string) (float64, float64, error) {
+		nackQuery := fmt.Sprintf(`sum(rate({__name__=~"pilot_xds_(cds|eds|lds|rds)_reject",node=~%q}[1m]))`,
+			proxyNodeRegex(ns))
+		nackRate, err := vectorValue(promAPI, nackQuery)
+		if err != nil {
+			return 0, 0, err
+		}
+		errorQuery := fmt.Sprintf(`sum(rate(istio_requests_total{destination_workload_namespace=%q,response_code=~"5.*"}[1m])) / `+
+			`sum(rate(istio_requests_total{destination_workload_namespace=%q}[1m]))`, ns, ns)
+		errorRate, err := vectorValue(promAPI, errorQuery)
+		if err != nil {
+			return 0, 0, err
+		}
+		return nackRate, errorRate, nil
+	}
+	done := func() {
+		// The port forwarding is stopped on interrupt too.
+		select {
+		case <-fw.StopChannel:
+		default:
+			close(fw.StopChannel)
+		}
+	}
+	return health, done, nil
+}
@@ -0,0 +1,199 @@
+// Copyright 2019 Istio Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func revisionNamespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func rolledOutDeployment(name, ns string) *appsv1.Deployment {
+	replicas := int32(1)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+	}
+}
+
+// revisionWebhook returns the injection webhook of the revision.
+func revisionWebhook(revision string) *admissionv1beta1.MutatingWebhookConfiguration {
+	return &admissionv1beta1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "istio-sidecar-injector-" + revision},
+		Webhooks: []admissionv1beta1.MutatingWebhook{{
+			Name: "sidecar-injector.istio.io",
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{revisionLabel: revision},
+			},
+		}},
+	}
+}
+
+func TestNamespacesToMigrate(t *testing.T) {
+	namespaces := []corev1.Namespace{
+		*revisionNamespace("d", map[string]string{injectionLabel: "enabled"}),
+		*revisionNamespace("c", map[string]string{revisionLabel: "1-4"}),
+		*revisionNamespace("b", map[string]string{injectionLabel: "enabled"}),
+		*revisionNamespace("a", map[string]string{revisionLabel: "1-5"}),
+		*revisionNamespace("kube-system", nil),
+		*revisionNamespace("off", map[string]string{injectionLabel: "disabled"}),
+	}
+	cases := []struct {
+		percent  int
+		migrated []string
+		selected []string
+	}{
+		{percent: 0, migrated: []string{"a"}},
+		{percent: 25, migrated: []string{"a"}},
+		{percent: 50, migrated: []string{"a"}, selected: []string{"b"}},
+		{percent: 100, migrated: []string{"a"}, selected: []string{"b", "c", "d"}},
+	}
+	names := func(nss []corev1.Namespace) []string {
+		var out []string
+		for _, ns := range nss {
+			out = append(out, ns.Name)
+		}
+		return out
+	}
+	for _, c := range cases {
+		migrated, selected := namespacesToMigrate(namespaces, "1-5", c.percent)
+		if !reflect.DeepEqual(names(migrated), c.migrated) || !reflect.DeepEqual(names(selected), c.selected) {
+			t.Errorf("percent %d: got migrated %v selected %v, want %v %v",
+				c.percent, names(migrated), names(selected), c.migrated, c.selected)
+		}
+	}
+}
+
+func TestRevisionMigrate(t *testing.T) {
+	objs := []runtime.Object{
+		revisionNamespace("bar", map[string]string{injectionLabel: "enabled"}),
+		revisionNamespace("foo", map[string]string{injectionLabel: "enabled"}),
+		rolledOutDeployment("details", "bar"),
+		revisionWebhook("1-5"),
+	}
+	client := fake.NewSimpleClientset(objs...)
+	defer func(f func(string) (kubernetes.Interface, error)) { interfaceFactory = f }(interfaceFactory)
+	defer func(f func() (migrationHealth, func(), error)) { migrationHealthFactory = f }(migrationHealthFactory)
+	interfaceFactory = func(_ string) (kubernetes.Interface, error) {
+		return client, nil
+	}
+	healthChecked := []string{}
+	migrationHealthFactory = func() (migrationHealth, func(), error) {
+		return func(ns string) (float64, float64, error) {
+			healthChecked = append(healthChecked, ns)
+			return 0, 0, nil
+		}, func() {}, nil
+	}
+
+	// The dry run doesn't change the namespaces.
+	out, err := runRevisionMigrate("--to 1-5 --percent 50 --dry-run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "namespace bar would be migrated") || strings.Contains(out, "foo") {
+		t.Errorf("unexpected dry run output %q", out)
+	}
+	ns, _ := client.CoreV1().Namespaces().Get("bar", metav1.GetOptions{})
+	if _, f := ns.Labels[revisionLabel]; f {
+		t.Fatalf("dry run labeled namespace bar: %v", ns.Labels)
+	}
+
+	out, err = runRevisionMigrate("--to 1-5 --percent 50 --dry-run=false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "deployment details.bar rolled out") || !strings.Contains(out, "namespace bar migrated to revision 1-5 (1/1)") {
+		t.Errorf("unexpected output %q", out)
+	}
+	ns, _ = client.CoreV1().Namespaces().Get("bar", metav1.GetOptions{})
+	if want := map[string]string{revisionLabel: "1-5"}; !reflect.DeepEqual(ns.Labels, want) {
+		t.Errorf("got labels %v for namespace bar, want %v", ns.Labels, want)
+	}
+	dep, _ := client.AppsV1().Deployments("bar").Get("details", metav1.GetOptions{})
+	if _, f := dep.Spec.Template.Annotations[restartedAtAnnotation]; !f {
+		t.Errorf("deployment details.bar was not restarted")
+	}
+	if !reflect.DeepEqual(healthChecked, []string{"bar"}) {
+		t.Errorf("got health checked for %v, want [bar]", healthChecked)
+	}
+
+	// The migration pauses when the namespace is unhealthy.
+	migrationHealthFactory = func() (migrationHealth, func(), error) {
+		return func(ns string) (float64, float64, error) {
+			return 0, 0.5, nil
+		}, func() {}, nil
+	}
+	if _, err = runRevisionMigrate("--to 1-5 --percent 100"); err == nil || !strings.Contains(err.Error(), "migration paused after namespace foo") {
+		t.Errorf("got error %v, want migration paused", err)
+	}
+}
+
+func TestRevisionMigrateWithoutWebhook(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		revisionNamespace("bar", map[string]string{injectionLabel: "enabled"}),
+		revisionWebhook("1-4"),
+	)
+	defer func(f func(string) (kubernetes.Interface, error)) { interfaceFactory = f }(interfaceFactory)
+	interfaceFactory = func(_ string) (kubernetes.Interface, error) {
+		return client, nil
+	}
+
+	_, err := runRevisionMigrate("--to 1-5 --percent 100 --max-nack-rate 0 --max-error-rate 0")
+	if err == nil || !strings.Contains(err.Error(), "no injection webhook selects namespace bar") {
+		t.Errorf("got error %v, want the missing webhook of the revision", err)
+	}
+	ns, _ := client.CoreV1().Namespaces().Get("bar", metav1.GetOptions{})
+	if want := map[string]string{injectionLabel: "enabled"}; !reflect.DeepEqual(ns.Labels, want) {
+		t.Errorf("got labels %v for namespace bar, want them unchanged", ns.Labels)
+	}
+}
+
+func TestProxyNodeRegex(t *testing.T) {
+	re := regexp.MustCompile("^" + proxyNodeRegex("bar") + "$")
+	cases := map[string]bool{
+		"sidecar~10.0.0.1~details-v1-1.bar~bar.svc.cluster.local": true,
+		"sidecar~10.0.0.2~details-v1-1.foo~foo.svc.cluster.local": false,
+		"sidecar~10.0.0.3~bar.foo~foo.svc.cluster.local":          false,
+		"router~10.0.0.4~gateway-1.barn~barn.svc.cluster.local":   false,
+	}
+	for id, want := range cases {
+		if got := re.MatchString(id); got != want {
+			t.Errorf("%s: got match %v, want %v", id, got, want)
+		}
+	}
+}
+
+func runRevisionMigrate(args string) (string, error) {
+	var out bytes.Buffer
+	rootCmd := GetRootCmd(strings.Split("experimental revision migrate "+args, " "))
+	rootCmd.SetOutput(&out)
+	err := rootCmd.Execute()
+	return out.String(), err
+}
@@ -147,10 +147,17 @@ debug and diagnose their Istio mesh.
 	experimentalCmd.AddCommand(uninjectCommand())
 	experimentalCmd.AddCommand(metricsCmd)
 	experimentalCmd.AddCommand(describe())
+	experimentalCmd.AddCommand(explainCmd())
 	experimentalCmd.AddCommand(addToMeshCmd())
 	experimentalCmd.AddCommand(removeFromMeshCmd())
 	experimentalCmd.AddCommand(Analyze())
 	experimentalCmd.AddCommand(waitCmd())
+	experimentalCmd.AddCommand(sidecarRecommendCmd())
+	experimentalCmd.AddCommand(scaffoldCmd())
+	experimentalCmd.AddCommand(revisionCmd())
+	experimentalCmd.AddCommand(loadTestCmd())
+	experimentalCmd.AddCommand(rootRotationCmd())
+	experimentalCmd.AddCommand(refreshGatewaySecretCmd())
 
 	postInstallCmd.AddCommand(Webhook())
 	experimentalCmd.AddCommand(postInstallCmd)
@@ -166,6 +173,8 @@ debug and diagnose their Istio mesh.
 	experimentalCmd.AddCommand(mesh.UpgradeCmd())
 
 	experimentalCmd.AddCommand(multicluster.NewCreateRemoteSecretCommand())
+	experimentalCmd.AddCommand(multicluster.NewCreateIntermediateCACommand())
+	experimentalCmd.AddCommand(multicluster.NewRevokeIntermediateCACommand())
 	experimentalCmd.AddCommand(multicluster.NewMulticlusterCommand())
 
 	rootCmd.AddCommand(collateral.CobraCommand(rootCmd, &doc.GenManHeader{
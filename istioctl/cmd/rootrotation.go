@@ -0,0 +1,304 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/security/pkg/pki/ca"
+	caserver "istio.io/istio/security/pkg/server/ca"
+)
+
+const (
+	rootRotationPath = "/ca/rootrotation"
+	// rootRotationPhaseKey is the key of the phase in the root rotation ConfigMap.
+	rootRotationPhaseKey = "phase"
+)
+
+var (
+	rootRotationConfigMap string
+	rootRotationThreshold float32
+	rootRotationForce     bool
+	rootRotationWait      bool
+	rootRotationTimeout   time.Duration
+)
+
+func rootRotationCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "root-rotation",
+		Short: "Rotate the root of the self-signed istiod CA without disrupting the mesh",
+		Long: `Rotates the root of the self-signed istiod CA in phases, verifying that each phase reached the
+proxies before moving to the next one:
+
+1. start distributes a new root to the proxies, with their trust bundle. The certificates are still
+   signed by the current root.
+2. switch signs the certificates with the key of the new root, once the proxies trust it. The previous
+   root is still trusted.
+3. complete drops the previous root, once the proxies got certificates signed by the new root.
+
+The proxies get the roots with their certificates, which they renew periodically, so each phase
+takes up to the lifetime of the workload certificates to reach all the proxies.
+`,
+		Example: `
+# Start the rotation, and wait until all the proxies trust the new root.
+istioctl experimental root-rotation start
+istioctl experimental root-rotation status --wait --timeout 24h
+
+# Sign with the new root, and drop the previous root once all the proxies use it.
+istioctl experimental root-rotation switch
+istioctl experimental root-rotation status --wait --timeout 24h
+istioctl experimental root-rotation complete
+`,
+	}
+	cmd.PersistentFlags().StringVar(&rootRotationConfigMap, "configmap", "istio-ca-root-rotation",
+		"the ConfigMap of the istio namespace driving the root rotation, CA_ROOT_ROTATION_CONFIGMAP of istiod")
+	cmd.PersistentFlags().Float32Var(&rootRotationThreshold, "threshold", 1,
+		"the ratio of the proxies which must have got the phase")
+
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "Show the phase of the root rotation, and the ratio of the proxies which got it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			deadline := time.Now().Add(rootRotationTimeout)
+			for {
+				reports, err := rootRotationReports()
+				if err != nil {
+					return err
+				}
+				done := printRootRotationStatus(cmd.OutOrStdout(), reports)
+				if done || !rootRotationWait {
+					return nil
+				}
+				if time.Now().After(deadline) {
+					return fmt.Errorf("timeout expired before the phase reached the proxies")
+				}
+				time.Sleep(pollInterval)
+			}
+		},
+	}
+	status.Flags().BoolVar(&rootRotationWait, "wait", false,
+		"wait until the ratio of the proxies which got the phase reaches the threshold")
+	status.Flags().DurationVar(&rootRotationTimeout, "timeout", 30*time.Second,
+		"the duration to wait before failing")
+
+	start := &cobra.Command{
+		Use:   "start",
+		Short: "Distribute a new root to the proxies",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return moveRootRotation(cmd.OutOrStdout(), ca.RootRotationIdle, ca.RootRotationDistributing, false)
+		},
+	}
+	switchCmd := &cobra.Command{
+		Use:   "switch",
+		Short: "Sign the certificates with the new root, once the proxies trust it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return moveRootRotation(cmd.OutOrStdout(), ca.RootRotationDistributing, ca.RootRotationSwitched, true)
+		},
+	}
+	complete := &cobra.Command{
+		Use:   "complete",
+		Short: "Drop the previous root, once the proxies got certificates signed by the new root",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return moveRootRotation(cmd.OutOrStdout(), ca.RootRotationSwitched, ca.RootRotationIdle, true)
+		},
+	}
+	abort := &cobra.Command{
+		Use:   "abort",
+		Short: "Drop the new root before switching to it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return moveRootRotation(cmd.OutOrStdout(), ca.RootRotationDistributing, ca.RootRotationIdle, false)
+		},
+	}
+	for _, c := range []*cobra.Command{switchCmd, complete} {
+		c.Flags().BoolVar(&rootRotationForce, "force", false,
+			"move to the next phase even if the ratio of the proxies which got the current phase is below the threshold")
+	}
+	cmd.AddCommand(status, start, switchCmd, complete, abort)
+	return cmd
+}
+
+// rootRotationReports returns the root rotation reports of the istiod replicas, by pod name.
+func rootRotationReports() (map[string]caserver.RootRotationReport, error) {
+	kubeClient, err := clientExecFactory(kubeconfig, configContext)
+	if err != nil {
+		return nil, err
+	}
+	results, err := kubeClient.AllPilotsDiscoveryDo(istioNamespace, "GET", rootRotationPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query istiod for the root rotation: %v", err)
+	}
+	reports := map[string]caserver.RootRotationReport{}
+	for pod, result := range results {
+		var report caserver.RootRotationReport
+		if err := json.Unmarshal(result, &report); err != nil {
+			return nil, fmt.Errorf("unexpected root rotation report of %s (is the istiod CA self-signed?): %s", pod, result)
+		}
+		reports[pod] = report
+	}
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("no root rotation report from istiod")
+	}
+	return reports, nil
+}
+
+// rootRotationStatus returns the status of the root rotation, the same for all the replicas once they
+// loaded the CA secret.
+func rootRotationStatus(reports map[string]caserver.RootRotationReport) (ca.RootRotationStatus, error) {
+	var status *ca.RootRotationStatus
+	for pod, report := range reports {
+		if status != nil && *status != report.Status {
+			return report.Status, fmt.Errorf("the root rotation status of %s differs from the other istiods, "+
+				"retry once the replicas are in sync", pod)
+		}
+		s := report.Status
+		status = &s
+	}
+	return *status, nil
+}
+
+// rootDistribution returns the number of the proxies connected to istiod which got the given root,
+// and the number of proxies. With signing, the proxies must have a certificate signed by the root,
+// otherwise the root must be in their trust bundle. The proxies may get their certificates from
+// another istiod than the one they are connected to.
+func rootDistribution(reports map[string]caserver.RootRotationReport, root string, signing bool) (int, int) {
+	deliveries := map[string]caserver.RootDelivery{}
+	proxies := map[string][]string{}
+	for _, report := range reports {
+		for address, delivery := range report.Deliveries {
+			if d, f := deliveries[address]; !f || delivery.Time.After(d.Time) {
+				deliveries[address] = delivery
+			}
+		}
+		for _, proxy := range report.Proxies {
+			proxies[proxy.ID] = append(proxies[proxy.ID], proxy.Addresses...)
+		}
+	}
+	distributed := 0
+	for _, addresses := range proxies {
+		for _, address := range addresses {
+			delivery, f := deliveries[address]
+			if f && (signing && delivery.SigningRoot == root || !signing && delivery.HasRoot(root)) {
+				distributed++
+				break
+			}
+		}
+	}
+	return distributed, len(proxies)
+}
+
+// phaseDistribution returns the distribution of the phase of the status: the new root in the
+// distributing phase, the certificates signed by the signing root otherwise.
+func phaseDistribution(reports map[string]caserver.RootRotationReport, status ca.RootRotationStatus) (int, int) {
+	if status.Phase == ca.RootRotationDistributing {
+		return rootDistribution(reports, status.PendingRoot, false)
+	}
+	return rootDistribution(reports, status.SigningRoot, true)
+}
+
+func reachedThreshold(distributed, total int) bool {
+	return total == 0 || float32(distributed)/float32(total) >= rootRotationThreshold
+}
+
+// printRootRotationStatus prints the status of the root rotation, and returns whether the phase reached
+// the threshold of the proxies.
+func printRootRotationStatus(w io.Writer, reports map[string]caserver.RootRotationReport) bool {
+	status, err := rootRotationStatus(reports)
+	if err != nil {
+		_, _ = fmt.Fprintf(w, "%v\n", err)
+		return false
+	}
+	_, _ = fmt.Fprintf(w, "Phase: %s\nSigning root: %s\n", status.Phase, status.SigningRoot)
+	if status.PendingRoot != "" {
+		_, _ = fmt.Fprintf(w, "Pending root: %s\n", status.PendingRoot)
+	}
+	if status.PreviousRoot != "" {
+		_, _ = fmt.Fprintf(w, "Previous root: %s\n", status.PreviousRoot)
+	}
+	distributed, total := phaseDistribution(reports, status)
+	if status.Phase == ca.RootRotationDistributing {
+		_, _ = fmt.Fprintf(w, "Pending root trusted by %d out of %d proxies\n", distributed, total)
+	} else {
+		_, _ = fmt.Fprintf(w, "Certificates signed by the signing root on %d out of %d proxies\n", distributed, total)
+	}
+	return reachedThreshold(distributed, total)
+}
+
+// moveRootRotation moves the root rotation from a phase to the next one, by setting the phase of the
+// root rotation ConfigMap. With checkDistribution, the current phase must have reached the threshold
+// of the proxies, unless forced.
+func moveRootRotation(w io.Writer, from, to ca.RootRotationPhase, checkDistribution bool) error {
+	reports, err := rootRotationReports()
+	if err != nil {
+		return err
+	}
+	status, err := rootRotationStatus(reports)
+	if err != nil {
+		return err
+	}
+	if status.Phase != from {
+		return fmt.Errorf("the root rotation is in the %s phase, expecting the %s phase", status.Phase, from)
+	}
+	if checkDistribution {
+		distributed, total := phaseDistribution(reports, status)
+		if !reachedThreshold(distributed, total) && !rootRotationForce {
+			return fmt.Errorf("the %s phase reached %d out of %d proxies, below the threshold %v "+
+				"(wait with status --wait, or use --force)", status.Phase, distributed, total, rootRotationThreshold)
+		}
+	}
+	if err := setRootRotationPhase(to); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(w, "Root rotation moved to the %s phase, check its progress with the status command\n", to)
+	return nil
+}
+
+// setRootRotationPhase sets the phase of the root rotation ConfigMap, creating it if needed.
+func setRootRotationPhase(phase ca.RootRotationPhase) error {
+	client, err := interfaceFactory(kubeconfig)
+	if err != nil {
+		return err
+	}
+	configMaps := client.CoreV1().ConfigMaps(istioNamespace)
+	cm, err := configMaps.Get(rootRotationConfigMap, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = configMaps.Create(&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: rootRotationConfigMap, Namespace: istioNamespace},
+			Data:       map[string]string{rootRotationPhaseKey: string(phase)},
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[rootRotationPhaseKey] = string(phase)
+	_, err = configMaps.Update(cm)
+	return err
+}
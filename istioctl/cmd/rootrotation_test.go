@@ -0,0 +1,125 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/pki/ca"
+	caserver "istio.io/istio/security/pkg/server/ca"
+)
+
+func TestRootDistribution(t *testing.T) {
+	now := time.Now()
+	reports := map[string]caserver.RootRotationReport{
+		"istiod-a": {
+			Proxies: []caserver.ConnectedProxy{
+				{ID: "a.default", Addresses: []string{"10.0.0.1"}},
+				{ID: "b.default", Addresses: []string{"10.0.0.2"}},
+			},
+			Deliveries: map[string]caserver.RootDelivery{
+				"10.0.0.1": {Roots: []string{"old"}, SigningRoot: "old", Time: now.Add(-time.Hour)},
+			},
+		},
+		// The proxies may get their certificates from another istiod.
+		"istiod-b": {
+			Proxies: []caserver.ConnectedProxy{
+				{ID: "c.default", Addresses: []string{"10.0.0.3"}},
+			},
+			Deliveries: map[string]caserver.RootDelivery{
+				"10.0.0.1": {Roots: []string{"old", "new"}, SigningRoot: "old", Time: now},
+				"10.0.0.3": {Roots: []string{"new", "old"}, SigningRoot: "new", Time: now},
+			},
+		},
+	}
+	testCases := []struct {
+		root        string
+		signing     bool
+		distributed int
+	}{
+		{"new", false, 2},
+		{"old", false, 2},
+		{"new", true, 1},
+		{"old", true, 1},
+		{"other", false, 0},
+	}
+	for _, tc := range testCases {
+		distributed, total := rootDistribution(reports, tc.root, tc.signing)
+		if distributed != tc.distributed || total != 3 {
+			t.Errorf("rootDistribution(%s, %v) = %d/%d, want %d/3", tc.root, tc.signing, distributed, total, tc.distributed)
+		}
+	}
+}
+
+func TestMoveRootRotation(t *testing.T) {
+	report := caserver.RootRotationReport{
+		Status: ca.RootRotationStatus{Phase: ca.RootRotationDistributing, SigningRoot: "old", PendingRoot: "new"},
+		Proxies: []caserver.ConnectedProxy{
+			{ID: "a.default", Addresses: []string{"10.0.0.1"}},
+			{ID: "b.default", Addresses: []string{"10.0.0.2"}},
+		},
+		Deliveries: map[string]caserver.RootDelivery{
+			"10.0.0.1": {Roots: []string{"old", "new"}, SigningRoot: "old", Time: time.Now()},
+		},
+	}
+	out, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientExecFactory = mockClientExecFactoryGenerator(map[string][]byte{"istiod-a": out})
+	client := fake.NewSimpleClientset()
+	interfaceFactory = func(string) (kubernetes.Interface, error) {
+		return client, nil
+	}
+	istioNamespace = "istio-system"
+	rootRotationConfigMap = "istio-ca-root-rotation"
+	rootRotationThreshold = 1
+	rootRotationForce = false
+
+	var buf bytes.Buffer
+	if err := moveRootRotation(&buf, ca.RootRotationIdle, ca.RootRotationDistributing, false); err == nil {
+		t.Error("starting a rotation in the distributing phase should fail")
+	}
+	// Half of the proxies trust the new root.
+	if err := moveRootRotation(&buf, ca.RootRotationDistributing, ca.RootRotationSwitched, true); err == nil {
+		t.Error("switching below the threshold should fail")
+	}
+	rootRotationThreshold = 0.5
+	if err := moveRootRotation(&buf, ca.RootRotationDistributing, ca.RootRotationSwitched, true); err != nil {
+		t.Fatal(err)
+	}
+	cm, err := client.CoreV1().ConfigMaps(istioNamespace).Get(rootRotationConfigMap, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cm.Data[rootRotationPhaseKey]; got != string(ca.RootRotationSwitched) {
+		t.Errorf("got phase %q, want %q", got, ca.RootRotationSwitched)
+	}
+
+	buf.Reset()
+	if done := printRootRotationStatus(&buf, map[string]caserver.RootRotationReport{"istiod-a": report}); !done {
+		t.Errorf("the phase should reach the threshold: %s", buf.String())
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("Pending root trusted by 1 out of 2 proxies")) {
+		t.Errorf("unexpected status output %s", buf.String())
+	}
+}
@@ -0,0 +1,413 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/hashicorp/go-multierror"
+	"github.com/spf13/cobra"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+	"istio.io/istio/pkg/config/validation"
+)
+
+const (
+	scaffoldGatewaySelector = "ingressgateway"
+	scaffoldGatewayHTTPPort = 80
+)
+
+var (
+	scaffoldFilename    string
+	scaffoldOutFilename string
+)
+
+// scaffoldSpec is the minimal description of a service the Istio configuration is generated from.
+type scaffoldSpec struct {
+	// Name of the Kubernetes Service.
+	Name string `json:"name"`
+	// Namespace of the Service, defaults to the namespace of the command.
+	Namespace string `json:"namespace,omitempty"`
+	// Hosts the service is exposed on through the ingress gateway. The service is only
+	// reachable inside the mesh if empty.
+	Hosts []string `json:"hosts,omitempty"`
+	// Ports of the service.
+	Ports []scaffoldPort `json:"ports"`
+	// Subsets, or versions, of the service traffic is split between.
+	Subsets []scaffoldSubset `json:"subsets,omitempty"`
+}
+
+type scaffoldPort struct {
+	Name   string `json:"name,omitempty"`
+	Number uint32 `json:"number"`
+	// Protocol of the port, HTTP if not set.
+	Protocol string `json:"protocol,omitempty"`
+}
+
+type scaffoldSubset struct {
+	Name string `json:"name"`
+	// Labels selecting the pods of the subset.
+	Labels map[string]string `json:"labels"`
+	// Weight is the percentage of the traffic sent to the subset.
+	Weight int32 `json:"weight,omitempty"`
+}
+
+func (p scaffoldPort) protocol() protocol.Instance {
+	if p.Protocol == "" {
+		return protocol.HTTP
+	}
+	return protocol.Parse(p.Protocol)
+}
+
+func scaffoldCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scaffold",
+		Short: "Generate Istio configuration for a service from a simple description",
+		Long: `
+Generates a starting point of Istio configuration for a service: a DestinationRule
+defining its subsets, a VirtualService splitting the traffic between them and,
+if external hosts are listed, a Gateway exposing it through the ingress gateway.
+
+The description is a YAML file with the name of the service, its ports, its
+subsets and their share of the traffic. The generated configuration contains
+comments about what each resource does and is meant to be reviewed and
+extended before applying.
+`,
+		Example: `
+# Generate the configuration of a service with a canary version
+cat <<EOF > reviews.yaml
+name: reviews
+hosts:
+- reviews.example.com
+ports:
+- number: 9080
+subsets:
+- name: v1
+  labels:
+    version: v1
+  weight: 90
+- name: v2
+  labels:
+    version: v2
+  weight: 10
+EOF
+istioctl experimental scaffold -f reviews.yaml | kubectl apply -f -
+`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if scaffoldFilename == "" {
+				return errors.New("no input file provided")
+			}
+
+			var reader io.Reader
+			if scaffoldFilename == "-" {
+				reader = c.InOrStdin()
+			} else {
+				file, err := os.Open(scaffoldFilename)
+				if err != nil {
+					return err
+				}
+				defer file.Close() // nolint: errcheck
+				reader = file
+			}
+
+			writer := c.OutOrStdout()
+			if scaffoldOutFilename != "-" {
+				file, err := os.Create(scaffoldOutFilename)
+				if err != nil {
+					return err
+				}
+				defer file.Close() // nolint: errcheck
+				writer = file
+			}
+
+			return scaffold(reader, writer, handlers.HandleNamespace(namespace, defaultNamespace))
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&scaffoldFilename, "filename", "f", "",
+		"Service description file, or '-' to read from stdin")
+	cmd.PersistentFlags().StringVarP(&scaffoldOutFilename, "output", "o", "-",
+		"Output filename")
+
+	return cmd
+}
+
+// scaffold reads a service description and writes the generated configuration, in YAML.
+func scaffold(reader io.Reader, writer io.Writer, defaultNs string) error {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	js, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return fmt.Errorf("could not parse service description: %v", err)
+	}
+	// Reject unknown fields, as a typo would otherwise silently drop part of the description.
+	decoder := json.NewDecoder(bytes.NewReader(js))
+	decoder.DisallowUnknownFields()
+	spec := &scaffoldSpec{}
+	if err := decoder.Decode(spec); err != nil {
+		return fmt.Errorf("could not parse service description: %v", err)
+	}
+	if spec.Namespace == "" {
+		spec.Namespace = defaultNs
+	}
+	if err := validateScaffoldSpec(spec); err != nil {
+		return err
+	}
+
+	out := &bytes.Buffer{}
+	configs := make([]model.Config, 0, 3)
+	if len(spec.Hosts) > 0 {
+		gw := scaffoldGateway(spec)
+		configs = append(configs, gw)
+		writeScaffoldConfig(out, gw,
+			fmt.Sprintf("Gateway exposing %s through the ingress gateway on %s.", spec.Name, strings.Join(spec.Hosts, ", ")),
+			"Add a tls section to the servers to terminate HTTPS at the gateway.")
+	}
+
+	vs := scaffoldVirtualService(spec)
+	configs = append(configs, vs)
+	writeScaffoldConfig(out, vs,
+		fmt.Sprintf("VirtualService routing the traffic for %s%s.", spec.Name, describeSplit(spec.Subsets)),
+		"Change the weights to shift traffic between subsets, or add match conditions, retries and timeouts to the routes.")
+
+	dr := scaffoldDestinationRule(spec)
+	configs = append(configs, dr)
+	writeScaffoldConfig(out, dr,
+		fmt.Sprintf("DestinationRule defining the subsets of %s by the labels of its pods.", spec.Name),
+		"Add a trafficPolicy to configure load balancing, connection pools, outlier detection or TLS.")
+
+	if err := validateScaffoldConfigs(configs); err != nil {
+		return multierror.Prefix(err, "generated config(s) are invalid:")
+	}
+	_, err = out.WriteTo(writer)
+	return err
+}
+
+func validateScaffoldSpec(spec *scaffoldSpec) error {
+	var errs error
+	if spec.Name == "" {
+		errs = multierror.Append(errs, errors.New("name is required"))
+	}
+	if len(spec.Ports) == 0 {
+		errs = multierror.Append(errs, errors.New("at least one port is required"))
+	}
+	for _, p := range spec.Ports {
+		if p.protocol() == protocol.Unsupported {
+			errs = multierror.Append(errs, fmt.Errorf("port %d: unsupported protocol %q", p.Number, p.Protocol))
+		}
+	}
+	total := int32(0)
+	for _, s := range spec.Subsets {
+		if s.Name == "" || len(s.Labels) == 0 {
+			errs = multierror.Append(errs, fmt.Errorf("subset %q: name and labels are required", s.Name))
+		}
+		total += s.Weight
+	}
+	if total != 0 && total != 100 {
+		errs = multierror.Append(errs, fmt.Errorf("the weights of the subsets add up to %d, must be 100", total))
+	}
+	return errs
+}
+
+func scaffoldConfigMeta(s schema.Instance, name, namespace string) model.ConfigMeta {
+	return model.ConfigMeta{
+		Type:      s.Type,
+		Group:     s.Group,
+		Version:   s.Version,
+		Name:      name,
+		Namespace: namespace,
+	}
+}
+
+func scaffoldGatewayName(spec *scaffoldSpec) string {
+	return spec.Name + "-gateway"
+}
+
+func scaffoldGateway(spec *scaffoldSpec) model.Config {
+	servers := make([]*networking.Server, 0)
+	httpServer := false
+	for _, p := range spec.Ports {
+		if p.protocol().IsHTTP() {
+			// All the HTTP ports of the service share the HTTP port of the gateway.
+			if httpServer {
+				continue
+			}
+			httpServer = true
+			servers = append(servers, &networking.Server{
+				Port:  &networking.Port{Number: scaffoldGatewayHTTPPort, Name: "http", Protocol: string(protocol.HTTP)},
+				Hosts: spec.Hosts,
+			})
+			continue
+		}
+		servers = append(servers, &networking.Server{
+			Port:  &networking.Port{Number: p.Number, Name: fmt.Sprintf("tcp-%d", p.Number), Protocol: string(protocol.TCP)},
+			Hosts: spec.Hosts,
+		})
+	}
+
+	return model.Config{
+		ConfigMeta: scaffoldConfigMeta(schemas.Gateway, scaffoldGatewayName(spec), spec.Namespace),
+		Spec: &networking.Gateway{
+			Selector: map[string]string{"istio": scaffoldGatewaySelector},
+			Servers:  servers,
+		},
+	}
+}
+
+// scaffoldRouteDestinations returns the destinations of a route to the port of the service.
+func scaffoldRouteDestinations(spec *scaffoldSpec, port uint32) []*networking.HTTPRouteDestination {
+	if len(spec.Subsets) == 0 {
+		return []*networking.HTTPRouteDestination{{
+			Destination: &networking.Destination{Host: spec.Name, Port: &networking.PortSelector{Number: port}},
+		}}
+	}
+	out := make([]*networking.HTTPRouteDestination, 0, len(spec.Subsets))
+	for i, s := range spec.Subsets {
+		weight := s.Weight
+		if weight == 0 && i == 0 {
+			// Without weights, all the traffic goes to the first subset.
+			weight = 100
+		}
+		out = append(out, &networking.HTTPRouteDestination{
+			Destination: &networking.Destination{Host: spec.Name, Subset: s.Name, Port: &networking.PortSelector{Number: port}},
+			Weight:      weight,
+		})
+	}
+	return out
+}
+
+func scaffoldVirtualService(spec *scaffoldSpec) model.Config {
+	vs := &networking.VirtualService{
+		Hosts: append([]string{spec.Name}, spec.Hosts...),
+	}
+	if len(spec.Hosts) > 0 {
+		// Routes apply to the traffic from the ingress gateway, and from the sidecars.
+		vs.Gateways = []string{scaffoldGatewayName(spec), "mesh"}
+	}
+
+	gatewayRouted := false
+	for _, p := range spec.Ports {
+		if p.protocol().IsHTTP() {
+			route := &networking.HTTPRoute{
+				Route: scaffoldRouteDestinations(spec, p.Number),
+			}
+			if len(spec.Ports) > 1 {
+				route.Match = []*networking.HTTPMatchRequest{{Port: p.Number}}
+				if len(spec.Hosts) > 0 && !gatewayRouted {
+					// Requests on the HTTP port of the gateway go to the first HTTP port of the service.
+					gatewayRouted = true
+					route.Match = append(route.Match, &networking.HTTPMatchRequest{
+						Port:     scaffoldGatewayHTTPPort,
+						Gateways: []string{scaffoldGatewayName(spec)},
+					})
+				}
+			}
+			vs.Http = append(vs.Http, route)
+			continue
+		}
+		routes := make([]*networking.RouteDestination, 0)
+		for _, d := range scaffoldRouteDestinations(spec, p.Number) {
+			routes = append(routes, &networking.RouteDestination{Destination: d.Destination, Weight: d.Weight})
+		}
+		vs.Tcp = append(vs.Tcp, &networking.TCPRoute{
+			Match: []*networking.L4MatchAttributes{{Port: p.Number}},
+			Route: routes,
+		})
+	}
+
+	return model.Config{
+		ConfigMeta: scaffoldConfigMeta(schemas.VirtualService, spec.Name, spec.Namespace),
+		Spec:       vs,
+	}
+}
+
+func scaffoldDestinationRule(spec *scaffoldSpec) model.Config {
+	dr := &networking.DestinationRule{
+		Host: spec.Name,
+	}
+	for _, s := range spec.Subsets {
+		dr.Subsets = append(dr.Subsets, &networking.Subset{
+			Name:   s.Name,
+			Labels: s.Labels,
+		})
+	}
+	return model.Config{
+		ConfigMeta: scaffoldConfigMeta(schemas.DestinationRule, spec.Name, spec.Namespace),
+		Spec:       dr,
+	}
+}
+
+// describeSplit describes how the traffic is split between the subsets.
+func describeSplit(subsets []scaffoldSubset) string {
+	if len(subsets) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(subsets))
+	for i, s := range subsets {
+		weight := s.Weight
+		if weight == 0 && i == 0 {
+			weight = 100
+		}
+		parts = append(parts, fmt.Sprintf("%d%% to subset %s", weight, s.Name))
+	}
+	return ": " + strings.Join(parts, ", ")
+}
+
+// writeScaffoldConfig writes the config in YAML, preceded by comments.
+func writeScaffoldConfig(out *bytes.Buffer, cfg model.Config, comments ...string) {
+	if out.Len() > 0 {
+		out.WriteString("---\n")
+	}
+	for _, c := range comments {
+		out.WriteString("# " + c + "\n")
+	}
+	writeYAMLOutput(schema.Set{schemas.Gateway, schemas.VirtualService, schemas.DestinationRule},
+		[]model.Config{cfg}, out)
+}
+
+func validateScaffoldConfigs(configs []model.Config) error {
+	var errs error
+	for _, cfg := range configs {
+		var err error
+		switch cfg.Type {
+		case schemas.Gateway.Type:
+			err = validation.ValidateGateway(cfg.Name, cfg.Namespace, cfg.Spec)
+		case schemas.VirtualService.Type:
+			err = validation.ValidateVirtualService(cfg.Name, cfg.Namespace, cfg.Spec)
+		case schemas.DestinationRule.Type:
+			err = validation.ValidateDestinationRule(cfg.Name, cfg.Namespace, cfg.Spec)
+		}
+		if err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"istio.io/istio/pilot/test/util"
+)
+
+func TestScaffold(t *testing.T) {
+	for _, name := range []string{"canary", "multi-port"} {
+		t.Run(name, func(t *testing.T) {
+			in, err := os.Open("testdata/scaffold/" + name + ".yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer in.Close() // nolint: errcheck
+
+			out := &bytes.Buffer{}
+			if err := scaffold(in, out, "default"); err != nil {
+				t.Fatalf("Unexpected error generating configs: %v", err)
+			}
+			util.CompareContent(out.Bytes(), "testdata/scaffold/"+name+".yaml.golden", t)
+		})
+	}
+}
+
+func TestScaffoldInvalid(t *testing.T) {
+	cases := []struct {
+		name    string
+		in      string
+		wantErr string
+	}{
+		{
+			name:    "missing name",
+			in:      "ports:\n- number: 80\n",
+			wantErr: "name is required",
+		},
+		{
+			name:    "missing ports",
+			in:      "name: reviews\n",
+			wantErr: "at least one port is required",
+		},
+		{
+			name:    "weights",
+			in:      "name: reviews\nports:\n- number: 80\nsubsets:\n- name: v1\n  labels: {version: v1}\n  weight: 50\n",
+			wantErr: "add up to 50",
+		},
+		{
+			name:    "unknown field",
+			in:      "name: reviews\nport: 80\n",
+			wantErr: "unknown field",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := scaffold(strings.NewReader(c.in), &bytes.Buffer{}, "default")
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("scaffold() => %v, want error containing %q", err, c.wantErr)
+			}
+		})
+	}
+}
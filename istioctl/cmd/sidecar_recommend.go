@@ -0,0 +1,203 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	prommodel "github.com/prometheus/common/model"
+	"github.com/spf13/cobra"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/pkg/log"
+
+	"istio.io/istio/istioctl/pkg/kubernetes"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+const (
+	dstSvcLabel   = "destination_service"
+	dstSvcNsLabel = "destination_service_namespace"
+	srcWlNsLabel  = "source_workload_namespace"
+	tcpOpenedTot  = "istio_tcp_connections_opened_total"
+
+	unknownLabelValue = "unknown"
+)
+
+var (
+	recommendWindow       time.Duration
+	recommendDefaultHosts []string
+)
+
+func sidecarRecommendCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sidecar-recommend <namespace>...",
+		Short: "Recommends Sidecar resources based on the traffic observed in the mesh.",
+		Long: `
+Recommends a default Sidecar resource for each of the specified namespaces.
+
+This command finds a Prometheus pod running in the specified istio system
+namespace, and queries the services the workloads of each namespace sent
+HTTP, gRPC or TCP traffic to during the observation window. The generated
+Sidecar only exports these services to the proxies of the namespace, which
+reduces the size of their configuration in large meshes.
+
+The recommendation only reflects observed traffic: review it before applying,
+as dependencies that were not exercised during the window are not included.
+`,
+		Example: `
+# Recommend a Sidecar for the default namespace from the traffic of the last 24 hours
+istioctl experimental sidecar-recommend default
+
+# Recommend Sidecars for several namespaces, from the traffic of the last week
+istioctl experimental sidecar-recommend foo bar --window 168h | kubectl apply -f -
+`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("sidecar-recommend requires at least one namespace")
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			client, err := clientExecFactory(kubeconfig, configContext)
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %v", err)
+			}
+
+			pl, err := client.PodsForSelector(istioNamespace, "app=prometheus")
+			if err != nil {
+				return fmt.Errorf("not able to locate Prometheus pod: %v", err)
+			}
+
+			if len(pl.Items) < 1 {
+				return errors.New("no Prometheus pods found")
+			}
+
+			// only use the first pod in the list
+			promPod := pl.Items[0]
+			fw, err := client.BuildPortForwarder(promPod.Name, istioNamespace, 0, 9090)
+			if err != nil {
+				return fmt.Errorf("could not build port forwarder for prometheus: %v", err)
+			}
+
+			if err = kubernetes.RunPortForwarder(fw, func(fw *kubernetes.PortForward) error {
+				log.Debugf("port-forward to prometheus pod ready")
+
+				promAPI, err := prometheusAPI(fw.LocalPort)
+				if err != nil {
+					return err
+				}
+
+				configs := make([]model.Config, 0, len(args))
+				for _, namespace := range args {
+					hosts, err := observedEgressHosts(promAPI, namespace, recommendWindow)
+					if err != nil {
+						return fmt.Errorf("could not find egress hosts for namespace '%s': %v", namespace, err)
+					}
+					configs = append(configs, recommendSidecar(namespace, append(hosts, recommendDefaultHosts...)))
+				}
+				writeYAMLOutput(schema.Set{schemas.Sidecar}, configs, c.OutOrStdout())
+
+				close(fw.StopChannel)
+				return nil
+			}); err != nil {
+				return fmt.Errorf("failure running port forward process: %v", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.PersistentFlags().DurationVar(&recommendWindow, "window", 24*time.Hour,
+		"The window of observed traffic used for the recommendation")
+	cmd.PersistentFlags().StringSliceVar(&recommendDefaultHosts, "default-hosts", []string{"istio-system/*"},
+		"Egress hosts added to every recommended Sidecar, in namespace/dnsName format")
+
+	return cmd
+}
+
+// observedEgressHosts returns the egress hosts, in namespace/dnsName format, of the services the workloads
+// of the namespace sent traffic to during the window.
+func observedEgressHosts(promAPI promv1.API, namespace string, window time.Duration) ([]string, error) {
+	hosts := make(map[string]struct{})
+	for _, metric := range []string{reqTot, tcpOpenedTot} {
+		query := fmt.Sprintf(`sum(increase(%s{%s="%s",reporter="source"}[%ds])) by (%s, %s) > 0`,
+			metric, srcWlNsLabel, namespace, int64(window.Seconds()), dstSvcLabel, dstSvcNsLabel)
+		log.Debugf("executing query: %s", query)
+		val, _, err := promAPI.Query(context.Background(), query, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("query() failure for '%s': %v", query, err)
+		}
+		vector, ok := val.(prommodel.Vector)
+		if !ok {
+			return nil, errors.New("bad metric value type returned for query")
+		}
+		for _, sample := range vector {
+			svc := string(sample.Metric[dstSvcLabel])
+			if svc == "" || svc == unknownLabelValue {
+				continue
+			}
+			ns := string(sample.Metric[dstSvcNsLabel])
+			if ns == "" || ns == unknownLabelValue {
+				ns = "*"
+			}
+			hosts[ns+"/"+svc] = struct{}{}
+		}
+	}
+
+	out := make([]string, 0, len(hosts))
+	for h := range hosts {
+		out = append(out, h)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// recommendSidecar builds the default Sidecar of the namespace, exporting only the given egress hosts.
+func recommendSidecar(namespace string, hosts []string) model.Config {
+	seen := make(map[string]struct{}, len(hosts))
+	egressHosts := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		if _, f := seen[h]; f {
+			continue
+		}
+		seen[h] = struct{}{}
+		egressHosts = append(egressHosts, h)
+	}
+
+	return model.Config{
+		ConfigMeta: model.ConfigMeta{
+			Type:      schemas.Sidecar.Type,
+			Group:     schemas.Sidecar.Group,
+			Version:   schemas.Sidecar.Version,
+			Name:      "default",
+			Namespace: namespace,
+		},
+		Spec: &networking.Sidecar{
+			Egress: []*networking.IstioEgressListener{
+				{
+					Hosts: egressHosts,
+				},
+			},
+		},
+	}
+}
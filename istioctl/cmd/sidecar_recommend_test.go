@@ -0,0 +1,110 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	prometheus_model "github.com/prometheus/common/model"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+func TestSidecarRecommendNoPrometheus(t *testing.T) {
+	clientExecFactory = mockExecClientAuthNoPilot
+
+	cases := []testCase{
+		{ // case 0
+			args:           strings.Split("experimental sidecar-recommend", " "),
+			expectedRegexp: regexp.MustCompile("Error: sidecar-recommend requires at least one namespace\n"),
+			wantException:  true,
+		},
+		{ // case 1
+			args:           strings.Split("experimental sidecar-recommend default", " "),
+			expectedOutput: "Error: no Prometheus pods found\n",
+			wantException:  true,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("case %d %s", i, strings.Join(c.args, " ")), func(t *testing.T) {
+			verifyOutput(t, c)
+		})
+	}
+}
+
+func TestSidecarRecommend(t *testing.T) {
+	mockProm := mockPromAPI{
+		cannedResponse: map[string]prometheus_model.Value{
+			`sum(increase(istio_requests_total{source_workload_namespace="default",reporter="source"}[3600s])) by (destination_service, destination_service_namespace) > 0`: prometheus_model.Vector{ // nolint: lll
+				&prometheus_model.Sample{Metric: prometheus_model.Metric{
+					dstSvcLabel: "reviews.default.svc.cluster.local", dstSvcNsLabel: "default"}},
+				&prometheus_model.Sample{Metric: prometheus_model.Metric{
+					dstSvcLabel: "ratings.bar.svc.cluster.local", dstSvcNsLabel: "bar"}},
+				&prometheus_model.Sample{Metric: prometheus_model.Metric{
+					dstSvcLabel: "unknown", dstSvcNsLabel: "unknown"}},
+			},
+			`sum(increase(istio_tcp_connections_opened_total{source_workload_namespace="default",reporter="source"}[3600s])) by (destination_service, destination_service_namespace) > 0`: prometheus_model.Vector{ // nolint: lll
+				&prometheus_model.Sample{Metric: prometheus_model.Metric{
+					dstSvcLabel: "mysql.db.svc.cluster.local", dstSvcNsLabel: "db"}},
+				&prometheus_model.Sample{Metric: prometheus_model.Metric{
+					dstSvcLabel: "www.google.com", dstSvcNsLabel: "unknown"}},
+			},
+		},
+	}
+
+	hosts, err := observedEgressHosts(mockProm, "default", time.Hour)
+	if err != nil {
+		t.Fatalf("Unwanted exception %v", err)
+	}
+	expectedHosts := []string{
+		"*/www.google.com",
+		"bar/ratings.bar.svc.cluster.local",
+		"db/mysql.db.svc.cluster.local",
+		"default/reviews.default.svc.cluster.local",
+	}
+	if !reflect.DeepEqual(hosts, expectedHosts) {
+		t.Fatalf("Unexpected hosts; got: %v\nwant: %v", hosts, expectedHosts)
+	}
+
+	var out bytes.Buffer
+	cfg := recommendSidecar("default", append(hosts[2:], "istio-system/*", "db/mysql.db.svc.cluster.local"))
+	writeYAMLOutput(schema.Set{schemas.Sidecar}, []model.Config{cfg}, &out)
+
+	expectedOutput := `apiVersion: networking.istio.io/v1alpha3
+kind: Sidecar
+metadata:
+  creationTimestamp: null
+  name: default
+  namespace: default
+spec:
+  egress:
+  - hosts:
+    - db/mysql.db.svc.cluster.local
+    - default/reviews.default.svc.cluster.local
+    - istio-system/*
+`
+	if out.String() != expectedOutput {
+		t.Fatalf("Unexpected output; got: %q\nwant: %q", out.String(), expectedOutput)
+	}
+}
@@ -34,6 +34,7 @@ const (
 
 	// below here are non-zero exit codes that don't indicate an error with istioctl itself
 	ExitAnalyzerFoundIssues = 79 // istioctl analyze found issues, for CI/CD
+	ExitProxiesNotSynced    = 80 // istioctl proxy-status --fail-on-stale found proxies out of sync
 )
 
 func GetExitCode(e error) int {
@@ -48,6 +49,8 @@ func GetExitCode(e error) int {
 		return ExitDataError
 	case AnalyzerFoundIssuesError:
 		return ExitAnalyzerFoundIssues
+	case ProxiesNotSyncedError:
+		return ExitProxiesNotSynced
 	default:
 		return ExitUnknownError
 	}
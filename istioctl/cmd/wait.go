@@ -19,10 +19,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
+	multierror "github.com/hashicorp/go-multierror"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
 
 	"istio.io/istio/istioctl/pkg/util/handlers"
@@ -33,6 +39,7 @@ import (
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	"istio.io/istio/istioctl/pkg/kubernetes"
 	v2 "istio.io/istio/pilot/pkg/proxy/envoy/v2"
@@ -40,94 +47,109 @@ import (
 )
 
 var (
-	forFlag              string
-	nameflag             string
-	threshold            float32
-	timeout              time.Duration
-	resourceVersion      string
-	verbose              bool
-	targetSchemaInstance configschema.Instance
-	clientGetter         func(string, string) (dynamic.Interface, error)
+	forFlag         string
+	threshold       float32
+	timeout         time.Duration
+	resourceVersion string
+	verbose         bool
+	waitOutput      string
+	labelSelector   string
+	proxySelector   string
+	namespaceScope  []string
+	targets         []waitTarget
+	clientGetter    func(string, string) (dynamic.Interface, error)
+
+	// outputMutex serializes the output of the concurrent waits.
+	outputMutex sync.Mutex
 )
 
 const pollInterval = time.Second
 
+// waitTarget is an Istio resource to wait for. A target without name stands for the resources of
+// its type matching the label selector.
+type waitTarget struct {
+	schema    configschema.Instance
+	name      string
+	namespace string
+}
+
+func (t waitTarget) key() string {
+	return model.Key(t.schema.Type, t.name, t.namespace)
+}
+
 // waitCmd represents the wait command
 func waitCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "wait [flags] <type> <name>[.<namespace>]",
-		Short: "Wait for an Istio resource",
-		Long: `Waits for the specified condition to be true of an Istio resource.  For example:
+		Use:   "wait [flags] <type> <name>[.<namespace>] | <type>/<name>[.<namespace>]... | -l <selector> <type>...",
+		Short: "Wait for Istio resources",
+		Long: `Waits for the specified condition to be true of Istio resources.  For example:
 
 istioctl experimental wait --for=distribution virtual-service bookinfo.default
 
 will block until the bookinfo virtual service has been distributed to all proxies in the mesh.
+
+istioctl experimental wait --for=delete virtual-service bookinfo.default
+
+will block until the bookinfo virtual service has been deleted, and removed from all proxies in the mesh.
+
+istioctl experimental wait virtual-service/bookinfo.default destination-rule/reviews.default
+
+will block until both the bookinfo virtual service and the reviews destination rule have been distributed
+to all proxies in the mesh, printing the progress of each resource as it changes.
+
+istioctl experimental wait -l release=v2 -n default virtual-service destination-rule
+
+will block until all the virtual services and destination rules labeled release=v2 in the default namespace
+have been distributed to all proxies in the mesh.
+
+istioctl experimental wait --proxy-selector app=productpage --namespace-scope default virtual-service reviews.default
+
+will block until the reviews virtual service has been distributed to the productpage proxies in the default
+namespace, regardless of the other proxies in the mesh. Without --namespace-scope, only the proxies in the
+namespaces the resource is exported to are counted.
+
+istioctl experimental wait -o json virtual-service bookinfo.default
+
+will report the distribution of the bookinfo virtual service, in total and by pilot, as a line of JSON
+on each poll until it has been distributed to all proxies in the mesh.
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			printVerbosef(cmd, "kubeconfig %s", kubeconfig)
 			printVerbosef(cmd, "ctx %s", configContext)
-			if forFlag == "delete" {
-				return errors.New("wait for delete is not yet implemented")
-			} else if forFlag != "distribution" {
+			if forFlag != "delete" && forFlag != "distribution" {
 				return fmt.Errorf("--for must be 'delete' or 'distribution', got: %s", forFlag)
 			}
-			var w *watcher
+			if waitOutput != summaryOutput && waitOutput != jsonOutput {
+				return fmt.Errorf("output format %q not supported", waitOutput)
+			}
 			ctx, cancel := context.WithTimeout(context.Background(), timeout)
 			defer cancel()
-			if resourceVersion == "" {
-				w = getAndWatchResource(ctx) // setup version getter from kubernetes
-			} else {
-				w = withContext(ctx)
-				w.Go(func(result chan string) error {
-					result <- resourceVersion
-					return nil
-				})
-			}
-			// wait for all deployed versions to be contained in resourceVersions
-			t := time.NewTicker(pollInterval)
-			printVerbosef(cmd, "getting first version from chan")
-			firstVersion, err := w.BlockingRead()
+			resolved, err := selectTargets(targets)
 			if err != nil {
-				return fmt.Errorf("unable to retrieve kubernetes resource %s: %v", "", err)
+				return err
 			}
-			resourceVersions := []string{firstVersion}
-			targetResource := model.Key(targetSchemaInstance.Type, nameflag, namespace)
-			for {
-				//run the check here as soon as we start
-				// because tickers won't run immediately
-				present, notpresent, err := poll(resourceVersions, targetResource)
-				printVerbosef(cmd, "Received poll result: %d/%d", present, present+notpresent)
-				if err != nil {
-					return err
-				} else if float32(present)/float32(present+notpresent) >= threshold {
-					_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Resource %s present on %d out of %d sidecars\n",
-						targetResource, present, present+notpresent)
+			if len(resolved) == 0 {
+				if forFlag == "delete" {
+					if waitOutput != jsonOutput {
+						_, _ = fmt.Fprintf(cmd.OutOrStdout(), "No resources matching %s found\n", labelSelector)
+					}
 					return nil
 				}
-				select {
-				case newVersion := <-w.resultsChan:
-					printVerbosef(cmd, "received new target version: %s", newVersion)
-					resourceVersions = append(resourceVersions, newVersion)
-				case <-t.C:
-					printVerbosef(cmd, "tick")
-					continue
-				case err = <-w.errorChan:
-					return fmt.Errorf("unable to retrieve kubernetes resource %s: %v", "", err)
-				case <-ctx.Done():
-					printVerbosef(cmd, "timeout")
-					// I think this means the timeout has happened:
-					t.Stop()
-					return fmt.Errorf("timeout expired before resource %s became effective on all sidecars",
-						targetResource)
-				}
+				return fmt.Errorf("no resources matching %s found", labelSelector)
 			}
+
+			return waitForTargets(ctx, cmd, resolved)
 		},
 		Args: func(cmd *cobra.Command, args []string) error {
-			if err := cobra.ExactArgs(2)(cmd, args); err != nil {
+			var err error
+			targets, err = parseTargets(args, handlers.HandleNamespace(namespace, defaultNamespace))
+			if err != nil {
 				return err
 			}
-			nameflag, namespace = handlers.InferPodInfo(args[1], handlers.HandleNamespace(namespace, defaultNamespace))
-			return validateType(args[0])
+			if resourceVersion != "" && (len(targets) != 1 || labelSelector != "") {
+				return errors.New("--resource-version requires a single resource")
+			}
+			return nil
 		},
 	}
 	cmd.PersistentFlags().StringVar(&forFlag, "for", "distribution",
@@ -139,68 +161,475 @@ will block until the bookinfo virtual service has been distributed to all proxie
 	cmd.PersistentFlags().StringVar(&resourceVersion, "resource-version", "",
 		"wait for a specific version of config to become current, rather than using whatever is latest in "+
 			"kubernetes")
+	cmd.PersistentFlags().StringVarP(&labelSelector, "selector", "l", "",
+		"wait for all the resources of the types matching the label selector, e.g. release=v2")
+	cmd.PersistentFlags().StringVar(&proxySelector, "proxy-selector", "",
+		"count only the sidecars of the workloads with the labels matching the selector, e.g. app=reviews")
+	cmd.PersistentFlags().StringSliceVar(&namespaceScope, "namespace-scope", nil,
+		"count only the sidecars in these namespaces, by default the namespaces the resource is exported to")
+	cmd.PersistentFlags().StringVarP(&waitOutput, "output", "o", summaryOutput,
+		"Output format: one of json|short. The json output reports the progress of each poll as a line of JSON")
 	cmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "enables verbose output")
 	_ = cmd.PersistentFlags().MarkHidden("verbose")
 	return cmd
 }
 
+// parseTargets parses the resources of the arguments: either <type> <name>[.<namespace>],
+// <type>/<name>[.<namespace>]... or the types of the resources matching the label selector.
+func parseTargets(args []string, defaultNs string) ([]waitTarget, error) {
+	if labelSelector != "" {
+		if len(args) == 0 {
+			return nil, errors.New("expecting the types of the resources matching the label selector")
+		}
+		var ret []waitTarget
+		for _, typ := range args {
+			instance, err := validateType(typ)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, waitTarget{schema: instance, namespace: defaultNs})
+		}
+		return ret, nil
+	}
+
+	if len(args) == 2 && !strings.Contains(args[0], "/") && !strings.Contains(args[1], "/") {
+		instance, err := validateType(args[0])
+		if err != nil {
+			return nil, err
+		}
+		name, ns := handlers.InferPodInfo(args[1], defaultNs)
+		return []waitTarget{{schema: instance, name: name, namespace: ns}}, nil
+	}
+
+	if len(args) == 0 {
+		return nil, errors.New("expecting <type> <name>[.<namespace>] or <type>/<name>[.<namespace>]...")
+	}
+	var ret []waitTarget
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "/", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid resource %q, expecting <type>/<name>[.<namespace>]", arg)
+		}
+		instance, err := validateType(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		name, ns := handlers.InferPodInfo(parts[1], defaultNs)
+		ret = append(ret, waitTarget{schema: instance, name: name, namespace: ns})
+	}
+	return ret, nil
+}
+
+// selectTargets replaces the targets without name with the resources of their types matching the
+// label selector.
+func selectTargets(targets []waitTarget) ([]waitTarget, error) {
+	var ret []waitTarget
+	var dclient dynamic.Interface
+	for _, target := range targets {
+		if target.name != "" {
+			ret = append(ret, target)
+			continue
+		}
+		if dclient == nil {
+			var err error
+			if dclient, err = clientGetter(kubeconfig, configContext); err != nil {
+				return nil, err
+			}
+		}
+		list, err := target.client(dclient).List(metav1.ListOptions{LabelSelector: labelSelector})
+		if err != nil {
+			return nil, fmt.Errorf("unable to list kubernetes resources %s: %v", target.schema.Type, err)
+		}
+		for _, item := range list.Items {
+			ret = append(ret, waitTarget{schema: target.schema, name: item.GetName(), namespace: target.namespace})
+		}
+	}
+	return ret, nil
+}
+
+// waitState is the progress of the wait for a target.
+type waitState struct {
+	target  waitTarget
+	key     string
+	watcher *watcher
+	// ready is set once the versions of the resource to count are known: from the first version accepted
+	// by Kubernetes for the distribution, or from its deletion.
+	ready            bool
+	acceptedVersions []string
+	// namespaces are the namespaces of the proxies importing the resource, all if empty.
+	namespaces []string
+	// last is the result of the previous poll, to only report the changes in the summary output.
+	last *pollResult
+	done bool
+	err  error
+}
+
+// newWaitState starts watching the target resource in Kubernetes.
+func newWaitState(ctx context.Context, target waitTarget) *waitState {
+	s := &waitState{target: target, key: target.key()}
+	switch {
+	case forFlag == "delete":
+		s.watcher = watchResourceDeletion(ctx, target)
+	case resourceVersion == "":
+		s.watcher = getAndWatchResource(ctx, target) // setup version getter from kubernetes
+	default:
+		s.watcher = withContext(ctx)
+		s.watcher.Go(func(result chan observedVersion) error {
+			result <- observedVersion{version: resourceVersion}
+			return nil
+		})
+	}
+	return s
+}
+
+// update records the versions of the target resource observed in Kubernetes since the last update.
+func (s *waitState) update(cmd *cobra.Command) {
+	for {
+		select {
+		case version := <-s.watcher.resultsChan:
+			s.ready = true
+			if forFlag == "delete" {
+				// The resource has no version in the config of the proxies it was removed from.
+				printVerbosef(cmd, "resource %s deleted", s.key)
+				s.acceptedVersions = []string{""}
+				continue
+			}
+			printVerbosef(cmd, "received new target version of %s: %s", s.key, version.version)
+			s.acceptedVersions = append(s.acceptedVersions, version.version)
+			s.namespaces = version.namespaces
+		case err := <-s.watcher.errorChan:
+			if err == context.DeadlineExceeded || err == context.Canceled {
+				// Reported as a timeout of the wait.
+				return
+			}
+			s.done = true
+			s.err = fmt.Errorf("unable to retrieve kubernetes resource %s: %v", s.key, err)
+			return
+		default:
+			return
+		}
+	}
+}
+
+// report prints the progress of the wait for the target: each poll with -o json, otherwise the changes of
+// the distribution and its completion.
+func (s *waitState) report(cmd *cobra.Command, result *pollResult) error {
+	printVerbosef(cmd, "Received poll result for %s: %d/%d", s.key, result.Present, result.Total)
+	complete := result.complete()
+	condition := "present on"
+	if forFlag == "delete" {
+		condition = "removed from"
+	}
+	switch {
+	case waitOutput == jsonOutput:
+		progress := waitProgress{
+			Resource:   s.key,
+			Condition:  forFlag,
+			pollResult: result,
+			Complete:   complete,
+		}
+		if forFlag != "delete" {
+			progress.AcceptedVersions = s.acceptedVersions
+		}
+		if err := printProgress(cmd, progress); err != nil {
+			return err
+		}
+	case complete:
+		printWaitf(cmd, "Resource %s %s %d out of %d sidecars\n", s.key, condition, result.Present, result.Total)
+	case s.last == nil || s.last.distributionCount != result.distributionCount:
+		printWaitf(cmd, "Waiting for resource %s: %s %d out of %d sidecars\n",
+			s.key, condition, result.Present, result.Total)
+	}
+	s.last = result
+	s.done = complete
+	return nil
+}
+
+// timeoutError returns the error of the target when the wait timed out.
+func (s *waitState) timeoutError() error {
+	switch {
+	case forFlag != "delete":
+		return fmt.Errorf("timeout expired before resource %s became effective on all sidecars", s.key)
+	case !s.ready:
+		return fmt.Errorf("timeout expired before resource %s was deleted", s.key)
+	default:
+		return fmt.Errorf("timeout expired before resource %s was removed from all sidecars", s.key)
+	}
+}
+
+// waitForTargets waits until the condition is true of all the targets: until the ratio of the proxies whose
+// config contains the current version of each resource reaches the threshold for the distribution, or until
+// each resource is deleted from Kubernetes then no longer in the config of the proxies for the deletion. The
+// pilots are polled once per tick for all the targets.
+func waitForTargets(ctx context.Context, cmd *cobra.Command, targets []waitTarget) error {
+	states := make([]*waitState, 0, len(targets))
+	for _, target := range targets {
+		states = append(states, newWaitState(ctx, target))
+	}
+	if forFlag != "delete" {
+		// The first version of the resources is read right away, so that the first poll counts all of them.
+		for _, s := range states {
+			printVerbosef(cmd, "getting first version of %s from chan", s.key)
+			version, err := s.watcher.BlockingRead()
+			if err != nil {
+				s.done = true
+				s.err = fmt.Errorf("unable to retrieve kubernetes resource %s: %v", s.key, err)
+				continue
+			}
+			s.ready = true
+			s.acceptedVersions = []string{version.version}
+			s.namespaces = version.namespaces
+		}
+	}
+
+	t := time.NewTicker(pollInterval)
+	defer t.Stop()
+	for {
+		//run the check here as soon as we start
+		// because tickers won't run immediately
+		var polled []*waitState
+		pending := false
+		for _, s := range states {
+			if !s.done {
+				s.update(cmd)
+			}
+			if s.done {
+				continue
+			}
+			pending = true
+			if s.ready {
+				polled = append(polled, s)
+			}
+		}
+		if !pending {
+			return waitErrors(states)
+		}
+		if len(polled) > 0 {
+			if err := pollTargets(cmd, polled); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-t.C:
+			printVerbosef(cmd, "tick")
+		case <-ctx.Done():
+			printVerbosef(cmd, "timeout")
+			for _, s := range states {
+				if !s.done {
+					s.err = s.timeoutError()
+				}
+			}
+			return waitErrors(states)
+		}
+	}
+}
+
+// waitErrors returns the errors of the targets, if any.
+func waitErrors(states []*waitState) error {
+	var failed []error
+	for _, s := range states {
+		if s.err != nil {
+			failed = append(failed, s.err)
+		}
+	}
+	switch len(failed) {
+	case 0:
+		return nil
+	case 1:
+		return failed[0]
+	default:
+		return multierror.Append(nil, failed...)
+	}
+}
+
+// pollTargets polls the pilots once for the distribution of the resources of the targets, and reports the
+// progress of each target.
+func pollTargets(cmd *cobra.Command, states []*waitState) error {
+	resources := make([]string, 0, len(states))
+	importing := make([][]string, 0, len(states))
+	for _, s := range states {
+		resources = append(resources, s.key)
+		importing = append(importing, s.namespaces)
+	}
+	distribution, err := poll(resources, pollScope(importing))
+	if err != nil {
+		return err
+	}
+	for _, s := range states {
+		result := distribution.count(s.key, len(resources), s.acceptedVersions, newProxyScope(s.namespaces).namespaces)
+		if err := s.report(cmd, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printWaitf(cmd *cobra.Command, template string, args ...interface{}) {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), template, args...)
+}
+
 func printVerbosef(cmd *cobra.Command, template string, args ...interface{}) {
 	if verbose {
-		fmt.Fprintf(cmd.OutOrStdout(), template+"\n", args...)
+		printWaitf(cmd, template+"\n", args...)
+	}
+}
+
+// waitProgress is the progress of the wait for a resource reported by each poll with -o json.
+type waitProgress struct {
+	Resource  string `json:"resource"`
+	Condition string `json:"condition"`
+	// AcceptedVersions are the versions of the resource counted as present, for the distribution.
+	AcceptedVersions []string `json:"acceptedVersions,omitempty"`
+	*pollResult
+	// Complete is set when the ratio of the config in the condition reaches the threshold.
+	Complete bool `json:"complete"`
+}
+
+// printProgress prints the progress as a single line of JSON.
+func printProgress(cmd *cobra.Command, progress waitProgress) error {
+	out, err := json.Marshal(progress)
+	if err != nil {
+		return err
 	}
+	printWaitf(cmd, "%s\n", out)
+	return nil
 }
 
-func validateType(typ string) error {
+func validateType(typ string) (configschema.Instance, error) {
 	for _, instance := range schemas.Istio {
 		if strings.EqualFold(typ, instance.VariableName) || strings.EqualFold(typ, instance.Type) {
-			targetSchemaInstance = instance
-			return nil
+			return instance, nil
+		}
+	}
+	return configschema.Instance{}, fmt.Errorf("type %s is not recognized", typ)
+}
+
+// distributionCount counts the config of the proxies, i.e. their clusters, listeners and routes, in the
+// condition waited for.
+type distributionCount struct {
+	Present int `json:"present"`
+	Total   int `json:"total"`
+}
+
+// pollResult is the distribution of a resource to the proxies of all the pilots, and by pilot.
+type pollResult struct {
+	distributionCount
+	Pilots map[string]distributionCount `json:"pilots"`
+}
+
+// complete returns whether the ratio of the config in the condition reaches the threshold.
+func (r *pollResult) complete() bool {
+	return float32(r.Present)/float32(r.Total) >= threshold
+}
+
+// proxyScope is the set of the proxies counted by the polls: the ones in the namespaces, or all if none,
+// with the labels matching the selector.
+type proxyScope struct {
+	namespaces []string
+	selector   string
+}
+
+// newProxyScope returns the scope of the proxies selected by the flags, in the namespaces importing the
+// resource unless the namespace scope is set.
+func newProxyScope(importing []string) proxyScope {
+	if len(namespaceScope) > 0 {
+		return proxyScope{namespaces: namespaceScope, selector: proxySelector}
+	}
+	return proxyScope{namespaces: importing, selector: proxySelector}
+}
+
+// pollScope returns the scope of the proxies of a poll of several resources, covering the namespaces
+// importing any of them: all if one of them is imported by all namespaces.
+func pollScope(importing [][]string) proxyScope {
+	var namespaces []string
+	for _, nss := range importing {
+		if len(nss) == 0 {
+			return newProxyScope(nil)
+		}
+		for _, ns := range nss {
+			if !contains(namespaces, ns) {
+				namespaces = append(namespaces, ns)
+			}
 		}
 	}
-	return fmt.Errorf("type %s is not recognized", typ)
+	return newProxyScope(namespaces)
 }
 
-func countVersions(versionCount map[string]int, configVersion string) {
-	if count, ok := versionCount[configVersion]; ok {
-		versionCount[configVersion] = count + 1
-	} else {
-		versionCount[configVersion] = 1
+// distributionPath returns the path of the distribution of the target resources to the proxies of the scope.
+func distributionPath(targetResources []string, scope proxyScope) string {
+	query := url.Values{"resource": targetResources}
+	if len(scope.namespaces) > 0 {
+		query.Set("proxy_namespace", strings.Join(scope.namespaces, ","))
+	}
+	if scope.selector != "" {
+		query.Set("proxy_selector", scope.selector)
 	}
+	return "/debug/config_distribution?" + query.Encode()
 }
 
-func poll(acceptedVersions []string, targetResource string) (present, notpresent int, err error) {
+// distribution is the config of the proxies reported by each pilot.
+type distribution map[string][]v2.SyncedVersions
+
+// poll queries all the pilots once for the versions of the target resources in the config of the proxies of
+// the scope.
+func poll(targetResources []string, scope proxyScope) (distribution, error) {
 	kubeClient, err := clientExecFactory(kubeconfig, configContext)
 	if err != nil {
-		return 0, 0, err
+		return nil, err
 	}
-	path := fmt.Sprintf("/debug/config_distribution?resource=%s", targetResource)
+	path := distributionPath(targetResources, scope)
 	pilotResponses, err := kubeClient.AllPilotsDiscoveryDo(istioNamespace, "GET", path, nil)
 	if err != nil {
-		return 0, 0, fmt.Errorf("unable to query pilot for distribution "+
+		return nil, fmt.Errorf("unable to query pilot for distribution "+
 			"(are you using pilot version >= 1.4 with config distribution tracking on): %s", err)
 	}
-	versionCount := make(map[string]int)
-	for _, response := range pilotResponses {
+	result := make(distribution, len(pilotResponses))
+	for pilot, response := range pilotResponses {
 		var configVersions []v2.SyncedVersions
-		err = json.Unmarshal(response, &configVersions)
-		if err != nil {
-			return 0, 0, err
-		}
-		for _, configVersion := range configVersions {
-			countVersions(versionCount, configVersion.ClusterVersion)
-			countVersions(versionCount, configVersion.RouteVersion)
-			countVersions(versionCount, configVersion.ListenerVersion)
+		if err := json.Unmarshal(response, &configVersions); err != nil {
+			return nil, err
 		}
+		result[pilot] = configVersions
 	}
+	return result, nil
+}
 
-	for version, count := range versionCount {
-		if contains(acceptedVersions, version) {
-			present += count
-		} else {
-			notpresent += count
+// count counts the config of the proxies in the namespaces, all if empty, containing one of the accepted
+// versions of the target resource. The config sent to a proxy at bootstrap is counted by the version pilot
+// sent, until the proxy acknowledges it. The versions reported without resource, by the pilots only
+// reporting a single resource, are counted if a single resource was polled.
+func (d distribution) count(targetResource string, polled int, acceptedVersions, namespaces []string) *pollResult {
+	result := &pollResult{Pilots: make(map[string]distributionCount, len(d))}
+	for pilot, configVersions := range d {
+		var count distributionCount
+		for _, configVersion := range configVersions {
+			if configVersion.Resource != targetResource && (configVersion.Resource != "" || polled > 1) {
+				continue
+			}
+			if len(namespaces) > 0 && configVersion.ProxyNamespace != "" &&
+				!contains(namespaces, configVersion.ProxyNamespace) {
+				continue
+			}
+			// The cluster, listener and route config of the proxy.
+			count.Total += 3
+			for _, versions := range [][2]string{
+				{configVersion.ClusterVersion, configVersion.ClusterBootstrapVersion},
+				{configVersion.RouteVersion, configVersion.RouteBootstrapVersion},
+				{configVersion.ListenerVersion, configVersion.ListenerBootstrapVersion},
+			} {
+				acked, bootstrap := versions[0], versions[1]
+				if contains(acceptedVersions, acked) || (bootstrap != "" && contains(acceptedVersions, bootstrap)) {
+					count.Present++
+				}
+			}
 		}
+		result.Pilots[pilot] = count
+		result.Present += count.Present
+		result.Total += count.Total
 	}
-	return present, notpresent, nil
+	return result
 }
 
 func init() {
@@ -220,27 +649,26 @@ func init() {
 }
 
 // getAndWatchResource ensures that ResourceVersions always contains
-// the current resourceVersion of the targetResource, adding new versions
+// the current resourceVersion of the target resource, adding new versions
 // as they are created.
-func getAndWatchResource(ictx context.Context) *watcher {
+func getAndWatchResource(ictx context.Context, target waitTarget) *watcher {
 	g := withContext(ictx)
-	g.Go(func(result chan string) error {
+	g.Go(func(result chan observedVersion) error {
 		// retrieve resource version from Kubernetes
 		dclient, err := clientGetter(kubeconfig, configContext)
 		if err != nil {
 			return err
 		}
-		collectionParts := strings.Split(targetSchemaInstance.Collection, "/")
-		group := targetSchemaInstance.Group + ".istio.io"
-		version := targetSchemaInstance.Version
-		resource := collectionParts[3]
-		r := dclient.Resource(schema.GroupVersionResource{Group: group, Version: version, Resource: resource}).Namespace(namespace)
-		obj, err := r.Get(nameflag, metav1.GetOptions{})
+		r := target.client(dclient)
+		obj, err := r.Get(target.name, metav1.GetOptions{})
 		if err != nil {
 			return err
 		}
 		localResourceVersion := obj.GetResourceVersion()
-		result <- localResourceVersion
+		result <- observedVersion{
+			version:    localResourceVersion,
+			namespaces: exportNamespaces(obj),
+		}
 		watch, err := r.Watch(metav1.ListOptions{ResourceVersion: localResourceVersion})
 		if err != nil {
 			return err
@@ -251,12 +679,16 @@ func getAndWatchResource(ictx context.Context) *watcher {
 			if err != nil {
 				return err
 			}
-			if watchname == nameflag {
+			if watchname == target.name {
 				newVersion, err := metaAccessor.ResourceVersion(w.Object)
 				if err != nil {
 					return err
 				}
-				result <- newVersion
+				version := observedVersion{version: newVersion}
+				if obj, ok := w.Object.(*unstructured.Unstructured); ok {
+					version.namespaces = exportNamespaces(obj)
+				}
+				result <- version
 			}
 			select {
 			case <-ictx.Done():
@@ -271,21 +703,98 @@ func getAndWatchResource(ictx context.Context) *watcher {
 	return g
 }
 
+// watchResourceDeletion sends an empty version once the target resource doesn't exist in Kubernetes.
+func watchResourceDeletion(ictx context.Context, target waitTarget) *watcher {
+	g := withContext(ictx)
+	g.Go(func(result chan observedVersion) error {
+		dclient, err := clientGetter(kubeconfig, configContext)
+		if err != nil {
+			return err
+		}
+		r := target.client(dclient)
+		obj, err := r.Get(target.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			result <- observedVersion{}
+			return nil
+		} else if err != nil {
+			return err
+		}
+		w, err := r.Watch(metav1.ListOptions{
+			FieldSelector:   fields.OneTermEqualSelector("metadata.name", target.name).String(),
+			ResourceVersion: obj.GetResourceVersion(),
+		})
+		if err != nil {
+			return err
+		}
+		defer w.Stop()
+		metaAccessor := meta.NewAccessor()
+		for {
+			select {
+			case event, ok := <-w.ResultChan():
+				if !ok {
+					return errors.New("the watch of the resource was closed before its deletion")
+				}
+				if name, err := metaAccessor.Name(event.Object); err == nil && name == target.name &&
+					event.Type == watch.Deleted {
+					result <- observedVersion{}
+					return nil
+				}
+			case <-ictx.Done():
+				return ictx.Err()
+			}
+		}
+	})
+	return g
+}
+
+// client returns the client of the resources of the schema of the target in its namespace.
+func (t waitTarget) client(dclient dynamic.Interface) dynamic.ResourceInterface {
+	collectionParts := strings.Split(t.schema.Collection, "/")
+	group := t.schema.Group + ".istio.io"
+	version := t.schema.Version
+	resource := collectionParts[3]
+	return dclient.Resource(schema.GroupVersionResource{Group: group, Version: version, Resource: resource}).Namespace(t.namespace)
+}
+
+// observedVersion is a version of the target resource accepted by Kubernetes.
+type observedVersion struct {
+	version string
+	// namespaces are the namespaces of the proxies importing the version, all if empty.
+	namespaces []string
+}
+
+// exportNamespaces returns the namespaces the resource is exported to by its exportTo, all if empty.
+func exportNamespaces(obj *unstructured.Unstructured) []string {
+	exportTo, _, _ := unstructured.NestedStringSlice(obj.Object, "spec", "exportTo")
+	var namespaces []string
+	for _, ns := range exportTo {
+		switch ns {
+		case "*":
+			return nil
+		case ".":
+			namespaces = append(namespaces, obj.GetNamespace())
+		default:
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
 type watcher struct {
-	resultsChan chan string
+	resultsChan chan observedVersion
 	errorChan   chan error
 	ctx         context.Context
 }
 
 func withContext(ctx context.Context) *watcher {
 	return &watcher{
-		resultsChan: make(chan string, 1),
+		resultsChan: make(chan observedVersion, 1),
 		errorChan:   make(chan error, 1),
 		ctx:         ctx,
 	}
 }
 
-func (w *watcher) Go(f func(chan string) error) {
+func (w *watcher) Go(f func(chan observedVersion) error) {
 	go func() {
 		if err := f(w.resultsChan); err != nil {
 			w.errorChan <- err
@@ -293,13 +802,13 @@ func (w *watcher) Go(f func(chan string) error) {
 	}()
 }
 
-func (w *watcher) BlockingRead() (string, error) {
+func (w *watcher) BlockingRead() (observedVersion, error) {
 	select {
 	case err := <-w.errorChan:
-		return "", err
+		return observedVersion{}, err
 	case res := <-w.resultsChan:
 		return res, nil
 	case <-w.ctx.Done():
-		return "", w.ctx.Err()
+		return observedVersion{}, w.ctx.Err()
 	}
 }
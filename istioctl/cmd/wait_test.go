@@ -15,16 +15,22 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/fake"
 
+	"istio.io/istio/istioctl/pkg/kubernetes"
 	v2 "istio.io/istio/pilot/pkg/proxy/envoy/v2"
 )
 
@@ -65,7 +71,8 @@ func TestWaitCmd(t *testing.T) {
 			execClientConfig: cannedResponseMap,
 			args:             strings.Split("x wait --timeout 2s virtual-service bar.default", " "),
 			wantException:    true,
-			expectedOutput:   "Error: timeout expired before resource virtual-service/default/bar became effective on all sidecars\n",
+			expectedOutput: "Waiting for resource virtual-service/default/bar: present on 0 out of 3 sidecars\n" +
+				"Error: timeout expired before resource virtual-service/default/bar became effective on all sidecars\n",
 		},
 		{
 			execClientConfig: cannedResponseMap,
@@ -83,10 +90,426 @@ func TestWaitCmd(t *testing.T) {
 	}
 }
 
+func TestWaitCmdDelete(t *testing.T) {
+	removedResponse, _ := json.Marshal([]v2.SyncedVersions{{ProxyID: "foo"}})
+	presentResponse, _ := json.Marshal([]v2.SyncedVersions{{
+		ProxyID:         "foo",
+		ClusterVersion:  "1",
+		ListenerVersion: "1",
+		RouteVersion:    "1",
+	}})
+
+	cases := []execTestCase{
+		{
+			execClientConfig: map[string][]byte{"onlyonepilot": removedResponse},
+			args:             strings.Split("x wait --for=delete virtual-service baz.default", " "),
+			expectedOutput:   "Resource virtual-service/default/baz removed from 3 out of 3 sidecars\n",
+		},
+		{
+			execClientConfig: map[string][]byte{"onlyonepilot": presentResponse},
+			args:             strings.Split("x wait --for=delete --timeout 2s virtual-service baz.default", " "),
+			wantException:    true,
+			expectedOutput: "Waiting for resource virtual-service/default/baz: removed from 0 out of 3 sidecars\n" +
+				"Error: timeout expired before resource virtual-service/default/baz was removed from all sidecars\n",
+		},
+		{
+			execClientConfig: map[string][]byte{"onlyonepilot": removedResponse},
+			args:             strings.Split("x wait --for=delete --timeout 2s virtual-service foo.default", " "),
+			wantException:    true,
+			expectedOutput:   "Error: timeout expired before resource virtual-service/default/foo was deleted\n",
+		},
+		{
+			execClientConfig: map[string][]byte{"onlyonepilot": removedResponse},
+			args:             strings.Split("x wait --for=ready virtual-service foo.default", " "),
+			wantException:    true,
+			expectedOutput:   "Error: --for must be 'delete' or 'distribution', got: ready\n",
+		},
+	}
+
+	_ = setupK8Sfake()
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("case %d %s", i, strings.Join(c.args, " ")), func(t *testing.T) {
+			verifyExecTestOutput(t, c)
+		})
+	}
+}
+
+func TestWaitCmdDeleteWatch(t *testing.T) {
+	removedResponse, _ := json.Marshal([]v2.SyncedVersions{{ProxyID: "foo"}})
+	client := setupK8Sfake()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = client.Resource(schema.GroupVersionResource{
+			Group:    "networking.istio.io",
+			Version:  "v1alpha3",
+			Resource: "virtualservices",
+		}).Namespace("default").Delete("foo", &metav1.DeleteOptions{})
+	}()
+	verifyExecTestOutput(t, execTestCase{
+		execClientConfig: map[string][]byte{"onlyonepilot": removedResponse},
+		args:             strings.Split("x wait --for=delete --timeout 10s virtual-service foo.default", " "),
+		expectedOutput:   "Resource virtual-service/default/foo removed from 3 out of 3 sidecars\n",
+	})
+}
+
+func TestWaitCmdMultipleResources(t *testing.T) {
+	var versions []v2.SyncedVersions
+	for _, resource := range []string{
+		"virtual-service/default/foo", "virtual-service/default/bar", "destination-rule/default/foo"} {
+		versions = append(versions, v2.SyncedVersions{
+			ProxyID:         "foo",
+			ProxyNamespace:  "default",
+			Resource:        resource,
+			ClusterVersion:  "1",
+			ListenerVersion: "1",
+			RouteVersion:    "1",
+		})
+	}
+	cannedResponse, _ := json.Marshal(versions)
+	cannedResponseMap := map[string][]byte{"onlyonepilot": cannedResponse}
+
+	cases := []struct {
+		args          string
+		wantException bool
+		wantOutput    []string
+	}{
+		{
+			args: "x wait --timeout 2s virtual-service/foo.default destination-rule/foo.default",
+			wantOutput: []string{
+				"Resource virtual-service/default/foo present on 3 out of 3 sidecars\n",
+				"Resource destination-rule/default/foo present on 3 out of 3 sidecars\n",
+			},
+		},
+		{
+			args:          "x wait --timeout 2s virtual-service/foo.default virtual-service/bar.default",
+			wantException: true,
+			wantOutput: []string{
+				"Resource virtual-service/default/foo present on 3 out of 3 sidecars\n",
+				"Waiting for resource virtual-service/default/bar: present on 0 out of 3 sidecars\n",
+			},
+		},
+		{
+			args:       "x wait --timeout 2s -l release=v2 virtual-service destination-rule",
+			wantOutput: []string{"Resource virtual-service/default/foo present on 3 out of 3 sidecars\n"},
+		},
+		{
+			args:          "x wait --timeout 2s -l release=v3 virtual-service",
+			wantException: true,
+		},
+		{
+			args:       "x wait --for=delete --timeout 2s -l release=v3 virtual-service",
+			wantOutput: []string{"No resources matching release=v3 found\n"},
+		},
+		{
+			args:          "x wait virtual-service/foo.default bar.default",
+			wantException: true,
+		},
+		{
+			args:          "x wait --resource-version=1 virtual-service/foo.default virtual-service/bar.default",
+			wantException: true,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("case %d %s", i, c.args), func(t *testing.T) {
+			_ = setupK8Sfake()
+			clientExecFactory = mockClientExecFactoryGenerator(cannedResponseMap)
+			var out bytes.Buffer
+			rootCmd := GetRootCmd(strings.Split(c.args, " "))
+			rootCmd.SetOutput(&out)
+			err := rootCmd.Execute()
+			if c.wantException != (err != nil) {
+				t.Fatalf("got error %v, want error %v, output was %q", err, c.wantException, out.String())
+			}
+			for _, want := range c.wantOutput {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("got output %q, want %q", out.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestWaitCmdJSONOutput(t *testing.T) {
+	cannedResponse, _ := json.Marshal([]v2.SyncedVersions{{
+		ProxyID:         "foo",
+		ClusterVersion:  "1",
+		ListenerVersion: "1",
+		RouteVersion:    "1",
+	}})
+	removedResponse, _ := json.Marshal([]v2.SyncedVersions{{ProxyID: "foo"}})
+
+	cases := []execTestCase{
+		{
+			execClientConfig: map[string][]byte{"onlyonepilot": cannedResponse},
+			args:             strings.Split("x wait -o json virtual-service foo.default", " "),
+			expectedOutput: `{"resource":"virtual-service/default/foo","condition":"distribution","acceptedVersions":["1"],` +
+				`"present":3,"total":3,"pilots":{"onlyonepilot":{"present":3,"total":3}},"complete":true}` + "\n",
+		},
+		{
+			execClientConfig: map[string][]byte{"onlyonepilot": removedResponse},
+			args:             strings.Split("x wait -o json --for=delete virtual-service baz.default", " "),
+			expectedOutput: `{"resource":"virtual-service/default/baz","condition":"delete",` +
+				`"present":3,"total":3,"pilots":{"onlyonepilot":{"present":3,"total":3}},"complete":true}` + "\n",
+		},
+		{
+			execClientConfig: map[string][]byte{"onlyonepilot": cannedResponse},
+			args:             strings.Split("x wait -o json --timeout 2s virtual-service bar.default", " "),
+			wantException:    true,
+			expectedString: `{"resource":"virtual-service/default/bar","condition":"distribution","acceptedVersions":["3"],` +
+				`"present":0,"total":3,"pilots":{"onlyonepilot":{"present":0,"total":3}},"complete":false}` + "\n",
+		},
+		{
+			execClientConfig: map[string][]byte{"onlyonepilot": cannedResponse},
+			args:             strings.Split("x wait -o yaml virtual-service foo.default", " "),
+			wantException:    true,
+			expectedOutput:   "Error: output format \"yaml\" not supported\n",
+		},
+	}
+
+	_ = setupK8Sfake()
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("case %d %s", i, strings.Join(c.args, " ")), func(t *testing.T) {
+			verifyExecTestOutput(t, c)
+		})
+	}
+}
+
+func TestWaitCmdOnboardedProxies(t *testing.T) {
+	response := func(bootstrapVersion string) map[string][]byte {
+		cannedResponse, _ := json.Marshal([]v2.SyncedVersions{
+			{
+				ProxyID:         "old",
+				ClusterVersion:  "5",
+				ListenerVersion: "5",
+				RouteVersion:    "5",
+			},
+			// The proxy has not acknowledged the config sent at bootstrap yet.
+			{
+				ProxyID:                  "new",
+				ClusterBootstrapVersion:  bootstrapVersion,
+				ListenerBootstrapVersion: bootstrapVersion,
+				RouteBootstrapVersion:    bootstrapVersion,
+			},
+		})
+		return map[string][]byte{"onlyonepilot": cannedResponse}
+	}
+
+	cases := []execTestCase{
+		{
+			execClientConfig: response("5"),
+			args:             strings.Split("x wait --timeout 2s virtual-service onboarding.default", " "),
+			expectedOutput:   "Resource virtual-service/default/onboarding present on 6 out of 6 sidecars\n",
+		},
+		{
+			execClientConfig: response("4"),
+			args:             strings.Split("x wait --timeout 2s virtual-service onboarding.default", " "),
+			wantException:    true,
+		},
+		{
+			execClientConfig: response(""),
+			args:             strings.Split("x wait --timeout 2s virtual-service onboarding.default", " "),
+			wantException:    true,
+		},
+	}
+
+	client := setupK8Sfake()
+	onboarding := newUnstructured("networking.istio.io/v1alpha3", "virtualservice", "default", "onboarding", "5")
+	if _, err := client.Resource(schema.GroupVersionResource{
+		Group:    "networking.istio.io",
+		Version:  "v1alpha3",
+		Resource: "virtualservices",
+	}).Namespace("default").Create(onboarding, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("case %d %s", i, strings.Join(c.args, " ")), func(t *testing.T) {
+			verifyExecTestOutput(t, c)
+		})
+	}
+}
+
+// countingExecClient counts the queries of the distribution to the pilots.
+type countingExecClient struct {
+	mockExecConfig
+	paths *[]string
+}
+
+func (c countingExecClient) AllPilotsDiscoveryDo(pilotNamespace, method, path string, body []byte) (map[string][]byte, error) {
+	*c.paths = append(*c.paths, path)
+	return c.mockExecConfig.AllPilotsDiscoveryDo(pilotNamespace, method, path, body)
+}
+
+func TestWaitCmdSinglePollPerTick(t *testing.T) {
+	var versions []v2.SyncedVersions
+	for _, resource := range []string{"virtual-service/default/foo", "destination-rule/default/foo"} {
+		versions = append(versions, v2.SyncedVersions{
+			ProxyID:         "foo",
+			Resource:        resource,
+			ClusterVersion:  "1",
+			ListenerVersion: "1",
+			RouteVersion:    "1",
+		})
+	}
+	cannedResponse, _ := json.Marshal(versions)
+
+	defer func(f func(kubeconfig, configContext string) (kubernetes.ExecClient, error)) { clientExecFactory = f }(clientExecFactory)
+	_ = setupK8Sfake()
+	var paths []string
+	clientExecFactory = func(_, _ string) (kubernetes.ExecClient, error) {
+		return countingExecClient{
+			mockExecConfig: mockExecConfig{results: map[string][]byte{"onlyonepilot": cannedResponse}},
+			paths:          &paths,
+		}, nil
+	}
+	var out bytes.Buffer
+	rootCmd := GetRootCmd(strings.Split("x wait --timeout 2s virtual-service/foo.default destination-rule/foo.default", " "))
+	rootCmd.SetOutput(&out)
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("unexpected error %v, output was %q", err, out.String())
+	}
+	want := []string{"/debug/config_distribution?resource=virtual-service%2Fdefault%2Ffoo" +
+		"&resource=destination-rule%2Fdefault%2Ffoo"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("got queries %v, want %v", paths, want)
+	}
+}
+
+func TestDistributionCount(t *testing.T) {
+	d := distribution{
+		"pilot1": {
+			{ProxyID: "a", ProxyNamespace: "ns1", Resource: "vs", ClusterVersion: "1", ListenerVersion: "1", RouteVersion: "1"},
+			{ProxyID: "a", ProxyNamespace: "ns1", Resource: "dr", ClusterVersion: "2"},
+		},
+		"pilot2": {
+			{ProxyID: "b", ProxyNamespace: "ns2", Resource: "vs", ClusterBootstrapVersion: "1",
+				ListenerBootstrapVersion: "1", RouteBootstrapVersion: "1"},
+			{ProxyID: "b", ProxyNamespace: "ns2", Resource: "dr"},
+		},
+	}
+	cases := []struct {
+		name       string
+		resource   string
+		versions   []string
+		namespaces []string
+		want       distributionCount
+	}{
+		{name: "acked and bootstrap", resource: "vs", versions: []string{"1"}, want: distributionCount{Present: 6, Total: 6}},
+		{name: "by resource", resource: "dr", versions: []string{"2"}, want: distributionCount{Present: 1, Total: 6}},
+		{name: "importing namespaces", resource: "vs", versions: []string{"1"}, namespaces: []string{"ns2"},
+			want: distributionCount{Present: 3, Total: 3}},
+		{name: "other version", resource: "vs", versions: []string{"3"}, want: distributionCount{Total: 6}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := d.count(c.resource, 2, c.versions, c.namespaces); got.distributionCount != c.want {
+				t.Errorf("got %+v, want %+v", got.distributionCount, c.want)
+			}
+		})
+	}
+
+	// The versions reported without resource apply to a single polled resource.
+	legacy := distribution{"pilot": {{ProxyID: "a", ClusterVersion: "1", ListenerVersion: "1", RouteVersion: "1"}}}
+	if got := legacy.count("vs", 1, []string{"1"}, nil); got.Present != 3 || got.Total != 3 {
+		t.Errorf("got %+v for a single resource, want 3/3", got.distributionCount)
+	}
+	if got := legacy.count("vs", 2, []string{"1"}, nil); got.Total != 0 {
+		t.Errorf("got %+v for several resources, want 0/0", got.distributionCount)
+	}
+}
+
+func TestPollScope(t *testing.T) {
+	cases := []struct {
+		importing [][]string
+		want      []string
+	}{
+		{importing: [][]string{{"ns1"}, {"ns2", "ns1"}}, want: []string{"ns1", "ns2"}},
+		{importing: [][]string{{"ns1"}, nil}},
+	}
+	for _, c := range cases {
+		if got := pollScope(c.importing); !reflect.DeepEqual(got.namespaces, c.want) {
+			t.Errorf("importing %v: got namespaces %v, want %v", c.importing, got.namespaces, c.want)
+		}
+	}
+}
+
+func TestDistributionPath(t *testing.T) {
+	cases := []struct {
+		resources []string
+		scope     proxyScope
+		want      string
+	}{
+		{
+			want: "/debug/config_distribution?resource=virtual-service%2Fdefault%2Ffoo",
+		},
+		{
+			scope: proxyScope{namespaces: []string{"ns1", "ns2"}, selector: "app=reviews"},
+			want: "/debug/config_distribution?proxy_namespace=ns1%2Cns2&proxy_selector=app%3Dreviews" +
+				"&resource=virtual-service%2Fdefault%2Ffoo",
+		},
+		{
+			resources: []string{"virtual-service/default/foo", "destination-rule/default/foo"},
+			want: "/debug/config_distribution?resource=virtual-service%2Fdefault%2Ffoo" +
+				"&resource=destination-rule%2Fdefault%2Ffoo",
+		},
+	}
+	for _, c := range cases {
+		if c.resources == nil {
+			c.resources = []string{"virtual-service/default/foo"}
+		}
+		if got := distributionPath(c.resources, c.scope); got != c.want {
+			t.Errorf("got path %s, want %s", got, c.want)
+		}
+	}
+}
+
+func TestExportNamespaces(t *testing.T) {
+	cases := []struct {
+		exportTo []interface{}
+		want     []string
+	}{
+		{},
+		{exportTo: []interface{}{"."}, want: []string{"default"}},
+		{exportTo: []interface{}{"*"}},
+		{exportTo: []interface{}{".", "*"}},
+	}
+	for _, c := range cases {
+		obj := newUnstructured("networking.istio.io/v1alpha3", "virtualservice", "default", "foo", "1")
+		if c.exportTo != nil {
+			obj.Object["spec"] = map[string]interface{}{"exportTo": c.exportTo}
+		}
+		if got := exportNamespaces(obj); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("exportTo %v: got namespaces %v, want %v", c.exportTo, got, c.want)
+		}
+	}
+}
+
+func TestWaitCmdProxyScope(t *testing.T) {
+	cannedResponse, _ := json.Marshal([]v2.SyncedVersions{{
+		ProxyID:         "foo",
+		ClusterVersion:  "1",
+		ListenerVersion: "1",
+		RouteVersion:    "1",
+	}})
+	_ = setupK8Sfake()
+	verifyExecTestOutput(t, execTestCase{
+		execClientConfig: map[string][]byte{"onlyonepilot": cannedResponse},
+		args: strings.Split("x wait --proxy-selector app=reviews --namespace-scope default,other "+
+			"virtual-service foo.default", " "),
+		expectedOutput: "Resource virtual-service/default/foo present on 3 out of 3 sidecars\n",
+	})
+}
+
 func setupK8Sfake() *fake.FakeDynamicClient {
+	labeled := newUnstructured("networking.istio.io/v1alpha3", "virtualservice", "default", "foo", "1")
+	labeled.SetLabels(map[string]string{"release": "v2"})
 	objs := []runtime.Object{
-		newUnstructured("networking.istio.io/v1alpha3", "virtualservice", "default", "foo", "1"),
+		labeled,
 		newUnstructured("networking.istio.io/v1alpha3", "virtualservice", "default", "bar", "3"),
+		newUnstructured("networking.istio.io/v1alpha3", "destinationrule", "default", "foo", "1"),
 	}
 	client := fake.NewSimpleDynamicClient(runtime.NewScheme(), objs...)
 	clientGetter = func(_, _ string) (dynamic.Interface, error) {
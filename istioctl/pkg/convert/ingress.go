@@ -35,7 +35,7 @@ func IstioIngresses(ingresses []*v1beta1.Ingress, domainSuffix string) ([]model.
 
 	ingressByHost := map[string]*model.Config{}
 	for _, ingrezz := range ingresses {
-		ingress.ConvertIngressVirtualService(*ingrezz, domainSuffix, ingressByHost)
+		ingress.ConvertIngressVirtualService(*ingrezz, domainSuffix, ingressByHost, nil)
 	}
 
 	out := make([]model.Config, 0, len(ingressByHost))
@@ -17,6 +17,7 @@ package kubernetes
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 
 	"istio.io/istio/security/pkg/nodeagent/sds"
 	"istio.io/pkg/log"
@@ -27,12 +28,15 @@ import (
 const (
 	ingressGatewayApp = "istio-ingressgateway"
 	debugEndpointPath = "localhost:8080/debug/sds"
+	// gatewaySecretRefreshPath reloads a kubernetes secret of the ingress gateway and pushes it to Envoy.
+	gatewaySecretRefreshPath = debugEndpointPath + "/gateway/refresh"
 )
 
 // ExecClientSDS wraps the kubernetes API and provides needed access for sds-status command
 type ExecClientSDS interface {
 	GetPodNodeAgentSecrets(string, string, string) (map[string]sds.Debug, error)
 	NodeAgentDebugEndpointOutput(string, string, string, string) (sds.Debug, error)
+	RefreshGatewaySecret(string, string, string) ([]byte, error)
 	ExecClient
 }
 
@@ -88,6 +92,16 @@ func (client *Client) NodeAgentDebugEndpointOutput(podName, ns, secretType, cont
 	return sdsDebug, nil
 }
 
+// RefreshGatewaySecret makes the node agent embedded in the ingress gateway pod reload the kubernetes secret
+// from the API server and push it to the gateway, and returns the response of the node agent
+func (client *Client) RefreshGatewaySecret(podName, ns, secretName string) ([]byte, error) {
+	request := []string{
+		"curl", "-sS", "--fail", "-X", "POST",
+		fmt.Sprintf("%s?name=%s", gatewaySecretRefreshPath, url.QueryEscape(secretName)),
+	}
+	return client.ExtractExecResult(podName, ns, proxyContainer, request)
+}
+
 // nodeAgentsForPod returns all node agents which are serving secrets to the supplied pod
 // in the case of an ingress-gateway, it is possible for there to be are two corresponding nodeagents: the one on the node
 // running as a daemon set serving workload secrets, and the embedded nodeagent running in the same pod
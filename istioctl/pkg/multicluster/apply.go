@@ -17,6 +17,7 @@ package multicluster
 import (
 	"bytes"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -36,11 +37,37 @@ func deleteSecret(cluster *Cluster, s *v1.Secret) error {
 
 // update current state to match desired state.
 func updateRemoteSecret(prev, curr *v1.Secret) (changed bool) {
+	if prev.Data == nil {
+		prev.Data = make(map[string][]byte)
+	}
+	if prev.Annotations == nil {
+		prev.Annotations = make(map[string]string)
+	}
+	if prev.Labels == nil {
+		prev.Labels = make(map[string]string)
+	}
+
+	dataChanged := false
 	prev.StringData = curr.StringData
 	for k, v := range curr.StringData {
 		newVal := []byte(v)
 		if !bytes.Equal(prev.Data[k], newVal) {
 			prev.Data[k] = newVal
+			dataChanged = true
+		}
+	}
+	for k, v := range curr.Data {
+		if !bytes.Equal(prev.Data[k], v) {
+			prev.Data[k] = v
+			dataChanged = true
+		}
+	}
+	changed = dataChanged
+
+	// the creation time is only recorded again when the credentials changed.
+	if creationTime, ok := curr.Annotations[remoteSecretCreationTimeAnnotationKey]; ok {
+		if _, found := prev.Annotations[remoteSecretCreationTimeAnnotationKey]; dataChanged || !found {
+			prev.Annotations[remoteSecretCreationTimeAnnotationKey] = creationTime
 			changed = true
 		}
 	}
@@ -58,7 +85,18 @@ func updateRemoteSecret(prev, curr *v1.Secret) (changed bool) {
 	return changed
 }
 
+// withCreationTime returns a copy of the remote secret recording the time its credentials were generated.
+func withCreationTime(secret *v1.Secret, now time.Time) *v1.Secret {
+	out := secret.DeepCopy()
+	if out.Annotations == nil {
+		out.Annotations = make(map[string]string)
+	}
+	out.Annotations[remoteSecretCreationTimeAnnotationKey] = now.UTC().Format(time.RFC3339)
+	return out
+}
+
 func applySecret(env Environment, cluster *Cluster, curr *v1.Secret) error {
+	curr = withCreationTime(curr, time.Now())
 	err := env.Poll(500*time.Millisecond, 5*time.Second, func() (bool, error) {
 		prev, err := cluster.client.CoreV1().Secrets(cluster.Namespace).Get(curr.Name, metav1.GetOptions{})
 		if err == nil {
@@ -78,7 +116,50 @@ func applySecret(env Environment, cluster *Cluster, curr *v1.Secret) error {
 	return err
 }
 
-func apply(mesh *Mesh, env Environment) error {
+// desiredRemoteSecret returns the remote secret of the cluster, which apply creates in the other clusters.
+func desiredRemoteSecret(cluster *Cluster, env Environment) (*v1.Secret, error) {
+	opt := RemoteSecretOptions{
+		KubeOptions: KubeOptions{
+			Context:   cluster.Context,
+			Namespace: cluster.Namespace,
+		},
+		ServiceAccountName: cluster.ServiceAccountReader,
+		AuthType:           RemoteSecretAuthTypeBearerToken,
+	}
+	cluster.Auth.apply(&opt)
+	return createRemoteSecret(opt, cluster.client, env)
+}
+
+// desiredRemoteSecrets returns the remote secrets of the clusters whose registry is joined with the other
+// clusters, by UID. The clusters whose remote secret can't be generated are reported and skipped.
+func desiredRemoteSecrets(mesh *Mesh, env Environment) map[types.UID]*v1.Secret {
+	desired := make(map[types.UID]*v1.Secret)
+	for _, cluster := range mesh.SortedClusters() {
+		if !joinable(cluster) {
+			continue
+		}
+		secret, err := desiredRemoteSecret(cluster, env)
+		if err != nil {
+			env.Errorf("could not generate the remote secret of cluster %v: %v\n", cluster, err)
+			continue
+		}
+		desired[cluster.uid] = secret
+	}
+	return desired
+}
+
+// clusterChanges are the changes applied to a cluster of the mesh.
+type clusterChanges struct {
+	cluster *Cluster
+	// apply are the remote secrets of the other clusters, created or updated.
+	apply []*v1.Secret
+	// prune are the remote secrets of the clusters which left the mesh.
+	prune []*v1.Secret
+}
+
+// planApply computes the changes of the clusters, in order. The clusters which can't be joined are
+// reported in the error, and the changes of the other clusters are still returned.
+func planApply(mesh *Mesh, env Environment) ([]*clusterChanges, error) {
 	var errs *multierror.Error
 
 	currentSecretsByUID := make(map[types.UID]*v1.Secret)
@@ -92,16 +173,7 @@ func apply(mesh *Mesh, env Environment) error {
 			continue
 		}
 
-		opt := RemoteSecretOptions{
-			KubeOptions: KubeOptions{
-				Context:   cluster.Context,
-				Namespace: cluster.Namespace,
-			},
-			ServiceAccountName: cluster.ServiceAccountReader,
-			AuthType:           RemoteSecretAuthTypeBearerToken,
-			// TODO add auth provider option (e.g. gcp)
-		}
-		secret, err := createRemoteSecret(opt, cluster.client, env)
+		secret, err := desiredRemoteSecret(cluster, env)
 		if err != nil {
 			err := fmt.Errorf("not joining cluster %v, could not creating remote secret: %v", cluster.Context, err)
 			errs = multierror.Append(errs, err)
@@ -114,6 +186,14 @@ func apply(mesh *Mesh, env Environment) error {
 		existingSecretsByUID[cluster.uid] = cluster.readRemoteSecrets(env)
 	}
 
+	changesByUID := make(map[types.UID]*clusterChanges)
+	changesOf := func(cluster *Cluster) *clusterChanges {
+		if _, ok := changesByUID[cluster.uid]; !ok {
+			changesByUID[cluster.uid] = &clusterChanges{cluster: cluster}
+		}
+		return changesByUID[cluster.uid]
+	}
+
 	joined := make(map[string]bool)
 
 	for _, first := range sortedClusters {
@@ -156,9 +236,8 @@ func apply(mesh *Mesh, env Environment) error {
 					continue
 				}
 
-				if err := applySecret(env, s.local, remoteSecret); err != nil {
-					env.Errorf("%v failed: %v\n", s.local, err)
-				}
+				changes := changesOf(s.local)
+				changes.apply = append(changes.apply, remoteSecret)
 				delete(existingSecretsByUID[s.local.uid], s.remote.uid)
 			}
 		}
@@ -167,23 +246,58 @@ func apply(mesh *Mesh, env Environment) error {
 	// existingSecretsByUID any leftover currentSecretsByUID
 	for uid, secrets := range existingSecretsByUID {
 		for _, secret := range secrets {
-			cluster := mesh.clustersByUID[uid]
-			fmt.Printf("Pruning %v from %v\n", secret.Name, cluster)
-			if err := deleteSecret(cluster, secret); err != nil {
-				err := fmt.Errorf("failed to prune secret %v from cluster %v: %v", secret.Name, cluster, err)
-				env.Errorf(err.Error())
-				errs = multierror.Append(errs, err)
-				continue
-			}
+			changes := changesOf(mesh.clustersByUID[uid])
+			changes.prune = append(changes.prune, secret)
+		}
+	}
+
+	plan := make([]*clusterChanges, 0, len(changesByUID))
+	for _, cluster := range sortedClusters {
+		if changes, ok := changesByUID[cluster.uid]; ok {
+			sort.Slice(changes.prune, func(i, j int) bool { return changes.prune[i].Name < changes.prune[j].Name })
+			plan = append(plan, changes)
+		}
+	}
+	return plan, errs.ErrorOrNil()
+}
+
+// applyChanges applies the changes of a cluster, and returns whether they were all applied. The
+// secrets which can't be applied are reported but not returned as errors, unlike the secrets which
+// can't be pruned.
+func applyChanges(env Environment, changes *clusterChanges) (bool, error) {
+	var errs *multierror.Error
+
+	complete := true
+	for _, secret := range changes.apply {
+		if err := applySecret(env, changes.cluster, secret); err != nil {
+			env.Errorf("%v failed: %v\n", changes.cluster, err)
+			complete = false
 		}
 	}
 
-	return errs.ErrorOrNil()
+	for _, secret := range changes.prune {
+		env.Printf("Pruning %v from %v\n", secret.Name, changes.cluster)
+		if err := deleteSecret(changes.cluster, secret); err != nil {
+			err := fmt.Errorf("failed to prune secret %v from cluster %v: %v", secret.Name, changes.cluster, err)
+			env.Errorf(err.Error())
+			errs = multierror.Append(errs, err)
+			continue
+		}
+	}
+
+	return complete, errs.ErrorOrNil()
+}
+
+func apply(mesh *Mesh, env Environment) error {
+	return applyStaged(mesh, env, stageOptions{})
 }
 
 type applyOptions struct {
 	KubeOptions
 	filenameOption
+	stageOptions
+	// maxCredentialAge is the age of the credentials of the remote secrets above which apply warns.
+	maxCredentialAge time.Duration
 }
 
 func (o *applyOptions) prepare(flags *pflag.FlagSet) error {
@@ -193,6 +307,9 @@ func (o *applyOptions) prepare(flags *pflag.FlagSet) error {
 
 func (o *applyOptions) addFlags(flags *pflag.FlagSet) {
 	o.filenameOption.addFlags(flags)
+	o.stageOptions.addFlags(flags)
+	flags.DurationVar(&o.maxCredentialAge, "max-credential-age", defaultMaxCredentialAge,
+		"warn about the remote secrets whose credentials are older than this. Zero disables the warning")
 }
 
 // NewApplyCommand creates a new command for applying multicluster configuration to the mesh.
@@ -213,7 +330,8 @@ func NewApplyCommand() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			return apply(mesh, env)
+			warnAgingRemoteSecrets(mesh, env, opt.maxCredentialAge)
+			return applyStaged(mesh, env, opt.stageOptions)
 		},
 	}
 	opt.addFlags(c.PersistentFlags())
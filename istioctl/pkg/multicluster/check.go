@@ -0,0 +1,253 @@
+// Copyright 2019 Istio Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multicluster
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/tools/clientcmd/api/latest"
+)
+
+type driftStatus string
+
+const (
+	driftStatusOk driftStatus = "ok"
+	// the remote secret apply would create is missing.
+	driftStatusMissing driftStatus = "missing"
+	// the remote secret is not part of the mesh description, apply would prune it.
+	driftStatusUnexpected driftStatus = "unexpected"
+	// the kubeconfig of the remote secret can't be used.
+	driftStatusInvalid driftStatus = "invalid"
+	// the token of the kubeconfig of the remote secret expired.
+	driftStatusTokenExpired driftStatus = "tokenExpired"
+	// the kubeconfig of the remote secret differs from the one apply would create, e.g. the token or the
+	// server address changed.
+	driftStatusStale driftStatus = "stale"
+	// the remote secret apply would create can't be generated.
+	driftStatusUnknown driftStatus = "unknown"
+)
+
+// remoteSecretDrift is the state of the remote secret of a cluster of the mesh in another cluster.
+type remoteSecretDrift struct {
+	// cluster is where the remote secret is.
+	cluster *Cluster
+	// remote is the context of the cluster referenced by the remote secret, or its UID if it is not part
+	// of the mesh.
+	remote string
+	status driftStatus
+	detail string
+}
+
+// joinable returns whether the service registry of the cluster is joined with the other clusters by apply.
+func joinable(c *Cluster) bool {
+	return c.installed && !c.DisableRegistryJoin
+}
+
+// tokenExpiry returns the expiration time of the JWT token, or zero if it has none.
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}
+	}
+	claims := struct {
+		Exp int64 `json:"exp"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
+
+// kubeconfigToken returns the bearer token of the current context of the kubeconfig, if any.
+func kubeconfigToken(kubeconfig []byte) string {
+	out, _, err := latest.Codec.Decode(kubeconfig, nil, nil)
+	if err != nil {
+		return ""
+	}
+	config, ok := out.(*api.Config)
+	if !ok {
+		return ""
+	}
+	context, ok := config.Contexts[config.CurrentContext]
+	if !ok {
+		return ""
+	}
+	authInfo, ok := config.AuthInfos[context.AuthInfo]
+	if !ok {
+		return ""
+	}
+	return authInfo.Token
+}
+
+// checkRemoteSecret compares the remote secret of the remote cluster with the one apply would create.
+func checkRemoteSecret(env Environment, secret, desired *v1.Secret, remote *Cluster, now time.Time) (driftStatus, string) {
+	state, server := secretStateAndServer(env, remoteSecrets{remote.uid: secret}, remote)
+	switch state {
+	case rsStatusOk:
+	case seStatusServerAddrMismatch:
+		return driftStatusStale, fmt.Sprintf("server %v", server)
+	default:
+		return driftStatusInvalid, string(state)
+	}
+
+	kubeconfig := secret.Data[string(remote.uid)]
+	if expiry := tokenExpiry(kubeconfigToken(kubeconfig)); !expiry.IsZero() && !now.Before(expiry) {
+		return driftStatusTokenExpired, fmt.Sprintf("token expired at %v", expiry.Format(time.RFC3339))
+	}
+
+	if desired == nil {
+		return driftStatusUnknown, "the remote secret of the cluster can't be generated"
+	}
+	if !bytes.Equal(kubeconfig, desired.Data[string(remote.uid)]) {
+		return driftStatusStale, "kubeconfig differs from the one apply would create"
+	}
+	return driftStatusOk, ""
+}
+
+// checkMesh compares the remote secrets of each cluster of the mesh with the ones apply would create, given
+// the desired remote secrets of the clusters by UID.
+func checkMesh(mesh *Mesh, env Environment, desired map[types.UID]*v1.Secret, now time.Time) []*remoteSecretDrift {
+	var drifts []*remoteSecretDrift
+	sortedClusters := mesh.SortedClusters()
+	for _, cluster := range sortedClusters {
+		if !cluster.installed {
+			continue
+		}
+		existing := cluster.readRemoteSecrets(env)
+
+		for _, remote := range sortedClusters {
+			if remote.uid == cluster.uid {
+				continue
+			}
+			drift := &remoteSecretDrift{cluster: cluster, remote: remote.Context}
+			secret, found := existing[remote.uid]
+			delete(existing, remote.uid)
+			switch {
+			case !joinable(cluster) || !joinable(remote):
+				if !found {
+					continue
+				}
+				drift.status, drift.detail = driftStatusUnexpected, "registry join is disabled or Istio is not installed"
+			case !found:
+				drift.status = driftStatusMissing
+			default:
+				drift.status, drift.detail = checkRemoteSecret(env, secret, desired[remote.uid], remote, now)
+			}
+			drifts = append(drifts, drift)
+		}
+
+		leftovers := make([]types.UID, 0, len(existing))
+		for uid := range existing {
+			leftovers = append(leftovers, uid)
+		}
+		sort.Slice(leftovers, func(i, j int) bool { return leftovers[i] < leftovers[j] })
+		for _, uid := range leftovers {
+			drifts = append(drifts, &remoteSecretDrift{
+				cluster: cluster,
+				remote:  string(uid),
+				status:  driftStatusUnexpected,
+				detail:  fmt.Sprintf("cluster not in the mesh, secret %v", existing[uid].Name),
+			})
+		}
+	}
+	return drifts
+}
+
+func printDrifts(env Environment, drifts []*remoteSecretDrift) {
+	tw := tabwriter.NewWriter(env.Stdout(), 0, 8, 2, '\t', 0)
+	_, _ = fmt.Fprintf(tw, "CLUSTER\tREMOTE\tSTATUS\tDETAIL\n")
+	for _, drift := range drifts {
+		_, _ = fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", drift.cluster.Context, drift.remote, drift.status, drift.detail)
+	}
+	_ = tw.Flush()
+}
+
+// Check prints the state of the remote secrets of each cluster of the mesh, and returns an error if any
+// drifted from the mesh description.
+func Check(opt checkOptions, env Environment) error {
+	mesh, err := meshFromFileDesc(opt.filename, env)
+	if err != nil {
+		return err
+	}
+
+	drifts := checkMesh(mesh, env, desiredRemoteSecrets(mesh, env), time.Now())
+	printDrifts(env, drifts)
+
+	drifted := 0
+	for _, drift := range drifts {
+		if drift.status != driftStatusOk {
+			drifted++
+		}
+	}
+	if drifted > 0 {
+		return fmt.Errorf("%v of %v remote secrets drifted from the mesh description", drifted, len(drifts))
+	}
+	return nil
+}
+
+type checkOptions struct {
+	KubeOptions
+	filenameOption
+}
+
+func (o *checkOptions) prepare(flags *pflag.FlagSet) error {
+	o.KubeOptions.prepare(flags)
+	return o.filenameOption.prepare()
+}
+
+func (o *checkOptions) addFlags(flags *pflag.FlagSet) {
+	o.filenameOption.addFlags(flags)
+}
+
+// NewCheckCommand creates a new command for checking the drift of the clusters from the mesh topology.
+func NewCheckCommand() *cobra.Command {
+	opt := checkOptions{}
+	c := &cobra.Command{
+		Use:     "check -f <mesh.yaml>",
+		Aliases: []string{"status"},
+		Short:   `Check the remote secrets of the clusters of a multi-cluster mesh against the mesh topology`,
+		Long: `Check the remote secrets of the clusters of a multi-cluster mesh against the mesh topology, without
+changing them. The remote secrets which are missing, which are not part of the mesh, whose token expired or
+whose kubeconfig differs from the one apply would create are reported, and the command fails if any.`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := opt.prepare(c.Flags()); err != nil {
+				return err
+			}
+			env, err := NewEnvironmentFromCobra(opt.Kubeconfig, opt.Context, c)
+			if err != nil {
+				return err
+			}
+			return Check(opt, env)
+		},
+	}
+	opt.addFlags(c.PersistentFlags())
+	return c
+}
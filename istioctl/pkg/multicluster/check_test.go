@@ -0,0 +1,113 @@
+// Copyright 2019 Istio Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multicluster
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func makeJWT(exp int64) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"alg":"RS256"}`)) + "." + encode([]byte(fmt.Sprintf(`{"exp":%d}`, exp))) + ".sig"
+}
+
+func TestTokenExpiry(t *testing.T) {
+	cases := []struct {
+		token string
+		want  time.Time
+	}{
+		{token: makeJWT(1000), want: time.Unix(1000, 0)},
+		{token: makeJWT(0)},
+		{token: "context0-token"},
+		{token: "a.!.c"},
+	}
+	for _, c := range cases {
+		if got := tokenExpiry(c.token); !got.Equal(c.want) {
+			t.Errorf("tokenExpiry(%q) = %v, want %v", c.token, got, c.want)
+		}
+	}
+}
+
+func TestCheckMesh(t *testing.T) {
+	now := time.Now()
+
+	_, staleKubeconfig := makeKubeconfig(clusters[0], []byte("rotated-token"), caDatas[0])
+	stale := makeRemoteSecret(clusters[0], staleKubeconfig)
+	_, expiredKubeconfig := makeKubeconfig(clusters[2], []byte(makeJWT(now.Add(-time.Hour).Unix())), caDatas[2])
+	expired := makeRemoteSecret(clusters[2], expiredKubeconfig)
+	departed := makeRemoteSecret(&Cluster{uid: "uid9", Context: "context9"}, kubeconfigs[0])
+
+	meshClusters := []*Cluster{cloneCluster(clusters[0]), cloneCluster(clusters[1]), cloneCluster(clusters[2])}
+	initObjs := map[types.UID][]runtime.Object{
+		clusters[0].uid: {remoteSecretClusters[1]},
+		clusters[1].uid: {stale, expired, departed},
+		clusters[2].uid: {remoteSecretClusters[0], remoteSecretClusters[1]},
+	}
+	for _, cluster := range meshClusters {
+		cluster.client = fake.NewSimpleClientset(initObjs[cluster.uid]...)
+	}
+	mesh := NewMesh(&MeshDesc{MeshID: "MyMeshID"}, meshClusters...)
+	env := newFakeEnvironmentOrDie(t, apiConfig)
+	desired := map[types.UID]*v1.Secret{
+		clusters[0].uid: remoteSecretClusters[0],
+		clusters[1].uid: remoteSecretClusters[1],
+		clusters[2].uid: remoteSecretClusters[2],
+	}
+
+	got := make(map[string]driftStatus)
+	for _, drift := range checkMesh(mesh, env, desired, now) {
+		got[drift.cluster.Context+"/"+drift.remote] = drift.status
+	}
+	want := map[string]driftStatus{
+		"context0/context1": driftStatusOk,
+		"context0/context2": driftStatusMissing,
+		"context1/context0": driftStatusStale,
+		"context1/context2": driftStatusTokenExpired,
+		"context1/uid9":     driftStatusUnexpected,
+		"context2/context0": driftStatusOk,
+		"context2/context1": driftStatusOk,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong drifts: %v", diff)
+	}
+
+	// The remote secrets of a cluster whose registry join is disabled are pruned by apply.
+	meshClusters[1].DisableRegistryJoin = true
+	got = make(map[string]driftStatus)
+	for _, drift := range checkMesh(mesh, env, desired, now) {
+		got[drift.cluster.Context+"/"+drift.remote] = drift.status
+	}
+	want = map[string]driftStatus{
+		"context0/context1": driftStatusUnexpected,
+		"context0/context2": driftStatusMissing,
+		"context1/context0": driftStatusUnexpected,
+		"context1/context2": driftStatusUnexpected,
+		"context1/uid9":     driftStatusUnexpected,
+		"context2/context0": driftStatusOk,
+		"context2/context1": driftStatusUnexpected,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong drifts with registry join disabled: %v", diff)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -33,6 +34,7 @@ type ConditionFunc func() (done bool, err error)
 type Environment interface {
 	GetConfig() *api.Config
 	CreateClientSet(context string) (kubernetes.Interface, error)
+	Stdin() io.Reader
 	Stdout() io.Writer
 	Stderr() io.Writer
 	ReadFile(filename string) ([]byte, error)
@@ -43,6 +45,7 @@ type Environment interface {
 
 type KubeEnvironment struct {
 	config     *api.Config
+	stdin      io.Reader
 	stdout     io.Writer
 	stderr     io.Writer
 	kubeconfig string
@@ -59,6 +62,14 @@ func (e *KubeEnvironment) Errorf(format string, a ...interface{}) {
 	_, _ = fmt.Fprintf(e.stderr, format, a...)
 }
 
+// Stdin returns the standard input, os.Stdin unless set.
+func (e *KubeEnvironment) Stdin() io.Reader {
+	if e.stdin == nil {
+		return os.Stdin
+	}
+	return e.stdin
+}
+
 func (e *KubeEnvironment) GetConfig() *api.Config                   { return e.config }
 func (e *KubeEnvironment) Stdout() io.Writer                        { return e.stdout }
 func (e *KubeEnvironment) Stderr() io.Writer                        { return e.stderr }
@@ -85,6 +96,23 @@ func NewEnvironment(kubeconfig, context string, stdout, stderr io.Writer) (*Kube
 	}, nil
 }
 
+// NewEnvironmentFromConfig returns an environment using the kubeconfig already loaded in config, and
+// the given streams.
+func NewEnvironmentFromConfig(kubeconfig string, config *api.Config, stdin io.Reader, stdout, stderr io.Writer) *KubeEnvironment {
+	return &KubeEnvironment{
+		config:     config,
+		stdin:      stdin,
+		stdout:     stdout,
+		stderr:     stderr,
+		kubeconfig: kubeconfig,
+	}
+}
+
 func NewEnvironmentFromCobra(kubeconfig, context string, cmd *cobra.Command) (Environment, error) {
-	return NewEnvironment(kubeconfig, context, cmd.OutOrStdout(), cmd.OutOrStderr())
+	env, err := NewEnvironment(kubeconfig, context, cmd.OutOrStdout(), cmd.OutOrStderr())
+	if err != nil {
+		return nil, err
+	}
+	env.stdin = cmd.InOrStdin()
+	return env, nil
 }
@@ -0,0 +1,290 @@
+// Copyright 2019 Istio Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multicluster
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/security/pkg/k8s/controller"
+	"istio.io/istio/security/pkg/pki/util"
+	pb "istio.io/istio/security/proto"
+)
+
+const (
+	// DefaultIntermediateCAServiceAccountName is the default service account authenticating the
+	// requests of intermediate CAs, which must be allowed by CA_SUBORDINATE_CA_IDENTITIES on istiod.
+	DefaultIntermediateCAServiceAccountName = "istio-remote-ca"
+
+	// intermediateCASecretName is the secret holding the plugged-in CA certificate of istiod.
+	intermediateCASecretName = "cacerts"
+
+	// IntermediateCASerialAnnotation is the hexadecimal serial number of the intermediate CA
+	// certificate of the secret, used to revoke it.
+	IntermediateCASerialAnnotation = "istio.io/intermediateCASerial"
+
+	intermediateCAKeySize = 2048
+	caRequestTimeout      = 30 * time.Second
+)
+
+// IntermediateCAOptions contains the options for requesting and revoking intermediate CAs.
+type IntermediateCAOptions struct {
+	KubeOptions
+
+	// Address of the CA of the primary cluster, e.g. the address of the istiod gateway.
+	CAAddress string
+
+	// Server name of the CA certificate.
+	CAServerName string
+
+	// Authenticate the requests with this service account's token.
+	ServiceAccountName string
+
+	// TTL of the intermediate CA certificate, the default TTL of the CA if zero.
+	TTL time.Duration
+}
+
+func (o *IntermediateCAOptions) addFlags(flagset *pflag.FlagSet) {
+	flagset.StringVar(&o.CAAddress, "ca-address", o.CAAddress,
+		"address of the CA of the primary cluster, e.g. istiod.example.com:15012.")
+	flagset.StringVar(&o.CAServerName, "ca-server-name", o.CAServerName,
+		"server name of the certificate of the CA.")
+	flagset.StringVar(&o.ServiceAccountName, "service-account", o.ServiceAccountName,
+		"authenticate the requests with this service account's token.")
+}
+
+func (o *IntermediateCAOptions) validate() error {
+	if o.CAAddress == "" {
+		return errors.New("must specify --ca-address")
+	}
+	return nil
+}
+
+// NewCreateIntermediateCACommand returns the command creating the secret of an intermediate CA for
+// the istiod of a remote cluster.
+func NewCreateIntermediateCACommand() *cobra.Command {
+	opts := IntermediateCAOptions{
+		CAServerName:       "istiod.istio-system.svc",
+		ServiceAccountName: DefaultIntermediateCAServiceAccountName,
+	}
+	c := &cobra.Command{
+		Use:   "create-intermediate-ca <cluster-name>",
+		Short: "Create the secret of an intermediate CA, signed by the CA of the primary cluster, for the istiod of a remote cluster",
+		Example: `
+# Request an intermediate CA from the CA of cluster c0 and install it in cluster c1, without sharing the root key.
+istioctl --Kubeconfig=c0.yaml x create-intermediate-ca c1 --ca-address istiod.example.com:15012 \
+    | kubectl -n istio-system --Kubeconfig=c1.yaml apply -f -
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			opts.prepare(c.Flags())
+			if err := opts.validate(); err != nil {
+				return err
+			}
+			env, err := NewEnvironmentFromCobra(opts.Kubeconfig, opts.Context, c)
+			if err != nil {
+				return err
+			}
+			out, err := CreateIntermediateCA(opts, args[0], env)
+			if err != nil {
+				fmt.Fprintf(c.OutOrStderr(), "error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprint(c.OutOrStdout(), out)
+			return nil
+		},
+	}
+	opts.addFlags(c.PersistentFlags())
+	c.PersistentFlags().DurationVar(&opts.TTL, "ttl", opts.TTL,
+		"TTL of the intermediate CA certificate, the default TTL of the CA if not set.")
+	return c
+}
+
+// NewRevokeIntermediateCACommand returns the command revoking an intermediate CA.
+func NewRevokeIntermediateCACommand() *cobra.Command {
+	opts := IntermediateCAOptions{
+		CAServerName:       "istiod.istio-system.svc",
+		ServiceAccountName: DefaultIntermediateCAServiceAccountName,
+	}
+	c := &cobra.Command{
+		Use:   "revoke-intermediate-ca <serial-number>",
+		Short: "Revoke an intermediate CA created with create-intermediate-ca",
+		Example: `
+# Revoke the intermediate CA of cluster c1.
+istioctl --Kubeconfig=c0.yaml x revoke-intermediate-ca --ca-address istiod.example.com:15012 \
+    $(kubectl -n istio-system --Kubeconfig=c1.yaml get secret cacerts \
+    -o jsonpath='{.metadata.annotations.istio\.io/intermediateCASerial}')
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			opts.prepare(c.Flags())
+			if err := opts.validate(); err != nil {
+				return err
+			}
+			env, err := NewEnvironmentFromCobra(opts.Kubeconfig, opts.Context, c)
+			if err != nil {
+				return err
+			}
+			if err := RevokeIntermediateCA(opts, args[0], env); err != nil {
+				return err
+			}
+			fmt.Fprintf(c.OutOrStdout(), "intermediate CA %s revoked\n", args[0])
+			return nil
+		},
+	}
+	opts.addFlags(c.PersistentFlags())
+	return c
+}
+
+// CreateIntermediateCA requests an intermediate CA certificate for the given cluster from the CA of
+// the primary cluster, and returns the cacerts secret plugging it into the istiod of the cluster.
+func CreateIntermediateCA(opt IntermediateCAOptions, clusterName string, env Environment) (string, error) {
+	client, err := env.CreateClientSet(opt.Context)
+	if err != nil {
+		return "", err
+	}
+
+	csrPEM, keyPEM, err := util.GenCSR(util.CertOptions{
+		Org:        clusterName,
+		IsCA:       true,
+		RSAKeySize: intermediateCAKeySize,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate the CSR: %v", err)
+	}
+
+	conn, ctx, cancel, err := dialCA(opt, client)
+	if err != nil {
+		return "", err
+	}
+	defer cancel()
+	defer conn.Close()
+	resp, err := pb.NewIstioSubordinateCAServiceClient(conn).CreateSubordinateCA(ctx, &pb.IstioCertificateRequest{
+		Csr:              string(csrPEM),
+		SubjectId:        clusterName,
+		ValidityDuration: int64(opt.TTL.Seconds()),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create the intermediate CA: %v", err)
+	}
+
+	secret, err := createIntermediateCASecret(opt.Namespace, keyPEM, resp.CertChain)
+	if err != nil {
+		return "", err
+	}
+	w := makeOutputWriterTestHook()
+	if err := writeEncodedObject(w, secret); err != nil {
+		return "", err
+	}
+	return w.String(), nil
+}
+
+// RevokeIntermediateCA revokes the intermediate CA certificate with the given serial number.
+func RevokeIntermediateCA(opt IntermediateCAOptions, serial string, env Environment) error {
+	client, err := env.CreateClientSet(opt.Context)
+	if err != nil {
+		return err
+	}
+	conn, ctx, cancel, err := dialCA(opt, client)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	defer conn.Close()
+	_, err = pb.NewIstioSubordinateCAServiceClient(conn).RevokeSubordinateCA(ctx,
+		&pb.RevokeSubordinateCARequest{SerialNumber: serial})
+	if err != nil {
+		return fmt.Errorf("failed to revoke the intermediate CA: %v", err)
+	}
+	return nil
+}
+
+// dialCA connects to the CA of the primary cluster, trusting its root cert, and returns the context of
+// the requests, authenticated with the token of the service account.
+func dialCA(opt IntermediateCAOptions, client kubernetes.Interface) (*grpc.ClientConn, context.Context,
+	context.CancelFunc, error) {
+	cm, err := client.CoreV1().ConfigMaps(opt.Namespace).Get(controller.CACertNamespaceConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("could not read the root cert of the CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(cm.Data[controller.CACertNamespaceConfigMapDataName])) {
+		return nil, nil, nil, fmt.Errorf("invalid root cert in configmap %s/%s", opt.Namespace, cm.Name)
+	}
+
+	tokenSecret, err := getServiceAccountSecretToken(client, opt.ServiceAccountName, opt.Namespace)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	token, ok := tokenSecret.Data[v1.ServiceAccountTokenKey]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("no %q data found in token secret %s/%s",
+			v1.ServiceAccountTokenKey, tokenSecret.Namespace, tokenSecret.Name)
+	}
+
+	creds := credentials.NewTLS(&tls.Config{RootCAs: pool, ServerName: opt.CAServerName})
+	conn, err := grpc.Dial(opt.CAAddress, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to connect to the CA %s: %v", opt.CAAddress, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), caRequestTimeout)
+	ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs("Authorization", "Bearer "+string(token)))
+	return conn, ctx, cancel, nil
+}
+
+// createIntermediateCASecret returns the cacerts secret of an intermediate CA, from its key and the
+// certificate chain returned by the CA, the root cert being the last element.
+func createIntermediateCASecret(namespace string, keyPEM []byte, certChain []string) (*v1.Secret, error) {
+	if len(certChain) < 2 {
+		return nil, fmt.Errorf("invalid certificate chain of %d certificates", len(certChain))
+	}
+	cert, err := util.ParsePemEncodedCertificate([]byte(certChain[0]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid intermediate CA certificate: %v", err)
+	}
+
+	chain := make([]string, 0, len(certChain))
+	for _, c := range certChain {
+		chain = append(chain, strings.TrimSuffix(c, "\n")+"\n")
+	}
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        intermediateCASecretName,
+			Namespace:   namespace,
+			Annotations: map[string]string{IntermediateCASerialAnnotation: cert.SerialNumber.Text(16)},
+		},
+		StringData: map[string]string{
+			"ca-cert.pem":    chain[0],
+			"ca-key.pem":     string(keyPEM),
+			"cert-chain.pem": strings.Join(chain, ""),
+			"root-cert.pem":  chain[len(chain)-1],
+		},
+	}, nil
+}
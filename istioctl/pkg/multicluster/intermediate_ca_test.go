@@ -0,0 +1,104 @@
+// Copyright 2019 Istio Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multicluster
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"istio.io/istio/security/pkg/k8s/controller"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+func TestCreateIntermediateCASecret(t *testing.T) {
+	rootCert, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+		IsCA:         true,
+		IsSelfSigned: true,
+		TTL:          time.Hour,
+		Org:          "Root CA",
+		RSAKeySize:   1024,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert := strings.TrimSuffix(string(rootCert), "\n")
+
+	if _, err := createIntermediateCASecret(testNamespace, []byte("key"), []string{caCert}); err == nil {
+		t.Error("expected an error for a chain without root cert")
+	}
+
+	secret, err := createIntermediateCASecret(testNamespace, []byte("key"), []string{caCert, string(rootCert)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Name != "cacerts" || secret.Namespace != testNamespace {
+		t.Errorf("got secret %s/%s, want %s/cacerts", secret.Namespace, secret.Name, testNamespace)
+	}
+	if secret.Annotations[IntermediateCASerialAnnotation] == "" {
+		t.Error("the serial number of the intermediate CA is not annotated")
+	}
+	want := map[string]string{
+		"ca-cert.pem":    string(rootCert),
+		"ca-key.pem":     "key",
+		"cert-chain.pem": string(rootCert) + string(rootCert),
+		"root-cert.pem":  string(rootCert),
+	}
+	for k, v := range want {
+		if secret.StringData[k] != v {
+			t.Errorf("got %s %q, want %q", k, secret.StringData[k], v)
+		}
+	}
+}
+
+func TestCreateIntermediateCAErrors(t *testing.T) {
+	rootCM := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: controller.CACertNamespaceConfigMap, Namespace: testNamespace},
+		Data:       map[string]string{controller.CACertNamespaceConfigMapDataName: "invalid"},
+	}
+	cases := []struct {
+		name    string
+		objs    []runtime.Object
+		wantErr string
+	}{
+		{
+			name:    "no root cert",
+			wantErr: "could not read the root cert of the CA",
+		},
+		{
+			name:    "invalid root cert",
+			objs:    []runtime.Object{rootCM},
+			wantErr: "invalid root cert",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			env := newFakeEnvironmentOrDie(t, nil, c.objs...)
+			opts := IntermediateCAOptions{
+				KubeOptions:        KubeOptions{Namespace: testNamespace},
+				CAAddress:          "istiod.example.com:15012",
+				ServiceAccountName: testServiceAccountName,
+			}
+			_, err := CreateIntermediateCA(opts, "remote", env)
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("got error %v, want %q", err, c.wantErr)
+			}
+		})
+	}
+}
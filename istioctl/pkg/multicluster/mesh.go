@@ -48,6 +48,64 @@ type ClusterDesc struct {
 
 	// When true, disables linking the service registry of this cluster with other clustersByContext in the mesh.
 	DisableRegistryJoin bool `json:"disableRegistryJoin,omitempty"`
+
+	// Optional authentication of the other clusters to the apiserver of this cluster. The token of the
+	// service account reader is used if not set.
+	Auth *ClusterAuthDesc `json:"auth,omitempty"`
+}
+
+// ClusterAuthDesc describes how the kubeconfig of the remote secret of a cluster authenticates to its
+// apiserver, e.g. with short-lived credentials of a cloud IAM instead of a service account token.
+type ClusterAuthDesc struct {
+	// Type of authentication, one of bearer-token, plugin, exec or client-certificate. `bearer-token` if
+	// not set.
+	Type RemoteSecretAuthType `json:"type,omitempty"`
+
+	// Name and configuration of the auth provider plugin, e.g. oidc or gcp, with type plugin.
+	PluginName   string            `json:"pluginName,omitempty"`
+	PluginConfig map[string]string `json:"pluginConfig,omitempty"`
+
+	// Exec credential plugin, with type exec.
+	Exec *ExecCredentialDesc `json:"exec,omitempty"`
+
+	// Files of the PEM encoded client certificate and key, with type client-certificate.
+	ClientCertificate string `json:"clientCertificate,omitempty"`
+	ClientKey         string `json:"clientKey,omitempty"`
+}
+
+// ExecCredentialDesc describes a command printing the credentials of the apiserver, run by Istio in the
+// other clusters. See https://kubernetes.io/docs/reference/access-authn-authz/authentication/#client-go-credential-plugins.
+type ExecCredentialDesc struct {
+	// Command to run, which must be available to the Istio control plane of the other clusters.
+	Command string `json:"command"`
+
+	// Arguments and environment variables of the command.
+	Args []string          `json:"args,omitempty"`
+	Env  map[string]string `json:"env,omitempty"`
+
+	// API version of the ExecCredential printed by the command.
+	// `client.authentication.k8s.io/v1beta1` if not set.
+	APIVersion string `json:"apiVersion,omitempty"`
+}
+
+// apply sets the authentication options of the remote secret of the cluster.
+func (d *ClusterAuthDesc) apply(opt *RemoteSecretOptions) {
+	if d == nil {
+		return
+	}
+	if d.Type != "" {
+		opt.AuthType = d.Type
+	}
+	opt.AuthPluginName = d.PluginName
+	opt.AuthPluginConfig = d.PluginConfig
+	if d.Exec != nil {
+		opt.ExecCommand = d.Exec.Command
+		opt.ExecArgs = d.Exec.Args
+		opt.ExecEnv = d.Exec.Env
+		opt.ExecAPIVersion = d.Exec.APIVersion
+	}
+	opt.ClientCertificate = d.ClientCertificate
+	opt.ClientKey = d.ClientKey
 }
 
 func (m *Mesh) addCluster(c *Cluster) {
@@ -135,6 +193,9 @@ func NewMulticlusterCommand() *cobra.Command {
 		NewGenerateCommand(),
 		NewApplyCommand(),
 		NewDescribeCommand(),
+		NewRemoveCommand(),
+		NewCheckCommand(),
+		NewRotateCommand(),
 	)
 
 	return c
@@ -0,0 +1,225 @@
+// Copyright 2019 Istio Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package multiclustertest provides a fake multi-cluster mesh, to test tools built on the
+// istioctl multicluster APIs without real clusters.
+package multiclustertest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/ghodss/yaml"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"istio.io/istio/istioctl/pkg/multicluster"
+)
+
+const (
+	fakeServiceAccountSecretName = "fake-service-account-secret-name"
+	istioNamespace               = "istio-system"
+)
+
+// The objects of the fake clusters, named after the index of the cluster in the mesh.
+
+func fakeContext(i int) string {
+	return fmt.Sprintf("context%v", i)
+}
+
+func fakeUID(i int) types.UID {
+	return types.UID(fmt.Sprintf("uid%v", i))
+}
+
+func fakeNetwork(i int) string {
+	return fmt.Sprintf("net%v", i)
+}
+
+func fakeServer(context string) string {
+	return fmt.Sprintf("server-%v", context)
+}
+
+func fakeToken(context string) []byte {
+	return []byte(fmt.Sprintf("%v-token", context))
+}
+
+func fakeCAData(context string) []byte {
+	return []byte(fmt.Sprintf("%v-caCert", context))
+}
+
+// fakeKubeSystemNamespace is the namespace whose UID identifies the cluster.
+func fakeKubeSystemNamespace(uid types.UID) *v1.Namespace {
+	return &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "kube-system",
+			UID:  uid,
+		},
+	}
+}
+
+// fakeServiceAccount is the service account whose credentials are embedded in the remote secrets.
+func fakeServiceAccount(name, namespace string) *v1.ServiceAccount {
+	return &v1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Secrets: []v1.ObjectReference{{
+			Name: fakeServiceAccountSecretName,
+		}},
+	}
+}
+
+func fakeServiceAccountTokenSecret(namespace string, token, caData []byte) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fakeServiceAccountSecretName,
+			Namespace: namespace,
+		},
+		Data: map[string][]byte{
+			v1.ServiceAccountRootCAKey: caData,
+			v1.ServiceAccountTokenKey:  token,
+		},
+	}
+}
+
+// FakeMeshEnvironment is an Environment simulating the clusters of a multi-cluster mesh with fake
+// clientsets, so that tools built on the multicluster APIs can be tested without real clusters.
+//
+// The cluster i of the mesh has the context `context<i>`, the UID `uid<i>`, the server `server-context<i>`
+// and Istio installed in istio-system, with the service account reader and its token secret. The
+// clusters and the mesh description can be changed before building the mesh.
+type FakeMeshEnvironment struct {
+	*multicluster.KubeEnvironment
+
+	// Clients are the fake clientsets of the clusters, by context.
+	Clients map[string]*fake.Clientset
+
+	// MeshDesc describes the fake clusters. It is also returned by ReadFile for MeshDescFilename.
+	MeshDesc *multicluster.MeshDesc
+
+	// Files are returned by ReadFile, by name, instead of the files on disk.
+	Files map[string][]byte
+
+	// Out and Err record the output of the commands.
+	Out bytes.Buffer
+	Err bytes.Buffer
+}
+
+var _ multicluster.Environment = (*FakeMeshEnvironment)(nil)
+
+// MeshDescFilename is the name of the mesh description of a FakeMeshEnvironment, e.g. for the -f flag
+// of the commands.
+const MeshDescFilename = "fake-mesh.yaml"
+
+// NewFakeMeshEnvironment returns an environment simulating a mesh of numClusters clusters.
+func NewFakeMeshEnvironment(meshID string, numClusters int) *FakeMeshEnvironment {
+	e := &FakeMeshEnvironment{
+		Clients: make(map[string]*fake.Clientset, numClusters),
+		MeshDesc: &multicluster.MeshDesc{
+			MeshID:   meshID,
+			Clusters: make(map[string]multicluster.ClusterDesc, numClusters),
+		},
+		Files: make(map[string][]byte),
+	}
+	config := &api.Config{
+		Contexts: make(map[string]*api.Context, numClusters),
+		Clusters: make(map[string]*api.Cluster, numClusters),
+	}
+	for i := 0; i < numClusters; i++ {
+		context := fakeContext(i)
+		e.Clients[context] = fake.NewSimpleClientset(
+			fakeKubeSystemNamespace(fakeUID(i)),
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: istioNamespace}},
+			fakeServiceAccount(multicluster.DefaultServiceAccountName, istioNamespace),
+			fakeServiceAccountTokenSecret(istioNamespace, fakeToken(context), fakeCAData(context)),
+		)
+		e.MeshDesc.Clusters[context] = multicluster.ClusterDesc{
+			Network:              fakeNetwork(i),
+			Namespace:            istioNamespace,
+			ServiceAccountReader: multicluster.DefaultServiceAccountName,
+		}
+		config.Contexts[context] = &api.Context{Cluster: context}
+		config.Clusters[context] = &api.Cluster{
+			Server:                   fakeServer(context),
+			CertificateAuthorityData: fakeCAData(context),
+		}
+	}
+	if numClusters > 0 {
+		config.CurrentContext = fakeContext(0)
+	}
+	e.KubeEnvironment = multicluster.NewEnvironmentFromConfig("fake", config, &bytes.Buffer{}, &e.Out, &e.Err)
+	return e
+}
+
+// CreateClientSet returns the fake clientset of the cluster.
+func (e *FakeMeshEnvironment) CreateClientSet(context string) (kubernetes.Interface, error) {
+	if context == "" {
+		context = e.GetConfig().CurrentContext
+	}
+	client, ok := e.Clients[context]
+	if !ok {
+		return nil, fmt.Errorf("context %q not found in the fake mesh", context)
+	}
+	return client, nil
+}
+
+// ReadFile returns the mesh description for MeshDescFilename and the files set in Files, and reads the
+// other files from disk.
+func (e *FakeMeshEnvironment) ReadFile(filename string) ([]byte, error) {
+	if filename == MeshDescFilename {
+		return e.MeshDescYAML()
+	}
+	if data, ok := e.Files[filename]; ok {
+		return data, nil
+	}
+	return ioutil.ReadFile(filename)
+}
+
+// Poll checks the condition once, without waiting.
+func (e *FakeMeshEnvironment) Poll(interval, timeout time.Duration, condition multicluster.ConditionFunc) error {
+	done, err := condition()
+	if err != nil {
+		return err
+	}
+	if !done {
+		return wait.ErrWaitTimeout
+	}
+	return nil
+}
+
+// MeshDescYAML returns the mesh description of the fake clusters.
+func (e *FakeMeshEnvironment) MeshDescYAML() ([]byte, error) {
+	return yaml.Marshal(e.MeshDesc)
+}
+
+// Mesh returns the mesh of the fake clusters, as discovered by the commands.
+func (e *FakeMeshEnvironment) Mesh() (*multicluster.Mesh, error) {
+	clusters := make([]*multicluster.Cluster, 0, len(e.MeshDesc.Clusters))
+	for context, desc := range e.MeshDesc.Clusters {
+		cluster, err := multicluster.NewCluster(context, desc, e)
+		if err != nil {
+			return nil, fmt.Errorf("error discovering %v: %v", context, err)
+		}
+		clusters = append(clusters, cluster)
+	}
+	return multicluster.NewMesh(e.MeshDesc, clusters...), nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 Istio Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multiclustertest
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"istio.io/istio/istioctl/pkg/multicluster"
+)
+
+func TestFakeMeshEnvironment(t *testing.T) {
+	g := NewWithT(t)
+
+	env := NewFakeMeshEnvironment("MyMeshID", 3)
+	mesh, err := env.Mesh()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	sortedClusters := mesh.SortedClusters()
+	g.Expect(sortedClusters).To(HaveLen(3))
+	for i, cluster := range sortedClusters {
+		context := fakeContext(i)
+		g.Expect(cluster.Context).To(Equal(context))
+		byUID, ok := mesh.ClusterByUID(fakeUID(i))
+		g.Expect(ok).To(BeTrue())
+		g.Expect(byUID).To(BeIdenticalTo(cluster))
+		g.Expect(cluster.Network).To(Equal(fakeNetwork(i)))
+		g.Expect(cluster.Namespace).To(Equal(istioNamespace))
+		g.Expect(cluster.ServiceAccountReader).To(Equal(multicluster.DefaultServiceAccountName))
+		g.Expect(env.GetConfig().Clusters[context].Server).To(Equal(fakeServer(context)))
+
+		client, err := env.CreateClientSet(context)
+		g.Expect(err).NotTo(HaveOccurred())
+		sa, err := client.CoreV1().ServiceAccounts(istioNamespace).Get(multicluster.DefaultServiceAccountName, metav1.GetOptions{})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(sa.Secrets).To(ConsistOf(v1.ObjectReference{Name: fakeServiceAccountSecretName}))
+		secret, err := client.CoreV1().Secrets(istioNamespace).Get(fakeServiceAccountSecretName, metav1.GetOptions{})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(secret.Data[v1.ServiceAccountTokenKey]).To(Equal([]byte(context + "-token")))
+		g.Expect(secret.Data[v1.ServiceAccountRootCAKey]).To(Equal([]byte(context + "-caCert")))
+	}
+
+	// the clusters can be changed before building the mesh.
+	desc := env.MeshDesc.Clusters[fakeContext(1)]
+	desc.DisableRegistryJoin = true
+	env.MeshDesc.Clusters[fakeContext(1)] = desc
+	mesh, err = env.Mesh()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mesh.SortedClusters()[1].DisableRegistryJoin).To(BeTrue())
+
+	_, err = env.CreateClientSet("context9")
+	g.Expect(err).To(HaveOccurred())
+	client, err := env.CreateClientSet("")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(client).To(BeIdenticalTo(env.Clients[fakeContext(0)]))
+
+	env.Files["extra.yaml"] = []byte("extra")
+	g.Expect(env.ReadFile("extra.yaml")).To(Equal([]byte("extra")))
+	_, err = env.ReadFile("does-not-exist.yaml")
+	g.Expect(err).To(HaveOccurred())
+
+	g.Expect(env.Poll(time.Second, time.Second, func() (bool, error) { return true, nil })).To(Succeed())
+	g.Expect(env.Poll(time.Second, time.Second, func() (bool, error) { return false, nil })).
+		To(Equal(wait.ErrWaitTimeout))
+
+	env.Printf("out")
+	env.Errorf("err")
+	g.Expect(env.Out.String()).To(Equal("out"))
+	g.Expect(env.Err.String()).To(Equal("err"))
+}
@@ -23,7 +23,11 @@ import (
 )
 
 // TODO(ayj) - add to istio.io/api/annotations
-const clusterContextAnnotationKey = "istio.io/clusterContext"
+const (
+	clusterContextAnnotationKey = "istio.io/clusterContext"
+	// the time the credentials of a remote secret were generated, in RFC3339 format.
+	remoteSecretCreationTimeAnnotationKey = "istio.io/remoteSecretCreationTime"
+)
 
 // KubeOptions contains kubernetes options common to all commands.
 type KubeOptions struct {
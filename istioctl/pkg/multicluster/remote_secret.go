@@ -16,9 +16,11 @@ package multicluster
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -173,6 +175,23 @@ func createPluginKubeconfig(caData []byte, context, server string, authProviderC
 	return c
 }
 
+func createExecKubeconfig(caData []byte, context, server string, execConfig *api.ExecConfig) *api.Config {
+	c := createBaseKubeconfig(caData, context, server)
+	c.AuthInfos[context] = &api.AuthInfo{
+		Exec: execConfig,
+	}
+	return c
+}
+
+func createClientCertificateKubeconfig(caData, certData, keyData []byte, context, server string) *api.Config {
+	c := createBaseKubeconfig(caData, context, server)
+	c.AuthInfos[context] = &api.AuthInfo{
+		ClientCertificateData: certData,
+		ClientKeyData:         keyData,
+	}
+	return c
+}
+
 func createRemoteSecretFromPlugin(
 	tokenSecret *v1.Secret,
 	context, server string,
@@ -191,6 +210,57 @@ func createRemoteSecretFromPlugin(
 	return createRemoteServiceAccountSecret(kubeconfig, uid, context)
 }
 
+func createRemoteSecretFromExec(
+	tokenSecret *v1.Secret,
+	context, server string,
+	uid types.UID,
+	execConfig *api.ExecConfig,
+) (*v1.Secret, error) {
+	caData, ok := tokenSecret.Data[v1.ServiceAccountRootCAKey]
+	if !ok {
+		return nil, errMissingRootCAKey
+	}
+
+	// Create a Kubeconfig to access the remote cluster using the exec credential plugin.
+	kubeconfig := createExecKubeconfig(caData, context, server, execConfig)
+
+	// Encode the Kubeconfig in a secret that can be loaded by Istio to dynamically discover and access the remote cluster.
+	return createRemoteServiceAccountSecret(kubeconfig, uid, context)
+}
+
+func createRemoteSecretFromClientCertificate(
+	tokenSecret *v1.Secret,
+	context, server string,
+	uid types.UID,
+	certData, keyData []byte,
+) (*v1.Secret, error) {
+	caData, ok := tokenSecret.Data[v1.ServiceAccountRootCAKey]
+	if !ok {
+		return nil, errMissingRootCAKey
+	}
+
+	// Create a Kubeconfig to access the remote cluster using the client certificate.
+	kubeconfig := createClientCertificateKubeconfig(caData, certData, keyData, context, server)
+
+	// Encode the Kubeconfig in a secret that can be loaded by Istio to dynamically discover and access the remote cluster.
+	return createRemoteServiceAccountSecret(kubeconfig, uid, context)
+}
+
+func readClientCertificate(opt RemoteSecretOptions, env Environment) ([]byte, []byte, error) {
+	if opt.ClientCertificate == "" || opt.ClientKey == "" {
+		return nil, nil, errors.New("the client certificate and key are required")
+	}
+	certData, err := env.ReadFile(opt.ClientCertificate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read the client certificate: %v", err)
+	}
+	keyData, err := env.ReadFile(opt.ClientKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read the client key: %v", err)
+	}
+	return certData, keyData, nil
+}
+
 var (
 	errMissingRootCAKey = fmt.Errorf("no %q data found", v1.ServiceAccountRootCAKey)
 	errMissingTokenKey  = fmt.Errorf("no %q data found", v1.ServiceAccountTokenKey)
@@ -293,6 +363,14 @@ const (
 
 	// User a custom custom authentication plugin for the remote kubernetes cluster.
 	RemoteSecretAuthTypePlugin RemoteSecretAuthType = "plugin"
+
+	// Use an exec credential plugin for authentication to the remote kubernetes cluster.
+	RemoteSecretAuthTypeExec RemoteSecretAuthType = "exec"
+
+	// Use a client certificate for authentication to the remote kubernetes cluster.
+	RemoteSecretAuthTypeClientCertificate RemoteSecretAuthType = "client-certificate"
+
+	defaultExecAPIVersion = "client.authentication.k8s.io/v1beta1"
 )
 
 // RemoteSecretOptions contains the options for creating a remote secret.
@@ -308,13 +386,24 @@ type RemoteSecretOptions struct {
 	// Authenticator plugin configuration
 	AuthPluginName   string
 	AuthPluginConfig map[string]string
+
+	// Exec credential plugin configuration
+	ExecCommand    string
+	ExecArgs       []string
+	ExecEnv        map[string]string
+	ExecAPIVersion string
+
+	// Files of the PEM encoded client certificate and key
+	ClientCertificate string
+	ClientKey         string
 }
 
 func (o *RemoteSecretOptions) addFlags(flagset *pflag.FlagSet) {
 	flagset.StringVar(&o.ServiceAccountName, "service-account", o.ServiceAccountName,
 		"create a secret with this service account's credentials.")
 	var supportedAuthType []string
-	for _, at := range []RemoteSecretAuthType{RemoteSecretAuthTypeBearerToken, RemoteSecretAuthTypePlugin} {
+	for _, at := range []RemoteSecretAuthType{RemoteSecretAuthTypeBearerToken, RemoteSecretAuthTypePlugin,
+		RemoteSecretAuthTypeExec, RemoteSecretAuthTypeClientCertificate} {
 		supportedAuthType = append(supportedAuthType, string(at))
 	}
 	flagset.Var(&o.AuthType, "auth-type",
@@ -322,9 +411,52 @@ func (o *RemoteSecretOptions) addFlags(flagset *pflag.FlagSet) {
 	flagset.StringVar(&o.AuthPluginName, "auth-plugin-name", o.AuthPluginName,
 		fmt.Sprintf("authenticator plug-in name. --auth-type=%v must be set with this option",
 			RemoteSecretAuthTypePlugin))
-	flagset.StringToString("auth-plugin-config", o.AuthPluginConfig,
+	flagset.StringToStringVar(&o.AuthPluginConfig, "auth-plugin-config", o.AuthPluginConfig,
 		fmt.Sprintf("authenticator plug-in configuration. --auth-type=%v must be set with this option",
 			RemoteSecretAuthTypePlugin))
+	flagset.StringVar(&o.ExecCommand, "exec-command", o.ExecCommand,
+		fmt.Sprintf("command of the exec credential plug-in. --auth-type=%v must be set with this option",
+			RemoteSecretAuthTypeExec))
+	flagset.StringArrayVar(&o.ExecArgs, "exec-arg", o.ExecArgs,
+		fmt.Sprintf("argument of the command of the exec credential plug-in, repeated for each argument. "+
+			"--auth-type=%v must be set with this option", RemoteSecretAuthTypeExec))
+	flagset.StringToStringVar(&o.ExecEnv, "exec-env", o.ExecEnv,
+		fmt.Sprintf("environment variables of the command of the exec credential plug-in. "+
+			"--auth-type=%v must be set with this option", RemoteSecretAuthTypeExec))
+	flagset.StringVar(&o.ExecAPIVersion, "exec-api-version", defaultExecAPIVersion,
+		fmt.Sprintf("API version of the credentials printed by the exec credential plug-in. "+
+			"--auth-type=%v must be set with this option", RemoteSecretAuthTypeExec))
+	flagset.StringVar(&o.ClientCertificate, "client-certificate", o.ClientCertificate,
+		fmt.Sprintf("file of the PEM encoded client certificate. --auth-type=%v must be set with this option",
+			RemoteSecretAuthTypeClientCertificate))
+	flagset.StringVar(&o.ClientKey, "client-key", o.ClientKey,
+		fmt.Sprintf("file of the PEM encoded client key. --auth-type=%v must be set with this option",
+			RemoteSecretAuthTypeClientCertificate))
+}
+
+// execConfig returns the exec credential plugin configuration of the kubeconfig.
+func (o *RemoteSecretOptions) execConfig() (*api.ExecConfig, error) {
+	if o.ExecCommand == "" {
+		return nil, errors.New("the command of the exec credential plug-in is required")
+	}
+	apiVersion := o.ExecAPIVersion
+	if apiVersion == "" {
+		apiVersion = defaultExecAPIVersion
+	}
+	config := &api.ExecConfig{
+		Command:    o.ExecCommand,
+		Args:       o.ExecArgs,
+		APIVersion: apiVersion,
+	}
+	names := make([]string, 0, len(o.ExecEnv))
+	for name := range o.ExecEnv {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		config.Env = append(config.Env, api.ExecEnvVar{Name: name, Value: o.ExecEnv[name]})
+	}
+	return config, nil
 }
 
 func createRemoteSecret(opt RemoteSecretOptions, client kubernetes.Interface, env Environment) (*v1.Secret, error) {
@@ -353,6 +485,17 @@ func createRemoteSecret(opt RemoteSecretOptions, client kubernetes.Interface, en
 			Config: opt.AuthPluginConfig,
 		}
 		remoteSecret, err = createRemoteSecretFromPlugin(tokenSecret, currentContext, server, uid, authProviderConfig)
+	case RemoteSecretAuthTypeExec:
+		var execConfig *api.ExecConfig
+		if execConfig, err = opt.execConfig(); err == nil {
+			remoteSecret, err = createRemoteSecretFromExec(tokenSecret, currentContext, server, uid, execConfig)
+		}
+	case RemoteSecretAuthTypeClientCertificate:
+		var certData, keyData []byte
+		if certData, keyData, err = readClientCertificate(opt, env); err == nil {
+			remoteSecret, err = createRemoteSecretFromClientCertificate(tokenSecret, currentContext, server, uid,
+				certData, keyData)
+		}
 	default:
 		err = fmt.Errorf("unsupported authentication type: %v", opt.AuthType)
 	}
@@ -653,3 +653,122 @@ users:
 		})
 	}
 }
+
+func TestCreateExecAndClientCertificateKubeconfig(t *testing.T) {
+	execConfig := &api.ExecConfig{
+		Command:    "aws-iam-authenticator",
+		Args:       []string{"token", "-i", "c0"},
+		APIVersion: defaultExecAPIVersion,
+	}
+	got := createExecKubeconfig([]byte("caData"), "c0", "https://c0", execConfig)
+	want := createBaseKubeconfig([]byte("caData"), "c0", "https://c0")
+	want.AuthInfos["c0"] = &api.AuthInfo{Exec: execConfig}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("wrong exec kubeconfig: %v", diff)
+	}
+
+	got = createClientCertificateKubeconfig([]byte("caData"), []byte("cert"), []byte("key"), "c0", "https://c0")
+	want = createBaseKubeconfig([]byte("caData"), "c0", "https://c0")
+	want.AuthInfos["c0"] = &api.AuthInfo{ClientCertificateData: []byte("cert"), ClientKeyData: []byte("key")}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("wrong client certificate kubeconfig: %v", diff)
+	}
+
+	if _, err := createRemoteSecretFromExec(makeSecret("", "", "token"), "c0", "", "uid", execConfig); err != errMissingRootCAKey {
+		t.Errorf("got error %v with an exec plugin, want %v", err, errMissingRootCAKey)
+	}
+	if _, err := createRemoteSecretFromClientCertificate(makeSecret("", "", "token"), "c0", "", "uid",
+		[]byte("cert"), []byte("key")); err != errMissingRootCAKey {
+		t.Errorf("got error %v with a client certificate, want %v", err, errMissingRootCAKey)
+	}
+}
+
+func TestRemoteSecretOptionsExecConfig(t *testing.T) {
+	opt := RemoteSecretOptions{
+		ExecCommand: "gke-gcloud-auth-plugin",
+		ExecArgs:    []string{"--verbose"},
+		ExecEnv:     map[string]string{"B": "2", "A": "1"},
+	}
+	got, err := opt.execConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &api.ExecConfig{
+		Command:    "gke-gcloud-auth-plugin",
+		Args:       []string{"--verbose"},
+		Env:        []api.ExecEnvVar{{Name: "A", Value: "1"}, {Name: "B", Value: "2"}},
+		APIVersion: defaultExecAPIVersion,
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("wrong exec config: %v", diff)
+	}
+
+	if _, err := (&RemoteSecretOptions{}).execConfig(); err == nil {
+		t.Error("wanted an error without command")
+	}
+}
+
+func TestClusterAuthDescApply(t *testing.T) {
+	cases := []struct {
+		name string
+		desc *ClusterAuthDesc
+		want RemoteSecretOptions
+	}{
+		{
+			name: "default",
+			want: RemoteSecretOptions{ServiceAccountName: "sa", AuthType: RemoteSecretAuthTypeBearerToken},
+		},
+		{
+			name: "oidc",
+			desc: &ClusterAuthDesc{
+				Type:         RemoteSecretAuthTypePlugin,
+				PluginName:   "oidc",
+				PluginConfig: map[string]string{"idp-issuer-url": "https://issuer"},
+			},
+			want: RemoteSecretOptions{
+				ServiceAccountName: "sa",
+				AuthType:           RemoteSecretAuthTypePlugin,
+				AuthPluginName:     "oidc",
+				AuthPluginConfig:   map[string]string{"idp-issuer-url": "https://issuer"},
+			},
+		},
+		{
+			name: "exec",
+			desc: &ClusterAuthDesc{
+				Type: RemoteSecretAuthTypeExec,
+				Exec: &ExecCredentialDesc{Command: "cmd", Args: []string{"a"}, Env: map[string]string{"K": "V"}},
+			},
+			want: RemoteSecretOptions{
+				ServiceAccountName: "sa",
+				AuthType:           RemoteSecretAuthTypeExec,
+				ExecCommand:        "cmd",
+				ExecArgs:           []string{"a"},
+				ExecEnv:            map[string]string{"K": "V"},
+			},
+		},
+		{
+			name: "client certificate",
+			desc: &ClusterAuthDesc{
+				Type:              RemoteSecretAuthTypeClientCertificate,
+				ClientCertificate: "cert.pem",
+				ClientKey:         "key.pem",
+			},
+			want: RemoteSecretOptions{
+				ServiceAccountName: "sa",
+				AuthType:           RemoteSecretAuthTypeClientCertificate,
+				ClientCertificate:  "cert.pem",
+				ClientKey:          "key.pem",
+			},
+		},
+	}
+	for i := range cases {
+		c := &cases[i]
+		t.Run(fmt.Sprintf("[%v] %v", i, c.name), func(tt *testing.T) {
+			got := RemoteSecretOptions{ServiceAccountName: "sa", AuthType: RemoteSecretAuthTypeBearerToken}
+			c.desc.apply(&got)
+			if diff := cmp.Diff(got, c.want); diff != "" {
+				tt.Errorf("wrong options: %v", diff)
+			}
+		})
+	}
+}
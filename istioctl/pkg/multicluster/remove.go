@@ -0,0 +1,286 @@
+// Copyright 2019 Istio Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multicluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"istio.io/istio/istioctl/pkg/kubernetes"
+)
+
+var (
+	removeVerifyInterval = 2 * time.Second
+	removeVerifyTimeout  = 1 * time.Minute
+
+	// pilotClientFactory returns the client of the pilots of the cluster of the context.
+	pilotClientFactory = func(kubeconfig, context string) (pilotClient, error) {
+		return kubernetes.NewClient(kubeconfig, context)
+	}
+)
+
+// pilotClient sends requests to the debug endpoints of the pilots of a cluster.
+type pilotClient interface {
+	AllPilotsDiscoveryDo(pilotNamespace, method, path string, body []byte) (map[string][]byte, error)
+}
+
+// referencesCluster returns whether the remote secret references the cluster, i.e. is named after its UID or
+// holds its kubeconfig.
+func referencesCluster(secret *v1.Secret, cluster *Cluster) bool {
+	if uidFromRemoteSecretName(secret.Name) == cluster.uid {
+		return true
+	}
+	_, ok := secret.Data[string(cluster.uid)]
+	return ok
+}
+
+// remoteSecretsOf returns the remote secrets of the local cluster which reference the remote cluster.
+func remoteSecretsOf(env Environment, local, remote *Cluster) []*v1.Secret {
+	var out []*v1.Secret
+	for _, secret := range local.readRemoteSecrets(env) {
+		if referencesCluster(secret, remote) {
+			out = append(out, secret)
+		}
+	}
+	return out
+}
+
+// remove detaches the departing cluster from the mesh: the remote secrets referencing it are deleted from the
+// other clusters, so that their control planes stop discovering its services and endpoints, and the remote
+// secrets of the departing cluster are deleted, so that its control plane stops discovering the mesh.
+func remove(mesh *Mesh, env Environment, departing *Cluster) error {
+	var errs *multierror.Error
+
+	deleteAll := func(cluster *Cluster, secrets []*v1.Secret) {
+		for _, secret := range secrets {
+			env.Printf("Removing %v from %v\n", secret.Name, cluster)
+			if err := deleteSecret(cluster, secret); err != nil && !kerrors.IsNotFound(err) {
+				err := fmt.Errorf("failed to remove secret %v from cluster %v: %v", secret.Name, cluster, err)
+				env.Errorf(err.Error())
+				errs = multierror.Append(errs, err)
+			}
+		}
+	}
+
+	for _, cluster := range mesh.SortedClusters() {
+		if cluster.uid == departing.uid {
+			continue
+		}
+		if !cluster.installed {
+			env.Printf("skipping cluster %v, Istio control plane not found\n", cluster)
+			continue
+		}
+		deleteAll(cluster, remoteSecretsOf(env, cluster, departing))
+	}
+
+	if departing.installed {
+		secrets := departing.readRemoteSecrets(env)
+		local := make([]*v1.Secret, 0, len(secrets))
+		for _, secret := range secrets {
+			local = append(local, secret)
+		}
+		sort.Slice(local, func(i, j int) bool { return local[i].Name < local[j].Name })
+		deleteAll(departing, local)
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// crossClusterEndpoints returns the endpoints that the pilots of the cluster discovered from the given clusters,
+// as reported by their endpoint shards. The shards of the remote clusters are named after their UID.
+func crossClusterEndpoints(client pilotClient, cluster *Cluster, from map[types.UID]bool) ([]string, error) {
+	results, err := client.AllPilotsDiscoveryDo(cluster.Namespace, "GET", "/debug/endpointShardz", nil)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for pilot, result := range results {
+		var services map[string]struct {
+			Shards map[string][]json.RawMessage
+		}
+		if err := json.Unmarshal(result, &services); err != nil {
+			return nil, fmt.Errorf("failed to parse the endpoint shards of %v: %v", pilot, err)
+		}
+		for hostname, service := range services {
+			for shard, endpoints := range service.Shards {
+				if len(endpoints) > 0 && from[types.UID(shard)] {
+					out = append(out, fmt.Sprintf("%d endpoints of %v from cluster %v in %v of %v",
+						len(endpoints), hostname, shard, pilot, cluster))
+				}
+			}
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// verifyRemoved waits until no cluster of the mesh references the departing cluster, nor the departing cluster
+// the mesh, and the pilots of the clusters no longer have the endpoints of the other side.
+func verifyRemoved(mesh *Mesh, env Environment, kubeconfig string, departing *Cluster) error {
+	// from are the clusters whose endpoints must be gone from the pilots of each cluster.
+	from := make(map[types.UID]map[types.UID]bool)
+	clients := make(map[types.UID]pilotClient)
+	for _, cluster := range append(mesh.SortedClusters(), departing) {
+		if !cluster.installed || clients[cluster.uid] != nil {
+			continue
+		}
+		client, err := pilotClientFactory(kubeconfig, cluster.Context)
+		if err != nil {
+			return fmt.Errorf("failed to create the pilot client of %v: %v", cluster, err)
+		}
+		clients[cluster.uid] = client
+		if cluster.uid != departing.uid {
+			from[cluster.uid] = map[types.UID]bool{departing.uid: true}
+			continue
+		}
+		from[cluster.uid] = make(map[types.UID]bool)
+		for _, other := range mesh.SortedClusters() {
+			if other.uid != departing.uid {
+				from[cluster.uid][other.uid] = true
+			}
+		}
+	}
+
+	var remaining []string
+	err := env.Poll(removeVerifyInterval, removeVerifyTimeout, func() (bool, error) {
+		remaining = remaining[:0]
+		for _, cluster := range mesh.SortedClusters() {
+			if cluster.uid == departing.uid || !cluster.installed {
+				continue
+			}
+			for _, secret := range remoteSecretsOf(env, cluster, departing) {
+				remaining = append(remaining, fmt.Sprintf("%v in %v", secret.Name, cluster))
+			}
+		}
+		if departing.installed {
+			for _, secret := range departing.readRemoteSecrets(env) {
+				remaining = append(remaining, fmt.Sprintf("%v in %v", secret.Name, departing))
+			}
+		}
+		if len(remaining) > 0 {
+			return false, nil
+		}
+
+		// The pilots drop the endpoints of a cluster once its remote secret is deleted.
+		for _, cluster := range append(mesh.SortedClusters(), departing) {
+			client, ok := clients[cluster.uid]
+			if !ok {
+				continue
+			}
+			endpoints, err := crossClusterEndpoints(client, cluster, from[cluster.uid])
+			if err != nil {
+				remaining = append(remaining, fmt.Sprintf("the endpoint shards of %v (%v)", cluster, err))
+				continue
+			}
+			remaining = append(remaining, endpoints...)
+		}
+		return len(remaining) == 0, nil
+	})
+	if err != nil {
+		return fmt.Errorf("cluster %v is still referenced by %v: %v", departing, remaining, err)
+	}
+	if len(remaining) > 0 {
+		return fmt.Errorf("cluster %v is still referenced by %v", departing, remaining)
+	}
+	env.Printf("Cluster %v removed from the mesh\n", departing)
+	return nil
+}
+
+type removeOptions struct {
+	KubeOptions
+	filenameOption
+	// cluster is the context of the cluster leaving the mesh.
+	cluster string
+	// verify waits until the cluster is no longer referenced by the mesh, and the pilots of the mesh no longer
+	// have its endpoints.
+	verify bool
+}
+
+func (o *removeOptions) prepare(flags *pflag.FlagSet) error {
+	o.KubeOptions.prepare(flags)
+	if err := o.filenameOption.prepare(); err != nil {
+		return err
+	}
+	if o.cluster == "" {
+		return errors.New("must specify --cluster")
+	}
+	return nil
+}
+
+func (o *removeOptions) addFlags(flags *pflag.FlagSet) {
+	o.filenameOption.addFlags(flags)
+	flags.StringVar(&o.cluster, "cluster", "",
+		"context of the cluster to remove from the mesh. The cluster may already be absent from the mesh description")
+	flags.BoolVar(&o.verify, "verify", false,
+		"wait until no cluster of the mesh references the removed cluster, and the removed cluster no other cluster. "+
+			"The pilots of the mesh must also have no endpoints of the removed cluster, and its pilots no endpoints of the mesh")
+}
+
+// departingCluster returns the cluster of the context, from the mesh if it is still part of its description.
+func departingCluster(mesh *Mesh, env Environment, context string) (*Cluster, error) {
+	if cluster, ok := mesh.ClusterByContext(context); ok {
+		return cluster, nil
+	}
+	return NewCluster(context, ClusterDesc{}, env)
+}
+
+// NewRemoveCommand creates a new command for removing a cluster from the mesh.
+func NewRemoveCommand() *cobra.Command {
+	opt := removeOptions{}
+	c := &cobra.Command{
+		Use:     "remove -f <mesh.yaml> --cluster <context>",
+		Aliases: []string{"unjoin"},
+		Short:   `Remove a cluster from a multi-cluster mesh`,
+		Long: `Remove a cluster from a multi-cluster mesh. The remote secrets referencing the cluster are deleted from the
+other clusters of the mesh, and the remote secrets of the cluster are deleted from it. Remove the cluster
+from the mesh description afterwards, or the next apply joins it again.`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := opt.prepare(c.Flags()); err != nil {
+				return err
+			}
+			env, err := NewEnvironmentFromCobra(opt.Kubeconfig, opt.Context, c)
+			if err != nil {
+				return err
+			}
+			mesh, err := meshFromFileDesc(opt.filename, env)
+			if err != nil {
+				return err
+			}
+			departing, err := departingCluster(mesh, env, opt.cluster)
+			if err != nil {
+				return fmt.Errorf("error discovering %v: %v", opt.cluster, err)
+			}
+			if err := remove(mesh, env, departing); err != nil {
+				return err
+			}
+			if opt.verify {
+				return verifyRemoved(mesh, env, opt.Kubeconfig, departing)
+			}
+			return nil
+		},
+	}
+	opt.addFlags(c.PersistentFlags())
+	return c
+}
@@ -0,0 +1,163 @@
+// Copyright 2019 Istio Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multicluster
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakePilotClient returns the endpoint shards of the pilots of a cluster.
+type fakePilotClient struct {
+	// shards are the endpoint shards of the pilots, by name.
+	shards map[string]string
+}
+
+func (c *fakePilotClient) AllPilotsDiscoveryDo(_, _, path string, _ []byte) (map[string][]byte, error) {
+	if path != "/debug/endpointShardz" {
+		return nil, fmt.Errorf("unexpected path %v", path)
+	}
+	out := make(map[string][]byte, len(c.shards))
+	for pilot, shards := range c.shards {
+		out[pilot] = []byte(shards)
+	}
+	return out, nil
+}
+
+// endpointShards returns the endpoint shards of a service with one endpoint per cluster.
+func endpointShards(hostname string, clusters ...*Cluster) string {
+	shards := make([]string, 0, len(clusters))
+	for _, c := range clusters {
+		shards = append(shards, fmt.Sprintf(`%q: [{"Address": "10.0.0.1"}]`, c.uid))
+	}
+	return fmt.Sprintf(`{%q: {"Shards": {%v}, "ServiceAccounts": {}}}`, hostname, strings.Join(shards, ", "))
+}
+
+// withFakePilots sets the pilot clients of the clusters, by context, until the returned function is called.
+func withFakePilots(pilots map[string]*fakePilotClient) func() {
+	saved := pilotClientFactory
+	pilotClientFactory = func(_, context string) (pilotClient, error) {
+		if c, ok := pilots[context]; ok {
+			return c, nil
+		}
+		return nil, fmt.Errorf("no pilot in %v", context)
+	}
+	return func() { pilotClientFactory = saved }
+}
+
+func TestRemove(t *testing.T) {
+	g := NewWithT(t)
+	defer withFakePilots(map[string]*fakePilotClient{
+		clusters[0].Context: {shards: map[string]string{"pilot-0": endpointShards("a.default.svc.cluster.local", clusters[0])}},
+		clusters[1].Context: {shards: map[string]string{"pilot-1": endpointShards("a.default.svc.cluster.local", clusters[1])}},
+		clusters[2].Context: {shards: map[string]string{"pilot-2": "{}"}},
+	})()
+
+	// a secret of the departing cluster created by hand, named after its context rather than its UID.
+	handmade := remoteSecretClusters[2].DeepCopy()
+	handmade.Name = "istio-remote-secret-" + clusters[2].Context
+
+	meshClusters := []*Cluster{cloneCluster(clusters[0]), cloneCluster(clusters[1]), cloneCluster(clusters[2])}
+	initObjs := map[types.UID][]runtime.Object{
+		clusters[0].uid: {remoteSecretClusters[1], remoteSecretClusters[2], pilotTokenSecrets[0]},
+		clusters[1].uid: {remoteSecretClusters[0], handmade, pilotTokenSecrets[1]},
+		clusters[2].uid: {remoteSecretClusters[0], remoteSecretClusters[1], pilotTokenSecrets[2]},
+	}
+	wantSecrets := map[types.UID][]*v1.Secret{
+		clusters[0].uid: {remoteSecretClusters[1], pilotTokenSecrets[0]},
+		clusters[1].uid: {remoteSecretClusters[0], pilotTokenSecrets[1]},
+		clusters[2].uid: {pilotTokenSecrets[2]},
+	}
+
+	env := newFakeEnvironmentOrDie(t, apiConfig)
+	mesh := NewMesh(&MeshDesc{MeshID: "MyMeshID"}, meshClusters...)
+	fakeClients := make(map[types.UID]*fake.Clientset, len(meshClusters))
+	for _, cluster := range meshClusters {
+		client := fake.NewSimpleClientset(initObjs[cluster.uid]...)
+		fakeClients[cluster.uid] = client
+		cluster.client = client
+	}
+
+	departing, err := departingCluster(mesh, env, clusters[2].Context)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(departing).To(Equal(meshClusters[2]))
+
+	g.Expect(remove(mesh, env, departing)).To(Succeed())
+	g.Expect(verifyRemoved(mesh, env, "", departing)).To(Succeed())
+
+	for _, cluster := range meshClusters {
+		t.Run(fmt.Sprintf("cluster %v", cluster.Context), func(tt *testing.T) {
+			secretList, err := fakeClients[cluster.uid].CoreV1().Secrets(cluster.Namespace).List(metav1.ListOptions{})
+			if err != nil {
+				tt.Fatal(err)
+			}
+			gotSecrets := make([]*v1.Secret, 0, len(secretList.Items))
+			for i := range secretList.Items {
+				gotSecrets = append(gotSecrets, &secretList.Items[i])
+			}
+			if diff := cmp.Diff(wantSecrets[cluster.uid], gotSecrets, cmpopts.SortSlices(lessSecret)); diff != "" {
+				tt.Errorf("wrong secrets: %v", diff)
+			}
+		})
+	}
+}
+
+func TestVerifyRemoved(t *testing.T) {
+	g := NewWithT(t)
+
+	meshClusters := []*Cluster{cloneCluster(clusters[0]), cloneCluster(clusters[1])}
+	meshClusters[0].client = fake.NewSimpleClientset(remoteSecretClusters[1])
+	meshClusters[1].client = fake.NewSimpleClientset()
+	mesh := NewMesh(&MeshDesc{MeshID: "MyMeshID"}, meshClusters...)
+	env := newFakeEnvironmentOrDie(t, apiConfig)
+	pilots := map[string]*fakePilotClient{
+		clusters[0].Context: {shards: map[string]string{"pilot-0": endpointShards("a.default.svc.cluster.local", clusters[0])}},
+		clusters[1].Context: {shards: map[string]string{"pilot-1": "{}"}},
+	}
+	defer withFakePilots(pilots)()
+
+	err := verifyRemoved(mesh, env, "", meshClusters[1])
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring(remoteSecretClusters[1].Name))
+
+	// the secret is removed, but the pilot still has the endpoints of the departing cluster.
+	meshClusters[0].client = fake.NewSimpleClientset()
+	pilots[clusters[0].Context].shards["pilot-0"] = endpointShards("a.default.svc.cluster.local", clusters[0], clusters[1])
+	err = verifyRemoved(mesh, env, "", meshClusters[1])
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring(
+		fmt.Sprintf("1 endpoints of a.default.svc.cluster.local from cluster %v in pilot-0", clusters[1].uid)))
+
+	// and the departing cluster still has the endpoints of the mesh.
+	pilots[clusters[0].Context].shards["pilot-0"] = endpointShards("a.default.svc.cluster.local", clusters[0])
+	pilots[clusters[1].Context].shards["pilot-1"] = endpointShards("a.default.svc.cluster.local", clusters[0], clusters[1])
+	err = verifyRemoved(mesh, env, "", meshClusters[1])
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring(
+		fmt.Sprintf("1 endpoints of a.default.svc.cluster.local from cluster %v in pilot-1", clusters[0].uid)))
+
+	pilots[clusters[1].Context].shards["pilot-1"] = endpointShards("a.default.svc.cluster.local", clusters[1])
+	g.Expect(verifyRemoved(mesh, env, "", meshClusters[1])).To(Succeed())
+}
@@ -0,0 +1,177 @@
+// Copyright 2019 Istio Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multicluster
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// default age of the credentials of a remote secret above which apply warns.
+const defaultMaxCredentialAge = 30 * 24 * time.Hour
+
+// credentialAge returns the age of the credentials of the remote secret. The creation time of the secret
+// is used if the time the credentials were generated was not recorded.
+func credentialAge(secret *v1.Secret, now time.Time) time.Duration {
+	created := secret.CreationTimestamp.Time
+	if value, ok := secret.Annotations[remoteSecretCreationTimeAnnotationKey]; ok {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			created = t
+		}
+	}
+	if created.IsZero() {
+		return 0
+	}
+	return now.Sub(created)
+}
+
+// agingRemoteSecrets returns a description of the remote secrets of the mesh whose credentials are older
+// than maxAge.
+func agingRemoteSecrets(mesh *Mesh, env Environment, maxAge time.Duration, now time.Time) []string {
+	var aging []string
+	for _, cluster := range mesh.SortedClusters() {
+		if !cluster.installed {
+			continue
+		}
+		existing := cluster.readRemoteSecrets(env)
+		for _, remote := range mesh.SortedClusters() {
+			secret, ok := existing[remote.uid]
+			if !ok {
+				continue
+			}
+			if age := credentialAge(secret, now); age > maxAge {
+				aging = append(aging, fmt.Sprintf("%v in cluster %v (%v old)", secret.Name, cluster, age.Round(time.Hour)))
+			}
+		}
+	}
+	return aging
+}
+
+func warnAgingRemoteSecrets(mesh *Mesh, env Environment, maxAge time.Duration) {
+	if maxAge <= 0 {
+		return
+	}
+	aging := agingRemoteSecrets(mesh, env, maxAge, time.Now())
+	if len(aging) == 0 {
+		return
+	}
+	env.Errorf("warning: the credentials of %v remote secret(s) are older than %v, "+
+		"consider rotating the service account tokens and running `istioctl x multicluster rotate`:\n", len(aging), maxAge)
+	for _, secret := range aging {
+		env.Errorf("  %v\n", secret)
+	}
+}
+
+// rotateMesh updates in place the kubeconfig of the remote secrets of the mesh which differ from the
+// desired remote secrets of the clusters by UID, and records the time of the rotation. The missing
+// remote secrets are left to apply.
+func rotateMesh(mesh *Mesh, env Environment, desired map[types.UID]*v1.Secret, now time.Time) error {
+	var errs *multierror.Error
+
+	sortedClusters := mesh.SortedClusters()
+	for _, cluster := range sortedClusters {
+		if !joinable(cluster) {
+			continue
+		}
+		existing := cluster.readRemoteSecrets(env)
+
+		for _, remote := range sortedClusters {
+			if remote.uid == cluster.uid {
+				continue
+			}
+			prev, ok := existing[remote.uid]
+			if !ok {
+				continue
+			}
+			curr, ok := desired[remote.uid]
+			if !ok {
+				env.Errorf("not rotating %v in cluster %v, the remote secret of cluster %v can't be generated\n",
+					prev.Name, cluster, remote)
+				continue
+			}
+			if bytes.Equal(prev.Data[string(remote.uid)], curr.Data[string(remote.uid)]) {
+				env.Printf("%v in cluster %v is up to date\n", prev.Name, cluster)
+				continue
+			}
+
+			updated := prev.DeepCopy()
+			updateRemoteSecret(updated, withCreationTime(curr, now))
+			if _, err := cluster.client.CoreV1().Secrets(cluster.Namespace).Update(updated); err != nil {
+				err := fmt.Errorf("failed to rotate %v in cluster %v: %v", prev.Name, cluster, err)
+				env.Errorf("%v\n", err)
+				errs = multierror.Append(errs, err)
+				continue
+			}
+			env.Printf("Rotated %v in cluster %v\n", prev.Name, cluster)
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// Rotate regenerates the kubeconfig of the remote secrets of the mesh from the current credentials of
+// the service accounts, and updates the remote secrets in place.
+func Rotate(opt rotateOptions, env Environment) error {
+	mesh, err := meshFromFileDesc(opt.filename, env)
+	if err != nil {
+		return err
+	}
+	return rotateMesh(mesh, env, desiredRemoteSecrets(mesh, env), time.Now())
+}
+
+type rotateOptions struct {
+	KubeOptions
+	filenameOption
+}
+
+func (o *rotateOptions) prepare(flags *pflag.FlagSet) error {
+	o.KubeOptions.prepare(flags)
+	return o.filenameOption.prepare()
+}
+
+func (o *rotateOptions) addFlags(flags *pflag.FlagSet) {
+	o.filenameOption.addFlags(flags)
+}
+
+// NewRotateCommand creates a new command for rotating the credentials of the remote secrets of the mesh.
+func NewRotateCommand() *cobra.Command {
+	opt := rotateOptions{}
+	c := &cobra.Command{
+		Use:   "rotate -f <mesh.yaml>",
+		Short: `Rotate the credentials of the remote secrets of a multi-cluster mesh`,
+		Long: `Regenerate the kubeconfig of the remote secrets of a multi-cluster mesh from the current token of the
+service account of each cluster, and update the remote secrets in place. Use it after the service account
+tokens expired or were rotated. The remote secrets which are missing are not created, use apply.`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := opt.prepare(c.Flags()); err != nil {
+				return err
+			}
+			env, err := NewEnvironmentFromCobra(opt.Kubeconfig, opt.Context, c)
+			if err != nil {
+				return err
+			}
+			return Rotate(opt, env)
+		},
+	}
+	opt.addFlags(c.PersistentFlags())
+	return c
+}
@@ -0,0 +1,122 @@
+// Copyright 2019 Istio Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multicluster
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCredentialAge(t *testing.T) {
+	now := time.Date(2019, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	annotated := remoteSecretClusters[0].DeepCopy()
+	annotated.CreationTimestamp = metav1.NewTime(now.Add(-48 * time.Hour))
+	annotated.Annotations[remoteSecretCreationTimeAnnotationKey] = now.Add(-time.Hour).Format(time.RFC3339)
+	unannotated := remoteSecretClusters[0].DeepCopy()
+	unannotated.CreationTimestamp = metav1.NewTime(now.Add(-48 * time.Hour))
+	invalid := annotated.DeepCopy()
+	invalid.Annotations[remoteSecretCreationTimeAnnotationKey] = "yesterday"
+
+	cases := []struct {
+		name   string
+		secret *v1.Secret
+		want   time.Duration
+	}{
+		{name: "annotated", secret: annotated, want: time.Hour},
+		{name: "creation timestamp", secret: unannotated, want: 48 * time.Hour},
+		{name: "invalid annotation", secret: invalid, want: 48 * time.Hour},
+		{name: "unknown", secret: remoteSecretClusters[0]},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := credentialAge(c.secret, now); got != c.want {
+				t.Errorf("credentialAge() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestAgingRemoteSecrets(t *testing.T) {
+	g := NewWithT(t)
+	now := time.Now()
+
+	old := withCreationTime(remoteSecretClusters[1], now.Add(-60*24*time.Hour))
+	recent := withCreationTime(remoteSecretClusters[0], now.Add(-time.Hour))
+
+	meshClusters := []*Cluster{cloneCluster(clusters[0]), cloneCluster(clusters[1])}
+	meshClusters[0].client = fake.NewSimpleClientset(old)
+	meshClusters[1].client = fake.NewSimpleClientset(recent)
+	mesh := NewMesh(&MeshDesc{MeshID: "MyMeshID"}, meshClusters...)
+	env := newFakeEnvironmentOrDie(t, apiConfig)
+
+	aging := agingRemoteSecrets(mesh, env, defaultMaxCredentialAge, now)
+	g.Expect(aging).To(HaveLen(1))
+	g.Expect(aging[0]).To(ContainSubstring(old.Name))
+	g.Expect(agingRemoteSecrets(mesh, env, 90*24*time.Hour, now)).To(BeEmpty())
+}
+
+func TestRotateMesh(t *testing.T) {
+	g := NewWithT(t)
+	now := time.Date(2019, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	_, staleKubeconfig := makeKubeconfig(clusters[0], []byte("expired-token"), caDatas[0])
+	stale := makeRemoteSecret(clusters[0], staleKubeconfig)
+
+	meshClusters := []*Cluster{cloneCluster(clusters[0]), cloneCluster(clusters[1]), cloneCluster(clusters[2])}
+	fakeClients := map[types.UID]*fake.Clientset{
+		clusters[0].uid: fake.NewSimpleClientset(remoteSecretClusters[2]),
+		clusters[1].uid: fake.NewSimpleClientset(stale, remoteSecretClusters[2]),
+		clusters[2].uid: fake.NewSimpleClientset(stale),
+	}
+	for _, cluster := range meshClusters {
+		cluster.client = fakeClients[cluster.uid]
+	}
+	mesh := NewMesh(&MeshDesc{MeshID: "MyMeshID"}, meshClusters...)
+	env := newFakeEnvironmentOrDie(t, apiConfig)
+	desired := map[types.UID]*v1.Secret{
+		clusters[0].uid: remoteSecretClusters[0],
+		clusters[1].uid: remoteSecretClusters[1],
+		clusters[2].uid: remoteSecretClusters[2],
+	}
+
+	g.Expect(rotateMesh(mesh, env, desired, now)).To(Succeed())
+
+	get := func(cluster *Cluster, name string) *v1.Secret {
+		secret, err := fakeClients[cluster.uid].CoreV1().Secrets(cluster.Namespace).Get(name, metav1.GetOptions{})
+		g.Expect(err).NotTo(HaveOccurred())
+		return secret
+	}
+	for _, cluster := range meshClusters[1:] {
+		rotated := get(cluster, stale.Name)
+		g.Expect(rotated.Data).To(Equal(remoteSecretClusters[0].Data))
+		g.Expect(rotated.Annotations).To(HaveKeyWithValue(remoteSecretCreationTimeAnnotationKey, now.Format(time.RFC3339)))
+	}
+
+	// the remote secrets which are up to date are left untouched, and the missing ones are not created.
+	for _, cluster := range meshClusters[:2] {
+		g.Expect(get(cluster, remoteSecretClusters[2].Name).Annotations).
+			NotTo(HaveKey(remoteSecretCreationTimeAnnotationKey))
+	}
+	_, err := fakeClients[clusters[0].uid].CoreV1().Secrets(clusters[0].Namespace).
+		Get(remoteSecretClusters[1].Name, metav1.GetOptions{})
+	g.Expect(err).To(HaveOccurred())
+}
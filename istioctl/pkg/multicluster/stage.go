@@ -0,0 +1,218 @@
+// Copyright 2019 Istio Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multicluster
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/spf13/pflag"
+	v1 "k8s.io/api/core/v1"
+)
+
+// stageOptions control how the changes are applied, one cluster at a time.
+type stageOptions struct {
+	// interactive asks for the confirmation of the changes of each cluster.
+	interactive bool
+	// stateFile records the changes applied, so that a stopped or failed run resumes.
+	stateFile string
+}
+
+func (o *stageOptions) addFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&o.interactive, "interactive", false,
+		"show the changes of each cluster and ask for a confirmation before applying them")
+	flags.StringVar(&o.stateFile, "stage", "",
+		"state file recording the clusters the changes were applied to. A run with the same state file skips "+
+			"the clusters whose changes were already applied, resuming a stopped or failed run")
+}
+
+// applyState is the state of a staged apply, persisted in the state file.
+type applyState struct {
+	// Applied are the digests of the changes applied, by cluster UID.
+	Applied map[string]string `json:"applied"`
+}
+
+func loadApplyState(env Environment, filename string) (*applyState, error) {
+	state := &applyState{Applied: map[string]string{}}
+	if filename == "" {
+		return state, nil
+	}
+	out, err := env.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read the state file %v: %v", filename, err)
+	}
+	if err := json.Unmarshal(out, state); err != nil {
+		return nil, fmt.Errorf("could not parse the state file %v: %v", filename, err)
+	}
+	if state.Applied == nil {
+		state.Applied = map[string]string{}
+	}
+	return state, nil
+}
+
+func (s *applyState) save(filename string) error {
+	if filename == "" {
+		return nil
+	}
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filename, out, 0644); err != nil {
+		return fmt.Errorf("could not write the state file %v: %v", filename, err)
+	}
+	return nil
+}
+
+// digest identifies the changes, so that they are applied again if they differ from the ones
+// recorded in the state file.
+func (c *clusterChanges) digest() string {
+	h := sha256.New()
+	writeSecret := func(op string, secret *v1.Secret) {
+		fmt.Fprintf(h, "%s %s/%s\n", op, secret.Namespace, secret.Name)
+		keys := make([]string, 0, len(secret.StringData)+len(secret.Data))
+		for k := range secret.StringData {
+			keys = append(keys, k)
+		}
+		for k := range secret.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(h, "%s=%s%s\n", k, secret.StringData[k], secret.Data[k])
+		}
+	}
+	for _, secret := range c.apply {
+		writeSecret("apply", secret)
+	}
+	for _, secret := range c.prune {
+		fmt.Fprintf(h, "prune %s/%s\n", secret.Namespace, secret.Name)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func (c *clusterChanges) print(env Environment) {
+	env.Printf("Changes of cluster %v:\n", c.cluster)
+	for _, secret := range c.apply {
+		env.Printf("  apply remote secret %v\n", secret.Name)
+	}
+	for _, secret := range c.prune {
+		env.Printf("  prune remote secret %v\n", secret.Name)
+	}
+}
+
+// Answers to the confirmation of the changes of a cluster.
+const (
+	answerApply = "apply"
+	answerSkip  = "skip"
+	answerStop  = "stop"
+)
+
+// confirm asks whether to apply the changes of the cluster, skip them, or stop. The run stops at
+// the end of the input.
+func confirm(env Environment, in *bufio.Reader, cluster *Cluster) string {
+	for {
+		env.Printf("Apply the changes of cluster %v? [y]es, [s]kip, [q]uit: ", cluster)
+		line, err := in.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return answerApply
+		case "s", "skip":
+			return answerSkip
+		case "q", "quit":
+			return answerStop
+		}
+		if err != nil {
+			env.Printf("\n")
+			return answerStop
+		}
+		env.Printf("Please answer y, s or q.\n")
+	}
+}
+
+// applyStaged computes the changes of all the clusters up front, then applies them with applyPlan.
+func applyStaged(mesh *Mesh, env Environment, opt stageOptions) error {
+	var errs *multierror.Error
+
+	plan, err := planApply(mesh, env)
+	if err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	if err := applyPlan(env, plan, opt); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// applyPlan applies the changes one cluster at a time, asking for a confirmation if interactive,
+// and recording the clusters applied to in the state file.
+func applyPlan(env Environment, plan []*clusterChanges, opt stageOptions) error {
+	var errs *multierror.Error
+
+	state, err := loadApplyState(env, opt.stateFile)
+	if err != nil {
+		return err
+	}
+
+	var in *bufio.Reader
+	if opt.interactive {
+		in = bufio.NewReader(env.Stdin())
+	}
+	for i, changes := range plan {
+		uid := string(changes.cluster.uid)
+		digest := changes.digest()
+		if state.Applied[uid] == digest {
+			env.Printf("Skipping cluster %v, its changes were applied by a previous run\n", changes.cluster)
+			continue
+		}
+
+		if opt.interactive {
+			changes.print(env)
+			switch confirm(env, in, changes.cluster) {
+			case answerSkip:
+				continue
+			case answerStop:
+				env.Printf("Stopped, the changes of %d cluster(s) were not applied\n", len(plan)-i)
+				return errs.ErrorOrNil()
+			}
+		}
+
+		complete, err := applyChanges(env, changes)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		if !complete || err != nil {
+			// The cluster is applied again when the run resumes.
+			continue
+		}
+		state.Applied[uid] = digest
+		if err := state.save(opt.stateFile); err != nil {
+			return multierror.Append(errs, err)
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
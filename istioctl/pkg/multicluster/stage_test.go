@@ -0,0 +1,100 @@
+// Copyright 2019 Istio Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multicluster
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func secretNames(t *testing.T, cluster *Cluster) []string {
+	t.Helper()
+	secrets, err := cluster.client.CoreV1().Secrets(cluster.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, secret := range secrets.Items {
+		names = append(names, secret.Name)
+	}
+	return names
+}
+
+func TestApplyPlan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	stateFile := filepath.Join(dir, "state.json")
+
+	local := [numFakeClusters]*Cluster{}
+	for i := range local {
+		local[i] = cloneCluster(clusters[i])
+	}
+	local[0].client = fake.NewSimpleClientset()
+	local[1].client = fake.NewSimpleClientset()
+	local[2].client = fake.NewSimpleClientset(remoteSecretClusters[0])
+	plan := []*clusterChanges{
+		{cluster: local[0], apply: []*v1.Secret{remoteSecretClusters[1]}},
+		{cluster: local[1], apply: []*v1.Secret{remoteSecretClusters[0]}},
+		{cluster: local[2], prune: []*v1.Secret{remoteSecretClusters[0]}},
+	}
+
+	// The changes of the first cluster are applied, the second cluster is skipped, and the run is stopped.
+	env := newFakeEnvironmentOrDie(t, apiConfig)
+	env.stdin = strings.NewReader("y\nmaybe\ns\nq\n")
+	if err := applyPlan(env, plan, stageOptions{interactive: true, stateFile: stateFile}); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range [][]string{{remoteSecretClusters[1].Name}, {}, {remoteSecretClusters[0].Name}} {
+		if got := secretNames(t, local[i]); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("got secrets %v in cluster %v after the interactive run, want %v", got, local[i], want)
+		}
+	}
+	out := env.Stdout().(interface{ String() string }).String()
+	if !strings.Contains(out, "Please answer y, s or q.") || !strings.Contains(out, "the changes of 1 cluster(s) were not applied") {
+		t.Errorf("unexpected output of the interactive run:\n%v", out)
+	}
+
+	// The run resumes from the state file, without applying the changes of the first cluster again.
+	actions := len(local[0].client.(*fake.Clientset).Actions())
+	env = newFakeEnvironmentOrDie(t, apiConfig)
+	if err := applyPlan(env, plan, stageOptions{stateFile: stateFile}); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(local[0].client.(*fake.Clientset).Actions()); got != actions {
+		t.Errorf("the changes of cluster %v were applied again", local[0])
+	}
+	for i, want := range [][]string{{remoteSecretClusters[1].Name}, {remoteSecretClusters[0].Name}, {}} {
+		if got := secretNames(t, local[i]); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("got secrets %v in cluster %v after the resumed run, want %v", got, local[i], want)
+		}
+	}
+	state, err := loadApplyState(env, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Applied) != numFakeClusters {
+		t.Errorf("got applied clusters %v in the state file, want all the clusters", state.Applied)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	v2 "istio.io/istio/pilot/pkg/proxy/envoy/v2"
 )
@@ -35,6 +36,71 @@ type writerStatus struct {
 	v2.SyncStatus
 }
 
+// ProxyStatus is the sync status of a proxy with a Pilot, as printed by PrintJSON.
+type ProxyStatus struct {
+	Proxy   string `json:"proxy"`
+	Pilot   string `json:"pilot"`
+	Version string `json:"version"`
+	CDS     string `json:"cds"`
+	LDS     string `json:"lds"`
+	EDS     string `json:"eds"`
+	RDS     string `json:"rds"`
+	// Synced is false if the proxy did not acknowledge the last response of any type sent by Pilot.
+	Synced bool `json:"synced"`
+	// LastPush and LastAck are the times of the last response sent to the proxy and of its last ACK,
+	// and SincePush and SinceAck the durations elapsed since. They are not reported by older Pilots.
+	LastPush  *time.Time `json:"last_push,omitempty"`
+	LastAck   *time.Time `json:"last_ack,omitempty"`
+	SincePush string     `json:"since_push,omitempty"`
+	SinceAck  string     `json:"since_ack,omitempty"`
+}
+
+// ProxyStatuses returns the sync status of the proxies from a slice of Pilot syncz responses, sorted by
+// proxy, and filtered for a specific pod if proxyName is not empty.
+func ProxyStatuses(statuses map[string][]byte, proxyName string) ([]ProxyStatus, error) {
+	return proxyStatuses(statuses, proxyName, time.Now())
+}
+
+func proxyStatuses(statuses map[string][]byte, proxyName string, now time.Time) ([]ProxyStatus, error) {
+	fullStatus, err := parseStatuses(statuses)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ProxyStatus, 0, len(fullStatus))
+	for _, status := range fullStatus {
+		if !strings.Contains(status.ProxyID, proxyName) {
+			continue
+		}
+		ps := ProxyStatus{
+			Proxy:   status.ProxyID,
+			Pilot:   status.pilot,
+			Version: statusVersion(status),
+			CDS:     truncatedStatus(xdsStatus(status.ClusterSent, status.ClusterAcked), status.TruncatedClusters),
+			LDS:     xdsStatus(status.ListenerSent, status.ListenerAcked),
+			EDS:     truncatedStatus(xdsStatus(status.EndpointSent, status.EndpointAcked), status.TruncatedEndpoints),
+			RDS:     truncatedStatus(xdsStatus(status.RouteSent, status.RouteAcked), status.TruncatedRoutes),
+			Synced: xdsSynced(status.ClusterSent, status.ClusterAcked) &&
+				xdsSynced(status.ListenerSent, status.ListenerAcked) &&
+				xdsSynced(status.EndpointSent, status.EndpointAcked) &&
+				xdsSynced(status.RouteSent, status.RouteAcked),
+		}
+		ps.LastPush, ps.SincePush = statusTime(status.LastSent, now)
+		ps.LastAck, ps.SinceAck = statusTime(status.LastAcked, now)
+		out = append(out, ps)
+	}
+	return out, nil
+}
+
+// PrintJSON outputs the sync status of the proxies as a JSON array.
+func (s *StatusWriter) PrintJSON(proxies []ProxyStatus) error {
+	out, err := json.MarshalIndent(proxies, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(s.Writer, string(out))
+	return err
+}
+
 // PrintAll takes a slice of Pilot syncz responses and outputs them using a tabwriter
 func (s *StatusWriter) PrintAll(statuses map[string][]byte) error {
 	w, fullStatus, err := s.setupStatusPrint(statuses)
@@ -66,14 +132,22 @@ func (s *StatusWriter) PrintSingle(statuses map[string][]byte, proxyName string)
 }
 
 func (s *StatusWriter) setupStatusPrint(statuses map[string][]byte) (*tabwriter.Writer, []*writerStatus, error) {
+	fullStatus, err := parseStatuses(statuses)
+	if err != nil {
+		return nil, nil, err
+	}
 	w := new(tabwriter.Writer).Init(s.Writer, 0, 8, 5, ' ', 0)
 	_, _ = fmt.Fprintln(w, "NAME\tCDS\tLDS\tEDS\tRDS\tPILOT\tVERSION")
+	return w, fullStatus, nil
+}
+
+func parseStatuses(statuses map[string][]byte) ([]*writerStatus, error) {
 	var fullStatus []*writerStatus
 	for pilot, status := range statuses {
 		var ss []*writerStatus
 		err := json.Unmarshal(status, &ss)
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 		for _, s := range ss {
 			s.pilot = pilot
@@ -83,24 +157,41 @@ func (s *StatusWriter) setupStatusPrint(statuses map[string][]byte) (*tabwriter.
 	sort.Slice(fullStatus, func(i, j int) bool {
 		return fullStatus[i].ProxyID < fullStatus[j].ProxyID
 	})
-	return w, fullStatus, nil
+	return fullStatus, nil
 }
 
 func statusPrintln(w io.Writer, status *writerStatus) error {
-	clusterSynced := xdsStatus(status.ClusterSent, status.ClusterAcked)
+	clusterSynced := truncatedStatus(xdsStatus(status.ClusterSent, status.ClusterAcked), status.TruncatedClusters)
 	listenerSynced := xdsStatus(status.ListenerSent, status.ListenerAcked)
-	routeSynced := xdsStatus(status.RouteSent, status.RouteAcked)
-	endpointSynced := xdsStatus(status.EndpointSent, status.EndpointAcked)
-	version := status.IstioVersion
-	if version == "" {
+	routeSynced := truncatedStatus(xdsStatus(status.RouteSent, status.RouteAcked), status.TruncatedRoutes)
+	endpointSynced := truncatedStatus(xdsStatus(status.EndpointSent, status.EndpointAcked), status.TruncatedEndpoints)
+	_, _ = fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+		status.ProxyID, clusterSynced, listenerSynced, endpointSynced, routeSynced, status.pilot, statusVersion(status))
+	return nil
+}
+
+func statusVersion(status *writerStatus) string {
+	if status.IstioVersion == "" {
 		// If we can't find an Istio version (talking to a 1.1 pilot), fallback to the proxy version
 		// This is misleading, as the proxy version isn't always the same as the Istio version,
 		// but it is better than not providing any information.
-		version = status.ProxyVersion + "*"
+		return status.ProxyVersion + "*"
 	}
-	_, _ = fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
-		status.ProxyID, clusterSynced, listenerSynced, endpointSynced, routeSynced, status.pilot, version)
-	return nil
+	return status.IstioVersion
+}
+
+// statusTime returns the time reported by Pilot and the duration elapsed since, or nothing if the time
+// is not reported.
+func statusTime(t time.Time, now time.Time) (*time.Time, string) {
+	if t.IsZero() {
+		return nil, ""
+	}
+	return &t, now.Sub(t).Round(time.Second).String()
+}
+
+// xdsSynced returns false if the last response sent to the proxy was not acknowledged.
+func xdsSynced(sent, acked string) bool {
+	return sent == "" || sent == acked
 }
 
 func xdsStatus(sent, acked string) string {
@@ -117,3 +208,12 @@ func xdsStatus(sent, acked string) string {
 	// Since the Nonce changes to uuid, so there is no more any time diff info
 	return "STALE"
 }
+
+// truncatedStatus appends the number of resources dropped by Pilot above the limits of the proxy, which
+// is then degraded, to the status.
+func truncatedStatus(status string, truncated int) string {
+	if truncated == 0 {
+		return status
+	}
+	return fmt.Sprintf("%s (%d TRUNCATED)", status, truncated)
+}
@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -127,6 +128,14 @@ func TestStatusWriter_PrintSingle(t *testing.T) {
 			filterPod: "proxy2",
 			want:      "testdata/singleStatusFallback.txt",
 		},
+		{
+			name: "degraded proxy with truncated resources",
+			input: map[string][]v2.SyncStatus{
+				"pilot2": statusInputTruncated(),
+			},
+			filterPod: "proxy2",
+			want:      "testdata/singleStatusTruncated.txt",
+		},
 		{
 			name: "error if given non-syncstatus info",
 			input: map[string][]v2.SyncStatus{
@@ -163,6 +172,85 @@ func TestStatusWriter_PrintSingle(t *testing.T) {
 	}
 }
 
+func TestProxyStatuses(t *testing.T) {
+	now := time.Date(2019, 12, 1, 0, 1, 0, 0, time.UTC)
+	sent := now.Add(-30 * time.Second)
+	acked := now.Add(-time.Minute)
+	synced := statusInput1()[0]
+	synced.ProxyID = "proxy3"
+	synced.ClusterAcked = synced.ClusterSent
+	synced.LastSent = sent
+	synced.LastAcked = sent
+	stale := statusInput2()[0]
+	stale.LastSent = sent
+	stale.LastAcked = acked
+
+	input := map[string][]byte{}
+	for key, ss := range map[string][]v2.SyncStatus{"pilot1": {synced}, "pilot2": {stale}} {
+		b, _ := json.Marshal(ss)
+		input[key] = b
+	}
+	want := []ProxyStatus{
+		{
+			Proxy:     "proxy2",
+			Pilot:     "pilot2",
+			Version:   "1.1",
+			CDS:       "STALE",
+			LDS:       "SYNCED",
+			EDS:       "STALE",
+			RDS:       "SYNCED",
+			Synced:    false,
+			LastPush:  &sent,
+			LastAck:   &acked,
+			SincePush: "30s",
+			SinceAck:  "1m0s",
+		},
+		{
+			Proxy:     "proxy3",
+			Pilot:     "pilot1",
+			Version:   "1.1",
+			CDS:       "SYNCED",
+			LDS:       "SYNCED",
+			EDS:       "SYNCED",
+			RDS:       "NOT SENT",
+			Synced:    true,
+			LastPush:  &sent,
+			LastAck:   &sent,
+			SincePush: "30s",
+			SinceAck:  "30s",
+		},
+	}
+	got, err := proxyStatuses(input, "", now)
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	got, err = proxyStatuses(input, "proxy3", now)
+	assert.NoError(t, err)
+	assert.Equal(t, want[1:], got)
+
+	// the times are not reported by older Pilots.
+	got, err = proxyStatuses(map[string][]byte{"pilot1": []byte(`[{"proxy":"proxy1"}]`)}, "", now)
+	assert.NoError(t, err)
+	assert.Equal(t, []ProxyStatus{{Proxy: "proxy1", Pilot: "pilot1", Version: "*", CDS: "NOT SENT", LDS: "NOT SENT",
+		EDS: "NOT SENT", RDS: "NOT SENT", Synced: true}}, got)
+
+	_, err = proxyStatuses(map[string][]byte{"pilot1": []byte(`gobbledygook`)}, "", now)
+	assert.Error(t, err)
+}
+
+func TestStatusWriter_PrintJSON(t *testing.T) {
+	got := &bytes.Buffer{}
+	sw := StatusWriter{Writer: got}
+	err := sw.PrintJSON([]ProxyStatus{{Proxy: "proxy1", Pilot: "pilot1", Version: "1.1", CDS: "SYNCED",
+		LDS: "SYNCED", EDS: "SYNCED", RDS: "STALE"}})
+	assert.NoError(t, err)
+
+	var out []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(got.Bytes(), &out))
+	assert.Equal(t, []map[string]interface{}{{"proxy": "proxy1", "pilot": "pilot1", "version": "1.1",
+		"cds": "SYNCED", "lds": "SYNCED", "eds": "SYNCED", "rds": "STALE", "synced": false}}, out)
+}
+
 func statusInput1() []v2.SyncStatus {
 	return []v2.SyncStatus{
 		{
@@ -228,3 +316,23 @@ func statusInputProxyVersion() []v2.SyncStatus {
 		},
 	}
 }
+
+func statusInputTruncated() []v2.SyncStatus {
+	return []v2.SyncStatus{
+		{
+			ProxyID:            "proxy2",
+			IstioVersion:       "1.1",
+			ClusterSent:        preDefinedNonce,
+			ClusterAcked:       preDefinedNonce,
+			ListenerSent:       preDefinedNonce,
+			ListenerAcked:      preDefinedNonce,
+			EndpointSent:       preDefinedNonce,
+			EndpointAcked:      preDefinedNonce,
+			RouteSent:          preDefinedNonce,
+			RouteAcked:         preDefinedNonce,
+			Degraded:           true,
+			TruncatedClusters:  20,
+			TruncatedEndpoints: 135,
+		},
+	}
+}
@@ -500,7 +500,7 @@ func mockValidateClientConfig(_ clientcmdapi.Config) error {
 	return nil
 }
 
-func mockCreateInterfaceFromClusterConfig(_ *clientcmdapi.Config) (kubernetes.Interface, error) {
+func mockCreateInterfaceFromClusterConfig(_ string, _ *clientcmdapi.Config) (kubernetes.Interface, error) {
 	return fake.NewSimpleClientset(), nil
 }
 
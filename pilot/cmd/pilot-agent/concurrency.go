@@ -0,0 +1,131 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"istio.io/pkg/env"
+	"istio.io/pkg/log"
+	"istio.io/pkg/monitoring"
+)
+
+const (
+	// concurrencyFromFlag is the source of the concurrency set by the concurrency flag.
+	concurrencyFromFlag = "flag"
+	// concurrencyFromCPULimit is the source of the concurrency derived from the CPU limit of the container.
+	concurrencyFromCPULimit = "cpu_limit"
+)
+
+var (
+	concurrencyAutoVar = env.RegisterBoolVar("PROXY_CONCURRENCY_AUTO", false,
+		"If enabled, the number of worker threads of Envoy is the CPU limit of the container rounded up, "+
+			"instead of the concurrency flag. The CPU limit is always used if the concurrency flag is 0.")
+	concurrencyMaxVar = env.RegisterIntVar("PROXY_CONCURRENCY_MAX", 0,
+		"The maximal number of worker threads of Envoy derived from the CPU limit of the container, "+
+			"unbounded if 0.")
+
+	concurrencySourceTag = monitoring.MustCreateLabel("source")
+	proxyConcurrency     = monitoring.NewGauge(
+		"istio_agent_proxy_concurrency",
+		"The number of worker threads of Envoy, 0 for one per core of the node.",
+		monitoring.WithLabels(concurrencySourceTag),
+	)
+
+	// cgroupRoot is where the cgroup file systems are mounted.
+	cgroupRoot = "/sys/fs/cgroup"
+)
+
+func init() {
+	monitoring.MustRegister(proxyConcurrency)
+}
+
+// tuneConcurrency returns the number of worker threads of Envoy and its source. Without the concurrency
+// flag, Envoy runs a worker thread per core of the node, regardless of the CPU limit of the container,
+// so the CPU limit is used instead if any. With auto, the CPU limit takes precedence over the flag.
+func tuneConcurrency(flag int, auto bool, max int) (int, string) {
+	if flag > 0 && !auto {
+		return flag, concurrencyFromFlag
+	}
+	limit, err := cgroupCPULimit(cgroupRoot)
+	if err != nil {
+		log.Warnf("unable to read the CPU limit of the container, using the concurrency flag %d: %v", flag, err)
+		return flag, concurrencyFromFlag
+	}
+	if limit <= 0 {
+		return flag, concurrencyFromFlag
+	}
+	concurrency := int(math.Ceil(limit))
+	if max > 0 && concurrency > max {
+		concurrency = max
+	}
+	return concurrency, concurrencyFromCPULimit
+}
+
+// cgroupCPULimit returns the CPU limit of the container in cores from the CFS quota of its cgroup, for
+// either the cgroup v2 or v1 hierarchy mounted at root, or 0 if it has no limit.
+func cgroupCPULimit(root string) (float64, error) {
+	// cgroup v2, e.g. "200000 100000", or "max 100000" without limit.
+	if data, err := ioutil.ReadFile(filepath.Join(root, "cpu.max")); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) != 2 {
+			return 0, fmt.Errorf("invalid cpu.max %q", string(data))
+		}
+		if fields[0] == "max" {
+			return 0, nil
+		}
+		return cpuQuota(fields[0], fields[1])
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	// cgroup v1, a quota of -1 without limit.
+	for _, dir := range []string{"cpu", "cpu,cpuacct"} {
+		quota, err := ioutil.ReadFile(filepath.Join(root, dir, "cpu.cfs_quota_us"))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		period, err := ioutil.ReadFile(filepath.Join(root, dir, "cpu.cfs_period_us"))
+		if err != nil {
+			return 0, err
+		}
+		return cpuQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+	}
+	return 0, nil
+}
+
+// cpuQuota returns the number of cores of the CFS quota and period in microseconds, 0 for a negative quota.
+func cpuQuota(quota, period string) (float64, error) {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid CFS quota %q: %v", quota, err)
+	}
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return 0, fmt.Errorf("invalid CFS period %q", period)
+	}
+	if q < 0 {
+		return 0, nil
+	}
+	return float64(q) / float64(p), nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeCgroupFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestCgroupCPULimit(t *testing.T) {
+	cases := []struct {
+		name    string
+		files   map[string]string
+		want    float64
+		wantErr bool
+	}{
+		{name: "v2", files: map[string]string{"cpu.max": "250000 100000\n"}, want: 2.5},
+		{name: "v2 without limit", files: map[string]string{"cpu.max": "max 100000\n"}},
+		{name: "v2 invalid", files: map[string]string{"cpu.max": "100000\n"}, wantErr: true},
+		{
+			name:  "v1",
+			files: map[string]string{"cpu/cpu.cfs_quota_us": "1600000\n", "cpu/cpu.cfs_period_us": "100000\n"},
+			want:  16,
+		},
+		{
+			name:  "v1 cpuacct",
+			files: map[string]string{"cpu,cpuacct/cpu.cfs_quota_us": "50000\n", "cpu,cpuacct/cpu.cfs_period_us": "100000\n"},
+			want:  0.5,
+		},
+		{
+			name:  "v1 without limit",
+			files: map[string]string{"cpu/cpu.cfs_quota_us": "-1\n", "cpu/cpu.cfs_period_us": "100000\n"},
+		},
+		{
+			name:    "v1 invalid period",
+			files:   map[string]string{"cpu/cpu.cfs_quota_us": "50000\n", "cpu/cpu.cfs_period_us": "0\n"},
+			wantErr: true,
+		},
+		{name: "no cgroup"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			root := writeCgroupFiles(t, c.files)
+			defer os.RemoveAll(root)
+			got, err := cgroupCPULimit(root)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("got error %v, want error %v", err, c.wantErr)
+			}
+			if got != c.want {
+				t.Errorf("got CPU limit %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestTuneConcurrency(t *testing.T) {
+	root := writeCgroupFiles(t, map[string]string{"cpu.max": "250000 100000\n"})
+	defer os.RemoveAll(root)
+	defer func(old string) { cgroupRoot = old }(cgroupRoot)
+	cgroupRoot = root
+
+	cases := []struct {
+		name       string
+		flag       int
+		auto       bool
+		max        int
+		want       int
+		wantSource string
+	}{
+		{name: "flag", flag: 2, want: 2, wantSource: concurrencyFromFlag},
+		{name: "no flag", want: 3, wantSource: concurrencyFromCPULimit},
+		{name: "auto", flag: 2, auto: true, want: 3, wantSource: concurrencyFromCPULimit},
+		{name: "auto with max", flag: 2, auto: true, max: 2, want: 2, wantSource: concurrencyFromCPULimit},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, source := tuneConcurrency(c.flag, c.auto, c.max)
+			if got != c.want || source != c.wantSource {
+				t.Errorf("got concurrency %d from %s, want %d from %s", got, source, c.want, c.wantSource)
+			}
+		})
+	}
+
+	cgroupRoot = filepath.Join(root, "missing")
+	if got, source := tuneConcurrency(0, true, 0); got != 0 || source != concurrencyFromFlag {
+		t.Errorf("without CPU limit got concurrency %d from %s, want the flag", got, source)
+	}
+}
@@ -53,6 +53,7 @@ import (
 	istio_agent "istio.io/istio/pkg/istio-agent"
 	"istio.io/istio/pkg/spiffe"
 	"istio.io/istio/pkg/util/gogoprotomarshal"
+	"istio.io/istio/pkg/wasm"
 )
 
 const trustworthyJWTPath = "/var/run/secrets/tokens/istio-token"
@@ -92,6 +93,13 @@ var (
 	proxyLogLevel            string
 	proxyComponentLogLevel   string
 	dnsRefreshRate           string
+	xdsInitialFetchTimeout   time.Duration
+	xdsDNSFailureRefreshRate time.Duration
+	xdsDNSFailureRefreshMax  time.Duration
+	xdsKeepaliveTime         time.Duration
+	xdsKeepaliveInterval     time.Duration
+	xdsKeepaliveProbes       uint32
+	xdsDrainOnHostRemoval    bool
 	concurrency              int
 	templateFile             string
 	disableInternalTelemetry bool
@@ -108,7 +116,15 @@ var (
 	sdsEnabledVar        = env.RegisterBoolVar("SDS_ENABLED", false, "")
 	autoMTLSEnabled      = env.RegisterBoolVar("ISTIO_AUTO_MTLS_ENABLED", false, "If true, auto mTLS is enabled, "+
 		"sidecar checks key/cert if SDS is not enabled.")
-	sdsUdsPathVar             = env.RegisterStringVar("SDS_UDS_PATH", "unix:/var/run/sds/uds_path", "SDS address")
+	sdsUdsPathVar         = env.RegisterStringVar("SDS_UDS_PATH", "unix:/var/run/sds/uds_path", "SDS address")
+	wasmModuleCacheDirVar = env.RegisterStringVar("WASM_MODULE_CACHE_DIR", "", "If set, the pilot agent fetches "+
+		"the remote Wasm modules served to Envoy on the status port, and caches them in this directory.")
+	wasmSigningKeyFileVar = env.RegisterStringVar("WASM_SIGNING_KEY_FILE", "", "The PEM encoded public key verifying "+
+		"the signatures of the remote Wasm modules, the signatures are not verified if not set.")
+	xdsSnapshotFileVar = env.RegisterStringVar("XDS_SNAPSHOT_FILE", "", "If set, the pilot agent persists the last "+
+		"acked xDS config of Envoy to this file, and bootstraps Envoy from it when the discovery address is unreachable at start.")
+	xdsSnapshotMaxBytesVar = env.RegisterIntVar("XDS_SNAPSHOT_MAX_BYTES", 10*1024*1024,
+		"The maximal size of the xDS config snapshot, larger configs are not persisted.")
 	stackdriverTracingEnabled = env.RegisterBoolVar("STACKDRIVER_TRACING_ENABLED", false, "If enabled, stackdriver will"+
 		" get configured as the tracer.")
 	stackdriverTracingDebug = env.RegisterBoolVar("STACKDRIVER_TRACING_DEBUG", false, "If set to true, "+
@@ -216,6 +232,10 @@ var (
 			proxyConfig.ParentShutdownDuration = types.DurationProto(parentShutdownDuration)
 			proxyConfig.DiscoveryAddress = discoveryAddress
 			proxyConfig.ConnectTimeout = types.DurationProto(connectTimeout)
+			if xdsDNSFailureRefreshMax > 0 && xdsDNSFailureRefreshMax <= xdsDNSFailureRefreshRate {
+				return fmt.Errorf("xdsDNSFailureRefreshMaxRate %v must be greater than xdsDNSFailureRefreshRate %v",
+					xdsDNSFailureRefreshMax, xdsDNSFailureRefreshRate)
+			}
 			proxyConfig.StatsdUdpAddress = statsdUDPAddress
 			if envoyMetricsService != "" {
 				if ms := fromJSON(envoyMetricsService); ms != nil {
@@ -230,7 +250,10 @@ var (
 				}
 			}
 			proxyConfig.ProxyAdminPort = int32(proxyAdminPort)
-			proxyConfig.Concurrency = int32(concurrency)
+			tunedConcurrency, source := tuneConcurrency(concurrency, concurrencyAutoVar.Get(), concurrencyMaxVar.Get())
+			log.Infof("Envoy concurrency: %d (source: %s)", tunedConcurrency, source)
+			proxyConcurrency.With(concurrencySourceTag.Value(source)).Record(float64(tunedConcurrency))
+			proxyConfig.Concurrency = int32(tunedConcurrency)
 
 			var pilotSAN []string
 			controlPlaneAuthEnabled := false
@@ -347,17 +370,19 @@ var (
 			// Main will be replaced by istio-agent when we clean up - this code can stay here and be removed with the rest.
 			sdsUDSPath := sdsUdsPathVar.Get()
 			sdsEnabled, sdsTokenPath := detectSds(controlPlaneBootstrap, sdsUDSPath, trustworthyJWTPath)
+			sdsInProcess := false
 
 			if !sdsEnabled && role.Type == model.SidecarProxy { // Not using citadel agent - this is either Pilot or Istiod.
 
 				// Istiod and new SDS-only mode doesn't use sdsUdsPathVar - sdsEnabled will be false.
 				sa := istio_agent.NewSDSAgent(discoveryAddress, controlPlaneAuthEnabled)
 
-				if sa.JWTPath != "" && role.Type == model.SidecarProxy {
-					// If user injected a JWT token for SDS - use SDS.
+				if (sa.JWTPath != "" || sa.FileMountedCerts) && role.Type == model.SidecarProxy {
+					// If user injected a JWT token for SDS, or the mounted certs are served over SDS - use SDS.
 					sdsEnabled = true
 					sdsTokenPath = sa.JWTPath
 					sdsUDSPath = sa.SDSAddress
+					sdsInProcess = true
 
 					if sa.RequireCerts {
 						controlPlaneAuthEnabled = true
@@ -394,6 +419,13 @@ var (
 				}
 			}
 
+			// The SDS server of the workload certificates is probed by the aggregated readiness of the
+			// status server.
+			workloadSDSUDSPath := ""
+			if sdsEnabled {
+				workloadSDSUDSPath = sdsUDSPath
+			}
+
 			// If control plane auth is not mTLS or global SDS flag is turned off, unset UDS path and token path
 			// for control plane SDS.
 			if !controlPlaneAuthEnabled || !sdsEnabled {
@@ -462,12 +494,19 @@ var (
 					localHostAddr = "[::1]"
 				}
 				prober := kubeAppProberNameVar.Get()
+				wasmCache, err := newWasmCache()
+				if err != nil {
+					cancel()
+					return err
+				}
 				statusServer, err := status.NewServer(status.Config{
 					LocalHostAddr:      localHostAddr,
 					AdminPort:          proxyAdminPort,
 					StatusPort:         statusPort,
 					KubeAppHTTPProbers: prober,
 					NodeType:           role.Type,
+					SDSUDSPath:         workloadSDSUDSPath,
+					WasmCache:          wasmCache,
 				})
 				if err != nil {
 					cancel()
@@ -494,6 +533,16 @@ var (
 				SDSTokenPath:        sdsTokenPath,
 				ControlPlaneAuth:    controlPlaneAuthEnabled,
 				DisableReportCalls:  disableInternalTelemetry,
+				SDSInProcess:        sdsInProcess,
+
+				XDSInitialFetchTimeout:      xdsInitialFetchTimeout,
+				XDSDNSFailureRefreshRate:    xdsDNSFailureRefreshRate,
+				XDSDNSFailureRefreshMaxRate: xdsDNSFailureRefreshMax,
+				XDSKeepaliveTime:            xdsKeepaliveTime,
+				XDSKeepaliveInterval:        xdsKeepaliveInterval,
+				XDSKeepaliveProbes:          xdsKeepaliveProbes,
+
+				XDSDrainConnectionsOnHostRemoval: xdsDrainOnHostRemoval,
 			})
 
 			agent := envoy.NewAgent(envoyProxy, features.TerminationDrainDuration())
@@ -502,8 +551,20 @@ var (
 				tlsCertsToWatch = []string{}
 			}
 
+			update := agent.Restart
+			if snapshotFile := xdsSnapshotFileVar.Get(); snapshotFile != "" && proxyConfig.CustomConfigFile == "" {
+				snapshot := envoy.NewConfigSnapshot(envoy.ConfigSnapshotOptions{
+					Path:             snapshotFile,
+					MaxBytes:         xdsSnapshotMaxBytesVar.Get(),
+					AdminPort:        uint32(proxyAdminPort),
+					DiscoveryAddress: proxyConfig.DiscoveryAddress,
+				}, agent.Restart)
+				update = snapshot.Update
+				go snapshot.Run(ctx)
+			}
+
 			// Watcher is also kicking envoy start.
-			watcher := envoy.NewWatcher(tlsCertsToWatch, agent.Restart)
+			watcher := envoy.NewWatcher(tlsCertsToWatch, update)
 			go watcher.Run(ctx)
 
 			// On SIGINT or SIGTERM, cancel the context, triggering a graceful shutdown
@@ -535,6 +596,25 @@ func waitForCompletion(ctx context.Context, fn func(context.Context)) {
 	wg.Done()
 }
 
+// newWasmCache creates the cache of the remote Wasm modules served to Envoy, if configured.
+func newWasmCache() (*wasm.LocalFileCache, error) {
+	dir := wasmModuleCacheDirVar.Get()
+	if dir == "" {
+		return nil, nil
+	}
+	options := wasm.Options{}
+	if keyFile := wasmSigningKeyFileVar.Get(); keyFile != "" {
+		pemKey, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Wasm signing key: %v", err)
+		}
+		if options.PublicKey, err = wasm.ParsePublicKey(pemKey); err != nil {
+			return nil, fmt.Errorf("failed to parse Wasm signing key %s: %v", keyFile, err)
+		}
+	}
+	return wasm.NewLocalFileCache(dir, options)
+}
+
 //explicitly setting the trustdomain so the pilot and mixer SAN will have same trustdomain
 //and the initialization of the spiffe pkg isn't linked to generating pilot's SAN first
 func setSpiffeTrustDomain(podNamespace string, domain string) {
@@ -732,6 +812,26 @@ func init() {
 		"The component log level used to start the Envoy proxy")
 	proxyCmd.PersistentFlags().StringVar(&dnsRefreshRate, "dnsRefreshRate", "300s",
 		"The dns_refresh_rate for bootstrap STRICT_DNS clusters")
+	proxyCmd.PersistentFlags().DurationVar(&xdsInitialFetchTimeout, "xdsInitialFetchTimeout", 0,
+		"Time Envoy waits for the initial listeners and clusters from the discovery service before "+
+			"starting without them, the Envoy default if 0")
+	proxyCmd.PersistentFlags().DurationVar(&xdsDNSFailureRefreshRate, "xdsDNSFailureRefreshRate", 0,
+		"Initial backoff between the DNS resolutions of the discovery address after a failure, doubling "+
+			"up to xdsDNSFailureRefreshMaxRate. The dnsRefreshRate is used if 0")
+	proxyCmd.PersistentFlags().DurationVar(&xdsDNSFailureRefreshMax, "xdsDNSFailureRefreshMaxRate", 0,
+		"Maximum backoff between the DNS resolutions of the discovery address after a failure, "+
+			"10 times xdsDNSFailureRefreshRate if 0, ignored without xdsDNSFailureRefreshRate")
+	proxyCmd.PersistentFlags().DurationVar(&xdsKeepaliveTime, "xdsKeepaliveTime", 0,
+		"Idle time of the discovery service connection before TCP keepalive probes are sent, "+
+			"so that NAT and load balancer timeouts do not silently drop it. 300s if 0")
+	proxyCmd.PersistentFlags().DurationVar(&xdsKeepaliveInterval, "xdsKeepaliveInterval", 0,
+		"Interval between the TCP keepalive probes of the discovery service connection, the OS default if 0")
+	proxyCmd.PersistentFlags().Uint32Var(&xdsKeepaliveProbes, "xdsKeepaliveProbes", 0,
+		"Number of unanswered TCP keepalive probes after which the discovery service connection is "+
+			"considered dead, the OS default if 0")
+	proxyCmd.PersistentFlags().BoolVar(&xdsDrainOnHostRemoval, "xdsDrainConnectionsOnHostRemoval", false,
+		"Close the discovery service connection once the discovery address no longer resolves to its "+
+			"host, so that Envoy reconnects after the control plane IP changed")
 	proxyCmd.PersistentFlags().IntVar(&concurrency, "concurrency", int(values.Concurrency),
 		"number of worker threads to run")
 	proxyCmd.PersistentFlags().StringVar(&templateFile, "templateFile", "",
@@ -0,0 +1,151 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/pkg/log"
+)
+
+const (
+	envoySource     = "envoy"
+	sdsSource       = "sds"
+	appSourcePrefix = "app/"
+
+	// The aggregated readiness fails with the code of the first source not ready, in this order, so
+	// that the probe tells which part of the pod is not ready.
+	envoyNotReadyCode = http.StatusServiceUnavailable
+	sdsNotReadyCode   = http.StatusBadGateway
+	appNotReadyCode   = http.StatusFailedDependency
+
+	sdsProbeTimeout = time.Second
+)
+
+// SourceReadiness is the readiness of one of the probe sources of the aggregated readiness.
+type SourceReadiness struct {
+	Name  string `json:"name"`
+	Ready bool   `json:"ready"`
+	Error string `json:"error,omitempty"`
+}
+
+// AggregatedReadiness is the JSON body of the aggregated readiness probe.
+type AggregatedReadiness struct {
+	Ready   bool              `json:"ready"`
+	Sources []SourceReadiness `json:"sources"`
+}
+
+// probeSource is a source of the aggregated readiness, and the HTTP code of the probe when it is
+// not ready.
+type probeSource struct {
+	name         string
+	notReadyCode int
+	check        func() error
+}
+
+// probeSources returns the sources of the aggregated readiness: Envoy, the SDS server if configured,
+// and the readiness probes of the application containers, which get the given headers.
+func (s *Server) probeSources(header http.Header) []probeSource {
+	sources := []probeSource{{name: envoySource, notReadyCode: envoyNotReadyCode, check: s.ready.Check}}
+	if s.sdsUDSPath != "" {
+		sources = append(sources, probeSource{
+			name:         sdsSource,
+			notReadyCode: sdsNotReadyCode,
+			check: func() error {
+				return checkSDS(s.sdsUDSPath)
+			},
+		})
+	}
+	var paths []string
+	for path := range s.appKubeProbers {
+		if strings.HasSuffix(path, "/readyz") {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		prober := s.appKubeProbers[path]
+		container := strings.TrimSuffix(strings.TrimPrefix(path, "/app-health/"), "/readyz")
+		sources = append(sources, probeSource{
+			name:         appSourcePrefix + container,
+			notReadyCode: appNotReadyCode,
+			check: func() error {
+				code, err := probeApp(prober, header)
+				if err != nil {
+					return err
+				}
+				// Same as the kubelet.
+				if code < http.StatusOK || code >= http.StatusBadRequest {
+					return fmt.Errorf("HTTP status %d", code)
+				}
+				return nil
+			},
+		})
+	}
+	return sources
+}
+
+// checkSDS checks that the SDS server accepts connections on its UDS address.
+func checkSDS(address string) error {
+	conn, err := net.DialTimeout("unix", strings.TrimPrefix(address, "unix:"), sdsProbeTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// aggregateReadiness checks the sources concurrently, and returns their readiness with the HTTP code
+// of the probe: 200 if all of them are ready, the code of the first source not ready otherwise.
+func aggregateReadiness(sources []probeSource) (AggregatedReadiness, int) {
+	states := make([]SourceReadiness, len(sources))
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func(i int, source probeSource) {
+			defer wg.Done()
+			states[i] = SourceReadiness{Name: source.name, Ready: true}
+			if err := source.check(); err != nil {
+				states[i] = SourceReadiness{Name: source.name, Error: err.Error()}
+			}
+		}(i, source)
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	for i, state := range states {
+		if !state.Ready {
+			code = sources[i].notReadyCode
+			break
+		}
+	}
+	return AggregatedReadiness{Ready: code == http.StatusOK, Sources: states}, code
+}
+
+func (s *Server) handleAggregatedReadyProbe(w http.ResponseWriter, req *http.Request) {
+	readiness, code := aggregateReadiness(s.probeSources(req.Header))
+	if !readiness.Ready {
+		log.Debugf("Pod is NOT ready: %v", readiness.Sources)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(readiness)
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAggregateReadiness(t *testing.T) {
+	ready := func() error { return nil }
+	notReady := func() error { return errors.New("not ready") }
+	envoy := func(check func() error) probeSource {
+		return probeSource{name: envoySource, notReadyCode: envoyNotReadyCode, check: check}
+	}
+	sds := func(check func() error) probeSource {
+		return probeSource{name: sdsSource, notReadyCode: sdsNotReadyCode, check: check}
+	}
+	app := func(check func() error) probeSource {
+		return probeSource{name: appSourcePrefix + "hello-world", notReadyCode: appNotReadyCode, check: check}
+	}
+
+	testCases := []struct {
+		name    string
+		sources []probeSource
+		code    int
+	}{
+		{"all ready", []probeSource{envoy(ready), sds(ready), app(ready)}, http.StatusOK},
+		{"envoy not ready", []probeSource{envoy(notReady), sds(notReady), app(notReady)}, envoyNotReadyCode},
+		{"sds not ready", []probeSource{envoy(ready), sds(notReady), app(notReady)}, sdsNotReadyCode},
+		{"app not ready", []probeSource{envoy(ready), sds(ready), app(notReady)}, appNotReadyCode},
+	}
+	for _, tc := range testCases {
+		readiness, code := aggregateReadiness(tc.sources)
+		if code != tc.code {
+			t.Errorf("%s: got code %d, want %d", tc.name, code, tc.code)
+		}
+		if readiness.Ready != (tc.code == http.StatusOK) {
+			t.Errorf("%s: got ready %v", tc.name, readiness.Ready)
+		}
+		if len(readiness.Sources) != len(tc.sources) {
+			t.Fatalf("%s: got sources %v", tc.name, readiness.Sources)
+		}
+		for i, source := range tc.sources {
+			state := readiness.Sources[i]
+			if state.Name != source.name || state.Ready != (source.check() == nil) {
+				t.Errorf("%s: got state %v for source %s", tc.name, state, source.name)
+			}
+		}
+	}
+}
+
+func TestProbeSources(t *testing.T) {
+	// The application is ready on /ready only.
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ready" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	appPort := listener.Addr().(*net.TCPAddr).Port
+
+	dir, err := ioutil.TempDir("", "sds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	udsPath := filepath.Join(dir, "uds_path")
+	sdsListener, err := net.Listen("unix", udsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sdsListener.Close()
+
+	server, err := NewServer(Config{
+		SDSUDSPath: "unix:" + udsPath,
+		KubeAppHTTPProbers: fmt.Sprintf(`{"/app-health/ready-app/readyz": {"path": "/ready", "port": %d},`+
+			`"/app-health/busy-app/readyz": {"path": "/busy", "port": %d},`+
+			`"/app-health/ready-app/livez": {"path": "/busy", "port": %d}}`, appPort, appPort, appPort),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sources := server.probeSources(nil)
+	var names []string
+	for _, source := range sources {
+		names = append(names, source.name)
+	}
+	want := []string{envoySource, sdsSource, appSourcePrefix + "busy-app", appSourcePrefix + "ready-app"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("got sources %v, want %v", names, want)
+	}
+	if err := sources[1].check(); err != nil {
+		t.Errorf("the SDS server should be ready: %v", err)
+	}
+	if err := sources[2].check(); err == nil {
+		t.Error("busy-app should not be ready")
+	}
+	if err := sources[3].check(); err != nil {
+		t.Errorf("ready-app should be ready: %v", err)
+	}
+
+	sdsListener.Close()
+	if err := sources[1].check(); err == nil {
+		t.Error("the SDS server should not be ready once closed")
+	}
+}
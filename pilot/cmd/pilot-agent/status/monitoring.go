@@ -0,0 +1,58 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"net/http"
+	"sync"
+
+	ocprom "contrib.go.opencensus.io/exporter/prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opencensus.io/stats/view"
+
+	"istio.io/pkg/log"
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	// startupDuration is the time the application containers are held for, when the injector holds
+	// them until the proxy is ready.
+	startupDuration = monitoring.NewGauge(
+		"istio_agent_startup_duration_seconds",
+		"The time from the start of the agent until the Envoy proxy was first ready.",
+	)
+
+	exporterOnce sync.Once
+	exporter     http.Handler
+)
+
+func init() {
+	monitoring.MustRegister(startupDuration)
+}
+
+// metricsHandler returns the handler serving the metrics of the agent in the Prometheus format, or
+// nil if the exporter can't be created.
+func metricsHandler() http.Handler {
+	exporterOnce.Do(func() {
+		e, err := ocprom.NewExporter(ocprom.Options{Registry: prometheus.NewRegistry()})
+		if err != nil {
+			log.Errorf("could not set up the prometheus exporter of the agent: %v", err)
+			return
+		}
+		view.RegisterExporter(e)
+		exporter = e
+	})
+	return exporter
+}
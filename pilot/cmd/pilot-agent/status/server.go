@@ -26,12 +26,14 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"istio.io/istio/pilot/pkg/model"
 
 	"istio.io/istio/pilot/cmd/pilot-agent/status/ready"
+	"istio.io/istio/pkg/wasm"
 	"istio.io/pkg/log"
 
 	corev1 "k8s.io/api/core/v1"
@@ -41,8 +43,17 @@ import (
 const (
 	// readyPath is for the pilot agent readiness itself.
 	readyPath = "/healthz/ready"
+	// aggregatedReadyPath is for the readiness of the pod as a whole: Envoy, the SDS server and the
+	// readiness probes of the application containers, with the state of each of them.
+	aggregatedReadyPath = "/healthz/ready/all"
+	// metricsPath serves the metrics of the pilot agent itself, the metrics of Envoy are served by
+	// Envoy on its own port.
+	metricsPath = "/metrics"
 	// quitPath is to notify the pilot agent to quit.
 	quitPath = "/quitquitquit"
+	// wasmPath serves the remote Wasm modules to Envoy, fetched by the pilot agent.
+	// For example, /wasm?url=https://example.com/filter.wasm&sha256=<checksum of the module>.
+	wasmPath = "/wasm"
 	// KubeAppProberEnvName is the name of the command line flag for pilot agent to pass app prober config.
 	// The json encoded string to pass app HTTP probe information from injector(istioctl or webhook).
 	// For example, ISTIO_KUBE_APP_PROBERS='{"/app-health/httpbin/livez":{"path": "/hello", "port": 8080}.
@@ -69,6 +80,11 @@ type Config struct {
 	NodeType           model.NodeType
 	StatusPort         uint16
 	AdminPort          uint16
+	// SDSUDSPath is the address of the SDS server of the workload certificates, e.g.
+	// unix:/var/run/sds/uds_path, probed by the aggregated readiness if set.
+	SDSUDSPath string
+	// WasmCache fetches the Wasm modules served to Envoy, which are not served if nil.
+	WasmCache *wasm.LocalFileCache
 }
 
 // Server provides an endpoint for handling status probes.
@@ -78,12 +94,23 @@ type Server struct {
 	appKubeProbers      KubeAppProbers
 	statusPort          uint16
 	lastProbeSuccessful bool
+	wasmCache           *wasm.LocalFileCache
+	sdsUDSPath          string
+	// start is the creation time of the server, used to record the startup duration when the
+	// proxy is first ready.
+	start time.Time
+	// wasEverReady is set to 1 by the first successful ready probe, which records the startup duration.
+	// It is swapped atomically as the probes are served concurrently.
+	wasEverReady int32
 }
 
 // NewServer creates a new status server.
 func NewServer(config Config) (*Server, error) {
 	s := &Server{
 		statusPort: config.StatusPort,
+		wasmCache:  config.WasmCache,
+		sdsUDSPath: config.SDSUDSPath,
+		start:      time.Now(),
 		ready: &ready.Probe{
 			LocalHostAddr: config.LocalHostAddr,
 			AdminPort:     config.AdminPort,
@@ -123,8 +150,15 @@ func (s *Server) Run(ctx context.Context) {
 
 	// Add the handler for ready probes.
 	mux.HandleFunc(readyPath, s.handleReadyProbe)
+	mux.HandleFunc(aggregatedReadyPath, s.handleAggregatedReadyProbe)
 	mux.HandleFunc(quitPath, s.handleQuit)
+	if h := metricsHandler(); h != nil {
+		mux.Handle(metricsPath, h)
+	}
 	mux.HandleFunc("/app-health/", s.handleAppProbe)
+	if s.wasmCache != nil {
+		mux.HandleFunc(wasmPath, s.handleWasm)
+	}
 
 	l, err := net.Listen("tcp", fmt.Sprintf(":%d", s.statusPort))
 	if err != nil {
@@ -173,6 +207,10 @@ func (s *Server) handleReadyProbe(w http.ResponseWriter, _ *http.Request) {
 		s.lastProbeSuccessful = true
 	}
 	s.mutex.Unlock()
+
+	if err == nil && atomic.CompareAndSwapInt32(&s.wasEverReady, 0, 1) {
+		startupDuration.Record(time.Since(s.start).Seconds())
+	}
 }
 
 func isRequestFromLocalhost(r *http.Request) bool {
@@ -200,6 +238,26 @@ func (s *Server) handleQuit(w http.ResponseWriter, r *http.Request) {
 	notifyExit()
 }
 
+func (s *Server) handleWasm(w http.ResponseWriter, r *http.Request) {
+	if !isRequestFromLocalhost(r) {
+		http.Error(w, "Only requests from localhost are allowed", http.StatusForbidden)
+		return
+	}
+	url, checksum := r.URL.Query().Get("url"), r.URL.Query().Get("sha256")
+	if url == "" || checksum == "" {
+		http.Error(w, "url and sha256 query parameters are required", http.StatusBadRequest)
+		return
+	}
+	path, err := s.wasmCache.Get(url, checksum)
+	if err != nil {
+		log.Errorf("Failed to fetch Wasm module: %v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/wasm")
+	http.ServeFile(w, r, path)
+}
+
 func (s *Server) handleAppProbe(w http.ResponseWriter, req *http.Request) {
 	// Validate the request first.
 	path := req.URL.Path
@@ -214,6 +272,20 @@ func (s *Server) handleAppProbe(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	code, err := probeApp(prober, req.Header)
+	if err != nil {
+		log.Errorf("Request to probe app failed: %v, original URL path = %v\napp URL path = %v", err, path, prober.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// We only write the status code to the response.
+	w.WriteHeader(code)
+}
+
+// probeApp sends the HTTP probe to the application, forwarding the given headers, and returns the
+// status code of the application.
+func probeApp(prober *corev1.HTTPGetAction, header http.Header) (int, error) {
 	// Construct a request sent to the application.
 	httpClient := &http.Client{
 		// TODO: figure out the appropriate timeout?
@@ -232,13 +304,11 @@ func (s *Server) handleAppProbe(w http.ResponseWriter, req *http.Request) {
 	}
 	appReq, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		log.Errorf("Failed to create request to probe app %v, original url %v", err, path)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return 0, fmt.Errorf("failed to create the request: %v", err)
 	}
 
 	// Forward incoming headers to the application.
-	for name, values := range req.Header {
+	for name, values := range header {
 		newValues := make([]string, len(values))
 		copy(newValues, values)
 		appReq.Header[name] = newValues
@@ -247,14 +317,10 @@ func (s *Server) handleAppProbe(w http.ResponseWriter, req *http.Request) {
 	// Send the request.
 	response, err := httpClient.Do(appReq)
 	if err != nil {
-		log.Errorf("Request to probe app failed: %v, original URL path = %v\napp URL path = %v", err, path, prober.Path)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return 0, err
 	}
 	defer response.Body.Close()
-
-	// We only write the status code to the response.
-	w.WriteHeader(response.StatusCode)
+	return response.StatusCode, nil
 }
 
 // notifyExit sends SIGTERM to itself
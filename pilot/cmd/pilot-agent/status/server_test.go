@@ -15,8 +15,12 @@
 package status
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -30,6 +34,7 @@ import (
 	"istio.io/istio/pkg/test/util/retry"
 
 	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/pkg/wasm"
 )
 
 type handler struct{}
@@ -303,3 +308,79 @@ func TestHandleQuit(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleWasm(t *testing.T) {
+	module := []byte("\x00asm\x01\x00\x00\x00")
+	sum := sha256.Sum256(module)
+	checksum := hex.EncodeToString(sum[:])
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(module)
+	}))
+	defer remote.Close()
+
+	dir, err := ioutil.TempDir("", "wasm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cache, err := wasm.NewLocalFileCache(dir, wasm.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServer(Config{StatusPort: 15020, WasmCache: cache})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		remoteAddr string
+		expected   int
+	}{
+		{
+			name:       "should serve the module",
+			query:      "url=" + remote.URL + "&sha256=" + checksum,
+			remoteAddr: "127.0.0.1",
+			expected:   http.StatusOK,
+		},
+		{
+			name:       "should require the checksum",
+			query:      "url=" + remote.URL,
+			remoteAddr: "127.0.0.1",
+			expected:   http.StatusBadRequest,
+		},
+		{
+			name:       "should fail on checksum mismatch",
+			query:      "url=" + remote.URL + "&sha256=" + strings.Repeat("0", 64),
+			remoteAddr: "127.0.0.1",
+			expected:   http.StatusBadGateway,
+		},
+		{
+			name:     "should require localhost",
+			query:    "url=" + remote.URL + "&sha256=" + checksum,
+			expected: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/wasm?"+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr + ":15020"
+			}
+
+			resp := httptest.NewRecorder()
+			s.handleWasm(resp, req)
+			if resp.Code != tt.expected {
+				t.Fatalf("Expected response code %v got %v", tt.expected, resp.Code)
+			}
+			if tt.expected == http.StatusOK && !bytes.Equal(resp.Body.Bytes(), module) {
+				t.Errorf("Expected the module, got %q", resp.Body.Bytes())
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/pkg/log"
+)
+
+var (
+	waitTimeoutSeconds int
+	waitPeriodMillis   int
+	waitURL            string
+
+	// waitCmd is run as the postStart hook of the istio-proxy container, injected first in the pod,
+	// so that Kubernetes doesn't start the application containers until the proxy is ready.
+	waitCmd = &cobra.Command{
+		Use:   "wait",
+		Short: "Waits until the Envoy proxy is ready",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			timeout := time.Duration(waitTimeoutSeconds) * time.Second
+			period := time.Duration(waitPeriodMillis) * time.Millisecond
+			log.Infof("Waiting %v for the Envoy proxy to be ready (checking %s)", timeout, waitURL)
+			start := time.Now()
+			if err := waitForReady(waitURL, timeout, period); err != nil {
+				return err
+			}
+			log.Infof("Envoy proxy is ready after %v", time.Since(start))
+			return nil
+		},
+	}
+)
+
+// waitForReady polls the readiness URL of the agent until it returns 200, or the timeout expires.
+func waitForReady(url string, timeout, period time.Duration) error {
+	client := &http.Client{Timeout: period}
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+	for {
+		resp, err := client.Get(url)
+		if err == nil {
+			_ = resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+			err = fmt.Errorf("HTTP status %d", resp.StatusCode)
+		}
+		lastErr = err
+		if time.Now().Add(period).After(deadline) {
+			return fmt.Errorf("timeout waiting for the Envoy proxy to be ready after %v (last error: %v)", timeout, lastErr)
+		}
+		time.Sleep(period)
+	}
+}
+
+func init() {
+	waitCmd.PersistentFlags().IntVar(&waitTimeoutSeconds, "timeoutSeconds", 60,
+		"maximum number of seconds to wait for the Envoy proxy to be ready")
+	waitCmd.PersistentFlags().IntVar(&waitPeriodMillis, "periodMillis", 500,
+		"number of milliseconds to wait between attempts")
+	waitCmd.PersistentFlags().StringVar(&waitURL, "url", "http://localhost:15020/healthz/ready",
+		"URL to use in requests")
+
+	rootCmd.AddCommand(waitCmd)
+}
@@ -16,6 +16,7 @@ package bootstrap
 
 import (
 	"fmt"
+	"io/ioutil"
 	"reflect"
 	"time"
 
@@ -26,6 +27,7 @@ import (
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pilot/cmd"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
 	kubecontroller "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
@@ -54,6 +56,7 @@ func (s *Server) initMeshConfiguration(args *PilotArgs) error {
 	// If a config file was specified, use it.
 	if args.MeshConfig != nil {
 		s.mesh = args.MeshConfig
+		s.endpointTTL = features.EndpointTTL
 		return nil
 	}
 	var meshConfig *meshconfig.MeshConfig
@@ -63,6 +66,8 @@ func (s *Server) initMeshConfiguration(args *PilotArgs) error {
 		meshConfig, err = cmd.ReadMeshConfig(args.Mesh.ConfigFile)
 		if err != nil {
 			log.Warnf("failed to read mesh configuration, using default: %v", err)
+		} else {
+			s.endpointTTL = readEndpointTTL(args.Mesh.ConfigFile)
 		}
 
 		// Watch the config file for changes and reload if it got modified
@@ -73,6 +78,13 @@ func (s *Server) initMeshConfiguration(args *PilotArgs) error {
 				log.Warnf("failed to read mesh configuration, using default: %v", err)
 				return
 			}
+			if ttl := readEndpointTTL(args.Mesh.ConfigFile); ttl != s.endpointTTL {
+				log.Infof("mesh configuration endpoint TTL updated to: %v", ttl)
+				s.endpointTTL = ttl
+				if s.EnvoyXdsServer != nil {
+					s.EnvoyXdsServer.SetEndpointTTL(ttl)
+				}
+			}
 			if !reflect.DeepEqual(meshConfig, s.mesh) {
 				log.Infof("mesh configuration updated to: %s", spew.Sdump(meshConfig))
 				if !reflect.DeepEqual(meshConfig.ConfigSources, s.mesh.ConfigSources) {
@@ -90,7 +102,8 @@ func (s *Server) initMeshConfiguration(args *PilotArgs) error {
 
 	if meshConfig == nil {
 		// Config file either wasn't specified or failed to load - use a default mesh.
-		if meshConfig, err = getMeshConfig(s.kubeClient, kubecontroller.IstioNamespace, kubecontroller.IstioConfigMap); err != nil {
+		if meshConfig, s.endpointTTL, err = getMeshConfig(s.kubeClient, kubecontroller.IstioNamespace,
+			kubecontroller.IstioConfigMap); err != nil {
 			log.Warnf("failed to read the default mesh configuration: %v, from the %s config map in the %s namespace",
 				err, kubecontroller.IstioConfigMap, kubecontroller.IstioNamespace)
 			return err
@@ -143,7 +156,7 @@ func (s *Server) initMeshNetworks(args *PilotArgs) error { //nolint: unparam
 			log.Infof("mesh networks configuration post-resolution %s", spew.Sdump(meshNetworks))
 			s.meshNetworks = meshNetworks
 			if s.kubeRegistry != nil {
-				s.kubeRegistry.InitNetworkLookup(meshNetworks)
+				s.kubeRegistry.ReloadNetworkLookup(meshNetworks)
 			}
 			if s.multicluster != nil {
 				s.multicluster.ReloadNetworkLookup(meshNetworks)
@@ -181,33 +194,56 @@ func (s *Server) addFileWatcher(file string, callback func()) {
 	}()
 }
 
-// getMeshConfig fetches the ProxyMesh configuration from Kubernetes ConfigMap.
-func getMeshConfig(kube kubernetes.Interface, namespace, name string) (*meshconfig.MeshConfig, error) {
+// getMeshConfig fetches the ProxyMesh configuration from Kubernetes ConfigMap, with its endpoint TTL.
+func getMeshConfig(kube kubernetes.Interface, namespace, name string) (*meshconfig.MeshConfig, time.Duration, error) {
 
 	if kube == nil {
 		defaultMesh := mesh.DefaultMeshConfig()
-		return &defaultMesh, nil
+		return &defaultMesh, features.EndpointTTL, nil
 	}
 
 	cfg, err := kube.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
 	if err != nil {
 		if errors.IsNotFound(err) {
 			defaultMesh := mesh.DefaultMeshConfig()
-			return &defaultMesh, nil
+			return &defaultMesh, features.EndpointTTL, nil
 		}
-		return nil, err
+		return nil, 0, err
 	}
 
 	// values in the data are strings, while proto might use a different data type.
 	// therefore, we have to get a value by a key
 	cfgYaml, exists := cfg.Data[configMapKey]
 	if !exists {
-		return nil, fmt.Errorf("missing configuration map key %q", configMapKey)
+		return nil, 0, fmt.Errorf("missing configuration map key %q", configMapKey)
 	}
 
 	meshConfig, err := mesh.ApplyMeshConfigDefaults(cfgYaml)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	return meshConfig, endpointTTL(cfgYaml), nil
+}
+
+// readEndpointTTL returns the endpoint TTL of the mesh configuration file.
+func readEndpointTTL(filename string) time.Duration {
+	yml, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.Warnf("failed to read the endpoint TTL of the mesh configuration, using PILOT_ENDPOINT_TTL: %v", err)
+		return features.EndpointTTL
+	}
+	return endpointTTL(string(yml))
+}
+
+// endpointTTL returns the endpointTtl of the mesh configuration, or PILOT_ENDPOINT_TTL if it is not set.
+func endpointTTL(yml string) time.Duration {
+	ttl, set, err := mesh.EndpointTTL(yml)
+	if err != nil {
+		log.Warnf("failed to read the endpoint TTL of the mesh configuration, using PILOT_ENDPOINT_TTL: %v", err)
+		return features.EndpointTTL
+	}
+	if !set {
+		return features.EndpointTTL
 	}
-	return meshConfig, nil
+	return ttl
 }
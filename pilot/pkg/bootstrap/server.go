@@ -35,6 +35,7 @@ import (
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
 
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 
@@ -108,6 +109,7 @@ type Server struct {
 
 	mesh             *meshconfig.MeshConfig
 	meshNetworks     *meshconfig.MeshNetworks
+	endpointTTL      time.Duration
 	configController model.ConfigStoreCache
 
 	kubeClient            kubernetes.Interface
@@ -217,7 +219,9 @@ func (s *Server) initKubeClient(args *PilotArgs) error {
 			return multierror.Prefix(kuberr, "failed to connect to Kubernetes API.")
 		}
 		s.kubeClient = client
-
+		// The informers are shared by the kube registry and the Ingress controller.
+		args.Config.ControllerOptions.InformerFactory = informers.NewSharedInformerFactoryWithOptions(client,
+			args.Config.ControllerOptions.ResyncPeriod, informers.WithNamespace(args.Config.ControllerOptions.WatchedNamespace))
 	}
 
 	return nil
@@ -233,6 +237,7 @@ func (s *Server) initDiscoveryService(args *PilotArgs) error {
 	}
 
 	s.EnvoyXdsServer = envoyv2.NewDiscoveryServer(environment, args.Plugins)
+	s.EnvoyXdsServer.SetEndpointTTL(s.endpointTTL)
 	s.mux = http.NewServeMux()
 	s.EnvoyXdsServer.InitDebug(s.mux, s.ServiceController, args.DiscoveryOptions.EnableProfiling)
 
@@ -80,7 +80,8 @@ func NewMulticluster(kc kubernetes.Interface, secretNamespace string,
 // AddMemberCluster is passed to the secret controller as a callback to be called
 // when a remote cluster is added.  This function needs to set up all the handlers
 // to watch for resources being added, deleted or changed on remote clusters.
-func (m *Multicluster) AddMemberCluster(clientset kubernetes.Interface, clusterID string) error {
+// A non empty network overrides the network of the cluster in the mesh networks.
+func (m *Multicluster) AddMemberCluster(clientset kubernetes.Interface, clusterID string, network string) error {
 	// stopCh to stop controller created here when cluster removed.
 	stopCh := make(chan struct{})
 	var remoteKubeController kubeController
@@ -92,6 +93,7 @@ func (m *Multicluster) AddMemberCluster(clientset kubernetes.Interface, clusterI
 		DomainSuffix:     m.DomainSuffix,
 		XDSUpdater:       m.XDSUpdater,
 		ClusterID:        clusterID,
+		Network:          network,
 	})
 	kubectl.InitNetworkLookup(m.meshNetworks)
 
@@ -142,7 +144,7 @@ func (m *Multicluster) ReloadNetworkLookup(meshNetworks *meshconfig.MeshNetworks
 	m.meshNetworks = meshNetworks
 	for _, controller := range m.remoteKubeControllers {
 		if controller != nil && controller.rc != nil {
-			controller.rc.InitNetworkLookup(meshNetworks)
+			controller.rc.ReloadNetworkLookup(meshNetworks)
 		}
 	}
 }
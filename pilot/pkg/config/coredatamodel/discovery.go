@@ -499,13 +499,13 @@ func (d *MCPDiscovery) GetProxyWorkloadLabels(*model.Proxy) (labels.Collection,
 // model Controller
 
 // AppendServiceHandler Not Supported
-func (d *MCPDiscovery) AppendServiceHandler(f func(*model.Service, model.Event)) error {
+func (d *MCPDiscovery) AppendServiceHandler(f func(*model.Service, model.Event), filters ...model.HandlerFilter) error {
 	log.Warnf("AppendServiceHandler %s", errUnsupported)
 	return nil
 }
 
 // AppendInstanceHandler Not Supported
-func (d *MCPDiscovery) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event)) error {
+func (d *MCPDiscovery) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event), filters ...model.HandlerFilter) error {
 	log.Warnf("AppendInstanceHandler %s", errUnsupported)
 	return nil
 }
@@ -0,0 +1,292 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingress
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"time"
+
+	"k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/pkg/log"
+
+	"istio.io/istio/pilot/pkg/serviceregistry/kube"
+)
+
+const (
+	// IstioIngressController is the controller of the IngressClasses whose Ingresses are processed by
+	// Istio, whatever the IngressClass mesh config.
+	IstioIngressController = "istio.io/ingress-controller"
+
+	// defaultIngressClassAnnotation marks the IngressClass of the Ingresses without class.
+	defaultIngressClassAnnotation = "ingressclass.kubernetes.io/is-default-class"
+
+	ingressClassGroupVersion = "networking.k8s.io/v1beta1"
+)
+
+// ingressClassResyncPeriod is the period the IngressClasses and the class names of the Ingresses are
+// listed at. They are not watched, the Kubernetes API of Pilot predating them, so the class name of a new
+// Ingress is only known after up to a period, the ingress class annotation taking effect immediately.
+const ingressClassResyncPeriod = 30 * time.Second
+
+// ingressClass is the part of the IngressClass resource used by the controller.
+type ingressClass struct {
+	Metadata struct {
+		Name        string            `json:"name"`
+		Annotations map[string]string `json:"annotations,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		Controller string `json:"controller"`
+	} `json:"spec"`
+}
+
+type ingressClassList struct {
+	Items []ingressClass `json:"items"`
+}
+
+// ingressWithClass is the part of the networking.k8s.io/v1beta1 Ingress resource with its class, which
+// the extensions/v1beta1 Ingress of the informers doesn't have.
+type ingressWithClass struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Spec struct {
+		IngressClassName string `json:"ingressClassName,omitempty"`
+	} `json:"spec"`
+}
+
+type ingressWithClassList struct {
+	Items []ingressWithClass `json:"items"`
+}
+
+// ingressClasses are the controllers of the IngressClasses of the cluster, so that Istio only processes
+// the Ingresses of its classes when other ingress controllers run in the cluster. A nil ingressClasses
+// has no class, for clusters without the IngressClass API.
+type ingressClasses struct {
+	mu sync.RWMutex
+	// controllers are the controllers of the classes, by class name.
+	controllers map[string]string
+	// defaultClass is the class of the Ingresses without class, empty if none.
+	defaultClass string
+	// classNames are the spec.ingressClassName of the Ingresses, by namespace/name.
+	classNames map[string]string
+	synced     bool
+
+	// handlers are called when the classes change.
+	handlers []func()
+	runOnce  sync.Once
+}
+
+func newIngressClasses() *ingressClasses {
+	return &ingressClasses{controllers: map[string]string{}, classNames: map[string]string{}}
+}
+
+var (
+	sharedIngressClassesMutex sync.Mutex
+	sharedIngressClasses      = map[kubernetes.Interface]map[string]*ingressClasses{}
+)
+
+// ingressClassesFor returns the classes of the cluster of the client, for the Ingresses of the namespace,
+// shared by the controller and the StatusSyncer so that the IngressClasses are listed once.
+func ingressClassesFor(client kubernetes.Interface, namespace string) *ingressClasses {
+	sharedIngressClassesMutex.Lock()
+	defer sharedIngressClassesMutex.Unlock()
+	byNamespace, f := sharedIngressClasses[client]
+	if !f {
+		byNamespace = map[string]*ingressClasses{}
+		sharedIngressClasses[client] = byNamespace
+	}
+	classes, f := byNamespace[namespace]
+	if !f {
+		classes = newIngressClasses()
+		byNamespace[namespace] = classes
+	}
+	return classes
+}
+
+// className returns the class of the Ingress, from the ingress class annotation or else from its
+// spec.ingressClassName, and false if it has none.
+func (c *ingressClasses) className(ingress *v1beta1.Ingress) (string, bool) {
+	if class, f := ingress.Annotations[kube.IngressClassAnnotation]; f {
+		return class, true
+	}
+	if c == nil {
+		return "", false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	class, f := c.classNames[ingress.Namespace+"/"+ingress.Name]
+	return class, f
+}
+
+// controller returns the controller of the class, and false if there is no such IngressClass.
+func (c *ingressClasses) controller(class string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	controller, f := c.controllers[class]
+	return controller, f
+}
+
+// defaultController returns the controller of the default class, and false if there is none.
+func (c *ingressClasses) defaultController() (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.defaultClass == "" {
+		return "", false
+	}
+	return c.controllers[c.defaultClass], true
+}
+
+// update replaces the classes, and returns true if they changed.
+func (c *ingressClasses) update(classes []ingressClass) bool {
+	controllers := make(map[string]string, len(classes))
+	defaultClass := ""
+	for _, class := range classes {
+		controllers[class.Metadata.Name] = class.Spec.Controller
+		if class.Metadata.Annotations[defaultIngressClassAnnotation] == "true" {
+			defaultClass = class.Metadata.Name
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.synced = true
+	if reflect.DeepEqual(controllers, c.controllers) && defaultClass == c.defaultClass {
+		return false
+	}
+	c.controllers = controllers
+	c.defaultClass = defaultClass
+	return true
+}
+
+// updateClassNames replaces the class names of the Ingresses, and returns true if they changed.
+func (c *ingressClasses) updateClassNames(ingresses []ingressWithClass) bool {
+	classNames := make(map[string]string, len(ingresses))
+	for _, ingress := range ingresses {
+		if ingress.Spec.IngressClassName != "" {
+			classNames[ingress.Metadata.Namespace+"/"+ingress.Metadata.Name] = ingress.Spec.IngressClassName
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if reflect.DeepEqual(classNames, c.classNames) {
+		return false
+	}
+	c.classNames = classNames
+	return true
+}
+
+// addHandler adds a handler called when the classes change.
+func (c *ingressClasses) addHandler(f func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers = append(c.handlers, f)
+}
+
+func (c *ingressClasses) HasSynced() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.synced
+}
+
+// run lists the IngressClasses and the class names of the Ingresses of the namespace until stop is
+// closed, calling the handlers when they change. The classes are synced, and empty, if the cluster
+// doesn't serve the IngressClass API. Only the first call runs, the classes being shared.
+func (c *ingressClasses) run(client kubernetes.Interface, namespace string, stop <-chan struct{}) {
+	c.runOnce.Do(func() {
+		if !ingressClassesServed(client) {
+			log.Infof("IngressClass API not served, the Ingresses are selected by the ingress class annotation")
+			c.update(nil)
+			return
+		}
+		wait.Until(func() {
+			classes, err := listIngressClasses(client)
+			if err != nil {
+				log.Warnf("failed to list the IngressClasses, keeping the previous ones: %v", err)
+				c.mu.Lock()
+				c.synced = true
+				c.mu.Unlock()
+				return
+			}
+			changed := c.update(classes)
+			if ingresses, err := listIngressesWithClass(client, namespace); err != nil {
+				log.Warnf("failed to list the class names of the Ingresses, keeping the previous ones: %v", err)
+			} else if c.updateClassNames(ingresses) {
+				changed = true
+			}
+			if changed {
+				log.Infof("IngressClasses updated, %d classes", len(classes))
+				c.mu.RLock()
+				handlers := c.handlers
+				c.mu.RUnlock()
+				for _, f := range handlers {
+					f()
+				}
+			}
+		}, ingressClassResyncPeriod, stop)
+	})
+}
+
+func ingressClassesServed(client kubernetes.Interface) bool {
+	resources, err := client.Discovery().ServerResourcesForGroupVersion(ingressClassGroupVersion)
+	if err != nil {
+		return false
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == "ingressclasses" {
+			return true
+		}
+	}
+	return false
+}
+
+func listIngressClasses(client kubernetes.Interface) ([]ingressClass, error) {
+	raw, err := client.NetworkingV1beta1().RESTClient().Get().
+		AbsPath("/apis", ingressClassGroupVersion, "ingressclasses").DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	list := &ingressClassList{}
+	if err := json.Unmarshal(raw, list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func listIngressesWithClass(client kubernetes.Interface, namespace string) ([]ingressWithClass, error) {
+	path := []string{"/apis", ingressClassGroupVersion}
+	if namespace != "" {
+		path = append(path, "namespaces", namespace)
+	}
+	raw, err := client.NetworkingV1beta1().RESTClient().Get().AbsPath(append(path, "ingresses")...).DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	list := &ingressWithClassList{}
+	if err := json.Unmarshal(raw, list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
@@ -0,0 +1,216 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingress
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"k8s.io/api/extensions/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+
+	kubecontroller "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
+	"istio.io/istio/pkg/config/mesh"
+)
+
+func parseIngressClasses(t *testing.T, list string) []ingressClass {
+	t.Helper()
+	out := &ingressClassList{}
+	if err := json.Unmarshal([]byte(list), out); err != nil {
+		t.Fatal(err)
+	}
+	return out.Items
+}
+
+func TestIngressClasses(t *testing.T) {
+	classes := newIngressClasses()
+	if classes.HasSynced() {
+		t.Fatal("the classes should not be synced before the first update")
+	}
+	if !classes.update(parseIngressClasses(t, `{"items": [
+		{"metadata": {"name": "istio"}, "spec": {"controller": "istio.io/ingress-controller"}},
+		{"metadata": {"name": "nginx", "annotations": {"ingressclass.kubernetes.io/is-default-class": "true"}},
+		 "spec": {"controller": "k8s.io/ingress-nginx"}}]}`)) {
+		t.Error("update() should report the new classes")
+	}
+	if !classes.HasSynced() {
+		t.Error("the classes should be synced once updated")
+	}
+	if controller, f := classes.controller("istio"); !f || controller != IstioIngressController {
+		t.Errorf("controller(istio) = %q, %v", controller, f)
+	}
+	if _, f := classes.controller("traefik"); f {
+		t.Error("controller(traefik) should not be found")
+	}
+	if controller, f := classes.defaultController(); !f || controller != "k8s.io/ingress-nginx" {
+		t.Errorf("defaultController() = %q, %v", controller, f)
+	}
+	if classes.update(parseIngressClasses(t, `{"items": [
+		{"metadata": {"name": "nginx", "annotations": {"ingressclass.kubernetes.io/is-default-class": "true"}},
+		 "spec": {"controller": "k8s.io/ingress-nginx"}},
+		{"metadata": {"name": "istio"}, "spec": {"controller": "istio.io/ingress-controller"}}]}`)) {
+		t.Error("update() should not report the same classes")
+	}
+
+	var none *ingressClasses
+	if _, f := none.defaultController(); f {
+		t.Error("a nil ingressClasses should have no default class")
+	}
+}
+
+func TestShouldProcessIngressWithIngressClasses(t *testing.T) {
+	istioDefault := newIngressClasses()
+	istioDefault.update(parseIngressClasses(t, `{"items": [
+		{"metadata": {"name": "gateway", "annotations": {"ingressclass.kubernetes.io/is-default-class": "true"}},
+		 "spec": {"controller": "istio.io/ingress-controller"}},
+		{"metadata": {"name": "nginx"}, "spec": {"controller": "k8s.io/ingress-nginx"}}]}`))
+	nginxDefault := newIngressClasses()
+	nginxDefault.update(parseIngressClasses(t, `{"items": [
+		{"metadata": {"name": "gateway"}, "spec": {"controller": "istio.io/ingress-controller"}},
+		{"metadata": {"name": "nginx", "annotations": {"ingressclass.kubernetes.io/is-default-class": "true"}},
+		 "spec": {"controller": "k8s.io/ingress-nginx"}}]}`))
+
+	cases := []struct {
+		name          string
+		mode          meshconfig.MeshConfig_IngressControllerMode
+		classes       *ingressClasses
+		ingressClass  string
+		shouldProcess bool
+	}{
+		{"istio class in default mode", meshconfig.MeshConfig_DEFAULT, nginxDefault, "gateway", true},
+		{"istio class in strict mode", meshconfig.MeshConfig_STRICT, nginxDefault, "gateway", true},
+		{"mesh class", meshconfig.MeshConfig_STRICT, nginxDefault, "istio", true},
+		{"other controller", meshconfig.MeshConfig_DEFAULT, istioDefault, "nginx", false},
+		{"no class with other default", meshconfig.MeshConfig_DEFAULT, nginxDefault, "", false},
+		{"no class with istio default", meshconfig.MeshConfig_STRICT, istioDefault, "", true},
+		{"no class without IngressClass API", meshconfig.MeshConfig_DEFAULT, nil, "", true},
+		{"off", meshconfig.MeshConfig_OFF, istioDefault, "", false},
+		{"istio class name", meshconfig.MeshConfig_STRICT, nginxDefault, "spec:gateway", true},
+		{"other class name", meshconfig.MeshConfig_DEFAULT, istioDefault, "spec:nginx", false},
+	}
+	for _, c := range cases {
+		ing := &v1beta1.Ingress{ObjectMeta: meta_v1.ObjectMeta{Name: "test-ingress", Namespace: "default"}}
+		if className := strings.TrimPrefix(c.ingressClass, "spec:"); className != c.ingressClass {
+			c.classes.updateClassNames(parseIngressesWithClass(t, `{"items": [
+				{"metadata": {"name": "test-ingress", "namespace": "default"}, "spec": {"ingressClassName": "`+className+`"}}]}`))
+		} else if c.ingressClass != "" {
+			ing.Annotations = map[string]string{"kubernetes.io/ingress.class": c.ingressClass}
+		}
+		mesh := mesh.DefaultMeshConfig()
+		mesh.IngressControllerMode = c.mode
+		mesh.IngressClass = "istio"
+		if got := shouldProcessIngress(&mesh, ing, c.classes); got != c.shouldProcess {
+			t.Errorf("%s: shouldProcessIngress() = %v, want %v", c.name, got, c.shouldProcess)
+		}
+	}
+}
+
+func parseIngressesWithClass(t *testing.T, list string) []ingressWithClass {
+	t.Helper()
+	out := &ingressWithClassList{}
+	if err := json.Unmarshal([]byte(list), out); err != nil {
+		t.Fatal(err)
+	}
+	return out.Items
+}
+
+func TestIngressClassName(t *testing.T) {
+	classes := newIngressClasses()
+	ingresses := parseIngressesWithClass(t, `{"items": [
+		{"metadata": {"name": "a", "namespace": "default"}, "spec": {"ingressClassName": "gateway"}},
+		{"metadata": {"name": "b", "namespace": "default"}, "spec": {}}]}`)
+	if !classes.updateClassNames(ingresses) {
+		t.Error("updateClassNames() should report the new class names")
+	}
+	if classes.updateClassNames(ingresses) {
+		t.Error("updateClassNames() should not report the same class names")
+	}
+
+	ingress := func(name string, annotations map[string]string) *v1beta1.Ingress {
+		return &v1beta1.Ingress{ObjectMeta: meta_v1.ObjectMeta{Name: name, Namespace: "default", Annotations: annotations}}
+	}
+	var none *ingressClasses
+	cases := []struct {
+		name      string
+		classes   *ingressClasses
+		ingress   *v1beta1.Ingress
+		wantClass string
+		wantFound bool
+	}{
+		{"class name", classes, ingress("a", nil), "gateway", true},
+		{"annotation over class name", classes, ingress("a", map[string]string{"kubernetes.io/ingress.class": "nginx"}), "nginx", true},
+		{"no class", classes, ingress("b", nil), "", false},
+		{"annotation without IngressClass API", none, ingress("c", map[string]string{"kubernetes.io/ingress.class": "istio"}), "istio", true},
+		{"no class without IngressClass API", none, ingress("a", nil), "", false},
+	}
+	for _, c := range cases {
+		if class, f := c.classes.className(c.ingress); class != c.wantClass || f != c.wantFound {
+			t.Errorf("%s: className() = %q, %v, want %q, %v", c.name, class, f, c.wantClass, c.wantFound)
+		}
+	}
+}
+
+func TestSharedIngressClasses(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	classes := ingressClassesFor(client, "")
+	if ingressClassesFor(client, "") != classes {
+		t.Error("the controller and the StatusSyncer of a client should share the classes")
+	}
+	if ingressClassesFor(client, "default") == classes || ingressClassesFor(fake.NewSimpleClientset(), "") == classes {
+		t.Error("the classes should not be shared across namespaces or clients")
+	}
+
+	syncer, err := NewStatusSyncer(&meshconfig.MeshConfig{IngressControllerMode: meshconfig.MeshConfig_DEFAULT},
+		client, "istio-system", kubecontroller.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctl := NewController(client, &meshconfig.MeshConfig{}, kubecontroller.Options{}).(*controller)
+	if syncer.classes != classes || ctl.classes != classes {
+		t.Error("the controller and the StatusSyncer should share the classes")
+	}
+}
+
+func TestIngressClassesServed(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	if ingressClassesServed(client) {
+		t.Error("the IngressClasses should not be served without the networking.k8s.io/v1beta1 API")
+	}
+	client.Resources = []*meta_v1.APIResourceList{{
+		GroupVersion: "networking.k8s.io/v1beta1",
+		APIResources: []meta_v1.APIResource{{Name: "ingresses"}},
+	}}
+	if ingressClassesServed(client) {
+		t.Error("the IngressClasses should not be served by a cluster only serving the Ingresses")
+	}
+	client.Resources[0].APIResources = append(client.Resources[0].APIResources, meta_v1.APIResource{Name: "ingressclasses"})
+	if !ingressClassesServed(client) {
+		t.Error("the IngressClasses should be served")
+	}
+
+	// Without the API, the classes are synced and empty, and the controller isn't blocked.
+	classes := newIngressClasses()
+	classes.addHandler(func() {
+		t.Error("the classes should not change")
+	})
+	classes.run(fake.NewSimpleClientset(), "", make(chan struct{}))
+	if !classes.HasSynced() {
+		t.Error("the classes should be synced without the IngressClass API")
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"reflect"
 	"time"
 
+	coreV1 "k8s.io/api/core/v1"
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
 	"k8s.io/client-go/informers/extensions/v1beta1"
 	"k8s.io/client-go/kubernetes"
@@ -55,6 +56,8 @@ import (
 // Follows mesh.IngressControllerMode setting to enable - OFF|STRICT|DEFAULT.
 // STRICT requires "kubernetes.io/ingress.class" == mesh.IngressClass
 // DEFAULT allows Ingress without explicit class.
+// The classes of the IngressClasses of the istio.io/ingress-controller controller are processed in both
+// modes, and the default IngressClass, if any, decides for the Ingresses without class.
 
 // In 1.1:
 // - K8S_INGRESS_NS - namespace of the Gateway that will act as ingress.
@@ -72,8 +75,22 @@ type controller struct {
 	queue    kube.Queue
 	informer cache.SharedIndexInformer
 	handler  *kube.ChainHandler
+
+	// serviceInformer is the informer of the Services of the kube registry, whose named ports are
+	// referenced by the Ingress backends. It is nil if not shared, and the named ports are skipped.
+	serviceInformer cache.SharedIndexInformer
+	ports           *servicePortCache
+
+	// classes are the IngressClasses selecting the Ingresses processed by Istio, shared with the StatusSyncer.
+	classes *ingressClasses
+	// namespace is the namespace of the Ingresses, all if empty.
+	namespace string
 }
 
+// ingressClassChange is the event of the changes of the IngressClasses, which may change the Ingresses
+// processed by Istio.
+type ingressClassChange struct{}
+
 var (
 	// TODO: move to features ( and remove in 1.2 )
 	ingressNamespace = env.RegisterStringVar("K8S_INGRESS_NS", "", "").Get()
@@ -112,10 +129,30 @@ func NewController(client kubernetes.Interface, mesh *meshconfig.MeshConfig,
 			},
 		})
 
+	classes := ingressClassesFor(client, options.WatchedNamespace)
+	classes.addHandler(func() {
+		queue.Push(kube.NewTask(handler.Apply, ingressClassChange{}, model.EventUpdate))
+	})
+
+	// The informer of the Services is shared with, and run by, the kube registry.
+	var serviceInformer cache.SharedIndexInformer
+	var ports *servicePortCache
+	if options.InformerFactory != nil {
+		services := options.InformerFactory.Core().V1().Services()
+		serviceInformer = services.Informer()
+		ports = newServicePortCache(services.Lister())
+		serviceInformer.AddEventHandler(servicePortsHandler(informer, ports, func(ingress *extensionsv1beta1.Ingress) {
+			queue.Push(kube.NewTask(handler.Apply, ingress, model.EventUpdate))
+		}))
+	}
+	servicesSynced := func() bool {
+		return serviceInformer == nil || serviceInformer.HasSynced()
+	}
+
 	// first handler in the chain blocks until the cache is fully synchronized
 	// it does this by returning an error to the chain handler
 	handler.Append(func(obj interface{}, event model.Event) error {
-		if !informer.HasSynced() {
+		if !informer.HasSynced() || !servicesSynced() || !classes.HasSynced() {
 			return errors.New("waiting till full synchronization")
 		}
 		if ingress, ok := obj.(*extensionsv1beta1.Ingress); ok {
@@ -131,13 +168,62 @@ func NewController(client kubernetes.Interface, mesh *meshconfig.MeshConfig,
 		queue:        queue,
 		informer:     informer,
 		handler:      handler,
+
+		serviceInformer: serviceInformer,
+		ports:           ports,
+		classes:         classes,
+		namespace:       options.WatchedNamespace,
+	}
+}
+
+// servicePortsHandler updates the Ingresses referring to the named ports of a Service when its ports change.
+func servicePortsHandler(ingresses cache.SharedIndexInformer, ports *servicePortCache,
+	update func(*extensionsv1beta1.Ingress)) cache.ResourceEventHandler {
+	onServiceChange := func(obj interface{}) {
+		svc, ok := obj.(*coreV1.Service)
+		if !ok {
+			tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+			if !ok {
+				return
+			}
+			if svc, ok = tombstone.Obj.(*coreV1.Service); !ok {
+				return
+			}
+		}
+		if !ports.invalidate(svc.Namespace, svc.Name) {
+			return
+		}
+		for _, obj := range ingresses.GetStore().List() {
+			ingress := obj.(*extensionsv1beta1.Ingress)
+			if ingress.Namespace == svc.Namespace && referencesNamedPort(ingress, svc.Name) {
+				update(ingress)
+			}
+		}
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: onServiceChange,
+		UpdateFunc: func(old, cur interface{}) {
+			oldSvc, oldOk := old.(*coreV1.Service)
+			curSvc, curOk := cur.(*coreV1.Service)
+			if oldOk && curOk && reflect.DeepEqual(oldSvc.Spec.Ports, curSvc.Spec.Ports) {
+				return
+			}
+			onServiceChange(cur)
+		},
+		DeleteFunc: onServiceChange,
 	}
 }
 
 func (c *controller) RegisterEventHandler(typ string, f func(model.Config, model.Event)) {
 	c.handler.Append(func(obj interface{}, event model.Event) error {
-		ingress, ok := obj.(*extensionsv1beta1.Ingress)
-		if !ok || !shouldProcessIngress(c.mesh, ingress) {
+		switch o := obj.(type) {
+		case *extensionsv1beta1.Ingress:
+			if !shouldProcessIngress(c.mesh, o, c.classes) {
+				return nil
+			}
+		case ingressClassChange:
+			// The Ingresses processed may have changed.
+		default:
 			return nil
 		}
 
@@ -150,7 +236,7 @@ func (c *controller) RegisterEventHandler(typ string, f func(model.Config, model
 		// TODO: This works well for Add and Delete events, but not so for Update:
 		// An updated ingress may also trigger an Add or Delete for one of its constituent sub-rules.
 		switch typ {
-		case schemas.VirtualService.Type:
+		case schemas.VirtualService.Type, schemas.DestinationRule.Type:
 			f(model.Config{
 				ConfigMeta: model.ConfigMeta{
 					Type: typ,
@@ -171,7 +257,7 @@ func (c *controller) GetResourceAtVersion(version string, key string) (resourceV
 }
 
 func (c *controller) HasSynced() bool {
-	return c.informer.HasSynced()
+	return c.informer.HasSynced() && (c.serviceInformer == nil || c.serviceInformer.HasSynced()) && c.classes.HasSynced()
 }
 
 func (c *controller) Run(stop <-chan struct{}) {
@@ -180,17 +266,18 @@ func (c *controller) Run(stop <-chan struct{}) {
 		c.queue.Run(stop)
 	}()
 	go c.informer.Run(stop)
+	go c.classes.run(c.client, c.namespace, stop)
 	<-stop
 }
 
 func (c *controller) ConfigDescriptor() schema.Set {
 	//TODO: are these two config descriptors right?
-	return schema.Set{schemas.Gateway, schemas.VirtualService}
+	return schema.Set{schemas.Gateway, schemas.VirtualService, schemas.DestinationRule}
 }
 
 //TODO: we don't return out of this function now
 func (c *controller) Get(typ, name, namespace string) *model.Config {
-	if typ != schemas.Gateway.Type && typ != schemas.VirtualService.Type {
+	if typ != schemas.Gateway.Type && typ != schemas.VirtualService.Type && typ != schemas.DestinationRule.Type {
 		return nil
 	}
 
@@ -206,7 +293,7 @@ func (c *controller) Get(typ, name, namespace string) *model.Config {
 	}
 
 	ingress := obj.(*extensionsv1beta1.Ingress)
-	if !shouldProcessIngress(c.mesh, ingress) {
+	if !shouldProcessIngress(c.mesh, ingress, c.classes) {
 		return nil
 	}
 
@@ -214,13 +301,14 @@ func (c *controller) Get(typ, name, namespace string) *model.Config {
 }
 
 func (c *controller) List(typ, namespace string) ([]model.Config, error) {
-	if typ != schemas.Gateway.Type && typ != schemas.VirtualService.Type {
+	if typ != schemas.Gateway.Type && typ != schemas.VirtualService.Type && typ != schemas.DestinationRule.Type {
 		return nil, errUnsupportedOp
 	}
 
 	out := make([]model.Config, 0)
 
 	ingressByHost := map[string]*model.Config{}
+	ruleByHost := map[string]*model.Config{}
 
 	for _, obj := range c.informer.GetStore().List() {
 		ingress := obj.(*extensionsv1beta1.Ingress)
@@ -228,23 +316,30 @@ func (c *controller) List(typ, namespace string) ([]model.Config, error) {
 			continue
 		}
 
-		if !shouldProcessIngress(c.mesh, ingress) {
+		if !shouldProcessIngress(c.mesh, ingress, c.classes) {
 			continue
 		}
 
 		switch typ {
 		case schemas.VirtualService.Type:
-			ConvertIngressVirtualService(*ingress, c.domainSuffix, ingressByHost)
+			ConvertIngressVirtualService(*ingress, c.domainSuffix, ingressByHost, c.ports.resolver())
+		case schemas.DestinationRule.Type:
+			ConvertIngressDestinationRule(*ingress, c.domainSuffix, ruleByHost, c.ports.resolver())
 		case schemas.Gateway.Type:
 			gateways := ConvertIngressV1alpha3(*ingress, c.domainSuffix)
 			out = append(out, gateways)
 		}
 	}
 
-	if typ == schemas.VirtualService.Type {
+	switch typ {
+	case schemas.VirtualService.Type:
 		for _, obj := range ingressByHost {
 			out = append(out, *obj)
 		}
+	case schemas.DestinationRule.Type:
+		for _, obj := range ruleByHost {
+			out = append(out, *obj)
+		}
 	}
 
 	return out, nil
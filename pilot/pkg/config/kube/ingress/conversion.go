@@ -23,20 +23,55 @@ import (
 
 	"github.com/hashicorp/go-multierror"
 	"k8s.io/api/extensions/v1beta1"
-	"k8s.io/apimachinery/pkg/util/intstr"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/pkg/log"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
-	"istio.io/istio/pilot/pkg/serviceregistry/kube"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/config/schemas"
 )
 
+const (
+	// BackendProtocolAnnotation declares the protocol spoken by the backends of an Ingress, using the
+	// same annotation as the nginx ingress controller. Backends declared as HTTPS or GRPCS expect TLS,
+	// and get a DestinationRule originating TLS from the ingress gateway.
+	BackendProtocolAnnotation = "nginx.ingress.kubernetes.io/backend-protocol"
+
+	// BackendTLSServerNameAnnotation overrides the SNI sent to TLS backends, using the same annotation
+	// as the nginx ingress controller.
+	BackendTLSServerNameAnnotation = "nginx.ingress.kubernetes.io/proxy-ssl-name"
+
+	// PathTypeAnnotation sets the type of the paths of an Ingress, with the semantics of the pathType of
+	// the networking.k8s.io Ingress paths: Exact, Prefix or ImplementationSpecific, the default.
+	PathTypeAnnotation = "ingress.istio.io/path-type"
+
+	// UseRegexAnnotation declares the paths of an Ingress as regular expressions matching the beginning
+	// of the request path, using the same annotation as the nginx ingress controller.
+	UseRegexAnnotation = "nginx.ingress.kubernetes.io/use-regex"
+
+	// RewriteTargetAnnotation rewrites the part of the request path matched by the paths of an Ingress
+	// to the target, using the same annotation as the nginx ingress controller. The capture groups of
+	// the nginx ingress controller are not supported, and the regex paths are not rewritten.
+	RewriteTargetAnnotation = "nginx.ingress.kubernetes.io/rewrite-target"
+
+	// The path types of PathTypeAnnotation.
+	PathTypeExact                  = "Exact"
+	PathTypePrefix                 = "Prefix"
+	PathTypeImplementationSpecific = "ImplementationSpecific"
+
+	// CertificateModeFile is the PILOT_INGRESS_CERTIFICATE_MODE reading the certificates of the ingress
+	// gateway from files only, ignoring the secret of the Ingress TLS blocks.
+	CertificateModeFile = "file"
+	// CertificateModeSDS is the PILOT_INGRESS_CERTIFICATE_MODE reading the certificates of the ingress
+	// gateway from the secret of the Ingress TLS blocks, which must be in the namespace of the gateway.
+	CertificateModeSDS = "sds"
+)
+
 // EncodeIngressRuleName encodes an ingress rule name for a given ingress resource name,
 // as well as the position of the rule and path specified within it, counting from 1.
 // ruleNum == pathNum == 0 indicates the default backend specified for an ingress.
@@ -72,35 +107,42 @@ func ConvertIngressV1alpha3(ingress v1beta1.Ingress, domainSuffix string) model.
 		Selector: labels.Instance{constants.IstioLabel: constants.IstioIngressLabelValue},
 	}
 
-	// FIXME this is a temporary hack until all test templates are updated
-	//for _, tls := range ingress.Spec.TLS {
-
-	// TODO: add secretName (converted to sdsName)
-	if len(ingress.Spec.TLS) > 0 {
-		tls := ingress.Spec.TLS[0] // FIXME
+	sds := features.IngressCertificateMode == CertificateModeSDS
+	for i, tls := range ingress.Spec.TLS {
 		// TODO validation when multiple wildcard tls secrets are given
 		if len(tls.Hosts) == 0 {
 			tls.Hosts = []string{"*"}
 		}
-		gateway.Servers = append(gateway.Servers, &networking.Server{
+		// The name of the server of the first TLS block is kept as is, the route names derive from it.
+		name := fmt.Sprintf("https-443-ingress-%s-%s", ingress.Name, ingress.Namespace)
+		if i > 0 {
+			name = fmt.Sprintf("%s-%d", name, i)
+		}
+		server := &networking.Server{
 			Port: &networking.Port{
 				Number:   443,
 				Protocol: string(protocol.HTTPS),
-				Name:     fmt.Sprintf("https-443-ingress-%s-%s", ingress.Name, ingress.Namespace),
+				Name:     name,
 			},
 			Hosts: tls.Hosts,
-			// While we accept multiple certs, we expect them to be mounted in
-			// /etc/istio/ingress-certs/tls.crt|tls.key|root-cert.pem
 			Tls: &networking.Server_TLSOptions{
 				HttpsRedirect: false,
 				Mode:          networking.Server_TLSOptions_SIMPLE,
+				// Without SDS, or without secret, we expect the certificates to be mounted in
+				// /etc/istio/ingress-certs/tls.crt|tls.key|root-cert.pem
 				// TODO this is no longer valid for the new v2 stuff
 				PrivateKey:        path.Join(constants.IngressCertsPath, constants.IngressKeyFilename),
 				ServerCertificate: path.Join(constants.IngressCertsPath, constants.IngressCertFilename),
 				// TODO: make sure this is mounted
 				CaCertificates: path.Join(constants.IngressCertsPath, constants.RootCertFilename),
 			},
-		})
+		}
+		if sds {
+			// Only opted in, as the secret must be in the namespace of the ingress gateway rather than
+			// in the namespace of the ingress, and the gateways running without SDS ignore it.
+			server.Tls.CredentialName = tls.SecretName
+		}
+		gateway.Servers = append(gateway.Servers, server)
 	}
 
 	gateway.Servers = append(gateway.Servers, &networking.Server{
@@ -127,8 +169,10 @@ func ConvertIngressV1alpha3(ingress v1beta1.Ingress, domainSuffix string) model.
 	return gatewayConfig
 }
 
-// ConvertIngressVirtualService converts from ingress spec to Istio VirtualServices
-func ConvertIngressVirtualService(ingress v1beta1.Ingress, domainSuffix string, ingressByHost map[string]*model.Config) {
+// ConvertIngressVirtualService converts from ingress spec to Istio VirtualServices. The named service
+// ports of the backends are resolved with ports, and the backends are skipped if it is nil.
+func ConvertIngressVirtualService(ingress v1beta1.Ingress, domainSuffix string, ingressByHost map[string]*model.Config,
+	ports ServicePortResolver) {
 	// Ingress allows a single host - if missing '*' is assumed
 	// We need to merge all rules with a particular host across
 	// all ingresses, and return a separate VirtualService for each
@@ -137,6 +181,26 @@ func ConvertIngressVirtualService(ingress v1beta1.Ingress, domainSuffix string,
 		ingressNamespace = constants.IstioIngressNamespace
 	}
 
+	pathType := ingress.Annotations[PathTypeAnnotation]
+	switch pathType {
+	case "", PathTypeExact, PathTypePrefix, PathTypeImplementationSpecific:
+	default:
+		log.Warnf("invalid path type %q of ingress %s:%s, using %s", pathType, ingress.Namespace, ingress.Name,
+			PathTypeImplementationSpecific)
+		pathType = PathTypeImplementationSpecific
+	}
+	regex := ingress.Annotations[UseRegexAnnotation] == "true"
+	rewrite := ingress.Annotations[RewriteTargetAnnotation]
+	if strings.Contains(rewrite, "$") || (rewrite != "" && regex) {
+		log.Warnf("unsupported rewrite target %q of ingress %s:%s, only the paths which are not regular "+
+			"expressions can be rewritten, without capture groups", rewrite, ingress.Namespace, ingress.Name)
+		rewrite = ""
+	}
+	if rewrite != "" && pathType == PathTypePrefix && !strings.HasSuffix(rewrite, "/") {
+		// The prefix paths match up to the slash following them, which the rewrite must keep.
+		rewrite += "/"
+	}
+
 	for _, rule := range ingress.Spec.Rules {
 		if rule.HTTP == nil {
 			log.Infof("invalid ingress rule %s:%s for host %q, no paths defined", ingress.Namespace, ingress.Name, rule.Host)
@@ -159,20 +223,20 @@ func ConvertIngressVirtualService(ingress v1beta1.Ingress, domainSuffix string,
 
 		httpRoutes := make([]*networking.HTTPRoute, 0)
 		for _, httpPath := range rule.HTTP.Paths {
-			httpMatch := &networking.HTTPMatchRequest{
-				Uri: createStringMatch(httpPath.Path),
-			}
-
-			httpRoute := ingressBackendToHTTPRoute(&httpPath.Backend, ingress.Namespace, domainSuffix)
+			httpRoute := ingressBackendToHTTPRoute(&httpPath.Backend, ingress.Namespace, domainSuffix, ports)
 			if httpRoute == nil {
 				log.Infof("invalid ingress rule %s:%s for host %q, no backend defined for path", ingress.Namespace, ingress.Name, rule.Host)
 				continue
 			}
-			httpRoute.Match = []*networking.HTTPMatchRequest{httpMatch}
+			httpRoute.Match = createHTTPMatches(httpPath.Path, pathType, regex)
+			if rewrite != "" {
+				httpRoute.Rewrite = &networking.HTTPRewrite{Uri: rewrite}
+			}
 			httpRoutes = append(httpRoutes, httpRoute)
 		}
 
 		virtualService.Http = httpRoutes
+		sortHTTPRoutes(virtualService.Http)
 
 		virtualServiceConfig := model.Config{
 			ConfigMeta: model.ConfigMeta{
@@ -190,17 +254,7 @@ func ConvertIngressVirtualService(ingress v1beta1.Ingress, domainSuffix string,
 		if f {
 			vs := old.Spec.(*networking.VirtualService)
 			vs.Http = append(vs.Http, httpRoutes...)
-			sort.SliceStable(vs.Http, func(i, j int) bool {
-				r1 := vs.Http[i].Match[0].Uri
-				r2 := vs.Http[j].Match[0].Uri
-				_, r1Ex := r1.MatchType.(*networking.StringMatch_Exact)
-				_, r2Ex := r2.MatchType.(*networking.StringMatch_Exact)
-				// TODO: default at the end
-				if r1Ex && !r2Ex {
-					return true
-				}
-				return false
-			})
+			sortHTTPRoutes(vs.Http)
 		} else {
 			ingressByHost[host] = &virtualServiceConfig
 		}
@@ -214,19 +268,101 @@ func ConvertIngressVirtualService(ingress v1beta1.Ingress, domainSuffix string,
 	}
 }
 
-func ingressBackendToHTTPRoute(backend *v1beta1.IngressBackend, namespace string, domainSuffix string) *networking.HTTPRoute {
+// ConvertIngressDestinationRule converts from ingress spec to Istio DestinationRules originating TLS
+// to the ingress backends, if the ingress declares that its backends expect TLS.
+// DestinationRules are merged per backend host across all ingresses, with one port level setting
+// per backend port. The named service ports are resolved with ports, and skipped if it is nil.
+func ConvertIngressDestinationRule(ingress v1beta1.Ingress, domainSuffix string, ruleByHost map[string]*model.Config,
+	ports ServicePortResolver) {
+	if !backendRequiresTLS(ingress.Annotations[BackendProtocolAnnotation]) {
+		return
+	}
+
+	backends := make([]*v1beta1.IngressBackend, 0)
+	if ingress.Spec.Backend != nil {
+		backends = append(backends, ingress.Spec.Backend)
+	}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for i := range rule.HTTP.Paths {
+			backends = append(backends, &rule.HTTP.Paths[i].Backend)
+		}
+	}
+
+	for _, backend := range backends {
+		// The port level settings select the port by number: the named ports which can't be resolved
+		// are skipped.
+		port, ok := backendPort(backend, ingress.Namespace, ports)
+		if !ok {
+			continue
+		}
+		host := fmt.Sprintf("%s.%s.svc.%s", backend.ServiceName, ingress.Namespace, domainSuffix)
+
+		cfg, f := ruleByHost[host]
+		if !f {
+			cfg = &model.Config{
+				ConfigMeta: model.ConfigMeta{
+					Type:      schemas.DestinationRule.Type,
+					Group:     schemas.DestinationRule.Group,
+					Version:   schemas.DestinationRule.Version,
+					Name:      backend.ServiceName + "-" + ingress.Name + "-" + constants.IstioIngressGatewayName,
+					Namespace: ingress.Namespace,
+					Domain:    domainSuffix,
+				},
+				Spec: &networking.DestinationRule{
+					Host:          host,
+					TrafficPolicy: &networking.TrafficPolicy{},
+				},
+			}
+			ruleByHost[host] = cfg
+		}
+
+		policy := cfg.Spec.(*networking.DestinationRule).TrafficPolicy
+		exists := false
+		for _, setting := range policy.PortLevelSettings {
+			if setting.Port.GetNumber() == port {
+				exists = true
+				break
+			}
+		}
+		if exists {
+			continue
+		}
+		policy.PortLevelSettings = append(policy.PortLevelSettings, &networking.TrafficPolicy_PortTrafficPolicy{
+			Port: &networking.PortSelector{Number: port},
+			Tls: &networking.TLSSettings{
+				Mode: networking.TLSSettings_SIMPLE,
+				Sni:  ingress.Annotations[BackendTLSServerNameAnnotation],
+			},
+		})
+	}
+}
+
+// backendRequiresTLS returns true if the backend protocol annotation value denotes a TLS protocol.
+func backendRequiresTLS(backendProtocol string) bool {
+	switch strings.ToUpper(backendProtocol) {
+	case "HTTPS", "GRPCS":
+		return true
+	default:
+		return false
+	}
+}
+
+func ingressBackendToHTTPRoute(backend *v1beta1.IngressBackend, namespace string, domainSuffix string,
+	ports ServicePortResolver) *networking.HTTPRoute {
 	if backend == nil {
 		return nil
 	}
 
-	port := &networking.PortSelector{}
-
-	if backend.ServicePort.Type == intstr.Int {
-		port.Number = uint32(backend.ServicePort.IntVal)
-	} else {
-		// Port names are not allowed in destination rules.
+	// The route destinations select the port by number: the named ports which can't be resolved
+	// are skipped.
+	number, ok := backendPort(backend, namespace, ports)
+	if !ok {
 		return nil
 	}
+	port := &networking.PortSelector{Number: number}
 
 	return &networking.HTTPRoute{
 		Route: []*networking.HTTPRouteDestination{
@@ -242,27 +378,119 @@ func ingressBackendToHTTPRoute(backend *v1beta1.IngressBackend, namespace string
 }
 
 // shouldProcessIngress determines whether the given ingress resource should be processed
-// by the controller, based on its ingress class annotation.
+// by the controller, based on its ingress class annotation or spec.ingressClassName and the IngressClasses
+// of the cluster:
+// the Ingresses of the mesh IngressClass or of an IngressClass of the Istio controller are processed.
+// The Ingresses without class are of the default IngressClass, if any, which makes them processed
+// or ignored whatever the mode, so that Istio coexists with the other ingress controllers.
 // See https://github.com/kubernetes/ingress/blob/master/examples/PREREQUISITES.md#ingress-class
-func shouldProcessIngress(mesh *meshconfig.MeshConfig, ingress *v1beta1.Ingress) bool {
-	class, exists := "", false
-	if ingress.Annotations != nil {
-		class, exists = ingress.Annotations[kube.IngressClassAnnotation]
+func shouldProcessIngress(mesh *meshconfig.MeshConfig, ingress *v1beta1.Ingress, classes *ingressClasses) bool {
+	class, exists := classes.className(ingress)
+	if !exists && mesh.IngressControllerMode != meshconfig.MeshConfig_OFF {
+		if controller, f := classes.defaultController(); f {
+			return controller == IstioIngressController
+		}
 	}
 
 	switch mesh.IngressControllerMode {
 	case meshconfig.MeshConfig_OFF:
 		return false
 	case meshconfig.MeshConfig_STRICT:
-		return exists && class == mesh.IngressClass
+		return exists && isIstioIngressClass(mesh, class, classes)
 	case meshconfig.MeshConfig_DEFAULT:
-		return !exists || class == mesh.IngressClass
+		return !exists || isIstioIngressClass(mesh, class, classes)
 	default:
 		log.Warnf("invalid ingress synchronization mode: %v", mesh.IngressControllerMode)
 		return false
 	}
 }
 
+// isIstioIngressClass returns true if the class is the mesh IngressClass, or an IngressClass of the Istio
+// controller.
+func isIstioIngressClass(mesh *meshconfig.MeshConfig, class string, classes *ingressClasses) bool {
+	if class == mesh.IngressClass {
+		return true
+	}
+	controller, _ := classes.controller(class)
+	return controller == IstioIngressController
+}
+
+// sortHTTPRoutes sorts the routes of the paths of the Ingresses so that the exact matches come first,
+// and then the longest paths, the most specific.
+func sortHTTPRoutes(routes []*networking.HTTPRoute) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		exact1, length1 := routePriority(routes[i])
+		exact2, length2 := routePriority(routes[j])
+		if exact1 != exact2 {
+			return exact1
+		}
+		return length1 > length2
+	})
+}
+
+// routePriority returns true if the route only has exact matches, and the length of its longest path.
+func routePriority(route *networking.HTTPRoute) (exact bool, length int) {
+	exact = true
+	for _, match := range route.Match {
+		var path string
+		switch m := match.GetUri().GetMatchType().(type) {
+		case *networking.StringMatch_Exact:
+			path = m.Exact
+		case *networking.StringMatch_Prefix:
+			exact = false
+			path = m.Prefix
+		case *networking.StringMatch_Regex:
+			exact = false
+			path = m.Regex
+		default:
+			// Matches all the paths.
+			exact = false
+		}
+		if len(path) > length {
+			length = len(path)
+		}
+	}
+	return exact, length
+}
+
+// createHTTPMatches returns the matches of the request path of an Ingress path, following the semantics
+// of the path type.
+func createHTTPMatches(path, pathType string, regex bool) []*networking.HTTPMatchRequest {
+	uri := func(m *networking.StringMatch) *networking.HTTPMatchRequest {
+		return &networking.HTTPMatchRequest{Uri: m}
+	}
+	if path == "" {
+		return []*networking.HTTPMatchRequest{uri(nil)}
+	}
+
+	switch {
+	case regex:
+		// As with the nginx ingress controller, the expression matches the beginning of the path.
+		return []*networking.HTTPMatchRequest{
+			uri(&networking.StringMatch{MatchType: &networking.StringMatch_Regex{Regex: path + ".*"}}),
+		}
+	case pathType == PathTypeExact:
+		return []*networking.HTTPMatchRequest{
+			uri(&networking.StringMatch{MatchType: &networking.StringMatch_Exact{Exact: path}}),
+		}
+	case pathType == PathTypePrefix:
+		// The prefix matches element by element: /foo matches /foo and /foo/bar, but not /foobar. The
+		// trailing slash is ignored.
+		prefix := strings.TrimRight(path, "/")
+		if prefix == "" {
+			return []*networking.HTTPMatchRequest{
+				uri(&networking.StringMatch{MatchType: &networking.StringMatch_Prefix{Prefix: "/"}}),
+			}
+		}
+		return []*networking.HTTPMatchRequest{
+			uri(&networking.StringMatch{MatchType: &networking.StringMatch_Exact{Exact: prefix}}),
+			uri(&networking.StringMatch{MatchType: &networking.StringMatch_Prefix{Prefix: prefix + "/"}}),
+		}
+	default:
+		return []*networking.HTTPMatchRequest{uri(createStringMatch(path))}
+	}
+}
+
 func createStringMatch(s string) *networking.StringMatch {
 	if s == "" {
 		return nil
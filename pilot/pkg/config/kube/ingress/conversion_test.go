@@ -15,6 +15,7 @@
 package ingress
 
 import (
+	"reflect"
 	"testing"
 
 	"k8s.io/api/extensions/v1beta1"
@@ -24,6 +25,7 @@ import (
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/config/mesh"
 )
@@ -112,8 +114,8 @@ func TestConversion(t *testing.T) {
 		},
 	}
 	cfgs := map[string]*model.Config{}
-	ConvertIngressVirtualService(ingress, "mydomain", cfgs)
-	ConvertIngressVirtualService(ingress2, "mydomain", cfgs)
+	ConvertIngressVirtualService(ingress, "mydomain", cfgs, nil)
+	ConvertIngressVirtualService(ingress2, "mydomain", cfgs, nil)
 
 	if len(cfgs) != 3 {
 		t.Error("VirtualServices, expected 3 got ", len(cfgs))
@@ -135,6 +137,135 @@ func TestConversion(t *testing.T) {
 	}
 }
 
+func TestConvertIngressV1alpha3TLS(t *testing.T) {
+	ingress := v1beta1.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "tls",
+			Namespace: "mock",
+		},
+		Spec: v1beta1.IngressSpec{
+			TLS: []v1beta1.IngressTLS{
+				{Hosts: []string{"a.example.com", "b.example.com"}, SecretName: "ab-cert"},
+				{Hosts: []string{"c.example.com"}, SecretName: "c-cert"},
+				{SecretName: "default-cert"},
+			},
+		},
+	}
+
+	gateway := ConvertIngressV1alpha3(ingress, "mydomain").Spec.(*networking.Gateway)
+	if len(gateway.Servers) != 4 {
+		t.Fatalf("got %d servers, want a server per TLS block and the HTTP server: %v", len(gateway.Servers), gateway.Servers)
+	}
+	cases := []struct {
+		name       string
+		hosts      []string
+		credential string
+	}{
+		{name: "https-443-ingress-tls-mock", hosts: []string{"a.example.com", "b.example.com"}, credential: "ab-cert"},
+		{name: "https-443-ingress-tls-mock-1", hosts: []string{"c.example.com"}, credential: "c-cert"},
+		{name: "https-443-ingress-tls-mock-2", hosts: []string{"*"}, credential: "default-cert"},
+	}
+	for i, c := range cases {
+		server := gateway.Servers[i]
+		if server.Port.Number != 443 || server.Port.Name != c.name {
+			t.Errorf("server %d: got port %v, want 443 named %s", i, server.Port, c.name)
+		}
+		if !reflect.DeepEqual(server.Hosts, c.hosts) {
+			t.Errorf("server %d: got hosts %v, want %v", i, server.Hosts, c.hosts)
+		}
+		// The certificates are mounted by default.
+		if server.Tls == nil || server.Tls.CredentialName != "" || server.Tls.ServerCertificate == "" {
+			t.Errorf("server %d: got TLS options %v, want the mounted certificates only", i, server.Tls)
+		}
+	}
+	if http := gateway.Servers[3]; http.Port.Number != 80 || http.Tls != nil {
+		t.Errorf("got last server %v, want the HTTP server", http)
+	}
+
+	// the SDS mode reads the certificates from the secrets of the TLS blocks.
+	defer func(mode string) { features.IngressCertificateMode = mode }(features.IngressCertificateMode)
+	features.IngressCertificateMode = CertificateModeSDS
+	gateway = ConvertIngressV1alpha3(ingress, "mydomain").Spec.(*networking.Gateway)
+	for i, c := range cases {
+		if server := gateway.Servers[i]; server.Tls.CredentialName != c.credential {
+			t.Errorf("server %d: got TLS options %v with the SDS mode, want credential %s", i, server.Tls, c.credential)
+		}
+	}
+}
+
+func TestConvertIngressDestinationRule(t *testing.T) {
+	backend := func(name string, port int32) v1beta1.IngressBackend {
+		return v1beta1.IngressBackend{ServiceName: name, ServicePort: intstr.IntOrString{IntVal: port}}
+	}
+	ingress := v1beta1.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "secure",
+			Namespace: "mock",
+			Annotations: map[string]string{
+				BackendProtocolAnnotation:      "HTTPS",
+				BackendTLSServerNameAnnotation: "foo.example.com",
+			},
+		},
+		Spec: v1beta1.IngressSpec{
+			Backend: &v1beta1.IngressBackend{ServiceName: "foo", ServicePort: intstr.IntOrString{IntVal: 8443}},
+			Rules: []v1beta1.IngressRule{
+				{
+					Host: "my.host.com",
+					IngressRuleValue: v1beta1.IngressRuleValue{
+						HTTP: &v1beta1.HTTPIngressRuleValue{
+							Paths: []v1beta1.HTTPIngressPath{
+								{Path: "/a", Backend: backend("foo", 8443)},
+								{Path: "/b", Backend: backend("foo", 9443)},
+								{Path: "/c", Backend: backend("bar", 443)},
+								{Path: "/d", Backend: v1beta1.IngressBackend{ServiceName: "baz", ServicePort: intstr.FromString("https")}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	plain := ingress
+	plain.Annotations = nil
+
+	cfgs := map[string]*model.Config{}
+	ConvertIngressDestinationRule(plain, "mydomain", cfgs, nil)
+	if len(cfgs) != 0 {
+		t.Fatalf("DestinationRules for ingress without backend protocol, expected 0 got %d", len(cfgs))
+	}
+
+	ConvertIngressDestinationRule(ingress, "mydomain", cfgs, nil)
+	if len(cfgs) != 2 {
+		t.Fatalf("DestinationRules, expected 2 got %d", len(cfgs))
+	}
+
+	expectedPorts := map[string][]uint32{
+		"foo.mock.svc.mydomain": {8443, 9443},
+		"bar.mock.svc.mydomain": {443},
+	}
+	for host, ports := range expectedPorts {
+		cfg, f := cfgs[host]
+		if !f {
+			t.Fatalf("missing DestinationRule for %s", host)
+		}
+		dr := cfg.Spec.(*networking.DestinationRule)
+		if dr.Host != host {
+			t.Errorf("unexpected host %s, expected %s", dr.Host, host)
+		}
+		if len(dr.TrafficPolicy.PortLevelSettings) != len(ports) {
+			t.Fatalf("unexpected port level settings for %s: %v", host, dr.TrafficPolicy.PortLevelSettings)
+		}
+		for i, setting := range dr.TrafficPolicy.PortLevelSettings {
+			if setting.Port.GetNumber() != ports[i] {
+				t.Errorf("unexpected port %d for %s, expected %d", setting.Port.GetNumber(), host, ports[i])
+			}
+			if setting.Tls.Mode != networking.TLSSettings_SIMPLE || setting.Tls.Sni != "foo.example.com" {
+				t.Errorf("unexpected TLS settings for %s: %v", host, setting.Tls)
+			}
+		}
+	}
+}
+
 func TestDecodeIngressRuleName(t *testing.T) {
 	cases := []struct {
 		ingressName string
@@ -219,9 +350,98 @@ func TestIngressClass(t *testing.T) {
 			ing.Annotations["kubernetes.io/ingress.class"] = c.ingressClass
 		}
 
-		if c.shouldProcess != shouldProcessIngress(&mesh, &ing) {
+		if c.shouldProcess != shouldProcessIngress(&mesh, &ing, nil) {
 			t.Errorf("shouldProcessIngress(<ingress of class '%s'>) => %v, want %v",
 				c.ingressClass, !c.shouldProcess, c.shouldProcess)
 		}
 	}
 }
+
+func TestConvertIngressPathTypes(t *testing.T) {
+	paths := func(paths ...string) v1beta1.IngressRuleValue {
+		out := &v1beta1.HTTPIngressRuleValue{}
+		for _, p := range paths {
+			out.Paths = append(out.Paths, v1beta1.HTTPIngressPath{
+				Path:    p,
+				Backend: v1beta1.IngressBackend{ServiceName: "foo", ServicePort: intstr.IntOrString{IntVal: 8000}},
+			})
+		}
+		return v1beta1.IngressRuleValue{HTTP: out}
+	}
+	exact := func(s string) *networking.HTTPMatchRequest {
+		return &networking.HTTPMatchRequest{Uri: &networking.StringMatch{MatchType: &networking.StringMatch_Exact{Exact: s}}}
+	}
+	prefix := func(s string) *networking.HTTPMatchRequest {
+		return &networking.HTTPMatchRequest{Uri: &networking.StringMatch{MatchType: &networking.StringMatch_Prefix{Prefix: s}}}
+	}
+	regex := func(s string) *networking.HTTPMatchRequest {
+		return &networking.HTTPMatchRequest{Uri: &networking.StringMatch{MatchType: &networking.StringMatch_Regex{Regex: s}}}
+	}
+
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		paths       []string
+		matches     [][]*networking.HTTPMatchRequest
+		rewrite     string
+	}{
+		{
+			name:    "implementation specific",
+			paths:   []string{"/foo", "/bar/*"},
+			matches: [][]*networking.HTTPMatchRequest{{exact("/foo")}, {prefix("/bar")}},
+		},
+		{
+			name:        "exact",
+			annotations: map[string]string{PathTypeAnnotation: PathTypeExact},
+			paths:       []string{"/foo/*"},
+			matches:     [][]*networking.HTTPMatchRequest{{exact("/foo/*")}},
+		},
+		{
+			name:        "prefix sorted by length",
+			annotations: map[string]string{PathTypeAnnotation: PathTypePrefix, RewriteTargetAnnotation: "/api"},
+			paths:       []string{"/", "/foo/", "/foo/bar"},
+			matches: [][]*networking.HTTPMatchRequest{
+				{exact("/foo/bar"), prefix("/foo/bar/")},
+				{exact("/foo"), prefix("/foo/")},
+				{prefix("/")},
+			},
+			rewrite: "/api/",
+		},
+		{
+			name:        "regex",
+			annotations: map[string]string{UseRegexAnnotation: "true", RewriteTargetAnnotation: "/$2"},
+			paths:       []string{"/foo/[0-9]+"},
+			matches:     [][]*networking.HTTPMatchRequest{{regex("/foo/[0-9]+.*")}},
+		},
+		{
+			name:        "invalid path type",
+			annotations: map[string]string{PathTypeAnnotation: "Suffix"},
+			paths:       []string{"/foo.*"},
+			matches:     [][]*networking.HTTPMatchRequest{{prefix("/foo")}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ingress := v1beta1.Ingress{
+				ObjectMeta: meta_v1.ObjectMeta{Name: "paths", Namespace: "mock", Annotations: c.annotations},
+				Spec: v1beta1.IngressSpec{
+					Rules: []v1beta1.IngressRule{{Host: "my.host.com", IngressRuleValue: paths(c.paths...)}},
+				},
+			}
+			cfgs := map[string]*model.Config{}
+			ConvertIngressVirtualService(ingress, "mydomain", cfgs, nil)
+			routes := cfgs["my.host.com"].Spec.(*networking.VirtualService).Http
+			if len(routes) != len(c.matches) {
+				t.Fatalf("got %d routes, want %d: %v", len(routes), len(c.matches), routes)
+			}
+			for i, r := range routes {
+				if !reflect.DeepEqual(r.Match, c.matches[i]) {
+					t.Errorf("route %d: got matches %v, want %v", i, r.Match, c.matches[i])
+				}
+				if got := r.Rewrite.GetUri(); got != c.rewrite {
+					t.Errorf("route %d: got rewrite %q, want %q", i, got, c.rewrite)
+				}
+			}
+		})
+	}
+}
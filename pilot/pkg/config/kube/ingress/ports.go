@@ -0,0 +1,123 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingress
+
+import (
+	"sync"
+
+	"k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+
+	"istio.io/istio/pilot/pkg/serviceregistry/kube"
+)
+
+// ServicePortResolver returns the number of the named port of a service, and false if the service or
+// the port are not found.
+type ServicePortResolver func(namespace, service, port string) (uint32, bool)
+
+// backendPort returns the number of the service port of the Ingress backend. Named ports are resolved
+// with the resolver, and skipped without one.
+func backendPort(backend *v1beta1.IngressBackend, namespace string, ports ServicePortResolver) (uint32, bool) {
+	if backend.ServicePort.Type == intstr.Int {
+		return uint32(backend.ServicePort.IntVal), true
+	}
+	if ports == nil {
+		return 0, false
+	}
+	return ports(namespace, backend.ServiceName, backend.ServicePort.StrVal)
+}
+
+// servicePortCache resolves the named ports of the Ingress backends from the Services of a lister, and
+// caches them until the Service changes.
+type servicePortCache struct {
+	mu       sync.Mutex
+	services listerv1.ServiceLister
+	// ports are the resolved ports of each service, by name. A missing service or port is cached as 0.
+	ports map[string]map[string]uint32
+}
+
+func newServicePortCache(services listerv1.ServiceLister) *servicePortCache {
+	return &servicePortCache{
+		services: services,
+		ports:    make(map[string]map[string]uint32),
+	}
+}
+
+// resolver returns the resolver of the named ports, nil without a cache.
+func (c *servicePortCache) resolver() ServicePortResolver {
+	if c == nil {
+		return nil
+	}
+	return c.resolve
+}
+
+func (c *servicePortCache) resolve(namespace, service, port string) (uint32, bool) {
+	key := kube.KeyFunc(service, namespace)
+	// The lock is held while the port is looked up, so that a concurrent invalidation isn't overwritten
+	// by the port of the previous version of the Service.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if number, f := c.ports[key][port]; f {
+		return number, number != 0
+	}
+
+	number := uint32(0)
+	if svc, err := c.services.Services(namespace).Get(service); err == nil {
+		for _, p := range svc.Spec.Ports {
+			if p.Name == port {
+				number = uint32(p.Port)
+				break
+			}
+		}
+	}
+
+	if c.ports[key] == nil {
+		c.ports[key] = make(map[string]uint32)
+	}
+	c.ports[key][port] = number
+	return number, number != 0
+}
+
+// invalidate forgets the ports of the service, and returns true if they were resolved for an Ingress.
+func (c *servicePortCache) invalidate(namespace, service string) bool {
+	key := kube.KeyFunc(service, namespace)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, f := c.ports[key]
+	delete(c.ports, key)
+	return f
+}
+
+// referencesNamedPort returns true if a backend of the Ingress refers to a named port of the service.
+func referencesNamedPort(ingress *v1beta1.Ingress, service string) bool {
+	named := func(backend *v1beta1.IngressBackend) bool {
+		return backend != nil && backend.ServiceName == service && backend.ServicePort.Type == intstr.String
+	}
+	if named(ingress.Spec.Backend) {
+		return true
+	}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for i := range rule.HTTP.Paths {
+			if named(&rule.HTTP.Paths[i].Backend) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingress
+
+import (
+	"testing"
+
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/pkg/model"
+	kubecontroller "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
+)
+
+func TestConvertIngressNamedPorts(t *testing.T) {
+	ingress := v1beta1.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "named",
+			Namespace: "mock",
+		},
+		Spec: v1beta1.IngressSpec{
+			Rules: []v1beta1.IngressRule{{
+				Host: "my.host.com",
+				IngressRuleValue: v1beta1.IngressRuleValue{
+					HTTP: &v1beta1.HTTPIngressRuleValue{
+						Paths: []v1beta1.HTTPIngressPath{{
+							Path:    "/test",
+							Backend: v1beta1.IngressBackend{ServiceName: "foo", ServicePort: intstr.FromString("http")},
+						}},
+					},
+				},
+			}},
+		},
+	}
+	service := &coreV1.Service{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "foo", Namespace: "mock"},
+		Spec: coreV1.ServiceSpec{Ports: []coreV1.ServicePort{
+			{Name: "grpc", Port: 9090},
+			{Name: "http", Port: 8080},
+		}},
+	}
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	ports := newServicePortCache(listerv1.NewServiceLister(store))
+
+	routes := func(resolver ServicePortResolver) []*networking.HTTPRoute {
+		cfgs := map[string]*model.Config{}
+		ConvertIngressVirtualService(ingress, "mydomain", cfgs, resolver)
+		return cfgs["my.host.com"].Spec.(*networking.VirtualService).Http
+	}
+
+	if got := routes(nil); len(got) != 0 {
+		t.Errorf("got routes %v without resolver, want the named port skipped", got)
+	}
+	if got := routes(ports.resolve); len(got) != 0 {
+		t.Errorf("got routes %v without service, want the named port skipped", got)
+	}
+
+	// the missing service is cached until it is added.
+	if err := store.Add(service); err != nil {
+		t.Fatal(err)
+	}
+	if got := routes(ports.resolve); len(got) != 0 {
+		t.Errorf("got routes %v before invalidation, want the cached missing port", got)
+	}
+	if !ports.invalidate("mock", "foo") {
+		t.Error("invalidate() should report the service as referenced")
+	}
+	got := routes(ports.resolve)
+	if len(got) != 1 || got[0].Route[0].Destination.Port.Number != 8080 {
+		t.Fatalf("got routes %v, want the route to port 8080", got)
+	}
+
+	// the port is re-resolved once the service changed.
+	service.Spec.Ports[1].Port = 8081
+	if err := store.Update(service); err != nil {
+		t.Fatal(err)
+	}
+	ports.invalidate("mock", "foo")
+	if got := routes(ports.resolve); len(got) != 1 || got[0].Route[0].Destination.Port.Number != 8081 {
+		t.Errorf("got routes %v, want the route to port 8081", got)
+	}
+
+	if ports.invalidate("mock", "bar") {
+		t.Error("invalidate() should not report an unreferenced service")
+	}
+	if !referencesNamedPort(&ingress, "foo") || referencesNamedPort(&ingress, "bar") {
+		t.Error("referencesNamedPort() should only report the backends with named ports of the service")
+	}
+}
+
+func TestControllerSharedServices(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	factory := informers.NewSharedInformerFactory(client, 0)
+	ctl := NewController(client, &meshconfig.MeshConfig{}, kubecontroller.Options{InformerFactory: factory}).(*controller)
+	if ctl.serviceInformer != factory.Core().V1().Services().Informer() {
+		t.Error("the controller should share the Services informer of the kube registry")
+	}
+	if ctl.ports.resolver() == nil {
+		t.Error("the named ports should be resolved from the shared Services")
+	}
+
+	ctl = NewController(client, &meshconfig.MeshConfig{}, kubecontroller.Options{}).(*controller)
+	if ctl.serviceInformer != nil || ctl.ports.resolver() != nil {
+		t.Error("the named ports should not be resolved without shared informers")
+	}
+}
@@ -50,12 +50,18 @@ const (
 	ingressElectionID = "istio-ingress-controller-leader"
 )
 
-// StatusSyncer keeps the status IP in each Ingress resource updated
+// StatusSyncer keeps the status IP in each Ingress resource updated with the addresses of the
+// ingress gateway, so that users and tools like external-dns see where the Ingresses are reachable.
+// Only the leader among the replicas updates the status.
 type StatusSyncer struct {
 	client kubernetes.Interface
 
 	ingressClass        string
 	defaultIngressClass string
+	// classes are the IngressClasses selecting the Ingresses processed by Istio, shared with the controller.
+	classes *ingressClasses
+	// namespace is the namespace of the Ingresses, all if empty.
+	namespace string
 
 	// Name of service (ingressgateway default) to find the IP
 	ingressService string
@@ -68,7 +74,10 @@ type StatusSyncer struct {
 
 // Run the syncer until stopCh is closed
 func (s *StatusSyncer) Run(stopCh <-chan struct{}) {
+	// The queue runs for the lifetime of the syncer, across leaderships, as it can't be restarted.
+	go s.queue.Run(stopCh)
 	go s.informer.Run(stopCh)
+	go s.classes.run(s.client, s.namespace, stopCh)
 	go s.elector.Run(context.Background())
 	<-stopCh
 	// TODO: should we remove current IPs on shutting down?
@@ -112,6 +121,8 @@ func NewStatusSyncer(mesh *meshconfig.MeshConfig,
 		queue:               queue,
 		ingressClass:        ingressClass,
 		defaultIngressClass: defaultIngressClass,
+		classes:             ingressClassesFor(client, options.WatchedNamespace),
+		namespace:           options.WatchedNamespace,
 		ingressService:      mesh.IngressService,
 		handler:             handler,
 	}
@@ -119,7 +130,6 @@ func NewStatusSyncer(mesh *meshconfig.MeshConfig,
 	callbacks := leaderelection.LeaderCallbacks{
 		OnStartedLeading: func(ctx context.Context) {
 			log.Infof("I am the new status update leader")
-			go st.queue.Run(ctx.Done())
 			err := wait.PollUntil(updateInterval, func() (bool, error) {
 				st.queue.Push(kube.NewTask(st.handler.Apply, "Start leading", model.EventUpdate))
 				return false, nil
@@ -165,11 +175,22 @@ func NewStatusSyncer(mesh *meshconfig.MeshConfig,
 	})
 
 	if err != nil {
-		log.Errorf("unexpected error starting leader election: %v", err)
+		return nil, fmt.Errorf("unexpected error starting leader election: %v", err)
 	}
 
 	st.elector = le
 
+	// The Ingresses added or updated while leading are updated right away, rather than at the next
+	// periodic update.
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			st.onIngressEvent(obj, model.EventAdd)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			st.onIngressEvent(cur, model.EventUpdate)
+		},
+	})
+
 	// Register handler at the beginning
 	handler.Append(func(obj interface{}, event model.Event) error {
 		addrs, err := st.runningAddresses(ingressNamespace)
@@ -177,40 +198,58 @@ func NewStatusSyncer(mesh *meshconfig.MeshConfig,
 			return err
 		}
 
+		if ing, ok := obj.(*v1beta1.Ingress); ok {
+			st.updateIngressStatus(ing, sliceToStatus(addrs))
+			return nil
+		}
 		return st.updateStatus(sliceToStatus(addrs))
 	})
 
 	return &st, nil
 }
 
+// onIngressEvent queues the update of the status of the Ingress, if this replica is the leader.
+func (s *StatusSyncer) onIngressEvent(obj interface{}, event model.Event) {
+	if s.elector == nil || !s.elector.IsLeader() {
+		return
+	}
+	s.queue.Push(kube.NewTask(s.handler.Apply, obj, event))
+}
+
 // updateStatus updates ingress status with the list of IP
 func (s *StatusSyncer) updateStatus(status []coreV1.LoadBalancerIngress) error {
 	ingressStore := s.informer.GetStore()
 	for _, obj := range ingressStore.List() {
-		currIng := obj.(*v1beta1.Ingress)
-		if !classIsValid(currIng, s.ingressClass, s.defaultIngressClass) {
-			continue
-		}
+		s.updateIngressStatus(obj.(*v1beta1.Ingress), status)
+	}
 
-		curIPs := currIng.Status.LoadBalancer.Ingress
-		sort.SliceStable(status, lessLoadBalancerIngress(status))
-		sort.SliceStable(curIPs, lessLoadBalancerIngress(curIPs))
+	return nil
+}
 
-		if ingressSliceEqual(status, curIPs) {
-			log.Infof("skipping update of Ingress %v/%v (no change)", currIng.Namespace, currIng.Name)
-			return nil
-		}
+// updateIngressStatus updates the status of the Ingress with the list of IP, if it is processed by Istio.
+func (s *StatusSyncer) updateIngressStatus(currIng *v1beta1.Ingress, status []coreV1.LoadBalancerIngress) {
+	if !classIsValid(currIng, s.ingressClass, s.defaultIngressClass, s.classes) {
+		return
+	}
 
-		currIng.Status.LoadBalancer.Ingress = status
+	// The informer store must not be modified.
+	currIng = currIng.DeepCopy()
+	curIPs := currIng.Status.LoadBalancer.Ingress
+	sort.SliceStable(status, lessLoadBalancerIngress(status))
+	sort.SliceStable(curIPs, lessLoadBalancerIngress(curIPs))
 
-		ingClient := s.client.ExtensionsV1beta1().Ingresses(currIng.Namespace)
-		_, err := ingClient.UpdateStatus(currIng)
-		if err != nil {
-			log.Warnf("error updating ingress status: %v", err)
-		}
+	if ingressSliceEqual(status, curIPs) {
+		log.Debugf("skipping update of Ingress %v/%v (no change)", currIng.Namespace, currIng.Name)
+		return
 	}
 
-	return nil
+	currIng.Status.LoadBalancer.Ingress = status
+
+	ingClient := s.client.ExtensionsV1beta1().Ingresses(currIng.Namespace)
+	_, err := ingClient.UpdateStatus(currIng)
+	if err != nil {
+		log.Warnf("error updating ingress status: %v", err)
+	}
 }
 
 // runningAddresses returns a list of IP addresses and/or FQDN where the
@@ -344,12 +383,21 @@ func convertIngressControllerMode(mode meshconfig.MeshConfig_IngressControllerMo
 
 // classIsValid returns true if the given Ingress either doesn't specify
 // the ingress.class annotation, or it's set to the configured in the
-// ingress controller.
-func classIsValid(ing *v1beta1.Ingress, controller, defClass string) bool {
-	// ingress fetched through annotation.
+// ingress controller. The IngressClasses of the Istio controller are valid, and
+// the default IngressClass decides for the Ingresses without annotation.
+func classIsValid(ing *v1beta1.Ingress, controller, defClass string, classes *ingressClasses) bool {
+	// ingress fetched through annotation, or spec.ingressClassName.
 	var ingress string
-	if ing != nil && len(ing.GetAnnotations()) != 0 {
-		ingress = ing.GetAnnotations()[kube.IngressClassAnnotation]
+	if ing != nil {
+		ingress, _ = classes.className(ing)
+	}
+
+	if ingress == "" {
+		if c, f := classes.defaultController(); f {
+			return c == IstioIngressController
+		}
+	} else if c, _ := classes.controller(ingress); c == IstioIngressController {
+		return true
 	}
 
 	// we have 2 valid combinations
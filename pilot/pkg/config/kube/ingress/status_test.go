@@ -191,7 +191,7 @@ func TestConvertIngressControllerMode(t *testing.T) {
 		ingressClass, defaultIngressClass := convertIngressControllerMode(c.Mode, "istio")
 
 		ing := makeAnnotatedIngress(c.Annotation)
-		if ignore := classIsValid(ing, ingressClass, defaultIngressClass); ignore != c.Ignore {
+		if ignore := classIsValid(ing, ingressClass, defaultIngressClass, nil); ignore != c.Ignore {
 			t.Errorf("convertIngressControllerMode(%q, %q), with Ingress annotation %q => "+
 				"Got ignore %v, want %v", c.Mode, "istio", c.Annotation, c.Ignore, ignore)
 		}
@@ -258,3 +258,46 @@ func TestRunningAddressesWithPod(t *testing.T) {
 		t.Errorf("Address is not correctly set to node ip %v %v", address, nodeIP)
 	}
 }
+
+func TestUpdateStatus(t *testing.T) {
+	client := makeFakeClient()
+	syncer, err := makeStatusSyncer(t, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := []coreV1.LoadBalancerIngress{{IP: serviceIP}}
+	ingresses := []*extensions.Ingress{
+		// Already up to date, it must not stop the update of the next ones.
+		{ObjectMeta: metaV1.ObjectMeta{Name: "current", Namespace: testNamespace},
+			Status: extensions.IngressStatus{LoadBalancer: coreV1.LoadBalancerStatus{Ingress: status}}},
+		{ObjectMeta: metaV1.ObjectMeta{Name: "new", Namespace: testNamespace}},
+		{ObjectMeta: metaV1.ObjectMeta{Name: "nginx", Namespace: testNamespace,
+			Annotations: map[string]string{kube.IngressClassAnnotation: "nginx"}}},
+	}
+	for _, ing := range ingresses {
+		if _, err := client.ExtensionsV1beta1().Ingresses(testNamespace).Create(ing); err != nil {
+			t.Fatal(err)
+		}
+		if err := syncer.informer.GetStore().Add(ing); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := syncer.updateStatus(status); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]int{"current": 1, "new": 1, "nginx": 0} {
+		ing, err := client.ExtensionsV1beta1().Ingresses(testNamespace).Get(name, metaV1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ing.Status.LoadBalancer.Ingress; len(got) != want || (want == 1 && got[0].IP != serviceIP) {
+			t.Errorf("status of Ingress %s is %v, want %d addresses", name, got, want)
+		}
+	}
+	if len(ingresses[1].Status.LoadBalancer.Ingress) != 0 {
+		t.Error("the Ingress of the informer store should not be modified")
+	}
+}
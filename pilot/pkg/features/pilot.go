@@ -53,6 +53,16 @@ var (
 	// For larger clusters it can increase memory use and GC - useful for small tests.
 	DebugConfigs = env.RegisterBoolVar("PILOT_DEBUG_ADSZ_CONFIG", false, "").Get()
 
+	// DebugPushDiff controls recording, for each push to a proxy, the names of the resources added,
+	// removed and changed since the previous push, for /debug/push_diff.
+	// It costs decoding each pushed resource, so it is disabled by default.
+	DebugPushDiff = env.RegisterBoolVar(
+		"PILOT_DEBUG_PUSH_DIFF",
+		false,
+		"If enabled, pilot records the names of the resources changed by each push to a proxy, "+
+			"served by /debug/push_diff.",
+	).Get()
+
 	DebounceAfter = env.RegisterDurationVar(
 		"PILOT_DEBOUNCE_AFTER",
 		100*time.Millisecond,
@@ -253,6 +263,124 @@ var (
 			"but the older, deprecated regex field. This should only be enabled to support "+
 			"legacy deployments that have not yet been migrated to the new safe regular expressions.",
 	)
+
+	PodCacheReconcileInterval = env.RegisterDurationVar(
+		"PILOT_POD_CACHE_RECONCILE_INTERVAL",
+		time.Minute*1,
+		"The interval at which the Kubernetes registry validates its pod IP index against the pod informer "+
+			"and evicts stale entries left behind by missed delete events. Set to 0 to disable reconciliation.",
+	).Get()
+
+	OrphanedEndpointsCheckInterval = env.RegisterDurationVar(
+		"PILOT_ORPHANED_ENDPOINTS_CHECK_INTERVAL",
+		time.Minute*1,
+		"The interval at which the Kubernetes registry looks for Endpoints without Service and Services without "+
+			"Endpoints, reported in the pilot_k8s_orphaned_endpoints metric. Set to 0 to disable the check.",
+	).Get()
+
+	ExcludedPodsCheckInterval = env.RegisterDurationVar(
+		"PILOT_EXCLUDED_PODS_CHECK_INTERVAL",
+		time.Minute*1,
+		"The interval at which the Kubernetes registry counts the pods excluded from the mesh, by reason, "+
+			"reported in the pilot_k8s_excluded_pods metric. Set to 0 to disable the check.",
+	).Get()
+
+	ExternalNameResolveInterval = env.RegisterDurationVar(
+		"PILOT_EXTERNAL_NAME_RESOLVE_INTERVAL",
+		30*time.Second,
+		"The interval at which the Kubernetes registry resolves again the external names of the ExternalName "+
+			"Services in STATIC mode, which are resolved in the background. Set to 0 to only resolve them "+
+			"when the Services change.",
+	).Get()
+
+	EndpointChurnWindow = env.RegisterDurationVar(
+		"PILOT_ENDPOINT_CHURN_WINDOW",
+		5*time.Minute,
+		"The rolling window over which the Kubernetes registry counts the endpoint addresses added to and "+
+			"removed from each service, reported for the services with the most churn in the "+
+			"pilot_k8s_top_endpoint_churn metric. Set to 0 to disable the tracking.",
+	).Get()
+
+	EndpointChurnWarnThreshold = env.RegisterIntVar(
+		"PILOT_ENDPOINT_CHURN_WARN_THRESHOLD",
+		100,
+		"The number of endpoint addresses added to or removed from a service within PILOT_ENDPOINT_CHURN_WINDOW "+
+			"above which Pilot logs a warning, once per window. Set to 0 to disable the warnings.",
+	).Get()
+
+	EndpointTTL = env.RegisterDurationVar(
+		"PILOT_ENDPOINT_TTL",
+		0,
+		"The time the proxies keep using the endpoints pushed by Pilot without hearing from it again, after "+
+			"which the endpoints are considered stale and unhealthy. Pilot pushes again the endpoints not "+
+			"pushed for half the TTL, so only the proxies cut off from Pilot longer than the TTL stop using "+
+			"them. Set to 0 to disable the TTL, the proxies keep the last endpoints pushed forever. Used when "+
+			"the endpointTtl of the mesh config is not set.",
+	).Get()
+
+	EndpointMergePolicy = env.RegisterStringVar(
+		"PILOT_ENDPOINT_MERGE_POLICY",
+		"merge-all",
+		"How the aggregate registry combines the instances of a service found in multiple registries or "+
+			"clusters. merge-all keeps all the instances, dedupe-by-ip+network keeps a single instance per "+
+			"address, port and network, from the first registry, and prefer-local deduplicates the same way "+
+			"but prefers the instances of the local cluster.",
+	).Get()
+
+	EnableRegistryEvents = env.RegisterBoolVar(
+		"PILOT_ENABLE_K8S_REGISTRY_EVENTS",
+		false,
+		"If enabled, the Kubernetes registry of the local cluster records Kubernetes Events on the Services "+
+			"and Pods with anomalies, e.g. endpoints without pod, unresolved target ports or nodes not found "+
+			"for the locality, so that they show up in kubectl describe.",
+	).Get()
+
+	MaxConnectedProxies = env.RegisterIntVar(
+		"PILOT_MAX_CONNECTED_PROXIES",
+		0,
+		"The maximum number of proxies allowed to connect to a single Pilot instance. Once reached, new "+
+			"connections are rejected with a retry hint so the proxies reconnect to other replicas. "+
+			"Set to 0 for no limit.",
+	).Get()
+
+	RejectedConnectionRetryAfter = env.RegisterDurationVar(
+		"PILOT_REJECTED_CONNECTION_RETRY_AFTER",
+		10*time.Second,
+		"The delay suggested to proxies whose connection was rejected because PILOT_MAX_CONNECTED_PROXIES "+
+			"was reached, before they retry.",
+	).Get()
+
+	MaxClustersPerProxy = env.RegisterIntVar(
+		"PILOT_MAX_CLUSTERS_PER_PROXY",
+		0,
+		"The maximum number of clusters pushed to a proxy, overridden by the sidecar.istio.io/maxClusters "+
+			"annotation. Beyond it the clusters of the namespace of the proxy are kept first, and the proxy "+
+			"is reported as degraded. Set to 0 for no limit.",
+	).Get()
+
+	MaxRoutesPerProxy = env.RegisterIntVar(
+		"PILOT_MAX_ROUTES_PER_PROXY",
+		0,
+		"The maximum number of virtual hosts pushed to a proxy, across its route configurations, overridden "+
+			"by the sidecar.istio.io/maxRoutes annotation. Set to 0 for no limit.",
+	).Get()
+
+	MaxEndpointsPerProxy = env.RegisterIntVar(
+		"PILOT_MAX_ENDPOINTS_PER_PROXY",
+		0,
+		"The maximum number of endpoints pushed to a proxy, shared evenly by its clusters, overridden by the "+
+			"sidecar.istio.io/maxEndpoints annotation. The healthy endpoints of the closest localities are "+
+			"kept first. Set to 0 for no limit.",
+	).Get()
+
+	IngressCertificateMode = env.RegisterStringVar(
+		"PILOT_INGRESS_CERTIFICATE_MODE",
+		"file",
+		"How the Gateway generated for a Kubernetes Ingress gets the certificates of its TLS blocks. file, the "+
+			"default, uses the certificates mounted in /etc/istio/ingress-certs. sds sets the credentialName of "+
+			"the servers to the Ingress TLS secretName, so that the ingress gateways running with SDS fetch the "+
+			"secret, which must be in their namespace.",
+	).Get()
 )
 
 var (
@@ -185,6 +185,35 @@ func (l *PodPortList) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// PodPortMap maps the names of the container ports of a pod to their number. Like PodPortList, it is
+// serialized as a string, so that it can be set by the ISTIO_META_POD_PORT_MAP env variable of the
+// injected proxies.
+type PodPortMap map[string]int
+
+func (m PodPortMap) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(map[string]int(m))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(b))
+}
+
+func (m *PodPortMap) UnmarshalJSON(data []byte) error {
+	var pm map[string]int
+	pms, err := strconv.Unquote(string(data))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(pms), &pm); err != nil {
+		return err
+	}
+	*m = pm
+	return nil
+}
+
 // NodeMetadata defines the metadata associated with a proxy
 // Fields should not be assumed to exist on the proxy, especially newly added fields which will not exist
 // on older versions.
@@ -231,6 +260,11 @@ type NodeMetadata struct {
 	// RequestedNetworkView specifies the networks that the proxy wants to see
 	RequestedNetworkView StringList `json:"REQUESTED_NETWORK_VIEW,omitempty"`
 
+	// OnDemandHosts specifies additional hosts imported in the SidecarScope of the proxy, in the
+	// namespace/host format of the egress hosts of the Sidecar config. Only the services visible to
+	// the config namespace of the proxy are imported.
+	OnDemandHosts StringList `json:"ON_DEMAND_HOSTS,omitempty"`
+
 	// ExchangeKeys specifies a list of metadata keys that should be used for Node Metadata Exchange.
 	ExchangeKeys StringList `json:"EXCHANGE_KEYS,omitempty"`
 
@@ -251,6 +285,10 @@ type NodeMetadata struct {
 	// PodPorts defines the ports on a pod. This is used to lookup named ports.
 	PodPorts PodPortList `json:"POD_PORTS,omitempty"`
 
+	// PodPortMap maps the names of the container ports of the pod to their number. It resolves the named
+	// target ports of the services of the proxies whose metadata lack PodPorts.
+	PodPortMap PodPortMap `json:"POD_PORT_MAP,omitempty"`
+
 	// CanonicalTelemetryService specifies the service name to use for all node telemetry.
 	CanonicalTelemetryService string `json:"CANONICAL_TELEMETRY_SERVICE,omitempty"`
 
@@ -266,6 +304,12 @@ type NodeMetadata struct {
 	StatsInclusionRegexps  string `json:"sidecar.istio.io/statsInclusionRegexps,omitempty"`
 	StatsInclusionSuffixes string `json:"sidecar.istio.io/statsInclusionSuffixes,omitempty"`
 
+	// MaxClusters, MaxRoutes and MaxEndpoints override the maximum numbers of clusters, virtual hosts
+	// and endpoints pushed to the proxy, PILOT_MAX_*_PER_PROXY of Pilot.
+	MaxClusters  string `json:"sidecar.istio.io/maxClusters,omitempty"`
+	MaxRoutes    string `json:"sidecar.istio.io/maxRoutes,omitempty"`
+	MaxEndpoints string `json:"sidecar.istio.io/maxEndpoints,omitempty"`
+
 	// TLSServerCertChain is the absolute path to server cert-chain file
 	TLSServerCertChain string `json:"TLS_SERVER_CERT_CHAIN,omitempty"`
 	// TLSServerKey is the absolute path to server private key file
@@ -448,6 +492,9 @@ func (node *Proxy) GetRouterMode() RouterMode {
 func (node *Proxy) SetSidecarScope(ps *PushContext) {
 	if node.Type == SidecarProxy {
 		node.SidecarScope = ps.getSidecarScope(node, node.WorkloadLabels)
+		if len(node.Metadata.OnDemandHosts) > 0 {
+			node.SidecarScope = ps.sidecarScopeWithHosts(node.SidecarScope, node.ConfigNamespace, node.Metadata.OnDemandHosts)
+		}
 	} else {
 		// Gateways should just have a default scope with egress: */*
 		node.SidecarScope = DefaultSidecarScopeForNamespace(ps, node.ConfigNamespace)
@@ -115,6 +115,42 @@ func TestStringList(t *testing.T) {
 	}
 }
 
+func TestPodPortMap(t *testing.T) {
+	cases := []struct {
+		name   string
+		in     string
+		expect model.PodPortMap
+	}{
+		{"no port", `"{}"`, model.PodPortMap{}},
+		{"one port", `"{\"http\":8080}"`, model.PodPortMap{"http": 8080}},
+		{"two ports", `"{\"grpc\":9090,\"http\":8080}"`, model.PodPortMap{"http": 8080, "grpc": 9090}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var out model.PodPortMap
+			if err := json.Unmarshal([]byte(tt.in), &out); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(out, tt.expect) {
+				t.Fatalf("Expected %v, got %v", tt.expect, out)
+			}
+			b, err := json.Marshal(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(string(b), tt.in) {
+				t.Fatalf("Expected %v, got %v", tt.in, string(b))
+			}
+		})
+	}
+
+	// the map is serialized as a string.
+	var out model.PodPortMap
+	if err := json.Unmarshal([]byte(`{"http":8080}`), &out); err == nil {
+		t.Errorf("Expected an error for an unquoted map, got %v", out)
+	}
+}
+
 func TestPodPortList(t *testing.T) {
 	cases := []struct {
 		name   string
@@ -14,6 +14,10 @@
 
 package model
 
+import (
+	"istio.io/istio/pkg/config/host"
+)
+
 // Controller defines an event controller loop.  Proxy agent registers itself
 // with the controller loop and receives notifications on changes to the
 // service topology or changes to the configuration artifacts.
@@ -28,13 +32,16 @@ package model
 // Handlers execute on the single worker queue in the order they are appended.
 // Handlers receive the notification event and the associated object.  Note
 // that all handlers must be appended before starting the controller.
+//
+// A handler appended with filters is only invoked for the events matching
+// any of the filters, see HandlerFilter.
 type Controller interface {
 	// AppendServiceHandler notifies about changes to the service catalog.
-	AppendServiceHandler(f func(*Service, Event)) error
+	AppendServiceHandler(f func(*Service, Event), filters ...HandlerFilter) error
 
 	// AppendInstanceHandler notifies about changes to the service instances
 	// for a service.
-	AppendInstanceHandler(f func(*ServiceInstance, Event)) error
+	AppendInstanceHandler(f func(*ServiceInstance, Event), filters ...HandlerFilter) error
 
 	// Run until a signal is received
 	Run(stop <-chan struct{})
@@ -68,3 +75,105 @@ func (event Event) String() string {
 	}
 	return out
 }
+
+// HandlerFilter selects the events of the services, or of the instances of
+// the services, a handler is notified about. An empty field matches
+// everything, so the zero filter matches all events.
+type HandlerFilter struct {
+	// Namespaces of the services.
+	Namespaces []string
+
+	// Hostnames of the services, which may be wildcarded, e.g. *.example.com.
+	Hostnames []host.Name
+
+	// Events to notify about.
+	Events []Event
+}
+
+// Matches returns true if the event of the service matches all the fields of
+// the filter.
+func (f HandlerFilter) Matches(svc *Service, event Event) bool {
+	if len(f.Events) > 0 && !containsEvent(f.Events, event) {
+		return false
+	}
+	if len(f.Namespaces) == 0 && len(f.Hostnames) == 0 {
+		return true
+	}
+	if svc == nil {
+		return false
+	}
+	if len(f.Namespaces) > 0 && !containsString(f.Namespaces, svc.Attributes.Namespace) {
+		return false
+	}
+	if len(f.Hostnames) > 0 && !subsetOfAny(svc.Hostname, f.Hostnames) {
+		return false
+	}
+	return true
+}
+
+func subsetOfAny(hostname host.Name, patterns []host.Name) bool {
+	for _, pattern := range patterns {
+		if hostname.SubsetOf(pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsEvent(events []Event, event Event) bool {
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAnyFilter(filters []HandlerFilter, svc *Service, event Event) bool {
+	for _, filter := range filters {
+		if filter.Matches(svc, event) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterServiceHandler returns a service handler invoking f only for the
+// events matching any of the filters, or f itself without filters.
+func FilterServiceHandler(f func(*Service, Event), filters ...HandlerFilter) func(*Service, Event) {
+	if len(filters) == 0 {
+		return f
+	}
+	return func(svc *Service, event Event) {
+		if matchesAnyFilter(filters, svc, event) {
+			f(svc, event)
+		}
+	}
+}
+
+// FilterInstanceHandler returns an instance handler invoking f only for the
+// events of the instances whose service matches any of the filters, or f
+// itself without filters.
+func FilterInstanceHandler(f func(*ServiceInstance, Event), filters ...HandlerFilter) func(*ServiceInstance, Event) {
+	if len(filters) == 0 {
+		return f
+	}
+	return func(instance *ServiceInstance, event Event) {
+		var svc *Service
+		if instance != nil {
+			svc = instance.Service
+		}
+		if matchesAnyFilter(filters, svc, event) {
+			f(instance, event)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"istio.io/istio/pkg/config/host"
+)
+
+func TestHandlerFilterMatches(t *testing.T) {
+	svc := &Service{
+		Hostname:   "reviews.bookinfo.svc.cluster.local",
+		Attributes: ServiceAttributes{Namespace: "bookinfo"},
+	}
+	cases := []struct {
+		name   string
+		filter HandlerFilter
+		svc    *Service
+		event  Event
+		want   bool
+	}{
+		{name: "zero filter", svc: svc, event: EventUpdate, want: true},
+		{name: "zero filter without service", event: EventUpdate, want: true},
+		{name: "namespace", filter: HandlerFilter{Namespaces: []string{"default", "bookinfo"}}, svc: svc, want: true},
+		{name: "other namespace", filter: HandlerFilter{Namespaces: []string{"default"}}, svc: svc, want: false},
+		{name: "namespace without service", filter: HandlerFilter{Namespaces: []string{"bookinfo"}}, want: false},
+		{name: "hostname", filter: HandlerFilter{Hostnames: []host.Name{"reviews.bookinfo.svc.cluster.local"}}, svc: svc, want: true},
+		{name: "wildcard hostname", filter: HandlerFilter{Hostnames: []host.Name{"*.bookinfo.svc.cluster.local"}}, svc: svc, want: true},
+		{name: "other hostname", filter: HandlerFilter{Hostnames: []host.Name{"*.default.svc.cluster.local"}}, svc: svc, want: false},
+		{name: "event", filter: HandlerFilter{Events: []Event{EventAdd, EventDelete}}, svc: svc, event: EventDelete, want: true},
+		{name: "other event", filter: HandlerFilter{Events: []Event{EventAdd, EventDelete}}, svc: svc, event: EventUpdate, want: false},
+		{
+			name: "all fields",
+			filter: HandlerFilter{
+				Namespaces: []string{"bookinfo"},
+				Hostnames:  []host.Name{"*.svc.cluster.local"},
+				Events:     []Event{EventAdd},
+			},
+			svc:   svc,
+			event: EventAdd,
+			want:  true,
+		},
+		{
+			name: "all fields but the event",
+			filter: HandlerFilter{
+				Namespaces: []string{"bookinfo"},
+				Hostnames:  []host.Name{"*.svc.cluster.local"},
+				Events:     []Event{EventAdd},
+			},
+			svc:   svc,
+			event: EventUpdate,
+			want:  false,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.filter.Matches(c.svc, c.event); got != c.want {
+				t.Errorf("Matches() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestFilterHandlers(t *testing.T) {
+	reviews := &Service{Hostname: "reviews.bookinfo.svc.cluster.local", Attributes: ServiceAttributes{Namespace: "bookinfo"}}
+	httpbin := &Service{Hostname: "httpbin.default.svc.cluster.local", Attributes: ServiceAttributes{Namespace: "default"}}
+	filters := []HandlerFilter{
+		{Namespaces: []string{"bookinfo"}},
+		{Hostnames: []host.Name{"httpbin.default.svc.cluster.local"}, Events: []Event{EventDelete}},
+	}
+
+	var services []string
+	serviceHandler := FilterServiceHandler(func(svc *Service, event Event) {
+		services = append(services, string(svc.Hostname)+"/"+event.String())
+	}, filters...)
+	var instances []string
+	instanceHandler := FilterInstanceHandler(func(instance *ServiceInstance, event Event) {
+		instances = append(instances, string(instance.Service.Hostname)+"/"+event.String())
+	}, filters...)
+	for _, svc := range []*Service{reviews, httpbin} {
+		for _, event := range []Event{EventAdd, EventUpdate, EventDelete} {
+			serviceHandler(svc, event)
+			instanceHandler(&ServiceInstance{Service: svc}, event)
+		}
+	}
+
+	want := []string{
+		"reviews.bookinfo.svc.cluster.local/add",
+		"reviews.bookinfo.svc.cluster.local/update",
+		"reviews.bookinfo.svc.cluster.local/delete",
+		"httpbin.default.svc.cluster.local/delete",
+	}
+	for name, got := range map[string][]string{"services": services, "instances": instances} {
+		if len(got) != len(want) {
+			t.Fatalf("%v: got %v, want %v", name, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%v: got %v, want %v", name, got, want)
+				break
+			}
+		}
+	}
+
+	calls := 0
+	unfiltered := func(*Service, Event) { calls++ }
+	FilterServiceHandler(unfiltered)(nil, EventAdd)
+	if calls != 1 {
+		t.Errorf("handler without filters called %v times, want 1", calls)
+	}
+}
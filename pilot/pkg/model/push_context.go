@@ -16,11 +16,14 @@ package model
 
 import (
 	"encoding/json"
+	"net"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	authn "istio.io/api/authentication/v1alpha1"
+	meshconfig "istio.io/api/mesh/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
 
 	"istio.io/istio/pilot/pkg/features"
@@ -82,11 +85,15 @@ type PushContext struct {
 
 	// sidecars for each namespace
 	sidecarsByNamespace map[string][]*SidecarScope
+	// sidecar scopes extended with the on-demand hosts of proxies, by sidecarScopeWithHostsKey
+	sidecarScopesWithHosts sync.Map
 	// envoy filters for each namespace including global config namespace
 	envoyFiltersByNamespace map[string][]*EnvoyFilterWrapper
 	// gateways for each namespace
 	gatewaysByNamespace map[string][]Config
 	allGateways         []Config
+	// gateways of each network, as configured in MeshNetworks and then as discovered by the registries
+	networkGateways map[string][]*NetworkGateway
 	////////// END ////////
 
 	// The following data is either a global index or used in the inbound path.
@@ -361,6 +368,13 @@ var (
 		"Duplicate envoy clusters caused by service entries with same hostname",
 	)
 
+	// DuplicatedListeners tracks the listeners discarded while computing LDS, since they share the name or
+	// the address of another listener, e.g. after applying the EnvoyFilter patches.
+	DuplicatedListeners = monitoring.NewGauge(
+		"pilot_duplicate_envoy_listeners",
+		"Duplicate envoy listeners, by name or address, discarded while computing LDS.",
+	)
+
 	// ProxyStatusClusterNoInstances tracks clusters (services) without workloads.
 	ProxyStatusClusterNoInstances = monitoring.NewGauge(
 		"pilot_eds_no_instances",
@@ -403,6 +417,7 @@ var (
 		ProxyStatusConflictOutboundListenerHTTPOverTCP,
 		ProxyStatusConflictInboundListener,
 		DuplicatedClusters,
+		DuplicatedListeners,
 		ProxyStatusClusterNoInstances,
 		DuplicatedDomains,
 		DuplicatedSubsets,
@@ -592,6 +607,30 @@ func (ps *PushContext) getSidecarScope(proxy *Proxy, workloadLabels labels.Colle
 	return DefaultSidecarScopeForNamespace(ps, proxy.ConfigNamespace)
 }
 
+// sidecarScopeWithHostsKey identifies a SidecarScope extended with on-demand hosts.
+type sidecarScopeWithHostsKey struct {
+	scope *SidecarScope
+	hosts string
+}
+
+// sidecarScopeWithHosts returns the SidecarScope extended with the on-demand hosts, shared by the
+// proxies of the config namespace requesting the same hosts. The default SidecarScope already imports
+// all the services visible to the namespace, it is not extended.
+func (ps *PushContext) sidecarScopeWithHosts(sc *SidecarScope, configNamespace string, hosts []string) *SidecarScope {
+	if sc == nil || sc.Config == nil {
+		return sc
+	}
+	key := sidecarScopeWithHostsKey{
+		scope: sc,
+		hosts: configNamespace + "|" + strings.Join(hosts, ","),
+	}
+	if extended, f := ps.sidecarScopesWithHosts.Load(key); f {
+		return extended.(*SidecarScope)
+	}
+	extended, _ := ps.sidecarScopesWithHosts.LoadOrStore(key, sc.withHosts(ps, configNamespace, hosts))
+	return extended.(*SidecarScope)
+}
+
 // GetAllSidecarScopes returns a map of namespace and the set of SidecarScope
 // object associated with the namespace. This will be used by the CDS code to
 // precompute CDS output for each sidecar scope. Since we have a default sidecarscope
@@ -724,6 +763,8 @@ func (ps *PushContext) InitContext(env *Environment, oldPushContext *PushContext
 		}
 	}
 
+	ps.initNetworkGateways(env)
+
 	ps.initDone = true
 	return nil
 }
@@ -1430,6 +1471,75 @@ func (ps *PushContext) initGateways(env *Environment) error {
 	return nil
 }
 
+// NetworkGateways returns the gateways of each network, as configured in MeshNetworks and then
+// as discovered by the registries.
+func (ps *PushContext) NetworkGateways() map[string][]*NetworkGateway {
+	return ps.networkGateways
+}
+
+// initNetworkGateways collects the gateways of each network. The addresses of the gateways are
+// deduplicated.
+func (ps *PushContext) initNetworkGateways(env *Environment) {
+	out := make(map[string][]*NetworkGateway)
+	seen := make(map[NetworkGateway]struct{})
+	add := func(gw *NetworkGateway) {
+		if _, f := seen[*gw]; f {
+			return
+		}
+		seen[*gw] = struct{}{}
+		out[gw.Network] = append(out[gw.Network], gw)
+	}
+
+	for name, network := range env.MeshNetworks.GetNetworks() {
+		registryName := getNetworkRegistry(network)
+		for _, gw := range network.Gateways {
+			for _, addr := range getGatewayAddresses(gw, registryName, env) {
+				add(&NetworkGateway{Network: name, Addr: addr, Port: gw.Port})
+			}
+		}
+	}
+
+	if d, ok := env.ServiceDiscovery.(NetworkGatewayDiscovery); ok {
+		for _, gws := range d.NetworkGateways() {
+			for _, gw := range gws {
+				add(gw)
+			}
+		}
+	}
+	ps.networkGateways = out
+}
+
+func getNetworkRegistry(network *meshconfig.Network) string {
+	var registryName string
+	for _, eps := range network.Endpoints {
+		if eps != nil && len(eps.GetFromRegistry()) > 0 {
+			registryName = eps.GetFromRegistry()
+			break
+		}
+	}
+
+	return registryName
+}
+
+func getGatewayAddresses(gw *meshconfig.Network_IstioNetworkGateway, registryName string, env *Environment) []string {
+	// First, if a gateway address is provided in the configuration use it. If the gateway address
+	// in the config was a hostname it got already resolved and replaced with an IP address
+	// when loading the config
+	if gwIP := net.ParseIP(gw.GetAddress()); gwIP != nil {
+		return []string{gw.GetAddress()}
+	}
+
+	// Second, try to find the gateway addresses by the provided service name
+	if gwSvcName := gw.GetRegistryServiceName(); len(gwSvcName) > 0 && len(registryName) > 0 {
+		svc, _ := env.GetService(host.Name(gwSvcName))
+		if svc != nil {
+			return svc.Attributes.ClusterExternalAddresses[registryName]
+		}
+	}
+
+	return nil
+}
+
 func (ps *PushContext) mergeGateways(proxy *Proxy) *MergedGateway {
 	// this should never happen
 	if proxy == nil {
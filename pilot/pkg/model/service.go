@@ -256,10 +256,29 @@ type NetworkEndpoint struct {
 	LbWeight uint32
 }
 
+// ProbeType is the kind of a health probe.
+type ProbeType string
+
+const (
+	// HTTPProbe is a probe sending a HTTP GET request to Path. It is the type of probes with no type.
+	HTTPProbe ProbeType = "HTTP"
+	// TCPProbe is a probe opening a TCP connection to Port.
+	TCPProbe ProbeType = "TCP"
+	// ExecProbe is a probe running Command in the workload. It has no Port.
+	ExecProbe ProbeType = "Exec"
+)
+
 // Probe represents a health probe associated with an instance of service.
 type Probe struct {
-	Port *Port  `json:"port,omitempty"`
-	Path string `json:"path,omitempty"`
+	Type    ProbeType `json:"type,omitempty"`
+	Port    *Port     `json:"port,omitempty"`
+	Path    string    `json:"path,omitempty"`
+	Command []string  `json:"command,omitempty"`
+}
+
+// IsHTTP returns true if the probe is a HTTP probe.
+func (p *Probe) IsHTTP() bool {
+	return p.Type == "" || p.Type == HTTPProbe
 }
 
 // ProbeList is a set of probes
@@ -376,8 +395,26 @@ type IstioEndpoint struct {
 
 	// TLSMode endpoint is injected with istio sidecar and ready to configure Istio mTLS
 	TLSMode string
+
+	// HintedZone is the zone whose proxies should prefer this endpoint, as in Kubernetes topology
+	// aware hints. Empty if the registry did not provide a hint.
+	HintedZone string
+
+	// HealthStatus is the health of the endpoint, Healthy unless the registry knows better.
+	HealthStatus HealthStatus
 }
 
+// HealthStatus is the health of an endpoint, as reported by its registry.
+type HealthStatus int32
+
+const (
+	// Healthy endpoints receive traffic.
+	Healthy HealthStatus = iota
+	// Draining endpoints are being terminated. The proxies stop sending them new requests, but
+	// drain their existing connections gracefully.
+	Draining
+)
+
 // ServiceAttributes represents a group of custom attributes of the service.
 type ServiceAttributes struct {
 	// ServiceRegistry indicates the backing service registry system where this service
@@ -401,6 +438,62 @@ type ServiceAttributes struct {
 	// Used by the aggregator to aggregate the Attributes.ClusterExternalAddresses
 	// for clusters where the service resides
 	ClusterExternalAddresses map[string][]string
+
+	// RegistryWeight is the weight of the endpoints of the service, relative to the endpoints
+	// of the service with the same hostname and namespace in the other registries, or 0 if not set.
+	RegistryWeight uint32
+
+	// ClusterRegistryWeights is a mapping between a cluster name and the RegistryWeight of
+	// the service in the registry of the cluster. Set by the aggregator for the services
+	// defined in multiple registries, it weights the endpoints of each registry in EDS.
+	ClusterRegistryWeights map[string]uint32
+}
+
+const (
+	// RegistryWeightAnnotation on a Service or ServiceEntry sets the RegistryWeight of its services,
+	// between 1 and MaxRegistryWeight. It splits the traffic between the registries defining the same
+	// hostname, for example 90/10 during a migration from a ServiceEntry to a Kubernetes Service.
+	RegistryWeightAnnotation = "networking.istio.io/registryWeight"
+
+	// MaxRegistryWeight is the maximum value of RegistryWeightAnnotation.
+	MaxRegistryWeight = 100
+)
+
+// ParseRegistryWeight returns the weight set by RegistryWeightAnnotation on the config of the given
+// namespace/name, or 0 if it is not set or invalid.
+func ParseRegistryWeight(name string, annotations map[string]string) uint32 {
+	value, f := annotations[RegistryWeightAnnotation]
+	if !f {
+		return 0
+	}
+	weight, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || weight == 0 || weight > MaxRegistryWeight {
+		// The configs are converted on every event, hence the debug level.
+		log.Debugf("invalid %s annotation %q on %s, the weight must be between 1 and %d",
+			RegistryWeightAnnotation, value, name, MaxRegistryWeight)
+		return 0
+	}
+	return uint32(weight)
+}
+
+// NetworkGateway is a gateway through which the endpoints of a network are reached from the
+// other networks, with Split Horizon EDS.
+type NetworkGateway struct {
+	// Network the gateway belongs to
+	Network string
+
+	// Addr is the IP address of the gateway
+	Addr string
+
+	// Port is the port of the gateway
+	Port uint32
+}
+
+// NetworkGatewayDiscovery is implemented by registries discovering network gateways, in addition
+// to the gateways configured in MeshNetworks.
+type NetworkGatewayDiscovery interface {
+	// NetworkGateways returns the discovered gateways, by network
+	NetworkGateways() map[string][]*NetworkGateway
 }
 
 // ServiceDiscovery enumerates Istio service instances.
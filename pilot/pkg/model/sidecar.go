@@ -266,6 +266,104 @@ func ConvertToSidecarScope(ps *PushContext, sidecarConfig *Config, configNamespa
 	return out
 }
 
+// withHosts returns a copy of the SidecarScope also importing the hosts, in the namespace/host format
+// of the egress hosts of the Sidecar config. The imported services and virtual services are added to the
+// catch all egress listener, or to a new one if the Sidecar has none.
+func (sc *SidecarScope) withHosts(ps *PushContext, configNamespace string, hosts []string) *SidecarScope {
+	validHosts := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		parts := strings.SplitN(h, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			log.Warnf("Ignoring invalid on-demand host %q, expected namespace/host", h)
+			continue
+		}
+		validHosts = append(validHosts, h)
+	}
+	if len(validHosts) == 0 {
+		return sc
+	}
+	imported := convertIstioListenerToWrapper(ps, configNamespace, &networking.IstioEgressListener{Hosts: validHosts})
+
+	out := *sc
+	out.EgressListeners = make([]*IstioEgressListenerWrapper, 0, len(sc.EgressListeners)+1)
+	merged := false
+	for _, listener := range sc.EgressListeners {
+		if merged || (listener.IstioListener != nil && listener.IstioListener.Port != nil) {
+			out.EgressListeners = append(out.EgressListeners, listener)
+			continue
+		}
+		out.EgressListeners = append(out.EgressListeners, listener.merge(imported))
+		merged = true
+	}
+	if !merged {
+		out.EgressListeners = append(out.EgressListeners, imported)
+	}
+	out.NamespaceForHostname = createNamespaceForHostname(out.EgressListeners)
+
+	dummyNode := Proxy{
+		ConfigNamespace: configNamespace,
+	}
+	out.services = make([]*Service, 0, len(sc.services)+len(imported.services))
+	out.services = append(out.services, sc.services...)
+	out.destinationRules = make(map[host.Name]*Config, len(sc.destinationRules)+len(imported.services))
+	for h, dr := range sc.destinationRules {
+		out.destinationRules[h] = dr
+	}
+	out.namespaceDependencies = make(map[string]struct{}, len(sc.namespaceDependencies))
+	for ns := range sc.namespaceDependencies {
+		out.namespaceDependencies[ns] = struct{}{}
+	}
+	for _, s := range imported.services {
+		if _, f := out.destinationRules[s.Hostname]; f {
+			continue
+		}
+		out.services = append(out.services, s)
+		out.destinationRules[s.Hostname] = ps.DestinationRule(&dummyNode, s)
+		out.namespaceDependencies[s.Attributes.Namespace] = struct{}{}
+	}
+
+	return &out
+}
+
+// merge returns a copy of the egress listener also importing the services and virtual services of
+// the other listener.
+func (ilw *IstioEgressListenerWrapper) merge(other *IstioEgressListenerWrapper) *IstioEgressListenerWrapper {
+	out := *ilw
+	out.listenerHosts = make(map[string][]host.Name, len(ilw.listenerHosts)+len(other.listenerHosts))
+	for ns, hosts := range ilw.listenerHosts {
+		out.listenerHosts[ns] = hosts
+	}
+	for ns, hosts := range other.listenerHosts {
+		out.listenerHosts[ns] = append(append([]host.Name{}, out.listenerHosts[ns]...), hosts...)
+	}
+
+	out.services = append([]*Service{}, ilw.services...)
+	hostnames := make(map[host.Name]struct{}, len(ilw.services))
+	for _, s := range ilw.services {
+		hostnames[s.Hostname] = struct{}{}
+	}
+	for _, s := range other.services {
+		if _, f := hostnames[s.Hostname]; !f {
+			out.services = append(out.services, s)
+		}
+	}
+
+	out.virtualServices = append([]Config{}, ilw.virtualServices...)
+	for _, vs := range other.virtualServices {
+		found := false
+		for _, existing := range ilw.virtualServices {
+			if existing.Namespace == vs.Namespace && existing.Name == vs.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out.virtualServices = append(out.virtualServices, vs)
+		}
+	}
+	return &out
+}
+
 func convertIstioListenerToWrapper(ps *PushContext, configNamespace string,
 	istioListener *networking.IstioEgressListener) *IstioEgressListenerWrapper {
 
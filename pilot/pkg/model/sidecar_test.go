@@ -797,6 +797,121 @@ func TestCreateSidecarScope(t *testing.T) {
 	}
 }
 
+func TestSidecarScopeWithOnDemandHosts(t *testing.T) {
+	serviceA := &Service{Hostname: "a.ns1.svc.cluster.local", Ports: port9000, Attributes: ServiceAttributes{Namespace: "ns1"}}
+	serviceB := &Service{Hostname: "b.ns2.svc.cluster.local", Attributes: ServiceAttributes{Namespace: "ns2"}}
+	serviceC := &Service{Hostname: "c.ns3.svc.cluster.local", Attributes: ServiceAttributes{Namespace: "ns3"}}
+
+	catchAll := &Config{
+		ConfigMeta: ConfigMeta{Name: "catch-all", Namespace: "mynamespace"},
+		Spec: &networking.Sidecar{
+			Egress: []*networking.IstioEgressListener{
+				{Hosts: []string{"ns1/*"}},
+			},
+		},
+	}
+	portOnly := &Config{
+		ConfigMeta: ConfigMeta{Name: "port-only", Namespace: "mynamespace"},
+		Spec: &networking.Sidecar{
+			Egress: []*networking.IstioEgressListener{
+				{
+					Port:  &networking.Port{Number: 9000, Protocol: "HTTP", Name: "http"},
+					Hosts: []string{"ns1/*"},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		sidecarConfig *Config
+		hosts         StringList
+		listeners     int
+		services      []host.Name
+	}{
+		{
+			name:          "no on-demand hosts",
+			sidecarConfig: catchAll,
+			listeners:     1,
+			services:      []host.Name{serviceA.Hostname},
+		},
+		{
+			name:          "catch all egress listener",
+			sidecarConfig: catchAll,
+			hosts:         StringList{"ns2/b.ns2.svc.cluster.local", "ns3/c.ns3.svc.cluster.local", "invalid"},
+			listeners:     1,
+			services:      []host.Name{serviceA.Hostname, serviceB.Hostname},
+		},
+		{
+			name:          "port egress listener",
+			sidecarConfig: portOnly,
+			hosts:         StringList{"*/b.ns2.svc.cluster.local"},
+			listeners:     2,
+			services:      []host.Name{serviceA.Hostname, serviceB.Hostname},
+		},
+		{
+			name:          "only invalid hosts",
+			sidecarConfig: catchAll,
+			hosts:         StringList{"/b.ns2.svc.cluster.local"},
+			listeners:     1,
+			services:      []host.Name{serviceA.Hostname},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps := NewPushContext()
+			meshConfig := mesh.DefaultMeshConfig()
+			ps.Env = &Environment{
+				Mesh: &meshConfig,
+			}
+			ps.publicServices = []*Service{serviceA, serviceB}
+			// Not visible to mynamespace, it must not be imported.
+			ps.privateServicesByNamespace["ns3"] = []*Service{serviceC}
+
+			sidecarScope := ConvertToSidecarScope(ps, tt.sidecarConfig, "mynamespace")
+			ps.sidecarsByNamespace["mynamespace"] = []*SidecarScope{sidecarScope}
+
+			proxy := &Proxy{
+				Type:            SidecarProxy,
+				ConfigNamespace: "mynamespace",
+				Metadata:        &NodeMetadata{OnDemandHosts: tt.hosts},
+			}
+			proxy.SetSidecarScope(ps)
+
+			if len(proxy.SidecarScope.EgressListeners) != tt.listeners {
+				t.Errorf("got %d egress listeners, want %d", len(proxy.SidecarScope.EgressListeners), tt.listeners)
+			}
+			var got []host.Name
+			for _, s := range proxy.SidecarScope.Services() {
+				got = append(got, s.Hostname)
+			}
+			if !reflect.DeepEqual(got, tt.services) {
+				t.Errorf("got services %v, want %v", got, tt.services)
+			}
+			for _, h := range tt.services {
+				if proxy.SidecarScope.NamespaceForHostname[h] == "" {
+					t.Errorf("service %s not found in the egress listeners", h)
+				}
+			}
+
+			// The shared SidecarScope is not modified, and the extended one is reused.
+			if len(sidecarScope.Services()) != 1 {
+				t.Errorf("the shared SidecarScope was modified: %v", sidecarScope.Services())
+			}
+			other := &Proxy{
+				Type:            SidecarProxy,
+				ConfigNamespace: "mynamespace",
+				Metadata:        &NodeMetadata{OnDemandHosts: tt.hosts},
+			}
+			other.SetSidecarScope(ps)
+			if other.SidecarScope != proxy.SidecarScope {
+				t.Error("expected the extended SidecarScope to be shared")
+			}
+		})
+	}
+}
+
 func TestIstioEgressListenerWrapper(t *testing.T) {
 	serviceA8000 := &Service{
 		Hostname:   "host",
@@ -383,7 +383,47 @@ func (configgen *ConfigGeneratorImpl) BuildListeners(env *model.Environment, nod
 	}
 
 	builder.patchListeners(push)
-	return builder.getListeners()
+	return normalizeListeners(push, node, builder.getListeners())
+}
+
+// normalizeListeners resolves the listener conflicts left by the EnvoyFilter patches, which Envoy would
+// reject with the whole update. For listeners sharing the same name or address, the first listener is kept
+// and the others are discarded.
+func normalizeListeners(push *model.PushContext, proxy *model.Proxy, listeners []*xdsapi.Listener) []*xdsapi.Listener {
+	names := make(map[string]bool, len(listeners))
+	addresses := make(map[string]string, len(listeners))
+	out := make([]*xdsapi.Listener, 0, len(listeners))
+	for _, l := range listeners {
+		if names[l.Name] {
+			msg := fmt.Sprintf("Duplicate listener %s found while pushing LDS, possibly added by an EnvoyFilter", l.Name)
+			log.Warnf("%s: %s", proxy.ID, msg)
+			push.Add(model.DuplicatedListeners, l.Name, proxy, msg)
+			continue
+		}
+		address := listenerAddress(l)
+		if existing, f := addresses[address]; f && address != "" {
+			msg := fmt.Sprintf("Listener %s conflicts with listener %s on address %s while pushing LDS, "+
+				"possibly patched by an EnvoyFilter", l.Name, existing, address)
+			log.Warnf("%s: %s", proxy.ID, msg)
+			push.Add(model.DuplicatedListeners, l.Name, proxy, msg)
+			continue
+		}
+		names[l.Name] = true
+		addresses[address] = l.Name
+		out = append(out, l)
+	}
+	return out
+}
+
+// listenerAddress returns the address a listener binds to, empty if it has none.
+func listenerAddress(l *xdsapi.Listener) string {
+	if sa := l.Address.GetSocketAddress(); sa != nil {
+		return fmt.Sprintf("%s/%s:%d", sa.Protocol, sa.Address, sa.GetPortValue())
+	}
+	if pipe := l.Address.GetPipe(); pipe != nil {
+		return "unix://" + pipe.Path
+	}
+	return ""
 }
 
 // buildSidecarListeners produces a list of listeners for sidecar proxies
@@ -1279,6 +1279,30 @@ func TestOutboundListenerAccessLogs(t *testing.T) {
 	}
 }
 
+func TestNormalizeListeners(t *testing.T) {
+	push := model.NewPushContext()
+	listeners := []*xdsapi.Listener{
+		{Name: "0.0.0.0_80", Address: util.BuildAddress("0.0.0.0", 80)},
+		{Name: "0.0.0.0_8080", Address: util.BuildAddress("0.0.0.0", 8080)},
+		// Added or patched by EnvoyFilters.
+		{Name: "0.0.0.0_80", Address: util.BuildAddress("0.0.0.0", 81)},
+		{Name: "patched", Address: util.BuildAddress("0.0.0.0", 8080)},
+		{Name: "other", Address: util.BuildAddress("1.1.1.1", 8080)},
+	}
+
+	got := normalizeListeners(push, &proxy, listeners)
+	var names []string
+	for _, l := range got {
+		names = append(names, l.Name)
+	}
+	if want := []string{"0.0.0.0_80", "0.0.0.0_8080", "other"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got listeners %v, want %v", names, want)
+	}
+	if discarded := push.ProxyStatus[model.DuplicatedListeners.Name()]; len(discarded) != 2 {
+		t.Errorf("got %d duplicate listeners in the push status, want 2: %v", len(discarded), discarded)
+	}
+}
+
 func TestHttpProxyListener(t *testing.T) {
 	p := &fakePlugin{}
 	configgen := NewConfigGenerator([]plugin.Plugin{p})
@@ -65,6 +65,11 @@ func buildHealthCheckFilter(probe *model.Probe, isXDSMarshalingToAnyEnabled bool
 
 func buildHealthCheckFilters(filterChain *plugin.FilterChain, probes model.ProbeList, endpoint *model.NetworkEndpoint, isXDSMarshalingToAnyEnabled bool) {
 	for _, probe := range probes {
+		// Only HTTP probes can be matched by the health check filter, the other probes are handled
+		// by the management listeners.
+		if !probe.IsHTTP() {
+			continue
+		}
 		// Check that the probe matches the listener port. If not, then the probe will be handled
 		// as a management port and not traced. If the port does match, then we need to add a
 		// health check filter for the probe path, to ensure that health checks are not traced.
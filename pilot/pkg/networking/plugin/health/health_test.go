@@ -198,6 +198,25 @@ func TestBuildHealthCheckFilters(t *testing.T) {
 			},
 			expected: plugin.FilterChain{},
 		},
+		// TCP and exec probes, so no health check filter required
+		{
+			probes: model.ProbeList{
+				&model.Probe{
+					Type: model.TCPProbe,
+					Port: &model.Port{
+						Port: 8080,
+					},
+				},
+				&model.Probe{
+					Type:    model.ExecProbe,
+					Command: []string{"cat", "/tmp/healthy"},
+				},
+			},
+			endpoint: &model.NetworkEndpoint{
+				Port: 8080,
+			},
+			expected: plugin.FilterChain{},
+		},
 	}
 
 	for _, c := range cases {
@@ -17,7 +17,10 @@ package v2
 import (
 	"errors"
 	"io"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
@@ -25,11 +28,13 @@ import (
 	ads "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v2"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
 	istiolog "istio.io/pkg/log"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/util/sets"
@@ -52,6 +57,9 @@ var (
 	SendTimeout = 5 * time.Second
 )
 
+// retryAfterKey is the trailer key holding the delay, in seconds, a rejected proxy should wait before reconnecting.
+const retryAfterKey = "retry-after"
+
 // DiscoveryStream is a common interface for EDS and ADS. It also has a
 // shorter name.
 type DiscoveryStream interface {
@@ -77,6 +85,10 @@ type XdsConnection struct {
 
 	node *model.Proxy
 
+	// Identities are the SPIFFE identities of the client certificate of the connection, empty if
+	// the connection is not authenticated with mutual TLS.
+	Identities []string
+
 	// Sending on this channel results in a push. We may also make it a channel of objects so
 	// same info can be sent to all clients, without recomputing.
 	pushChannel chan *XdsEvent
@@ -93,6 +105,10 @@ type XdsConnection struct {
 	EndpointNonceSent, EndpointNonceAcked string
 	EndpointPercent                       int
 
+	// lastSent and lastAcked are the times of the last response sent to the proxy and of its last ACK,
+	// reported by /debug/syncz.
+	lastSent, lastAcked time.Time
+
 	// current list of clusters monitored by the client
 	Clusters []string
 
@@ -107,6 +123,21 @@ type XdsConnection struct {
 	// CDSWatch is set if the remote server is watching Clusters
 	CDSWatch bool
 
+	// Watched are the resources of the registered generators watched by the remote server,
+	// by type URL.
+	Watched map[string]*WatchedResource
+
+	// truncation records the resources dropped from the last pushes because of the resource limits
+	// of the proxy, which is then degraded.
+	truncation resourceTruncation
+
+	// edsSentAt are the times the load assignments were last sent, by cluster, to send them again
+	// before they go stale if the endpoint TTL is set.
+	edsSentAt map[string]time.Time
+
+	// pushDiffs records the diffs of the pushed resources, if PILOT_DEBUG_PUSH_DIFF is enabled.
+	pushDiffs *pushDiffRecorder
+
 	// added will be true if at least one discovery request was received, and the connection
 	// is added to the map of active.
 	added bool
@@ -134,6 +165,23 @@ type XdsEvent struct {
 	noncePrefix string
 }
 
+// NewXdsEvent returns the event of the push request, as pushed to the connections.
+func NewXdsEvent(req *model.PushRequest) *XdsEvent {
+	edsUpdates := req.EdsUpdates
+	if req.Full {
+		// Setting this to nil will trigger a full push
+		edsUpdates = nil
+	}
+	return &XdsEvent{
+		push:               req.Push,
+		edsUpdatedServices: edsUpdates,
+		start:              req.Start,
+		namespacesUpdated:  req.NamespacesUpdated,
+		configTypesUpdated: req.ConfigTypesUpdated,
+		noncePrefix:        req.Push.Version,
+	}
+}
+
 func newXdsConnection(peerAddr string, stream DiscoveryStream) *XdsConnection {
 	return &XdsConnection{
 		pushChannel:  make(chan *XdsEvent),
@@ -179,6 +227,12 @@ func (s *DiscoveryServer) StreamAggregatedResources(stream ads.AggregatedDiscove
 		peerAddr = peerInfo.Addr.String()
 	}
 
+	if err := s.admitConnection(stream); err != nil {
+		adsLog.Warnf("ADS: rejecting connection from %s: %v", peerAddr, err)
+		return err
+	}
+	defer s.releaseConnection()
+
 	t0 := time.Now()
 
 	// first call - lazy loading, in tests. This should not happen if readiness
@@ -193,6 +247,7 @@ func (s *DiscoveryServer) StreamAggregatedResources(stream ads.AggregatedDiscove
 		return err
 	}
 	con := newXdsConnection(peerAddr, stream)
+	con.Identities = peerIdentities(stream.Context())
 
 	// Do not call: defer close(con.pushChannel) !
 	// the push channel will be garbage collected when the connection is no longer used.
@@ -228,12 +283,18 @@ func (s *DiscoveryServer) StreamAggregatedResources(stream ads.AggregatedDiscove
 			case ClusterType:
 				if con.CDSWatch {
 					// Already received a cluster watch request, this is an ACK
+					if isExpiredNonce(con, discReq) {
+						continue
+					}
 					if discReq.ErrorDetail != nil {
 						errCode := codes.Code(discReq.ErrorDetail.Code)
 						adsLog.Warnf("ADS:CDS: ACK ERROR %v %s %s:%s", peerAddr, con.ConID, errCode.String(), discReq.ErrorDetail.GetMessage())
 						incrementXDSRejects(cdsReject, con.node.ID, errCode.String())
 					} else if discReq.ResponseNonce != "" {
+						con.mu.Lock()
 						con.ClusterNonceAcked = discReq.ResponseNonce
+						con.lastAcked = time.Now()
+						con.mu.Unlock()
 					}
 					adsLog.Debugf("ADS:CDS: ACK %s %s %s %s", peerAddr, con.ConID, discReq.VersionInfo, discReq.ResponseNonce)
 					continue
@@ -251,12 +312,18 @@ func (s *DiscoveryServer) StreamAggregatedResources(stream ads.AggregatedDiscove
 			case ListenerType:
 				if con.LDSWatch {
 					// Already received a cluster watch request, this is an ACK
+					if isExpiredNonce(con, discReq) {
+						continue
+					}
 					if discReq.ErrorDetail != nil {
 						errCode := codes.Code(discReq.ErrorDetail.Code)
 						adsLog.Warnf("ADS:LDS: ACK ERROR %v %s %s:%s", peerAddr, con.ConID, errCode.String(), discReq.ErrorDetail.GetMessage())
 						incrementXDSRejects(ldsReject, con.node.ID, errCode.String())
 					} else if discReq.ResponseNonce != "" {
+						con.mu.Lock()
 						con.ListenerNonceAcked = discReq.ResponseNonce
+						con.lastAcked = time.Now()
+						con.mu.Unlock()
 					}
 					adsLog.Debugf("ADS:LDS: ACK %s %s %s %s", peerAddr, con.ConID, discReq.VersionInfo, discReq.ResponseNonce)
 					continue
@@ -269,6 +336,9 @@ func (s *DiscoveryServer) StreamAggregatedResources(stream ads.AggregatedDiscove
 				}
 
 			case RouteType:
+				if isExpiredNonce(con, discReq) {
+					continue
+				}
 				if discReq.ErrorDetail != nil {
 					errCode := codes.Code(discReq.ErrorDetail.Code)
 					adsLog.Warnf("ADS:RDS: ACK ERROR %v %s %s:%s", peerAddr, con.ConID, errCode.String(), discReq.ErrorDetail.GetMessage())
@@ -278,20 +348,14 @@ func (s *DiscoveryServer) StreamAggregatedResources(stream ads.AggregatedDiscove
 				routes := discReq.GetResourceNames()
 				if discReq.ResponseNonce != "" {
 					con.mu.RLock()
-					routeNonceSent := con.RouteNonceSent
 					routeVersionInfoSent := con.RouteVersionInfoSent
 					con.mu.RUnlock()
-					if routeNonceSent != "" && routeNonceSent != discReq.ResponseNonce {
-						adsLog.Debugf("ADS:RDS: Expired nonce received %s %s, sent %s, received %s",
-							peerAddr, con.ConID, routeNonceSent, discReq.ResponseNonce)
-						rdsExpiredNonce.Increment()
-						continue
-					}
 					if discReq.VersionInfo == routeVersionInfoSent {
 						if listEqualUnordered(con.Routes, routes) {
 							adsLog.Debugf("ADS:RDS: ACK %s %s %s %s", peerAddr, con.ConID, discReq.VersionInfo, discReq.ResponseNonce)
 							con.mu.Lock()
 							con.RouteNonceAcked = discReq.ResponseNonce
+							con.lastAcked = time.Now()
 							con.mu.Unlock()
 							continue
 						}
@@ -310,18 +374,25 @@ func (s *DiscoveryServer) StreamAggregatedResources(stream ads.AggregatedDiscove
 				}
 
 			case EndpointType:
+				// A request with an expired nonce changing the clusters is still handled.
+				expired := isExpiredNonce(con, discReq)
 				if discReq.ErrorDetail != nil {
-					errCode := codes.Code(discReq.ErrorDetail.Code)
-					adsLog.Warnf("ADS:EDS: ACK ERROR %v %s %s:%s", peerAddr, con.ConID, errCode.String(), discReq.ErrorDetail.GetMessage())
-					incrementXDSRejects(edsReject, con.node.ID, errCode.String())
+					if !expired {
+						errCode := codes.Code(discReq.ErrorDetail.Code)
+						adsLog.Warnf("ADS:EDS: ACK ERROR %v %s %s:%s", peerAddr, con.ConID, errCode.String(), discReq.ErrorDetail.GetMessage())
+						incrementXDSRejects(edsReject, con.node.ID, errCode.String())
+					}
 					continue
 				}
 				clusters := discReq.GetResourceNames()
 				if clusters == nil && discReq.ResponseNonce != "" {
 					// There is no requirement that ACK includes clusters. The test doesn't.
-					con.mu.Lock()
-					con.EndpointNonceAcked = discReq.ResponseNonce
-					con.mu.Unlock()
+					if !expired {
+						con.mu.Lock()
+						con.EndpointNonceAcked = discReq.ResponseNonce
+						con.lastAcked = time.Now()
+						con.mu.Unlock()
+					}
 					continue
 				}
 
@@ -332,11 +403,15 @@ func (s *DiscoveryServer) StreamAggregatedResources(stream ads.AggregatedDiscove
 
 				// Already got a list of endpoints to watch and it is the same as the request, this is an ack
 				if listEqualUnordered(con.Clusters, clusters) {
+					if expired {
+						continue
+					}
 					adsLog.Debugf("ADS:EDS: ACK %s %s %s %s", peerAddr, con.ConID, discReq.VersionInfo, discReq.ResponseNonce)
 					if discReq.ResponseNonce != "" {
 						con.mu.Lock()
 						edsClusterMutex.RLock()
 						con.EndpointNonceAcked = discReq.ResponseNonce
+						con.lastAcked = time.Now()
 						if len(edsClusters) != 0 {
 							con.EndpointPercent = int((float64(len(clusters)) / float64(len(edsClusters))) * float64(100))
 						}
@@ -359,7 +434,14 @@ func (s *DiscoveryServer) StreamAggregatedResources(stream ads.AggregatedDiscove
 				}
 
 			default:
-				adsLog.Warnf("ADS: Unknown watched resources %s", discReq.String())
+				g, f := s.generators[discReq.TypeUrl]
+				if !f {
+					adsLog.Warnf("ADS: Unknown watched resources %s", discReq.String())
+					break
+				}
+				if err := s.handleGeneratedRequest(con, g, discReq); err != nil {
+					return err
+				}
 			}
 
 			con.mu.Lock()
@@ -553,6 +635,9 @@ func (s *DiscoveryServer) pushConnection(con *XdsConnection, pushEv *XdsEvent) e
 			return err
 		}
 	}
+	if err := s.pushAllGenerated(con, pushEv.push, currentVersion); err != nil {
+		return err
+	}
 	proxiesConvergeDelay.Record(time.Since(pushEv.start).Seconds())
 	return nil
 }
@@ -612,7 +697,7 @@ func (s *DiscoveryServer) AdsPushAll(version string, req *model.PushRequest) {
 
 	adsLog.Infof("XDS: Pushing:%s Services:%d ConnectedEndpoints:%d",
 		version, len(req.Push.Services(nil)), adsClientCount())
-	monServices.Record(float64(len(req.Push.Services(nil))))
+	recordServices(req.Push.Services(nil))
 
 	t0 := time.Now()
 
@@ -665,6 +750,27 @@ func (s *DiscoveryServer) startPush(req *model.PushRequest) {
 	}
 }
 
+// admitConnection reserves a connection slot for a new stream. If MaxConnections is reached the stream
+// is rejected with a retry-after hint in the trailer, so the proxy can reconnect to another replica.
+func (s *DiscoveryServer) admitConnection(stream grpc.ServerStream) error {
+	n := atomic.AddInt32(&s.connections, 1)
+	if s.MaxConnections <= 0 || int(n) <= s.MaxConnections {
+		return nil
+	}
+	atomic.AddInt32(&s.connections, -1)
+	rejectedConnections.Increment()
+
+	retryAfter := int64(s.RejectedRetryAfter.Seconds())
+	stream.SetTrailer(metadata.Pairs(retryAfterKey, strconv.FormatInt(retryAfter, 10)))
+	return status.Errorf(codes.ResourceExhausted,
+		"too many connected proxies (max %d), retry after %ds", s.MaxConnections, retryAfter)
+}
+
+// releaseConnection releases the slot reserved by admitConnection.
+func (s *DiscoveryServer) releaseConnection() {
+	atomic.AddInt32(&s.connections, -1)
+}
+
 func (s *DiscoveryServer) addCon(conID string, con *XdsConnection) {
 	adsClientsMutex.Lock()
 	defer adsClientsMutex.Unlock()
@@ -682,6 +788,8 @@ func (s *DiscoveryServer) addCon(conID string, con *XdsConnection) {
 }
 
 func (s *DiscoveryServer) removeCon(conID string, con *XdsConnection) {
+	clearTruncation(con)
+
 	adsClientsMutex.Lock()
 	defer adsClientsMutex.Unlock()
 
@@ -707,16 +815,54 @@ func (s *DiscoveryServer) removeCon(conID string, con *XdsConnection) {
 	}
 }
 
+// isExpiredNonce returns whether the request has the nonce of a response of its type superseded by a
+// later push, which happens when the proxy is slow to process the pushes. Such ACKs and NACKs are
+// stale and are dropped without warnings, as the proxy will ACK or NACK the latest response as well.
+func isExpiredNonce(con *XdsConnection, discReq *xdsapi.DiscoveryRequest) bool {
+	if discReq.ResponseNonce == "" {
+		return false
+	}
+	var nonceSent, xdsType string
+	con.mu.RLock()
+	switch discReq.TypeUrl {
+	case ClusterType:
+		nonceSent, xdsType = con.ClusterNonceSent, "cds"
+	case ListenerType:
+		nonceSent, xdsType = con.ListenerNonceSent, "lds"
+	case RouteType:
+		nonceSent, xdsType = con.RouteNonceSent, "rds"
+	case EndpointType:
+		nonceSent, xdsType = con.EndpointNonceSent, "eds"
+	default:
+		if w := con.Watched[discReq.TypeUrl]; w != nil {
+			nonceSent, xdsType = w.NonceSent, discReq.TypeUrl
+		}
+	}
+	con.mu.RUnlock()
+
+	if nonceSent == "" || nonceSent == discReq.ResponseNonce {
+		return false
+	}
+	adsLog.Debugf("ADS:%s: Expired nonce received %s %s, sent %s, received %s",
+		strings.ToUpper(xdsType), con.PeerAddr, con.ConID, nonceSent, discReq.ResponseNonce)
+	expiredNonce.With(typeTag.Value(xdsType)).Increment()
+	if discReq.TypeUrl == RouteType {
+		rdsExpiredNonce.Increment()
+	}
+	return true
+}
+
 // Send with timeout
 func (conn *XdsConnection) send(res *xdsapi.DiscoveryResponse) error {
 	done := make(chan error, 1)
 	// hardcoded for now - not sure if we need a setting
 	t := time.NewTimer(SendTimeout)
 	go func() {
-		err := conn.stream.Send(res)
-		done <- err
+		// Record the nonce before sending the response, so that a quick ACK of the response isn't
+		// considered expired.
 		conn.mu.Lock()
 		if res.Nonce != "" {
+			conn.lastSent = time.Now()
 			switch res.TypeUrl {
 			case ClusterType:
 				conn.ClusterNonceSent = res.Nonce
@@ -726,12 +872,30 @@ func (conn *XdsConnection) send(res *xdsapi.DiscoveryResponse) error {
 				conn.RouteNonceSent = res.Nonce
 			case EndpointType:
 				conn.EndpointNonceSent = res.Nonce
+			default:
+				if w := conn.Watched[res.TypeUrl]; w != nil {
+					w.NonceSent = res.Nonce
+				}
 			}
 		}
 		if res.TypeUrl == RouteType {
 			conn.RouteVersionInfoSent = res.VersionInfo
 		}
 		conn.mu.Unlock()
+
+		err := conn.stream.Send(res)
+		done <- err
+		if err == nil {
+			recordResourceSizes(res)
+		}
+		if err == nil && features.DebugPushDiff {
+			conn.mu.Lock()
+			if conn.pushDiffs == nil {
+				conn.pushDiffs = newPushDiffRecorder()
+			}
+			conn.pushDiffs.record(res)
+			conn.mu.Unlock()
+		}
 	}()
 	select {
 	case <-t.C:
@@ -0,0 +1,116 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"fmt"
+	"testing"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/loadbalancer"
+	"istio.io/istio/pilot/pkg/networking/util"
+	v2 "istio.io/istio/pilot/pkg/proxy/envoy/v2"
+	"istio.io/istio/pkg/config/schemas"
+	"istio.io/pkg/log"
+)
+
+// The benchmarks take the model to run, so that they can be run from a Benchmark function for the
+// topologies of CI, or with testing.Benchmark for a planned topology, e.g.:
+//
+//	r := testing.Benchmark(func(b *testing.B) { bench.EDSGeneration(b, m) })
+
+// setup returns the discovery server of the model, with the logs of the pushes reduced to warnings.
+func setup(b *testing.B, m LoadModel, stop <-chan struct{}) *v2.DiscoveryServer {
+	b.Helper()
+	log.FindScope("ads").SetOutputLevel(log.WarnLevel)
+	s, err := m.NewDiscoveryServer(stop)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return s
+}
+
+// PushContextInit measures the initialization of a full push context of the model.
+func PushContextInit(b *testing.B, m LoadModel) {
+	stop := make(chan struct{})
+	defer close(stop)
+	s := setup(b, m, stop)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		push := model.NewPushContext()
+		if err := push.InitContext(s.Env, nil, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// EDSGeneration measures the generation of the endpoints of all the services of the model for a sidecar,
+// as pushed by EDS.
+func EDSGeneration(b *testing.B, m LoadModel) {
+	stop := make(chan struct{})
+	defer close(stop)
+	s := setup(b, m, stop)
+	push := s.Env.PushContext
+	proxy := m.Proxies(push)[0]
+	var response *xdsapi.DiscoveryResponse
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		loadAssignments := make([]*xdsapi.ClusterLoadAssignment, 0, m.Services)
+		for svc := 0; svc < m.Services; svc++ {
+			l := s.LoadAssignmentForCluster(proxy, push, fmt.Sprintf("outbound|80||%s", m.Hostname(svc)))
+			if l == nil {
+				continue
+			}
+			clonedCLA := util.CloneClusterLoadAssignment(l)
+			l = &clonedCLA
+			loadbalancer.ApplyLocalityLBSetting(proxy.Locality, l, s.Env.Mesh.LocalityLbSetting, true)
+			loadAssignments = append(loadAssignments, l)
+		}
+		response = &xdsapi.DiscoveryResponse{TypeUrl: v2.EndpointType, VersionInfo: push.Version}
+		for _, l := range loadAssignments {
+			response.Resources = append(response.Resources, util.MessageToAny(l))
+		}
+	}
+	_ = response
+}
+
+// ProxyNeedsPush measures the selection of the sidecars of all the namespaces of the model, and of their
+// xDS types, to push for a service entry update in a namespace.
+func ProxyNeedsPush(b *testing.B, m LoadModel) {
+	stop := make(chan struct{})
+	defer close(stop)
+	s := setup(b, m, stop)
+	push := s.Env.PushContext
+	proxies := m.Proxies(push)
+	event := v2.NewXdsEvent(&model.PushRequest{
+		Full:               true,
+		Push:               push,
+		NamespacesUpdated:  map[string]struct{}{m.Namespace(0): {}},
+		ConfigTypesUpdated: map[string]struct{}{schemas.ServiceEntry.Type: {}},
+	})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, proxy := range proxies {
+			if v2.ProxyNeedsPush(proxy, event) {
+				_ = v2.PushTypeFor(proxy, event)
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"testing"
+)
+
+var loadModels = []LoadModel{
+	{Services: 10, PodsPerService: 10, Namespaces: 1},
+	{Services: 100, PodsPerService: 10, Namespaces: 10},
+	{Services: 1000, PodsPerService: 10, Namespaces: 100},
+}
+
+func BenchmarkPushContextInit(b *testing.B) {
+	for _, m := range loadModels {
+		b.Run(m.String(), func(b *testing.B) {
+			PushContextInit(b, m)
+		})
+	}
+}
+
+func BenchmarkEDSGeneration(b *testing.B) {
+	for _, m := range loadModels {
+		b.Run(m.String(), func(b *testing.B) {
+			EDSGeneration(b, m)
+		})
+	}
+}
+
+func BenchmarkProxyNeedsPush(b *testing.B) {
+	for _, m := range loadModels {
+		b.Run(m.String(), func(b *testing.B) {
+			ProxyNeedsPush(b, m)
+		})
+	}
+}
+
+func TestLoadModel(t *testing.T) {
+	m := LoadModel{Services: 6, PodsPerService: 3, Namespaces: 2}
+	stop := make(chan struct{})
+	defer close(stop)
+	s, err := m.NewDiscoveryServer(stop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	push := s.Env.PushContext
+	services, err := s.Env.Services()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != m.Services {
+		t.Errorf("got %d services, want %d", len(services), m.Services)
+	}
+	proxies := m.Proxies(push)
+	if len(proxies) != m.Namespaces || proxies[1].ConfigNamespace != "ns-1" {
+		t.Fatalf("got proxies %v, want one per namespace", proxies)
+	}
+	cla := s.LoadAssignmentForCluster(proxies[0], push, "outbound|80||svc-1.ns-1.svc.cluster.local")
+	if cla == nil || len(cla.Endpoints) != 1 || len(cla.Endpoints[0].LbEndpoints) != m.PodsPerService {
+		t.Errorf("got load assignment %v, want %d endpoints", cla, m.PodsPerService)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bench provides synthetic mesh topologies and benchmarks of the resource generation of pilot, to
+// run in CI or to estimate the capacity of pilot for a planned topology.
+package bench
+
+import (
+	"fmt"
+	"time"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	v2 "istio.io/istio/pilot/pkg/proxy/envoy/v2"
+	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
+	"istio.io/istio/pilot/pkg/serviceregistry/external"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+// LoadModel is a synthetic mesh topology.
+type LoadModel struct {
+	// Services is the number of services, spread evenly over the namespaces.
+	Services int
+	// PodsPerService is the number of pods, i.e. endpoints, of each service.
+	PodsPerService int
+	// Namespaces is the number of namespaces, 1 if not set.
+	Namespaces int
+}
+
+// String returns the services/pods per service/namespaces of the model, e.g. to name a sub-benchmark.
+func (m LoadModel) String() string {
+	return fmt.Sprintf("%d/%d/%d", m.Services, m.PodsPerService, m.namespaces())
+}
+
+func (m LoadModel) namespaces() int {
+	if m.Namespaces < 1 {
+		return 1
+	}
+	return m.Namespaces
+}
+
+// Namespace returns the namespace of the i-th service of the model, or of its i-th namespace.
+func (m LoadModel) Namespace(i int) string {
+	return fmt.Sprintf("ns-%d", i%m.namespaces())
+}
+
+// Hostname returns the hostname of the i-th service of the model.
+func (m LoadModel) Hostname(svc int) string {
+	return fmt.Sprintf("svc-%d.%s.svc.cluster.local", svc, m.Namespace(svc))
+}
+
+// Configs returns the service entries of the services of the model, with the static endpoints of their
+// pods, which have distinct addresses across the model.
+func (m LoadModel) Configs() []model.Config {
+	result := make([]model.Config, 0, m.Services)
+	for svc := 0; svc < m.Services; svc++ {
+		endpoints := make([]*networking.ServiceEntry_Endpoint, 0, m.PodsPerService)
+		for pod := 0; pod < m.PodsPerService; pod++ {
+			i := svc*m.PodsPerService + pod
+			endpoints = append(endpoints, &networking.ServiceEntry_Endpoint{
+				Address: fmt.Sprintf("10.%d.%d.%d", (i/(256*256))%256, (i/256)%256, i%256),
+				Labels:  map[string]string{"app": fmt.Sprintf("svc-%d", svc)},
+			})
+		}
+		result = append(result, model.Config{
+			ConfigMeta: model.ConfigMeta{
+				Type:              schemas.ServiceEntry.Type,
+				Name:              fmt.Sprintf("svc-%d", svc),
+				Namespace:         m.Namespace(svc),
+				CreationTimestamp: time.Now(),
+			},
+			Spec: &networking.ServiceEntry{
+				Hosts: []string{m.Hostname(svc)},
+				Ports: []*networking.Port{
+					{Number: 80, Name: "http-port", Protocol: "http"},
+				},
+				Endpoints:  endpoints,
+				Location:   networking.ServiceEntry_MESH_INTERNAL,
+				Resolution: networking.ServiceEntry_STATIC,
+			},
+		})
+	}
+	return result
+}
+
+// NewDiscoveryServer returns a discovery server of the services of the model in a memory registry, with
+// its push context and endpoint shards initialized. The registry runs until stop is closed.
+func (m LoadModel) NewDiscoveryServer(stop <-chan struct{}) (*v2.DiscoveryServer, error) {
+	meshConfig := mesh.DefaultMeshConfig()
+	configController := memory.NewController(memory.Make(schemas.Istio))
+	istioConfigStore := model.MakeIstioStore(configController)
+	serviceControllers := aggregate.NewController()
+	serviceEntryStore := external.NewServiceDiscovery(configController, istioConfigStore)
+	go configController.Run(stop)
+	serviceControllers.AddRegistry(aggregate.Registry{
+		Name:             "ServiceEntries",
+		Controller:       serviceEntryStore,
+		ServiceDiscovery: serviceEntryStore,
+	})
+
+	env := &model.Environment{
+		Mesh:             &meshConfig,
+		IstioConfigStore: istioConfigStore,
+		ServiceDiscovery: serviceControllers,
+		PushContext:      model.NewPushContext(),
+	}
+	for _, cfg := range m.Configs() {
+		if _, err := configController.Create(cfg); err != nil {
+			return nil, err
+		}
+	}
+	// The push only logs its errors, so the configs are checked by initializing a push context first.
+	if err := env.PushContext.InitContext(env, nil, nil); err != nil {
+		return nil, err
+	}
+	s := v2.NewDiscoveryServer(env, []string{})
+	// A full push initializes the endpoint shards of the services. There are no proxies connected to push to.
+	s.Push(&model.PushRequest{Full: true})
+	return s, nil
+}
+
+// Proxies returns a sidecar per namespace of the model, with the sidecar scope of the push context.
+func (m LoadModel) Proxies(push *model.PushContext) []*model.Proxy {
+	proxies := make([]*model.Proxy, 0, m.namespaces())
+	for ns := 0; ns < m.namespaces(); ns++ {
+		proxy := &model.Proxy{
+			Type:            model.SidecarProxy,
+			IPAddresses:     []string{fmt.Sprintf("172.16.%d.%d", ns/256%256, ns%256)},
+			ID:              fmt.Sprintf("sidecar.%s", m.Namespace(ns)),
+			ConfigNamespace: m.Namespace(ns),
+			Metadata:        &model.NodeMetadata{},
+		}
+		proxy.SetSidecarScope(push)
+		proxies = append(proxies, proxy)
+	}
+	return proxies
+}
@@ -49,6 +49,12 @@ func (s *DiscoveryServer) pushCds(con *XdsConnection, push *model.PushContext, v
 	// TODO: Modify interface to take services, and config instead of making library query registry
 	pushStart := time.Now()
 	rawClusters := s.generateRawClusters(con.node, push)
+	rawClusters, dropped := limitClusters(con.node, push, rawClusters, proxyResourceLimits(con.node).clusters)
+	con.mu.Lock()
+	recordTruncation(con, "cds", con.truncation.clusters, dropped)
+	con.truncation.clusters = dropped
+	con.mu.Unlock()
+	rawClusters = s.filterClusters(con, rawClusters)
 
 	if s.DebugConfigs {
 		con.CDSClusters = rawClusters
@@ -22,12 +22,15 @@ import (
 	"net/http"
 	"net/http/pprof"
 	"sort"
+	"strings"
+	"time"
 
 	"istio.io/istio/pilot/pkg/features"
 
 	adminapi "github.com/envoyproxy/go-control-plane/envoy/admin/v2alpha"
 	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/ptypes/any"
+	klabels "k8s.io/apimachinery/pkg/labels"
 
 	authn "istio.io/api/authentication/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
@@ -125,7 +128,13 @@ func (s *DiscoveryServer) InitDebug(mux *http.ServeMux, sctl *aggregate.Controll
 	s.addDebugHandler(mux, "/debug/config_distribution", "Version status of all Envoys connected to this Pilot instance", s.distributedVersions)
 
 	s.addDebugHandler(mux, "/debug/registryz", "Debug support for registry", s.registryz)
+	s.addDebugHandler(mux, "/debug/clusterz", "Dumps the state of each registry, by cluster ID",
+		func(w http.ResponseWriter, req *http.Request) { clusterz(sctl, w, req) })
+	s.addDebugHandler(mux, "/debug/serviceportz", "Ports of the services found in multiple clusters, by cluster ID",
+		func(w http.ResponseWriter, req *http.Request) { servicePortz(sctl, w, req) })
 	s.addDebugHandler(mux, "/debug/endpointz", "Debug support for endpoints", s.endpointz)
+	s.addDebugHandler(mux, "/debug/endpoint_churnz", "Services with the most endpoint churn, by cluster ID",
+		func(w http.ResponseWriter, req *http.Request) { endpointChurnz(sctl, w, req) })
 	s.addDebugHandler(mux, "/debug/endpointShardz", "Info about the endpoint shards", s.endpointShardz)
 	s.addDebugHandler(mux, "/debug/configz", "Debug support for config", s.configz)
 
@@ -133,6 +142,13 @@ func (s *DiscoveryServer) InitDebug(mux *http.ServeMux, sctl *aggregate.Controll
 	s.addDebugHandler(mux, "/debug/authorizationz", "Internal authorization policies", s.Authorizationz)
 	s.addDebugHandler(mux, "/debug/config_dump", "ConfigDump in the form of the Envoy admin config dump API for passed in proxyID", s.ConfigDump)
 	s.addDebugHandler(mux, "/debug/push_status", "Last PushContext Details", s.PushStatusHandler)
+	s.addDebugHandler(mux, "/debug/push_diff", "Resources changed by the last pushes to the passed in proxyID, "+
+		"if PILOT_DEBUG_PUSH_DIFF is enabled", s.PushDiffHandler)
+	s.addDebugHandler(mux, "/debug/config_fanout", "Proxies affected, generated resources and push bytes of each "+
+		"config, optionally filtered by type, namespace and name", s.configFanoutz)
+	s.addDebugHandler(mux, "/debug/snapshot", "Archive of the registries, config digests, connected proxies and "+
+		"recent pushes, to reproduce issues offline",
+		func(w http.ResponseWriter, req *http.Request) { s.snapshotz(sctl, w, req) })
 }
 
 func (s *DiscoveryServer) addDebugHandler(mux *http.ServeMux, path string, help string,
@@ -155,6 +171,31 @@ type SyncStatus struct {
 	EndpointSent    string `json:"endpoint_sent,omitempty"`
 	EndpointAcked   string `json:"endpoint_acked,omitempty"`
 	EndpointPercent int    `json:"endpoint_percent,omitempty"`
+	// Degraded is set if resources were dropped from the last pushes because of the limits of the proxy,
+	// with the numbers of dropped clusters, virtual hosts and endpoints.
+	Degraded           bool `json:"degraded,omitempty"`
+	TruncatedClusters  int  `json:"truncated_clusters,omitempty"`
+	TruncatedRoutes    int  `json:"truncated_routes,omitempty"`
+	TruncatedEndpoints int  `json:"truncated_endpoints,omitempty"`
+	// LastSent and LastAcked are the times of the last response sent to the proxy and of its last ACK,
+	// zero if none.
+	LastSent  time.Time `json:"last_sent"`
+	LastAcked time.Time `json:"last_acked"`
+}
+
+// ProxyAddresses returns the IP addresses of the proxies connected to this Pilot instance, by proxy ID.
+func ProxyAddresses() map[string][]string {
+	out := map[string][]string{}
+	adsClientsMutex.RLock()
+	for _, con := range adsClients {
+		con.mu.RLock()
+		if con.node != nil {
+			out[con.node.ID] = append([]string(nil), con.node.IPAddresses...)
+		}
+		con.mu.RUnlock()
+	}
+	adsClientsMutex.RUnlock()
+	return out
 }
 
 // Syncz dumps the synchronization status of all Envoys connected to this Pilot instance
@@ -176,6 +217,14 @@ func Syncz(w http.ResponseWriter, _ *http.Request) {
 				EndpointSent:    con.EndpointNonceSent,
 				EndpointAcked:   con.EndpointNonceAcked,
 				EndpointPercent: con.EndpointPercent,
+
+				Degraded:           con.truncation.degraded(),
+				TruncatedClusters:  con.truncation.clusters,
+				TruncatedRoutes:    con.truncation.routes,
+				TruncatedEndpoints: con.truncation.endpointCount(),
+
+				LastSent:  con.lastSent,
+				LastAcked: con.lastAcked,
 			})
 		}
 		con.mu.RUnlock()
@@ -213,6 +262,72 @@ func (s *DiscoveryServer) registryz(w http.ResponseWriter, req *http.Request) {
 	_, _ = fmt.Fprintln(w, "{}]")
 }
 
+// registryDumper is implemented by service registries able to dump their internal state.
+type registryDumper interface {
+	DebugDump() interface{}
+}
+
+// clusterz dumps the state of each registry supporting it, keyed by cluster ID, so the state of
+// registries in a multicluster mesh can be compared. The cluster query parameter restricts
+// the output to a single cluster.
+func clusterz(sctl *aggregate.Controller, w http.ResponseWriter, req *http.Request) {
+	cluster := req.URL.Query().Get("cluster")
+	out := make(map[string]interface{})
+	for _, r := range sctl.GetRegistries() {
+		if cluster != "" && cluster != r.ClusterID {
+			continue
+		}
+		if d, ok := r.ServiceDiscovery.(registryDumper); ok {
+			out[r.ClusterID] = d.DebugDump()
+		}
+	}
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(w, "unable to marshal registry information: %v", err)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
+
+// endpointChurnReporter is implemented by service registries tracking the endpoint churn of their services.
+type endpointChurnReporter interface {
+	EndpointChurn() interface{}
+}
+
+// endpointChurnz dumps the services with the most endpoint churn of each registry supporting it, keyed
+// by cluster ID. A single flapping deployment often explains the push load of the whole mesh.
+func endpointChurnz(sctl *aggregate.Controller, w http.ResponseWriter, _ *http.Request) {
+	out := make(map[string]interface{})
+	for _, r := range sctl.GetRegistries() {
+		if d, ok := r.ServiceDiscovery.(endpointChurnReporter); ok {
+			out[r.ClusterID] = d.EndpointChurn()
+		}
+	}
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(w, "unable to marshal endpoint churn: %v", err)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
+
+// servicePortz dumps the ports of the services found in multiple clusters, with the ports of each
+// cluster, the merged ports pushed to the proxies and the conflicting ports left out.
+func servicePortz(sctl *aggregate.Controller, w http.ResponseWriter, _ *http.Request) {
+	b, err := json.MarshalIndent(sctl.ServicePorts(), "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(w, "unable to marshal service ports: %v", err)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
+
 // Dumps info about the endpoint shards, tracked using the new direct interface.
 // Legacy registry provides are synced to the new data structure as well, during
 // the full push.
@@ -273,10 +388,19 @@ func (s *DiscoveryServer) endpointz(w http.ResponseWriter, req *http.Request) {
 
 // SyncedVersions shows what resourceVersion of a given resource has been acked by Envoy.
 type SyncedVersions struct {
-	ProxyID         string `json:"proxy,omitempty"`
+	ProxyID string `json:"proxy,omitempty"`
+	// ProxyNamespace is the config namespace of the proxy.
+	ProxyNamespace string `json:"proxy_namespace,omitempty"`
+	// Resource is the resource the versions are reported for, one of the resources of the query.
+	Resource        string `json:"resource,omitempty"`
 	ClusterVersion  string `json:"cluster_acked,omitempty"`
 	ListenerVersion string `json:"listener_acked,omitempty"`
 	RouteVersion    string `json:"route_acked,omitempty"`
+	// The versions of the resource in the config sent to the proxy at bootstrap, reported until the proxy
+	// acknowledges its first config of the type.
+	ClusterBootstrapVersion  string `json:"cluster_bootstrap,omitempty"`
+	ListenerBootstrapVersion string `json:"listener_bootstrap,omitempty"`
+	RouteBootstrapVersion    string `json:"route_bootstrap,omitempty"`
 }
 
 func (s *DiscoveryServer) distributedVersions(w http.ResponseWriter, req *http.Request) {
@@ -286,23 +410,53 @@ func (s *DiscoveryServer) distributedVersions(w http.ResponseWriter, req *http.R
 			"PILOT_ENABLE_CONFIG_DISTRIBUTION_TRACKING environment variable to true to enable.")
 		return
 	}
-	if resourceID := req.URL.Query().Get("resource"); resourceID != "" {
-		proxyNamespace := req.URL.Query().Get("proxy_namespace")
-		knownVersions := make(map[string]string)
+	// The distribution of several resources can be queried at once, e.g. resource=vs1&resource=vs2
+	if resourceIDs := req.URL.Query()["resource"]; len(resourceIDs) > 0 && resourceIDs[0] != "" {
+		// The proxies counted can be scoped to a comma separated list of namespaces, and to the ones
+		// with the labels matching a selector, e.g. proxy_namespace=ns1,ns2&proxy_selector=app=reviews
+		proxyNamespaces := map[string]bool{}
+		if proxyNamespace := req.URL.Query().Get("proxy_namespace"); proxyNamespace != "" {
+			for _, ns := range strings.Split(proxyNamespace, ",") {
+				proxyNamespaces[ns] = true
+			}
+		}
+		proxySelector, err := klabels.Parse(req.URL.Query().Get("proxy_selector"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, "invalid proxy_selector: %v", err)
+			return
+		}
+		// The versions of each resource known by config version.
+		knownVersions := make(map[string]map[string]string, len(resourceIDs))
+		for _, resourceID := range resourceIDs {
+			knownVersions[resourceID] = make(map[string]string)
+		}
 		var results []SyncedVersions
 		adsClientsMutex.RLock()
 		for _, con := range adsClients {
 			// wrap this in independent scope so that panic's don't bypass Unlock...
 			con.mu.RLock()
 
-			if con.node != nil && (proxyNamespace == "" || proxyNamespace == con.node.ConfigNamespace) {
+			if con.node != nil && (len(proxyNamespaces) == 0 || proxyNamespaces[con.node.ConfigNamespace]) &&
+				proxySelector.Matches(klabels.Set(proxyLabels(con.node))) {
 				// TODO: handle skipped nodes
-				results = append(results, SyncedVersions{
-					ProxyID:         con.node.ID,
-					ClusterVersion:  s.getResourceVersion(con.ClusterNonceAcked, resourceID, knownVersions),
-					ListenerVersion: s.getResourceVersion(con.ListenerNonceAcked, resourceID, knownVersions),
-					RouteVersion:    s.getResourceVersion(con.RouteNonceAcked, resourceID, knownVersions),
-				})
+				for _, resourceID := range resourceIDs {
+					cache := knownVersions[resourceID]
+					results = append(results, SyncedVersions{
+						ProxyID:         con.node.ID,
+						ProxyNamespace:  con.node.ConfigNamespace,
+						Resource:        resourceID,
+						ClusterVersion:  s.getResourceVersion(con.ClusterNonceAcked, resourceID, cache),
+						ListenerVersion: s.getResourceVersion(con.ListenerNonceAcked, resourceID, cache),
+						RouteVersion:    s.getResourceVersion(con.RouteNonceAcked, resourceID, cache),
+						ClusterBootstrapVersion: s.getBootstrapVersion(
+							con.ClusterNonceSent, con.ClusterNonceAcked, resourceID, cache),
+						ListenerBootstrapVersion: s.getBootstrapVersion(
+							con.ListenerNonceSent, con.ListenerNonceAcked, resourceID, cache),
+						RouteBootstrapVersion: s.getBootstrapVersion(
+							con.RouteNonceSent, con.RouteNonceAcked, resourceID, cache),
+					})
+				}
 			}
 			con.mu.RUnlock()
 		}
@@ -322,6 +476,14 @@ func (s *DiscoveryServer) distributedVersions(w http.ResponseWriter, req *http.R
 	}
 }
 
+// proxyLabels returns the labels of the workload of the proxy.
+func proxyLabels(node *model.Proxy) map[string]string {
+	if node.Metadata == nil {
+		return nil
+	}
+	return node.Metadata.Labels
+}
+
 // The Config Version is only used as the nonce prefix, but we can reconstruct it because is is a
 // b64 encoding of a 64 bit array, which will always be 12 chars in length.
 // len = ceil(bitlength/(2^6))+1
@@ -347,6 +509,15 @@ func (s *DiscoveryServer) getResourceVersion(nonce, key string, cache map[string
 	return result
 }
 
+// getBootstrapVersion returns the version of the resource in the config sent to the proxy, if the proxy
+// hasn't acknowledged any config of the type since it connected.
+func (s *DiscoveryServer) getBootstrapVersion(nonceSent, nonceAcked, key string, cache map[string]string) string {
+	if nonceAcked != "" {
+		return ""
+	}
+	return s.getResourceVersion(nonceSent, key, cache)
+}
+
 // Config debugging.
 func (s *DiscoveryServer) configz(w http.ResponseWriter, req *http.Request) {
 	w.Header().Add("Content-Type", "application/json")
@@ -706,7 +877,27 @@ func writeAllADS(w io.Writer) {
 	_, _ = fmt.Fprint(w, "]\n")
 }
 
+// AddReadinessCheck adds a check to the readiness of the server. The server is not ready while
+// the check returns an error.
+func (s *DiscoveryServer) AddReadinessCheck(name string, check func() error) {
+	s.readinessMutex.Lock()
+	defer s.readinessMutex.Unlock()
+	if s.readinessChecks == nil {
+		s.readinessChecks = map[string]func() error{}
+	}
+	s.readinessChecks[name] = check
+}
+
 func (s *DiscoveryServer) ready(w http.ResponseWriter, req *http.Request) {
+	s.readinessMutex.RLock()
+	defer s.readinessMutex.RUnlock()
+	for name, check := range s.readinessChecks {
+		if err := check(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = fmt.Fprintf(w, "%s not ready: %v\n", name, err)
+			return
+		}
+	}
 	w.WriteHeader(200)
 }
 
@@ -108,6 +108,36 @@ type DiscoveryServer struct {
 
 	// debugHandlers is the list of all the supported debug handlers.
 	debugHandlers map[string]string
+
+	// MaxConnections is the maximum number of proxies allowed to connect to this server.
+	// Defaults to PILOT_MAX_CONNECTED_PROXIES, 0 means no limit.
+	MaxConnections int
+
+	// RejectedRetryAfter is the delay suggested to proxies rejected because MaxConnections was reached.
+	RejectedRetryAfter time.Duration
+
+	// connections is the number of admitted ADS streams. Accessed atomically.
+	connections int32
+
+	// generators are the registered generators of resources, by type URL.
+	generators map[string]Generator
+
+	// resourceFilters are the registered filters of the resources sent to the proxies.
+	resourceFilters []ResourceFilter
+
+	// readinessChecks are the checks of the components sharing the readiness of the server, by
+	// name. Protected by readinessMutex.
+	readinessChecks map[string]func() error
+	readinessMutex  sync.RWMutex
+
+	// pushHistory records the last pushes, for /debug/snapshot.
+	pushHistory pushHistory
+
+	// endpointTTL is the TTL of the pushed endpoints, in nanoseconds. Set from the mesh config, defaults
+	// to PILOT_ENDPOINT_TTL.
+	endpointTTL *atomic.Int64
+	// endpointTTLChanged is signaled when the TTL changes, to refresh the endpoints at the new period.
+	endpointTTLChanged chan struct{}
 }
 
 // EndpointShards holds the set of endpoint shards of a service. Registries update
@@ -141,6 +171,10 @@ func NewDiscoveryServer(env *model.Environment, plugins []string) *DiscoveryServ
 		pushQueue:               NewPushQueue(),
 		DebugConfigs:            features.DebugConfigs,
 		debugHandlers:           map[string]string{},
+		MaxConnections:          features.MaxConnectedProxies,
+		RejectedRetryAfter:      features.RejectedConnectionRetryAfter,
+		endpointTTL:             atomic.NewInt64(int64(features.EndpointTTL)),
+		endpointTTLChanged:      make(chan struct{}, 1),
 	}
 
 	// Flush cached discovery responses when detecting jwt public key change.
@@ -159,6 +193,25 @@ func (s *DiscoveryServer) Start(stopCh <-chan struct{}) {
 	go s.handleUpdates(stopCh)
 	go s.periodicRefreshMetrics(stopCh)
 	go s.sendPushes(stopCh)
+	go s.periodicRefreshEndpoints(stopCh)
+}
+
+// EndpointTTL returns the TTL of the pushed endpoints, 0 if they have none.
+func (s *DiscoveryServer) EndpointTTL() time.Duration {
+	return time.Duration(s.endpointTTL.Load())
+}
+
+// SetEndpointTTL sets the TTL of the pushed endpoints, 0 to push them without TTL. The endpoints already
+// pushed keep their TTL until they are pushed again.
+func (s *DiscoveryServer) SetEndpointTTL(ttl time.Duration) {
+	if time.Duration(s.endpointTTL.Swap(int64(ttl))) == ttl {
+		return
+	}
+	adsLog.Infof("Endpoint TTL set to %v", ttl)
+	select {
+	case s.endpointTTLChanged <- struct{}{}:
+	default:
+	}
 }
 
 // Push metrics are updated periodically (10s default)
@@ -192,6 +245,7 @@ func (s *DiscoveryServer) periodicRefreshMetrics(stopCh <-chan struct{}) {
 func (s *DiscoveryServer) Push(req *model.PushRequest) {
 	if !req.Full {
 		req.Push = s.globalPushContext()
+		s.pushHistory.record(req, versionInfo(), nil)
 		go s.AdsPushAll(versionInfo(), req)
 		return
 	}
@@ -208,6 +262,7 @@ func (s *DiscoveryServer) Push(req *model.PushRequest) {
 		adsLog.Errorf("XDS: Failed to update services: %v", err)
 		// We can't push if we can't read the data - stick with previous version.
 		pushContextErrors.Increment()
+		s.pushHistory.record(req, "", err)
 		return
 	}
 
@@ -229,6 +284,7 @@ func (s *DiscoveryServer) Push(req *model.PushRequest) {
 	versionMutex.Unlock()
 
 	req.Push = push
+	s.pushHistory.record(req, versionLocal, nil)
 	go s.AdsPushAll(versionLocal, req)
 }
 
@@ -364,22 +420,11 @@ func doSendPushes(stopCh <-chan struct{}, semaphore chan struct{}, queue *PushQu
 			proxiesQueueTime.Record(time.Since(info.Start).Seconds())
 
 			go func() {
-				edsUpdates := info.EdsUpdates
-				if info.Full {
-					// Setting this to nil will trigger a full push
-					edsUpdates = nil
-				}
+				event := NewXdsEvent(info)
+				event.done = doneFunc
 
 				select {
-				case client.pushChannel <- &XdsEvent{
-					push:               info.Push,
-					edsUpdatedServices: edsUpdates,
-					done:               doneFunc,
-					start:              info.Start,
-					namespacesUpdated:  info.NamespacesUpdated,
-					configTypesUpdated: info.ConfigTypesUpdated,
-					noncePrefix:        info.Push.Version,
-				}:
+				case client.pushChannel <- event:
 					return
 				case <-client.stream.Context().Done(): // grpc stream was closed
 					doneFunc()
@@ -16,7 +16,10 @@ package v2
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"sync"
@@ -26,6 +29,9 @@ import (
 
 	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	"istio.io/istio/pkg/test/util/retry"
 
@@ -317,3 +323,89 @@ func TestDebounce(t *testing.T) {
 		})
 	}
 }
+
+type trailerStream struct {
+	fakeStream
+	trailer metadata.MD
+}
+
+func (h *trailerStream) SetTrailer(md metadata.MD) {
+	h.trailer = metadata.Join(h.trailer, md)
+}
+
+func TestAdmitConnection(t *testing.T) {
+	s := &DiscoveryServer{MaxConnections: 2, RejectedRetryAfter: 5 * time.Second}
+
+	for i := 0; i < 2; i++ {
+		if err := s.admitConnection(&trailerStream{}); err != nil {
+			t.Fatalf("connection %d rejected: %v", i, err)
+		}
+	}
+
+	stream := &trailerStream{}
+	err := s.admitConnection(stream)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	if got := stream.trailer.Get(retryAfterKey); !reflect.DeepEqual(got, []string{"5"}) {
+		t.Fatalf("expected retry-after trailer 5, got %v", got)
+	}
+	if n := atomic.LoadInt32(&s.connections); n != 2 {
+		t.Fatalf("expected 2 admitted connections, got %d", n)
+	}
+
+	// Once a connection is released, a new one is admitted again.
+	s.releaseConnection()
+	if err := s.admitConnection(&trailerStream{}); err != nil {
+		t.Fatalf("connection rejected after release: %v", err)
+	}
+
+	// No limit by default.
+	unlimited := &DiscoveryServer{}
+	for i := 0; i < 10; i++ {
+		if err := unlimited.admitConnection(&trailerStream{}); err != nil {
+			t.Fatalf("connection %d rejected without limit: %v", i, err)
+		}
+	}
+}
+
+func TestReadinessChecks(t *testing.T) {
+	s := &DiscoveryServer{}
+	ready := func() int {
+		rec := httptest.NewRecorder()
+		s.ready(rec, httptest.NewRequest("GET", "/ready", nil))
+		return rec.Code
+	}
+	if code := ready(); code != http.StatusOK {
+		t.Fatalf("got status %d without checks, want 200", code)
+	}
+
+	var caErr error
+	s.AddReadinessCheck("ca", func() error { return caErr })
+	if code := ready(); code != http.StatusOK {
+		t.Fatalf("got status %d with passing check, want 200", code)
+	}
+	caErr = errors.New("signing key not loaded")
+	if code := ready(); code != http.StatusServiceUnavailable {
+		t.Fatalf("got status %d with failing check, want 503", code)
+	}
+}
+
+func TestSetEndpointTTL(t *testing.T) {
+	s := NewDiscoveryServer(&model.Environment{}, nil)
+	s.SetEndpointTTL(time.Minute)
+	if got := s.EndpointTTL(); got != time.Minute {
+		t.Fatalf("got endpoint TTL %v, want 1m", got)
+	}
+	select {
+	case <-s.endpointTTLChanged:
+	default:
+		t.Fatal("the change of the endpoint TTL is not signaled")
+	}
+	s.SetEndpointTTL(time.Minute)
+	select {
+	case <-s.endpointTTLChanged:
+		t.Fatal("the same endpoint TTL is signaled as a change")
+	default:
+	}
+}
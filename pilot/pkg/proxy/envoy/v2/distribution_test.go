@@ -0,0 +1,112 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+)
+
+func TestDistributedVersionsProxyScope(t *testing.T) {
+	defer func(enabled bool) { features.EnableDistributionTracking = enabled }(features.EnableDistributionTracking)
+	features.EnableDistributionTracking = true
+
+	connections := map[string]*XdsConnection{
+		"distribution-a": {node: &model.Proxy{ID: "a.ns1", ConfigNamespace: "distribution-ns1",
+			Metadata: &model.NodeMetadata{Labels: map[string]string{"app": "reviews"}}}},
+		"distribution-b": {node: &model.Proxy{ID: "b.ns1", ConfigNamespace: "distribution-ns1",
+			Metadata: &model.NodeMetadata{Labels: map[string]string{"app": "ratings"}}}},
+		"distribution-c": {node: &model.Proxy{ID: "c.ns2", ConfigNamespace: "distribution-ns2",
+			Metadata: &model.NodeMetadata{Labels: map[string]string{"app": "reviews"}}}},
+	}
+	adsClientsMutex.Lock()
+	for id, con := range connections {
+		adsClients[id] = con
+	}
+	adsClientsMutex.Unlock()
+	defer func() {
+		adsClientsMutex.Lock()
+		for id := range connections {
+			delete(adsClients, id)
+		}
+		adsClientsMutex.Unlock()
+	}()
+
+	s := &DiscoveryServer{}
+	cases := []struct {
+		query string
+		code  int
+		want  []string
+	}{
+		{
+			query: "proxy_namespace=distribution-ns1,distribution-ns2",
+			code:  http.StatusOK,
+			want:  []string{"vs:a.ns1", "vs:b.ns1", "vs:c.ns2"},
+		},
+		{
+			query: "proxy_namespace=distribution-ns1,distribution-ns2&proxy_selector=app%3Dreviews",
+			code:  http.StatusOK,
+			want:  []string{"vs:a.ns1", "vs:c.ns2"},
+		},
+		{
+			query: "proxy_namespace=distribution-ns1&proxy_selector=app+notin+(reviews)",
+			code:  http.StatusOK,
+			want:  []string{"vs:b.ns1"},
+		},
+		{
+			query: "resource=dr&proxy_namespace=distribution-ns2",
+			code:  http.StatusOK,
+			want:  []string{"dr:c.ns2", "vs:c.ns2"},
+		},
+		{
+			query: "proxy_selector=app+in+(",
+			code:  http.StatusBadRequest,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			s.distributedVersions(rr, httptest.NewRequest("GET", "/debug/config_distribution?resource=vs&"+c.query, nil))
+			if rr.Code != c.code {
+				t.Fatalf("got status %d, want %d: %s", rr.Code, c.code, rr.Body.String())
+			}
+			if c.code != http.StatusOK {
+				return
+			}
+			var versions []SyncedVersions
+			if err := json.Unmarshal(rr.Body.Bytes(), &versions); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, v := range versions {
+				if v.ProxyNamespace == "" {
+					t.Errorf("no namespace reported for proxy %s", v.ProxyID)
+				}
+				got = append(got, v.Resource+":"+v.ProxyID)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got proxies %v, want %v", got, c.want)
+			}
+		})
+	}
+}
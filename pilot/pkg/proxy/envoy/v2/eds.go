@@ -15,6 +15,7 @@
 package v2
 
 import (
+	"sort"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -23,6 +24,7 @@ import (
 	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
 	core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/api/v2/endpoint"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/wrappers"
 
 	networkingapi "istio.io/api/networking/v1alpha3"
@@ -98,7 +100,7 @@ func loadAssignment(c *EdsCluster) *xdsapi.ClusterLoadAssignment {
 
 // buildEnvoyLbEndpoint packs the endpoint based on istio info.
 func buildEnvoyLbEndpoint(uid string, family model.AddressFamily, address string, port uint32,
-	network string, weight uint32, tlsMode string) *endpoint.LbEndpoint {
+	network string, weight uint32, tlsMode string, healthStatus model.HealthStatus) *endpoint.LbEndpoint {
 
 	var addr core.Address
 	switch family {
@@ -137,6 +139,10 @@ func buildEnvoyLbEndpoint(uid string, family model.AddressFamily, address string
 	// Do not remove
 	ep.Metadata = util.BuildLbEndpointMetadata(uid, network, tlsMode)
 
+	if healthStatus == model.Draining {
+		ep.HealthStatus = core.HealthStatus_DRAINING
+	}
+
 	return ep
 }
 
@@ -224,7 +230,7 @@ func (s *DiscoveryServer) updateClusterInc(push *model.PushContext, clusterName
 		return s.updateCluster(push, clusterName, edsCluster)
 	}
 
-	locEps := buildLocalityLbEndpointsFromShards(se, svcPort, subsetLabels, clusterName, push)
+	locEps := buildLocalityLbEndpointsFromShards(se, svc, svcPort, subsetLabels, clusterName, nil, push, s.endpointMerge())
 	// There is a chance multiple goroutines will update the cluster at the same time.
 	// This could be prevented by a lock - but because the update may be slow, it may be
 	// better to accept the extra computations.
@@ -372,12 +378,12 @@ func (s *DiscoveryServer) SvcUpdate(cluster, hostname string, namespace string,
 	// When a service deleted, we should cleanup the endpoint shards and also remove keys from EndpointShardsByService to
 	// prevent memory leaks.
 	if event == model.EventDelete {
-		inboundServiceDeletes.Increment()
+		inboundServiceDeletes.With(clusterIDTag.Value(cluster)).Increment()
 		s.mutex.Lock()
 		defer s.mutex.Unlock()
 		s.deleteService(cluster, hostname, namespace)
 	} else {
-		inboundServiceUpdates.Increment()
+		inboundServiceUpdates.With(clusterIDTag.Value(cluster)).Increment()
 	}
 }
 
@@ -423,7 +429,7 @@ func (s *DiscoveryServer) edsIncremental(version string, push *model.PushContext
 // on each step: instead the conversion happens once, when an endpoint is first discovered.
 func (s *DiscoveryServer) EDSUpdate(clusterID, serviceName string, namespace string,
 	istioEndpoints []*model.IstioEndpoint) error {
-	inboundEDSUpdates.Increment()
+	inboundEDSUpdates.With(clusterIDTag.Value(clusterID)).Increment()
 	s.edsUpdate(clusterID, serviceName, namespace, istioEndpoints, false)
 	return nil
 }
@@ -605,6 +611,13 @@ func (s *DiscoveryServer) loadAssignmentsForClusterLegacy(push *model.PushContex
 	return l
 }
 
+// LoadAssignmentForCluster returns the endpoints of the cluster for the proxy, before the network filter
+// and the locality priorities applied to them for the proxy by pushEds. It is shared, and must not be mutated.
+func (s *DiscoveryServer) LoadAssignmentForCluster(proxy *model.Proxy, push *model.PushContext,
+	clusterName string) *xdsapi.ClusterLoadAssignment {
+	return s.loadAssignmentsForClusterIsolated(proxy, push, clusterName)
+}
+
 // loadAssignmentsForClusterIsolated return the endpoints for a proxy in an isolated namespace
 // Initial implementation is computing the endpoints on the flight - caching will be added as needed, based on
 // perf tests. The logic to compute is based on the current UpdateClusterInc
@@ -661,7 +674,7 @@ func (s *DiscoveryServer) loadAssignmentsForClusterIsolated(proxy *model.Proxy,
 		return s.loadAssignmentsForClusterLegacy(push, clusterName)
 	}
 
-	locEps := buildLocalityLbEndpointsFromShards(se, svcPort, subsetLabels, clusterName, push)
+	locEps := buildLocalityLbEndpointsFromShards(se, svc, svcPort, subsetLabels, clusterName, proxy, push, s.endpointMerge())
 
 	return &xdsapi.ClusterLoadAssignment{
 		ClusterName: clusterName,
@@ -676,6 +689,10 @@ func (s *DiscoveryServer) pushEds(push *model.PushContext, con *XdsConnection, v
 	loadAssignments := make([]*xdsapi.ClusterLoadAssignment, 0)
 	endpoints := 0
 	empty := make([]string, 0)
+	quota := endpointQuota(con, proxyResourceLimits(con.node).endpoints)
+	previousDropped := con.truncation.endpointCount()
+	truncated := make(map[string]int)
+	endpointTTL := s.EndpointTTL()
 
 	// All clusters that this endpoint is watching. For 1.0 - it's typically all clusters in the mesh.
 	// For 1.1+Sidecar - it's the small set of explicitly imported clusters, using the isolated DestinationRules
@@ -696,10 +713,10 @@ func (s *DiscoveryServer) pushEds(push *model.PushContext, con *XdsConnection, v
 			continue
 		}
 
-		// If networks are set (by default they aren't) apply the Split Horizon
-		// EDS filter on the endpoints
-		if s.Env.MeshNetworks != nil && len(s.Env.MeshNetworks.Networks) > 0 {
-			endpoints := EndpointsByNetworkFilter(l.Endpoints, con, s.Env)
+		// If networks are set (by default they aren't) or network gateways were discovered,
+		// apply the Split Horizon EDS filter on the endpoints
+		if len(s.Env.MeshNetworks.GetNetworks()) > 0 || len(push.NetworkGateways()) > 0 {
+			endpoints := EndpointsByNetworkFilter(push, l.Endpoints, con)
 			filteredCLA := &xdsapi.ClusterLoadAssignment{
 				ClusterName: l.ClusterName,
 				Endpoints:   endpoints,
@@ -724,6 +741,9 @@ func (s *DiscoveryServer) pushEds(push *model.PushContext, con *XdsConnection, v
 			loadbalancer.ApplyLocalityLBSetting(con.node.Locality, l, localityLbSettings, enableFailover)
 		}
 
+		l, truncated[clusterName] = limitEndpoints(l, quota)
+		l = withEndpointTTL(l, endpointTTL)
+
 		for _, e := range l.Endpoints {
 			endpoints += len(e.LbEndpoints)
 		}
@@ -734,6 +754,21 @@ func (s *DiscoveryServer) pushEds(push *model.PushContext, con *XdsConnection, v
 		loadAssignments = append(loadAssignments, l)
 	}
 
+	con.mu.Lock()
+	if edsUpdatedServices == nil || con.truncation.endpoints == nil {
+		con.truncation.endpoints = make(map[string]int)
+	}
+	for clusterName, dropped := range truncated {
+		if dropped > 0 {
+			con.truncation.endpoints[clusterName] = dropped
+		} else {
+			delete(con.truncation.endpoints, clusterName)
+		}
+	}
+	recordTruncation(con, "eds", previousDropped, con.truncation.endpointCount())
+	con.mu.Unlock()
+
+	loadAssignments = s.filterLoadAssignments(con, loadAssignments)
 	response := endpointDiscoveryResponse(loadAssignments, version, push.Version)
 	err := con.send(response)
 	edsPushTime.Record(time.Since(pushStart).Seconds())
@@ -744,6 +779,17 @@ func (s *DiscoveryServer) pushEds(push *model.PushContext, con *XdsConnection, v
 	}
 	edsPushes.Increment()
 
+	if endpointTTL > 0 {
+		con.mu.Lock()
+		if con.edsSentAt == nil {
+			con.edsSentAt = make(map[string]time.Time)
+		}
+		for _, l := range loadAssignments {
+			con.edsSentAt[l.ClusterName] = pushStart
+		}
+		con.mu.Unlock()
+	}
+
 	if edsUpdatedServices == nil {
 		adsLog.Infof("EDS: PUSH for node:%s clusters:%d endpoints:%d empty:%v",
 			con.node.ID, len(con.Clusters), endpoints, empty)
@@ -754,6 +800,110 @@ func (s *DiscoveryServer) pushEds(push *model.PushContext, con *XdsConnection, v
 	return nil
 }
 
+// withEndpointTTL returns the cluster load assignment with its endpoints considered stale after the TTL, so
+// that a proxy which can't reach Pilot stops using them once the TTL expires. The cluster load assignment
+// is shared by the proxies, so it is copied rather than mutated.
+func withEndpointTTL(cla *xdsapi.ClusterLoadAssignment, ttl time.Duration) *xdsapi.ClusterLoadAssignment {
+	if ttl <= 0 {
+		return cla
+	}
+	policy := &xdsapi.ClusterLoadAssignment_Policy{}
+	if cla.Policy != nil {
+		policy.DropOverloads = cla.Policy.DropOverloads
+		policy.OverprovisioningFactor = cla.Policy.OverprovisioningFactor
+		policy.DisableOverprovisioning = cla.Policy.DisableOverprovisioning
+	}
+	policy.EndpointStaleAfter = ptypes.DurationProto(ttl)
+	return &xdsapi.ClusterLoadAssignment{
+		ClusterName:    cla.ClusterName,
+		Endpoints:      cla.Endpoints,
+		NamedEndpoints: cla.NamedEndpoints,
+		Policy:         policy,
+	}
+}
+
+// expiringServices returns the hostnames of the clusters of the connection whose load assignment was
+// sent at least half a TTL ago, or never, so that they are sent again before they go stale.
+func (con *XdsConnection) expiringServices(now time.Time, ttl time.Duration) map[string]struct{} {
+	con.mu.RLock()
+	defer con.mu.RUnlock()
+	var out map[string]struct{}
+	for _, clusterName := range con.Clusters {
+		if sent, f := con.edsSentAt[clusterName]; f && now.Sub(sent) < ttl/2 {
+			continue
+		}
+		_, _, hostname, _ := model.ParseSubsetKey(clusterName)
+		if out == nil {
+			out = make(map[string]struct{})
+		}
+		out[string(hostname)] = struct{}{}
+	}
+	return out
+}
+
+// refreshEndpoints pushes to each connected proxy the load assignments close to the end of their TTL,
+// the ones recently pushed on endpoint changes being left out.
+func (s *DiscoveryServer) refreshEndpoints(ttl time.Duration) {
+	adsClientsMutex.RLock()
+	connections := make([]*XdsConnection, 0, len(adsClients))
+	for _, con := range adsClients {
+		connections = append(connections, con)
+	}
+	adsClientsMutex.RUnlock()
+
+	now := time.Now()
+	push := s.globalPushContext()
+	for _, con := range connections {
+		edsUpdates := con.expiringServices(now, ttl)
+		if len(edsUpdates) == 0 {
+			continue
+		}
+		endpointRefreshes.Increment()
+		s.pushQueue.Enqueue(con, &model.PushRequest{
+			Full:       false,
+			Push:       push,
+			EdsUpdates: edsUpdates,
+			Start:      now,
+		})
+	}
+}
+
+// periodicRefreshEndpoints refreshes the load assignments of the connected proxies every quarter of the
+// TTL, so that each is sent again between half and three quarters of the TTL after it was last sent. The
+// period follows the changes of the TTL, and there is no refresh while it is 0.
+func (s *DiscoveryServer) periodicRefreshEndpoints(stopCh <-chan struct{}) {
+	var ticker *time.Ticker
+	var tickC <-chan time.Time
+	reset := func() {
+		if ticker != nil {
+			ticker.Stop()
+			ticker, tickC = nil, nil
+		}
+		if ttl := s.EndpointTTL(); ttl > 0 {
+			ticker = time.NewTicker(ttl / 4)
+			tickC = ticker.C
+		}
+	}
+	reset()
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+	for {
+		select {
+		case <-tickC:
+			if ttl := s.EndpointTTL(); ttl > 0 {
+				s.refreshEndpoints(ttl)
+			}
+		case <-s.endpointTTLChanged:
+			reset()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
 // getDestinationRule gets the DestinationRule for a given hostname. As an optimization, this also gets the service port,
 // which is needed to access the traffic policy from the destination rule.
 func getDestinationRule(push *model.PushContext, proxy *model.Proxy, hostname host.Name, clusterPort int) (*networkingapi.DestinationRule, *model.Port) {
@@ -889,20 +1039,71 @@ func endpointDiscoveryResponse(loadAssignments []*xdsapi.ClusterLoadAssignment,
 	return out
 }
 
+// endpointMerge is how the endpoints of the shards of a service, i.e. of its clusters, are merged.
+type endpointMerge struct {
+	policy aggregate.MergePolicy
+	// localCluster is the shard preferred by aggregate.MergePolicyPreferLocal.
+	localCluster string
+}
+
+// endpointKey identifies the same endpoint across shards.
+type endpointKey struct {
+	address string
+	port    uint32
+	network string
+}
+
+// endpointMerge returns how the endpoints of the services found in multiple clusters are merged, as
+// configured on the aggregate registry.
+func (s *DiscoveryServer) endpointMerge() endpointMerge {
+	if agg, ok := s.Env.ServiceDiscovery.(*aggregate.Controller); ok {
+		policy, localCluster := agg.EndpointMergePolicy()
+		return endpointMerge{policy: policy, localCluster: localCluster}
+	}
+	return endpointMerge{policy: aggregate.MergePolicyMergeAll}
+}
+
+// shardOrder returns the shards in the order their endpoints are merged: the local cluster first
+// with aggregate.MergePolicyPreferLocal, then by name, so that the same duplicates are kept every time.
+func (m endpointMerge) shardOrder(shards map[string][]*model.IstioEndpoint) []string {
+	out := make([]string, 0, len(shards))
+	for shard := range shards {
+		out = append(out, shard)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if m.policy == aggregate.MergePolicyPreferLocal && (out[i] == m.localCluster) != (out[j] == m.localCluster) {
+			return out[i] == m.localCluster
+		}
+		return out[i] < out[j]
+	})
+	return out
+}
+
 // build LocalityLbEndpoints for a cluster from existing EndpointShards.
+// If proxy is set, the zone hints of the endpoints are honored for its zone.
+// If the service is defined in multiple registries with weights, the endpoints of each shard are weighted.
+// The endpoints of the shards are merged as configured by merge.
 func buildLocalityLbEndpointsFromShards(
 	shards *EndpointShards,
+	svc *model.Service,
 	svcPort *model.Port,
 	epLabels labels.Collection,
 	clusterName string,
-	push *model.PushContext) []*endpoint.LocalityLbEndpoints {
+	proxy *model.Proxy,
+	push *model.PushContext,
+	merge endpointMerge) []*endpoint.LocalityLbEndpoints {
 	localityEpMap := make(map[string]*endpoint.LocalityLbEndpoints)
 
 	shards.mutex.Lock()
 	// The shards are updated independently, now need to filter and merge
 	// for this cluster
-	for _, endpoints := range shards.Shards {
-		for _, ep := range endpoints {
+	selected := make([]*model.IstioEndpoint, 0)
+	// shardOf is the shard of the selected endpoints.
+	shardOf := make(map[*model.IstioEndpoint]string)
+	dedupe := merge.policy == aggregate.MergePolicyDedupeByIPAndNetwork || merge.policy == aggregate.MergePolicyPreferLocal
+	seen := make(map[endpointKey]string)
+	for _, shard := range merge.shardOrder(shards.Shards) {
+		for _, ep := range shards.Shards[shard] {
 			if svcPort.Name != ep.ServicePortName {
 				continue
 			}
@@ -910,21 +1111,49 @@ func buildLocalityLbEndpointsFromShards(
 			if !epLabels.HasSubsetOf(ep.Labels) {
 				continue
 			}
-
-			locLbEps, found := localityEpMap[ep.Locality]
-			if !found {
-				locLbEps = &endpoint.LocalityLbEndpoints{
-					Locality:    util.ConvertLocality(ep.Locality),
-					LbEndpoints: make([]*endpoint.LbEndpoint, 0, len(endpoints)),
+			if dedupe {
+				k := endpointKey{address: ep.Address, port: ep.EndpointPort, network: ep.Network}
+				if first, f := seen[k]; f {
+					adsLog.Debugf("Dropping endpoint %v:%v of cluster %q, duplicate of cluster %q", k.address, k.port,
+						shard, first)
+					continue
 				}
-				localityEpMap[ep.Locality] = locLbEps
+				seen[k] = shard
 			}
-			if ep.EnvoyEndpoint == nil {
-				ep.EnvoyEndpoint = buildEnvoyLbEndpoint(ep.UID, ep.Family, ep.Address, ep.EndpointPort, ep.Network, ep.LbWeight, ep.TLSMode)
-			}
-			locLbEps.LbEndpoints = append(locLbEps.LbEndpoints, ep.EnvoyEndpoint)
+			selected = append(selected, ep)
+			shardOf[ep] = shard
+		}
+	}
 
+	if proxy != nil && proxy.Locality != nil {
+		selected = filterByZoneHints(selected, proxy.Locality.Zone)
+	}
+	shardScales := registryWeightScales(selected, shardOf, svc.Attributes.ClusterRegistryWeights)
+
+	for _, ep := range selected {
+		locLbEps, found := localityEpMap[ep.Locality]
+		if !found {
+			locLbEps = &endpoint.LocalityLbEndpoints{
+				Locality:    util.ConvertLocality(ep.Locality),
+				LbEndpoints: make([]*endpoint.LbEndpoint, 0, len(selected)),
+			}
+			localityEpMap[ep.Locality] = locLbEps
 		}
+		if ep.EnvoyEndpoint == nil {
+			ep.EnvoyEndpoint = buildEnvoyLbEndpoint(ep.UID, ep.Family, ep.Address, ep.EndpointPort, ep.Network, ep.LbWeight, ep.TLSMode, ep.HealthStatus)
+		}
+		lbEp := ep.EnvoyEndpoint
+		if scale, f := shardScales[shardOf[ep]]; f {
+			// The cached endpoint is shared by the clusters, so the weight is set in a copy.
+			weighted := *lbEp
+			weight := uint32(float64(lbEp.LoadBalancingWeight.GetValue())*scale + 0.5)
+			if weight == 0 {
+				weight = 1
+			}
+			weighted.LoadBalancingWeight = &wrappers.UInt32Value{Value: weight}
+			lbEp = &weighted
+		}
+		locLbEps.LbEndpoints = append(locLbEps.LbEndpoints, lbEp)
 	}
 	shards.mutex.Unlock()
 
@@ -949,6 +1178,63 @@ func buildLocalityLbEndpointsFromShards(
 	return locEps
 }
 
+// registryWeightScale is the total weight of the endpoints of a registry with a weight of 1, once
+// weighted. It keeps the precision of the split when the registries have many endpoints.
+const registryWeightScale = 1000
+
+// registryWeightScales returns the factor applied to the weight of the endpoints of each shard, so
+// that the total weight of the endpoints of each registry is proportional to its registry weight.
+// It returns nil, the endpoints being unweighted, unless the endpoints come from multiple shards,
+// all with a registry weight.
+func registryWeightScales(endpoints []*model.IstioEndpoint, shardOf map[*model.IstioEndpoint]string,
+	weights map[string]uint32) map[string]float64 {
+	if len(weights) < 2 {
+		return nil
+	}
+	totals := make(map[string]uint32)
+	for _, ep := range endpoints {
+		weight := ep.LbWeight
+		if weight == 0 {
+			weight = 1
+		}
+		totals[shardOf[ep]] += weight
+	}
+	if len(totals) < 2 {
+		return nil
+	}
+	scales := make(map[string]float64, len(totals))
+	for shard, total := range totals {
+		weight := weights[shard]
+		if weight == 0 {
+			return nil
+		}
+		scales[shard] = float64(weight) * registryWeightScale / float64(total)
+	}
+	return scales
+}
+
+// filterByZoneHints returns the endpoints hinted for the zone, the way kube-proxy honors topology
+// aware hints. Hints are ignored, and all endpoints returned, unless every endpoint has a hint and
+// at least one of them is hinted for the zone.
+func filterByZoneHints(endpoints []*model.IstioEndpoint, zone string) []*model.IstioEndpoint {
+	if zone == "" || len(endpoints) == 0 {
+		return endpoints
+	}
+	out := make([]*model.IstioEndpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if ep.HintedZone == "" {
+			return endpoints
+		}
+		if ep.HintedZone == zone {
+			out = append(out, ep)
+		}
+	}
+	if len(out) == 0 {
+		return endpoints
+	}
+	return out
+}
+
 func updateEdsStats(locEps []*endpoint.LocalityLbEndpoints, cluster string) {
 	edsInstances.With(clusterTag.Value(cluster)).Record(float64(len(locEps)))
 	epc := 0
@@ -0,0 +1,82 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
+)
+
+func TestEdsEndpointMergePolicy(t *testing.T) {
+	s := SetupDiscoveryServer(t, createEndpoints(1, 1)...)
+	agg := s.Env.ServiceDiscovery.(*aggregate.Controller)
+	agg.SetLocalCluster("cluster-2")
+
+	// The endpoints of cluster-1 have a weight of 1 and the ones of cluster-2 a weight of 2, to tell
+	// which of the duplicates is kept.
+	ep := func(addr, network string, weight uint32) *model.IstioEndpoint {
+		return &model.IstioEndpoint{Address: addr, EndpointPort: 80, ServicePortName: "http-port", Network: network,
+			LbWeight: weight}
+	}
+	hostname := "foo-0.com"
+	_ = s.EDSUpdate("cluster-1", hostname, "default", []*model.IstioEndpoint{
+		ep("1.1.1.1", "", 1), ep("1.1.1.2", "", 1)})
+	_ = s.EDSUpdate("cluster-2", hostname, "default", []*model.IstioEndpoint{
+		ep("1.1.1.2", "", 2), ep("1.1.1.3", "", 2), ep("1.1.1.1", "network-2", 2)})
+
+	cases := []struct {
+		policy aggregate.MergePolicy
+		want   map[string]uint32
+	}{
+		{aggregate.MergePolicyMergeAll, map[string]uint32{
+			"111.0.0.0": 1, "1.1.1.1/1": 1, "1.1.1.1/2": 2, "1.1.1.2/1": 1, "1.1.1.2/2": 2, "1.1.1.3": 2}},
+		// The same address in another network is not a duplicate.
+		{aggregate.MergePolicyDedupeByIPAndNetwork, map[string]uint32{
+			"111.0.0.0": 1, "1.1.1.1/1": 1, "1.1.1.1/2": 2, "1.1.1.2": 1, "1.1.1.3": 2}},
+		{aggregate.MergePolicyPreferLocal, map[string]uint32{
+			"111.0.0.0": 1, "1.1.1.1/1": 1, "1.1.1.1/2": 2, "1.1.1.2": 2, "1.1.1.3": 2}},
+	}
+	for _, c := range cases {
+		agg.SetMergePolicy(c.policy)
+		push := s.globalPushContext()
+		proxy := &model.Proxy{
+			Type:            model.SidecarProxy,
+			IPAddresses:     []string{"10.3.3.3"},
+			ID:              "random",
+			ConfigNamespace: "default",
+			Metadata:        &model.NodeMetadata{},
+		}
+		proxy.SetSidecarScope(push)
+		cla := s.loadAssignmentsForClusterIsolated(proxy, push, fmt.Sprintf("outbound|80||%s", hostname))
+		got := map[string]uint32{}
+		for _, locEps := range cla.Endpoints {
+			for _, lbEp := range locEps.LbEndpoints {
+				addr := lbEp.GetEndpoint().Address.GetSocketAddress().Address
+				weight := lbEp.LoadBalancingWeight.GetValue()
+				if _, f := c.want[addr]; !f {
+					addr = fmt.Sprintf("%s/%d", addr, weight)
+				}
+				got[addr] = weight
+			}
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%v: got endpoints %v, want %v", c.policy, got, c.want)
+		}
+	}
+}
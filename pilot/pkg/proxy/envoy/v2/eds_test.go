@@ -30,6 +30,7 @@ import (
 	"time"
 
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/api/v2/endpoint"
+	"github.com/golang/protobuf/ptypes"
 
 	"istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/bootstrap"
@@ -266,6 +267,24 @@ func TestDeleteService(t *testing.T) {
 	}
 }
 
+// Validates that the endpoints are pushed with their TTL.
+func TestEdsEndpointTTL(t *testing.T) {
+	server, tearDown := initLocalPilotTestEnv(t)
+	defer tearDown()
+	defer server.EnvoyXdsServer.SetEndpointTTL(server.EnvoyXdsServer.EndpointTTL())
+	server.EnvoyXdsServer.SetEndpointTTL(30 * time.Second)
+	addEdsCluster(server, "edsttl.svc.cluster.local", "http", "10.0.0.54", 8080)
+
+	adscConn := adsConnectAndWait(t, 0x0a0a0a0a)
+	defer adscConn.Close()
+	testEndpoints("10.0.0.54", "outbound|8080||edsttl.svc.cluster.local", adscConn, t)
+
+	cla := adscConn.GetEndpoints()["outbound|8080||edsttl.svc.cluster.local"]
+	if ttl, err := ptypes.Duration(cla.GetPolicy().GetEndpointStaleAfter()); err != nil || ttl != 30*time.Second {
+		t.Errorf("got endpoints stale after %v, want 30s", cla.GetPolicy().GetEndpointStaleAfter())
+	}
+}
+
 func adsConnectAndWait(t *testing.T, ip int) *adsc.ADSC {
 	adscConn, err := adsc.Dial(util.MockPilotGrpcAddr, "", &adsc.Config{
 		IP: testIP(uint32(ip)),
@@ -15,40 +15,32 @@
 package v2
 
 import (
-	"net"
-
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/api/v2/endpoint"
 	"github.com/golang/protobuf/ptypes/wrappers"
 
-	"istio.io/api/mesh/v1alpha1"
-
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
-	"istio.io/istio/pkg/config/host"
 )
 
 // EndpointsFilterFunc is a function that filters data from the ClusterLoadAssignment and returns updated one
-type EndpointsFilterFunc func(endpoints []endpoint.LocalityLbEndpoints, conn *XdsConnection, env *model.Environment) []*endpoint.LocalityLbEndpoints
+type EndpointsFilterFunc func(push *model.PushContext, endpoints []endpoint.LocalityLbEndpoints, conn *XdsConnection) []*endpoint.LocalityLbEndpoints
 
 // EndpointsByNetworkFilter is a network filter function to support Split Horizon EDS - filter the endpoints based on the network
 // of the connected sidecar. The filter will filter out all endpoints which are not present within the
 // sidecar network and add a gateway endpoint to remote networks that have endpoints (if gateway exists).
-// Information for the mesh networks is provided as a MeshNetwork config map.
-func EndpointsByNetworkFilter(endpoints []*endpoint.LocalityLbEndpoints, conn *XdsConnection, env *model.Environment) []*endpoint.LocalityLbEndpoints {
+// Information for the mesh networks is provided as a MeshNetwork config map, and by the gateways
+// discovered by the registries, collected by the push context.
+func EndpointsByNetworkFilter(push *model.PushContext, endpoints []*endpoint.LocalityLbEndpoints, conn *XdsConnection) []*endpoint.LocalityLbEndpoints {
 	// If the sidecar does not specify a network, ignore Split Horizon EDS and return all
 	network := conn.node.Metadata.Network
 
+	gateways := push.NetworkGateways()
+
 	// calculate the multiples of weight.
 	// It is needed to normalize the LB Weight across different networks.
 	multiples := 1
-	for _, network := range env.MeshNetworks.Networks {
-		num := 0
-		registryName := getNetworkRegistry(network)
-		for _, gw := range network.Gateways {
-			addrs := getGatewayAddresses(gw, registryName, env)
-			num += len(addrs)
-		}
-		if num > 1 {
+	for _, gws := range gateways {
+		if num := len(gws); num > 1 {
 			multiples *= num
 		}
 	}
@@ -85,43 +77,27 @@ func EndpointsByNetworkFilter(endpoints []*endpoint.LocalityLbEndpoints, conn *X
 		// for each one of those add a new endpoint that points to the network's
 		// gateway with the relevant weight
 		for network, w := range remoteEps {
-			networkConf, found := env.MeshNetworks.Networks[network]
-			if !found {
-				adsLog.Debugf("the endpoints within network %s will be ignored for no network configured", network)
-				continue
-			}
-			gws := networkConf.Gateways
+			gws := gateways[network]
 			if len(gws) == 0 {
 				adsLog.Debugf("the endpoints within network %s will be ignored for no gateways configured", network)
 				continue
 			}
 
-			registryName := getNetworkRegistry(networkConf)
-			gwEps := make([]*endpoint.LbEndpoint, 0)
+			gwEps := make([]*endpoint.LbEndpoint, 0, len(gws))
 			// There may be multiples gateways for the network. Add an LbEndpoint for
 			// each one of them
 			for _, gw := range gws {
-				gwAddresses := getGatewayAddresses(gw, registryName, env)
-				// If gateway addresses are found, create an endpoint for each one of them
-				if len(gwAddresses) > 0 {
-					for _, gwAddr := range gwAddresses {
-						epAddr := util.BuildAddress(gwAddr, gw.Port)
-						gwEp := &endpoint.LbEndpoint{
-							HostIdentifier: &endpoint.LbEndpoint_Endpoint{
-								Endpoint: &endpoint.Endpoint{
-									Address: epAddr,
-								},
-							},
-							LoadBalancingWeight: &wrappers.UInt32Value{
-								Value: w,
-							},
-						}
-						gwEps = append(gwEps, gwEp)
-					}
+				gwEp := &endpoint.LbEndpoint{
+					HostIdentifier: &endpoint.LbEndpoint_Endpoint{
+						Endpoint: &endpoint.Endpoint{
+							Address: util.BuildAddress(gw.Addr, gw.Port),
+						},
+					},
+					LoadBalancingWeight: &wrappers.UInt32Value{
+						Value: w,
+					},
 				}
-			}
-			if len(gwEps) == 0 {
-				continue
+				gwEps = append(gwEps, gwEp)
 			}
 			weight := w * uint32(multiples/len(gwEps))
 			for _, gwEp := range gwEps {
@@ -174,34 +150,3 @@ func createLocalityLbEndpoints(base *endpoint.LocalityLbEndpoints, lbEndpoints [
 	}
 	return ep
 }
-
-func getNetworkRegistry(network *v1alpha1.Network) string {
-	var registryName string
-	for _, eps := range network.Endpoints {
-		if eps != nil && len(eps.GetFromRegistry()) > 0 {
-			registryName = eps.GetFromRegistry()
-			break
-		}
-	}
-
-	return registryName
-}
-
-func getGatewayAddresses(gw *v1alpha1.Network_IstioNetworkGateway, registryName string, env *model.Environment) []string {
-	// First, if a gateway address is provided in the configuration use it. If the gateway address
-	// in the config was a hostname it got already resolved and replaced with an IP address
-	// when loading the config
-	if gwIP := net.ParseIP(gw.GetAddress()); gwIP != nil {
-		return []string{gw.GetAddress()}
-	}
-
-	// Second, try to find the gateway addresses by the provided service name
-	if gwSvcName := gw.GetRegistryServiceName(); len(gwSvcName) > 0 && len(registryName) > 0 {
-		svc, _ := env.GetService(host.Name(gwSvcName))
-		if svc != nil {
-			return svc.Attributes.ClusterExternalAddresses[registryName]
-		}
-	}
-
-	return nil
-}
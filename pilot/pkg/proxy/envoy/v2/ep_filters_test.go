@@ -15,6 +15,8 @@
 package v2
 
 import (
+	"fmt"
+	"reflect"
 	"sort"
 	"testing"
 
@@ -25,8 +27,11 @@ import (
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 
+	"istio.io/istio/pilot/pkg/config/memory"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/schemas"
 )
 
 type LbEpInfo struct {
@@ -143,7 +148,7 @@ func TestEndpointsByNetworkFilter(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			filtered := EndpointsByNetworkFilter(tt.endpoints, tt.conn, tt.env)
+			filtered := EndpointsByNetworkFilter(pushContext(t, tt.env), tt.endpoints, tt.conn)
 			if len(filtered) != len(tt.want) {
 				t.Errorf("Unexpected number of filtered endpoints: got %v, want %v", len(filtered), len(tt.want))
 				return
@@ -311,7 +316,7 @@ func TestEndpointsByNetworkFilter_RegistryServiceName(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			filtered := EndpointsByNetworkFilter(tt.endpoints, tt.conn, tt.env)
+			filtered := EndpointsByNetworkFilter(pushContext(t, tt.env), tt.endpoints, tt.conn)
 			if len(filtered) != len(tt.want) {
 				t.Errorf("Unexpected number of filtered endpoints: got %v, want %v", len(filtered), len(tt.want))
 				return
@@ -358,6 +363,61 @@ func xdsConnection(network string) *XdsConnection {
 	}
 }
 
+type gatewayDiscovery struct {
+	*MemServiceDiscovery
+	gateways map[string][]*model.NetworkGateway
+}
+
+func (d *gatewayDiscovery) NetworkGateways() map[string][]*model.NetworkGateway {
+	return d.gateways
+}
+
+func TestEndpointsByNetworkFilter_DiscoveredGateways(t *testing.T) {
+	//  - 1 configured gateway for network1
+	//  - 1 discovered gateway for network2, in addition to the configured ones
+	//  - 1 discovered gateway for network4, which has no configured gateways
+	env := environment()
+	env.MeshNetworks.Networks["network2"].Gateways = env.MeshNetworks.Networks["network2"].Gateways[:1]
+	env.ServiceDiscovery = &gatewayDiscovery{
+		MemServiceDiscovery: NewMemServiceDiscovery(nil, 0),
+		gateways: map[string][]*model.NetworkGateway{
+			"network2": {
+				{Network: "network2", Addr: "2.2.2.2", Port: 80},
+				{Network: "network2", Addr: "2.2.2.22", Port: 15443},
+			},
+			"network4": {
+				{Network: "network4", Addr: "4.4.4.4", Port: 15443},
+			},
+		},
+	}
+
+	filtered := EndpointsByNetworkFilter(pushContext(t, env), testEndpoints(), xdsConnection("network1"))
+	if len(filtered) != 1 {
+		t.Fatalf("Unexpected number of filtered endpoints: got %v, want 1", len(filtered))
+	}
+
+	// The configured and the discovered gateways of network2 share its endpoint, and
+	// the duplicated gateway 2.2.2.2:80 is only added once
+	want := map[string]uint32{
+		"10.0.0.1:0":     2,
+		"10.0.0.2:0":     2,
+		"2.2.2.2:80":     1,
+		"2.2.2.22:15443": 1,
+		"4.4.4.4:15443":  2,
+	}
+	got := map[string]uint32{}
+	for _, lbEp := range filtered[0].LbEndpoints {
+		addr := lbEp.GetEndpoint().Address.GetSocketAddress()
+		got[fmt.Sprintf("%s:%d", addr.Address, addr.GetPortValue())] = lbEp.LoadBalancingWeight.GetValue()
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected endpoints: got %v, want %v", got, want)
+	}
+	if w := filtered[0].LoadBalancingWeight.GetValue(); w != 8 {
+		t.Errorf("Unexpected weight: got %v, want 8", w)
+	}
+}
+
 // environment creates an Environment object with the following MeshNetworks configurations:
 //  - 1 gateway for network1
 //  - 2 gateway for network2
@@ -412,6 +472,19 @@ func environment() *model.Environment {
 	}
 }
 
+// pushContext returns the push context of the environment, collecting its network gateways.
+func pushContext(t *testing.T, env *model.Environment) *model.PushContext {
+	t.Helper()
+	m := mesh.DefaultMeshConfig()
+	env.Mesh = &m
+	env.IstioConfigStore = model.MakeIstioStore(memory.Make(schemas.Istio))
+	push := model.NewPushContext()
+	if err := push.InitContext(env, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	return push
+}
+
 // testEndpoints creates endpoints to be handed to the filter. It creates
 // 2 endpoints on network1, 1 endpoint on network2 and 1 endpoint on network4.
 func testEndpoints() []*endpoint.LocalityLbEndpoints {
@@ -473,3 +546,106 @@ func createLbEndpoints(lbEpsInfo []*LbEpInfo) []*endpoint.LbEndpoint {
 
 	return lbEndpoints
 }
+
+func TestFilterByZoneHints(t *testing.T) {
+	ep := func(address, zone string) *model.IstioEndpoint {
+		return &model.IstioEndpoint{Address: address, HintedZone: zone}
+	}
+	addresses := func(eps []*model.IstioEndpoint) []string {
+		out := make([]string, 0, len(eps))
+		for _, e := range eps {
+			out = append(out, e.Address)
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	tests := []struct {
+		name      string
+		endpoints []*model.IstioEndpoint
+		zone      string
+		want      []string
+	}{
+		{
+			name:      "hinted for zone",
+			endpoints: []*model.IstioEndpoint{ep("1.1.1.1", "zone1"), ep("2.2.2.2", "zone2"), ep("3.3.3.3", "zone1")},
+			zone:      "zone1",
+			want:      []string{"1.1.1.1", "3.3.3.3"},
+		},
+		{
+			name:      "no endpoint hinted for zone",
+			endpoints: []*model.IstioEndpoint{ep("1.1.1.1", "zone1"), ep("2.2.2.2", "zone2")},
+			zone:      "zone3",
+			want:      []string{"1.1.1.1", "2.2.2.2"},
+		},
+		{
+			name:      "endpoint without hint",
+			endpoints: []*model.IstioEndpoint{ep("1.1.1.1", "zone1"), ep("2.2.2.2", "")},
+			zone:      "zone1",
+			want:      []string{"1.1.1.1", "2.2.2.2"},
+		},
+		{
+			name:      "proxy without zone",
+			endpoints: []*model.IstioEndpoint{ep("1.1.1.1", "zone1"), ep("2.2.2.2", "zone2")},
+			zone:      "",
+			want:      []string{"1.1.1.1", "2.2.2.2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := addresses(filterByZoneHints(tt.endpoints, tt.zone))
+			if len(got) != len(tt.want) {
+				t.Fatalf("filterByZoneHints() => %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("filterByZoneHints() => %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildLocalityLbEndpointsRegistryWeights(t *testing.T) {
+	ep := func(addr string) *model.IstioEndpoint {
+		return &model.IstioEndpoint{Address: addr, EndpointPort: 80, ServicePortName: "http"}
+	}
+	port := &model.Port{Name: "http", Port: 80}
+	weights := func(shards *EndpointShards, svc *model.Service) map[string]uint32 {
+		locEps := buildLocalityLbEndpointsFromShards(shards, svc, port, nil, "outbound|80||hello", nil, model.NewPushContext(),
+			endpointMerge{})
+		out := map[string]uint32{}
+		for _, locEp := range locEps {
+			for _, lbEp := range locEp.LbEndpoints {
+				out[lbEp.GetEndpoint().Address.GetSocketAddress().Address] = lbEp.LoadBalancingWeight.GetValue()
+			}
+		}
+		return out
+	}
+	newShards := func() *EndpointShards {
+		return &EndpointShards{Shards: map[string][]*model.IstioEndpoint{
+			"cluster-1": {ep("1.1.1.1"), ep("1.1.1.2"), ep("1.1.1.3")},
+			"":          {ep("2.2.2.2")},
+		}}
+	}
+
+	// The endpoints of the ServiceEntry get 90% of the traffic, however many Kubernetes endpoints there are.
+	svc := &model.Service{Attributes: model.ServiceAttributes{
+		ClusterRegistryWeights: map[string]uint32{"cluster-1": 10, "": 90},
+	}}
+	want := map[string]uint32{"1.1.1.1": 3333, "1.1.1.2": 3333, "1.1.1.3": 3333, "2.2.2.2": 90000}
+	shards := newShards()
+	if got := weights(shards, svc); !reflect.DeepEqual(got, want) {
+		t.Errorf("weighted endpoints => %v, want %v", got, want)
+	}
+	if w := shards.Shards[""][0].EnvoyEndpoint.LoadBalancingWeight.GetValue(); w != 1 {
+		t.Errorf("the weight of the cached endpoint was changed to %d", w)
+	}
+
+	// The endpoints are not weighted unless all the registries set a weight.
+	svc.Attributes.ClusterRegistryWeights = map[string]uint32{"cluster-1": 0, "": 90}
+	want = map[string]uint32{"1.1.1.1": 1, "1.1.1.2": 1, "1.1.1.3": 1, "2.2.2.2": 1}
+	if got := weights(newShards(), svc); !reflect.DeepEqual(got, want) {
+		t.Errorf("unweighted endpoints => %v, want %v", got, want)
+	}
+}
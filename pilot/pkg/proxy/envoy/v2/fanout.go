@@ -0,0 +1,223 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	"github.com/golang/protobuf/proto"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+// gatewayConfigType is the type of the Gateway configs, as found in the config metadata of the resources.
+const gatewayConfigType = "gateway"
+
+// configRef identifies a config referenced by the generated Envoy resources.
+type configRef struct {
+	Type      string
+	Namespace string
+	Name      string
+}
+
+// ConfigFanout is the impact of a config on the proxies connected to this Pilot instance: the
+// number of proxies whose generated resources reference it, the number of resources referencing
+// it, and the serialized size in bytes of these resources for all the proxies.
+//
+// The resources of a VirtualService are its routes and the TCP/TLS filter chains it generated,
+// the resources of a DestinationRule its clusters, and the resources of a Gateway the listeners
+// and route configurations generated for its servers, shared with the other gateways merged on
+// the same ports.
+type ConfigFanout struct {
+	Type                string `json:"type"`
+	Namespace           string `json:"namespace"`
+	Name                string `json:"name"`
+	Proxies             int    `json:"proxies"`
+	Clusters            int    `json:"clusters,omitempty"`
+	Listeners           int    `json:"listeners,omitempty"`
+	FilterChains        int    `json:"filter_chains,omitempty"`
+	RouteConfigurations int    `json:"route_configurations,omitempty"`
+	Routes              int    `json:"routes,omitempty"`
+	Bytes               int    `json:"bytes"`
+}
+
+// configFanouts accumulates the fanout of the configs over the proxies.
+type configFanouts map[configRef]*ConfigFanout
+
+// configRefFromMetadata returns the config whose path is recorded in the istio metadata of a
+// resource, see util.BuildConfigInfoMetadata.
+func configRefFromMetadata(md *core.Metadata) (configRef, bool) {
+	path := md.GetFilterMetadata()[util.IstioMetadataKey].GetFields()["config"].GetStringValue()
+	// /apis/<group>/<version>/namespaces/<namespace>/<type>/<name>
+	parts := strings.Split(path, "/")
+	if len(parts) < 8 || parts[len(parts)-4] != "namespaces" {
+		return configRef{}, false
+	}
+	return configRef{Type: parts[len(parts)-2], Namespace: parts[len(parts)-3], Name: parts[len(parts)-1]}, true
+}
+
+// gatewayRefs returns the gateways owning the servers.
+func gatewayRefs(node *model.Proxy, servers []*networking.Server) []configRef {
+	if node.MergedGateway == nil {
+		return nil
+	}
+	seen := make(map[configRef]bool)
+	var out []configRef
+	for _, server := range servers {
+		parts := strings.SplitN(node.MergedGateway.GatewayNameForServer[server], "/", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		ref := configRef{Type: gatewayConfigType, Namespace: parts[0], Name: parts[1]}
+		if !seen[ref] {
+			seen[ref] = true
+			out = append(out, ref)
+		}
+	}
+	return out
+}
+
+// addProxy adds the fanout of the configs referenced by the resources generated for a proxy.
+func (f configFanouts) addProxy(node *model.Proxy, clusters []*xdsapi.Cluster, listeners []*xdsapi.Listener,
+	routes []*xdsapi.RouteConfiguration) {
+	touched := make(map[configRef]bool)
+	get := func(ref configRef) *ConfigFanout {
+		fanout, ok := f[ref]
+		if !ok {
+			fanout = &ConfigFanout{Type: ref.Type, Namespace: ref.Namespace, Name: ref.Name}
+			f[ref] = fanout
+		}
+		if !touched[ref] {
+			touched[ref] = true
+			fanout.Proxies++
+		}
+		return fanout
+	}
+
+	for _, c := range clusters {
+		if ref, ok := configRefFromMetadata(c.Metadata); ok {
+			fanout := get(ref)
+			fanout.Clusters++
+			fanout.Bytes += proto.Size(c)
+		}
+	}
+
+	for _, l := range listeners {
+		for _, fc := range l.FilterChains {
+			if ref, ok := configRefFromMetadata(fc.Metadata); ok {
+				fanout := get(ref)
+				fanout.FilterChains++
+				fanout.Bytes += proto.Size(fc)
+			}
+		}
+		if node.MergedGateway == nil {
+			continue
+		}
+		port := l.Address.GetSocketAddress().GetPortValue()
+		for _, ref := range gatewayRefs(node, node.MergedGateway.Servers[port]) {
+			fanout := get(ref)
+			fanout.Listeners++
+			fanout.Bytes += proto.Size(l)
+		}
+	}
+
+	for _, rc := range routes {
+		if node.MergedGateway != nil {
+			for _, ref := range gatewayRefs(node, node.MergedGateway.ServersByRouteName[rc.Name]) {
+				fanout := get(ref)
+				fanout.RouteConfigurations++
+				fanout.Bytes += proto.Size(rc)
+			}
+		}
+		for _, vh := range rc.VirtualHosts {
+			for _, r := range vh.Routes {
+				if ref, ok := configRefFromMetadata(r.Metadata); ok {
+					fanout := get(ref)
+					fanout.Routes++
+					fanout.Bytes += proto.Size(r)
+				}
+			}
+		}
+	}
+}
+
+// sorted returns the fanouts matching the filters, the most expensive first.
+func (f configFanouts) sorted(configType, namespace, name string) []*ConfigFanout {
+	out := make([]*ConfigFanout, 0, len(f))
+	for ref, fanout := range f {
+		if (configType != "" && ref.Type != configType) || (namespace != "" && ref.Namespace != namespace) ||
+			(name != "" && ref.Name != name) {
+			continue
+		}
+		out = append(out, fanout)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Bytes != out[j].Bytes {
+			return out[i].Bytes > out[j].Bytes
+		}
+		if out[i].Type != out[j].Type {
+			return out[i].Type < out[j].Type
+		}
+		if out[i].Namespace != out[j].Namespace {
+			return out[i].Namespace < out[j].Namespace
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// configFanoutz reports, for each config, how many of the proxies connected to this Pilot instance it
+// affects, how many of their generated resources reference it and its contribution to the push bytes.
+// The resources of all the proxies are generated, so it is expensive with many proxies. The type,
+// namespace and name query parameters restrict the output, e.g. type=virtual-service.
+func (s *DiscoveryServer) configFanoutz(w http.ResponseWriter, req *http.Request) {
+	adsClientsMutex.RLock()
+	connections := make([]*XdsConnection, 0, len(adsClients))
+	for _, con := range adsClients {
+		connections = append(connections, con)
+	}
+	adsClientsMutex.RUnlock()
+
+	push := s.globalPushContext()
+	fanouts := make(configFanouts)
+	for _, con := range connections {
+		con.mu.RLock()
+		node := con.node
+		con.mu.RUnlock()
+		if node == nil {
+			continue
+		}
+		fanouts.addProxy(node, s.generateRawClusters(node, push), s.generateRawListeners(con, push),
+			s.generateRawRoutes(con, push))
+	}
+
+	query := req.URL.Query()
+	out, err := json.MarshalIndent(fanouts.sorted(query.Get("type"), query.Get("namespace"), query.Get("name")), "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(out)
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"reflect"
+	"testing"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	xdsapi_listener "github.com/envoyproxy/go-control-plane/envoy/api/v2/listener"
+	route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	"github.com/golang/protobuf/proto"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+func configMetadata(configType, namespace, name string) *core.Metadata {
+	return util.BuildConfigInfoMetadata(model.ConfigMeta{
+		Group:     "networking.istio.io",
+		Version:   "v1alpha3",
+		Type:      configType,
+		Namespace: namespace,
+		Name:      name,
+	})
+}
+
+func TestConfigRefFromMetadata(t *testing.T) {
+	if got, ok := configRefFromMetadata(configMetadata("virtual-service", "default", "reviews")); !ok ||
+		got != (configRef{Type: "virtual-service", Namespace: "default", Name: "reviews"}) {
+		t.Errorf("configRefFromMetadata() = %v, %v", got, ok)
+	}
+	if _, ok := configRefFromMetadata(nil); ok {
+		t.Error("configRefFromMetadata() should not find a config without metadata")
+	}
+}
+
+func TestConfigFanouts(t *testing.T) {
+	reviewsRoute := &route.Route{Name: "reviews", Metadata: configMetadata("virtual-service", "default", "reviews")}
+	reviewsRoutes := &xdsapi.RouteConfiguration{
+		Name: "80",
+		VirtualHosts: []*route.VirtualHost{{
+			Name:   "reviews:80",
+			Routes: []*route.Route{reviewsRoute, {Name: "default"}},
+		}},
+	}
+	ratingsCluster := &xdsapi.Cluster{Name: "ratings", Metadata: configMetadata("destination-rule", "default", "ratings")}
+	tcpChain := &xdsapi_listener.FilterChain{Metadata: configMetadata("virtual-service", "default", "mongo")}
+	sidecarListener := &xdsapi.Listener{Name: "0.0.0.0_27017", FilterChains: []*xdsapi_listener.FilterChain{tcpChain}}
+
+	server := &networking.Server{Port: &networking.Port{Number: 80, Protocol: "HTTP", Name: "http"}}
+	gatewayNode := &model.Proxy{MergedGateway: &model.MergedGateway{
+		Servers:              map[uint32][]*networking.Server{80: {server}},
+		GatewayNameForServer: map[*networking.Server]string{server: "istio-system/ingress"},
+		ServersByRouteName:   map[string][]*networking.Server{"http.80": {server}},
+	}}
+	gatewayListener := &xdsapi.Listener{
+		Name: "0.0.0.0_80",
+		Address: &core.Address{Address: &core.Address_SocketAddress{SocketAddress: &core.SocketAddress{
+			Address:       "0.0.0.0",
+			PortSpecifier: &core.SocketAddress_PortValue{PortValue: 80},
+		}}},
+	}
+	gatewayRoutes := &xdsapi.RouteConfiguration{
+		Name: "http.80",
+		VirtualHosts: []*route.VirtualHost{{
+			Name:   "reviews:80",
+			Routes: []*route.Route{reviewsRoute},
+		}},
+	}
+
+	fanouts := make(configFanouts)
+	for i := 0; i < 2; i++ {
+		fanouts.addProxy(&model.Proxy{}, []*xdsapi.Cluster{ratingsCluster, {Name: "other"}},
+			[]*xdsapi.Listener{sidecarListener}, []*xdsapi.RouteConfiguration{reviewsRoutes})
+	}
+	fanouts.addProxy(gatewayNode, nil, []*xdsapi.Listener{gatewayListener}, []*xdsapi.RouteConfiguration{gatewayRoutes})
+
+	want := []*ConfigFanout{
+		{
+			Type: "gateway", Namespace: "istio-system", Name: "ingress", Proxies: 1, Listeners: 1, RouteConfigurations: 1,
+			Bytes: proto.Size(gatewayListener) + proto.Size(gatewayRoutes),
+		},
+		{
+			Type: "destination-rule", Namespace: "default", Name: "ratings", Proxies: 2, Clusters: 2,
+			Bytes: 2 * proto.Size(ratingsCluster),
+		},
+		{
+			Type: "virtual-service", Namespace: "default", Name: "reviews", Proxies: 3, Routes: 3,
+			Bytes: 3 * proto.Size(reviewsRoute),
+		},
+		{
+			Type: "virtual-service", Namespace: "default", Name: "mongo", Proxies: 2, FilterChains: 2,
+			Bytes: 2 * proto.Size(tcpChain),
+		},
+	}
+	sort := func(fanouts []*ConfigFanout) map[configRef]ConfigFanout {
+		out := make(map[configRef]ConfigFanout)
+		for _, f := range fanouts {
+			out[configRef{Type: f.Type, Namespace: f.Namespace, Name: f.Name}] = *f
+		}
+		return out
+	}
+	got := fanouts.sorted("", "", "")
+	if !reflect.DeepEqual(sort(got), sort(want)) {
+		t.Fatalf("got fanouts %+v, want %+v", sort(got), sort(want))
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i-1].Bytes < got[i].Bytes {
+			t.Errorf("fanouts not sorted by bytes: %v before %v", got[i-1].Bytes, got[i].Bytes)
+		}
+	}
+
+	got = fanouts.sorted("virtual-service", "default", "reviews")
+	if len(got) != 1 || got[0].Name != "reviews" {
+		t.Errorf("got filtered fanouts %+v, want reviews only", got)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"context"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/spiffe"
+)
+
+// VirtualHostType is the type URL passed to the ResourceFilters for the virtual hosts of the route
+// configurations.
+const VirtualHostType = "type.googleapis.com/envoy.api.v2.route.VirtualHost"
+
+// ResourceFilter decides which of the generated resources are sent to a proxy, based on its identity.
+// Filters are registered with RegisterResourceFilter, so that multi-tenant platforms can keep the
+// resources of other tenants from a proxy even when a mistake in the mesh config would leak them.
+type ResourceFilter interface {
+	// Allow returns true if the resource of the type URL can be sent to the proxy. The resource is a
+	// *xdsapi.Cluster, *xdsapi.Listener, *xdsapi.RouteConfiguration, *route.VirtualHost of a route
+	// configuration, *xdsapi.ClusterLoadAssignment, or the *any.Any built by a registered Generator.
+	// The resources may be shared by the proxies, and must not be modified.
+	Allow(proxy *ProxyIdentity, typeURL string, resource proto.Message) bool
+}
+
+// ProxyIdentity is the identity of a proxy connected to the ADS server.
+type ProxyIdentity struct {
+	// Identities are the SPIFFE identities of the client certificate of the connection, empty if the
+	// connection is not authenticated with mutual TLS.
+	Identities []string
+
+	// Node is the proxy as described in its discovery requests, which is not authenticated.
+	Node *model.Proxy
+}
+
+// RegisterResourceFilter registers a filter of the resources sent to the proxies. A resource is sent
+// only if all the filters allow it. It must be called before the server is started.
+func (s *DiscoveryServer) RegisterResourceFilter(f ResourceFilter) {
+	s.resourceFilters = append(s.resourceFilters, f)
+}
+
+// peerIdentities returns the SPIFFE identities of the client certificate of the stream.
+func peerIdentities(ctx context.Context) []string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return nil
+	}
+	var identities []string
+	for _, uri := range tlsInfo.State.PeerCertificates[0].URIs {
+		if uri.Scheme == spiffe.Scheme {
+			identities = append(identities, uri.String())
+		}
+	}
+	return identities
+}
+
+// allowed returns true if all the registered filters allow the resource to be sent to the proxy.
+func (s *DiscoveryServer) allowed(proxy *ProxyIdentity, typeURL string, resource proto.Message) bool {
+	for _, f := range s.resourceFilters {
+		if !f.Allow(proxy, typeURL, resource) {
+			filteredResources.With(typeTag.Value(typeURL)).Increment()
+			return false
+		}
+	}
+	return true
+}
+
+func (s *DiscoveryServer) proxyIdentity(con *XdsConnection) *ProxyIdentity {
+	return &ProxyIdentity{Identities: con.Identities, Node: con.node}
+}
+
+func (s *DiscoveryServer) filterClusters(con *XdsConnection, clusters []*xdsapi.Cluster) []*xdsapi.Cluster {
+	if len(s.resourceFilters) == 0 {
+		return clusters
+	}
+	proxy := s.proxyIdentity(con)
+	out := make([]*xdsapi.Cluster, 0, len(clusters))
+	for _, c := range clusters {
+		if s.allowed(proxy, ClusterType, c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (s *DiscoveryServer) filterListeners(con *XdsConnection, listeners []*xdsapi.Listener) []*xdsapi.Listener {
+	if len(s.resourceFilters) == 0 {
+		return listeners
+	}
+	proxy := s.proxyIdentity(con)
+	out := make([]*xdsapi.Listener, 0, len(listeners))
+	for _, l := range listeners {
+		if s.allowed(proxy, ListenerType, l) {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// filterRoutes filters the route configurations, and their virtual hosts. The route configurations
+// with denied virtual hosts are copied rather than mutated.
+func (s *DiscoveryServer) filterRoutes(con *XdsConnection, routes []*xdsapi.RouteConfiguration) []*xdsapi.RouteConfiguration {
+	if len(s.resourceFilters) == 0 {
+		return routes
+	}
+	proxy := s.proxyIdentity(con)
+	out := make([]*xdsapi.RouteConfiguration, 0, len(routes))
+	for _, rc := range routes {
+		if !s.allowed(proxy, RouteType, rc) {
+			continue
+		}
+		virtualHosts := make([]*route.VirtualHost, 0, len(rc.VirtualHosts))
+		for _, vh := range rc.VirtualHosts {
+			if s.allowed(proxy, VirtualHostType, vh) {
+				virtualHosts = append(virtualHosts, vh)
+			}
+		}
+		if len(virtualHosts) != len(rc.VirtualHosts) {
+			filtered := *rc
+			filtered.VirtualHosts = virtualHosts
+			rc = &filtered
+		}
+		out = append(out, rc)
+	}
+	return out
+}
+
+func (s *DiscoveryServer) filterLoadAssignments(con *XdsConnection,
+	loadAssignments []*xdsapi.ClusterLoadAssignment) []*xdsapi.ClusterLoadAssignment {
+	if len(s.resourceFilters) == 0 {
+		return loadAssignments
+	}
+	proxy := s.proxyIdentity(con)
+	out := make([]*xdsapi.ClusterLoadAssignment, 0, len(loadAssignments))
+	for _, cla := range loadAssignments {
+		if s.allowed(proxy, EndpointType, cla) {
+			out = append(out, cla)
+		}
+	}
+	return out
+}
+
+func (s *DiscoveryServer) filterGenerated(con *XdsConnection, typeURL string, resources []*any.Any) []*any.Any {
+	if len(s.resourceFilters) == 0 {
+		return resources
+	}
+	proxy := s.proxyIdentity(con)
+	out := make([]*any.Any, 0, len(resources))
+	for _, r := range resources {
+		if s.allowed(proxy, typeURL, r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// tenantFilter only allows the resources whose name is prefixed by the namespace of the identity.
+type tenantFilter struct{}
+
+func (tenantFilter) Allow(proxy *ProxyIdentity, typeURL string, resource proto.Message) bool {
+	if len(proxy.Identities) == 0 {
+		return false
+	}
+	// spiffe://cluster.local/ns/<namespace>/sa/<service account>
+	namespace := strings.Split(proxy.Identities[0], "/")[4]
+	var name string
+	switch r := resource.(type) {
+	case *xdsapi.Cluster:
+		name = r.Name
+	case *xdsapi.RouteConfiguration:
+		return true
+	case *route.VirtualHost:
+		name = r.Name
+	default:
+		return true
+	}
+	return strings.HasPrefix(name, namespace+"/")
+}
+
+func TestResourceFilter(t *testing.T) {
+	s := &DiscoveryServer{}
+	con := &XdsConnection{
+		node:       &model.Proxy{ID: "app.tenant-a"},
+		Identities: []string{"spiffe://cluster.local/ns/tenant-a/sa/app"},
+	}
+	clusters := []*xdsapi.Cluster{{Name: "tenant-a/app"}, {Name: "tenant-b/app"}}
+	routes := []*xdsapi.RouteConfiguration{{
+		Name:         "80",
+		VirtualHosts: []*route.VirtualHost{{Name: "tenant-a/app"}, {Name: "tenant-b/app"}},
+	}}
+
+	if got := s.filterClusters(con, clusters); !reflect.DeepEqual(got, clusters) {
+		t.Errorf("got clusters %v without filter, want all of them", got)
+	}
+
+	s.RegisterResourceFilter(tenantFilter{})
+	if got := s.filterClusters(con, clusters); len(got) != 1 || got[0].Name != "tenant-a/app" {
+		t.Errorf("got clusters %v, want the clusters of tenant-a only", got)
+	}
+	got := s.filterRoutes(con, routes)
+	if len(got) != 1 || len(got[0].VirtualHosts) != 1 || got[0].VirtualHosts[0].Name != "tenant-a/app" {
+		t.Errorf("got routes %v, want the virtual hosts of tenant-a only", got)
+	}
+	if len(routes[0].VirtualHosts) != 2 {
+		t.Error("filterRoutes() should not modify the generated route configurations")
+	}
+
+	con.Identities = nil
+	if got := s.filterClusters(con, clusters); len(got) != 0 {
+		t.Errorf("got clusters %v for an unauthenticated proxy, want none", got)
+	}
+}
+
+func TestPeerIdentities(t *testing.T) {
+	spiffeID, _ := url.Parse("spiffe://cluster.local/ns/default/sa/app")
+	other, _ := url.Parse("https://example.com")
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{{URIs: []*url.URL{other, spiffeID}}},
+		}},
+	})
+	if got := peerIdentities(ctx); !reflect.DeepEqual(got, []string{spiffeID.String()}) {
+		t.Errorf("peerIdentities() = %v, want %v", got, spiffeID)
+	}
+	if got := peerIdentities(peer.NewContext(context.Background(), &peer.Peer{})); len(got) != 0 {
+		t.Errorf("peerIdentities() = %v without TLS, want none", got)
+	}
+}
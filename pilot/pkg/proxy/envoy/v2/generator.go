@@ -0,0 +1,152 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"fmt"
+	"time"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/grpc/codes"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// Generator generates the resources of a type URL which is not built into the ADS server, such
+// as NDS, ECDS or custom resources. Generators are registered with RegisterGenerator, so that new
+// resource types don't require changes to the ADS core.
+type Generator interface {
+	// Generate returns the resources for the proxy. resourceNames are the names of the resources
+	// requested by the proxy, empty if it watches all of them.
+	Generate(proxy *model.Proxy, push *model.PushContext, resourceNames []string) ([]*any.Any, error)
+}
+
+// WatchedResource tracks the resources of a generated type watched by a proxy.
+type WatchedResource struct {
+	// ResourceNames are the names of the watched resources, empty for all resources.
+	ResourceNames []string
+
+	// Last nonce sent and ack'd, used for debugging
+	NonceSent, NonceAcked string
+}
+
+// RegisterGenerator registers the generator of the resources of the type URL. It must be called
+// before the server is started. The built-in types and the already registered types can't be
+// overridden.
+func (s *DiscoveryServer) RegisterGenerator(typeURL string, g Generator) error {
+	switch typeURL {
+	case ClusterType, EndpointType, ListenerType, RouteType:
+		return fmt.Errorf("type %s is built into the ADS server", typeURL)
+	}
+	if _, f := s.generators[typeURL]; f {
+		return fmt.Errorf("a generator is already registered for type %s", typeURL)
+	}
+	if s.generators == nil {
+		s.generators = map[string]Generator{}
+	}
+	s.generators[typeURL] = g
+	return nil
+}
+
+// handleGeneratedRequest handles a discovery request of a type with a registered generator,
+// pushing the resources unless the request is an ACK or a NACK.
+func (s *DiscoveryServer) handleGeneratedRequest(con *XdsConnection, g Generator, discReq *xdsapi.DiscoveryRequest) error {
+	typeURL := discReq.TypeUrl
+	// A request with an expired nonce changing the resources is still handled.
+	expired := isExpiredNonce(con, discReq)
+	if discReq.ErrorDetail != nil {
+		if !expired {
+			errCode := codes.Code(discReq.ErrorDetail.Code)
+			adsLog.Warnf("ADS:%s: ACK ERROR %v %s %s:%s", typeURL, con.PeerAddr, con.ConID, errCode.String(), discReq.ErrorDetail.GetMessage())
+			incrementXDSRejects(generatorRejects.With(typeTag.Value(typeURL)), con.node.ID, errCode.String())
+		}
+		return nil
+	}
+
+	con.mu.Lock()
+	w, watched := con.Watched[typeURL]
+	if watched && discReq.ResponseNonce != "" && listEqualUnordered(w.ResourceNames, discReq.ResourceNames) {
+		if !expired {
+			w.NonceAcked = discReq.ResponseNonce
+			con.lastAcked = time.Now()
+		}
+		con.mu.Unlock()
+		adsLog.Debugf("ADS:%s: ACK %s %s %s %s", typeURL, con.PeerAddr, con.ConID, discReq.VersionInfo, discReq.ResponseNonce)
+		return nil
+	}
+	if con.Watched == nil {
+		con.Watched = map[string]*WatchedResource{}
+	}
+	con.Watched[typeURL] = &WatchedResource{ResourceNames: discReq.ResourceNames}
+	con.mu.Unlock()
+
+	adsLog.Debugf("ADS:%s: REQ %s %s resources:%d", typeURL, con.PeerAddr, con.ConID, len(discReq.ResourceNames))
+	return s.pushGenerated(con, typeURL, g, s.globalPushContext(), versionInfo())
+}
+
+// pushGenerated generates and sends the resources of the type to the proxy.
+func (s *DiscoveryServer) pushGenerated(con *XdsConnection, typeURL string, g Generator, push *model.PushContext, version string) error {
+	con.mu.RLock()
+	w := con.Watched[typeURL]
+	con.mu.RUnlock()
+	if w == nil {
+		return nil
+	}
+
+	pushStart := time.Now()
+	resources, err := g.Generate(con.node, push, w.ResourceNames)
+	if err != nil {
+		// Failing to generate the resources of a type shouldn't break the other types.
+		adsLog.Errorf("ADS:%s: failed to generate resources for node:%s: %v", typeURL, con.node.ID, err)
+		generatorPushes.With(typeTag.Value(typeURL + "_builderr")).Increment()
+		return nil
+	}
+	resources = s.filterGenerated(con, typeURL, resources)
+	response := &xdsapi.DiscoveryResponse{
+		TypeUrl:     typeURL,
+		VersionInfo: version,
+		Nonce:       nonce(push.Version),
+		Resources:   resources,
+	}
+	err = con.send(response)
+	generatorPushTime.With(typeTag.Value(typeURL)).Record(time.Since(pushStart).Seconds())
+	if err != nil {
+		adsLog.Warnf("ADS:%s: Send failure %s: %v", typeURL, con.ConID, err)
+		recordSendError(generatorPushes.With(typeTag.Value(typeURL+"_senderr")), err)
+		return err
+	}
+	generatorPushes.With(typeTag.Value(typeURL)).Increment()
+
+	adsLog.Infof("ADS:%s: PUSH for node:%s resources:%d", typeURL, con.node.ID, len(resources))
+	return nil
+}
+
+// pushAllGenerated pushes the resources of all the generated types watched by the proxy.
+func (s *DiscoveryServer) pushAllGenerated(con *XdsConnection, push *model.PushContext, version string) error {
+	con.mu.RLock()
+	typeURLs := make([]string, 0, len(con.Watched))
+	for typeURL := range con.Watched {
+		typeURLs = append(typeURLs, typeURL)
+	}
+	con.mu.RUnlock()
+
+	for _, typeURL := range typeURLs {
+		if err := s.pushGenerated(con, typeURL, s.generators[typeURL], push, version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/genproto/googleapis/rpc/status"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+const testGeneratedType = typePrefix + "Test"
+
+type fakeGenerator struct {
+	names []string
+	err   error
+}
+
+func (g *fakeGenerator) Generate(_ *model.Proxy, _ *model.PushContext, resourceNames []string) ([]*any.Any, error) {
+	g.names = resourceNames
+	if g.err != nil {
+		return nil, g.err
+	}
+	out := make([]*any.Any, 0, len(resourceNames))
+	for _, name := range resourceNames {
+		out = append(out, &any.Any{TypeUrl: testGeneratedType, Value: []byte(name)})
+	}
+	return out, nil
+}
+
+type recordingStream struct {
+	fakeStream
+	sent []*xdsapi.DiscoveryResponse
+}
+
+func (h *recordingStream) Send(resp *xdsapi.DiscoveryResponse) error {
+	h.sent = append(h.sent, resp)
+	return nil
+}
+
+func TestRegisterGenerator(t *testing.T) {
+	s := &DiscoveryServer{}
+	if err := s.RegisterGenerator(ClusterType, &fakeGenerator{}); err == nil {
+		t.Error("expected built-in type to be rejected")
+	}
+	if err := s.RegisterGenerator(testGeneratedType, &fakeGenerator{}); err != nil {
+		t.Fatalf("RegisterGenerator() failed: %v", err)
+	}
+	if err := s.RegisterGenerator(testGeneratedType, &fakeGenerator{}); err == nil {
+		t.Error("expected duplicate registration to be rejected")
+	}
+}
+
+func TestHandleGeneratedRequest(t *testing.T) {
+	s := &DiscoveryServer{Env: &model.Environment{PushContext: model.NewPushContext()}}
+	g := &fakeGenerator{}
+	if err := s.RegisterGenerator(testGeneratedType, g); err != nil {
+		t.Fatal(err)
+	}
+	stream := &recordingStream{}
+	con := newXdsConnection("10.0.0.1", stream)
+	con.node = &model.Proxy{ID: "proxy"}
+
+	// A request pushes the generated resources.
+	req := &xdsapi.DiscoveryRequest{TypeUrl: testGeneratedType, ResourceNames: []string{"a", "b"}}
+	if err := s.handleGeneratedRequest(con, g, req); err != nil {
+		t.Fatalf("handleGeneratedRequest() failed: %v", err)
+	}
+	if len(stream.sent) != 1 || len(stream.sent[0].Resources) != 2 || stream.sent[0].TypeUrl != testGeneratedType {
+		t.Fatalf("got responses %v, want one response with 2 resources", stream.sent)
+	}
+	nonce := stream.sent[0].Nonce
+
+	// The ACK and the NACK are not pushed.
+	ack := &xdsapi.DiscoveryRequest{TypeUrl: testGeneratedType, ResourceNames: []string{"b", "a"}, ResponseNonce: nonce}
+	if err := s.handleGeneratedRequest(con, g, ack); err != nil {
+		t.Fatal(err)
+	}
+	nack := &xdsapi.DiscoveryRequest{TypeUrl: testGeneratedType, ResponseNonce: nonce, ErrorDetail: &status.Status{Message: "rejected"}}
+	if err := s.handleGeneratedRequest(con, g, nack); err != nil {
+		t.Fatal(err)
+	}
+	if len(stream.sent) != 1 {
+		t.Fatalf("got %d responses, want no push for ACK and NACK", len(stream.sent))
+	}
+	if got := con.Watched[testGeneratedType].NonceAcked; got != nonce {
+		t.Errorf("got nonce acked %q, want %q", got, nonce)
+	}
+
+	// Full pushes include the watched generated resources.
+	if err := s.pushAllGenerated(con, s.globalPushContext(), versionInfo()); err != nil {
+		t.Fatal(err)
+	}
+	if len(stream.sent) != 2 || !reflect.DeepEqual(g.names, []string{"a", "b"}) {
+		t.Fatalf("got %d responses for %v, want a push of [a b]", len(stream.sent), g.names)
+	}
+
+	// Generation failures are not sent, but don't break the stream.
+	g.err = errors.New("generation failed")
+	if err := s.pushAllGenerated(con, s.globalPushContext(), versionInfo()); err != nil {
+		t.Fatalf("pushAllGenerated() => %v, want no error", err)
+	}
+	if len(stream.sent) != 2 {
+		t.Fatalf("got %d responses, want no push on generation failure", len(stream.sent))
+	}
+}
@@ -26,7 +26,7 @@ import (
 func (s *DiscoveryServer) pushLds(con *XdsConnection, push *model.PushContext, version string) error {
 	// TODO: Modify interface to take services, and config instead of making library query registry
 	pushStart := time.Now()
-	rawListeners := s.generateRawListeners(con, push)
+	rawListeners := s.filterListeners(con, s.generateRawListeners(con, push))
 
 	if s.DebugConfigs {
 		con.LDSListeners = rawListeners
@@ -0,0 +1,283 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/api/v2/endpoint"
+	route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/config/host"
+)
+
+// resourceLimits are the maximum numbers of clusters, virtual hosts and endpoints pushed to a proxy,
+// 0 for no limit.
+type resourceLimits struct {
+	clusters  int
+	routes    int
+	endpoints int
+}
+
+// proxyResourceLimits returns the resource limits of the proxy: the limits of Pilot, unless overridden
+// by the metadata of the proxy.
+func proxyResourceLimits(node *model.Proxy) resourceLimits {
+	limits := resourceLimits{
+		clusters:  features.MaxClustersPerProxy,
+		routes:    features.MaxRoutesPerProxy,
+		endpoints: features.MaxEndpointsPerProxy,
+	}
+	if node == nil || node.Metadata == nil {
+		return limits
+	}
+	for _, o := range []struct {
+		value string
+		limit *int
+	}{
+		{node.Metadata.MaxClusters, &limits.clusters},
+		{node.Metadata.MaxRoutes, &limits.routes},
+		{node.Metadata.MaxEndpoints, &limits.endpoints},
+	} {
+		if o.value == "" {
+			continue
+		}
+		limit, err := strconv.Atoi(o.value)
+		if err != nil || limit < 0 {
+			adsLog.Warnf("Ignoring invalid resource limit %q of node %s", o.value, node.ID)
+			continue
+		}
+		*o.limit = limit
+	}
+	return limits
+}
+
+// resourceTruncation records the resources dropped from the last pushes to a proxy because of its limits.
+type resourceTruncation struct {
+	clusters int
+	routes   int
+	// endpoints are the dropped endpoints by cluster, as EDS may push a part of the clusters only.
+	endpoints map[string]int
+}
+
+func (t *resourceTruncation) endpointCount() int {
+	n := 0
+	for _, dropped := range t.endpoints {
+		n += dropped
+	}
+	return n
+}
+
+func (t *resourceTruncation) degraded() bool {
+	return t.clusters > 0 || t.routes > 0 || t.endpointCount() > 0
+}
+
+// localPriority is the priority of a resource for the service of the given hostname: 0 for the services
+// of the namespace of the proxy, 1 otherwise.
+func localPriority(node *model.Proxy, push *model.PushContext, hostname host.Name) int {
+	if push.ServiceByHostnameAndNamespace[hostname][node.ConfigNamespace] != nil {
+		return 0
+	}
+	return 1
+}
+
+// limitClusters returns at most limit clusters, and the number of dropped clusters. The clusters which
+// are not outbound, e.g. inbound or passthrough, are kept first, then the outbound clusters of the
+// services of the namespace of the proxy.
+func limitClusters(node *model.Proxy, push *model.PushContext, clusters []*xdsapi.Cluster,
+	limit int) ([]*xdsapi.Cluster, int) {
+	if limit <= 0 || len(clusters) <= limit {
+		return clusters, 0
+	}
+	priorities := make(map[*xdsapi.Cluster]int, len(clusters))
+	for _, c := range clusters {
+		direction, _, hostname, _ := model.ParseSubsetKey(c.Name)
+		if direction == model.TrafficDirectionOutbound {
+			priorities[c] = 1 + localPriority(node, push, hostname)
+		}
+	}
+	out := append([]*xdsapi.Cluster(nil), clusters...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return priorities[out[i]] < priorities[out[j]]
+	})
+	return out[:limit], len(clusters) - limit
+}
+
+// limitRoutes returns the route configurations with at most limit virtual hosts, and the number of
+// dropped virtual hosts. The catch-all virtual hosts are kept first, then the virtual hosts of the
+// services of the namespace of the proxy. The route configurations are copied, as they may be shared.
+func limitRoutes(node *model.Proxy, push *model.PushContext, routes []*xdsapi.RouteConfiguration,
+	limit int) ([]*xdsapi.RouteConfiguration, int) {
+	if limit <= 0 {
+		return routes, 0
+	}
+	var vhosts []*route.VirtualHost
+	for _, r := range routes {
+		vhosts = append(vhosts, r.VirtualHosts...)
+	}
+	if len(vhosts) <= limit {
+		return routes, 0
+	}
+	priorities := make(map[*route.VirtualHost]int, len(vhosts))
+	for _, vh := range vhosts {
+		priorities[vh] = 1 + localPriority(node, push, host.Name(strings.SplitN(vh.Name, ":", 2)[0]))
+		for _, domain := range vh.Domains {
+			if domain == "*" {
+				priorities[vh] = 0
+				break
+			}
+		}
+	}
+	sort.SliceStable(vhosts, func(i, j int) bool {
+		return priorities[vhosts[i]] < priorities[vhosts[j]]
+	})
+	kept := make(map[*route.VirtualHost]bool, limit)
+	for _, vh := range vhosts[:limit] {
+		kept[vh] = true
+	}
+
+	out := make([]*xdsapi.RouteConfiguration, 0, len(routes))
+	for _, r := range routes {
+		limited := *r
+		limited.VirtualHosts = make([]*route.VirtualHost, 0, len(r.VirtualHosts))
+		for _, vh := range r.VirtualHosts {
+			if kept[vh] {
+				limited.VirtualHosts = append(limited.VirtualHosts, vh)
+			}
+		}
+		out = append(out, &limited)
+	}
+	return out, len(vhosts) - limit
+}
+
+// endpointQuota returns the maximum number of endpoints of each cluster of the connection, the limit
+// shared evenly by the clusters, so that the incremental pushes keep the same endpoints. Each cluster
+// keeps at least one endpoint.
+func endpointQuota(con *XdsConnection, limit int) int {
+	if limit <= 0 || len(con.Clusters) == 0 {
+		return 0
+	}
+	if quota := limit / len(con.Clusters); quota > 0 {
+		return quota
+	}
+	return 1
+}
+
+// limitEndpoints returns the load assignment with at most quota endpoints, and the number of dropped
+// endpoints. The healthy endpoints of the localities with the lowest priority, i.e. the closest to the
+// proxy, are kept first. The load assignment is copied, as it may be shared.
+func limitEndpoints(cla *xdsapi.ClusterLoadAssignment, quota int) (*xdsapi.ClusterLoadAssignment, int) {
+	type lbEndpointRef struct {
+		locality, endpoint int
+		priority           uint32
+		unhealthy          bool
+	}
+	if quota <= 0 {
+		return cla, 0
+	}
+	var refs []lbEndpointRef
+	for i, locality := range cla.Endpoints {
+		for j, lbEndpoint := range locality.LbEndpoints {
+			refs = append(refs, lbEndpointRef{
+				locality:  i,
+				endpoint:  j,
+				priority:  locality.Priority,
+				unhealthy: lbEndpoint.HealthStatus == core.HealthStatus_UNHEALTHY,
+			})
+		}
+	}
+	if len(refs) <= quota {
+		return cla, 0
+	}
+	sort.SliceStable(refs, func(i, j int) bool {
+		if refs[i].unhealthy != refs[j].unhealthy {
+			return !refs[i].unhealthy
+		}
+		return refs[i].priority < refs[j].priority
+	})
+	kept := make([][]bool, len(cla.Endpoints))
+	for i, locality := range cla.Endpoints {
+		kept[i] = make([]bool, len(locality.LbEndpoints))
+	}
+	for _, ref := range refs[:quota] {
+		kept[ref.locality][ref.endpoint] = true
+	}
+
+	limited := util.CloneClusterLoadAssignment(cla)
+	localities := make([]*endpoint.LocalityLbEndpoints, 0, len(limited.Endpoints))
+	for i, locality := range limited.Endpoints {
+		lbEndpoints := make([]*endpoint.LbEndpoint, 0, len(locality.LbEndpoints))
+		for j, lbEndpoint := range locality.LbEndpoints {
+			if kept[i][j] {
+				lbEndpoints = append(lbEndpoints, lbEndpoint)
+			}
+		}
+		if len(lbEndpoints) > 0 {
+			locality.LbEndpoints = lbEndpoints
+			localities = append(localities, locality)
+		}
+	}
+	limited.Endpoints = localities
+	return &limited, len(refs) - quota
+}
+
+// truncatedTotals are the resources currently dropped from the pushes to the connected proxies, by type,
+// recorded in the pilot_xds_truncated_resources metric. The degraded proxies are listed in /debug/adsz.
+var truncatedTotals = struct {
+	sync.Mutex
+	byType map[string]int
+}{byType: make(map[string]int)}
+
+// recordTruncation records the resources of the given type dropped from a push to the connection,
+// replacing the previous ones dropped from the connection.
+func recordTruncation(con *XdsConnection, typ string, previous, dropped int) {
+	if dropped > 0 {
+		adsLog.Warnf("%s: dropped %d resources of node:%s above its limit", strings.ToUpper(typ), dropped, con.node.ID)
+	}
+	if dropped != previous {
+		adjustTruncation(typ, dropped-previous)
+	}
+}
+
+func adjustTruncation(typ string, delta int) {
+	truncatedTotals.Lock()
+	defer truncatedTotals.Unlock()
+	truncatedTotals.byType[typ] += delta
+	truncatedResources.With(typeTag.Value(typ)).Record(float64(truncatedTotals.byType[typ]))
+}
+
+// clearTruncation removes the resources dropped from the pushes to a closed connection from the metric.
+func clearTruncation(con *XdsConnection) {
+	con.mu.Lock()
+	defer con.mu.Unlock()
+	dropped := map[string]int{
+		"cds": con.truncation.clusters,
+		"rds": con.truncation.routes,
+		"eds": con.truncation.endpointCount(),
+	}
+	for typ, n := range dropped {
+		if n > 0 {
+			adjustTruncation(typ, -n)
+		}
+	}
+	con.truncation = resourceTruncation{}
+}
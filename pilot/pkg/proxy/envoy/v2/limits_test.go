@@ -0,0 +1,255 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/api/v2/endpoint"
+	route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/host"
+)
+
+func limitsPushContext() (*model.Proxy, *model.PushContext) {
+	node := &model.Proxy{ID: "app.ns1", ConfigNamespace: "ns1", Metadata: &model.NodeMetadata{}}
+	push := model.NewPushContext()
+	push.ServiceByHostnameAndNamespace = map[host.Name]map[string]*model.Service{
+		"a.ns1.svc.cluster.local": {"ns1": &model.Service{}},
+		"b.ns2.svc.cluster.local": {"ns2": &model.Service{}},
+	}
+	return node, push
+}
+
+// truncatedTotal returns the resources of the type currently dropped from the connected proxies.
+func truncatedTotal(typ string) int {
+	truncatedTotals.Lock()
+	defer truncatedTotals.Unlock()
+	return truncatedTotals.byType[typ]
+}
+
+func TestRecordTruncation(t *testing.T) {
+	node, _ := limitsPushContext()
+	con := newXdsConnection("10.0.0.1", &fakeStream{})
+	con.node = node
+	cds, eds := truncatedTotal("cds"), truncatedTotal("eds")
+
+	recordTruncation(con, "cds", 0, 5)
+	con.truncation.clusters = 5
+	recordTruncation(con, "eds", 0, 3)
+	con.truncation.endpoints = map[string]int{"outbound|80||a.ns1.svc.cluster.local": 3}
+	if got := truncatedTotal("cds") - cds; got != 5 {
+		t.Errorf("got %d truncated clusters, want 5", got)
+	}
+
+	// the clusters dropped from the next push replace the previous ones.
+	recordTruncation(con, "cds", 5, 2)
+	con.truncation.clusters = 2
+	if got := truncatedTotal("cds") - cds; got != 2 {
+		t.Errorf("got %d truncated clusters, want 2", got)
+	}
+
+	// the resources dropped from a closed connection are removed.
+	clearTruncation(con)
+	if got := truncatedTotal("cds") - cds; got != 0 {
+		t.Errorf("got %d truncated clusters after disconnect, want 0", got)
+	}
+	if got := truncatedTotal("eds") - eds; got != 0 {
+		t.Errorf("got %d truncated endpoints after disconnect, want 0", got)
+	}
+	if con.truncation.degraded() {
+		t.Error("the connection should not be degraded after disconnect")
+	}
+}
+
+func TestProxyResourceLimits(t *testing.T) {
+	node, _ := limitsPushContext()
+	node.Metadata.MaxClusters = "10"
+	node.Metadata.MaxEndpoints = "invalid"
+	if got, want := proxyResourceLimits(node), (resourceLimits{clusters: 10}); got != want {
+		t.Errorf("got limits %+v, want %+v", got, want)
+	}
+}
+
+func TestLimitClusters(t *testing.T) {
+	node, push := limitsPushContext()
+	var clusters []*xdsapi.Cluster
+	for _, name := range []string{
+		"outbound|80||b.ns2.svc.cluster.local",
+		"outbound|80||a.ns1.svc.cluster.local",
+		"BlackHoleCluster",
+		"inbound|8080||app.ns1.svc.cluster.local",
+	} {
+		clusters = append(clusters, &xdsapi.Cluster{Name: name})
+	}
+	got, dropped := limitClusters(node, push, clusters, 3)
+	var names []string
+	for _, c := range got {
+		names = append(names, c.Name)
+	}
+	want := []string{"BlackHoleCluster", "inbound|8080||app.ns1.svc.cluster.local", "outbound|80||a.ns1.svc.cluster.local"}
+	if dropped != 1 || !reflect.DeepEqual(names, want) {
+		t.Errorf("got clusters %v, dropped %d, want %v, dropped 1", names, dropped, want)
+	}
+	if got, dropped := limitClusters(node, push, clusters, 0); len(got) != len(clusters) || dropped != 0 {
+		t.Errorf("no limit should keep all the clusters, got %d, dropped %d", len(got), dropped)
+	}
+}
+
+func TestLimitRoutes(t *testing.T) {
+	node, push := limitsPushContext()
+	routes := []*xdsapi.RouteConfiguration{
+		{
+			Name: "80",
+			VirtualHosts: []*route.VirtualHost{
+				{Name: "b.ns2.svc.cluster.local:80", Domains: []string{"b.ns2.svc.cluster.local"}},
+				{Name: "a.ns1.svc.cluster.local:80", Domains: []string{"a.ns1.svc.cluster.local"}},
+				{Name: "allow_any", Domains: []string{"*"}},
+			},
+		},
+		{
+			Name: "8080",
+			VirtualHosts: []*route.VirtualHost{
+				{Name: "b.ns2.svc.cluster.local:8080", Domains: []string{"b.ns2.svc.cluster.local"}},
+			},
+		},
+	}
+	got, dropped := limitRoutes(node, push, routes, 2)
+	if dropped != 2 {
+		t.Errorf("got %d dropped virtual hosts, want 2", dropped)
+	}
+	if len(got) != 2 || len(got[0].VirtualHosts) != 2 || len(got[1].VirtualHosts) != 0 {
+		t.Fatalf("unexpected routes %v", got)
+	}
+	if got[0].VirtualHosts[0].Name != "a.ns1.svc.cluster.local:80" || got[0].VirtualHosts[1].Name != "allow_any" {
+		t.Errorf("got virtual hosts %v", got[0].VirtualHosts)
+	}
+	if len(routes[0].VirtualHosts) != 3 {
+		t.Error("the pushed routes should not be modified")
+	}
+}
+
+func TestLimitEndpoints(t *testing.T) {
+	lbEndpoint := func(status core.HealthStatus) *endpoint.LbEndpoint {
+		return &endpoint.LbEndpoint{HealthStatus: status}
+	}
+	cla := &xdsapi.ClusterLoadAssignment{
+		ClusterName: "outbound|80||a.ns1.svc.cluster.local",
+		Endpoints: []*endpoint.LocalityLbEndpoints{
+			{
+				Priority:    1,
+				LbEndpoints: []*endpoint.LbEndpoint{lbEndpoint(core.HealthStatus_HEALTHY), lbEndpoint(core.HealthStatus_HEALTHY)},
+			},
+			{
+				Priority:    0,
+				LbEndpoints: []*endpoint.LbEndpoint{lbEndpoint(core.HealthStatus_UNHEALTHY), lbEndpoint(core.HealthStatus_HEALTHY)},
+			},
+		},
+	}
+	got, dropped := limitEndpoints(cla, 2)
+	if dropped != 2 {
+		t.Errorf("got %d dropped endpoints, want 2", dropped)
+	}
+	// The healthy endpoint of the closest locality, then a healthy endpoint of the other locality.
+	if len(got.Endpoints) != 2 || len(got.Endpoints[0].LbEndpoints) != 1 || len(got.Endpoints[1].LbEndpoints) != 1 ||
+		got.Endpoints[1].LbEndpoints[0] != cla.Endpoints[1].LbEndpoints[1] {
+		t.Errorf("unexpected endpoints %v", got.Endpoints)
+	}
+	if len(cla.Endpoints[0].LbEndpoints) != 2 || len(cla.Endpoints[1].LbEndpoints) != 2 {
+		t.Error("the pushed load assignment should not be modified")
+	}
+
+	got, _ = limitEndpoints(cla, 1)
+	if len(got.Endpoints) != 1 || got.Endpoints[0].Priority != 0 {
+		t.Errorf("the empty localities should be dropped, got %v", got.Endpoints)
+	}
+	if got, dropped := limitEndpoints(cla, 0); got != cla || dropped != 0 {
+		t.Errorf("no quota should keep all the endpoints")
+	}
+}
+
+func TestWithEndpointTTL(t *testing.T) {
+	cla := &xdsapi.ClusterLoadAssignment{
+		ClusterName: "outbound|80||a.ns1.svc.cluster.local",
+		Endpoints:   []*endpoint.LocalityLbEndpoints{{Priority: 1}},
+		Policy: &xdsapi.ClusterLoadAssignment_Policy{
+			OverprovisioningFactor: &wrappers.UInt32Value{Value: 200},
+		},
+	}
+	if got := withEndpointTTL(cla, 0); got != cla {
+		t.Error("no TTL should keep the load assignment")
+	}
+
+	got := withEndpointTTL(cla, 30*time.Second)
+	if got.ClusterName != cla.ClusterName || !reflect.DeepEqual(got.Endpoints, cla.Endpoints) {
+		t.Errorf("unexpected load assignment %v", got)
+	}
+	if got.Policy.GetOverprovisioningFactor().GetValue() != 200 {
+		t.Errorf("the policy of the load assignment should be kept, got %v", got.Policy)
+	}
+	if ttl, err := ptypes.Duration(got.Policy.GetEndpointStaleAfter()); err != nil || ttl != 30*time.Second {
+		t.Errorf("got endpoints stale after %v, want 30s", got.Policy.GetEndpointStaleAfter())
+	}
+	if cla.Policy.EndpointStaleAfter != nil {
+		t.Error("the pushed load assignment should not be modified")
+	}
+
+	if got := withEndpointTTL(&xdsapi.ClusterLoadAssignment{}, time.Minute); got.Policy.GetEndpointStaleAfter() == nil {
+		t.Error("a policy should be added to the load assignment without policy")
+	}
+}
+
+func TestEndpointQuota(t *testing.T) {
+	con := &XdsConnection{Clusters: []string{"a", "b", "c"}}
+	for limit, want := range map[int]int{0: 0, 2: 1, 10: 3} {
+		if got := endpointQuota(con, limit); got != want {
+			t.Errorf("endpointQuota(%d) = %d, want %d", limit, got, want)
+		}
+	}
+}
+
+func TestExpiringServices(t *testing.T) {
+	now := time.Unix(1000, 0)
+	con := &XdsConnection{
+		Clusters: []string{
+			"outbound|80||a.ns1.svc.cluster.local",
+			"outbound|80|v1|a.ns1.svc.cluster.local",
+			"outbound|80||b.ns1.svc.cluster.local",
+			"outbound|80||c.ns1.svc.cluster.local",
+		},
+		edsSentAt: map[string]time.Time{
+			"outbound|80||a.ns1.svc.cluster.local":   now.Add(-20 * time.Second),
+			"outbound|80|v1|a.ns1.svc.cluster.local": now.Add(-5 * time.Second),
+			"outbound|80||b.ns1.svc.cluster.local":   now.Add(-5 * time.Second),
+		},
+	}
+	want := map[string]struct{}{
+		"a.ns1.svc.cluster.local": {},
+		"c.ns1.svc.cluster.local": {},
+	}
+	if got := con.expiringServices(now, 30*time.Second); !reflect.DeepEqual(got, want) {
+		t.Errorf("got expiring services %v, want %v", got, want)
+	}
+	if got := con.expiringServices(now, time.Minute); !reflect.DeepEqual(got, map[string]struct{}{"c.ns1.svc.cluster.local": {}}) {
+		t.Errorf("got expiring services %v, want only the load assignment never sent", got)
+	}
+}
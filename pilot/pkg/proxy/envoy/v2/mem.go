@@ -38,17 +38,17 @@ type MemServiceController struct {
 }
 
 // AppendServiceHandler appends a service handler to the controller
-func (c *MemServiceController) AppendServiceHandler(f func(*model.Service, model.Event)) error {
+func (c *MemServiceController) AppendServiceHandler(f func(*model.Service, model.Event), filters ...model.HandlerFilter) error {
 	c.Lock()
-	c.svcHandlers = append(c.svcHandlers, f)
+	c.svcHandlers = append(c.svcHandlers, model.FilterServiceHandler(f, filters...))
 	c.Unlock()
 	return nil
 }
 
 // AppendInstanceHandler appends a service instance handler to the controller
-func (c *MemServiceController) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event)) error {
+func (c *MemServiceController) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event), filters ...model.HandlerFilter) error {
 	c.Lock()
-	c.instHandlers = append(c.instHandlers, f)
+	c.instHandlers = append(c.instHandlers, model.FilterInstanceHandler(f, filters...))
 	c.Unlock()
 	return nil
 }
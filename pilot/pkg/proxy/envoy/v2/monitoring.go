@@ -14,8 +14,12 @@
 package v2
 
 import (
+	"sync"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
 	"google.golang.org/grpc/codes"
 
+	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/mcp/status"
 	"istio.io/pkg/monitoring"
 )
@@ -26,6 +30,9 @@ var (
 	nodeTag    = monitoring.MustCreateLabel("node")
 	typeTag    = monitoring.MustCreateLabel("type")
 
+	// clusterIDTag is the ID of the cluster of a service registry, while clusterTag is an Envoy cluster.
+	clusterIDTag = monitoring.MustCreateLabel("cluster_id")
+
 	cdsReject = monitoring.NewGauge(
 		"pilot_xds_cds_reject",
 		"Pilot rejected CSD configs.",
@@ -67,6 +74,12 @@ var (
 		"Total number of RDS messages with an expired nonce.",
 	)
 
+	expiredNonce = monitoring.NewSum(
+		"pilot_xds_expired_nonce",
+		"Total number of XDS requests with an expired nonce, acknowledging or rejecting a superseded response.",
+		monitoring.WithLabels(typeTag),
+	)
+
 	totalXDSRejects = monitoring.NewSum(
 		"pilot_total_xds_rejects",
 		"Total number of XDS responses from pilot rejected by proxy.",
@@ -74,7 +87,8 @@ var (
 
 	monServices = monitoring.NewGauge(
 		"pilot_services",
-		"Total services known to pilot.",
+		"Total services known to pilot, by cluster ID. A service in several clusters is counted in each.",
+		monitoring.WithLabels(clusterIDTag),
 	)
 
 	// TODO: Update all the resource stats in separate routine
@@ -84,6 +98,22 @@ var (
 		"Number of endpoints connected to this pilot using XDS.",
 	)
 
+	rejectedConnections = monitoring.NewSum(
+		"pilot_xds_rejected_connections",
+		"Total number of XDS connections rejected because the maximum number of connected proxies was reached.",
+	)
+
+	truncatedResources = monitoring.NewGauge(
+		"pilot_xds_truncated_resources",
+		"Resources currently dropped from the pushes to the degraded proxies above their limits, by type (cds, rds or eds).",
+		monitoring.WithLabels(typeTag),
+	)
+
+	endpointRefreshes = monitoring.NewSum(
+		"pilot_xds_endpoint_refreshes",
+		"Total number of pushes of the load assignments close to the end of their TTL (endpointTtl of the mesh config).",
+	)
+
 	xdsResponseWriteTimeouts = monitoring.NewSum(
 		"pilot_xds_write_timeout",
 		"Pilot XDS response write timeouts.",
@@ -120,6 +150,44 @@ var (
 	ldsPushTime = pushTime.With(typeTag.Value("lds"))
 	rdsPushTime = pushTime.With(typeTag.Value("rds"))
 
+	resourceSize = monitoring.NewDistribution(
+		"pilot_xds_resource_size_bytes",
+		"Serialized size in bytes of the xDS resources pushed, by type.",
+		[]float64{100, 1000, 10000, 100000, 1000000, 4000000, 10000000},
+		monitoring.WithLabels(typeTag),
+	)
+
+	cdsResourceSize = resourceSize.With(typeTag.Value("cds"))
+	edsResourceSize = resourceSize.With(typeTag.Value("eds"))
+	ldsResourceSize = resourceSize.With(typeTag.Value("lds"))
+	rdsResourceSize = resourceSize.With(typeTag.Value("rds"))
+
+	// The metrics of the registered generators, labeled by their type URL.
+	generatorPushes = monitoring.NewSum(
+		"pilot_xds_generator_pushes",
+		"Pilot pushes, build and send errors of the resources of registered generators.",
+		monitoring.WithLabels(typeTag),
+	)
+
+	generatorPushTime = monitoring.NewDistribution(
+		"pilot_xds_generator_push_time",
+		"Total time in seconds Pilot takes to push the resources of registered generators.",
+		[]float64{.01, .1, 1, 3, 5, 10, 20, 30},
+		monitoring.WithLabels(typeTag),
+	)
+
+	generatorRejects = monitoring.NewGauge(
+		"pilot_xds_generator_reject",
+		"Pilot rejected resources of registered generators.",
+		monitoring.WithLabels(typeTag, nodeTag, errTag),
+	)
+
+	filteredResources = monitoring.NewSum(
+		"pilot_xds_filtered_resources",
+		"Resources not sent to the proxies because a registered ResourceFilter denied them, by type URL.",
+		monitoring.WithLabels(typeTag),
+	)
+
 	// only supported dimension is millis, unfortunately. default to unitdimensionless.
 	proxiesQueueTime = monitoring.NewDistribution(
 		"pilot_proxy_queue_time",
@@ -147,7 +215,7 @@ var (
 	inboundUpdates = monitoring.NewSum(
 		"pilot_inbound_updates",
 		"Total number of updates received by pilot.",
-		monitoring.WithLabels(typeTag),
+		monitoring.WithLabels(typeTag, clusterIDTag),
 	)
 
 	inboundConfigUpdates  = inboundUpdates.With(typeTag.Value("config"))
@@ -156,6 +224,42 @@ var (
 	inboundServiceDeletes = inboundUpdates.With(typeTag.Value("svcdelete"))
 )
 
+// serviceClusterIDs are the cluster IDs of the last recorded services, to reset the gauge of the
+// clusters without services.
+var (
+	serviceClusterIDs      = map[string]struct{}{}
+	serviceClusterIDsMutex sync.Mutex
+)
+
+// recordServices records the number of services by cluster ID, the services without cluster VIP
+// (service entries, ...) having an empty cluster ID.
+func recordServices(services []*model.Service) {
+	counts := map[string]int{}
+	for _, svc := range services {
+		svc.Mutex.RLock()
+		for clusterID := range svc.ClusterVIPs {
+			counts[clusterID]++
+		}
+		if len(svc.ClusterVIPs) == 0 {
+			counts[""]++
+		}
+		svc.Mutex.RUnlock()
+	}
+
+	serviceClusterIDsMutex.Lock()
+	defer serviceClusterIDsMutex.Unlock()
+	for clusterID := range serviceClusterIDs {
+		if _, f := counts[clusterID]; !f {
+			monServices.With(clusterIDTag.Value(clusterID)).Record(0)
+		}
+	}
+	serviceClusterIDs = map[string]struct{}{}
+	for clusterID, count := range counts {
+		monServices.With(clusterIDTag.Value(clusterID)).Record(float64(count))
+		serviceClusterIDs[clusterID] = struct{}{}
+	}
+}
+
 func recordSendError(metric monitoring.Metric, err error) {
 	s, ok := status.FromError(err)
 	// Unavailable or canceled code will be sent when a connection is closing down. This is very normal,
@@ -171,6 +275,27 @@ func incrementXDSRejects(metric monitoring.Metric, node, errCode string) {
 	totalXDSRejects.Increment()
 }
 
+// recordResourceSizes records the sizes of the resources of a response. The resources of the
+// registered generators are labeled by their type URL.
+func recordResourceSizes(res *xdsapi.DiscoveryResponse) {
+	var metric monitoring.Metric
+	switch res.TypeUrl {
+	case ClusterType:
+		metric = cdsResourceSize
+	case EndpointType:
+		metric = edsResourceSize
+	case ListenerType:
+		metric = ldsResourceSize
+	case RouteType:
+		metric = rdsResourceSize
+	default:
+		metric = resourceSize.With(typeTag.Value(res.TypeUrl))
+	}
+	for _, resource := range res.Resources {
+		metric.Record(float64(len(resource.Value)))
+	}
+}
+
 func init() {
 	monitoring.MustRegister(
 		cdsReject,
@@ -180,12 +305,21 @@ func init() {
 		edsInstances,
 		edsAllLocalityEndpoints,
 		rdsExpiredNonce,
+		expiredNonce,
 		totalXDSRejects,
 		monServices,
 		xdsClients,
+		rejectedConnections,
+		truncatedResources,
+		endpointRefreshes,
 		xdsResponseWriteTimeouts,
 		pushes,
 		pushTime,
+		resourceSize,
+		generatorPushes,
+		generatorPushTime,
+		generatorRejects,
+		filteredResources,
 		proxiesConvergeDelay,
 		proxiesQueueTime,
 		pushContextErrors,
@@ -0,0 +1,174 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"testing"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/golang/protobuf/ptypes/any"
+	"go.opencensus.io/stats/view"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// resourceSizeData returns the distribution of the resource sizes of the type.
+func resourceSizeData(t *testing.T, typ string) *view.DistributionData {
+	t.Helper()
+	rows, err := view.RetrieveData("pilot_xds_resource_size_bytes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		for _, tag := range row.Tags {
+			if tag.Key.Name() == "type" && tag.Value == typ {
+				return row.Data.(*view.DistributionData)
+			}
+		}
+	}
+	return &view.DistributionData{}
+}
+
+func TestRecordResourceSizes(t *testing.T) {
+	rds := resourceSizeData(t, "rds")
+	custom := resourceSizeData(t, "custom")
+
+	recordResourceSizes(&xdsapi.DiscoveryResponse{
+		TypeUrl:   RouteType,
+		Resources: []*any.Any{{Value: make([]byte, 50)}, {Value: make([]byte, 5000)}},
+	})
+	recordResourceSizes(&xdsapi.DiscoveryResponse{
+		TypeUrl:   "custom",
+		Resources: []*any.Any{{Value: make([]byte, 10)}},
+	})
+
+	if data := resourceSizeData(t, "rds"); data.Count != rds.Count+2 || data.Max < 5000 {
+		t.Errorf("got %d RDS resources of max size %v, want %d of max size 5000", data.Count, data.Max, rds.Count+2)
+	}
+	if data := resourceSizeData(t, "custom"); data.Count != custom.Count+1 {
+		t.Errorf("got %d resources of the custom type, want %d", data.Count, custom.Count+1)
+	}
+}
+
+// expiredNonceCount returns the number of expired nonces of the type.
+func expiredNonceCount(t *testing.T, typ string) float64 {
+	t.Helper()
+	rows, err := view.RetrieveData("pilot_xds_expired_nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		for _, tag := range row.Tags {
+			if tag.Key.Name() == "type" && tag.Value == typ {
+				return row.Data.(*view.SumData).Value
+			}
+		}
+	}
+	return 0
+}
+
+func TestIsExpiredNonce(t *testing.T) {
+	con := newXdsConnection("10.0.0.1", &fakeStream{})
+	con.RouteNonceSent = "rds-2"
+	con.Watched = map[string]*WatchedResource{testGeneratedType: {NonceSent: "gen-1"}}
+	rds := expiredNonceCount(t, "rds")
+	generated := expiredNonceCount(t, testGeneratedType)
+
+	cases := []struct {
+		name    string
+		req     *xdsapi.DiscoveryRequest
+		expired bool
+	}{
+		{"first request", &xdsapi.DiscoveryRequest{TypeUrl: RouteType}, false},
+		{"latest nonce", &xdsapi.DiscoveryRequest{TypeUrl: RouteType, ResponseNonce: "rds-2"}, false},
+		{"superseded nonce", &xdsapi.DiscoveryRequest{TypeUrl: RouteType, ResponseNonce: "rds-1"}, true},
+		{"nothing sent for the type", &xdsapi.DiscoveryRequest{TypeUrl: ClusterType, ResponseNonce: "cds-1"}, false},
+		{"superseded generated nonce", &xdsapi.DiscoveryRequest{TypeUrl: testGeneratedType, ResponseNonce: "gen-0"}, true},
+	}
+	for _, c := range cases {
+		if got := isExpiredNonce(con, c.req); got != c.expired {
+			t.Errorf("%s: isExpiredNonce() => %v, want %v", c.name, got, c.expired)
+		}
+	}
+
+	if got := expiredNonceCount(t, "rds"); got != rds+1 {
+		t.Errorf("got %v expired RDS nonces, want %v", got, rds+1)
+	}
+	if got := expiredNonceCount(t, testGeneratedType); got != generated+1 {
+		t.Errorf("got %v expired nonces of the generated type, want %v", got, generated+1)
+	}
+}
+
+func TestSendRecordsNonce(t *testing.T) {
+	stream := &recordingStream{}
+	con := newXdsConnection("10.0.0.1", stream)
+	if err := con.send(&xdsapi.DiscoveryResponse{TypeUrl: EndpointType, Nonce: "eds-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if isExpiredNonce(con, &xdsapi.DiscoveryRequest{TypeUrl: EndpointType, ResponseNonce: "eds-1"}) {
+		t.Error("the ACK of the sent response should not be expired")
+	}
+	if !isExpiredNonce(con, &xdsapi.DiscoveryRequest{TypeUrl: EndpointType, ResponseNonce: "eds-0"}) {
+		t.Error("the ACK of a previous response should be expired")
+	}
+}
+
+// servicesByClusterID returns the number of services recorded by cluster ID.
+func servicesByClusterID(t *testing.T) map[string]float64 {
+	t.Helper()
+	rows, err := view.RetrieveData("pilot_services")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := map[string]float64{}
+	for _, row := range rows {
+		// The services without cluster ID are recorded without the tag.
+		clusterID := ""
+		for _, tag := range row.Tags {
+			if tag.Key.Name() == "cluster_id" {
+				clusterID = tag.Value
+			}
+		}
+		out[clusterID] = row.Data.(*view.LastValueData).Value
+	}
+	return out
+}
+
+func TestRecordServices(t *testing.T) {
+	recordServices([]*model.Service{
+		{Hostname: "a.default.svc.cluster.local", ClusterVIPs: map[string]string{"c1": "10.0.0.1", "c2": "10.1.0.1"}},
+		{Hostname: "b.default.svc.cluster.local", ClusterVIPs: map[string]string{"c1": "10.0.0.2"}},
+		{Hostname: "external.example.com"},
+	})
+	expectServices(t, map[string]float64{"c1": 2, "c2": 1, "": 1})
+
+	// The clusters left without services are reset.
+	recordServices([]*model.Service{
+		{Hostname: "a.default.svc.cluster.local", ClusterVIPs: map[string]string{"c2": "10.1.0.1"}},
+	})
+	expectServices(t, map[string]float64{"c1": 0, "c2": 1, "": 0})
+}
+
+// expectServices checks the number of services recorded for the cluster IDs, the services of the
+// other tests being recorded as well.
+func expectServices(t *testing.T, want map[string]float64) {
+	t.Helper()
+	got := servicesByClusterID(t)
+	for clusterID, count := range want {
+		if got[clusterID] != count {
+			t.Errorf("got %v services in cluster %q, want %v", got[clusterID], clusterID, count)
+		}
+	}
+}
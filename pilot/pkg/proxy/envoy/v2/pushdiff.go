@@ -0,0 +1,187 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+// pushDiffHistorySize is the number of diffs kept per connection.
+const pushDiffHistorySize = 50
+
+// PushDiff is the name-level difference between the resources of a type sent to a proxy by a
+// push, and the resources sent before.
+type PushDiff struct {
+	Time    time.Time `json:"time"`
+	TypeURL string    `json:"type"`
+	Version string    `json:"version"`
+	Nonce   string    `json:"nonce"`
+	Added   []string  `json:"added,omitempty"`
+	Removed []string  `json:"removed,omitempty"`
+	Changed []string  `json:"changed,omitempty"`
+}
+
+// pushDiffRecorder records the diffs of the responses sent on a connection, when
+// PILOT_DEBUG_PUSH_DIFF is enabled. It is protected by the mutex of the connection.
+type pushDiffRecorder struct {
+	// sent are the digests of the sent resources, by type URL and name.
+	sent map[string]map[string][sha256.Size]byte
+	// diffs are the last recorded diffs, oldest first.
+	diffs []PushDiff
+}
+
+func newPushDiffRecorder() *pushDiffRecorder {
+	return &pushDiffRecorder{sent: map[string]map[string][sha256.Size]byte{}}
+}
+
+// record records the diff between the response and the previously sent resources of its type.
+// EDS responses of incremental pushes only include the updated clusters, so the clusters missing
+// from EDS responses are not reported as removed.
+func (r *pushDiffRecorder) record(res *xdsapi.DiscoveryResponse) {
+	current := make(map[string][sha256.Size]byte, len(res.Resources))
+	for _, resource := range res.Resources {
+		name, digest := resourceDigest(res.TypeUrl, resource)
+		current[name] = digest
+	}
+
+	previous := r.sent[res.TypeUrl]
+	diff := PushDiff{
+		Time:    time.Now(),
+		TypeURL: res.TypeUrl,
+		Version: res.VersionInfo,
+		Nonce:   res.Nonce,
+	}
+	for name, digest := range current {
+		if prev, f := previous[name]; !f {
+			diff.Added = append(diff.Added, name)
+		} else if prev != digest {
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	switch {
+	case res.TypeUrl != EndpointType:
+		for name := range previous {
+			if _, f := current[name]; !f {
+				diff.Removed = append(diff.Removed, name)
+			}
+		}
+		r.sent[res.TypeUrl] = current
+	case previous != nil:
+		for name, digest := range current {
+			previous[name] = digest
+		}
+	default:
+		r.sent[res.TypeUrl] = current
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	r.diffs = append(r.diffs, diff)
+	if len(r.diffs) > pushDiffHistorySize {
+		r.diffs = r.diffs[len(r.diffs)-pushDiffHistorySize:]
+	}
+}
+
+// resourceDigest returns the name of the resource and the digest of its content. The resources
+// of the built-in types are decoded and deterministically encoded, since the encoding of maps is
+// not stable. The resources of the other types are named by their digest.
+func resourceDigest(typeURL string, resource *any.Any) (string, [sha256.Size]byte) {
+	var msg proto.Message
+	switch typeURL {
+	case ClusterType:
+		msg = &xdsapi.Cluster{}
+	case ListenerType:
+		msg = &xdsapi.Listener{}
+	case RouteType:
+		msg = &xdsapi.RouteConfiguration{}
+	case EndpointType:
+		msg = &xdsapi.ClusterLoadAssignment{}
+	}
+	if msg == nil || ptypes.UnmarshalAny(resource, msg) != nil {
+		digest := sha256.Sum256(resource.Value)
+		return fmt.Sprintf("%x", digest[:8]), digest
+	}
+
+	var name string
+	switch m := msg.(type) {
+	case *xdsapi.Cluster:
+		name = m.Name
+	case *xdsapi.Listener:
+		name = m.Name
+	case *xdsapi.RouteConfiguration:
+		name = m.Name
+	case *xdsapi.ClusterLoadAssignment:
+		name = m.ClusterName
+	}
+	buf := proto.NewBuffer(nil)
+	buf.SetDeterministic(true)
+	if err := buf.Marshal(msg); err != nil {
+		return name, sha256.Sum256(resource.Value)
+	}
+	return name, sha256.Sum256(buf.Bytes())
+}
+
+// PushDiffHandler returns the diffs recorded for the resources sent to the proxy, oldest first.
+func (s *DiscoveryServer) PushDiffHandler(w http.ResponseWriter, req *http.Request) {
+	proxyID := req.URL.Query().Get("proxyID")
+	if proxyID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("You must provide a proxyID in the query string"))
+		return
+	}
+
+	adsClientsMutex.RLock()
+	connections := adsSidecarIDConnectionsMap[proxyID]
+	mostRecent := ""
+	for key := range connections {
+		if mostRecent == "" || key > mostRecent {
+			mostRecent = key
+		}
+	}
+	con := connections[mostRecent]
+	adsClientsMutex.RUnlock()
+	if con == nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("Proxy not connected to this Pilot instance"))
+		return
+	}
+
+	con.mu.RLock()
+	diffs := []PushDiff{}
+	if con.pushDiffs != nil {
+		diffs = append(diffs, con.pushDiffs.diffs...)
+	}
+	con.mu.RUnlock()
+
+	out, err := json.MarshalIndent(diffs, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(out)
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/golang/protobuf/ptypes/any"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+func clustersResponse(clusters ...*xdsapi.Cluster) *xdsapi.DiscoveryResponse {
+	res := &xdsapi.DiscoveryResponse{TypeUrl: ClusterType}
+	for _, c := range clusters {
+		res.Resources = append(res.Resources, util.MessageToAny(c))
+	}
+	return res
+}
+
+func loadAssignmentsResponse(names ...string) *xdsapi.DiscoveryResponse {
+	res := &xdsapi.DiscoveryResponse{TypeUrl: EndpointType}
+	for _, name := range names {
+		res.Resources = append(res.Resources, util.MessageToAny(&xdsapi.ClusterLoadAssignment{ClusterName: name}))
+	}
+	return res
+}
+
+func TestPushDiffRecorder(t *testing.T) {
+	r := newPushDiffRecorder()
+	a := &xdsapi.Cluster{Name: "a"}
+	b := &xdsapi.Cluster{Name: "b"}
+	bChanged := &xdsapi.Cluster{Name: "b", AltStatName: "changed"}
+	c := &xdsapi.Cluster{Name: "c"}
+
+	r.record(clustersResponse(a, b))
+	r.record(clustersResponse(a, bChanged, c))
+	r.record(clustersResponse(bChanged, c))
+	// EDS pushes are incremental: the missing clusters are not removed.
+	r.record(loadAssignmentsResponse("a", "b"))
+	r.record(loadAssignmentsResponse("b", "c"))
+	r.record(&xdsapi.DiscoveryResponse{TypeUrl: "custom", Resources: []*any.Any{{TypeUrl: "custom", Value: []byte("x")}}})
+
+	customName, _ := resourceDigest("custom", &any.Any{Value: []byte("x")})
+	want := []PushDiff{
+		{TypeURL: ClusterType, Added: []string{"a", "b"}},
+		{TypeURL: ClusterType, Added: []string{"c"}, Changed: []string{"b"}},
+		{TypeURL: ClusterType, Removed: []string{"a"}},
+		{TypeURL: EndpointType, Added: []string{"a", "b"}},
+		{TypeURL: EndpointType, Added: []string{"c"}},
+		{TypeURL: "custom", Added: []string{customName}},
+	}
+	if len(r.diffs) != len(want) {
+		t.Fatalf("got %d diffs, want %d", len(r.diffs), len(want))
+	}
+	for i, d := range r.diffs {
+		d.Time = want[i].Time
+		if !reflect.DeepEqual(d, want[i]) {
+			t.Errorf("diff %d => %+v, want %+v", i, d, want[i])
+		}
+	}
+
+	for i := 0; i < pushDiffHistorySize; i++ {
+		r.record(clustersResponse(a))
+	}
+	if len(r.diffs) != pushDiffHistorySize {
+		t.Errorf("got %d diffs, want history limited to %d", len(r.diffs), pushDiffHistorySize)
+	}
+}
+
+func TestPushDiffHandler(t *testing.T) {
+	defer func(enabled bool) { features.DebugPushDiff = enabled }(features.DebugPushDiff)
+	features.DebugPushDiff = true
+
+	s := &DiscoveryServer{}
+	con := newXdsConnection("10.0.0.1", &recordingStream{})
+	con.node = &model.Proxy{ID: "diff-proxy"}
+	con.ConID = "diff-proxy-1"
+	adsClientsMutex.Lock()
+	adsSidecarIDConnectionsMap[con.node.ID] = map[string]*XdsConnection{con.ConID: con}
+	adsClientsMutex.Unlock()
+	defer func() {
+		adsClientsMutex.Lock()
+		delete(adsSidecarIDConnectionsMap, con.node.ID)
+		adsClientsMutex.Unlock()
+	}()
+
+	if err := con.send(clustersResponse(&xdsapi.Cluster{Name: "a"})); err != nil {
+		t.Fatal(err)
+	}
+	// The diff is recorded after the response is sent.
+	var diffs []PushDiff
+	for i := 0; i < 100 && len(diffs) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		rec := httptest.NewRecorder()
+		s.PushDiffHandler(rec, httptest.NewRequest("GET", "/debug/push_diff?proxyID=diff-proxy", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d, want 200", rec.Code)
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &diffs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(diffs) != 1 || !reflect.DeepEqual(diffs[0].Added, []string{"a"}) {
+		t.Fatalf("got diffs %+v, want cluster a added", diffs)
+	}
+
+	rec := httptest.NewRecorder()
+	s.PushDiffHandler(rec, httptest.NewRequest("GET", "/debug/push_diff?proxyID=unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("got status %d for unknown proxy, want 404", rec.Code)
+	}
+}
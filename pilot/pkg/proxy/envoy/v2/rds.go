@@ -28,6 +28,12 @@ import (
 func (s *DiscoveryServer) pushRoute(con *XdsConnection, push *model.PushContext, version string) error {
 	pushStart := time.Now()
 	rawRoutes := s.generateRawRoutes(con, push)
+	rawRoutes, dropped := limitRoutes(con.node, push, rawRoutes, proxyResourceLimits(con.node).routes)
+	con.mu.Lock()
+	recordTruncation(con, "rds", con.truncation.routes, dropped)
+	con.truncation.routes = dropped
+	con.mu.Unlock()
+	rawRoutes = s.filterRoutes(con, rawRoutes)
 	if s.DebugConfigs {
 		for _, r := range rawRoutes {
 			con.RouteConfigs[r.Name] = r
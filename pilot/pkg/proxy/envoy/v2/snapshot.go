@@ -0,0 +1,273 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
+	istioversion "istio.io/pkg/version"
+)
+
+// pushHistorySize is the number of pushes kept for /debug/snapshot.
+const pushHistorySize = 100
+
+// PushRecord is a push of the server, as recorded in the push history.
+type PushRecord struct {
+	Time               time.Time `json:"time"`
+	Version            string    `json:"version,omitempty"`
+	Full               bool      `json:"full"`
+	ConfigTypesUpdated []string  `json:"configTypesUpdated,omitempty"`
+	NamespacesUpdated  []string  `json:"namespacesUpdated,omitempty"`
+	EdsUpdates         []string  `json:"edsUpdates,omitempty"`
+	Error              string    `json:"error,omitempty"`
+}
+
+// pushHistory keeps the last pushes of the server, oldest first.
+type pushHistory struct {
+	mutex   sync.Mutex
+	records []PushRecord
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// record adds a push to the history, dropping the oldest push if the history is full.
+func (h *pushHistory) record(req *model.PushRequest, pushVersion string, err error) {
+	r := PushRecord{
+		Time:               time.Now(),
+		Version:            pushVersion,
+		Full:               req.Full,
+		ConfigTypesUpdated: sortedKeys(req.ConfigTypesUpdated),
+		NamespacesUpdated:  sortedKeys(req.NamespacesUpdated),
+		EdsUpdates:         sortedKeys(req.EdsUpdates),
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.records = append(h.records, r)
+	if len(h.records) > pushHistorySize {
+		h.records = h.records[len(h.records)-pushHistorySize:]
+	}
+}
+
+func (h *pushHistory) list() []PushRecord {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return append([]PushRecord{}, h.records...)
+}
+
+// SnapshotMetadata describes the server a snapshot was taken on.
+type SnapshotMetadata struct {
+	Time        time.Time `json:"time"`
+	PushVersion string    `json:"pushVersion"`
+	Version     string    `json:"version"`
+}
+
+// ConfigDigest identifies the content of a config of the config store.
+type ConfigDigest struct {
+	Type            string `json:"type"`
+	Namespace       string `json:"namespace,omitempty"`
+	Name            string `json:"name"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	SHA256          string `json:"sha256"`
+}
+
+// ProxySnapshot is a proxy connected to the server.
+type ProxySnapshot struct {
+	ConID       string              `json:"conID"`
+	PeerAddr    string              `json:"peerAddr"`
+	Connect     time.Time           `json:"connect"`
+	ID          string              `json:"id,omitempty"`
+	Type        model.NodeType      `json:"type,omitempty"`
+	ClusterID   string              `json:"clusterID,omitempty"`
+	IPAddresses []string            `json:"ipAddresses,omitempty"`
+	Namespace   string              `json:"namespace,omitempty"`
+	Metadata    *model.NodeMetadata `json:"metadata,omitempty"`
+	Clusters    []string            `json:"clusters,omitempty"`
+	Routes      []string            `json:"routes,omitempty"`
+	NoncesSent  map[string]string   `json:"noncesSent,omitempty"`
+	NoncesAcked map[string]string   `json:"noncesAcked,omitempty"`
+}
+
+// snapshotz serves an archive of the state of the server: the services of the registries, the
+// digests of the configs, the connected proxies and the last pushes, so that a problem can be
+// reproduced offline against the same state. It is mapped to /debug/snapshot.
+func (s *DiscoveryServer) snapshotz(sctl *aggregate.Controller, w http.ResponseWriter, _ *http.Request) {
+	now := time.Now()
+	files := []struct {
+		name    string
+		content func() (interface{}, error)
+	}{
+		{"metadata.json", func() (interface{}, error) {
+			return &SnapshotMetadata{Time: now, PushVersion: versionInfo(), Version: istioversion.Info.String()}, nil
+		}},
+		{"services.json", func() (interface{}, error) { return s.Env.ServiceDiscovery.Services() }},
+		{"registries.json", func() (interface{}, error) { return registryDumps(sctl), nil }},
+		{"configs.json", s.configDigests},
+		{"proxies.json", func() (interface{}, error) { return proxySnapshots(), nil }},
+		{"push_history.json", func() (interface{}, error) { return s.pushHistory.list(), nil }},
+		{"push_status.json", func() (interface{}, error) {
+			model.LastPushMutex.Lock()
+			defer model.LastPushMutex.Unlock()
+			if model.LastPushStatus == nil {
+				return nil, nil
+			}
+			b, err := model.LastPushStatus.StatusJSON()
+			return json.RawMessage(b), err
+		}},
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=pilot-snapshot-%s.tar.gz", now.UTC().Format("20060102T150405Z")))
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		content, err := f.content()
+		if err != nil {
+			adsLog.Warnf("Snapshot: failed to read %s: %v", f.name, err)
+			content = map[string]string{"error": err.Error()}
+		}
+		b, err := json.MarshalIndent(content, "", "  ")
+		if err != nil {
+			adsLog.Warnf("Snapshot: failed to marshal %s: %v", f.name, err)
+			b, _ = json.Marshal(map[string]string{"error": err.Error()})
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(b)), ModTime: now}); err != nil {
+			adsLog.Warnf("Snapshot: failed to write %s: %v", f.name, err)
+			return
+		}
+		if _, err := tw.Write(b); err != nil {
+			adsLog.Warnf("Snapshot: failed to write %s: %v", f.name, err)
+			return
+		}
+	}
+	_ = tw.Close()
+	_ = gz.Close()
+}
+
+// registryDumps returns the state of each registry supporting it, keyed by cluster ID.
+func registryDumps(sctl *aggregate.Controller) map[string]interface{} {
+	out := make(map[string]interface{})
+	if sctl == nil {
+		return out
+	}
+	for _, r := range sctl.GetRegistries() {
+		if d, ok := r.ServiceDiscovery.(registryDumper); ok {
+			out[r.ClusterID] = d.DebugDump()
+		}
+	}
+	return out
+}
+
+// configDigests returns the digests of the configs of the config store, sorted by type, namespace
+// and name.
+func (s *DiscoveryServer) configDigests() (interface{}, error) {
+	digests := make([]ConfigDigest, 0)
+	for _, typ := range s.Env.IstioConfigStore.ConfigDescriptor() {
+		configs, err := s.Env.IstioConfigStore.List(typ.Type, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %v", typ.Type, err)
+		}
+		for _, c := range configs {
+			b, err := json.Marshal(c.Spec)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal %s %s/%s: %v", c.Type, c.Namespace, c.Name, err)
+			}
+			sum := sha256.Sum256(b)
+			digests = append(digests, ConfigDigest{
+				Type:            c.Type,
+				Namespace:       c.Namespace,
+				Name:            c.Name,
+				ResourceVersion: c.ResourceVersion,
+				SHA256:          hex.EncodeToString(sum[:]),
+			})
+		}
+	}
+	sort.Slice(digests, func(i, j int) bool {
+		a, b := digests[i], digests[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return digests, nil
+}
+
+// proxySnapshots returns the proxies connected to the server, sorted by connection ID.
+func proxySnapshots() []ProxySnapshot {
+	proxies := make([]ProxySnapshot, 0)
+	adsClientsMutex.RLock()
+	for _, con := range adsClients {
+		con.mu.RLock()
+		p := ProxySnapshot{
+			ConID:    con.ConID,
+			PeerAddr: con.PeerAddr,
+			Connect:  con.Connect,
+			Clusters: append([]string{}, con.Clusters...),
+			Routes:   append([]string{}, con.Routes...),
+			NoncesSent: map[string]string{
+				ClusterType:  con.ClusterNonceSent,
+				ListenerType: con.ListenerNonceSent,
+				RouteType:    con.RouteNonceSent,
+				EndpointType: con.EndpointNonceSent,
+			},
+			NoncesAcked: map[string]string{
+				ClusterType:  con.ClusterNonceAcked,
+				ListenerType: con.ListenerNonceAcked,
+				RouteType:    con.RouteNonceAcked,
+				EndpointType: con.EndpointNonceAcked,
+			},
+		}
+		if con.node != nil {
+			p.ID = con.node.ID
+			p.Type = con.node.Type
+			p.ClusterID = con.node.ClusterID
+			p.IPAddresses = con.node.IPAddresses
+			p.Namespace = con.node.ConfigNamespace
+			p.Metadata = con.node.Metadata
+		}
+		con.mu.RUnlock()
+		proxies = append(proxies, p)
+	}
+	adsClientsMutex.RUnlock()
+	sort.Slice(proxies, func(i, j int) bool { return proxies[i].ConID < proxies[j].ConID })
+	return proxies
+}
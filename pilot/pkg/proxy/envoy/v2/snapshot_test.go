@@ -0,0 +1,141 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+func TestPushHistory(t *testing.T) {
+	h := &pushHistory{}
+	h.record(&model.PushRequest{
+		Full:               true,
+		ConfigTypesUpdated: map[string]struct{}{"b": {}, "a": {}},
+	}, "v1", nil)
+	for i := 0; i < pushHistorySize; i++ {
+		h.record(&model.PushRequest{EdsUpdates: map[string]struct{}{"svc": {}}}, "v2", nil)
+	}
+	h.record(&model.PushRequest{Full: true}, "", errors.New("failed"))
+
+	records := h.list()
+	if len(records) != pushHistorySize {
+		t.Fatalf("got %d records, want %d", len(records), pushHistorySize)
+	}
+	if records[0].Version != "v2" || !reflect.DeepEqual(records[0].EdsUpdates, []string{"svc"}) {
+		t.Errorf("the oldest push should be dropped, got %+v", records[0])
+	}
+	if last := records[len(records)-1]; !last.Full || last.Error != "failed" {
+		t.Errorf("got last push %+v, want the failed full push", last)
+	}
+
+	h = &pushHistory{}
+	h.record(&model.PushRequest{ConfigTypesUpdated: map[string]struct{}{"b": {}, "a": {}}}, "v1", nil)
+	if got := h.list()[0].ConfigTypesUpdated; !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("got config types %v, want them sorted", got)
+	}
+}
+
+func TestSnapshotz(t *testing.T) {
+	store := model.MakeIstioStore(memory.Make(schemas.Istio))
+	if _, err := store.Create(model.Config{
+		ConfigMeta: model.ConfigMeta{
+			Type:      schemas.VirtualService.Type,
+			Version:   schemas.VirtualService.Version,
+			Name:      "reviews",
+			Namespace: "default",
+		},
+		Spec: &networking.VirtualService{
+			Hosts: []string{"reviews"},
+			Http: []*networking.HTTPRoute{{
+				Route: []*networking.HTTPRouteDestination{{Destination: &networking.Destination{Host: "reviews"}}},
+			}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	registry := NewMemServiceDiscovery(map[host.Name]*model.Service{
+		"reviews.default.svc.cluster.local": {Hostname: "reviews.default.svc.cluster.local"},
+	}, 2)
+	s := &DiscoveryServer{Env: &model.Environment{ServiceDiscovery: registry, IstioConfigStore: store}}
+	s.pushHistory.record(&model.PushRequest{Full: true}, "v1", nil)
+
+	rec := httptest.NewRecorder()
+	s.snapshotz(nil, rec, httptest.NewRequest("GET", "/debug/snapshot", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/gzip" {
+		t.Errorf("got content type %q, want application/gzip", ct)
+	}
+
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if files[hdr.Name], err = ioutil.ReadAll(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"metadata.json", "services.json", "registries.json", "configs.json",
+		"proxies.json", "push_history.json", "push_status.json"} {
+		if _, f := files[name]; !f {
+			t.Errorf("missing %s in the snapshot", name)
+		}
+	}
+
+	var digests []ConfigDigest
+	if err := json.Unmarshal(files["configs.json"], &digests); err != nil {
+		t.Fatal(err)
+	}
+	if len(digests) != 1 || digests[0].Name != "reviews" || digests[0].SHA256 == "" {
+		t.Errorf("got config digests %+v, want the digest of the virtual service", digests)
+	}
+	var services []*model.Service
+	if err := json.Unmarshal(files["services.json"], &services); err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || services[0].Hostname != "reviews.default.svc.cluster.local" {
+		t.Errorf("got services %+v, want the reviews service", services)
+	}
+	var history []PushRecord
+	if err := json.Unmarshal(files["push_history.json"], &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Version != "v1" {
+		t.Errorf("got push history %+v, want the recorded push", history)
+	}
+}
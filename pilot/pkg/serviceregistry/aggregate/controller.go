@@ -15,12 +15,15 @@
 package aggregate
 
 import (
+	"fmt"
+	"reflect"
 	"sync"
 
 	"github.com/hashicorp/go-multierror"
 
 	"istio.io/pkg/log"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/serviceregistry"
 	"istio.io/istio/pkg/config/host"
@@ -48,16 +51,128 @@ var (
 type Controller struct {
 	registries []Registry
 	storeLock  sync.RWMutex
+
+	// servicePorts stores the ports of the services found in multiple clusters, as of the last
+	// call to Services.
+	servicePorts      map[host.Name]*ServicePorts
+	servicePortsMutex sync.RWMutex
+
+	// duplicateServices stores the registries of the services defined in multiple weighted
+	// registries, merged as of the last call to Services.
+	duplicateServices      map[serviceKey][]string
+	duplicateServicesMutex sync.Mutex
+
+	// mergePolicy is how the instances of a service found in multiple registries are combined, and
+	// localClusterID the cluster preferred by MergePolicyPreferLocal.
+	mergePolicy    MergePolicy
+	localClusterID string
+}
+
+// MergePolicy is how the instances of a service found in multiple registries are combined.
+type MergePolicy string
+
+const (
+	// MergePolicyMergeAll keeps the instances of all the registries, including duplicates.
+	MergePolicyMergeAll MergePolicy = "merge-all"
+	// MergePolicyDedupeByIPAndNetwork keeps a single instance per address, port and network, from
+	// the first registry defining it. The same address in different networks is not a duplicate.
+	MergePolicyDedupeByIPAndNetwork MergePolicy = "dedupe-by-ip+network"
+	// MergePolicyPreferLocal deduplicates like MergePolicyDedupeByIPAndNetwork, but keeps the instance
+	// of the local cluster when it is one of the duplicates.
+	MergePolicyPreferLocal MergePolicy = "prefer-local"
+)
+
+// ParseMergePolicy returns the merge policy of the given name.
+func ParseMergePolicy(name string) (MergePolicy, error) {
+	switch p := MergePolicy(name); p {
+	case MergePolicyMergeAll, MergePolicyDedupeByIPAndNetwork, MergePolicyPreferLocal:
+		return p, nil
+	case "":
+		return MergePolicyMergeAll, nil
+	default:
+		return "", fmt.Errorf("unknown endpoint merge policy %q, expected one of %v, %v or %v", name,
+			MergePolicyMergeAll, MergePolicyDedupeByIPAndNetwork, MergePolicyPreferLocal)
+	}
+}
+
+// ServicePorts is the provenance of the ports of a service found in multiple clusters.
+type ServicePorts struct {
+	// Clusters are the ports of the service in each cluster
+	Clusters map[string]model.PortList `json:"clusters"`
+	// Merged are the ports of the aggregated service: the ports of the first cluster, then the
+	// ports of the other clusters that don't conflict with them.
+	Merged model.PortList `json:"merged"`
+	// Conflicts describe the ports left out of the aggregated service
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// serviceKey identifies the services with the same hostname and namespace across registries.
+type serviceKey struct {
+	hostname  host.Name
+	namespace string
+}
+
+// clusterPorts are the ports of a service in a cluster
+type clusterPorts struct {
+	clusterID string
+	ports     model.PortList
+}
+
+// clusterInstances are the instances of a service in a cluster
+type clusterInstances struct {
+	clusterID string
+	instances []*model.ServiceInstance
+}
+
+// endpointKey identifies the same endpoint across registries.
+type endpointKey struct {
+	address string
+	port    int
+	network string
 }
 
 // NewController creates a new Aggregate controller
 func NewController() *Controller {
+	mergePolicy, err := ParseMergePolicy(features.EndpointMergePolicy)
+	if err != nil {
+		log.Warnf("%v, using %v", err, MergePolicyMergeAll)
+		mergePolicy = MergePolicyMergeAll
+	}
 
 	return &Controller{
-		registries: []Registry{},
+		registries:        []Registry{},
+		servicePorts:      make(map[host.Name]*ServicePorts),
+		duplicateServices: make(map[serviceKey][]string),
+		mergePolicy:       mergePolicy,
 	}
 }
 
+// SetMergePolicy sets how the instances of a service found in multiple registries are combined
+// by InstancesByPort and by the endpoints pushed by EDS.
+func (c *Controller) SetMergePolicy(policy MergePolicy) {
+	c.storeLock.Lock()
+	defer c.storeLock.Unlock()
+
+	c.mergePolicy = policy
+}
+
+// SetLocalCluster sets the cluster Pilot runs in, whose instances are preferred by MergePolicyPreferLocal.
+func (c *Controller) SetLocalCluster(clusterID string) {
+	c.storeLock.Lock()
+	defer c.storeLock.Unlock()
+
+	c.localClusterID = clusterID
+}
+
+// EndpointMergePolicy returns how the instances of a service found in multiple registries are combined,
+// and the local cluster preferred by MergePolicyPreferLocal.
+func (c *Controller) EndpointMergePolicy() (MergePolicy, string) {
+	c.storeLock.RLock()
+	defer c.storeLock.RUnlock()
+
+	return c.mergePolicy, c.localClusterID
+}
+
 // AddRegistry adds registries into the aggregated controller
 func (c *Controller) AddRegistry(registry Registry) {
 	c.storeLock.Lock()
@@ -111,16 +226,45 @@ func (c *Controller) Services() ([]*model.Service, error) {
 	// smap is a map of hostname (string) to service, used to identify services that
 	// are installed in multiple clusters.
 	smap := make(map[host.Name]*model.Service)
+	// sindex is the index of the services in the result, and sports their ports in each cluster.
+	sindex := make(map[host.Name]int)
+	sports := make(map[host.Name][]clusterPorts)
+	// kindex is the index of the services in the result by hostname and namespace, kregistry the
+	// index of the registry defining them first, and kweights the registry weights of the services
+	// in each cluster. dindex is the index of the services also defined by a previous registry, and
+	// dregistries the registries defining them.
+	kindex := make(map[serviceKey]int)
+	kregistry := make(map[serviceKey]int)
+	kweights := make(map[serviceKey]map[string]uint32)
+	dindex := make(map[serviceKey][]int)
+	dregistries := make(map[serviceKey][]string)
 
 	services := make([]*model.Service, 0)
 	var errs error
 	// Locking Registries list while walking it to prevent inconsistent results
-	for _, r := range c.GetRegistries() {
+	registries := c.GetRegistries()
+	for ri, r := range registries {
 		svcs, err := r.Services()
 		if err != nil {
 			errs = multierror.Append(errs, err)
 			continue
 		}
+		// addService adds the service of the registry to the result.
+		addService := func(s *model.Service) {
+			k := serviceKey{hostname: s.Hostname, namespace: s.Attributes.Namespace}
+			if first, f := kregistry[k]; !f {
+				kindex[k] = len(services)
+				kregistry[k] = ri
+				kweights[k] = make(map[string]uint32)
+			} else if first != ri {
+				// The service is defined by a previous registry, for example a ServiceEntry for a
+				// Kubernetes Service during a migration.
+				dindex[k] = append(dindex[k], len(services))
+				dregistries[k] = append(dregistries[k], string(r.Name))
+			}
+			kweights[k][r.ClusterID] = s.Attributes.RegistryWeight
+			services = append(services, s)
+		}
 		// Race condition: multiple threads may call Services, and multiple services
 		// may modify one of the service's cluster ID
 		clusterAddressesMutex.Lock()
@@ -129,7 +273,9 @@ func (c *Controller) Services() ([]*model.Service, error) {
 			// Do not bother checking for the cluster ID.
 			// DO NOT ASSIGN CLUSTER ID to non-k8s registries. This will prevent service entries with multiple
 			// VIPs or CIDR ranges in the address field
-			services = append(services, svcs...)
+			for _, s := range svcs {
+				addService(s)
+			}
 		} else {
 			// This is K8S typically
 			for _, s := range svcs {
@@ -141,8 +287,13 @@ func (c *Controller) Services() ([]*model.Service, error) {
 					// the order is less clear.
 					sp = s
 					smap[s.Hostname] = sp
-					services = append(services, sp)
+					sindex[s.Hostname] = len(services)
+					addService(sp)
+				} else {
+					kweights[serviceKey{hostname: sp.Hostname, namespace: sp.Attributes.Namespace}][r.ClusterID] =
+						s.Attributes.RegistryWeight
 				}
+				sports[s.Hostname] = append(sports[s.Hostname], clusterPorts{clusterID: r.ClusterID, ports: s.Ports})
 
 				sp.Mutex.Lock()
 				// If the registry has a cluster ID, keep track of the cluster and the
@@ -163,25 +314,182 @@ func (c *Controller) Services() ([]*model.Service, error) {
 		}
 		clusterAddressesMutex.Unlock()
 	}
-	return services, errs
+
+	servicePorts := make(map[host.Name]*ServicePorts)
+	for hostname, ports := range sports {
+		if len(ports) < 2 {
+			continue
+		}
+		sp := mergePorts(ports)
+		servicePorts[hostname] = sp
+		if i := sindex[hostname]; len(sp.Merged) != len(services[i].Ports) {
+			// The service of the first cluster is owned by its registry, so the ports are merged in a copy.
+			svc := services[i].DeepCopy()
+			svc.Ports = sp.Merged
+			services[i] = svc
+		}
+	}
+	c.setServicePorts(servicePorts)
+
+	// The endpoints of a service defined in multiple registries are weighted in EDS only if every
+	// registry sets a weight. The services of the other registries are then left out, so that the
+	// cluster of the first service has the endpoints of all the registries. Otherwise the services
+	// are all kept, as if they were unrelated.
+	duplicates := make(map[serviceKey][]string)
+	merged := make(map[int]bool)
+	for k, weights := range kweights {
+		if len(weights) < 2 || !allRegistryWeights(weights) {
+			continue
+		}
+		// The service of the first registry is owned by it, so the weights are set in a copy.
+		i := kindex[k]
+		svc := services[i].DeepCopy()
+		svc.Attributes.ClusterRegistryWeights = weights
+		services[i] = svc
+		if len(dindex[k]) == 0 {
+			continue
+		}
+		duplicates[k] = append([]string{string(registries[kregistry[k]].Name)}, dregistries[k]...)
+		for _, d := range dindex[k] {
+			merged[d] = true
+		}
+	}
+	c.setDuplicateServices(duplicates)
+	if len(merged) == 0 {
+		return services, errs
+	}
+
+	out := make([]*model.Service, 0, len(services)-len(merged))
+	for i, svc := range services {
+		if !merged[i] {
+			out = append(out, svc)
+		}
+	}
+	return out, errs
+}
+
+// allRegistryWeights returns true if every registry sets a weight.
+func allRegistryWeights(weights map[string]uint32) bool {
+	for _, w := range weights {
+		if w == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// mergePorts merges the ports of a service in multiple clusters, in the order of the clusters.
+// A port conflicts with the merged ports if it has the same number or name as one of them,
+// without being the same port.
+func mergePorts(ports []clusterPorts) *ServicePorts {
+	sp := &ServicePorts{
+		Clusters: make(map[string]model.PortList, len(ports)),
+		Merged:   append(model.PortList{}, ports[0].ports...),
+	}
+	for _, cp := range ports {
+		sp.Clusters[cp.clusterID] = cp.ports
+	}
+	for _, cp := range ports[1:] {
+		for _, p := range cp.ports {
+			existing, found := sp.Merged.GetByPort(p.Port)
+			if !found {
+				existing, found = sp.Merged.Get(p.Name)
+			}
+			if !found {
+				sp.Merged = append(sp.Merged, p)
+				continue
+			}
+			if existing.Name != p.Name || existing.Port != p.Port || existing.Protocol != p.Protocol {
+				sp.Conflicts = append(sp.Conflicts, fmt.Sprintf("port %s %d/%s of cluster %s conflicts with port %s %d/%s",
+					p.Name, p.Port, p.Protocol, cp.clusterID, existing.Name, existing.Port, existing.Protocol))
+			}
+		}
+	}
+	return sp
+}
+
+// setServicePorts stores the ports of the services found in multiple clusters, warning about
+// the new port conflicts.
+func (c *Controller) setServicePorts(servicePorts map[host.Name]*ServicePorts) {
+	c.servicePortsMutex.Lock()
+	defer c.servicePortsMutex.Unlock()
+
+	for hostname, sp := range servicePorts {
+		if prev, f := c.servicePorts[hostname]; f && reflect.DeepEqual(prev.Conflicts, sp.Conflicts) {
+			continue
+		}
+		for _, conflict := range sp.Conflicts {
+			log.Warnf("service %s in multiple clusters: %s", hostname, conflict)
+		}
+	}
+	c.servicePorts = servicePorts
+}
+
+// setDuplicateServices stores the registries of the merged services, logging the new duplicates.
+func (c *Controller) setDuplicateServices(duplicates map[serviceKey][]string) {
+	c.duplicateServicesMutex.Lock()
+	defer c.duplicateServicesMutex.Unlock()
+
+	for k, registries := range duplicates {
+		if prev, f := c.duplicateServices[k]; f && reflect.DeepEqual(prev, registries) {
+			continue
+		}
+		log.Infof("service %s/%s is defined in the registries %v, the first definition is used and the weighted endpoints merged",
+			k.namespace, k.hostname, registries)
+	}
+	c.duplicateServices = duplicates
+}
+
+// ServicePorts returns the provenance of the ports of the services found in multiple clusters,
+// as of the last call to Services.
+func (c *Controller) ServicePorts() map[host.Name]*ServicePorts {
+	c.servicePortsMutex.RLock()
+	defer c.servicePortsMutex.RUnlock()
+
+	return c.servicePorts
 }
 
 // GetService retrieves a service by hostname if exists
+// The ports of a service found in multiple clusters are merged, as in Services.
 func (c *Controller) GetService(hostname host.Name) (*model.Service, error) {
 	var errs error
+	var out *model.Service
+	var ports []clusterPorts
 	for _, r := range c.GetRegistries() {
+		if out != nil && r.ClusterID == "" {
+			continue
+		}
 		service, err := r.GetService(hostname)
 		if err != nil {
 			errs = multierror.Append(errs, err)
-		} else if service != nil {
-			if errs != nil {
-				log.Warnf("GetService() found match but encountered an error: %v", errs)
+			continue
+		}
+		if service == nil {
+			continue
+		}
+		if out == nil {
+			out = service
+			if r.ClusterID == "" {
+				break
 			}
-			return service, nil
 		}
-
+		ports = append(ports, clusterPorts{clusterID: r.ClusterID, ports: service.Ports})
+	}
+	if out == nil {
+		return nil, errs
 	}
-	return nil, errs
+	if errs != nil {
+		log.Warnf("GetService() found match but encountered an error: %v", errs)
+	}
+	if len(ports) > 1 {
+		if merged := mergePorts(ports).Merged; len(merged) != len(out.Ports) {
+			// The service of the first cluster is owned by its registry, so the ports are merged in a copy.
+			svc := out.DeepCopy()
+			svc.Ports = merged
+			out = svc
+		}
+	}
+	return out, nil
 }
 
 // ManagementPorts retrieves set of health check ports by instance IP
@@ -210,24 +518,71 @@ func (c *Controller) WorkloadHealthCheckInfo(addr string) model.ProbeList {
 // any of the supplied labels. All instances match an empty label list.
 func (c *Controller) InstancesByPort(svc *model.Service, port int,
 	labels labels.Collection) ([]*model.ServiceInstance, error) {
-	var instances, tmpInstances []*model.ServiceInstance
+	var instances []clusterInstances
 	var errs error
 	for _, r := range c.GetRegistries() {
-		var err error
-		tmpInstances, err = r.InstancesByPort(svc, port, labels)
+		tmpInstances, err := r.InstancesByPort(svc, port, labels)
 		if err != nil {
 			errs = multierror.Append(errs, err)
 		} else if len(tmpInstances) > 0 {
 			if errs != nil {
 				log.Warnf("Instances() found match but encountered an error: %v", errs)
 			}
-			instances = append(instances, tmpInstances...)
+			instances = append(instances, clusterInstances{clusterID: r.ClusterID, instances: tmpInstances})
 		}
 	}
 	if len(instances) > 0 {
 		errs = nil
 	}
-	return instances, errs
+	policy, localClusterID := c.EndpointMergePolicy()
+	return mergeInstances(instances, policy, localClusterID), errs
+}
+
+// mergeInstances combines the instances of a service in multiple registries, in the order of the
+// registries, according to the merge policy.
+func mergeInstances(instances []clusterInstances, policy MergePolicy, localClusterID string) []*model.ServiceInstance {
+	var out []*model.ServiceInstance
+	if len(instances) < 2 || policy == MergePolicyMergeAll {
+		for _, ci := range instances {
+			out = append(out, ci.instances...)
+		}
+		return out
+	}
+
+	if policy == MergePolicyPreferLocal {
+		// The local instances are merged first, so that they win over their duplicates.
+		local := make([]clusterInstances, 0, len(instances))
+		for _, ci := range instances {
+			if ci.clusterID == localClusterID {
+				local = append(local, ci)
+			}
+		}
+		for _, ci := range instances {
+			if ci.clusterID != localClusterID {
+				local = append(local, ci)
+			}
+		}
+		instances = local
+	}
+
+	seen := make(map[endpointKey]string)
+	for _, ci := range instances {
+		for _, instance := range ci.instances {
+			k := endpointKey{
+				address: instance.Endpoint.Address,
+				port:    instance.Endpoint.Port,
+				network: instance.Endpoint.Network,
+			}
+			if first, f := seen[k]; f {
+				log.Debugf("Dropping endpoint %v:%v of cluster %q, duplicate of cluster %q", k.address, k.port,
+					ci.clusterID, first)
+				continue
+			}
+			seen[k] = ci.clusterID
+			out = append(out, instance)
+		}
+	}
+	return out
 }
 
 // GetProxyServiceInstances lists service instances co-located with a given proxy
@@ -294,9 +649,9 @@ func (c *Controller) Run(stop <-chan struct{}) {
 }
 
 // AppendServiceHandler implements a service catalog operation
-func (c *Controller) AppendServiceHandler(f func(*model.Service, model.Event)) error {
+func (c *Controller) AppendServiceHandler(f func(*model.Service, model.Event), filters ...model.HandlerFilter) error {
 	for _, r := range c.GetRegistries() {
-		if err := r.AppendServiceHandler(f); err != nil {
+		if err := r.AppendServiceHandler(f, filters...); err != nil {
 			log.Infof("Fail to append service handler to adapter %s", r.Name)
 			return err
 		}
@@ -305,9 +660,9 @@ func (c *Controller) AppendServiceHandler(f func(*model.Service, model.Event)) e
 }
 
 // AppendInstanceHandler implements a service instance catalog operation
-func (c *Controller) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event)) error {
+func (c *Controller) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event), filters ...model.HandlerFilter) error {
 	for _, r := range c.GetRegistries() {
-		if err := r.AppendInstanceHandler(f); err != nil {
+		if err := r.AppendInstanceHandler(f, filters...); err != nil {
 			log.Infof("Fail to append instance handler to adapter %s", r.Name)
 			return err
 		}
@@ -324,3 +679,18 @@ func (c *Controller) GetIstioServiceAccounts(svc *model.Service, ports []int) []
 	}
 	return nil
 }
+
+// NetworkGateways implements model.NetworkGatewayDiscovery, merging the gateways discovered by the registries
+func (c *Controller) NetworkGateways() map[string][]*model.NetworkGateway {
+	out := make(map[string][]*model.NetworkGateway)
+	for _, r := range c.GetRegistries() {
+		d, ok := r.ServiceDiscovery.(model.NetworkGatewayDiscovery)
+		if !ok {
+			continue
+		}
+		for network, gateways := range d.NetworkGateways() {
+			out[network] = append(out[network], gateways...)
+		}
+	}
+	return out
+}
@@ -152,6 +152,161 @@ func TestServicesForMultiCluster(t *testing.T) {
 	t.Logf("Return service ClusterVIPs match ground truth")
 }
 
+func TestServicesForMultiClusterPorts(t *testing.T) {
+	aggregateCtl := buildMockControllerForMultiCluster()
+
+	// cluster-2 has an additional port, and a port conflicting with cluster-1
+	svc2 := memory.MakeService("hello.default.svc.cluster.local", "10.1.2.0")
+	svc2.Ports = append(svc2.Ports[1:],
+		&model.Port{Name: "grpc", Port: 7070, Protocol: protocol.GRPC},
+		&model.Port{Name: "http-alt", Port: 80, Protocol: protocol.HTTP})
+	discovery2.AddService(svc2.Hostname, svc2)
+	svc1, _ := discovery1.GetService(memory.HelloService.Hostname)
+
+	services, err := aggregateCtl.Services()
+	if err != nil {
+		t.Fatalf("Services() encountered unexpected error: %v", err)
+	}
+	var hello *model.Service
+	for _, svc := range services {
+		if svc.Hostname == memory.HelloService.Hostname {
+			hello = svc
+		}
+	}
+	if hello == nil {
+		t.Fatalf("Services() => no service %s", memory.HelloService.Hostname)
+	}
+
+	wantPorts := append(model.PortList{}, svc1.Ports...)
+	wantPorts = append(wantPorts, &model.Port{Name: "grpc", Port: 7070, Protocol: protocol.GRPC})
+	if !reflect.DeepEqual(hello.Ports, wantPorts) {
+		t.Errorf("Services() => ports %v, want %v", hello.Ports.GetNames(), wantPorts.GetNames())
+	}
+	if len(svc1.Ports) != len(wantPorts)-1 {
+		t.Errorf("Services() merged the ports into the service of cluster-1")
+	}
+	if !reflect.DeepEqual(hello.ClusterVIPs, map[string]string{"cluster-1": "10.1.1.0", "cluster-2": "10.1.2.0"}) {
+		t.Errorf("Services() => ClusterVIPs %v", hello.ClusterVIPs)
+	}
+
+	sp := aggregateCtl.ServicePorts()[memory.HelloService.Hostname]
+	if sp == nil {
+		t.Fatalf("ServicePorts() => no ports for %s", memory.HelloService.Hostname)
+	}
+	if !reflect.DeepEqual(sp.Clusters, map[string]model.PortList{"cluster-1": svc1.Ports, "cluster-2": svc2.Ports}) {
+		t.Errorf("ServicePorts() => unexpected cluster ports %v", sp.Clusters)
+	}
+	wantConflicts := []string{"port http-alt 80/HTTP of cluster cluster-2 conflicts with port http 80/HTTP"}
+	if !reflect.DeepEqual(sp.Conflicts, wantConflicts) {
+		t.Errorf("ServicePorts() => conflicts %v, want %v", sp.Conflicts, wantConflicts)
+	}
+	if _, f := aggregateCtl.ServicePorts()[memory.WorldService.Hostname]; f {
+		t.Errorf("ServicePorts() => unexpected ports for %s, found in a single cluster", memory.WorldService.Hostname)
+	}
+}
+
+func TestGetServiceForMultiClusterPorts(t *testing.T) {
+	aggregateCtl := buildMockControllerForMultiCluster()
+
+	// cluster-2 has an additional port, and a port conflicting with cluster-1
+	svc2 := memory.MakeService("hello.default.svc.cluster.local", "10.1.2.0")
+	svc2.Ports = append(svc2.Ports[1:],
+		&model.Port{Name: "grpc", Port: 7070, Protocol: protocol.GRPC},
+		&model.Port{Name: "http-alt", Port: 80, Protocol: protocol.HTTP})
+	discovery2.AddService(svc2.Hostname, svc2)
+	svc1, _ := discovery1.GetService(memory.HelloService.Hostname)
+
+	hello, err := aggregateCtl.GetService(memory.HelloService.Hostname)
+	if err != nil {
+		t.Fatalf("GetService() encountered unexpected error: %v", err)
+	}
+	wantPorts := append(model.PortList{}, svc1.Ports...)
+	wantPorts = append(wantPorts, &model.Port{Name: "grpc", Port: 7070, Protocol: protocol.GRPC})
+	if !reflect.DeepEqual(hello.Ports, wantPorts) {
+		t.Errorf("GetService() => ports %v, want %v", hello.Ports.GetNames(), wantPorts.GetNames())
+	}
+	if len(svc1.Ports) != len(wantPorts)-1 {
+		t.Errorf("GetService() merged the ports into the service of cluster-1")
+	}
+
+	services, err := aggregateCtl.Services()
+	if err != nil {
+		t.Fatalf("Services() encountered unexpected error: %v", err)
+	}
+	for _, svc := range services {
+		if svc.Hostname == memory.HelloService.Hostname && !reflect.DeepEqual(svc.Ports, hello.Ports) {
+			t.Errorf("GetService() => ports %v, Services() => ports %v", hello.Ports.GetNames(), svc.Ports.GetNames())
+		}
+	}
+}
+
+func TestServicesInMultipleRegistries(t *testing.T) {
+	kubeSvc := memory.MakeService("hello.default.svc.cluster.local", "10.1.1.0")
+	kubeSvc.Attributes.RegistryWeight = 10
+	entrySvc := memory.MakeService("hello.default.svc.cluster.local", "10.1.2.0")
+	entrySvc.Attributes.RegistryWeight = 90
+
+	aggregateCtl := NewController()
+	aggregateCtl.AddRegistry(Registry{
+		Name:             serviceregistry.KubernetesRegistry,
+		ClusterID:        "cluster-1",
+		ServiceDiscovery: memory.NewDiscovery(map[host.Name]*model.Service{kubeSvc.Hostname: kubeSvc}, 2),
+		Controller:       &memory.MockController{},
+	})
+	entries := memory.NewDiscovery(map[host.Name]*model.Service{
+		entrySvc.Hostname:            entrySvc,
+		memory.WorldService.Hostname: memory.WorldService,
+	}, 2)
+	aggregateCtl.AddRegistry(Registry{
+		Name:             "ServiceEntries",
+		ServiceDiscovery: entries,
+		Controller:       &memory.MockController{},
+	})
+
+	services, err := aggregateCtl.Services()
+	if err != nil {
+		t.Fatalf("Services() encountered unexpected error: %v", err)
+	}
+	if len(services) != 2 {
+		t.Fatalf("Services() => %d services, want the duplicate service merged", len(services))
+	}
+	hello := services[0]
+	if hello.Hostname != kubeSvc.Hostname || hello.Address != kubeSvc.Address {
+		t.Fatalf("Services() => %s %s, want the service of the first registry", hello.Hostname, hello.Address)
+	}
+	wantWeights := map[string]uint32{"cluster-1": 10, "": 90}
+	if !reflect.DeepEqual(hello.Attributes.ClusterRegistryWeights, wantWeights) {
+		t.Errorf("Services() => registry weights %v, want %v", hello.Attributes.ClusterRegistryWeights, wantWeights)
+	}
+	if kubeSvc.Attributes.ClusterRegistryWeights != nil {
+		t.Errorf("Services() set the registry weights in the service of the registry")
+	}
+
+	// Unless every registry sets a weight, the services are all kept, and their endpoints unweighted.
+	for _, weight := range []uint32{0, 10} {
+		entrySvc = memory.MakeService("hello.default.svc.cluster.local", "10.1.2.0")
+		entries.AddService(entrySvc.Hostname, entrySvc)
+		kubeSvc.Attributes.RegistryWeight = weight
+		services, err = aggregateCtl.Services()
+		if err != nil {
+			t.Fatalf("Services() encountered unexpected error: %v", err)
+		}
+		if len(services) != 3 {
+			t.Fatalf("Services() => %d services, want the services of both registries", len(services))
+		}
+		for _, svc := range services {
+			if svc.Attributes.ClusterRegistryWeights != nil {
+				t.Errorf("Services() => registry weights %v for %s, want none",
+					svc.Attributes.ClusterRegistryWeights, svc.Address)
+			}
+		}
+		if services[0].Address != kubeSvc.Address || services[1].Address != entrySvc.Address &&
+			services[2].Address != entrySvc.Address {
+			t.Errorf("Services() => %v, want the services of both registries", services)
+		}
+	}
+}
+
 func TestServices(t *testing.T) {
 	aggregateCtl := buildMockController()
 	// List Services from aggregate controller
@@ -358,6 +513,100 @@ func TestInstances(t *testing.T) {
 	}
 }
 
+func TestInstancesMergePolicy(t *testing.T) {
+	cases := []struct {
+		policy MergePolicy
+		want   int
+	}{
+		{policy: MergePolicyMergeAll, want: 4},
+		{policy: MergePolicyDedupeByIPAndNetwork, want: 2},
+		{policy: MergePolicyPreferLocal, want: 2},
+	}
+	for _, c := range cases {
+		t.Run(string(c.policy), func(t *testing.T) {
+			// The hello services of both clusters have instances with the same addresses.
+			aggregateCtl := buildMockControllerForMultiCluster()
+			aggregateCtl.SetMergePolicy(c.policy)
+			aggregateCtl.SetLocalCluster("cluster-2")
+
+			instances, err := aggregateCtl.InstancesByPort(memory.HelloService, 80, labels.Collection{})
+			if err != nil {
+				t.Fatalf("Instances() encountered unexpected error: %v", err)
+			}
+			if len(instances) != c.want {
+				t.Errorf("got %d instances, want %d", len(instances), c.want)
+			}
+		})
+	}
+}
+
+func TestMergeInstances(t *testing.T) {
+	instance := func(address, network string) *model.ServiceInstance {
+		return &model.ServiceInstance{Endpoint: model.NetworkEndpoint{Address: address, Port: 80, Network: network}}
+	}
+	cluster1 := clusterInstances{clusterID: "cluster-1", instances: []*model.ServiceInstance{
+		instance("10.0.0.1", "network1"), instance("10.0.0.2", "network1"),
+	}}
+	cluster2 := clusterInstances{clusterID: "cluster-2", instances: []*model.ServiceInstance{
+		instance("10.0.0.1", "network1"), instance("10.0.0.2", "network2"),
+	}}
+	all := []clusterInstances{cluster1, cluster2}
+
+	cases := []struct {
+		name   string
+		policy MergePolicy
+		local  string
+		want   []*model.ServiceInstance
+	}{
+		{
+			name:   "merge all",
+			policy: MergePolicyMergeAll,
+			want:   append(append([]*model.ServiceInstance{}, cluster1.instances...), cluster2.instances...),
+		},
+		{
+			name:   "dedupe keeps the same address in other networks",
+			policy: MergePolicyDedupeByIPAndNetwork,
+			want:   []*model.ServiceInstance{cluster1.instances[0], cluster1.instances[1], cluster2.instances[1]},
+		},
+		{
+			name:   "prefer local",
+			policy: MergePolicyPreferLocal,
+			local:  "cluster-2",
+			want:   []*model.ServiceInstance{cluster2.instances[0], cluster2.instances[1], cluster1.instances[1]},
+		},
+		{
+			name:   "prefer unknown local cluster",
+			policy: MergePolicyPreferLocal,
+			local:  "cluster-3",
+			want:   []*model.ServiceInstance{cluster1.instances[0], cluster1.instances[1], cluster2.instances[1]},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := mergeInstances(all, c.policy, c.local)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("mergeInstances() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseMergePolicy(t *testing.T) {
+	for name, want := range map[string]MergePolicy{
+		"":                     MergePolicyMergeAll,
+		"merge-all":            MergePolicyMergeAll,
+		"dedupe-by-ip+network": MergePolicyDedupeByIPAndNetwork,
+		"prefer-local":         MergePolicyPreferLocal,
+	} {
+		if got, err := ParseMergePolicy(name); err != nil || got != want {
+			t.Errorf("ParseMergePolicy(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseMergePolicy("prefer-remote"); err == nil {
+		t.Error("ParseMergePolicy() should fail for an unknown policy")
+	}
+}
+
 func TestInstancesError(t *testing.T) {
 	aggregateCtl := buildMockController()
 
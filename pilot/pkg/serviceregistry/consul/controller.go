@@ -207,7 +207,8 @@ func (c *Controller) Run(stop <-chan struct{}) {
 }
 
 // AppendServiceHandler implements a service catalog operation
-func (c *Controller) AppendServiceHandler(f func(*model.Service, model.Event)) error {
+func (c *Controller) AppendServiceHandler(f func(*model.Service, model.Event), filters ...model.HandlerFilter) error {
+	f = model.FilterServiceHandler(f, filters...)
 	c.monitor.AppendServiceHandler(func(instances []*api.CatalogService, event model.Event) error {
 		f(convertService(instances), event)
 		return nil
@@ -216,7 +217,8 @@ func (c *Controller) AppendServiceHandler(f func(*model.Service, model.Event)) e
 }
 
 // AppendInstanceHandler implements a service catalog operation
-func (c *Controller) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event)) error {
+func (c *Controller) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event), filters ...model.HandlerFilter) error {
+	f = model.FilterInstanceHandler(f, filters...)
 	c.monitor.AppendInstanceHandler(func(instance *api.CatalogService, event model.Event) error {
 		f(convertInstance(instance), event)
 		return nil
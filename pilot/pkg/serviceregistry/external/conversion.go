@@ -65,6 +65,8 @@ func convertServices(cfg model.Config) []*model.Service {
 		}
 	}
 
+	registryWeight := model.ParseRegistryWeight(cfg.Namespace+"/"+cfg.Name, cfg.Annotations)
+
 	for _, hostname := range serviceEntry.Hosts {
 		if len(serviceEntry.Addresses) > 0 {
 			for _, address := range serviceEntry.Addresses {
@@ -87,6 +89,7 @@ func convertServices(cfg model.Config) []*model.Service {
 							Name:            hostname,
 							Namespace:       cfg.Namespace,
 							ExportTo:        exportTo,
+							RegistryWeight:  registryWeight,
 						},
 					})
 				} else if net.ParseIP(address) != nil {
@@ -102,6 +105,7 @@ func convertServices(cfg model.Config) []*model.Service {
 							Name:            hostname,
 							Namespace:       cfg.Namespace,
 							ExportTo:        exportTo,
+							RegistryWeight:  registryWeight,
 						},
 					})
 				}
@@ -119,6 +123,7 @@ func convertServices(cfg model.Config) []*model.Service {
 					Name:            hostname,
 					Namespace:       cfg.Namespace,
 					ExportTo:        exportTo,
+					RegistryWeight:  registryWeight,
 				},
 			})
 		}
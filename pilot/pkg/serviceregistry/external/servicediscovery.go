@@ -83,14 +83,14 @@ func NewServiceDiscovery(configController model.ConfigStoreCache, store model.Is
 }
 
 // AppendServiceHandler adds service resource event handler
-func (d *ServiceEntryStore) AppendServiceHandler(f func(*model.Service, model.Event)) error {
-	d.serviceHandlers = append(d.serviceHandlers, f)
+func (d *ServiceEntryStore) AppendServiceHandler(f func(*model.Service, model.Event), filters ...model.HandlerFilter) error {
+	d.serviceHandlers = append(d.serviceHandlers, model.FilterServiceHandler(f, filters...))
 	return nil
 }
 
 // AppendInstanceHandler adds instance event handler.
-func (d *ServiceEntryStore) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event)) error {
-	d.instanceHandlers = append(d.instanceHandlers, f)
+func (d *ServiceEntryStore) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event), filters ...model.HandlerFilter) error {
+	d.instanceHandlers = append(d.instanceHandlers, model.FilterInstanceHandler(f, filters...))
 	return nil
 }
 
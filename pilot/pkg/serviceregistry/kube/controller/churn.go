@@ -0,0 +1,244 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"istio.io/pkg/log"
+	"istio.io/pkg/monitoring"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/host"
+)
+
+const (
+	// topEndpointChurnServices is the number of services reported in the pilot_k8s_top_endpoint_churn metric.
+	topEndpointChurnServices = 10
+	// endpointChurnReportInterval is the interval at which the pilot_k8s_top_endpoint_churn metric is updated.
+	endpointChurnReportInterval = 30 * time.Second
+)
+
+var (
+	endpointChanges = monitoring.NewSum(
+		"pilot_k8s_endpoint_changes",
+		"Endpoint addresses added to or removed from the services of the registry.",
+		monitoring.WithLabels(clusterIDTag),
+	)
+
+	// topEndpointChurn is registered with Prometheus directly, as the series of the services dropping
+	// out of the top can't be deleted from the views of the monitoring package.
+	topEndpointChurn = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pilot_k8s_top_endpoint_churn",
+		Help: "Endpoint addresses added to or removed from the services with the most churn within " +
+			"PILOT_ENDPOINT_CHURN_WINDOW, as of the last report.",
+	}, []string{"cluster_id", "service"})
+)
+
+func init() {
+	monitoring.MustRegister(endpointChanges)
+	prometheus.MustRegister(topEndpointChurn)
+}
+
+// ServiceChurn is the number of endpoint addresses added to or removed from a service within the
+// churn window. A single flapping deployment often explains the push load of the whole mesh.
+type ServiceChurn struct {
+	Hostname host.Name `json:"hostname"`
+	Changes  int       `json:"changes"`
+}
+
+// churnSample is the number of endpoint addresses changed by an update.
+type churnSample struct {
+	time    time.Time
+	changes int
+}
+
+// endpointChurn keeps a rolling count of the endpoint addresses added to and removed from each
+// service. A nil endpointChurn tracks nothing.
+type endpointChurn struct {
+	mu        sync.Mutex
+	clusterID string
+	window    time.Duration
+	// threshold is the number of changes within the window above which a warning is logged.
+	threshold int
+
+	addresses map[host.Name]map[string]struct{}
+	samples   map[host.Name][]churnSample
+	warned    map[host.Name]time.Time
+	// reported are the services in the metric as of the last report.
+	reported map[host.Name]bool
+}
+
+func newEndpointChurn(clusterID string, window time.Duration, threshold int) *endpointChurn {
+	if window <= 0 {
+		return nil
+	}
+	return &endpointChurn{
+		clusterID: clusterID,
+		window:    window,
+		threshold: threshold,
+		addresses: make(map[host.Name]map[string]struct{}),
+		samples:   make(map[host.Name][]churnSample),
+		warned:    make(map[host.Name]time.Time),
+		reported:  make(map[host.Name]bool),
+	}
+}
+
+// trim drops the samples of the service older than the window, and returns the changes within it.
+func (ec *endpointChurn) trim(hostname host.Name, now time.Time) int {
+	samples := ec.samples[hostname]
+	i := 0
+	for i < len(samples) && now.Sub(samples[i].time) > ec.window {
+		i++
+	}
+	samples = samples[i:]
+	if len(samples) == 0 {
+		delete(ec.samples, hostname)
+		return 0
+	}
+	ec.samples[hostname] = samples
+	total := 0
+	for _, s := range samples {
+		total += s.changes
+	}
+	return total
+}
+
+// record compares the endpoints of the service with the previous ones, and returns the number of
+// addresses added or removed.
+func (ec *endpointChurn) record(hostname host.Name, endpoints []*model.IstioEndpoint, now time.Time) int {
+	if ec == nil {
+		return 0
+	}
+	current := make(map[string]struct{}, len(endpoints))
+	for _, ep := range endpoints {
+		current[ep.Address] = struct{}{}
+	}
+
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	previous := ec.addresses[hostname]
+	changes := 0
+	for address := range current {
+		if _, f := previous[address]; !f {
+			changes++
+		}
+	}
+	for address := range previous {
+		if _, f := current[address]; !f {
+			changes++
+		}
+	}
+	if len(current) == 0 {
+		delete(ec.addresses, hostname)
+	} else {
+		ec.addresses[hostname] = current
+	}
+	if changes == 0 {
+		return 0
+	}
+
+	ec.samples[hostname] = append(ec.samples[hostname], churnSample{time: now, changes: changes})
+	total := ec.trim(hostname, now)
+	if ec.threshold > 0 && total >= ec.threshold && now.Sub(ec.warned[hostname]) >= ec.window {
+		ec.warned[hostname] = now
+		log.Warnf("%d endpoint addresses of service %s in cluster %s changed within %v, the service is "+
+			"flapping and causing pushes to the whole mesh", total, hostname, ec.clusterID, ec.window)
+	}
+	return changes
+}
+
+// top returns the n services with the most churn within the window, or all of them if n is 0.
+func (ec *endpointChurn) top(n int, now time.Time) []ServiceChurn {
+	if ec == nil {
+		return nil
+	}
+	ec.mu.Lock()
+	out := make([]ServiceChurn, 0, len(ec.samples))
+	for hostname := range ec.samples {
+		if total := ec.trim(hostname, now); total > 0 {
+			out = append(out, ServiceChurn{Hostname: hostname, Changes: total})
+		}
+	}
+	for hostname, t := range ec.warned {
+		if now.Sub(t) >= ec.window {
+			delete(ec.warned, hostname)
+		}
+	}
+	ec.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Changes != out[j].Changes {
+			return out[i].Changes > out[j].Changes
+		}
+		return out[i].Hostname < out[j].Hostname
+	})
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// changes returns the churn of the service within the window.
+func (ec *endpointChurn) changes(hostname host.Name, now time.Time) int {
+	if ec == nil {
+		return 0
+	}
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	return ec.trim(hostname, now)
+}
+
+// report records the services with the most churn in the metric. The series of the services which
+// are no longer among them are removed.
+func (ec *endpointChurn) report(now time.Time) {
+	top := ec.top(topEndpointChurnServices, now)
+	current := make(map[host.Name]bool, len(top))
+	for _, sc := range top {
+		current[sc.Hostname] = true
+		topEndpointChurn.WithLabelValues(ec.clusterID, string(sc.Hostname)).Set(float64(sc.Changes))
+	}
+	for hostname := range ec.reported {
+		if !current[hostname] {
+			topEndpointChurn.DeleteLabelValues(ec.clusterID, string(hostname))
+		}
+	}
+	ec.reported = current
+}
+
+// reportLoop periodically records the services with the most churn until stop is closed.
+func (ec *endpointChurn) reportLoop(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ec.report(time.Now())
+		case <-stop:
+			return
+		}
+	}
+}
+
+// EndpointChurn returns the services of the registry with the most endpoint churn within the
+// churn window, the most flapping first.
+func (c *Controller) EndpointChurn() interface{} {
+	return c.churn.top(0, time.Now())
+}
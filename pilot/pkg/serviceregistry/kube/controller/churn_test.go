@@ -0,0 +1,130 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/host"
+)
+
+func churnEndpoints(addresses ...string) []*model.IstioEndpoint {
+	out := make([]*model.IstioEndpoint, 0, len(addresses))
+	for _, address := range addresses {
+		out = append(out, &model.IstioEndpoint{Address: address})
+	}
+	return out
+}
+
+func TestEndpointChurn(t *testing.T) {
+	const (
+		flapping host.Name = "flapping.default.svc.cluster.local"
+		stable   host.Name = "stable.default.svc.cluster.local"
+	)
+	now := time.Date(2019, 12, 1, 0, 0, 0, 0, time.UTC)
+	ec := newEndpointChurn("cluster1", 5*time.Minute, 3)
+
+	if got := ec.record(stable, churnEndpoints("10.0.0.1", "10.0.0.2"), now); got != 2 {
+		t.Errorf("record() = %d changes for the new endpoints, want 2", got)
+	}
+	if got := ec.record(stable, churnEndpoints("10.0.0.2", "10.0.0.1"), now); got != 0 {
+		t.Errorf("record() = %d changes for the same endpoints, want 0", got)
+	}
+
+	ec.record(flapping, churnEndpoints("10.0.1.1"), now)
+	ec.record(flapping, churnEndpoints("10.0.1.2"), now.Add(time.Minute))
+	ec.record(flapping, nil, now.Add(2*time.Minute))
+	if !ec.warned[flapping].Equal(now.Add(time.Minute)) {
+		t.Errorf("the flapping service should be warned about once it reached the threshold, got %v", ec.warned[flapping])
+	}
+
+	want := []ServiceChurn{{Hostname: flapping, Changes: 4}, {Hostname: stable, Changes: 2}}
+	if got := ec.top(0, now.Add(2*time.Minute)); !reflect.DeepEqual(got, want) {
+		t.Errorf("top() = %v, want %v", got, want)
+	}
+	if got := ec.top(1, now.Add(2*time.Minute)); !reflect.DeepEqual(got, want[:1]) {
+		t.Errorf("top(1) = %v, want %v", got, want[:1])
+	}
+
+	// the changes older than the window are forgotten.
+	want = []ServiceChurn{{Hostname: flapping, Changes: 3}}
+	if got := ec.top(0, now.Add(6*time.Minute)); !reflect.DeepEqual(got, want) {
+		t.Errorf("top() = %v after the window, want %v", got, want)
+	}
+	if got := ec.changes(stable, now.Add(6*time.Minute)); got != 0 {
+		t.Errorf("changes() = %d after the window, want 0", got)
+	}
+
+	// the tracking is disabled without window.
+	var disabled *endpointChurn
+	if disabled = newEndpointChurn("cluster1", 0, 3); disabled != nil {
+		t.Fatal("newEndpointChurn() should disable the tracking without window")
+	}
+	if got := disabled.record(stable, churnEndpoints("10.0.0.1"), now); got != 0 {
+		t.Errorf("record() = %d changes with the tracking disabled, want 0", got)
+	}
+	if got := disabled.top(0, now); len(got) != 0 {
+		t.Errorf("top() = %v with the tracking disabled, want none", got)
+	}
+}
+
+// reportedChurn returns the churn of the services of the cluster in the pilot_k8s_top_endpoint_churn metric.
+func reportedChurn(t *testing.T, clusterID string) map[string]float64 {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 100)
+	topEndpointChurn.Collect(ch)
+	close(ch)
+	out := map[string]float64{}
+	for m := range ch {
+		metric := &dto.Metric{}
+		if err := m.Write(metric); err != nil {
+			t.Fatal(err)
+		}
+		labels := map[string]string{}
+		for _, l := range metric.Label {
+			labels[l.GetName()] = l.GetValue()
+		}
+		if labels["cluster_id"] == clusterID {
+			out[labels["service"]] = metric.Gauge.GetValue()
+		}
+	}
+	return out
+}
+
+func TestEndpointChurnReport(t *testing.T) {
+	now := time.Date(2019, 12, 1, 0, 0, 0, 0, time.UTC)
+	ec := newEndpointChurn("report", 5*time.Minute, 0)
+	ec.record("a.default.svc.cluster.local", churnEndpoints("10.0.0.1", "10.0.0.2"), now)
+	ec.record("b.default.svc.cluster.local", churnEndpoints("10.0.1.1"), now.Add(3*time.Minute))
+
+	ec.report(now.Add(4 * time.Minute))
+	want := map[string]float64{"a.default.svc.cluster.local": 2, "b.default.svc.cluster.local": 1}
+	if got := reportedChurn(t, "report"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got reported churn %v, want %v", got, want)
+	}
+
+	// the series of the services dropping out of the top are removed.
+	ec.report(now.Add(6 * time.Minute))
+	want = map[string]float64{"b.default.svc.cluster.local": 1}
+	if got := reportedChurn(t, "report"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got reported churn %v after the window, want %v", got, want)
+	}
+}
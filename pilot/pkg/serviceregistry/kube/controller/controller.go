@@ -15,12 +15,12 @@
 package controller
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
 	"reflect"
 	"sort"
-	"strconv"
 	"sync"
 	"time"
 
@@ -30,7 +30,6 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
-	listerv1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
@@ -42,9 +41,9 @@ import (
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/serviceregistry/kube"
 	"istio.io/istio/pkg/config/host"
-	configKube "istio.io/istio/pkg/config/kube"
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/schemas"
+	"istio.io/istio/pkg/spiffe"
 )
 
 const (
@@ -77,21 +76,26 @@ var (
 	k8sEvents = monitoring.NewSum(
 		"pilot_k8s_reg_events",
 		"Events from k8s registry.",
-		monitoring.WithLabels(typeTag, eventTag),
+		monitoring.WithLabels(clusterIDTag, typeTag, eventTag),
 	)
 
 	endpointsWithNoPods = monitoring.NewSum(
 		"pilot_k8s_endpoints_with_no_pods",
 		"Endpoints that does not have any corresponding pods.")
+
+	stalePodIPEvictions = monitoring.NewSum(
+		"pilot_k8s_stale_pod_ip_evictions",
+		"Stale pod IP mappings evicted from the pod cache by reconciliation.")
 )
 
 func init() {
 	monitoring.MustRegister(k8sEvents)
 	monitoring.MustRegister(endpointsWithNoPods)
+	monitoring.MustRegister(stalePodIPEvictions)
 }
 
-func incrementEvent(kind, event string) {
-	k8sEvents.With(typeTag.Value(kind), eventTag.Value(event)).Increment()
+func incrementEvent(clusterID, kind, event string) {
+	k8sEvents.With(clusterIDTag.Value(clusterID), typeTag.Value(kind), eventTag.Value(event)).Increment()
 }
 
 // Options stores the configurable attributes of a Controller.
@@ -104,11 +108,23 @@ type Options struct {
 	// ClusterID identifies the remote cluster in a multicluster env.
 	ClusterID string
 
+	// Network is the network of all the endpoints of the registry, overriding the MeshNetworks.
+	Network string
+
 	// XDSUpdater will push changes to the xDS server.
 	XDSUpdater model.XDSUpdater
 
 	// TrustDomain used in SPIFFE identity
 	TrustDomain string
+
+	// RecordEvents records Kubernetes Events on the Services and Pods with registry anomalies. Only set
+	// for the local cluster, as the service accounts of the remote clusters can't create Events.
+	RecordEvents bool
+
+	// InformerFactory creates the informers of the registry if set, to share them with the other
+	// controllers of the cluster, e.g. the Services read by the Ingress controller. It must watch the
+	// WatchedNamespace with the client of the registry, which runs the shared informers.
+	InformerFactory informers.SharedInformerFactory
 }
 
 // Controller is a collection of synchronized resource watchers
@@ -122,6 +138,9 @@ type Controller struct {
 	endpoints cacheHandler
 	nodes     cacheHandler
 
+	// serviceIndex indexes the services by the labels of their selectors.
+	serviceIndex *selectorIndex
+
 	pods *PodCache
 
 	// Env is set by server to point to the environment, to allow the controller to
@@ -141,12 +160,42 @@ type Controller struct {
 	servicesMap map[host.Name]*model.Service
 	// externalNameSvcInstanceMap stores hostname ==> instance, is used to store instances for ExternalName k8s services
 	externalNameSvcInstanceMap map[host.Name][]*model.ServiceInstance
+	// externalNames stores the ExternalName services whose external name is resolved in the registry, by hostname
+	externalNames map[host.Name]*externalName
+	// lookupHost resolves the external names, net.DefaultResolver.LookupHost unless overridden by the tests
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	// excludedServices stores the hostnames of services excluded from the mesh with kube.ServiceExportedAnnotation
+	excludedServices map[host.Name]struct{}
+	// topologyAwareServices stores the hostnames of services with kube.TopologyAwareHintsAnnotation enabled
+	topologyAwareServices map[host.Name]struct{}
+	// networkGateways stores the network gateways of the services labeled with kube.NetworkLabel, by hostname
+	networkGateways map[host.Name][]*model.NetworkGateway
+	// trafficIntermediaries stores the intermediaries of the services annotated with
+	// kube.TrafficViaAnnotation, by hostname
+	trafficIntermediaries map[host.Name]*kube.TrafficIntermediary
+	// serviceUpdateTimes and endpointsUpdateTimes store the time of the last service and endpoints
+	// event processed for a hostname, exposed for debugging.
+	serviceUpdateTimes   map[host.Name]time.Time
+	endpointsUpdateTimes map[host.Name]time.Time
+
+	// churn counts the endpoint addresses added to and removed from each service.
+	churn *endpointChurn
+
+	// networkMutex protects ranger and networkForRegistry, which are reloaded with the MeshNetworks
+	networkMutex sync.RWMutex
 
 	// CIDR ranger based on path-compressed prefix trie
 	ranger cidranger.Ranger
 
 	// Network name for the registry as specified by the MeshNetworks configmap
 	networkForRegistry string
+
+	// network is the network of the registry set with the options, which takes precedence over
+	// networkForRegistry
+	network string
+
+	// events records the registry anomalies on the Services and Pods, nil if disabled.
+	events *registryEvents
 }
 
 type cacheHandler struct {
@@ -166,15 +215,34 @@ func NewController(client kubernetes.Interface, options Options) *Controller {
 		client:                     client,
 		queue:                      kube.NewQueue(1 * time.Second),
 		ClusterID:                  options.ClusterID,
+		network:                    options.Network,
 		XDSUpdater:                 options.XDSUpdater,
 		servicesMap:                make(map[host.Name]*model.Service),
 		externalNameSvcInstanceMap: make(map[host.Name][]*model.ServiceInstance),
+		externalNames:              make(map[host.Name]*externalName),
+		lookupHost:                 net.DefaultResolver.LookupHost,
+		excludedServices:           make(map[host.Name]struct{}),
+		topologyAwareServices:      make(map[host.Name]struct{}),
+		networkGateways:            make(map[host.Name][]*model.NetworkGateway),
+		trafficIntermediaries:      make(map[host.Name]*kube.TrafficIntermediary),
+		serviceUpdateTimes:         make(map[host.Name]time.Time),
+		endpointsUpdateTimes:       make(map[host.Name]time.Time),
+		serviceIndex:               newSelectorIndex(),
+		churn:                      newEndpointChurn(options.ClusterID, features.EndpointChurnWindow, features.EndpointChurnWarnThreshold),
+	}
+
+	if options.RecordEvents {
+		out.events = newRegistryEvents(client, options.ClusterID)
 	}
 
-	sharedInformers := informers.NewSharedInformerFactoryWithOptions(client, options.ResyncPeriod, informers.WithNamespace(options.WatchedNamespace))
+	sharedInformers := options.InformerFactory
+	if sharedInformers == nil {
+		sharedInformers = informers.NewSharedInformerFactoryWithOptions(client, options.ResyncPeriod, informers.WithNamespace(options.WatchedNamespace))
+	}
 
 	svcInformer := sharedInformers.Core().V1().Services().Informer()
 	out.services = out.createCacheHandler(svcInformer, "Services")
+	svcInformer.AddEventHandler(out.serviceIndex.eventHandler())
 
 	epInformer := sharedInformers.Core().V1().Endpoints().Informer()
 	out.endpoints = out.createEDSCacheHandler(epInformer, "Endpoints")
@@ -182,7 +250,7 @@ func NewController(client kubernetes.Interface, options Options) *Controller {
 	nodeInformer := sharedInformers.Core().V1().Nodes().Informer()
 	out.nodes = out.createCacheHandler(nodeInformer, "Nodes")
 
-	podInformer := sharedInformers.Core().V1().Pods().Informer()
+	podInformer := newPodInformer(client, options.WatchedNamespace, options.ResyncPeriod)
 	out.pods = newPodCache(out.createCacheHandler(podInformer, "Pod"), out)
 
 	return out
@@ -210,19 +278,19 @@ func (c *Controller) createCacheHandler(informer cache.SharedIndexInformer, otyp
 		cache.ResourceEventHandlerFuncs{
 			// TODO: filtering functions to skip over un-referenced resources (perf)
 			AddFunc: func(obj interface{}) {
-				incrementEvent(otype, "add")
+				incrementEvent(c.ClusterID, otype, "add")
 				c.queue.Push(kube.Task{Handler: handler.Apply, Obj: obj, Event: model.EventAdd})
 			},
 			UpdateFunc: func(old, cur interface{}) {
 				if !reflect.DeepEqual(old, cur) {
-					incrementEvent(otype, "update")
+					incrementEvent(c.ClusterID, otype, "update")
 					c.queue.Push(kube.Task{Handler: handler.Apply, Obj: cur, Event: model.EventUpdate})
 				} else {
-					incrementEvent(otype, "updatesame")
+					incrementEvent(c.ClusterID, otype, "updatesame")
 				}
 			},
 			DeleteFunc: func(obj interface{}) {
-				incrementEvent(otype, "delete")
+				incrementEvent(c.ClusterID, otype, "delete")
 				c.queue.Push(kube.Task{Handler: handler.Apply, Obj: obj, Event: model.EventDelete})
 			},
 		})
@@ -231,7 +299,8 @@ func (c *Controller) createCacheHandler(informer cache.SharedIndexInformer, otyp
 }
 
 // compareEndpoints returns true if the two endpoints are the same in aspects Pilot cares about
-// This currently means only looking at "Ready" endpoints
+// This means looking at both "Ready" and "NotReady" endpoints, since the not ready endpoints of
+// services publishing them and the terminating endpoints are pushed too.
 func compareEndpoints(a, b *v1.Endpoints) bool {
 	if len(a.Subsets) != len(b.Subsets) {
 		return false
@@ -243,6 +312,9 @@ func compareEndpoints(a, b *v1.Endpoints) bool {
 		if !reflect.DeepEqual(a.Subsets[i].Addresses, b.Subsets[i].Addresses) {
 			return false
 		}
+		if !reflect.DeepEqual(a.Subsets[i].NotReadyAddresses, b.Subsets[i].NotReadyAddresses) {
+			return false
+		}
 	}
 	return true
 }
@@ -254,7 +326,7 @@ func (c *Controller) createEDSCacheHandler(informer cache.SharedIndexInformer, o
 		cache.ResourceEventHandlerFuncs{
 			// TODO: filtering functions to skip over un-referenced resources (perf)
 			AddFunc: func(obj interface{}) {
-				incrementEvent(otype, "add")
+				incrementEvent(c.ClusterID, otype, "add")
 				c.queue.Push(kube.Task{Handler: handler.Apply, Obj: obj, Event: model.EventAdd})
 			},
 			UpdateFunc: func(old, cur interface{}) {
@@ -263,14 +335,14 @@ func (c *Controller) createEDSCacheHandler(informer cache.SharedIndexInformer, o
 				curE := cur.(*v1.Endpoints)
 
 				if !compareEndpoints(oldE, curE) {
-					incrementEvent(otype, "update")
+					incrementEvent(c.ClusterID, otype, "update")
 					c.queue.Push(kube.Task{Handler: handler.Apply, Obj: cur, Event: model.EventUpdate})
 				} else {
-					incrementEvent(otype, "updatesame")
+					incrementEvent(c.ClusterID, otype, "updatesame")
 				}
 			},
 			DeleteFunc: func(obj interface{}) {
-				incrementEvent(otype, "delete")
+				incrementEvent(c.ClusterID, otype, "delete")
 				// Deleting the endpoints results in an empty set from EDS perspective - only
 				// deleting the service should delete the resources. The full sync replaces the
 				// maps.
@@ -300,6 +372,7 @@ func (c *Controller) Run(stop <-chan struct{}) {
 		c.queue.Run(stop)
 	}()
 
+	go c.events.run(stop)
 	go c.services.informer.Run(stop)
 	go c.pods.informer.Run(stop)
 	go c.nodes.informer.Run(stop)
@@ -310,6 +383,22 @@ func (c *Controller) Run(stop <-chan struct{}) {
 
 	go c.endpoints.informer.Run(stop)
 
+	if features.PodCacheReconcileInterval > 0 {
+		go c.pods.reconcileLoop(stop, features.PodCacheReconcileInterval)
+	}
+	if features.OrphanedEndpointsCheckInterval > 0 {
+		go c.checkOrphansLoop(stop, features.OrphanedEndpointsCheckInterval)
+	}
+	if features.ExcludedPodsCheckInterval > 0 {
+		go c.checkExcludedPodsLoop(stop, features.ExcludedPodsCheckInterval)
+	}
+	if features.ExternalNameResolveInterval > 0 {
+		go c.resolveExternalNamesLoop(stop, features.ExternalNameResolveInterval)
+	}
+	if c.churn != nil {
+		go c.churn.reportLoop(stop, endpointChurnReportInterval)
+	}
+
 	<-stop
 	log.Infof("Controller terminated")
 }
@@ -343,16 +432,29 @@ func (c *Controller) GetService(hostname host.Name) (*model.Service, error) {
 
 // GetPodLocality retrieves the locality for a pod.
 func (c *Controller) GetPodLocality(pod *v1.Pod) string {
+	return c.getLocality(podReference(pod.Name, pod.Namespace, string(pod.UID)), pod.Labels, pod.Spec.NodeName)
+}
+
+// getPodInfoLocality retrieves the locality for a cached pod.
+func (c *Controller) getPodInfoLocality(pod *podInfo) string {
+	return c.getLocality(podReference(pod.name, pod.namespace, pod.uid), pod.labels, pod.nodeName)
+}
+
+func (c *Controller) getLocality(pod *v1.ObjectReference, podLabels map[string]string, nodeName string) string {
 	// if pod has `istio-locality` label, skip below ops
-	if len(pod.Labels[model.LocalityLabel]) > 0 {
-		return model.GetLocalityOrDefault(pod.Labels[model.LocalityLabel], "")
+	if len(podLabels[model.LocalityLabel]) > 0 {
+		return model.GetLocalityOrDefault(podLabels[model.LocalityLabel], "")
 	}
 
 	// NodeName is set by the scheduler after the pod is created
 	// https://github.com/kubernetes/community/blob/master/contributors/devel/api-conventions.md#late-initialization
-	node, exists, err := c.nodes.informer.GetStore().GetByKey(pod.Spec.NodeName)
+	node, exists, err := c.nodes.informer.GetStore().GetByKey(nodeName)
 	if !exists || err != nil {
-		log.Warnf("unable to get node %q for pod %q: %v", pod.Spec.NodeName, pod.Name, err)
+		log.Warnf("unable to get node %q for pod %q: %v", nodeName, pod.Name, err)
+		// The node of a pending pod is not scheduled yet, which is not an anomaly.
+		if nodeName != "" {
+			c.events.warn(pod, EventReasonNodeNotFound, "Node %q of the pod not found, the locality of its endpoints is unknown", nodeName)
+		}
 		return ""
 	}
 
@@ -372,15 +474,7 @@ func (c *Controller) ManagementPorts(addr string) model.PortList {
 	if pod == nil {
 		return nil
 	}
-
-	managementPorts, err := kube.ConvertProbesToPorts(&pod.Spec)
-	if err != nil {
-		log.Infof("Error while parsing liveliness and readiness probe ports for %s => %v", addr, err)
-	}
-
-	// We continue despite the error because healthCheckPorts could return a partial
-	// list of management ports
-	return managementPorts
+	return pod.managementPorts
 }
 
 // WorkloadHealthCheckInfo implements a service catalog operation
@@ -389,57 +483,7 @@ func (c *Controller) WorkloadHealthCheckInfo(addr string) model.ProbeList {
 	if pod == nil {
 		return nil
 	}
-
-	probes := make([]*model.Probe, 0)
-
-	// Obtain probes from the readiness and liveness probes
-	for _, container := range pod.Spec.Containers {
-		if container.ReadinessProbe != nil && container.ReadinessProbe.Handler.HTTPGet != nil {
-			p, err := kube.ConvertProbePort(&container, &container.ReadinessProbe.Handler)
-			if err != nil {
-				log.Infof("Error while parsing readiness probe port =%v", err)
-			}
-			probes = append(probes, &model.Probe{
-				Port: p,
-				Path: container.ReadinessProbe.Handler.HTTPGet.Path,
-			})
-		}
-		if container.LivenessProbe != nil && container.LivenessProbe.Handler.HTTPGet != nil {
-			p, err := kube.ConvertProbePort(&container, &container.LivenessProbe.Handler)
-			if err != nil {
-				log.Infof("Error while parsing liveness probe port =%v", err)
-			}
-			probes = append(probes, &model.Probe{
-				Port: p,
-				Path: container.LivenessProbe.Handler.HTTPGet.Path,
-			})
-		}
-	}
-
-	// Obtain probe from prometheus scrape
-	if scrape := pod.Annotations[PrometheusScrape]; scrape == "true" {
-		var port *model.Port
-		path := PrometheusPathDefault
-		if portstr := pod.Annotations[PrometheusPort]; portstr != "" {
-			portnum, err := strconv.Atoi(portstr)
-			if err != nil {
-				log.Warna(err)
-			} else {
-				port = &model.Port{
-					Port: portnum,
-				}
-			}
-		}
-		if pod.Annotations[PrometheusPath] != "" {
-			path = pod.Annotations[PrometheusPath]
-		}
-		probes = append(probes, &model.Probe{
-			Port: port,
-			Path: path,
-		})
-	}
-
-	return probes
+	return pod.probes
 }
 
 // InstancesByPort implements a service catalog operation
@@ -482,7 +526,7 @@ func (c *Controller) InstancesByPort(svc *model.Service, reqSvcPort int,
 			var podLabels labels.Instance
 			pod := c.pods.getPodByIP(ea.IP)
 			if pod != nil {
-				podLabels = configKube.ConvertLabels(pod.ObjectMeta)
+				podLabels = pod.labels
 			}
 			// check that one of the input labels is a subset of the labels
 			if !labelsList.HasSubsetOf(podLabels) {
@@ -491,13 +535,13 @@ func (c *Controller) InstancesByPort(svc *model.Service, reqSvcPort int,
 
 			az, sa, uid := "", "", ""
 			if pod != nil {
-				az = c.GetPodLocality(pod)
-				sa = kube.SecureNamingSAN(pod)
+				az = c.getPodInfoLocality(pod)
+				sa = pod.serviceAccount
 				if mixerEnabled {
-					uid = fmt.Sprintf("kubernetes://%s.%s", pod.Name, pod.Namespace)
+					uid = fmt.Sprintf("kubernetes://%s.%s", pod.name, pod.namespace)
 				}
 			}
-			tlsMode := kube.PodTLSMode(pod)
+			tlsMode := podTLSMode(pod)
 
 			// identify the port by name. K8S EndpointPort uses the service port name
 			for _, port := range ss.Ports {
@@ -543,11 +587,10 @@ func (c *Controller) GetProxyServiceInstances(proxy *model.Proxy) ([]*model.Serv
 				return out, nil
 			}
 
-			proxyNamespace = pod.Namespace
+			proxyNamespace = pod.namespace
 			// 1. find proxy service by label selector, if not any, there may exist headless service
 			// failover to 3
-			svcLister := listerv1.NewServiceLister(c.services.informer.GetIndexer())
-			if services, err := svcLister.GetPodServices(pod); err == nil && len(services) > 0 {
+			if services := c.serviceIndex.getPodServices(pod.selectorPod()); len(services) > 0 {
 				for _, svc := range services {
 					out = append(out, c.getProxyServiceInstancesByPod(pod, svc, proxy)...)
 				}
@@ -621,18 +664,16 @@ func (c *Controller) getProxyServiceInstancesFromMetadata(proxy *model.Proxy) ([
 	}
 
 	// Find the Service associated with the pod.
-	svcLister := listerv1.NewServiceLister(c.services.informer.GetIndexer())
-	services, err := svcLister.GetPodServices(dummyPod)
-	if err != nil {
-		return nil, fmt.Errorf("error getting instances: %v", err)
-
-	}
+	services := c.serviceIndex.getPodServices(dummyPod)
 	if len(services) == 0 {
-		return nil, fmt.Errorf("no instances found: %v ", err)
+		return nil, fmt.Errorf("no instances found")
 	}
 
 	out := make([]*model.ServiceInstance, 0)
 	for _, svc := range services {
+		if !kube.IsServiceExported(svc) {
+			continue
+		}
 		svcAccount := proxy.Metadata.ServiceAccount
 		hostname := kube.ServiceHostname(svc.Name, svc.Namespace, c.domainSuffix)
 		c.RLock()
@@ -646,9 +687,15 @@ func (c *Controller) getProxyServiceInstancesFromMetadata(proxy *model.Proxy) ([
 			if !f {
 				return nil, fmt.Errorf("failed to get svc port for %v", port.Name)
 			}
-			targetPort, err := findPortFromMetadata(port, proxy.Metadata.PodPorts)
+			targetPort, err := findPortFromMetadata(port, proxy.Metadata.PodPorts, proxy.Metadata.PodPortMap)
 			if err != nil {
-				return nil, fmt.Errorf("failed to find target port for %v: %v", proxy.ID, err)
+				// The metadata of older proxies may lack the pod ports. Resolve named ports
+				// from the pods of the same workload instead.
+				var f bool
+				targetPort, f = c.pods.findNamedPort(proxy.ConfigNamespace, proxy.WorkloadLabels[0], port.TargetPort.StrVal)
+				if !f {
+					return nil, fmt.Errorf("failed to find target port for %v: %v", proxy.ID, err)
+				}
 			}
 			// Construct the ServiceInstance
 			out = append(out, &model.ServiceInstance{
@@ -669,8 +716,9 @@ func (c *Controller) getProxyServiceInstancesFromMetadata(proxy *model.Proxy) ([
 	return out, nil
 }
 
-// findPortFromMetadata resolves the TargetPort of a Service Port, by reading the Pod spec.
-func findPortFromMetadata(svcPort v1.ServicePort, podPorts []model.PodPort) (int, error) {
+// findPortFromMetadata resolves the TargetPort of a Service Port, by reading the Pod spec, or the
+// container port map of the Pod.
+func findPortFromMetadata(svcPort v1.ServicePort, podPorts []model.PodPort, podPortMap model.PodPortMap) (int, error) {
 	target := svcPort.TargetPort
 
 	switch target.Type {
@@ -681,6 +729,9 @@ func findPortFromMetadata(svcPort v1.ServicePort, podPorts []model.PodPort) (int
 				return port.ContainerPort, nil
 			}
 		}
+		if port, f := podPortMap[name]; f {
+			return port, nil
+		}
 	case intstr.Int:
 		// For a direct reference we can just return the port number
 		return target.IntValue(), nil
@@ -727,7 +778,7 @@ func (c *Controller) getProxyServiceInstancesByEndpoint(endpoints v1.Endpoints,
 	return out
 }
 
-func (c *Controller) getProxyServiceInstancesByPod(pod *v1.Pod, service *v1.Service, proxy *model.Proxy) []*model.ServiceInstance {
+func (c *Controller) getProxyServiceInstancesByPod(pod *podInfo, service *v1.Service, proxy *model.Proxy) []*model.ServiceInstance {
 	out := make([]*model.ServiceInstance, 0)
 
 	hostname := kube.ServiceHostname(service.Name, service.Namespace, c.domainSuffix)
@@ -745,9 +796,11 @@ func (c *Controller) getProxyServiceInstancesByPod(pod *v1.Pod, service *v1.Serv
 			continue
 		}
 		// find target port
-		portNum, err := FindPort(pod, &port)
+		portNum, err := pod.findPort(&port)
 		if err != nil {
 			log.Warnf("Failed to find port for service %s/%s: %v", service.Namespace, service.Name, err)
+			c.events.warn(podReference(pod.name, pod.namespace, pod.uid), EventReasonTargetPortNotFound,
+				"Target port of the port %q of the service %s/%s not found: %v", port.Name, service.Namespace, service.Name, err)
 			continue
 		}
 
@@ -768,18 +821,19 @@ func (c *Controller) GetProxyWorkloadLabels(proxy *model.Proxy) (labels.Collecti
 
 	pod := c.pods.getPodByIP(proxyIP)
 	if pod != nil {
-		return labels.Collection{pod.Labels}, nil
+		return labels.Collection{pod.labels}, nil
 	}
 	return nil, nil
 }
 
 func (c *Controller) getEndpoints(podIP, address string, endpointPort int32, svcPort *model.Port, svc *model.Service) *model.ServiceInstance {
-	podLabels, _ := c.pods.labelsByIP(podIP)
+	var podLabels labels.Instance
 	pod := c.pods.getPodByIP(podIP)
 	az, sa := "", ""
 	if pod != nil {
-		az = c.GetPodLocality(pod)
-		sa = kube.SecureNamingSAN(pod)
+		podLabels = pod.labels
+		az = c.getPodInfoLocality(pod)
+		sa = pod.serviceAccount
 	}
 	return &model.ServiceInstance{
 		Endpoint: model.NetworkEndpoint{
@@ -792,14 +846,15 @@ func (c *Controller) getEndpoints(podIP, address string, endpointPort int32, svc
 		Service:        svc,
 		Labels:         podLabels,
 		ServiceAccount: sa,
-		TLSMode:        kube.PodTLSMode(pod),
+		TLSMode:        podTLSMode(pod),
 	}
 }
 
 // GetIstioServiceAccounts returns the Istio service accounts running a serivce
 // hostname. Each service account is encoded according to the SPIFFE VSID spec.
 // For example, a service account named "bar" in namespace "foo" is encoded as
-// "spiffe://cluster.local/ns/foo/sa/bar".
+// "spiffe://cluster.local/ns/foo/sa/bar". If trust domain aliases are configured
+// in the mesh config, the service account is also returned in each alias.
 func (c *Controller) GetIstioServiceAccounts(svc *model.Service, ports []int) []string {
 	saSet := make(map[string]bool)
 
@@ -831,11 +886,38 @@ func (c *Controller) GetIstioServiceAccounts(svc *model.Service, ports []int) []
 		saArray = append(saArray, sa)
 	}
 
+	// During a trust domain migration, peers may present identities from any of the trust domain aliases.
+	if c.Env != nil && c.Env.Mesh != nil {
+		saArray = spiffe.ExpandWithTrustDomains(saArray, c.Env.Mesh.TrustDomainAliases)
+	}
+
 	return saArray
 }
 
+// NetworkGateways implements model.NetworkGatewayDiscovery, returning the gateways of the
+// services labeled with kube.NetworkLabel, by network.
+func (c *Controller) NetworkGateways() map[string][]*model.NetworkGateway {
+	c.RLock()
+	defer c.RUnlock()
+
+	out := make(map[string][]*model.NetworkGateway)
+	for _, gateways := range c.networkGateways {
+		for _, gw := range gateways {
+			out[gw.Network] = append(out[gw.Network], gw)
+		}
+	}
+	for _, gateways := range out {
+		gws := gateways
+		sort.Slice(gws, func(i, j int) bool {
+			return gws[i].Addr < gws[j].Addr
+		})
+	}
+	return out
+}
+
 // AppendServiceHandler implements a service catalog operation
-func (c *Controller) AppendServiceHandler(f func(*model.Service, model.Event)) error {
+func (c *Controller) AppendServiceHandler(f func(*model.Service, model.Event), filters ...model.HandlerFilter) error {
+	f = model.FilterServiceHandler(f, filters...)
 	c.services.handler.Append(func(obj interface{}, event model.Event) error {
 		svc, ok := obj.(*v1.Service)
 		if !ok {
@@ -854,26 +936,89 @@ func (c *Controller) AppendServiceHandler(f func(*model.Service, model.Event)) e
 		log.Debugf("Handle event %s for service %s in namespace %s", event, svc.Name, svc.Namespace)
 
 		svcConv := kube.ConvertService(*svc, c.domainSuffix, c.ClusterID)
+		excluded := event != model.EventDelete && !kube.IsServiceExported(svc)
+		if excluded {
+			// A service excluded from the mesh is handled as if it was deleted.
+			log.Debugf("Service %s in namespace %s is excluded from the mesh", svc.Name, svc.Namespace)
+			event = model.EventDelete
+		}
+
 		switch event {
 		case model.EventDelete:
 			c.Lock()
 			delete(c.servicesMap, svcConv.Hostname)
 			delete(c.externalNameSvcInstanceMap, svcConv.Hostname)
+			delete(c.externalNames, svcConv.Hostname)
+			delete(c.serviceUpdateTimes, svcConv.Hostname)
+			delete(c.endpointsUpdateTimes, svcConv.Hostname)
+			delete(c.topologyAwareServices, svcConv.Hostname)
+			delete(c.networkGateways, svcConv.Hostname)
+			delete(c.trafficIntermediaries, svcConv.Hostname)
+			if excluded {
+				c.excludedServices[svcConv.Hostname] = struct{}{}
+			} else {
+				delete(c.excludedServices, svcConv.Hostname)
+			}
 			c.Unlock()
 			// EDS needs to just know when service is deleted.
 			c.XDSUpdater.SvcUpdate(c.ClusterID, svc.Name, svc.Namespace, event)
 		default:
-			// instance conversion is only required when service is added/updated.
-			instances := kube.ExternalNameServiceInstances(*svc, svcConv)
+			gateways := kube.NetworkGateways(svc, svcConv, c.ClusterID)
 			c.Lock()
+			// instance conversion is only required when service is added/updated. The external names
+			// resolved in the registry are resolved in the background.
+			instances, resolve := c.updateExternalNameLocked(svc, svcConv)
 			c.servicesMap[svcConv.Hostname] = svcConv
+			c.serviceUpdateTimes[svcConv.Hostname] = time.Now()
 			if instances == nil {
 				delete(c.externalNameSvcInstanceMap, svcConv.Hostname)
 			} else {
 				c.externalNameSvcInstanceMap[svcConv.Hostname] = instances
 			}
+			if len(gateways) == 0 {
+				delete(c.networkGateways, svcConv.Hostname)
+			} else {
+				c.networkGateways[svcConv.Hostname] = gateways
+			}
+			_, wasExcluded := c.excludedServices[svcConv.Hostname]
+			delete(c.excludedServices, svcConv.Hostname)
+			_, hadHints := c.topologyAwareServices[svcConv.Hostname]
+			hasHints := kube.TopologyAwareHintsEnabled(svc)
+			if hasHints {
+				c.topologyAwareServices[svcConv.Hostname] = struct{}{}
+			} else {
+				delete(c.topologyAwareServices, svcConv.Hostname)
+			}
+			hadVia := c.trafficIntermediaries[svcConv.Hostname]
+			via := kube.TrafficVia(svc)
+			if via != nil {
+				c.trafficIntermediaries[svcConv.Hostname] = via
+			} else {
+				delete(c.trafficIntermediaries, svcConv.Hostname)
+			}
+			viaChanged := !reflect.DeepEqual(hadVia, via)
 			c.Unlock()
 			c.XDSUpdater.SvcUpdate(c.ClusterID, svc.Name, svc.Namespace, event)
+
+			if resolve != nil {
+				go c.resolveExternalName(resolve)
+			} else if instances != nil && svcConv.Resolution == model.ClientSideLB {
+				// The ports of the service may have changed.
+				_ = c.XDSUpdater.EDSUpdate(c.ClusterID, string(svcConv.Hostname), svc.Namespace, externalNameEndpoints(instances))
+			}
+
+			// Endpoints of a service rejoining the mesh were dropped while it was excluded, and
+			// the zone hints and the intermediary of the endpoints depend on the service.
+			if wasExcluded || hadHints != hasHints || viaChanged {
+				item, exists, err := c.endpoints.informer.GetStore().GetByKey(kube.KeyFunc(svc.Name, svc.Namespace))
+				if err == nil && exists {
+					c.updateEDS(item.(*v1.Endpoints), model.EventUpdate)
+				} else if viaChanged {
+					// The endpoints of a service routed through an intermediary don't depend on its own endpoints.
+					c.updateEDS(&v1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: svc.Name, Namespace: svc.Namespace}},
+						model.EventUpdate)
+				}
+			}
 		}
 
 		f(svcConv, event)
@@ -884,7 +1029,8 @@ func (c *Controller) AppendServiceHandler(f func(*model.Service, model.Event)) e
 }
 
 // AppendInstanceHandler implements a service catalog operation
-func (c *Controller) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event)) error {
+func (c *Controller) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event), filters ...model.HandlerFilter) error {
+	f = model.FilterInstanceHandler(f, filters...)
 	if c.endpoints.handler == nil {
 		return nil
 	}
@@ -932,72 +1078,247 @@ func (c *Controller) AppendInstanceHandler(f func(*model.ServiceInstance, model.
 
 func (c *Controller) updateEDS(ep *v1.Endpoints, event model.Event) {
 	hostname := kube.ServiceHostname(ep.Name, ep.Namespace, c.domainSuffix)
-	mixerEnabled := c.Env != nil && c.Env.Mesh != nil && (c.Env.Mesh.MixerCheckServer != "" || c.Env.Mesh.MixerReportServer != "")
+
+	c.Lock()
+	_, excluded := c.excludedServices[hostname]
+	_, topologyAware := c.topologyAwareServices[hostname]
+	via := c.trafficIntermediaries[hostname]
+	if !excluded {
+		c.endpointsUpdateTimes[hostname] = time.Now()
+	}
+	c.Unlock()
+	if excluded {
+		log.Debugf("Skip EDS update for endpoint %s in namespace %s, service excluded from the mesh", ep.Name, ep.Namespace)
+		return
+	}
 
 	endpoints := make([]*model.IstioEndpoint, 0)
 	if event != model.EventDelete {
-		for _, ss := range ep.Subsets {
-			for _, ea := range ss.Addresses {
-				pod := c.pods.getPodByIP(ea.IP)
-				if pod == nil {
-					// This means, the endpoint event has arrived before pod event. This might happen because
-					// PodCache is eventually consistent. We should try to get the pod from kube-api server.
-					if ea.TargetRef != nil && ea.TargetRef.Kind == "Pod" {
-						pod = c.pods.getPod(ea.TargetRef.Name, ea.TargetRef.Namespace)
-						if pod == nil {
-							// If pod is still not availalable, this an unuusual case.
-							endpointsWithNoPods.Increment()
-							log.Errorf("Endpoint without pod %s %s.%s", ea.IP, ep.Name, ep.Namespace)
-							if c.Env != nil {
-								c.Env.PushContext.Add(model.EndpointNoPod, string(hostname), nil, ea.IP)
-							}
-							continue
+		if via != nil {
+			endpoints = c.intermediaryEndpoints(hostname, ep, via)
+		} else {
+			endpoints = c.buildEndpoints(hostname, ep, topologyAware)
+		}
+	}
+
+	if log.InfoEnabled() {
+		var addresses []string
+		for _, iep := range endpoints {
+			addresses = append(addresses, iep.Address)
+		}
+		log.Infof("Handle EDS endpoint %s in namespace %s -> %v", ep.Name, ep.Namespace, addresses)
+	}
+
+	if changes := c.churn.record(hostname, endpoints, time.Now()); changes > 0 {
+		endpointChanges.With(clusterIDTag.Value(c.ClusterID)).Record(float64(changes))
+	}
+
+	_ = c.XDSUpdater.EDSUpdate(c.ClusterID, string(hostname), ep.Namespace, endpoints)
+
+	// Intermediaries are not chained: only the services routed through a service which is not routed
+	// through an intermediary itself are updated.
+	if via == nil {
+		c.updateServicesVia(ep.Name, ep.Namespace)
+	}
+}
+
+// buildEndpoints converts the addresses of the endpoints of a service to Istio endpoints.
+func (c *Controller) buildEndpoints(hostname host.Name, ep *v1.Endpoints, topologyAware bool) []*model.IstioEndpoint {
+	mixerEnabled := c.Env != nil && c.Env.Mesh != nil && (c.Env.Mesh.MixerCheckServer != "" || c.Env.Mesh.MixerReportServer != "")
+
+	endpoints := make([]*model.IstioEndpoint, 0)
+	publishNotReady := c.publishNotReadyAddresses(ep)
+	for _, ss := range ep.Subsets {
+		for _, ea := range endpointAddresses(ss) {
+			// Terminating pods are no longer in the pod cache, they are read from the informer.
+			pod, draining := c.terminatingPod(ea.EndpointAddress)
+			if !ea.ready && !draining && !publishNotReady {
+				continue
+			}
+			healthStatus := model.Healthy
+			if draining {
+				healthStatus = model.Draining
+			} else {
+				pod = c.pods.getPodByIP(ea.IP)
+			}
+			if pod == nil {
+				// This means, the endpoint event has arrived before pod event. This might happen because
+				// PodCache is eventually consistent. We should try to get the pod from kube-api server.
+				if ea.TargetRef != nil && ea.TargetRef.Kind == "Pod" {
+					pod = c.pods.getPod(ea.TargetRef.Name, ea.TargetRef.Namespace)
+					if pod == nil {
+						// If pod is still not availalable, this an unuusual case.
+						endpointsWithNoPods.Increment()
+						log.Errorf("Endpoint without pod %s %s.%s", ea.IP, ep.Name, ep.Namespace)
+						c.events.warn(c.serviceReference(ep.Name, ep.Namespace), EventReasonEndpointWithoutPod,
+							"Pod %s/%s of the endpoint %s not found", ea.TargetRef.Namespace, ea.TargetRef.Name, ea.IP)
+						if c.Env != nil {
+							c.Env.PushContext.Add(model.EndpointNoPod, string(hostname), nil, ea.IP)
 						}
+						continue
 					}
 				}
+			}
 
-				var labels map[string]string
-				locality, sa, uid := "", "", ""
-				if pod != nil {
-					locality = c.GetPodLocality(pod)
-					sa = kube.SecureNamingSAN(pod)
-					if mixerEnabled {
-						uid = fmt.Sprintf("kubernetes://%s.%s", pod.Name, pod.Namespace)
-					}
-					labels = map[string]string(configKube.ConvertLabels(pod.ObjectMeta))
+			var labels map[string]string
+			locality, sa, uid, hintedZone := "", "", "", ""
+			if pod != nil {
+				locality = c.getPodInfoLocality(pod)
+				sa = pod.serviceAccount
+				if mixerEnabled {
+					uid = fmt.Sprintf("kubernetes://%s.%s", pod.name, pod.namespace)
 				}
-
-				tlsMode := kube.PodTLSMode(pod)
-
-				// EDS and ServiceEntry use name for service port - ADS will need to
-				// map to numbers.
-				for _, port := range ss.Ports {
-					endpoints = append(endpoints, &model.IstioEndpoint{
-						Address:         ea.IP,
-						EndpointPort:    uint32(port.Port),
-						ServicePortName: port.Name,
-						Labels:          labels,
-						UID:             uid,
-						ServiceAccount:  sa,
-						Network:         c.endpointNetwork(ea.IP),
-						Locality:        locality,
-						Attributes:      model.ServiceAttributes{Name: ep.Name, Namespace: ep.Namespace},
-						TLSMode:         tlsMode,
-					})
+				labels = map[string]string(pod.labels)
+				if topologyAware {
+					// Like the EndpointSlice controller, hint each endpoint for the zone it is in.
+					hintedZone = util.ConvertLocality(locality).GetZone()
 				}
 			}
+
+			tlsMode := podTLSMode(pod)
+
+			// EDS and ServiceEntry use name for service port - ADS will need to
+			// map to numbers.
+			for _, port := range ss.Ports {
+				endpoints = append(endpoints, &model.IstioEndpoint{
+					Address:         ea.IP,
+					EndpointPort:    uint32(port.Port),
+					ServicePortName: port.Name,
+					Labels:          labels,
+					UID:             uid,
+					ServiceAccount:  sa,
+					Network:         c.endpointNetwork(ea.IP),
+					Locality:        locality,
+					Attributes:      model.ServiceAttributes{Name: ep.Name, Namespace: ep.Namespace},
+					TLSMode:         tlsMode,
+					HintedZone:      hintedZone,
+					HealthStatus:    healthStatus,
+				})
+			}
 		}
 	}
+	return endpoints
+}
 
-	if log.InfoEnabled() {
-		var addresses []string
-		for _, iep := range endpoints {
-			addresses = append(addresses, iep.Address)
+// intermediaryEndpoints returns the endpoints of a service routed through an intermediary: the
+// addresses of the endpoints of the intermediary, on each port of the service.
+func (c *Controller) intermediaryEndpoints(hostname host.Name, ep *v1.Endpoints,
+	via *kube.TrafficIntermediary) []*model.IstioEndpoint {
+	endpoints := make([]*model.IstioEndpoint, 0)
+
+	c.RLock()
+	svc := c.servicesMap[hostname]
+	c.RUnlock()
+	if svc == nil {
+		return endpoints
+	}
+	item, exists, err := c.endpoints.informer.GetStore().GetByKey(kube.KeyFunc(via.Name, via.Namespace))
+	if err != nil || !exists {
+		log.Debugf("Intermediary %s/%s of service %s has no endpoints", via.Namespace, via.Name, hostname)
+		return endpoints
+	}
+
+	viaHostname := kube.ServiceHostname(via.Name, via.Namespace, c.domainSuffix)
+	seen := map[string]bool{}
+	for _, viaEp := range c.buildEndpoints(viaHostname, item.(*v1.Endpoints), false) {
+		if seen[viaEp.Address] {
+			continue
+		}
+		seen[viaEp.Address] = true
+		for _, port := range svc.Ports {
+			iep := *viaEp
+			iep.EndpointPort = uint32(port.Port)
+			if via.Port != 0 {
+				iep.EndpointPort = via.Port
+			}
+			iep.ServicePortName = port.Name
+			iep.Attributes = model.ServiceAttributes{Name: ep.Name, Namespace: ep.Namespace}
+			endpoints = append(endpoints, &iep)
 		}
-		log.Infof("Handle EDS endpoint %s in namespace %s -> %v", ep.Name, ep.Namespace, addresses)
 	}
+	return endpoints
+}
 
-	_ = c.XDSUpdater.EDSUpdate(c.ClusterID, string(hostname), ep.Namespace, endpoints)
+// updateServicesVia updates the endpoints of the services routed through the given service.
+func (c *Controller) updateServicesVia(name, namespace string) {
+	var routed []*v1.Endpoints
+	c.RLock()
+	for hostname, via := range c.trafficIntermediaries {
+		if via.Name != name || via.Namespace != namespace {
+			continue
+		}
+		if svc := c.servicesMap[hostname]; svc != nil {
+			routed = append(routed, &v1.Endpoints{ObjectMeta: metav1.ObjectMeta{
+				Name:      svc.Attributes.Name,
+				Namespace: svc.Attributes.Namespace,
+			}})
+		}
+	}
+	c.RUnlock()
+
+	for _, ep := range routed {
+		c.updateEDS(ep, model.EventUpdate)
+	}
+}
+
+// endpointAddress is an address of an endpoints subset, with its readiness.
+type endpointAddress struct {
+	v1.EndpointAddress
+	ready bool
+}
+
+// endpointAddresses returns the ready and not ready addresses of the subset.
+func endpointAddresses(ss v1.EndpointSubset) []endpointAddress {
+	out := make([]endpointAddress, 0, len(ss.Addresses)+len(ss.NotReadyAddresses))
+	for _, ea := range ss.Addresses {
+		out = append(out, endpointAddress{EndpointAddress: ea, ready: true})
+	}
+	for _, ea := range ss.NotReadyAddresses {
+		out = append(out, endpointAddress{EndpointAddress: ea})
+	}
+	return out
+}
+
+// publishNotReadyAddresses returns true if the service of the endpoints publishes the addresses
+// of its not ready pods, which then receive traffic like the ready ones.
+func (c *Controller) publishNotReadyAddresses(ep *v1.Endpoints) bool {
+	obj, exists, err := c.services.informer.GetIndexer().GetByKey(kube.KeyFunc(ep.Name, ep.Namespace))
+	if err != nil || !exists {
+		return false
+	}
+	return obj.(*v1.Service).Spec.PublishNotReadyAddresses
+}
+
+// terminatingPod returns the podInfo of the pod of the address and true if the pod is being
+// deleted, in which case its endpoints are drained.
+func (c *Controller) terminatingPod(ea v1.EndpointAddress) (*podInfo, bool) {
+	if ea.TargetRef == nil || ea.TargetRef.Kind != "Pod" {
+		return nil, false
+	}
+	obj, exists, err := c.pods.informer.GetStore().GetByKey(kube.KeyFunc(ea.TargetRef.Name, ea.TargetRef.Namespace))
+	if err != nil || !exists {
+		return nil, false
+	}
+	pod := obj.(*v1.Pod)
+	if pod.DeletionTimestamp == nil {
+		return nil, false
+	}
+	return newPodInfo(pod), true
+}
+
+// drainPodEndpoints re-evaluates the endpoints of the services selecting a terminating pod, so
+// that its endpoints are drained even when the endpoints object itself is not updated.
+func (c *Controller) drainPodEndpoints(pod *v1.Pod) {
+	for _, svc := range c.serviceIndex.getPodServices(pod) {
+		obj, exists, err := c.endpoints.informer.GetStore().GetByKey(kube.KeyFunc(svc.Name, svc.Namespace))
+		if err != nil || !exists {
+			continue
+		}
+		c.queue.Push(kube.NewTask(func(obj interface{}, event model.Event) error {
+			c.updateEDS(obj.(*v1.Endpoints), event)
+			return nil
+		}, obj, model.EventUpdate))
+	}
 }
 
 // namedRangerEntry for holding network's CIDR and name
@@ -1014,11 +1335,20 @@ func (n namedRangerEntry) Network() net.IPNet {
 // InitNetworkLookup will read the mesh networks configuration from the environment
 // and initialize CIDR rangers for an efficient network lookup when needed
 func (c *Controller) InitNetworkLookup(meshNetworks *meshconfig.MeshNetworks) {
+	var ranger cidranger.Ranger
+	networkForRegistry := c.network
+	defer func() {
+		c.networkMutex.Lock()
+		c.ranger = ranger
+		c.networkForRegistry = networkForRegistry
+		c.networkMutex.Unlock()
+	}()
+
 	if meshNetworks == nil || len(meshNetworks.Networks) == 0 {
 		return
 	}
 
-	c.ranger = cidranger.NewPCTrieRanger()
+	ranger = cidranger.NewPCTrieRanger()
 
 	for n, v := range meshNetworks.Networks {
 		for _, ep := range v.Endpoints {
@@ -1032,17 +1362,34 @@ func (c *Controller) InitNetworkLookup(meshNetworks *meshconfig.MeshNetworks) {
 					name:    n,
 					network: *network,
 				}
-				_ = c.ranger.Insert(rangerEntry)
+				_ = ranger.Insert(rangerEntry)
 			}
-			if ep.GetFromRegistry() != "" && ep.GetFromRegistry() == c.ClusterID {
-				c.networkForRegistry = n
+			if ep.GetFromRegistry() != "" && ep.GetFromRegistry() == c.ClusterID && c.network == "" {
+				networkForRegistry = n
 			}
 		}
 	}
 }
 
+// ReloadNetworkLookup reloads the mesh networks configuration, and re-evaluates the network of
+// all the endpoints of the registry so that the changes are pushed with EDS.
+func (c *Controller) ReloadNetworkLookup(meshNetworks *meshconfig.MeshNetworks) {
+	c.InitNetworkLookup(meshNetworks)
+
+	// The endpoints are updated from the queue, so that they are not raced by the informer events.
+	for _, obj := range c.endpoints.informer.GetStore().List() {
+		c.queue.Push(kube.NewTask(func(obj interface{}, event model.Event) error {
+			c.updateEDS(obj.(*v1.Endpoints), event)
+			return nil
+		}, obj, model.EventUpdate))
+	}
+}
+
 // return the mesh network for the endpoint IP. Empty string if not found.
 func (c *Controller) endpointNetwork(endpointIP string) string {
+	c.networkMutex.RLock()
+	defer c.networkMutex.RUnlock()
+
 	// If networkForRegistry is set then all endpoints discovered by this registry
 	// belong to the configured network so simply return it
 	if len(c.networkForRegistry) != 0 {
@@ -1075,20 +1422,26 @@ func (c *Controller) endpointNetwork(endpointIP string) string {
 // string up in all named ports in all containers in the target pod.  If no
 // match is found, fail.
 func FindPort(pod *v1.Pod, svcPort *v1.ServicePort) (int, error) {
+	var containerPorts []v1.ContainerPort
+	for _, container := range pod.Spec.Containers {
+		containerPorts = append(containerPorts, container.Ports...)
+	}
+	return findContainerPort(containerPorts, svcPort, string(pod.UID))
+}
+
+func findContainerPort(containerPorts []v1.ContainerPort, svcPort *v1.ServicePort, uid string) (int, error) {
 	portName := svcPort.TargetPort
 	switch portName.Type {
 	case intstr.String:
 		name := portName.StrVal
-		for _, container := range pod.Spec.Containers {
-			for _, port := range container.Ports {
-				if port.Name == name && port.Protocol == svcPort.Protocol {
-					return int(port.ContainerPort), nil
-				}
+		for _, port := range containerPorts {
+			if port.Name == name && port.Protocol == svcPort.Protocol {
+				return int(port.ContainerPort), nil
 			}
 		}
 	case intstr.Int:
 		return portName.IntValue(), nil
 	}
 
-	return 0, fmt.Errorf("no suitable port for manifest: %s", pod.UID)
+	return 0, fmt.Errorf("no suitable port for manifest: %s", uid)
 }
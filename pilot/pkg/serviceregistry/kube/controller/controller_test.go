@@ -96,6 +96,9 @@ type XdsEvent struct {
 
 	// The id of the event
 	ID string
+
+	// The endpoints associated with an EDS update, if any
+	Endpoints []*model.IstioEndpoint
 }
 
 // NewFakeXDS creates a XdsUpdater reporting events via a channel.
@@ -108,7 +111,7 @@ func NewFakeXDS() *FakeXdsUpdater {
 func (fx *FakeXdsUpdater) EDSUpdate(shard, hostname string, namespace string, entry []*model.IstioEndpoint) error {
 	if len(entry) > 0 {
 		select {
-		case fx.Events <- XdsEvent{Type: "eds", ID: hostname}:
+		case fx.Events <- XdsEvent{Type: "eds", ID: hostname, Endpoints: entry}:
 		default:
 		}
 
@@ -1198,6 +1201,22 @@ func TestCompareEndpoints(t *testing.T) {
 			&v1.Endpoints{Subsets: []v1.EndpointSubset{
 				{Addresses: []v1.EndpointAddress{addressA}},
 			}},
+			false,
+		},
+		{
+			"same not ready address",
+			&v1.Endpoints{Subsets: []v1.EndpointSubset{
+				{
+					NotReadyAddresses: []v1.EndpointAddress{addressB},
+					Addresses:         []v1.EndpointAddress{addressA},
+				},
+			}},
+			&v1.Endpoints{Subsets: []v1.EndpointSubset{
+				{
+					NotReadyAddresses: []v1.EndpointAddress{addressB},
+					Addresses:         []v1.EndpointAddress{addressA},
+				},
+			}},
 			true,
 		},
 		{
@@ -1500,6 +1519,245 @@ func addNodes(t *testing.T, controller *Controller, nodes ...*coreV1.Node) {
 	}
 }
 
+func TestTopologyAwareHints(t *testing.T) {
+	controller, fx := newFakeController(t)
+	defer controller.Stop()
+
+	addNodes(t, controller, generateNode("node1", map[string]string{NodeZoneLabel: "zone1", NodeRegionLabel: "region1"}))
+	pod1 := generatePod("128.0.0.1", "pod1", "nsA", "", "node1", map[string]string{"app": "prod-app"}, map[string]string{})
+	addPods(t, controller, pod1)
+	if err := waitForPod(controller, pod1.Status.PodIP); err != nil {
+		t.Fatalf("wait for pod err: %v", err)
+	}
+
+	createService(controller, "svc1", "nsA", map[string]string{kube.TopologyAwareHintsAnnotation: "Auto"},
+		[]int32{8080}, map[string]string{"app": "prod-app"}, t)
+	if ev := fx.Wait("service"); ev == nil {
+		t.Fatal("Timeout creating service")
+	}
+	createEndpoints(controller, "svc1", "nsA", []string{"tcp-port"}, []string{"128.0.0.1"}, t)
+	ev := fx.Wait("eds")
+	if ev == nil || len(ev.Endpoints) != 1 {
+		t.Fatalf("expected eds event with one endpoint, got %v", ev)
+	}
+	if zone := ev.Endpoints[0].HintedZone; zone != "zone1" {
+		t.Errorf("HintedZone => %q, want zone1", zone)
+	}
+
+	// Disabling the hints republishes the endpoints without hint.
+	svc, err := controller.client.CoreV1().Services("nsA").Get("svc1", metaV1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Annotations = nil
+	if _, err := controller.client.CoreV1().Services("nsA").Update(svc); err != nil {
+		t.Fatal(err)
+	}
+	ev = fx.Wait("eds")
+	if ev == nil || len(ev.Endpoints) != 1 {
+		t.Fatalf("expected eds event with one endpoint, got %v", ev)
+	}
+	if zone := ev.Endpoints[0].HintedZone; zone != "" {
+		t.Errorf("HintedZone => %q, want none", zone)
+	}
+}
+
+func TestTrafficViaIntermediary(t *testing.T) {
+	controller, fx := newFakeController(t)
+	defer controller.Stop()
+
+	gw := generatePod("128.0.0.2", "egressgateway", "istio-system", "", "node1", map[string]string{"app": "egressgateway"}, map[string]string{})
+	addPods(t, controller, gw)
+	if err := waitForPod(controller, gw.Status.PodIP); err != nil {
+		t.Fatalf("wait for pod err: %v", err)
+	}
+	createService(controller, "egressgateway", "istio-system", nil, []int32{8443}, map[string]string{"app": "egressgateway"}, t)
+	if ev := fx.Wait("service"); ev == nil {
+		t.Fatal("Timeout creating service")
+	}
+	createEndpoints(controller, "egressgateway", "istio-system", []string{"tcp-port"}, []string{"128.0.0.2"}, t)
+	if ev := fx.Wait("eds"); ev == nil {
+		t.Fatal("Timeout creating endpoints")
+	}
+
+	// waitForEDS returns the next EDS update of svc1, skipping the updates of the gateway.
+	svc1 := string(kube.ServiceHostname("svc1", "nsA", domainSuffix))
+	waitForEDS := func() *XdsEvent {
+		for {
+			ev := fx.Wait("eds")
+			if ev == nil || ev.ID == svc1 {
+				return ev
+			}
+		}
+	}
+
+	// The endpoints of the annotated service, which has none of its own, are the gateway endpoints.
+	createService(controller, "svc1", "nsA", map[string]string{kube.TrafficViaAnnotation: "egressgateway.istio-system:8443"},
+		[]int32{8080}, map[string]string{"app": "prod-app"}, t)
+	ev := waitForEDS()
+	if ev == nil || len(ev.Endpoints) != 1 {
+		t.Fatalf("expected eds event with one endpoint, got %v", ev)
+	}
+	ep := ev.Endpoints[0]
+	if ep.Address != "128.0.0.2" || ep.EndpointPort != 8443 || ep.ServicePortName != "tcp-port" {
+		t.Errorf("got endpoint %s:%d for port %s, want 128.0.0.2:8443 for port tcp-port",
+			ep.Address, ep.EndpointPort, ep.ServicePortName)
+	}
+	if ep.Attributes.Name != "svc1" || ep.Attributes.Namespace != "nsA" {
+		t.Errorf("got endpoint of service %s/%s, want nsA/svc1", ep.Attributes.Namespace, ep.Attributes.Name)
+	}
+
+	// The endpoints of the service follow the gateway endpoints.
+	updateEndpoints(controller, "egressgateway", "istio-system", []string{"tcp-port"}, []string{"128.0.0.2", "128.0.0.3"}, t)
+	ev = waitForEDS()
+	if ev == nil || len(ev.Endpoints) != 2 {
+		t.Fatalf("expected eds event with two endpoints, got %v", ev)
+	}
+}
+
+func TestReloadNetworkLookup(t *testing.T) {
+	controller, fx := newFakeController(t)
+	defer controller.Stop()
+
+	pod1 := generatePod("10.10.1.1", "pod1", "nsA", "", "node1", map[string]string{"app": "prod-app"}, map[string]string{})
+	addPods(t, controller, pod1)
+	if err := waitForPod(controller, pod1.Status.PodIP); err != nil {
+		t.Fatalf("wait for pod err: %v", err)
+	}
+
+	createService(controller, "svc1", "nsA", nil, []int32{8080}, map[string]string{"app": "prod-app"}, t)
+	if ev := fx.Wait("service"); ev == nil {
+		t.Fatal("Timeout creating service")
+	}
+	createEndpoints(controller, "svc1", "nsA", []string{"tcp-port"}, []string{"10.10.1.1"}, t)
+	ev := fx.Wait("eds")
+	if ev == nil || len(ev.Endpoints) != 1 {
+		t.Fatalf("expected eds event with one endpoint, got %v", ev)
+	}
+	if network := ev.Endpoints[0].Network; network != "" {
+		t.Errorf("Network => %q, want none", network)
+	}
+
+	// Adding the CIDR of the endpoint republishes the endpoint in its network.
+	controller.ReloadNetworkLookup(&meshconfig.MeshNetworks{
+		Networks: map[string]*meshconfig.Network{
+			"network1": {
+				Endpoints: []*meshconfig.Network_NetworkEndpoints{
+					{
+						Ne: &meshconfig.Network_NetworkEndpoints_FromCidr{
+							FromCidr: "10.10.1.1/24",
+						},
+					},
+				},
+			},
+		},
+	})
+	ev = fx.Wait("eds")
+	if ev == nil || len(ev.Endpoints) != 1 {
+		t.Fatalf("expected eds event with one endpoint, got %v", ev)
+	}
+	if network := ev.Endpoints[0].Network; network != "network1" {
+		t.Errorf("Network => %q, want network1", network)
+	}
+
+	// Removing the networks republishes the endpoint without network.
+	controller.ReloadNetworkLookup(nil)
+	ev = fx.Wait("eds")
+	if ev == nil || len(ev.Endpoints) != 1 {
+		t.Fatalf("expected eds event with one endpoint, got %v", ev)
+	}
+	if network := ev.Endpoints[0].Network; network != "" {
+		t.Errorf("Network => %q, want none", network)
+	}
+}
+
+func TestNotReadyAndTerminatingEndpoints(t *testing.T) {
+	controller, fx := newFakeController(t)
+	defer controller.Stop()
+
+	pod1 := generatePod("10.10.1.1", "pod1", "nsA", "", "node1", map[string]string{"app": "prod-app"}, map[string]string{})
+	pod2 := generatePod("10.10.1.2", "pod2", "nsA", "", "node1", map[string]string{"app": "prod-app"}, map[string]string{})
+	addPods(t, controller, pod1, pod2)
+	for _, pod := range []*coreV1.Pod{pod1, pod2} {
+		if err := waitForPod(controller, pod.Status.PodIP); err != nil {
+			t.Fatalf("wait for pod err: %v", err)
+		}
+	}
+
+	endpointsOf := func(name string, ready, notReady *coreV1.Pod) *coreV1.Endpoints {
+		address := func(pod *coreV1.Pod) []coreV1.EndpointAddress {
+			return []coreV1.EndpointAddress{{
+				IP:        pod.Status.PodIP,
+				TargetRef: &v1.ObjectReference{Kind: "Pod", Name: pod.Name, Namespace: pod.Namespace},
+			}}
+		}
+		return &coreV1.Endpoints{
+			ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: "nsA"},
+			Subsets: []coreV1.EndpointSubset{{
+				Addresses:         address(ready),
+				NotReadyAddresses: address(notReady),
+				Ports:             []coreV1.EndpointPort{{Name: "tcp-port", Port: 1001}},
+			}},
+		}
+	}
+	expectEndpoints := func(want map[string]model.HealthStatus) {
+		t.Helper()
+		ev := fx.Wait("eds")
+		if ev == nil {
+			t.Fatal("Timeout waiting for eds event")
+		}
+		got := make(map[string]model.HealthStatus)
+		for _, ep := range ev.Endpoints {
+			got[ep.Address] = ep.HealthStatus
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got endpoints %v, want %v", got, want)
+		}
+	}
+
+	// The not ready endpoints are ignored, unless the service publishes them.
+	createService(controller, "svc1", "nsA", nil, []int32{8080}, map[string]string{"app": "prod-app"}, t)
+	if ev := fx.Wait("service"); ev == nil {
+		t.Fatal("Timeout creating service")
+	}
+	if _, err := controller.client.CoreV1().Endpoints("nsA").Create(endpointsOf("svc1", pod1, pod2)); err != nil {
+		t.Fatal(err)
+	}
+	expectEndpoints(map[string]model.HealthStatus{"10.10.1.1": model.Healthy})
+
+	svc2 := &coreV1.Service{
+		ObjectMeta: metaV1.ObjectMeta{Name: "svc2", Namespace: "nsA"},
+		Spec: coreV1.ServiceSpec{
+			ClusterIP:                "10.0.0.2",
+			Ports:                    []coreV1.ServicePort{{Name: "tcp-port", Port: 8080}},
+			Selector:                 map[string]string{"app": "other-app"},
+			PublishNotReadyAddresses: true,
+		},
+	}
+	if _, err := controller.client.CoreV1().Services("nsA").Create(svc2); err != nil {
+		t.Fatal(err)
+	}
+	if ev := fx.Wait("service"); ev == nil {
+		t.Fatal("Timeout creating service")
+	}
+	if _, err := controller.client.CoreV1().Endpoints("nsA").Create(endpointsOf("svc2", pod1, pod2)); err != nil {
+		t.Fatal(err)
+	}
+	expectEndpoints(map[string]model.HealthStatus{"10.10.1.1": model.Healthy, "10.10.1.2": model.Healthy})
+
+	// A terminating pod is drained, even though its endpoints are not updated.
+	terminating, err := controller.client.CoreV1().Pods("nsA").Get("pod1", metaV1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := metaV1.Now()
+	terminating.DeletionTimestamp = &now
+	if _, err := controller.client.CoreV1().Pods("nsA").Update(terminating); err != nil {
+		t.Fatal(err)
+	}
+	expectEndpoints(map[string]model.HealthStatus{"10.10.1.1": model.Draining})
+}
+
 func TestEndpointUpdate(t *testing.T) {
 	controller, fx := newFakeController(t)
 	defer controller.Stop()
@@ -1601,3 +1859,122 @@ func TestEndpointUpdateBeforePodUpdate(t *testing.T) {
 		t.Errorf("Timeout incremental eds")
 	}
 }
+
+func TestExcludedService(t *testing.T) {
+	controller, fx := newFakeController(t)
+	defer controller.Stop()
+
+	pod1 := generatePod("128.0.0.1", "pod1", "nsa", "", "node1", map[string]string{"app": "prod-app"}, map[string]string{})
+	addPods(t, controller, pod1)
+	if err := waitForPod(controller, pod1.Status.PodIP); err != nil {
+		t.Fatalf("wait for pod err: %v", err)
+	}
+
+	createService(controller, "svc1", "nsa", map[string]string{kube.ServiceExportedAnnotation: "false"},
+		[]int32{8080}, map[string]string{"app": "prod-app"}, t)
+	if ev := fx.Wait("service"); ev == nil {
+		t.Fatal("Timeout creating service")
+	}
+	hostname := kube.ServiceHostname("svc1", "nsa", domainSuffix)
+	if svc, _ := controller.GetService(hostname); svc != nil {
+		t.Fatalf("excluded service %s found in registry", hostname)
+	}
+
+	createEndpoints(controller, "svc1", "nsa", []string{"tcp-port"}, []string{"128.0.0.1"}, t)
+	var ep *coreV1.Endpoints
+	test.Eventually(t, "endpoints synced", func() bool {
+		item, exists, _ := controller.endpoints.informer.GetStore().GetByKey(kube.KeyFunc("svc1", "nsa"))
+		if exists {
+			ep = item.(*coreV1.Endpoints)
+		}
+		return exists
+	})
+
+	// Drain pending events, then verify the endpoints of the excluded service are not published.
+	for len(fx.Events) > 0 {
+		<-fx.Events
+	}
+	controller.updateEDS(ep, model.EventUpdate)
+	select {
+	case ev := <-fx.Events:
+		t.Fatalf("unexpected event for excluded service: %v", ev)
+	default:
+	}
+
+	// Removing the annotation adds the service back, together with its endpoints.
+	svc, err := controller.client.CoreV1().Services("nsa").Get("svc1", metaV1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Annotations = nil
+	if _, err := controller.client.CoreV1().Services("nsa").Update(svc); err != nil {
+		t.Fatal(err)
+	}
+	if ev := fx.Wait("eds"); ev == nil || ev.ID != string(hostname) {
+		t.Fatalf("expected eds event for %s, got %v", hostname, ev)
+	}
+	if svc, _ := controller.GetService(hostname); svc == nil {
+		t.Fatalf("service %s not found in registry", hostname)
+	}
+}
+
+func TestEndpointNetworkOverride(t *testing.T) {
+	meshNetworks := &meshconfig.MeshNetworks{
+		Networks: map[string]*meshconfig.Network{
+			"network1": {
+				Endpoints: []*meshconfig.Network_NetworkEndpoints{
+					{
+						Ne: &meshconfig.Network_NetworkEndpoints_FromRegistry{
+							FromRegistry: "cluster1",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		network  string
+		expected string
+	}{
+		{name: "mesh networks", expected: "network1"},
+		{name: "override", network: "network2", expected: "network2"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Controller{ClusterID: "cluster1", network: tc.network}
+			c.InitNetworkLookup(meshNetworks)
+			if network := c.endpointNetwork("10.10.1.1"); network != tc.expected {
+				t.Errorf("got network %q, want %q", network, tc.expected)
+			}
+		})
+	}
+}
+
+func TestFindPortFromMetadata(t *testing.T) {
+	podPorts := []model.PodPort{{Name: "http", ContainerPort: 8080}, {ContainerPort: 9090}}
+	podPortMap := model.PodPortMap{"grpc": 7070, "http": 8081}
+	cases := []struct {
+		name       string
+		targetPort intstr.IntOrString
+		podPorts   []model.PodPort
+		podPortMap model.PodPortMap
+		want       int
+		wantErr    bool
+	}{
+		{"number", intstr.FromInt(9090), nil, nil, 9090, false},
+		{"name from pod ports", intstr.FromString("http"), podPorts, podPortMap, 8080, false},
+		{"name from pod port map", intstr.FromString("grpc"), podPorts, podPortMap, 7070, false},
+		{"name from pod port map only", intstr.FromString("http"), nil, podPortMap, 8081, false},
+		{"unknown name", intstr.FromString("tcp"), podPorts, podPortMap, 0, true},
+		{"no metadata", intstr.FromString("http"), nil, nil, 0, true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findPortFromMetadata(v1.ServicePort{TargetPort: tt.targetPort}, tt.podPorts, tt.podPortMap)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("findPortFromMetadata() => %d, %v, want %d (error: %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/kube"
+	"istio.io/istio/pkg/config/host"
+)
+
+// RegistryDump is a snapshot of the state of a Kubernetes registry, exposed for debugging.
+type RegistryDump struct {
+	ClusterID string                     `json:"clusterID"`
+	Services  map[host.Name]*ServiceDump `json:"services"`
+	Orphans   *Orphans                   `json:"orphans"`
+	// ExcludedPods are the numbers of pods excluded from the mesh, by reason.
+	ExcludedPods map[string]int `json:"excludedPods"`
+}
+
+// ServiceDump is the registry state of a single service.
+type ServiceDump struct {
+	Service               *model.Service           `json:"service"`
+	Endpoints             int                      `json:"endpoints"`
+	NotReadyEndpoints     int                      `json:"notReadyEndpoints"`
+	ExternalNameInstances []*model.ServiceInstance `json:"externalNameInstances,omitempty"`
+	LastServiceUpdate     *time.Time               `json:"lastServiceUpdate,omitempty"`
+	LastEndpointsUpdate   *time.Time               `json:"lastEndpointsUpdate,omitempty"`
+	EndpointChurn         int                      `json:"endpointChurn,omitempty"`
+}
+
+// DebugDump returns a snapshot of the registry, keyed by hostname.
+func (c *Controller) DebugDump() interface{} {
+	return c.dump()
+}
+
+func (c *Controller) dump() *RegistryDump {
+	out := &RegistryDump{
+		ClusterID: c.ClusterID,
+		Services:  make(map[host.Name]*ServiceDump),
+	}
+
+	now := time.Now()
+	c.RLock()
+	for hostname, svc := range c.servicesMap {
+		sd := &ServiceDump{
+			Service:               svc,
+			ExternalNameInstances: c.externalNameSvcInstanceMap[hostname],
+			EndpointChurn:         c.churn.changes(hostname, now),
+		}
+		if t, f := c.serviceUpdateTimes[hostname]; f {
+			t := t
+			sd.LastServiceUpdate = &t
+		}
+		if t, f := c.endpointsUpdateTimes[hostname]; f {
+			t := t
+			sd.LastEndpointsUpdate = &t
+		}
+		out.Services[hostname] = sd
+	}
+	c.RUnlock()
+
+	for _, sd := range out.Services {
+		key := kube.KeyFunc(sd.Service.Attributes.Name, sd.Service.Attributes.Namespace)
+		item, exists, err := c.endpoints.informer.GetStore().GetByKey(key)
+		if err != nil || !exists {
+			continue
+		}
+		for _, ss := range item.(*v1.Endpoints).Subsets {
+			sd.Endpoints += len(ss.Addresses)
+			sd.NotReadyEndpoints += len(ss.NotReadyAddresses)
+		}
+	}
+	out.Orphans = c.orphans()
+	out.ExcludedPods = c.excludedPods()
+
+	return out
+}
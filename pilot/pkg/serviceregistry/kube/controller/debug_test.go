@@ -0,0 +1,108 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"istio.io/istio/pilot/pkg/serviceregistry/kube"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func TestDebugDump(t *testing.T) {
+	controller, fx := newFakeController(t)
+	defer controller.Stop()
+	controller.ClusterID = "cluster1"
+
+	pods := []*coreV1.Pod{
+		generatePod("128.0.0.1", "pod1", "nsa", "", "node1", map[string]string{"app": "prod-app"}, map[string]string{}),
+		generatePod("128.0.0.2", "pod2", "nsa", "", "node1", map[string]string{"app": "prod-app"}, map[string]string{}),
+	}
+	addPods(t, controller, pods...)
+	for _, pod := range pods {
+		if err := waitForPod(controller, pod.Status.PodIP); err != nil {
+			t.Fatalf("wait for pod err: %v", err)
+		}
+	}
+
+	createService(controller, "svc1", "nsa", nil, []int32{8080}, map[string]string{"app": "prod-app"}, t)
+	if ev := fx.Wait("service"); ev == nil {
+		t.Fatal("Timeout creating service")
+	}
+	createEndpoints(controller, "svc1", "nsa", []string{"tcp-port"}, []string{"128.0.0.1", "128.0.0.2"}, t)
+	if ev := fx.Wait("eds"); ev == nil {
+		t.Fatal("Timeout incremental eds")
+	}
+
+	dump := controller.dump()
+	if dump.ClusterID != "cluster1" {
+		t.Errorf("got cluster ID %q, want cluster1", dump.ClusterID)
+	}
+	hostname := kube.ServiceHostname("svc1", "nsa", domainSuffix)
+	sd, f := dump.Services[hostname]
+	if !f {
+		t.Fatalf("service %s not found in dump", hostname)
+	}
+	if sd.Endpoints != 2 || sd.NotReadyEndpoints != 0 {
+		t.Errorf("got %d endpoints and %d not ready endpoints, want 2 and 0", sd.Endpoints, sd.NotReadyEndpoints)
+	}
+	if sd.LastServiceUpdate == nil || sd.LastEndpointsUpdate == nil {
+		t.Errorf("missing update timestamps: %+v", sd)
+	}
+	if len(dump.Orphans.Endpoints) != 0 || len(dump.Orphans.Services) != 0 {
+		t.Errorf("got orphans %+v, want none", dump.Orphans)
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	controller, fx := newFakeController(t)
+	defer controller.Stop()
+
+	// svc1 has endpoints, svc2 has none and the endpoints of svc3 have no service.
+	createService(controller, "svc1", "nsa", nil, []int32{8080}, map[string]string{"app": "prod-app"}, t)
+	createService(controller, "svc2", "nsa", nil, []int32{8080}, map[string]string{"app": "prod-app"}, t)
+	for i := 0; i < 2; i++ {
+		if ev := fx.Wait("service"); ev == nil {
+			t.Fatal("Timeout creating service")
+		}
+	}
+	createEndpoints(controller, "svc1", "nsa", []string{"tcp-port"}, []string{"128.0.0.1"}, t)
+	createEndpoints(controller, "svc3", "nsa", []string{"tcp-port"}, []string{"128.0.0.2"}, t)
+
+	// Leader election locks are not orphans.
+	lock := &coreV1.Endpoints{ObjectMeta: metaV1.ObjectMeta{
+		Name:        "leader",
+		Namespace:   "nsa",
+		Annotations: map[string]string{resourcelock.LeaderElectionRecordAnnotationKey: "{}"},
+	}}
+	if _, err := controller.client.CoreV1().Endpoints("nsa").Create(lock); err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Orphans{Endpoints: []string{"nsa/svc3"}, Services: []string{"nsa/svc2"}}
+	retry.UntilSuccessOrFail(t, func() error {
+		if got := controller.orphans(); !reflect.DeepEqual(got, want) {
+			return fmt.Errorf("got orphans %+v, want %+v", got, want)
+		}
+		return nil
+	}, retry.Timeout(5*time.Second))
+}
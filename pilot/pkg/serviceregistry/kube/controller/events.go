@@ -0,0 +1,117 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// EventReasonEndpointWithoutPod is the reason of the Events on the Services with an endpoint whose
+	// pod is not found.
+	EventReasonEndpointWithoutPod = "EndpointWithoutPod"
+	// EventReasonTargetPortNotFound is the reason of the Events on the Pods without the target port of
+	// a port of their Services.
+	EventReasonTargetPortNotFound = "TargetPortNotFound"
+	// EventReasonNodeNotFound is the reason of the Events on the Pods whose node is not found, so that
+	// their locality is unknown.
+	EventReasonNodeNotFound = "NodeNotFound"
+
+	// eventInterval is the minimum interval between the Events of a reason on an object. The anomalies
+	// are usually detected on every update of the object, which would flood the Events otherwise.
+	eventInterval = 5 * time.Minute
+)
+
+// registryEvents records warning Events on the Services and Pods with registry anomalies, so that the
+// application teams see them with kubectl describe. A nil registryEvents records nothing.
+type registryEvents struct {
+	client      kubernetes.Interface
+	broadcaster record.EventBroadcaster
+	recorder    record.EventRecorder
+
+	mutex sync.Mutex
+	// recorded is the time of the last Event of a reason, by object and reason.
+	recorded map[string]time.Time
+}
+
+func newRegistryEvents(client kubernetes.Interface, clusterID string) *registryEvents {
+	broadcaster := record.NewBroadcaster()
+	source := v1.EventSource{Component: "pilot-discovery"}
+	if clusterID != "" {
+		source.Component += "-" + clusterID
+	}
+	return &registryEvents{
+		client:      client,
+		broadcaster: broadcaster,
+		recorder:    broadcaster.NewRecorder(scheme.Scheme, source),
+		recorded:    map[string]time.Time{},
+	}
+}
+
+// run sends the Events to the API server until stop is closed.
+func (e *registryEvents) run(stop <-chan struct{}) {
+	if e == nil {
+		return
+	}
+	w := e.broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: e.client.CoreV1().Events("")})
+	<-stop
+	w.Stop()
+}
+
+// warn records a warning Event on the object, unless an Event of the reason was recorded on it less
+// than eventInterval ago.
+func (e *registryEvents) warn(object *v1.ObjectReference, reason, messageFmt string, args ...interface{}) {
+	if e == nil {
+		return
+	}
+	key := fmt.Sprintf("%s/%s/%s/%s", object.Kind, object.Namespace, object.Name, reason)
+	now := time.Now()
+	e.mutex.Lock()
+	if t, f := e.recorded[key]; f && now.Sub(t) < eventInterval {
+		e.mutex.Unlock()
+		return
+	}
+	for k, t := range e.recorded {
+		if now.Sub(t) >= eventInterval {
+			delete(e.recorded, k)
+		}
+	}
+	e.recorded[key] = now
+	e.mutex.Unlock()
+	e.recorder.Eventf(object, v1.EventTypeWarning, reason, messageFmt, args...)
+}
+
+func podReference(name, namespace, uid string) *v1.ObjectReference {
+	return &v1.ObjectReference{Kind: "Pod", APIVersion: "v1", Name: name, Namespace: namespace, UID: types.UID(uid)}
+}
+
+// serviceReference returns the reference of the Service, with its UID if it is in the cache, for the
+// Events to be listed by kubectl describe.
+func (c *Controller) serviceReference(name, namespace string) *v1.ObjectReference {
+	ref := &v1.ObjectReference{Kind: "Service", APIVersion: "v1", Name: name, Namespace: namespace}
+	if obj, f, err := c.services.informer.GetStore().GetByKey(namespace + "/" + name); err == nil && f {
+		ref.UID = obj.(*v1.Service).UID
+	}
+	return ref
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func newFakeRegistryEvents() (*registryEvents, *record.FakeRecorder) {
+	recorder := record.NewFakeRecorder(10)
+	return &registryEvents{recorder: recorder, recorded: map[string]time.Time{}}, recorder
+}
+
+func TestRegistryEventsRateLimit(t *testing.T) {
+	events, recorder := newFakeRegistryEvents()
+	pod := podReference("pod1", "nsa", "uid1")
+	events.warn(pod, EventReasonTargetPortNotFound, "port %q", "http")
+	events.warn(pod, EventReasonTargetPortNotFound, "port %q", "http")
+	events.warn(pod, EventReasonNodeNotFound, "node %q", "node1")
+	events.warn(podReference("pod2", "nsa", "uid2"), EventReasonTargetPortNotFound, "port %q", "http")
+	if got := len(recorder.Events); got != 3 {
+		t.Fatalf("got %d events, want 3", got)
+	}
+	if e := <-recorder.Events; e != `Warning TargetPortNotFound port "http"` {
+		t.Errorf("unexpected event %q", e)
+	}
+
+	// The events are recorded again after eventInterval.
+	events.recorded["Pod/nsa/pod1/"+EventReasonTargetPortNotFound] = time.Now().Add(-eventInterval)
+	events.warn(pod, EventReasonTargetPortNotFound, "port %q", "http")
+	if got := len(recorder.Events); got != 3 {
+		t.Errorf("got %d events, want 3", got)
+	}
+
+	var disabled *registryEvents
+	disabled.warn(pod, EventReasonTargetPortNotFound, "port %q", "http")
+}
+
+func TestLocalityEvents(t *testing.T) {
+	controller := NewController(fake.NewSimpleClientset(), Options{DomainSuffix: domainSuffix, RecordEvents: true})
+	recorder := record.NewFakeRecorder(10)
+	controller.events.recorder = recorder
+
+	pod := podReference("pod1", "nsa", "uid1")
+	if got := controller.getLocality(pod, nil, ""); got != "" {
+		t.Errorf("got locality %q, want none", got)
+	}
+	if got := len(recorder.Events); got != 0 {
+		t.Errorf("a pending pod should not record events, got %d", got)
+	}
+	controller.getLocality(pod, nil, "missing-node")
+	if got := len(recorder.Events); got != 1 {
+		t.Fatalf("got %d events, want 1", got)
+	}
+	if e := <-recorder.Events; !strings.HasPrefix(e, "Warning "+EventReasonNodeNotFound) {
+		t.Errorf("unexpected event %q", e)
+	}
+}
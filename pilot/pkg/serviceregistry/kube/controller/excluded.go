@@ -0,0 +1,128 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"istio.io/api/annotation"
+
+	"istio.io/pkg/monitoring"
+)
+
+// The reasons a pod is excluded from the mesh, from the most to the least explicit.
+const (
+	// ExcludedInjectionDisabled is the reason of the pods opted out of the sidecar injection.
+	ExcludedInjectionDisabled = "injection_disabled"
+	// ExcludedHostNetwork is the reason of the pods sharing the network of their node, whose
+	// address is ambiguous and which the sidecar can't be injected into.
+	ExcludedHostNetwork = "host_network"
+	// ExcludedNoSidecar is the reason of the pods without sidecar, e.g. in namespaces without injection.
+	ExcludedNoSidecar = "no_sidecar"
+	// ExcludedNoServicePort is the reason of the pods selected by services none of whose ports
+	// targets a port of the pod, so they are left out of the endpoints of these services.
+	ExcludedNoServicePort = "no_service_port"
+)
+
+var excludedPodReasons = []string{
+	ExcludedInjectionDisabled,
+	ExcludedHostNetwork,
+	ExcludedNoSidecar,
+	ExcludedNoServicePort,
+}
+
+var (
+	reasonTag = monitoring.MustCreateLabel("reason")
+
+	excludedPods = monitoring.NewGauge(
+		"pilot_k8s_excluded_pods",
+		"Pods of the registry excluded from the mesh, by reason, as of the last check.",
+		monitoring.WithLabels(clusterIDTag, reasonTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(excludedPods)
+}
+
+// excludedPodReason returns the reason the pod is excluded from the mesh, empty if it is not. The
+// services are the ones selecting the pod.
+func excludedPodReason(pod *v1.Pod, services []*v1.Service) string {
+	switch {
+	case pod.Annotations[annotation.SidecarInject.Name] == "false":
+		return ExcludedInjectionDisabled
+	case pod.Spec.HostNetwork:
+		return ExcludedHostNetwork
+	case pod.Annotations[annotation.SidecarStatus.Name] == "":
+		return ExcludedNoSidecar
+	case len(services) > 0 && !targetsPod(pod, services):
+		return ExcludedNoServicePort
+	}
+	return ""
+}
+
+// targetsPod returns true if a port of any of the services targets a port of the pod.
+func targetsPod(pod *v1.Pod, services []*v1.Service) bool {
+	var containerPorts []v1.ContainerPort
+	for _, container := range pod.Spec.Containers {
+		containerPorts = append(containerPorts, container.Ports...)
+	}
+	for _, svc := range services {
+		for i := range svc.Spec.Ports {
+			if _, err := findContainerPort(containerPorts, &svc.Spec.Ports[i], string(pod.UID)); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// excludedPods returns the number of running pods of the registry excluded from the mesh, by
+// reason, from the pod informer cache.
+func (c *Controller) excludedPods() map[string]int {
+	out := make(map[string]int, len(excludedPodReasons))
+	for _, reason := range excludedPodReasons {
+		out[reason] = 0
+	}
+	for _, obj := range c.pods.informer.GetStore().List() {
+		pod := obj.(*v1.Pod)
+		if pod.Status.PodIP == "" || pod.DeletionTimestamp != nil ||
+			pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		if reason := excludedPodReason(pod, c.serviceIndex.getPodServices(pod)); reason != "" {
+			out[reason]++
+		}
+	}
+	return out
+}
+
+// checkExcludedPodsLoop periodically records the pods excluded from the mesh until stop is closed.
+func (c *Controller) checkExcludedPodsLoop(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for reason, n := range c.excludedPods() {
+				excludedPods.With(clusterIDTag.Value(c.ClusterID), reasonTag.Value(reason)).Record(float64(n))
+			}
+		case <-stop:
+			return
+		}
+	}
+}
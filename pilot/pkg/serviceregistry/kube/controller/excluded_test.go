@@ -0,0 +1,98 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"istio.io/api/annotation"
+
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func TestExcludedPodReason(t *testing.T) {
+	injected := map[string]string{annotation.SidecarStatus.Name: "{}"}
+	httpService := &coreV1.Service{Spec: coreV1.ServiceSpec{Ports: []coreV1.ServicePort{{
+		Port: 80, TargetPort: intstr.FromString("http"), Protocol: coreV1.ProtocolTCP}}}}
+	pod := func(annotations map[string]string, hostNetwork bool, ports ...coreV1.ContainerPort) *coreV1.Pod {
+		return &coreV1.Pod{
+			ObjectMeta: metaV1.ObjectMeta{Name: "pod1", Namespace: "nsa", Annotations: annotations},
+			Spec: coreV1.PodSpec{
+				HostNetwork: hostNetwork,
+				Containers:  []coreV1.Container{{Name: "app", Ports: ports}},
+			},
+		}
+	}
+	httpPort := coreV1.ContainerPort{Name: "http", ContainerPort: 8080, Protocol: coreV1.ProtocolTCP}
+
+	cases := []struct {
+		name     string
+		pod      *coreV1.Pod
+		services []*coreV1.Service
+		want     string
+	}{
+		{"in the mesh", pod(injected, false, httpPort), []*coreV1.Service{httpService}, ""},
+		{"in the mesh without service", pod(injected, false), nil, ""},
+		{"injection disabled", pod(map[string]string{annotation.SidecarInject.Name: "false"}, false, httpPort),
+			[]*coreV1.Service{httpService}, ExcludedInjectionDisabled},
+		{"host network", pod(injected, true, httpPort), []*coreV1.Service{httpService}, ExcludedHostNetwork},
+		{"no sidecar", pod(nil, false, httpPort), []*coreV1.Service{httpService}, ExcludedNoSidecar},
+		{"no service port", pod(injected, false, coreV1.ContainerPort{Name: "grpc", ContainerPort: 9090}),
+			[]*coreV1.Service{httpService}, ExcludedNoServicePort},
+	}
+	for _, c := range cases {
+		if got := excludedPodReason(c.pod, c.services); got != c.want {
+			t.Errorf("%s: excludedPodReason() = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestExcludedPods(t *testing.T) {
+	controller, fx := newFakeController(t)
+	defer controller.Stop()
+
+	injected := map[string]string{annotation.SidecarStatus.Name: "{}"}
+	pods := []*coreV1.Pod{
+		generatePod("128.0.0.1", "pod1", "nsa", "", "node1", map[string]string{"app": "prod-app"}, injected),
+		generatePod("128.0.0.2", "pod2", "nsa", "", "node1", map[string]string{"app": "prod-app"},
+			map[string]string{annotation.SidecarInject.Name: "false"}),
+		generatePod("128.0.0.3", "pod3", "nsa", "", "node1", map[string]string{"app": "prod-app"}, nil),
+	}
+	addPods(t, controller, pods...)
+	createService(controller, "svc1", "nsa", nil, []int32{8080}, map[string]string{"app": "prod-app"}, t)
+	if ev := fx.Wait("service"); ev == nil {
+		t.Fatal("Timeout creating service")
+	}
+
+	want := map[string]int{
+		ExcludedInjectionDisabled: 1,
+		ExcludedHostNetwork:       0,
+		ExcludedNoSidecar:         1,
+		ExcludedNoServicePort:     0,
+	}
+	retry.UntilSuccessOrFail(t, func() error {
+		if got := controller.excludedPods(); !reflect.DeepEqual(got, want) {
+			return fmt.Errorf("got excluded pods %v, want %v", got, want)
+		}
+		return nil
+	}, retry.Timeout(5*time.Second))
+}
@@ -0,0 +1,149 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"istio.io/pkg/log"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/kube"
+)
+
+// externalNameResolveTimeout bounds the resolution of an external name, so that an unresponsive
+// DNS server doesn't hold the resolution of the others.
+const externalNameResolveTimeout = 5 * time.Second
+
+// externalName is an ExternalName service in kube.ExternalNameModeStatic, with the addresses its
+// external name last resolved to.
+type externalName struct {
+	svc      *v1.Service
+	svcConv  *model.Service
+	resolved []string
+	// failed is set while the resolution fails, to only log the failure once.
+	failed bool
+}
+
+// updateExternalNameLocked registers the service if its external name is resolved in the registry,
+// and unregisters it otherwise. It returns the instances of the service from the addresses its
+// external name last resolved to, and the entry to resolve in the background if the external name is
+// new. c must be locked.
+func (c *Controller) updateExternalNameLocked(svc *v1.Service, svcConv *model.Service) ([]*model.ServiceInstance, *externalName) {
+	prev := c.externalNames[svcConv.Hostname]
+	if svcConv.Resolution != model.ClientSideLB || svc.Spec.Type != v1.ServiceTypeExternalName || svc.Spec.ExternalName == "" {
+		delete(c.externalNames, svcConv.Hostname)
+		return kube.ExternalNameServiceInstances(*svc, svcConv, nil), nil
+	}
+
+	entry := &externalName{svc: svc, svcConv: svcConv}
+	c.externalNames[svcConv.Hostname] = entry
+	if prev == nil || prev.svc.Spec.ExternalName != svc.Spec.ExternalName {
+		return nil, entry
+	}
+	entry.resolved, entry.failed = prev.resolved, prev.failed
+	return kube.ExternalNameServiceInstances(*svc, svcConv, entry.resolved), nil
+}
+
+// resolveExternalName resolves the external name of the entry and, if the addresses changed and the
+// entry is still current, updates the instances and the endpoints of the service.
+func (c *Controller) resolveExternalName(entry *externalName) {
+	name := entry.svc.Spec.ExternalName
+	ctx, cancel := context.WithTimeout(context.Background(), externalNameResolveTimeout)
+	addrs, err := c.lookupHost(ctx, name)
+	cancel()
+
+	hostname := entry.svcConv.Hostname
+	c.Lock()
+	if c.externalNames[hostname] != entry {
+		c.Unlock()
+		return
+	}
+	if err != nil {
+		// The last resolved addresses are kept until the name resolves again.
+		if !entry.failed {
+			log.Warnf("failed to resolve external name %s of service %s/%s: %v",
+				name, entry.svc.Namespace, entry.svc.Name, err)
+		}
+		entry.failed = true
+		c.Unlock()
+		return
+	}
+	entry.failed = false
+	sort.Strings(addrs)
+	if reflect.DeepEqual(addrs, entry.resolved) {
+		c.Unlock()
+		return
+	}
+	entry.resolved = addrs
+	instances := kube.ExternalNameServiceInstances(*entry.svc, entry.svcConv, addrs)
+	if instances == nil {
+		delete(c.externalNameSvcInstanceMap, hostname)
+	} else {
+		c.externalNameSvcInstanceMap[hostname] = instances
+	}
+	c.Unlock()
+
+	log.Infof("Handle external name %s of service %s in namespace %s -> %v", name, entry.svc.Name, entry.svc.Namespace, addrs)
+	_ = c.XDSUpdater.EDSUpdate(c.ClusterID, string(hostname), entry.svc.Namespace, externalNameEndpoints(instances))
+}
+
+// resolveExternalNames resolves again the external names of all the services resolved in the registry.
+func (c *Controller) resolveExternalNames() {
+	c.RLock()
+	entries := make([]*externalName, 0, len(c.externalNames))
+	for _, entry := range c.externalNames {
+		entries = append(entries, entry)
+	}
+	c.RUnlock()
+	for _, entry := range entries {
+		c.resolveExternalName(entry)
+	}
+}
+
+// resolveExternalNamesLoop periodically resolves again the external names until stop is closed.
+func (c *Controller) resolveExternalNamesLoop(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.resolveExternalNames()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// externalNameEndpoints returns the endpoints of the instances of an ExternalName service.
+func externalNameEndpoints(instances []*model.ServiceInstance) []*model.IstioEndpoint {
+	out := make([]*model.IstioEndpoint, 0, len(instances))
+	for _, instance := range instances {
+		out = append(out, &model.IstioEndpoint{
+			Family:          instance.Endpoint.Family,
+			Address:         instance.Endpoint.Address,
+			EndpointPort:    uint32(instance.Endpoint.Port),
+			ServicePortName: instance.Endpoint.ServicePort.Name,
+			Labels:          instance.Labels,
+			Attributes:      instance.Service.Attributes,
+		})
+	}
+	return out
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/kube"
+	"istio.io/istio/pkg/config/labels"
+)
+
+func TestStaticExternalNameResolution(t *testing.T) {
+	controller, fx := newFakeController(t)
+	defer controller.Stop()
+
+	var mu sync.Mutex
+	resolved := []string{"1.1.1.2", "1.1.1.1"}
+	var lookupErr error
+	controller.lookupHost = func(_ context.Context, host string) ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if host != "db.example.com" {
+			t.Errorf("lookup of %s, want db.example.com", host)
+		}
+		return append([]string(nil), resolved...), lookupErr
+	}
+
+	svc := &coreV1.Service{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "db",
+			Namespace: "nsA",
+			Annotations: map[string]string{
+				kube.ExternalNameModeAnnotation:  kube.ExternalNameModeStatic,
+				kube.ExternalNamePortsAnnotation: "tcp-db:5432",
+			},
+		},
+		Spec: coreV1.ServiceSpec{
+			Type:         coreV1.ServiceTypeExternalName,
+			ExternalName: "db.example.com",
+		},
+	}
+	if _, err := controller.client.CoreV1().Services("nsA").Create(svc); err != nil {
+		t.Fatal(err)
+	}
+	hostname := kube.ServiceHostname("db", "nsA", domainSuffix)
+
+	expectEndpoints := func(want ...string) {
+		t.Helper()
+		ev := fx.Wait("eds")
+		if ev == nil {
+			t.Fatal("timeout waiting for the endpoints of the external name")
+		}
+		var got []string
+		for _, ep := range ev.Endpoints {
+			if ep.EndpointPort != 5432 || ep.ServicePortName != "tcp-db" {
+				t.Errorf("endpoint %s:%d of port %s, want port 5432 tcp-db", ep.Address, ep.EndpointPort, ep.ServicePortName)
+			}
+			got = append(got, ep.Address)
+		}
+		sort.Strings(got)
+		if ev.ID != string(hostname) || !reflect.DeepEqual(got, want) {
+			t.Fatalf("got endpoints %v of %s, want %v of %s", got, ev.ID, want, hostname)
+		}
+	}
+	expectInstances := func(want ...string) {
+		t.Helper()
+		service, err := controller.GetService(hostname)
+		if err != nil || service == nil {
+			t.Fatalf("GetService(%s) = %v, %v", hostname, service, err)
+		}
+		instances, err := controller.InstancesByPort(service, 5432, labels.Collection{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, instance := range instances {
+			got = append(got, instance.Endpoint.Address)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got instances %v, want %v", got, want)
+		}
+	}
+
+	// The external name is resolved in the background once the service is added.
+	expectEndpoints("1.1.1.1", "1.1.1.2")
+	expectInstances("1.1.1.1", "1.1.1.2")
+
+	mu.Lock()
+	resolved = []string{"1.1.1.3"}
+	mu.Unlock()
+	controller.resolveExternalNames()
+	expectEndpoints("1.1.1.3")
+	expectInstances("1.1.1.3")
+
+	// The last resolved addresses are kept while the resolution fails.
+	mu.Lock()
+	lookupErr = errors.New("no such host")
+	mu.Unlock()
+	fx.Clear()
+	controller.resolveExternalNames()
+	expectInstances("1.1.1.3")
+
+	// A service no longer resolved in the registry is forgotten.
+	svc.Annotations = nil
+	if _, err := controller.client.CoreV1().Services("nsA").Update(svc); err != nil {
+		t.Fatal(err)
+	}
+	if ev := fx.Wait("service"); ev == nil {
+		t.Fatal("timeout updating the service")
+	}
+	controller.RLock()
+	_, f := controller.externalNames[hostname]
+	controller.RUnlock()
+	if f {
+		t.Errorf("external name of %s still resolved in the registry", hostname)
+	}
+	if service, _ := controller.GetService(hostname); service == nil || service.Resolution != model.DNSLB {
+		t.Errorf("service %v, want DNS resolution", service)
+	}
+}
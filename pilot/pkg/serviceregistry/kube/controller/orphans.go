@@ -0,0 +1,119 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"istio.io/pkg/log"
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	clusterIDTag = monitoring.MustCreateLabel("cluster_id")
+
+	orphanedEndpoints = monitoring.NewGauge(
+		"pilot_k8s_orphaned_endpoints",
+		"Endpoints without Service (type endpoints) and Services without Endpoints (type services), "+
+			"as of the last check.",
+		monitoring.WithLabels(clusterIDTag, typeTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(orphanedEndpoints)
+}
+
+// Orphans lists the Endpoints objects whose Service is missing, and the Services whose Endpoints
+// object is missing, by namespace/name key. They usually result from partially deleted manifests
+// and explain traffic going nowhere.
+type Orphans struct {
+	Endpoints []string `json:"endpoints"`
+	Services  []string `json:"services"`
+}
+
+// orphans returns the orphans of the registry, from the informer caches.
+func (c *Controller) orphans() *Orphans {
+	out := &Orphans{
+		Endpoints: make([]string, 0),
+		Services:  make([]string, 0),
+	}
+	for _, obj := range c.endpoints.informer.GetStore().List() {
+		ep := obj.(*v1.Endpoints)
+		// The leader election locks are Endpoints without Service.
+		if _, f := ep.Annotations[resourcelock.LeaderElectionRecordAnnotationKey]; f {
+			continue
+		}
+		if key, err := cache.MetaNamespaceKeyFunc(ep); err == nil && !c.hasObject(c.services, key) {
+			out.Endpoints = append(out.Endpoints, key)
+		}
+	}
+	for _, obj := range c.services.informer.GetStore().List() {
+		svc := obj.(*v1.Service)
+		// ExternalName services don't have endpoints.
+		if svc.Spec.Type == v1.ServiceTypeExternalName {
+			continue
+		}
+		if key, err := cache.MetaNamespaceKeyFunc(svc); err == nil && !c.hasObject(c.endpoints, key) {
+			out.Services = append(out.Services, key)
+		}
+	}
+	sort.Strings(out.Endpoints)
+	sort.Strings(out.Services)
+	return out
+}
+
+func (c *Controller) hasObject(ch cacheHandler, key string) bool {
+	_, exists, err := ch.informer.GetStore().GetByKey(key)
+	return err == nil && exists
+}
+
+// checkOrphansLoop periodically records the orphans of the registry until stop is closed, and
+// logs the new ones.
+func (c *Controller) checkOrphansLoop(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	reported := make(map[string]bool)
+	for {
+		select {
+		case <-ticker.C:
+			orphans := c.orphans()
+			orphanedEndpoints.With(clusterIDTag.Value(c.ClusterID), typeTag.Value("endpoints")).Record(float64(len(orphans.Endpoints)))
+			orphanedEndpoints.With(clusterIDTag.Value(c.ClusterID), typeTag.Value("services")).Record(float64(len(orphans.Services)))
+
+			current := make(map[string]bool, len(orphans.Endpoints)+len(orphans.Services))
+			for _, key := range orphans.Endpoints {
+				current["endpoints/"+key] = true
+				if !reported["endpoints/"+key] {
+					log.Warnf("Endpoints %s in cluster %s have no Service", key, c.ClusterID)
+				}
+			}
+			for _, key := range orphans.Services {
+				current["services/"+key] = true
+				if !reported["services/"+key] {
+					log.Warnf("Service %s in cluster %s has no Endpoints", key, c.ClusterID)
+				}
+			}
+			reported = current
+		case <-stop:
+			return
+		}
+	}
+}
@@ -16,12 +16,21 @@ package controller
 
 import (
 	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
 	"sync"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 
+	"istio.io/api/annotation"
+
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/serviceregistry/kube"
 	configKube "istio.io/istio/pkg/config/kube"
@@ -30,6 +39,268 @@ import (
 	"istio.io/pkg/log"
 )
 
+// retainedPodAnnotations are the only pod annotations kept in the pod informer store.
+var retainedPodAnnotations = []string{
+	annotation.AlphaIdentity.Name,
+	annotation.SidecarInject.Name,
+	annotation.SidecarStatus.Name,
+	PrometheusScrape,
+	PrometheusPort,
+	PrometheusPath,
+	kube.HealthCheckPortsAnnotation,
+}
+
+// podInfo is the subset of a pod used by the controller. It is populated at event time,
+// so lookups by IP do not need to keep or walk the full pod object.
+type podInfo struct {
+	name      string
+	namespace string
+	uid       string
+	ip        string
+	labels    labels.Instance
+	nodeName  string
+	// serviceAccount is the secure naming SAN of the pod.
+	serviceAccount  string
+	tlsMode         string
+	managementPorts model.PortList
+	probes          model.ProbeList
+	containerPorts  []v1.ContainerPort
+}
+
+// newPodInfo extracts the podInfo of a pod.
+func newPodInfo(pod *v1.Pod) *podInfo {
+	managementPorts, err := kube.ConvertProbesToPorts(&pod.Spec)
+	if err != nil {
+		// We continue despite the error because ConvertProbesToPorts could return a partial
+		// list of management ports
+		log.Infof("Error while parsing liveliness and readiness probe ports for %s/%s => %v", pod.Namespace, pod.Name, err)
+	}
+	healthCheckPorts := kube.ConvertHealthCheckPorts(pod)
+	managementPorts = mergeManagementPorts(managementPorts, healthCheckPorts)
+
+	var containerPorts []v1.ContainerPort
+	for _, container := range pod.Spec.Containers {
+		containerPorts = append(containerPorts, container.Ports...)
+	}
+
+	return &podInfo{
+		name:            pod.Name,
+		namespace:       pod.Namespace,
+		uid:             string(pod.UID),
+		ip:              pod.Status.PodIP,
+		labels:          configKube.ConvertLabels(pod.ObjectMeta),
+		nodeName:        pod.Spec.NodeName,
+		serviceAccount:  kube.SecureNamingSAN(pod),
+		tlsMode:         kube.PodTLSMode(pod),
+		managementPorts: managementPorts,
+		probes:          podProbes(pod, healthCheckPorts),
+		containerPorts:  containerPorts,
+	}
+}
+
+// podProbes returns the HTTP readiness and liveness probes of the pod, and its prometheus scrape endpoint.
+func podProbes(pod *v1.Pod, healthCheckPorts model.PortList) model.ProbeList {
+	probes := make([]*model.Probe, 0)
+
+	// Obtain probes from the readiness and liveness probes
+	for i := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[i]
+		if p := containerProbe(container, container.ReadinessProbe); p != nil {
+			probes = append(probes, p)
+		}
+		if p := containerProbe(container, container.LivenessProbe); p != nil {
+			probes = append(probes, p)
+		}
+	}
+
+	// Obtain probes from the health check ports, kubelet can't probe them
+	for _, port := range healthCheckPorts {
+		probes = append(probes, &model.Probe{
+			Type: model.TCPProbe,
+			Port: port,
+		})
+	}
+
+	// Obtain probe from prometheus scrape
+	if scrape := pod.Annotations[PrometheusScrape]; scrape == "true" {
+		var port *model.Port
+		path := PrometheusPathDefault
+		if portstr := pod.Annotations[PrometheusPort]; portstr != "" {
+			portnum, err := strconv.Atoi(portstr)
+			if err != nil {
+				log.Warna(err)
+			} else {
+				port = &model.Port{
+					Port: portnum,
+				}
+			}
+		}
+		if pod.Annotations[PrometheusPath] != "" {
+			path = pod.Annotations[PrometheusPath]
+		}
+		probes = append(probes, &model.Probe{
+			Port: port,
+			Path: path,
+		})
+	}
+
+	return probes
+}
+
+// containerProbe converts a readiness or liveness probe of the container, nil if it has none.
+func containerProbe(container *v1.Container, probe *v1.Probe) *model.Probe {
+	if probe == nil {
+		return nil
+	}
+	switch {
+	case probe.Handler.HTTPGet != nil:
+		p, err := kube.ConvertProbePort(container, &probe.Handler)
+		if err != nil {
+			log.Infof("Error while parsing probe port =%v", err)
+		}
+		return &model.Probe{
+			Port: p,
+			Path: probe.Handler.HTTPGet.Path,
+		}
+	case probe.Handler.TCPSocket != nil:
+		p, err := kube.ConvertProbePort(container, &probe.Handler)
+		if err != nil {
+			log.Infof("Error while parsing probe port =%v", err)
+		}
+		return &model.Probe{
+			Type: model.TCPProbe,
+			Port: p,
+		}
+	case probe.Handler.Exec != nil:
+		return &model.Probe{
+			Type:    model.ExecProbe,
+			Command: probe.Handler.Exec.Command,
+		}
+	}
+	return nil
+}
+
+// mergeManagementPorts adds the health check ports to the management ports derived from the probes,
+// sorted by port number. The protocol of a health check port takes precedence.
+func mergeManagementPorts(managementPorts, healthCheckPorts model.PortList) model.PortList {
+	if len(healthCheckPorts) == 0 {
+		return managementPorts
+	}
+	out := make(model.PortList, 0, len(managementPorts)+len(healthCheckPorts))
+	out = append(out, healthCheckPorts...)
+	for _, p := range managementPorts {
+		if _, found := healthCheckPorts.GetByPort(p.Port); !found {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Port < out[j].Port })
+	return out
+}
+
+// selectorPod returns a pod with just the information needed to match service selectors.
+func (p *podInfo) selectorPod() *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      p.name,
+			Namespace: p.namespace,
+			Labels:    p.labels,
+		},
+	}
+}
+
+// findPort locates the container port of the pod targeted by the service port.
+func (p *podInfo) findPort(svcPort *v1.ServicePort) (int, error) {
+	return findContainerPort(p.containerPorts, svcPort, p.uid)
+}
+
+// podTLSMode returns the tls mode of the pod, or disabled if the pod is unknown.
+func podTLSMode(p *podInfo) string {
+	if p == nil {
+		return model.DisabledTLSModeLabel
+	}
+	return p.tlsMode
+}
+
+// trimPod returns a copy of the pod holding only the fields used by the controller,
+// so the informer store does not retain full pod objects.
+func trimPod(pod *v1.Pod) *v1.Pod {
+	var annotations map[string]string
+	for _, a := range retainedPodAnnotations {
+		if v, f := pod.Annotations[a]; f {
+			if annotations == nil {
+				annotations = make(map[string]string)
+			}
+			annotations[a] = v
+		}
+	}
+
+	containers := make([]v1.Container, 0, len(pod.Spec.Containers))
+	for _, c := range pod.Spec.Containers {
+		containers = append(containers, v1.Container{
+			Name:           c.Name,
+			Ports:          c.Ports,
+			LivenessProbe:  c.LivenessProbe,
+			ReadinessProbe: c.ReadinessProbe,
+		})
+	}
+
+	return &v1.Pod{
+		TypeMeta: pod.TypeMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              pod.Name,
+			Namespace:         pod.Namespace,
+			UID:               pod.UID,
+			ResourceVersion:   pod.ResourceVersion,
+			DeletionTimestamp: pod.DeletionTimestamp,
+			Labels:            pod.Labels,
+			Annotations:       annotations,
+		},
+		Spec: v1.PodSpec{
+			ServiceAccountName: pod.Spec.ServiceAccountName,
+			NodeName:           pod.Spec.NodeName,
+			HostNetwork:        pod.Spec.HostNetwork,
+			Containers:         containers,
+		},
+		Status: v1.PodStatus{
+			Phase: pod.Status.Phase,
+			PodIP: pod.Status.PodIP,
+		},
+	}
+}
+
+// newPodInformer creates a pod informer whose store holds trimmed pods.
+func newPodInformer(client kubernetes.Interface, namespace string, resync time.Duration) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+				list, err := client.CoreV1().Pods(namespace).List(opts)
+				if err != nil {
+					return nil, err
+				}
+				for i := range list.Items {
+					list.Items[i] = *trimPod(&list.Items[i])
+				}
+				return list, nil
+			},
+			WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+				w, err := client.CoreV1().Pods(namespace).Watch(opts)
+				if err != nil {
+					return nil, err
+				}
+				return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+					if pod, ok := in.Object.(*v1.Pod); ok {
+						in.Object = trimPod(pod)
+					}
+					return in, true
+				}), nil
+			},
+		},
+		&v1.Pod{},
+		resync,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+}
+
 // PodCache is an eventually consistent pod cache
 type PodCache struct {
 	cacheHandler
@@ -39,15 +310,28 @@ type PodCache struct {
 	// this allows us to retrieve the latest status by pod IP.
 	// This should only contain RUNNING or PENDING pods with an allocated IP.
 	podsByIP map[string]string
+	// pods maps the key of the pods in podsByIP to their podInfo.
+	pods map[string]*podInfo
+	// podGenerations records the generation at which each IP mapping was last written.
+	// Reconciliation uses it to avoid evicting a mapping that changed while it was being validated.
+	podGenerations map[string]uint64
+	generation     uint64
+	// workloadPorts maps workload keys, built from the namespace and labels of the pods, to the named
+	// container ports of these pods. It allows resolving named target ports of proxies whose metadata
+	// lack them, without reading the pod.
+	workloadPorts map[string]*namedPorts
 
 	c *Controller
 }
 
 func newPodCache(ch cacheHandler, c *Controller) *PodCache {
 	out := &PodCache{
-		cacheHandler: ch,
-		c:            c,
-		podsByIP:     make(map[string]string),
+		cacheHandler:   ch,
+		c:              c,
+		podsByIP:       make(map[string]string),
+		pods:           make(map[string]*podInfo),
+		podGenerations: make(map[string]uint64),
+		workloadPorts:  make(map[string]*namedPorts),
 	}
 
 	ch.handler.Append(out.event)
@@ -83,44 +367,248 @@ func (pc *PodCache) event(obj interface{}, ev model.Event) error {
 		case model.EventAdd:
 			switch pod.Status.Phase {
 			case v1.PodPending, v1.PodRunning:
-				if _, ok := pc.podsByIP[ip]; !ok {
-					// add to cache if the pod is running or pending
-					pc.podsByIP[ip] = key
-					pc.proxyUpdates(ip)
-				}
+				// add to cache if the pod is running or pending
+				pc.addPod(key, newPodInfo(pod))
 			}
 		case model.EventUpdate:
 			if pod.DeletionTimestamp != nil {
-				// delete only if this pod was in the cache
-				if pc.podsByIP[ip] == key {
-					delete(pc.podsByIP, ip)
+				// The endpoints of a pod starting to terminate are drained.
+				if _, cached := pc.pods[key]; cached && pc.c != nil {
+					pc.c.drainPodEndpoints(pod)
 				}
+				// delete only if this pod was in the cache
+				pc.deletePodIP(ip, key)
 				return nil
 			}
 			switch pod.Status.Phase {
 			case v1.PodPending, v1.PodRunning:
-				if _, ok := pc.podsByIP[ip]; !ok {
-					// add to cache if the pod is running or pending
-					pc.podsByIP[ip] = key
-					pc.proxyUpdates(ip)
-				}
+				// add to cache if the pod is running or pending
+				pc.addPod(key, newPodInfo(pod))
 
 			default:
 				// delete if the pod switched to other states and is in the cache
-				if pc.podsByIP[ip] == key {
-					delete(pc.podsByIP, ip)
-				}
+				pc.deletePodIP(ip, key)
 			}
 		case model.EventDelete:
 			// delete only if this pod was in the cache
-			if pc.podsByIP[ip] == key {
-				delete(pc.podsByIP, ip)
-			}
+			pc.deletePodIP(ip, key)
 		}
 	}
 	return nil
 }
 
+// addPod maps the pod IP to the pod key and records its podInfo. An existing mapping owned by
+// another pod is only replaced when that pod is stale, which happens when an IP is reused before
+// the delete event of the previous owner has been processed. The caller must hold the write lock.
+func (pc *PodCache) addPod(key string, info *podInfo) {
+	ip := info.ip
+	existing, ok := pc.podsByIP[ip]
+	if ok && existing != key {
+		if !pc.isStale(ip, existing) {
+			return
+		}
+		log.Infof("Replacing stale pod %s for IP %s with %s", existing, ip, key)
+		pc.releasePorts(pc.pods[existing])
+		delete(pc.pods, existing)
+	}
+	pc.releasePorts(pc.pods[key])
+	pc.retainPorts(info)
+	pc.pods[key] = info
+	if existing == key {
+		return
+	}
+	pc.generation++
+	pc.podsByIP[ip] = key
+	pc.podGenerations[ip] = pc.generation
+	pc.proxyUpdates(ip)
+}
+
+// deletePodIP removes the mapping for ip if it is owned by the pod key.
+// The caller must hold the write lock.
+func (pc *PodCache) deletePodIP(ip, key string) {
+	if pc.podsByIP[ip] == key {
+		delete(pc.podsByIP, ip)
+		delete(pc.podGenerations, ip)
+		pc.releasePorts(pc.pods[key])
+		delete(pc.pods, key)
+	}
+}
+
+// namedPorts holds the named container ports of the pods of a workload.
+type namedPorts struct {
+	ports map[string]int
+	// refs is the number of cached pods of the workload.
+	refs int
+}
+
+// workloadKey returns the key identifying the pods of a workload, from their namespace and labels.
+func workloadKey(namespace string, l labels.Instance) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(l.String()))
+	return namespace + "/" + strconv.FormatUint(h.Sum64(), 16)
+}
+
+// retainPorts records the named ports of the pod. The caller must hold the write lock.
+func (pc *PodCache) retainPorts(info *podInfo) {
+	if info == nil {
+		return
+	}
+	ports := make(map[string]int)
+	for _, p := range info.containerPorts {
+		if p.Name != "" {
+			ports[p.Name] = int(p.ContainerPort)
+		}
+	}
+	if len(ports) == 0 {
+		return
+	}
+	key := workloadKey(info.namespace, info.labels)
+	np, f := pc.workloadPorts[key]
+	if !f {
+		np = &namedPorts{}
+		pc.workloadPorts[key] = np
+	}
+	// All pods of a workload share the same spec, the latest one wins.
+	np.ports = ports
+	np.refs++
+}
+
+// releasePorts drops the reference of the pod to the named ports of its workload.
+// The caller must hold the write lock.
+func (pc *PodCache) releasePorts(info *podInfo) {
+	if info == nil {
+		return
+	}
+	key := workloadKey(info.namespace, info.labels)
+	np, f := pc.workloadPorts[key]
+	if !f {
+		return
+	}
+	// Pods without named ports never retained the entry.
+	for _, p := range info.containerPorts {
+		if p.Name != "" {
+			np.refs--
+			break
+		}
+	}
+	if np.refs <= 0 {
+		delete(pc.workloadPorts, key)
+	}
+}
+
+// findNamedPort resolves a named container port from the pods of the workload with the given namespace and labels.
+func (pc *PodCache) findNamedPort(namespace string, l labels.Instance, name string) (int, bool) {
+	if name == "" {
+		return 0, false
+	}
+	pc.RLock()
+	defer pc.RUnlock()
+	np, f := pc.workloadPorts[workloadKey(namespace, l)]
+	if !f {
+		return 0, false
+	}
+	port, f := np.ports[name]
+	return port, f
+}
+
+// isStale returns true if the pod identified by key no longer owns ip according to the informer store.
+// Lookup errors are not considered stale, so transient failures never evict a valid mapping.
+func (pc *PodCache) isStale(ip, key string) bool {
+	if pc.informer == nil {
+		return false
+	}
+	item, exists, err := pc.informer.GetStore().GetByKey(key)
+	if err != nil {
+		return false
+	}
+	if !exists {
+		return true
+	}
+	pod := item.(*v1.Pod)
+	if pod.Status.PodIP != ip || pod.DeletionTimestamp != nil {
+		return true
+	}
+	switch pod.Status.Phase {
+	case v1.PodPending, v1.PodRunning:
+		return false
+	default:
+		return true
+	}
+}
+
+// reconcile validates every IP mapping against the informer store, evicting mappings whose pod
+// is gone, terminating or was assigned a different IP, and indexing pods whose events were missed.
+// It returns the number of evicted mappings.
+func (pc *PodCache) reconcile() int {
+	if pc.informer == nil {
+		return 0
+	}
+
+	type snapshot struct {
+		key        string
+		generation uint64
+	}
+	pc.RLock()
+	entries := make(map[string]snapshot, len(pc.podsByIP))
+	for ip, key := range pc.podsByIP {
+		entries[ip] = snapshot{key: key, generation: pc.podGenerations[ip]}
+	}
+	pc.RUnlock()
+
+	stale := make(map[string]snapshot)
+	for ip, e := range entries {
+		if pc.isStale(ip, e.key) {
+			stale[ip] = e
+		}
+	}
+
+	pc.Lock()
+	defer pc.Unlock()
+
+	evicted := 0
+	for ip, e := range stale {
+		// Skip mappings updated by an event since the snapshot was taken.
+		if pc.podsByIP[ip] != e.key || pc.podGenerations[ip] != e.generation {
+			continue
+		}
+		log.Infof("Evicting stale pod %s for IP %s from pod cache", e.key, ip)
+		pc.deletePodIP(ip, e.key)
+		evicted++
+	}
+	if evicted > 0 {
+		stalePodIPEvictions.Record(float64(evicted))
+	}
+
+	for _, item := range pc.informer.GetStore().List() {
+		pod, ok := item.(*v1.Pod)
+		if !ok || len(pod.Status.PodIP) == 0 || pod.DeletionTimestamp != nil {
+			continue
+		}
+		switch pod.Status.Phase {
+		case v1.PodPending, v1.PodRunning:
+			key := kube.KeyFunc(pod.Name, pod.Namespace)
+			if pc.podsByIP[pod.Status.PodIP] != key {
+				pc.addPod(key, newPodInfo(pod))
+			}
+		}
+	}
+	return evicted
+}
+
+// reconcileLoop periodically runs reconcile until stop is closed.
+func (pc *PodCache) reconcileLoop(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			pc.reconcile()
+		case <-stop:
+			return
+		}
+	}
+}
+
 func (pc *PodCache) proxyUpdates(ip string) {
 	if pc.c != nil && pc.c.XDSUpdater != nil {
 		pc.c.XDSUpdater.ProxyUpdate(pc.c.ClusterID, ip)
@@ -135,27 +623,25 @@ func (pc *PodCache) getPodKey(addr string) (string, bool) {
 	return key, exists
 }
 
-// getPodByIp returns the pod or nil if pod not found or an error occurred
-func (pc *PodCache) getPodByIP(addr string) *v1.Pod {
-	key, exists := pc.getPodKey(addr)
+// getPodByIP returns the podInfo of the pod or nil if pod not found
+func (pc *PodCache) getPodByIP(addr string) *podInfo {
+	pc.RLock()
+	defer pc.RUnlock()
+	key, exists := pc.podsByIP[addr]
 	if !exists {
 		return nil
 	}
-	item, exists, err := pc.informer.GetStore().GetByKey(key)
-	if !exists || err != nil {
-		return nil
-	}
-	return item.(*v1.Pod)
+	return pc.pods[key]
 }
 
 // getPod loads the pod from k8s.
-func (pc *PodCache) getPod(name string, namespace string) *v1.Pod {
+func (pc *PodCache) getPod(name string, namespace string) *podInfo {
 	pod, err := pc.c.client.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
 	if err != nil {
 		log.Warnf("failed to get pod %s/%s from kube-apiserver: %v", namespace, name, err)
 		return nil
 	}
-	return pod
+	return newPodInfo(pod)
 }
 
 // labelsByIP returns pod labels or nil if pod not found or an error occurred
@@ -164,5 +650,5 @@ func (pc *PodCache) labelsByIP(addr string) (labels.Instance, bool) {
 	if pod == nil {
 		return nil, false
 	}
-	return configKube.ConvertLabels(pod.ObjectMeta), true
+	return pod.labels, true
 }
@@ -21,6 +21,7 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
@@ -28,6 +29,7 @@ import (
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/serviceregistry/kube"
 	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/protocol"
 )
 
 // Prepare k8s. This can be used in multiple tests, to
@@ -224,3 +226,199 @@ func TestPodCacheEvents(t *testing.T) {
 		t.Errorf("getPodKey => got %s, want none", pod)
 	}
 }
+
+func TestPodCacheReconcile(t *testing.T) {
+	c, fx := newFakeController(t)
+	defer c.Stop()
+	initTestEnv(t, c.client, fx)
+	cache.WaitForCacheSync(c.stop, c.pods.informer.HasSynced)
+
+	ip := "128.0.0.10"
+	pod := generatePod(ip, "reused", "nsb", "", "", map[string]string{"app": "reused"}, map[string]string{})
+	addPods(t, c, pod)
+	if err := waitForPod(c, ip); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate missed delete events: one IP owned by a pod that no longer exists, and an IP that
+	// was reused by a new pod while still pointing at its previous owner.
+	c.pods.Lock()
+	c.pods.podsByIP["128.0.0.11"] = "nsa/deleted"
+	c.pods.podsByIP[ip] = "nsa/previous-owner"
+	c.pods.Unlock()
+
+	if evicted := c.pods.reconcile(); evicted != 2 {
+		t.Fatalf("reconcile() evicted %d entries, want 2", evicted)
+	}
+	if key, exists := c.pods.getPodKey("128.0.0.11"); exists {
+		t.Errorf("getPodKey => got %s, want none", key)
+	}
+	if key, exists := c.pods.getPodKey(ip); !exists || key != "nsb/reused" {
+		t.Errorf("getPodKey => got %s, want nsb/reused", key)
+	}
+
+	// A second pass is a no-op.
+	if evicted := c.pods.reconcile(); evicted != 0 {
+		t.Errorf("reconcile() evicted %d entries, want 0", evicted)
+	}
+}
+
+func TestTrimPod(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "nsA",
+			UID:       "uid1",
+			Labels:    map[string]string{"app": "test-app", model.TLSModeLabelName: model.IstioMutualTLSModeLabel},
+			Annotations: map[string]string{
+				PrometheusScrape: "true",
+				PrometheusPort:   "9090",
+				"kubectl.kubernetes.io/last-applied-configuration": "{}",
+			},
+		},
+		Spec: v1.PodSpec{
+			ServiceAccountName: "acct1",
+			NodeName:           "node1",
+			Containers: []v1.Container{{
+				Name:  "app",
+				Image: "app:latest",
+				Env:   []v1.EnvVar{{Name: "FOO", Value: "bar"}},
+				Ports: []v1.ContainerPort{{Name: "http", ContainerPort: 8080, Protocol: v1.ProtocolTCP}},
+				ReadinessProbe: &v1.Probe{Handler: v1.Handler{HTTPGet: &v1.HTTPGetAction{
+					Path: "/ready", Port: intstr.FromInt(8081)}}},
+			}},
+		},
+		Status: v1.PodStatus{
+			Phase:      v1.PodRunning,
+			PodIP:      "128.0.0.1",
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		},
+	}
+
+	trimmed := trimPod(pod)
+	if _, f := trimmed.Annotations["kubectl.kubernetes.io/last-applied-configuration"]; f {
+		t.Errorf("unexpected annotation retained: %v", trimmed.Annotations)
+	}
+	if len(trimmed.Status.Conditions) != 0 || trimmed.Spec.Containers[0].Image != "" || trimmed.Spec.Containers[0].Env != nil {
+		t.Errorf("unexpected fields retained: %+v", trimmed)
+	}
+
+	// The podInfo of the trimmed pod must match the one of the full pod.
+	want := newPodInfo(pod)
+	got := newPodInfo(trimmed)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("podInfo of trimmed pod %+v, want %+v", got, want)
+	}
+	if got.ip != "128.0.0.1" || got.nodeName != "node1" || got.tlsMode != model.IstioMutualTLSModeLabel {
+		t.Errorf("unexpected podInfo %+v", got)
+	}
+	if len(got.managementPorts) != 1 || got.managementPorts[0].Port != 8081 {
+		t.Errorf("unexpected management ports %v", got.managementPorts)
+	}
+	if len(got.probes) != 2 || got.probes[0].Path != "/ready" || got.probes[1].Port.Port != 9090 {
+		t.Errorf("unexpected probes %v", got.probes)
+	}
+	port, err := got.findPort(&v1.ServicePort{TargetPort: intstr.FromString("http"), Protocol: v1.ProtocolTCP})
+	if err != nil || port != 8080 {
+		t.Errorf("findPort() => %d, %v, want 8080", port, err)
+	}
+}
+
+func TestPodInfoNonHTTPProbes(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "nsA",
+			Annotations: map[string]string{
+				kube.HealthCheckPortsAnnotation: "6379:redis,3306,invalid",
+			},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name:  "app",
+				Ports: []v1.ContainerPort{{Name: "mysql", ContainerPort: 3306, Protocol: v1.ProtocolTCP}},
+				ReadinessProbe: &v1.Probe{Handler: v1.Handler{TCPSocket: &v1.TCPSocketAction{
+					Port: intstr.FromString("mysql")}}},
+				LivenessProbe: &v1.Probe{Handler: v1.Handler{Exec: &v1.ExecAction{
+					Command: []string{"cat", "/tmp/healthy"}}}},
+			}},
+		},
+		Status: v1.PodStatus{
+			PodIP: "128.0.0.1",
+		},
+	}
+
+	// The annotation must survive the trimming of the pod.
+	info := newPodInfo(trimPod(pod))
+
+	tcp := &model.Port{Name: "mgmt-3306", Port: 3306, Protocol: protocol.TCP}
+	redis := &model.Port{Name: "mgmt-6379", Port: 6379, Protocol: protocol.Redis}
+	if want := (model.PortList{tcp, redis}); !reflect.DeepEqual(info.managementPorts, want) {
+		t.Errorf("unexpected management ports %v, want %v", info.managementPorts, want)
+	}
+
+	want := model.ProbeList{
+		{Type: model.TCPProbe, Port: tcp},
+		{Type: model.ExecProbe, Command: []string{"cat", "/tmp/healthy"}},
+		{Type: model.TCPProbe, Port: tcp},
+		{Type: model.TCPProbe, Port: redis},
+	}
+	if !reflect.DeepEqual(info.probes, want) {
+		t.Errorf("unexpected probes %v, want %v", info.probes, want)
+	}
+	for _, p := range info.probes {
+		if p.IsHTTP() {
+			t.Errorf("unexpected HTTP probe %v", p)
+		}
+	}
+}
+
+func TestPodCacheNamedPorts(t *testing.T) {
+	pc := newPodCache(cacheHandler{handler: &kube.ChainHandler{}}, nil)
+
+	podLabels := map[string]string{"app": "test-app"}
+	newPod := func(name, ip string) *podInfo {
+		pod := generatePod(ip, name, "nsa", "", "", podLabels, map[string]string{})
+		pod.Spec.Containers = []v1.Container{{
+			Name:  "app",
+			Ports: []v1.ContainerPort{{Name: "http", ContainerPort: 8080}, {ContainerPort: 9090}},
+		}}
+		return newPodInfo(pod)
+	}
+
+	pc.Lock()
+	pc.addPod("nsa/pod1", newPod("pod1", "128.0.0.1"))
+	pc.addPod("nsa/pod2", newPod("pod2", "128.0.0.2"))
+	pc.Unlock()
+
+	if port, f := pc.findNamedPort("nsa", podLabels, "http"); !f || port != 8080 {
+		t.Errorf("findNamedPort(http) => %d, %v, want 8080", port, f)
+	}
+	if _, f := pc.findNamedPort("nsa", podLabels, "grpc"); f {
+		t.Errorf("findNamedPort(grpc) => found, want none")
+	}
+	if _, f := pc.findNamedPort("nsb", podLabels, "http"); f {
+		t.Errorf("findNamedPort in other namespace => found, want none")
+	}
+	if _, f := pc.findNamedPort("nsa", map[string]string{"app": "other"}, "http"); f {
+		t.Errorf("findNamedPort with other labels => found, want none")
+	}
+
+	// Ports are kept as long as a pod of the workload is cached.
+	pc.Lock()
+	pc.deletePodIP("128.0.0.1", "nsa/pod1")
+	pc.Unlock()
+	if _, f := pc.findNamedPort("nsa", podLabels, "http"); !f {
+		t.Errorf("findNamedPort(http) => none, want found")
+	}
+
+	pc.Lock()
+	pc.deletePodIP("128.0.0.2", "nsa/pod2")
+	pc.Unlock()
+	if _, f := pc.findNamedPort("nsa", podLabels, "http"); f {
+		t.Errorf("findNamedPort(http) after deleting all pods => found, want none")
+	}
+	if len(pc.workloadPorts) != 0 {
+		t.Errorf("workloadPorts not cleaned up: %v", pc.workloadPorts)
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"sort"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"istio.io/istio/pilot/pkg/serviceregistry/kube"
+)
+
+// selectorIndex is an inverted index of the services by the labels of their selectors, so that the
+// services of a pod are found from its labels without listing all the services of its namespace,
+// which GetProxyServiceInstances does for every connecting proxy.
+type selectorIndex struct {
+	mutex sync.RWMutex
+	// services stores the services with a selector, by namespace/name key.
+	services map[string]*v1.Service
+	// byLabel stores the keys of the services of a namespace, by label of their selectors.
+	byLabel map[string]map[label]map[string]struct{}
+	// selectAll stores the keys of the services of a namespace with an empty, non-nil, selector,
+	// which select all the pods of the namespace.
+	selectAll map[string]map[string]struct{}
+}
+
+type label struct {
+	key   string
+	value string
+}
+
+func newSelectorIndex() *selectorIndex {
+	return &selectorIndex{
+		services:  map[string]*v1.Service{},
+		byLabel:   map[string]map[label]map[string]struct{}{},
+		selectAll: map[string]map[string]struct{}{},
+	}
+}
+
+// eventHandler returns the handler keeping the index in sync with the services informer.
+func (idx *selectorIndex) eventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if svc, ok := obj.(*v1.Service); ok {
+				idx.update(svc)
+			}
+		},
+		UpdateFunc: func(_, cur interface{}) {
+			if svc, ok := cur.(*v1.Service); ok {
+				idx.update(svc)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			svc, ok := obj.(*v1.Service)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					return
+				}
+				if svc, ok = tombstone.Obj.(*v1.Service); !ok {
+					return
+				}
+			}
+			idx.delete(svc)
+		},
+	}
+}
+
+func (idx *selectorIndex) update(svc *v1.Service) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	key := kube.KeyFunc(svc.Name, svc.Namespace)
+	idx.deleteLocked(key)
+	if svc.Spec.Selector == nil {
+		// Services with nil selectors match nothing, not everything.
+		return
+	}
+	idx.services[key] = svc
+	if len(svc.Spec.Selector) == 0 {
+		keys, f := idx.selectAll[svc.Namespace]
+		if !f {
+			keys = map[string]struct{}{}
+			idx.selectAll[svc.Namespace] = keys
+		}
+		keys[key] = struct{}{}
+		return
+	}
+	labels, f := idx.byLabel[svc.Namespace]
+	if !f {
+		labels = map[label]map[string]struct{}{}
+		idx.byLabel[svc.Namespace] = labels
+	}
+	for k, v := range svc.Spec.Selector {
+		l := label{key: k, value: v}
+		keys, f := labels[l]
+		if !f {
+			keys = map[string]struct{}{}
+			labels[l] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+func (idx *selectorIndex) delete(svc *v1.Service) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	idx.deleteLocked(kube.KeyFunc(svc.Name, svc.Namespace))
+}
+
+func (idx *selectorIndex) deleteLocked(key string) {
+	svc, f := idx.services[key]
+	if !f {
+		return
+	}
+	delete(idx.services, key)
+	if len(svc.Spec.Selector) == 0 {
+		delete(idx.selectAll[svc.Namespace], key)
+		if len(idx.selectAll[svc.Namespace]) == 0 {
+			delete(idx.selectAll, svc.Namespace)
+		}
+		return
+	}
+	labels := idx.byLabel[svc.Namespace]
+	for k, v := range svc.Spec.Selector {
+		l := label{key: k, value: v}
+		delete(labels[l], key)
+		if len(labels[l]) == 0 {
+			delete(labels, l)
+		}
+	}
+	if len(labels) == 0 {
+		delete(idx.byLabel, svc.Namespace)
+	}
+}
+
+// getPodServices returns the services selecting the pod, sorted by name, like
+// ServiceLister.GetPodServices.
+func (idx *selectorIndex) getPodServices(pod *v1.Pod) []*v1.Service {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+	var out []*v1.Service
+	for key := range idx.selectAll[pod.Namespace] {
+		out = append(out, idx.services[key])
+	}
+	labels := idx.byLabel[pod.Namespace]
+	seen := map[string]struct{}{}
+	for k, v := range pod.Labels {
+		for key := range labels[label{key: k, value: v}] {
+			if _, f := seen[key]; f {
+				continue
+			}
+			seen[key] = struct{}{}
+			// The service is a candidate, it selects the pod if all the labels of its selector match.
+			svc := idx.services[key]
+			if selects(svc.Spec.Selector, pod.Labels) {
+				out = append(out, svc)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+func selects(selector, labels map[string]string) bool {
+	for k, v := range selector {
+		if lv, f := labels[k]; !f || lv != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func selectorService(name, namespace string, selector map[string]string) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       v1.ServiceSpec{Selector: selector},
+	}
+}
+
+func TestSelectorIndex(t *testing.T) {
+	idx := newSelectorIndex()
+	idx.update(selectorService("app", "nsa", map[string]string{"app": "a"}))
+	idx.update(selectorService("app-v1", "nsa", map[string]string{"app": "a", "version": "v1"}))
+	idx.update(selectorService("app-v2", "nsa", map[string]string{"app": "a", "version": "v2"}))
+	idx.update(selectorService("all", "nsa", map[string]string{}))
+	idx.update(selectorService("none", "nsa", nil))
+	idx.update(selectorService("other-ns", "nsb", map[string]string{"app": "a"}))
+
+	names := func(pod *v1.Pod) []string {
+		var out []string
+		for _, svc := range idx.getPodServices(pod) {
+			out = append(out, svc.Name)
+		}
+		return out
+	}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "nsa",
+		Labels:    map[string]string{"app": "a", "version": "v1", "extra": "label"},
+	}}
+	if got, want := names(pod), []string{"all", "app", "app-v1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got services %v, want %v", got, want)
+	}
+
+	// The selector of a service is changed.
+	idx.update(selectorService("app-v1", "nsa", map[string]string{"app": "b", "version": "v1"}))
+	if got, want := names(pod), []string{"all", "app"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got services %v, want %v", got, want)
+	}
+
+	idx.eventHandler().OnDelete(cache.DeletedFinalStateUnknown{Obj: selectorService("all", "nsa", map[string]string{})})
+	idx.delete(selectorService("app", "nsa", nil))
+	if got := names(pod); len(got) != 0 {
+		t.Errorf("got services %v, want none", got)
+	}
+
+	idx.delete(selectorService("app-v1", "nsa", nil))
+	idx.delete(selectorService("app-v2", "nsa", nil))
+	idx.delete(selectorService("other-ns", "nsb", nil))
+	if len(idx.services) != 0 || len(idx.byLabel) != 0 || len(idx.selectAll) != 0 {
+		t.Errorf("the index should be empty: %v %v %v", idx.services, idx.byLabel, idx.selectAll)
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"istio.io/api/annotation"
+	"istio.io/pkg/log"
 
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/serviceregistry"
@@ -43,9 +44,145 @@ const (
 	// responsible for it
 	IngressClassAnnotation = "kubernetes.io/ingress.class"
 
+	// ServiceExportedAnnotation set to "false" on a Service removes it from the mesh: the registry
+	// skips its conversion and does not publish its endpoints.
+	ServiceExportedAnnotation = "networking.istio.io/exported"
+
+	// TopologyAwareHintsAnnotation set to "Auto" on a Service makes proxies prefer the endpoints
+	// in their own zone, as kube-proxy does.
+	TopologyAwareHintsAnnotation = "service.kubernetes.io/topology-aware-hints"
+
+	// NetworkLabel set on a Service marks it as the gateway of the network named by the label,
+	// for Split Horizon EDS.
+	NetworkLabel = "topology.istio.io/network"
+
+	// NetworkGatewayPortAnnotation sets the port of a network gateway Service, DefaultNetworkGatewayPort if not set.
+	NetworkGatewayPortAnnotation = "networking.istio.io/gatewayPort"
+
+	// DefaultNetworkGatewayPort is the port of the network gateways, used for mTLS passthrough.
+	DefaultNetworkGatewayPort = 15443
+
+	// ExternalNameModeAnnotation sets how the external name of an ExternalName Service is resolved:
+	// ExternalNameModeDNS, the default, or ExternalNameModeStatic.
+	ExternalNameModeAnnotation = "networking.istio.io/externalNameMode"
+
+	// ExternalNameModeDNS resolves the external name in the proxies, with strict DNS clusters, like
+	// a ServiceEntry with DNS resolution.
+	ExternalNameModeDNS = "DNS"
+
+	// ExternalNameModeStatic resolves the external name in the registry, in the background and again
+	// periodically, the proxies load balancing over the resolved addresses.
+	ExternalNameModeStatic = "STATIC"
+
+	// ExternalNamePortsAnnotation adds ports to an ExternalName Service, which often has none, as a
+	// comma separated list of <name>:<port>. The protocol of the ports is derived from their name.
+	ExternalNamePortsAnnotation = "networking.istio.io/externalNamePorts"
+
+	// PortProtocolsAnnotation overrides the protocol derived from the name of the ports of a
+	// Service, for Services whose ports can't be renamed, as a comma separated list of
+	// <port>:<protocol>, e.g. "8080:http,9090:grpc". UDP ports are not overridden.
+	PortProtocolsAnnotation = "networking.istio.io/portProtocols"
+
+	// HealthCheckPortsAnnotation declares additional health check ports on a pod, for the protocols
+	// kubelet can't probe, as a comma separated list of <port>[:<protocol>], e.g. "6379:redis,9000".
+	// The protocol defaults to TCP. The ports are handled as management ports.
+	HealthCheckPortsAnnotation = "sidecar.istio.io/healthCheckPorts"
+
+	// TrafficViaAnnotation routes the traffic of a Service through an intermediary Service, e.g. an
+	// egress gateway, as <name>[.<namespace>][:<port>]. The endpoints of the Service are replaced by
+	// the endpoints of the intermediary, on the given port or the port of the Service, and the
+	// intermediary is expected to forward the traffic to the Service.
+	TrafficViaAnnotation = "networking.istio.io/trafficVia"
+
 	managementPortPrefix = "mgmt-"
 )
 
+// IsServiceExported returns false if the service opted out of the mesh using ServiceExportedAnnotation.
+func IsServiceExported(svc *coreV1.Service) bool {
+	return svc.Annotations[ServiceExportedAnnotation] != "false"
+}
+
+// TopologyAwareHintsEnabled returns true if the service enabled topology aware hints using TopologyAwareHintsAnnotation.
+func TopologyAwareHintsEnabled(svc *coreV1.Service) bool {
+	return strings.EqualFold(svc.Annotations[TopologyAwareHintsAnnotation], "auto")
+}
+
+// TrafficIntermediary is the intermediary Service the traffic of a Service is routed through.
+type TrafficIntermediary struct {
+	Name      string
+	Namespace string
+	// Port is the port of the endpoints of the intermediary, the port of the Service if zero.
+	Port uint32
+}
+
+// TrafficVia returns the intermediary of a Service set with TrafficViaAnnotation, nil if the traffic
+// of the Service is not routed through an intermediary. The namespace defaults to the namespace of
+// the Service.
+func TrafficVia(svc *coreV1.Service) *TrafficIntermediary {
+	value := svc.Annotations[TrafficViaAnnotation]
+	if value == "" {
+		return nil
+	}
+
+	out := &TrafficIntermediary{Namespace: svc.Namespace}
+	name := value
+	if i := strings.LastIndex(value, ":"); i >= 0 {
+		n, err := strconv.ParseUint(value[i+1:], 10, 16)
+		if err != nil || n == 0 {
+			log.Warnf("invalid %s annotation %q on service %s/%s", TrafficViaAnnotation, value, svc.Namespace, svc.Name)
+			return nil
+		}
+		out.Port = uint32(n)
+		name = value[:i]
+	}
+	parts := strings.SplitN(name, ".", 2)
+	out.Name = parts[0]
+	if len(parts) == 2 {
+		out.Namespace = parts[1]
+	}
+	if out.Name == "" || out.Namespace == "" || (out.Name == svc.Name && out.Namespace == svc.Namespace) {
+		log.Warnf("invalid %s annotation %q on service %s/%s", TrafficViaAnnotation, value, svc.Namespace, svc.Name)
+		return nil
+	}
+	return out
+}
+
+// NetworkGateways returns the network gateways of a Service marked with NetworkLabel, reachable on the
+// external IPs and load balancer IPs of the Service.
+func NetworkGateways(svc *coreV1.Service, istioService *model.Service, clusterID string) []*model.NetworkGateway {
+	network := svc.Labels[NetworkLabel]
+	if network == "" {
+		return nil
+	}
+
+	port := uint32(DefaultNetworkGatewayPort)
+	if p, f := svc.Annotations[NetworkGatewayPortAnnotation]; f {
+		n, err := strconv.ParseUint(p, 10, 16)
+		if err != nil || n == 0 {
+			log.Warnf("invalid %s annotation %q on service %s/%s", NetworkGatewayPortAnnotation, p, svc.Namespace, svc.Name)
+			return nil
+		}
+		port = uint32(n)
+	}
+
+	addrs := append([]string{}, svc.Spec.ExternalIPs...)
+	addrs = append(addrs, istioService.Attributes.ClusterExternalAddresses[clusterID]...)
+
+	out := make([]*model.NetworkGateway, 0, len(addrs))
+	for _, addr := range addrs {
+		// Load balancer hostnames can't be used as endpoint addresses.
+		if net.ParseIP(addr) == nil {
+			continue
+		}
+		out = append(out, &model.NetworkGateway{
+			Network: network,
+			Addr:    addr,
+			Port:    port,
+		})
+	}
+	return out
+}
+
 func convertPort(port coreV1.ServicePort) *model.Port {
 	return &model.Port{
 		Name:     port.Name,
@@ -66,6 +203,9 @@ func ConvertService(svc coreV1.Service, domainSuffix string, clusterID string) *
 	if svc.Spec.Type == coreV1.ServiceTypeExternalName && svc.Spec.ExternalName != "" {
 		external = svc.Spec.ExternalName
 		resolution = model.DNSLB
+		if isExternalNameStatic(&svc) {
+			resolution = model.ClientSideLB
+		}
 		meshExternal = true
 	}
 
@@ -73,9 +213,17 @@ func ConvertService(svc coreV1.Service, domainSuffix string, clusterID string) *
 		resolution = model.Passthrough
 	}
 
+	protocols := portProtocols(&svc)
 	ports := make([]*model.Port, 0, len(svc.Spec.Ports))
 	for _, port := range svc.Spec.Ports {
-		ports = append(ports, convertPort(port))
+		p := convertPort(port)
+		if proto, f := protocols[port.Port]; f && port.Protocol != coreV1.ProtocolUDP {
+			p.Protocol = proto
+		}
+		ports = append(ports, p)
+	}
+	if external != "" {
+		ports = append(ports, externalNamePorts(&svc, ports)...)
 	}
 
 	var exportTo map[visibility.Instance]bool
@@ -112,6 +260,7 @@ func ConvertService(svc coreV1.Service, domainSuffix string, clusterID string) *
 			Namespace:       svc.Namespace,
 			UID:             fmt.Sprintf("istio://%s/services/%s", svc.Namespace, svc.Name),
 			ExportTo:        exportTo,
+			RegistryWeight:  model.ParseRegistryWeight(svc.Namespace+"/"+svc.Name, svc.Annotations),
 		},
 	}
 
@@ -135,25 +284,111 @@ func ConvertService(svc coreV1.Service, domainSuffix string, clusterID string) *
 	return istioService
 }
 
-func ExternalNameServiceInstances(k8sSvc coreV1.Service, svc *model.Service) []*model.ServiceInstance {
+// ExternalNameServiceInstances returns the instances of an ExternalName service: its external name,
+// resolved by the proxies, or the addresses its external name resolved to in ExternalNameModeStatic.
+func ExternalNameServiceInstances(k8sSvc coreV1.Service, svc *model.Service, resolved []string) []*model.ServiceInstance {
 	if k8sSvc.Spec.Type != coreV1.ServiceTypeExternalName || k8sSvc.Spec.ExternalName == "" {
 		return nil
 	}
-	out := make([]*model.ServiceInstance, 0, len(svc.Ports))
-	for _, portEntry := range svc.Ports {
-		out = append(out, &model.ServiceInstance{
-			Endpoint: model.NetworkEndpoint{
-				Address:     k8sSvc.Spec.ExternalName,
-				Port:        portEntry.Port,
-				ServicePort: portEntry,
-			},
-			Service: svc,
-			Labels:  k8sSvc.Labels,
+	addrs := []string{k8sSvc.Spec.ExternalName}
+	if svc.Resolution == model.ClientSideLB {
+		if len(resolved) == 0 {
+			return nil
+		}
+		addrs = resolved
+	}
+	out := make([]*model.ServiceInstance, 0, len(addrs)*len(svc.Ports))
+	for _, addr := range addrs {
+		for _, portEntry := range svc.Ports {
+			out = append(out, &model.ServiceInstance{
+				Endpoint: model.NetworkEndpoint{
+					Address:     addr,
+					Port:        portEntry.Port,
+					ServicePort: portEntry,
+				},
+				Service: svc,
+				Labels:  k8sSvc.Labels,
+			})
+		}
+	}
+	return out
+}
+
+// isExternalNameStatic returns true if the external name of the service is resolved when the
+// service is converted, as set by ExternalNameModeAnnotation.
+func isExternalNameStatic(svc *coreV1.Service) bool {
+	mode, f := svc.Annotations[ExternalNameModeAnnotation]
+	if !f || strings.EqualFold(mode, ExternalNameModeStatic) || strings.EqualFold(mode, ExternalNameModeDNS) {
+		return f && strings.EqualFold(mode, ExternalNameModeStatic)
+	}
+	// The services are converted on every event, hence the debug level.
+	log.Debugf("invalid %s annotation %q on service %s/%s, using %s",
+		ExternalNameModeAnnotation, mode, svc.Namespace, svc.Name, ExternalNameModeDNS)
+	return false
+}
+
+// externalNamePorts returns the ports added to the service by ExternalNamePortsAnnotation, skipping
+// the invalid ones and the ones conflicting with the existing ports.
+func externalNamePorts(svc *coreV1.Service, existing model.PortList) []*model.Port {
+	value := svc.Annotations[ExternalNamePortsAnnotation]
+	if value == "" {
+		return nil
+	}
+	var out model.PortList
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) != 2 {
+			log.Warnf("invalid port %q in %s annotation on service %s/%s", entry, ExternalNamePortsAnnotation, svc.Namespace, svc.Name)
+			continue
+		}
+		name := parts[0]
+		port, err := strconv.ParseUint(parts[1], 10, 16)
+		if name == "" || err != nil || port == 0 {
+			log.Warnf("invalid port %q in %s annotation on service %s/%s", entry, ExternalNamePortsAnnotation, svc.Namespace, svc.Name)
+			continue
+		}
+		_, nameFound := existing.Get(name)
+		_, portFound := existing.GetByPort(int(port))
+		_, nameAdded := out.Get(name)
+		_, portAdded := out.GetByPort(int(port))
+		if nameFound || portFound || nameAdded || portAdded {
+			log.Warnf("duplicate port %q in %s annotation on service %s/%s", entry, ExternalNamePortsAnnotation, svc.Namespace, svc.Name)
+			continue
+		}
+		out = append(out, &model.Port{
+			Name:     name,
+			Port:     int(port),
+			Protocol: kube.ConvertProtocol(int32(port), name, coreV1.ProtocolTCP),
 		})
 	}
 	return out
 }
 
+// portProtocols returns the protocols of the ports overridden by PortProtocolsAnnotation, by port
+// number, skipping the invalid entries.
+func portProtocols(svc *coreV1.Service) map[int32]protocol.Instance {
+	value := svc.Annotations[PortProtocolsAnnotation]
+	if value == "" {
+		return nil
+	}
+	out := make(map[int32]protocol.Instance)
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) != 2 {
+			log.Warnf("invalid port protocol %q in %s annotation on service %s/%s", entry, PortProtocolsAnnotation, svc.Namespace, svc.Name)
+			continue
+		}
+		port, err := strconv.ParseUint(parts[0], 10, 16)
+		proto := protocol.Parse(parts[1])
+		if err != nil || port == 0 || proto == protocol.Unsupported {
+			log.Warnf("invalid port protocol %q in %s annotation on service %s/%s", entry, PortProtocolsAnnotation, svc.Namespace, svc.Name)
+			continue
+		}
+		out[int32(port)] = proto
+	}
+	return out
+}
+
 // ServiceHostname produces FQDN for a k8s service
 func ServiceHostname(name, namespace, domainSuffix string) host.Name {
 	return host.Name(fmt.Sprintf("%s.%s.svc.%s", name, namespace, domainSuffix))
@@ -266,3 +501,41 @@ func ConvertProbesToPorts(t *coreV1.PodSpec) (model.PortList, error) {
 
 	return mgmtPorts, errs
 }
+
+// ConvertHealthCheckPorts returns the ports declared by the HealthCheckPortsAnnotation of the pod,
+// sorted by port number, skipping the invalid entries.
+func ConvertHealthCheckPorts(pod *coreV1.Pod) model.PortList {
+	value := pod.Annotations[HealthCheckPortsAnnotation]
+	if value == "" {
+		return nil
+	}
+	set := make(map[int]*model.Port)
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) > 2 {
+			log.Warnf("invalid health check port %q in %s annotation on pod %s/%s", entry, HealthCheckPortsAnnotation, pod.Namespace, pod.Name)
+			continue
+		}
+		port, err := strconv.ParseUint(parts[0], 10, 16)
+		proto := protocol.TCP
+		if len(parts) == 2 {
+			proto = protocol.Parse(parts[1])
+		}
+		if err != nil || port == 0 || proto == protocol.Unsupported || proto == protocol.UDP {
+			log.Warnf("invalid health check port %q in %s annotation on pod %s/%s", entry, HealthCheckPortsAnnotation, pod.Namespace, pod.Name)
+			continue
+		}
+		set[int(port)] = &model.Port{
+			Name:     managementPortPrefix + strconv.Itoa(int(port)),
+			Port:     int(port),
+			Protocol: proto,
+		}
+	}
+
+	ports := make(model.PortList, 0, len(set))
+	for _, p := range set {
+		ports = append(ports, p)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Port < ports[j].Port })
+	return ports
+}
@@ -291,6 +291,138 @@ func TestExternalServiceConversion(t *testing.T) {
 	}
 }
 
+func TestExternalNameServiceModes(t *testing.T) {
+	newService := func(annotations map[string]string) coreV1.Service {
+		return coreV1.Service{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:        "service1",
+				Namespace:   "default",
+				Annotations: annotations,
+			},
+			Spec: coreV1.ServiceSpec{
+				Type:         coreV1.ServiceTypeExternalName,
+				ExternalName: "localhost",
+			},
+		}
+	}
+
+	cases := []struct {
+		name           string
+		annotations    map[string]string
+		wantResolution model.Resolution
+		wantPorts      model.PortList
+		resolved       []string
+		wantAddress    string
+	}{
+		{
+			name:           "no ports",
+			wantResolution: model.DNSLB,
+		},
+		{
+			name: "dns",
+			annotations: map[string]string{
+				ExternalNamePortsAnnotation: "http:80, tcp-db:5432,invalid,http-dup:80,grpc:0",
+			},
+			wantResolution: model.DNSLB,
+			wantPorts: model.PortList{
+				{Name: "http", Port: 80, Protocol: protocol.HTTP},
+				{Name: "tcp-db", Port: 5432, Protocol: protocol.TCP},
+			},
+			wantAddress: "localhost",
+		},
+		{
+			name: "static",
+			annotations: map[string]string{
+				ExternalNameModeAnnotation:  "static",
+				ExternalNamePortsAnnotation: "http:80",
+			},
+			wantResolution: model.ClientSideLB,
+			wantPorts: model.PortList{
+				{Name: "http", Port: 80, Protocol: protocol.HTTP},
+			},
+			resolved:    []string{"127.0.0.1"},
+			wantAddress: "127.0.0.1",
+		},
+		{
+			name: "static not resolved yet",
+			annotations: map[string]string{
+				ExternalNameModeAnnotation:  "static",
+				ExternalNamePortsAnnotation: "http:80",
+			},
+			wantResolution: model.ClientSideLB,
+			wantPorts: model.PortList{
+				{Name: "http", Port: 80, Protocol: protocol.HTTP},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			svc := newService(c.annotations)
+			service := ConvertService(svc, domainSuffix, clusterID)
+			if service.Resolution != c.wantResolution {
+				t.Errorf("resolution => %v, want %v", service.Resolution, c.wantResolution)
+			}
+			if !service.External() {
+				t.Error("service should be external")
+			}
+			if !reflect.DeepEqual(service.Ports, c.wantPorts) && len(service.Ports)+len(c.wantPorts) > 0 {
+				t.Errorf("ports => %v, want %v", service.Ports.GetNames(), c.wantPorts.GetNames())
+			}
+
+			instances := ExternalNameServiceInstances(svc, service, c.resolved)
+			if c.wantAddress == "" {
+				if len(instances) != 0 {
+					t.Errorf("instances => %d, want none", len(instances))
+				}
+				return
+			}
+			found := false
+			for _, instance := range instances {
+				if instance.Endpoint.Address == c.wantAddress && instance.Endpoint.Port == 80 {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("instances => no instance %s:80", c.wantAddress)
+			}
+		})
+	}
+}
+
+func TestPortProtocolsAnnotation(t *testing.T) {
+	svc := coreV1.Service{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "service1",
+			Namespace: "default",
+			Annotations: map[string]string{
+				PortProtocolsAnnotation: "8080:http, 9090:grpc,5353:http,invalid,70000:http,8443:unknown",
+			},
+		},
+		Spec: coreV1.ServiceSpec{
+			ClusterIP: "10.0.0.1",
+			Ports: []coreV1.ServicePort{
+				{Name: "web", Port: 8080, Protocol: coreV1.ProtocolTCP},
+				{Name: "tcp-rpc", Port: 9090, Protocol: coreV1.ProtocolTCP},
+				{Name: "dns", Port: 5353, Protocol: coreV1.ProtocolUDP},
+				{Name: "https-admin", Port: 8443, Protocol: coreV1.ProtocolTCP},
+			},
+		},
+	}
+
+	service := ConvertService(svc, domainSuffix, clusterID)
+	want := map[string]protocol.Instance{
+		"web":         protocol.HTTP,
+		"tcp-rpc":     protocol.GRPC,
+		"dns":         protocol.UDP,
+		"https-admin": protocol.HTTPS,
+	}
+	for _, port := range service.Ports {
+		if port.Protocol != want[port.Name] {
+			t.Errorf("port %s protocol => %v, want %v", port.Name, port.Protocol, want[port.Name])
+		}
+	}
+}
+
 func TestExternalClusterLocalServiceConversion(t *testing.T) {
 	serviceName := "service1"
 	namespace := "default"
@@ -399,6 +531,133 @@ func TestLBServiceConversion(t *testing.T) {
 	}
 }
 
+func TestNetworkGateways(t *testing.T) {
+	newService := func(labels, annotations map[string]string) *coreV1.Service {
+		return &coreV1.Service{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:        "istio-ingressgateway",
+				Namespace:   "istio-system",
+				Labels:      labels,
+				Annotations: annotations,
+			},
+			Spec: coreV1.ServiceSpec{
+				Ports: []coreV1.ServicePort{
+					{
+						Name:     "tls",
+						Port:     15443,
+						Protocol: coreV1.ProtocolTCP,
+					},
+				},
+				Type:        coreV1.ServiceTypeLoadBalancer,
+				ExternalIPs: []string{"10.1.1.1"},
+			},
+			Status: coreV1.ServiceStatus{
+				LoadBalancer: coreV1.LoadBalancerStatus{
+					Ingress: []coreV1.LoadBalancerIngress{
+						{IP: "127.68.32.112"},
+						{Hostname: "gateway.example.com"},
+					},
+				},
+			},
+		}
+	}
+
+	cases := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		want        []*model.NetworkGateway
+	}{
+		{
+			name: "not a gateway",
+		},
+		{
+			name:   "default port",
+			labels: map[string]string{NetworkLabel: "network1"},
+			want: []*model.NetworkGateway{
+				{Network: "network1", Addr: "10.1.1.1", Port: DefaultNetworkGatewayPort},
+				{Network: "network1", Addr: "127.68.32.112", Port: DefaultNetworkGatewayPort},
+			},
+		},
+		{
+			name:        "port annotation",
+			labels:      map[string]string{NetworkLabel: "network1"},
+			annotations: map[string]string{NetworkGatewayPortAnnotation: "443"},
+			want: []*model.NetworkGateway{
+				{Network: "network1", Addr: "10.1.1.1", Port: 443},
+				{Network: "network1", Addr: "127.68.32.112", Port: 443},
+			},
+		},
+		{
+			name:        "invalid port annotation",
+			labels:      map[string]string{NetworkLabel: "network1"},
+			annotations: map[string]string{NetworkGatewayPortAnnotation: "tls"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			svc := newService(c.labels, c.annotations)
+			got := NetworkGateways(svc, ConvertService(*svc, domainSuffix, clusterID), clusterID)
+			if len(got) != len(c.want) {
+				t.Fatalf("got %d gateways, want %d", len(got), len(c.want))
+			}
+			for i := range got {
+				if !reflect.DeepEqual(got[i], c.want[i]) {
+					t.Errorf("got gateway %v, want %v", got[i], c.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTrafficVia(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		want  *TrafficIntermediary
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name:  "same namespace",
+			value: "egressgateway",
+			want:  &TrafficIntermediary{Name: "egressgateway", Namespace: "default"},
+		},
+		{
+			name:  "other namespace and port",
+			value: "istio-egressgateway.istio-system:8080",
+			want:  &TrafficIntermediary{Name: "istio-egressgateway", Namespace: "istio-system", Port: 8080},
+		},
+		{
+			name:  "invalid port",
+			value: "istio-egressgateway.istio-system:http",
+		},
+		{
+			name:  "no name",
+			value: ".istio-system",
+		},
+		{
+			name:  "the service itself",
+			value: "service1.default",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			svc := &coreV1.Service{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:        "service1",
+					Namespace:   "default",
+					Annotations: map[string]string{TrafficViaAnnotation: c.value},
+				},
+			}
+			if got := TrafficVia(svc); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got intermediary %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
 func TestProbesToPortsConversion(t *testing.T) {
 
 	expected := model.PortList{
@@ -486,6 +745,32 @@ func TestProbesToPortsConversion(t *testing.T) {
 	}
 }
 
+func TestConvertHealthCheckPorts(t *testing.T) {
+	pod := &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "default",
+			Annotations: map[string]string{
+				HealthCheckPortsAnnotation: "9000, 6379:redis,8080:grpc,5353:udp,0,70000,1:2:3,8443:unknown",
+			},
+		},
+	}
+
+	expected := model.PortList{
+		{Name: "mgmt-6379", Port: 6379, Protocol: protocol.Redis},
+		{Name: "mgmt-8080", Port: 8080, Protocol: protocol.GRPC},
+		{Name: "mgmt-9000", Port: 9000, Protocol: protocol.TCP},
+	}
+	if ports := ConvertHealthCheckPorts(pod); !reflect.DeepEqual(ports, expected) {
+		t.Errorf("ConvertHealthCheckPorts() => %v, want %v", ports, expected)
+	}
+
+	pod.Annotations = nil
+	if ports := ConvertHealthCheckPorts(pod); len(ports) != 0 {
+		t.Errorf("ConvertHealthCheckPorts() without annotation => %v, want none", ports)
+	}
+}
+
 func TestSecureNamingSANCustomIdentity(t *testing.T) {
 
 	pod := &coreV1.Pod{}
@@ -273,11 +273,11 @@ func (sd *ServiceDiscovery) GetIstioServiceAccounts(svc *model.Service, ports []
 
 type MockController struct{}
 
-func (c *MockController) AppendServiceHandler(f func(*model.Service, model.Event)) error {
+func (c *MockController) AppendServiceHandler(f func(*model.Service, model.Event), filters ...model.HandlerFilter) error {
 	return nil
 }
 
-func (c *MockController) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event)) error {
+func (c *MockController) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event), filters ...model.HandlerFilter) error {
 	return nil
 }
 
@@ -55,6 +55,10 @@ type Config struct {
 	// IP is currently the primary key used to locate inbound configs. It is sent by client,
 	// must match a known endpoint IP. Tests can use a ServiceEntry to register fake IPs.
 	IP string
+
+	// ACKHandler, if set, is called with the type and the version of each response once it
+	// is acknowledged, before the update is reported.
+	ACKHandler func(typeURL, versionInfo string)
 }
 
 // ADSC implements a basic client for ADS, for use in stress tests and tools
@@ -103,6 +107,8 @@ type ADSC struct {
 	Updates     chan string
 	VersionInfo map[string]string
 
+	ackHandler func(typeURL, versionInfo string)
+
 	mutex sync.Mutex
 }
 
@@ -146,6 +152,7 @@ func Dial(url string, certDir string, opts *Config) (*ADSC, error) {
 		opts.Workload = "test-1"
 	}
 	adsc.Metadata = opts.Meta
+	adsc.ackHandler = opts.ACKHandler
 
 	adsc.nodeID = fmt.Sprintf("%s~%s~%s.%s~%s.svc.cluster.local", opts.NodeType, opts.IP,
 		opts.Workload, opts.Namespace, opts.Namespace)
@@ -285,6 +292,9 @@ func (a *ADSC) handleRecv() {
 		a.mutex.Lock()
 		a.ack(msg)
 		a.mutex.Unlock()
+		if a.ackHandler != nil {
+			a.ackHandler(msg.TypeUrl, msg.VersionInfo)
+		}
 
 		if len(listeners) > 0 {
 			a.handleLDS(listeners)
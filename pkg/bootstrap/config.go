@@ -89,6 +89,27 @@ type Config struct {
 	SDSTokenPath        string
 	ControlPlaneAuth    bool
 	DisableReportCalls  bool
+
+	// SDSInProcess is set if SDSUDSPath is the in-process SDS server of the agent, authenticating the
+	// connection to the discovery server with the certificates signed by the K8S CA.
+	SDSInProcess bool
+
+	// XDSInitialFetchTimeout is the time Envoy waits for the initial listeners and clusters, the Envoy
+	// default if 0.
+	XDSInitialFetchTimeout time.Duration
+	// XDSDNSFailureRefreshRate is the initial backoff between the resolutions of the discovery address
+	// after a DNS failure, up to XDSDNSFailureRefreshMaxRate. The DNS refresh rate is used if 0.
+	XDSDNSFailureRefreshRate    time.Duration
+	XDSDNSFailureRefreshMaxRate time.Duration
+	// XDSKeepaliveTime, XDSKeepaliveInterval and XDSKeepaliveProbes tune the TCP keepalive of the xDS
+	// connection, so that it survives NAT timeouts and broken connections are detected. The keepalive
+	// time defaults to 300s and the others to the OS settings if 0.
+	XDSKeepaliveTime     time.Duration
+	XDSKeepaliveInterval time.Duration
+	XDSKeepaliveProbes   uint32
+	// XDSDrainConnectionsOnHostRemoval closes the xDS connection when the discovery address no longer
+	// resolves to its host, e.g. after the IP of the control plane changed.
+	XDSDrainConnectionsOnHostRemoval bool
 }
 
 // newTemplateParams creates a new template configuration for the given configuration.
@@ -114,8 +135,16 @@ func (cfg Config) toTemplateParams() (map[string]interface{}, error) {
 		option.PilotSubjectAltName(cfg.PilotSubjectAltName),
 		option.MixerSubjectAltName(cfg.MixerSubjectAltName),
 		option.DNSRefreshRate(cfg.DNSRefreshRate),
+		option.XDSInitialFetchTimeout(durationOrNil(cfg.XDSInitialFetchTimeout)),
+		option.XDSDNSFailureRefreshRate(durationOrNil(cfg.XDSDNSFailureRefreshRate)),
+		option.XDSDNSFailureRefreshMaxRate(durationOrNil(cfg.XDSDNSFailureRefreshMaxRate)),
+		option.XDSKeepaliveTime(uint32(cfg.XDSKeepaliveTime.Seconds())),
+		option.XDSKeepaliveInterval(uint32(cfg.XDSKeepaliveInterval.Seconds())),
+		option.XDSKeepaliveProbes(cfg.XDSKeepaliveProbes),
+		option.XDSDrainConnectionsOnHostRemoval(cfg.XDSDrainConnectionsOnHostRemoval),
 		option.SDSTokenPath(cfg.SDSTokenPath),
 		option.SDSUDSPath(cfg.SDSUDSPath),
+		option.SDSInProcess(cfg.SDSInProcess),
 		option.ControlPlaneAuth(cfg.ControlPlaneAuth),
 		option.DisableReportCalls(cfg.DisableReportCalls))
 
@@ -203,6 +232,14 @@ func lightstepAccessTokenFile(config string) string {
 	return path.Join(config, lightstepAccessTokenBase)
 }
 
+// durationOrNil returns nil for a zero duration, so that its option is skipped.
+func durationOrNil(d time.Duration) *types.Duration {
+	if d == 0 {
+		return nil
+	}
+	return types.DurationProto(d)
+}
+
 // convertDuration converts to golang duration and logs errors
 func convertDuration(d *types.Duration) time.Duration {
 	if d == nil {
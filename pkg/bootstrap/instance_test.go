@@ -22,6 +22,7 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	v1 "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
 	core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
@@ -84,6 +85,13 @@ func TestGolden(t *testing.T) {
 		sdsTokenPath               string
 		expectLightstepAccessToken bool
 		stats                      stats
+		xdsInitialFetchTimeout     time.Duration
+		xdsDNSFailureRefreshRate   time.Duration
+		xdsDNSFailureRefreshMax    time.Duration
+		xdsKeepaliveTime           time.Duration
+		xdsKeepaliveInterval       time.Duration
+		xdsKeepaliveProbes         uint32
+		xdsDrainOnHostRemoval      bool
 		checkLocality              bool
 		setup                      func()
 		teardown                   func()
@@ -100,6 +108,16 @@ func TestGolden(t *testing.T) {
 		{
 			base: "default",
 		},
+		{
+			base:                     "xds",
+			xdsInitialFetchTimeout:   30 * time.Second,
+			xdsDNSFailureRefreshRate: time.Second,
+			xdsDNSFailureRefreshMax:  20 * time.Second,
+			xdsKeepaliveTime:         60 * time.Second,
+			xdsKeepaliveInterval:     10 * time.Second,
+			xdsKeepaliveProbes:       3,
+			xdsDrainOnHostRemoval:    true,
+		},
 		{
 			base: "running",
 			envVars: map[string]string{
@@ -268,6 +286,15 @@ func TestGolden(t *testing.T) {
 				NodeIPs:      []string{"10.3.3.3", "10.4.4.4", "10.5.5.5", "10.6.6.6", "10.4.4.4"},
 				SDSUDSPath:   c.sdsUDSPath,
 				SDSTokenPath: c.sdsTokenPath,
+
+				XDSInitialFetchTimeout:      c.xdsInitialFetchTimeout,
+				XDSDNSFailureRefreshRate:    c.xdsDNSFailureRefreshRate,
+				XDSDNSFailureRefreshMaxRate: c.xdsDNSFailureRefreshMax,
+				XDSKeepaliveTime:            c.xdsKeepaliveTime,
+				XDSKeepaliveInterval:        c.xdsKeepaliveInterval,
+				XDSKeepaliveProbes:          c.xdsKeepaliveProbes,
+
+				XDSDrainConnectionsOnHostRemoval: c.xdsDrainOnHostRemoval,
 			}).CreateFileForEpoch(0)
 			if err != nil {
 				t.Fatal(err)
@@ -23,6 +23,7 @@ import (
 	envoyAPI "github.com/envoyproxy/go-control-plane/envoy/api/v2"
 	envoyAPICore "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	"github.com/gogo/protobuf/types"
+	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/ptypes/wrappers"
 
 	networkingAPI "istio.io/api/networking/v1alpha3"
@@ -51,7 +52,8 @@ func keepaliveConverter(value *networkingAPI.ConnectionPoolSettings_TCPSettings_
 		if value.Interval != nil && value.Interval.Seconds > 0 {
 			upstreamConnectionOptions.TcpKeepalive.KeepaliveInterval = &wrappers.UInt32Value{Value: uint32(value.Interval.Seconds)}
 		}
-		return convertToJSON(upstreamConnectionOptions), nil
+		// The wrapped values must be marshaled as plain numbers for Envoy to accept them.
+		return (&jsonpb.Marshaler{OrigName: true}).MarshalToString(upstreamConnectionOptions)
 	}
 }
 
@@ -90,6 +90,34 @@ func DNSRefreshRate(value string) Instance {
 	return newOption("dns_refresh_rate", value)
 }
 
+func XDSInitialFetchTimeout(value *types.Duration) Instance {
+	return newDurationOption("xds_initial_fetch_timeout", value)
+}
+
+func XDSDNSFailureRefreshRate(value *types.Duration) Instance {
+	return newDurationOption("xds_dns_failure_refresh_rate", value)
+}
+
+func XDSDNSFailureRefreshMaxRate(value *types.Duration) Instance {
+	return newDurationOption("xds_dns_failure_refresh_max_rate", value)
+}
+
+func XDSKeepaliveTime(seconds uint32) Instance {
+	return newOptionOrSkipIfZero("xds_keepalive_time", seconds)
+}
+
+func XDSKeepaliveInterval(seconds uint32) Instance {
+	return newOptionOrSkipIfZero("xds_keepalive_interval", seconds)
+}
+
+func XDSKeepaliveProbes(value uint32) Instance {
+	return newOptionOrSkipIfZero("xds_keepalive_probes", value)
+}
+
+func XDSDrainConnectionsOnHostRemoval(value bool) Instance {
+	return newOption("xds_drain_connections_on_host_removal", value)
+}
+
 func Localhost(value LocalhostValue) Instance {
 	return newOption("localhost", value)
 }
@@ -231,3 +259,7 @@ func SDSUDSPath(value string) Instance {
 func SDSTokenPath(value string) Instance {
 	return newOption("sds_token_path", value)
 }
+
+func SDSInProcess(value bool) Instance {
+	return newOption("sds_in_process", value)
+}
@@ -132,6 +132,60 @@ func TestOptions(t *testing.T) {
 			option:   option.DNSRefreshRate("1s"),
 			expected: "1s",
 		},
+		{
+			testName: "nil xds initial fetch timeout",
+			key:      "xds_initial_fetch_timeout",
+			option:   option.XDSInitialFetchTimeout(nil),
+			expected: nil,
+		},
+		{
+			testName: "xds initial fetch timeout",
+			key:      "xds_initial_fetch_timeout",
+			option:   option.XDSInitialFetchTimeout(types.DurationProto(30 * time.Second)),
+			expected: "30s",
+		},
+		{
+			testName: "xds dns failure refresh rate",
+			key:      "xds_dns_failure_refresh_rate",
+			option:   option.XDSDNSFailureRefreshRate(types.DurationProto(time.Second)),
+			expected: "1s",
+		},
+		{
+			testName: "xds dns failure refresh max rate",
+			key:      "xds_dns_failure_refresh_max_rate",
+			option:   option.XDSDNSFailureRefreshMaxRate(types.DurationProto(20 * time.Second)),
+			expected: "20s",
+		},
+		{
+			testName: "xds keepalive time zero",
+			key:      "xds_keepalive_time",
+			option:   option.XDSKeepaliveTime(0),
+			expected: nil,
+		},
+		{
+			testName: "xds keepalive time",
+			key:      "xds_keepalive_time",
+			option:   option.XDSKeepaliveTime(60),
+			expected: uint32(60),
+		},
+		{
+			testName: "xds keepalive interval",
+			key:      "xds_keepalive_interval",
+			option:   option.XDSKeepaliveInterval(10),
+			expected: uint32(10),
+		},
+		{
+			testName: "xds keepalive probes",
+			key:      "xds_keepalive_probes",
+			option:   option.XDSKeepaliveProbes(3),
+			expected: uint32(3),
+		},
+		{
+			testName: "xds drain connections on host removal",
+			key:      "xds_drain_connections_on_host_removal",
+			option:   option.XDSDrainConnectionsOnHostRemoval(true),
+			expected: true,
+		},
 		{
 			testName: "localhost v4",
 			key:      "localhost",
@@ -542,7 +596,7 @@ func TestOptions(t *testing.T) {
 			option: option.EnvoyMetricsServiceTCPKeepalive(&networkingAPI.ConnectionPoolSettings_TCPSettings_TcpKeepalive{
 				Time: types.DurationProto(time.Second),
 			}),
-			expected: "{\"tcp_keepalive\":{\"keepalive_time\":{\"value\":1}}}",
+			expected: "{\"tcp_keepalive\":{\"keepalive_time\":1}}",
 		},
 		{
 			testName: "envoy accesslog address empty",
@@ -612,7 +666,7 @@ func TestOptions(t *testing.T) {
 			option: option.EnvoyAccessLogServiceTCPKeepalive(&networkingAPI.ConnectionPoolSettings_TCPSettings_TcpKeepalive{
 				Time: types.DurationProto(time.Second),
 			}),
-			expected: "{\"tcp_keepalive\":{\"keepalive_time\":{\"value\":1}}}",
+			expected: "{\"tcp_keepalive\":{\"keepalive_time\":1}}",
 		},
 		{
 			testName: "envoy stats matcher inclusion prefix nil",
@@ -680,6 +734,12 @@ func TestOptions(t *testing.T) {
 			option:   option.SDSTokenPath("fake"),
 			expected: "fake",
 		},
+		{
+			testName: "sds in process",
+			key:      "sds_in_process",
+			option:   option.SDSInProcess(true),
+			expected: true,
+		},
 	}
 
 	for _, c := range cases {
@@ -15,8 +15,10 @@
 package mesh
 
 import (
+	"fmt"
 	"time"
 
+	"github.com/ghodss/yaml"
 	"github.com/gogo/protobuf/types"
 	"github.com/hashicorp/go-multierror"
 
@@ -118,6 +120,29 @@ func ApplyMeshConfigDefaults(yaml string) (*meshconfig.MeshConfig, error) {
 	return ApplyMeshConfig(yaml, DefaultMeshConfig())
 }
 
+// EndpointTTL returns the endpointTtl of the mesh config YAML, e.g. "5m", and whether it is set. It is
+// the time the proxies keep using the endpoints pushed by Pilot without hearing from it again, 0 disabling
+// the TTL. The MeshConfig API has no field for it yet, so it is read apart and ignored by ApplyMeshConfig.
+func EndpointTTL(yml string) (time.Duration, bool, error) {
+	var cfg struct {
+		EndpointTTL string `json:"endpointTtl"`
+	}
+	if err := yaml.Unmarshal([]byte(yml), &cfg); err != nil {
+		return 0, false, multierror.Prefix(err, "failed to read endpointTtl.")
+	}
+	if cfg.EndpointTTL == "" {
+		return 0, false, nil
+	}
+	ttl, err := time.ParseDuration(cfg.EndpointTTL)
+	if err != nil {
+		return 0, false, multierror.Prefix(err, "invalid endpointTtl.")
+	}
+	if ttl < 0 {
+		return 0, false, fmt.Errorf("invalid endpointTtl %v: must not be negative", ttl)
+	}
+	return ttl, true, nil
+}
+
 // EmptyMeshNetworks configuration with no networks
 func EmptyMeshNetworks() meshconfig.MeshNetworks {
 	return meshconfig.MeshNetworks{
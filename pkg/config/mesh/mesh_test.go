@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 
@@ -58,6 +59,36 @@ defaultConfig:
 	}
 }
 
+func TestEndpointTTL(t *testing.T) {
+	cases := []struct {
+		yaml    string
+		ttl     time.Duration
+		set     bool
+		wantErr bool
+	}{
+		{yaml: "ingressClass: istio", set: false},
+		{yaml: "endpointTtl: 5m\ningressClass: istio", ttl: 5 * time.Minute, set: true},
+		{yaml: "endpointTtl: 0s", ttl: 0, set: true},
+		{yaml: "endpointTtl: 5", wantErr: true},
+		{yaml: "endpointTtl: -1m", wantErr: true},
+	}
+	for _, c := range cases {
+		ttl, set, err := mesh.EndpointTTL(c.yaml)
+		if (err != nil) != c.wantErr {
+			t.Errorf("EndpointTTL(%q) got error %v, want error %v", c.yaml, err, c.wantErr)
+			continue
+		}
+		if ttl != c.ttl || set != c.set {
+			t.Errorf("EndpointTTL(%q) got %v, %v, want %v, %v", c.yaml, ttl, set, c.ttl, c.set)
+		}
+	}
+
+	// The mesh config is still read with the endpointTtl.
+	if _, err := mesh.ApplyMeshConfigDefaults("endpointTtl: 5m\ningressClass: istio"); err != nil {
+		t.Errorf("ApplyMeshConfigDefaults() failed: %v", err)
+	}
+}
+
 func TestApplyMeshNetworksDefaults(t *testing.T) {
 	yml := fmt.Sprintf(`
 networks:
@@ -59,6 +59,17 @@ type ProxyConfig struct {
 	SDSTokenPath        string
 	ControlPlaneAuth    bool
 	DisableReportCalls  bool
+	SDSInProcess        bool
+
+	// Tuning of the xDS connection in the bootstrap, see bootstrap.Config.
+	XDSInitialFetchTimeout      time.Duration
+	XDSDNSFailureRefreshRate    time.Duration
+	XDSDNSFailureRefreshMaxRate time.Duration
+	XDSKeepaliveTime            time.Duration
+	XDSKeepaliveInterval        time.Duration
+	XDSKeepaliveProbes          uint32
+
+	XDSDrainConnectionsOnHostRemoval bool
 }
 
 // NewProxy creates an instance of the proxy control commands
@@ -164,12 +175,27 @@ func (e *envoy) Run(config interface{}, epoch int, abort <-chan error) error {
 			SDSTokenPath:        e.SDSTokenPath,
 			ControlPlaneAuth:    e.ControlPlaneAuth,
 			DisableReportCalls:  e.DisableReportCalls,
+			SDSInProcess:        e.SDSInProcess,
+
+			XDSInitialFetchTimeout:      e.XDSInitialFetchTimeout,
+			XDSDNSFailureRefreshRate:    e.XDSDNSFailureRefreshRate,
+			XDSDNSFailureRefreshMaxRate: e.XDSDNSFailureRefreshMaxRate,
+			XDSKeepaliveTime:            e.XDSKeepaliveTime,
+			XDSKeepaliveInterval:        e.XDSKeepaliveInterval,
+			XDSKeepaliveProbes:          e.XDSKeepaliveProbes,
+
+			XDSDrainConnectionsOnHostRemoval: e.XDSDrainConnectionsOnHostRemoval,
 		}).CreateFileForEpoch(epoch)
 		if err != nil {
 			log.Errora("Failed to generate bootstrap config: ", err)
 			os.Exit(1) // Prevent infinite loop attempting to write the file, let k8s/systemd report
 		}
 		fname = out
+		if snapshot, ok := config.(SnapshotConfig); ok {
+			if err := bootstrapFromSnapshot(fname, snapshot.Path); err != nil {
+				log.Warnf("Failed to bootstrap Envoy from the xDS config snapshot %s: %v", snapshot.Path, err)
+			}
+		}
 	}
 
 	// spin up a new Envoy process
@@ -0,0 +1,442 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/pkg/log"
+)
+
+const (
+	// defaultSnapshotInterval is the default interval between two snapshots of the config of Envoy.
+	defaultSnapshotInterval = 30 * time.Second
+
+	// discoveryDialTimeout is the timeout of the checks of the reachability of the discovery server.
+	discoveryDialTimeout = 2 * time.Second
+)
+
+// ConfigSnapshotOptions configures the snapshot of the xDS config of Envoy.
+type ConfigSnapshotOptions struct {
+	// Path is the file the snapshot is persisted to.
+	Path string
+	// MaxBytes bounds the size of the snapshot. The configs larger than this are not persisted.
+	MaxBytes int
+	// AdminPort is the admin port of Envoy, the config is read from.
+	AdminPort uint32
+	// DiscoveryAddress is the address of the discovery server. Envoy is bootstrapped from the
+	// snapshot if it is unreachable when Envoy starts.
+	DiscoveryAddress string
+	// Interval is the interval between two snapshots, and between two checks of the discovery
+	// server while Envoy runs from the snapshot.
+	Interval time.Duration
+}
+
+// SnapshotConfig is the config of the epochs bootstrapped from a snapshot of the xDS config.
+type SnapshotConfig struct {
+	// Config is the config sent by the watcher.
+	Config interface{}
+	// Path is the snapshot file.
+	Path string
+}
+
+// ConfigSnapshot persists the last acked CDS, EDS, LDS and RDS config of Envoy, so that Envoy can
+// be bootstrapped from it when the discovery server is unreachable at start. Envoy is hot restarted
+// with its regular bootstrap config once the discovery server is reachable again.
+type ConfigSnapshot struct {
+	ConfigSnapshotOptions
+
+	// restart hot restarts Envoy with the config.
+	restart func(interface{})
+	// reachable returns whether the discovery server is reachable.
+	reachable func(address string) bool
+	// fetch returns the response of the admin API of Envoy at the path.
+	fetch func(path string, adminPort uint32) ([]byte, error)
+
+	mu sync.Mutex
+	// started is set once the first config of the watcher is received.
+	started bool
+	// fallback is set while Envoy runs from the snapshot.
+	fallback bool
+	// latest is the latest config of the watcher.
+	latest interface{}
+}
+
+// NewConfigSnapshot creates the snapshot of the xDS config of Envoy, restarting Envoy with restart.
+func NewConfigSnapshot(options ConfigSnapshotOptions, restart func(interface{})) *ConfigSnapshot {
+	if options.Interval <= 0 {
+		options.Interval = defaultSnapshotInterval
+	}
+	return &ConfigSnapshot{
+		ConfigSnapshotOptions: options,
+		restart:               restart,
+		reachable:             isReachable,
+		fetch: func(path string, adminPort uint32) ([]byte, error) {
+			buffer, err := doEnvoyGet(path, adminPort)
+			if err != nil {
+				return nil, err
+			}
+			return buffer.Bytes(), nil
+		},
+	}
+}
+
+// Update restarts Envoy with a new config of the watcher. Envoy is bootstrapped from the snapshot
+// when it starts while the discovery server is unreachable, or while it still runs from the snapshot.
+func (s *ConfigSnapshot) Update(config interface{}) {
+	s.mu.Lock()
+	s.latest = config
+	if !s.started {
+		s.started = true
+		if _, err := os.Stat(s.Path); err == nil && !s.reachable(s.DiscoveryAddress) {
+			log.Warnf("Discovery server %s is unreachable, bootstrapping Envoy from the xDS config snapshot %s",
+				s.DiscoveryAddress, s.Path)
+			s.fallback = true
+		}
+	}
+	if s.fallback {
+		config = SnapshotConfig{Config: config, Path: s.Path}
+	}
+	s.mu.Unlock()
+
+	s.restart(config)
+}
+
+// Run snapshots the config of Envoy periodically, and hot restarts Envoy with its regular bootstrap
+// config once the discovery server is reachable if Envoy runs from the snapshot.
+func (s *ConfigSnapshot) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reconcile()
+		}
+	}
+}
+
+func (s *ConfigSnapshot) reconcile() {
+	s.mu.Lock()
+	if s.fallback {
+		if !s.reachable(s.DiscoveryAddress) {
+			s.mu.Unlock()
+			return
+		}
+		log.Infof("Discovery server %s is reachable, restarting Envoy from the discovery server", s.DiscoveryAddress)
+		s.fallback = false
+		latest := s.latest
+		s.mu.Unlock()
+		s.restart(latest)
+		return
+	}
+	started := s.started
+	s.mu.Unlock()
+
+	if started {
+		if err := s.save(); err != nil {
+			log.Warnf("Failed to snapshot the xDS config of Envoy: %v", err)
+		}
+	}
+}
+
+// save persists the current xDS config of Envoy, unless it is empty or too large.
+func (s *ConfigSnapshot) save() error {
+	configDump, err := s.fetch("config_dump", s.AdminPort)
+	if err != nil {
+		return err
+	}
+	clusters, err := s.fetch("clusters?format=json", s.AdminPort)
+	if err != nil {
+		return err
+	}
+	snapshot, err := buildConfigSnapshot(configDump, clusters)
+	if err != nil {
+		return err
+	}
+	if len(snapshot.Clusters) == 0 && len(snapshot.Listeners) == 0 {
+		// Nothing was received from the discovery server yet, keep the previous snapshot.
+		return nil
+	}
+	out, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if s.MaxBytes > 0 && len(out) > s.MaxBytes {
+		return fmt.Errorf("the snapshot of %d bytes exceeds the limit of %d bytes", len(out), s.MaxBytes)
+	}
+
+	// Write the snapshot atomically, Envoy may be bootstrapped from it at any time.
+	tmp, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err = tmp.Write(out); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+func isReachable(address string) bool {
+	conn, err := net.DialTimeout("tcp", address, discoveryDialTimeout)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// configSnapshot is the static resources of the bootstrap config of Envoy, built from its dynamic
+// resources.
+type configSnapshot struct {
+	Clusters  []map[string]interface{} `json:"clusters"`
+	Listeners []map[string]interface{} `json:"listeners"`
+}
+
+// buildConfigSnapshot builds the static resources equivalent to the dynamic resources of the
+// config dump of Envoy: the EDS clusters are made static with their current endpoints, and the
+// routes of the RDS listeners are inlined. The JSON is handled generically, so that the typed
+// configs of all the filters are preserved.
+func buildConfigSnapshot(configDump, clusters []byte) (*configSnapshot, error) {
+	var dump struct {
+		Configs []struct {
+			DynamicActiveClusters []struct {
+				Cluster map[string]interface{} `json:"cluster"`
+			} `json:"dynamic_active_clusters"`
+			DynamicActiveListeners []struct {
+				Listener map[string]interface{} `json:"listener"`
+			} `json:"dynamic_active_listeners"`
+			DynamicRouteConfigs []struct {
+				RouteConfig map[string]interface{} `json:"route_config"`
+			} `json:"dynamic_route_configs"`
+		} `json:"configs"`
+	}
+	if err := json.Unmarshal(configDump, &dump); err != nil {
+		return nil, fmt.Errorf("failed to parse the config dump: %v", err)
+	}
+	assignments, err := loadAssignments(clusters)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &configSnapshot{Clusters: []map[string]interface{}{}, Listeners: []map[string]interface{}{}}
+	routes := map[string]interface{}{}
+	for _, config := range dump.Configs {
+		for _, r := range config.DynamicRouteConfigs {
+			if name, ok := r.RouteConfig["name"].(string); ok {
+				routes[name] = r.RouteConfig
+			}
+		}
+	}
+	for _, config := range dump.Configs {
+		for _, c := range config.DynamicActiveClusters {
+			if c.Cluster == nil {
+				continue
+			}
+			if c.Cluster["type"] == "EDS" {
+				name, _ := c.Cluster["name"].(string)
+				c.Cluster["type"] = "STATIC"
+				delete(c.Cluster, "eds_cluster_config")
+				c.Cluster["load_assignment"] = loadAssignment(name, assignments[name])
+			}
+			snapshot.Clusters = append(snapshot.Clusters, c.Cluster)
+		}
+		for _, l := range config.DynamicActiveListeners {
+			if l.Listener == nil {
+				continue
+			}
+			if err := inlineRoutes(l.Listener, routes); err != nil {
+				log.Warnf("Skipping listener %v of the xDS config snapshot: %v", l.Listener["name"], err)
+				continue
+			}
+			snapshot.Listeners = append(snapshot.Listeners, l.Listener)
+		}
+	}
+	return snapshot, nil
+}
+
+// inlineRoutes replaces the RDS config of the HTTP connection managers of the listener by the routes.
+func inlineRoutes(listener map[string]interface{}, routes map[string]interface{}) error {
+	chains, _ := listener["filter_chains"].([]interface{})
+	for _, chain := range chains {
+		c, _ := chain.(map[string]interface{})
+		filters, _ := c["filters"].([]interface{})
+		for _, filter := range filters {
+			f, _ := filter.(map[string]interface{})
+			for _, key := range []string{"typed_config", "config"} {
+				config, _ := f[key].(map[string]interface{})
+				rds, ok := config["rds"].(map[string]interface{})
+				if !ok {
+					continue
+				}
+				name, _ := rds["route_config_name"].(string)
+				route, found := routes[name]
+				if !found {
+					return fmt.Errorf("route %q not found", name)
+				}
+				delete(config, "rds")
+				config["route_config"] = route
+			}
+		}
+	}
+	return nil
+}
+
+// hostStatus is the status of an endpoint in the /clusters admin API of Envoy.
+type hostStatus struct {
+	Address      map[string]interface{} `json:"address"`
+	Weight       uint32                 `json:"weight"`
+	Priority     uint32                 `json:"priority"`
+	HealthStatus struct {
+		PendingDynamicRemoval bool `json:"pending_dynamic_removal"`
+	} `json:"health_status"`
+}
+
+// loadAssignments returns the endpoints of the clusters, from the /clusters admin API of Envoy.
+func loadAssignments(clusters []byte) (map[string][]hostStatus, error) {
+	var statuses struct {
+		ClusterStatuses []struct {
+			Name         string       `json:"name"`
+			HostStatuses []hostStatus `json:"host_statuses"`
+		} `json:"cluster_statuses"`
+	}
+	if err := json.Unmarshal(clusters, &statuses); err != nil {
+		return nil, fmt.Errorf("failed to parse the clusters: %v", err)
+	}
+	assignments := map[string][]hostStatus{}
+	for _, c := range statuses.ClusterStatuses {
+		for _, h := range c.HostStatuses {
+			if h.Address != nil && !h.HealthStatus.PendingDynamicRemoval {
+				assignments[c.Name] = append(assignments[c.Name], h)
+			}
+		}
+	}
+	return assignments, nil
+}
+
+// loadAssignment returns the load assignment of a static cluster with the endpoints, by priority.
+// The localities of the endpoints are not exposed by Envoy, so they are not preserved.
+func loadAssignment(name string, hosts []hostStatus) map[string]interface{} {
+	byPriority := map[uint32][]interface{}{}
+	priorities := []uint32{}
+	for _, h := range hosts {
+		if _, f := byPriority[h.Priority]; !f {
+			priorities = append(priorities, h.Priority)
+		}
+		endpoint := map[string]interface{}{
+			"endpoint": map[string]interface{}{"address": h.Address},
+		}
+		if h.Weight > 0 {
+			endpoint["load_balancing_weight"] = h.Weight
+		}
+		byPriority[h.Priority] = append(byPriority[h.Priority], endpoint)
+	}
+	sort.Slice(priorities, func(i, j int) bool { return priorities[i] < priorities[j] })
+
+	endpoints := []interface{}{}
+	for _, priority := range priorities {
+		locality := map[string]interface{}{"lb_endpoints": byPriority[priority]}
+		if priority > 0 {
+			locality["priority"] = priority
+		}
+		endpoints = append(endpoints, locality)
+	}
+	return map[string]interface{}{"cluster_name": name, "endpoints": endpoints}
+}
+
+// The type URLs of the resources of the xDS files of the snapshot.
+const (
+	clusterTypeURL  = "type.googleapis.com/envoy.api.v2.Cluster"
+	listenerTypeURL = "type.googleapis.com/envoy.api.v2.Listener"
+)
+
+// bootstrapFromSnapshot makes the snapshot the xDS source of the JSON bootstrap config file: its
+// clusters and listeners are written to CDS and LDS files next to the bootstrap config, which
+// replace the ADS config of the dynamic resources. They stay dynamic resources, so they don't
+// conflict with the ones of the discovery server once Envoy is restarted with its regular config.
+func bootstrapFromSnapshot(bootstrapPath, snapshotPath string) error {
+	in, err := ioutil.ReadFile(snapshotPath)
+	if err != nil {
+		return err
+	}
+	var snapshot configSnapshot
+	if err = json.Unmarshal(in, &snapshot); err != nil {
+		return fmt.Errorf("failed to parse the snapshot: %v", err)
+	}
+	in, err = ioutil.ReadFile(bootstrapPath)
+	if err != nil {
+		return err
+	}
+	bootstrap := map[string]interface{}{}
+	if err = json.Unmarshal(in, &bootstrap); err != nil {
+		return fmt.Errorf("failed to parse the bootstrap config: %v", err)
+	}
+
+	prefix := strings.TrimSuffix(bootstrapPath, filepath.Ext(bootstrapPath))
+	cdsPath, ldsPath := prefix+"-cds.json", prefix+"-lds.json"
+	if err = writeDiscoveryFile(cdsPath, clusterTypeURL, snapshot.Clusters); err != nil {
+		return err
+	}
+	if err = writeDiscoveryFile(ldsPath, listenerTypeURL, snapshot.Listeners); err != nil {
+		return err
+	}
+	bootstrap["dynamic_resources"] = map[string]interface{}{
+		"cds_config": map[string]interface{}{"path": cdsPath},
+		"lds_config": map[string]interface{}{"path": ldsPath},
+	}
+
+	out, err := json.MarshalIndent(bootstrap, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(bootstrapPath, out, 0644)
+}
+
+// writeDiscoveryFile writes the resources as the discovery response of a filesystem xDS source.
+func writeDiscoveryFile(path, typeURL string, resources []map[string]interface{}) error {
+	typed := make([]map[string]interface{}, 0, len(resources))
+	for _, r := range resources {
+		resource := make(map[string]interface{}, len(r)+1)
+		for k, v := range r {
+			resource[k] = v
+		}
+		resource["@type"] = typeURL
+		typed = append(typed, resource)
+	}
+	out, err := json.Marshal(map[string]interface{}{
+		"version_info": "snapshot",
+		"resources":    typed,
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, out, 0644)
+}
@@ -0,0 +1,206 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testConfigDump = `{
+ "configs": [
+  {
+   "@type": "type.googleapis.com/envoy.admin.v2alpha.ClustersConfigDump",
+   "static_clusters": [{"cluster": {"name": "xds-grpc"}}],
+   "dynamic_active_clusters": [
+    {"version_info": "1", "cluster": {"name": "outbound|80||a.default", "type": "EDS",
+     "eds_cluster_config": {"eds_config": {"ads": {}}, "service_name": "outbound|80||a.default"}}},
+    {"version_info": "1", "cluster": {"name": "outbound|80||b.com", "type": "STRICT_DNS"}}
+   ]
+  },
+  {
+   "@type": "type.googleapis.com/envoy.admin.v2alpha.ListenersConfigDump",
+   "dynamic_active_listeners": [
+    {"version_info": "1", "listener": {"name": "0.0.0.0_80", "filter_chains": [{"filters": [{
+     "name": "envoy.http_connection_manager",
+     "typed_config": {"@type": "type.googleapis.com/envoy.config.filter.network.http_connection_manager.v2.HttpConnectionManager",
+      "rds": {"config_source": {"ads": {}}, "route_config_name": "80"}}}]}]}},
+    {"version_info": "1", "listener": {"name": "0.0.0.0_81", "filter_chains": [{"filters": [{
+     "name": "envoy.http_connection_manager",
+     "config": {"rds": {"config_source": {"ads": {}}, "route_config_name": "81"}}}]}]}}
+   ]
+  },
+  {
+   "@type": "type.googleapis.com/envoy.admin.v2alpha.RoutesConfigDump",
+   "dynamic_route_configs": [{"version_info": "1", "route_config": {"name": "80", "virtual_hosts": []}}]
+  }
+ ]
+}`
+
+const testClusters = `{
+ "cluster_statuses": [
+  {"name": "outbound|80||a.default", "host_statuses": [
+   {"address": {"socket_address": {"address": "10.0.0.1", "port_value": 80}}, "weight": 2},
+   {"address": {"socket_address": {"address": "10.0.0.2", "port_value": 80}}, "priority": 1},
+   {"address": {"socket_address": {"address": "10.0.0.3", "port_value": 80}}, "health_status": {"pending_dynamic_removal": true}}
+  ]}
+ ]
+}`
+
+func decodeJSON(t *testing.T, in string) interface{} {
+	t.Helper()
+	var out interface{}
+	if err := json.Unmarshal([]byte(in), &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestBuildConfigSnapshot(t *testing.T) {
+	snapshot, err := buildConfigSnapshot([]byte(testConfigDump), []byte(testClusters))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ := json.Marshal(snapshot)
+	expected := decodeJSON(t, `{
+ "clusters": [
+  {"name": "outbound|80||a.default", "type": "STATIC", "load_assignment": {"cluster_name": "outbound|80||a.default", "endpoints": [
+   {"lb_endpoints": [{"endpoint": {"address": {"socket_address": {"address": "10.0.0.1", "port_value": 80}}}, "load_balancing_weight": 2}]},
+   {"priority": 1, "lb_endpoints": [{"endpoint": {"address": {"socket_address": {"address": "10.0.0.2", "port_value": 80}}}}]}
+  ]}},
+  {"name": "outbound|80||b.com", "type": "STRICT_DNS"}
+ ],
+ "listeners": [
+  {"name": "0.0.0.0_80", "filter_chains": [{"filters": [{
+   "name": "envoy.http_connection_manager",
+   "typed_config": {"@type": "type.googleapis.com/envoy.config.filter.network.http_connection_manager.v2.HttpConnectionManager",
+    "route_config": {"name": "80", "virtual_hosts": []}}}]}]}
+ ]
+}`)
+	if actual := decodeJSON(t, string(out)); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("got snapshot %s, want %v", out, expected)
+	}
+}
+
+func TestConfigSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	var restarts []interface{}
+	reachable := true
+	newSnapshot := func(maxBytes int) *ConfigSnapshot {
+		s := NewConfigSnapshot(ConfigSnapshotOptions{Path: filepath.Join(dir, "snapshot.json"), MaxBytes: maxBytes},
+			func(config interface{}) { restarts = append(restarts, config) })
+		s.reachable = func(string) bool { return reachable }
+		s.fetch = func(path string, _ uint32) ([]byte, error) {
+			if path == "config_dump" {
+				return []byte(testConfigDump), nil
+			}
+			return []byte(testClusters), nil
+		}
+		return s
+	}
+
+	// Envoy starts from the discovery server, and its config is persisted.
+	s := newSnapshot(0)
+	s.Update("certs")
+	s.reconcile()
+	if !reflect.DeepEqual(restarts, []interface{}{"certs"}) {
+		t.Fatalf("got restarts %v, want the watcher config", restarts)
+	}
+	if _, err = os.Stat(s.Path); err != nil {
+		t.Fatalf("the snapshot was not persisted: %v", err)
+	}
+
+	// The discovery server is unreachable at the next start: Envoy is bootstrapped from the snapshot,
+	// then hot restarted once the discovery server is reachable again.
+	restarts = nil
+	reachable = false
+	s = newSnapshot(0)
+	s.Update("certs")
+	s.reconcile()
+	s.Update("new certs")
+	reachable = true
+	s.reconcile()
+	expected := []interface{}{
+		SnapshotConfig{Config: "certs", Path: s.Path},
+		SnapshotConfig{Config: "new certs", Path: s.Path},
+		"new certs",
+	}
+	if !reflect.DeepEqual(restarts, expected) {
+		t.Errorf("got restarts %v, want %v", restarts, expected)
+	}
+
+	// The configs larger than the limit are not persisted.
+	_ = os.Remove(s.Path)
+	s = newSnapshot(10)
+	s.Update("certs")
+	if err = s.save(); err == nil {
+		t.Errorf("a snapshot larger than the limit was persisted")
+	}
+	if _, err = os.Stat(s.Path); !os.IsNotExist(err) {
+		t.Errorf("got %v for the snapshot larger than the limit, want not found", err)
+	}
+}
+
+func TestBootstrapFromSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	bootstrapPath := filepath.Join(dir, "envoy-rev0.json")
+	snapshotPath := filepath.Join(dir, "snapshot.json")
+	bootstrap := `{"node": {"id": "sidecar"}, "static_resources": {"clusters": [{"name": "xds-grpc"}]},
+ "dynamic_resources": {"ads_config": {"api_type": "GRPC"}, "cds_config": {"ads": {}}, "lds_config": {"ads": {}}}}`
+	snapshot := `{"clusters": [{"name": "a"}], "listeners": [{"name": "l"}]}`
+	if err = ioutil.WriteFile(bootstrapPath, []byte(bootstrap), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(snapshotPath, []byte(snapshot), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = bootstrapFromSnapshot(bootstrapPath, snapshotPath); err != nil {
+		t.Fatal(err)
+	}
+
+	cdsPath, ldsPath := filepath.Join(dir, "envoy-rev0-cds.json"), filepath.Join(dir, "envoy-rev0-lds.json")
+	expected := map[string]string{
+		// The snapshot is not added to the static resources, which the discovery server can't replace.
+		bootstrapPath: `{"node": {"id": "sidecar"}, "static_resources": {"clusters": [{"name": "xds-grpc"}]},
+ "dynamic_resources": {"cds_config": {"path": "` + cdsPath + `"}, "lds_config": {"path": "` + ldsPath + `"}}}`,
+		cdsPath: `{"version_info": "snapshot",
+ "resources": [{"@type": "type.googleapis.com/envoy.api.v2.Cluster", "name": "a"}]}`,
+		ldsPath: `{"version_info": "snapshot",
+ "resources": [{"@type": "type.googleapis.com/envoy.api.v2.Listener", "name": "l"}]}`,
+	}
+	for path, want := range expected {
+		out, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := decodeJSON(t, string(out)); !reflect.DeepEqual(actual, decodeJSON(t, want)) {
+			t.Errorf("got %s for %s, want %s", out, path, want)
+		}
+	}
+}
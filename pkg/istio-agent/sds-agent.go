@@ -19,18 +19,23 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"path"
 	"strings"
 	"time"
 
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/kube"
+	"istio.io/istio/security/pkg/nodeagent/caclient"
 	caClientInterface "istio.io/istio/security/pkg/nodeagent/caclient/interface"
 	citadel "istio.io/istio/security/pkg/nodeagent/caclient/providers/citadel"
 	gca "istio.io/istio/security/pkg/nodeagent/caclient/providers/google"
 	"istio.io/istio/security/pkg/nodeagent/plugin/providers/google/stsclient"
 
 	"istio.io/istio/security/pkg/nodeagent/cache"
+	"istio.io/istio/security/pkg/nodeagent/model"
 	"istio.io/istio/security/pkg/nodeagent/sds"
 	"istio.io/istio/security/pkg/nodeagent/secretfetcher"
+	"istio.io/istio/security/pkg/pki/util"
 	"istio.io/pkg/env"
 	"istio.io/pkg/log"
 )
@@ -56,7 +61,9 @@ import (
 var (
 	caProviderEnv = env.RegisterStringVar(caProvider, "Citadel", "").Get()
 	// TODO: default to same as discovery address
-	caEndpointEnv = env.RegisterStringVar(caEndpoint, "", "").Get()
+	caEndpointEnv  = env.RegisterStringVar(caEndpoint, "", "").Get()
+	caEndpointsEnv = env.RegisterStringVar(caEndpoints, "", "").Get()
+	caLocalityEnv  = env.RegisterStringVar(caLocality, "", "").Get()
 
 	pluginNamesEnv             = env.RegisterStringVar(pluginNames, "", "").Get()
 	enableIngressGatewaySDSEnv = env.RegisterBoolVar(enableIngressGatewaySDS, false, "").Get()
@@ -67,12 +74,26 @@ var (
 	secretRotationIntervalEnv          = env.RegisterDurationVar(SecretRotationInterval, 10*time.Minute, "").Get()
 	staledConnectionRecycleIntervalEnv = env.RegisterDurationVar(staledConnectionRecycleInterval, 5*time.Minute, "").Get()
 	initialBackoffEnv                  = env.RegisterIntVar(InitialBackoff, 10, "").Get()
+	fileMountedCertsEnv                = env.RegisterBoolVar(fileMountedCerts, false, "").Get()
+	fileMountedCertsDirEnv             = env.RegisterStringVar(fileMountedCertsDir, "/etc/certs", "").Get()
+	debugPortEnv                       = env.RegisterIntVar(debugPort, 0, "").Get()
+	maxRetryBackoffEnv                 = env.RegisterDurationVar(maxRetryBackoff, 200*time.Millisecond, "").Get()
+	retryTimeoutEnv                    = env.RegisterDurationVar(retryTimeout, time.Second, "").Get()
+	circuitBreakerFailuresEnv          = env.RegisterIntVar(circuitBreakerFailures, 5, "").Get()
+	circuitBreakerCooldownEnv          = env.RegisterDurationVar(circuitBreakerCooldown, 30*time.Second, "").Get()
+	additionalRootsFileEnv             = env.RegisterStringVar(additionalRootsFile, "", "").Get()
+	outputKeyCertToDirEnv              = env.RegisterStringVar(outputKeyCertToDir, constants.ConfigPathDir, "").Get()
+	localSDSPathEnv                    = env.RegisterStringVar(localSDSPath, LocalSDS, "").Get()
+	eccSigAlgEnv                       = env.RegisterStringVar(eccSigAlg, "", "").Get()
 
 	// Location of a custom-mounted root (for example using Secret)
 	mountedRoot = "/etc/certs/root-cert.pem"
 
 	// Location of K8S CA root.
 	k8sCAPath = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+
+	// The maximum backoff between the attempts to get the bootstrap certificates.
+	bootstrapMaxRetryBackoff = 30 * time.Second
 )
 
 const (
@@ -82,6 +103,14 @@ const (
 	// CA endpoint.
 	caEndpoint = "CA_ADDR"
 
+	// Comma separated CA endpoints of the clusters of the mesh, prefixed with their locality, e.g.
+	// us-east1=istiod.east.example.com:15012. The CSRs are sent to the endpoint closest to the workload,
+	// failing over to the next ones. Overrides CA_ADDR, the endpoints must use the same TLS settings.
+	caEndpoints = "CA_ADDRS"
+
+	// The region/zone/subzone locality of the workload, selecting the closest of the CA_ADDRS.
+	caLocality = "CA_LOCALITY"
+
 	// names of authentication provider's plugins.
 	pluginNames = "PLUGINS"
 
@@ -114,6 +143,54 @@ const (
 	// The environmental variable name for the initial backoff in milliseconds.
 	// example value format like "10"
 	InitialBackoff = "INITIAL_BACKOFF_MSEC"
+
+	// The environmental variable name for serving the certs mounted in a directory, watched for rotations,
+	// instead of getting them from the CA. It eases the migration from the mounted Secrets.
+	fileMountedCerts = "FILE_MOUNTED_CERTS"
+
+	// The environmental variable name for the directory of the mounted certs, with the cert-chain.pem,
+	// key.pem and root-cert.pem files.
+	fileMountedCertsDir = "FILE_MOUNTED_CERTS_DIR"
+
+	// The environmental variable name for the port of the debug server, listening on localhost, disabled if 0.
+	// /debug/certs reports the state of the workload certificate, with a 503 status if it is not valid.
+	debugPort = "SDS_DEBUG_PORT"
+
+	// The environmental variable name for the maximum backoff between the retries of a failed CA request,
+	// which double with a random jitter.
+	// example value format like "200ms"
+	maxRetryBackoff = "CA_MAX_RETRY_BACKOFF"
+
+	// The environmental variable name for the budget of the retries of a failed CA request.
+	// example value format like "1s"
+	retryTimeout = "CA_RETRY_TIMEOUT"
+
+	// The environmental variable name for the consecutive failed CA requests opening the circuit breaker,
+	// failing the requests fast until the cooldown elapsed. Disabled if 0.
+	circuitBreakerFailures = "CA_CIRCUIT_BREAKER_FAILURES"
+
+	// The environmental variable name for the cooldown of the CA circuit breaker.
+	// example value format like "30s"
+	circuitBreakerCooldown = "CA_CIRCUIT_BREAKER_COOLDOWN"
+
+	// The environmental variable name for a file with additional PEM encoded roots trusted by the proxies,
+	// for example mounted from a ConfigMap with the root of the new CA during a CA migration. The file is
+	// watched, and the roots are combined with the mounted root and the root of the CA.
+	additionalRootsFile = "ADDITIONAL_ROOTS_FILE"
+
+	// The environmental variable name for the directory where the key.pem, cert-chain.pem and root-cert.pem
+	// files of the workload are written, for debugging and backward compat. The files are not written if
+	// it is set to empty, for example with a read-only root filesystem.
+	outputKeyCertToDir = "OUTPUT_CERTS"
+
+	// The environmental variable name for the path of the UDS of the in-process SDS server, in a writeable
+	// dir. Each proxy running on the same VM needs a distinct path.
+	localSDSPath = "LOCAL_SDS_PATH"
+
+	// The environmental variable name for the EC signature algorithm of the keys of the workload
+	// certificates, ECDSA for P-256 keys. ECDSA keys cut the CPU of the TLS handshakes, notably on the
+	// gateways. RSA keys are generated if empty.
+	eccSigAlg = "ECC_SIGNATURE_ALGORITHM"
 )
 
 var (
@@ -121,7 +198,7 @@ var (
 	// If the file is missing, the agent will fallback to using mounted certificates if XDS address is secure.
 	JWTPath = "./var/run/secrets/tokens/istio-token"
 
-	// LocalSDS is the location of the in-process SDS server - must be in a writeable dir.
+	// LocalSDS is the default location of the in-process SDS server - must be in a writeable dir.
 	LocalSDS = "/etc/istio/proxy/SDS"
 
 	workloadSdsCacheOptions cache.Options
@@ -137,7 +214,7 @@ var (
 // - root cert to use for connecting to XDS server
 // - CA address, with proper defaults and detection
 type SDSAgent struct {
-	// Location of JWTPath to connect to CA. If empty, SDS is not possible.
+	// Location of JWTPath to connect to CA. If empty, SDS is not possible unless FileMountedCerts is set.
 	// If set SDS will be used - either local or via hostPath.
 	JWTPath string
 
@@ -148,6 +225,10 @@ type SDSAgent struct {
 	// CertPath is set with the location of the certs, or empty if mounted certs are not present.
 	CertsPath string
 
+	// FileMountedCerts is set if the certs of CertsPath are served over SDS, rather than the certs
+	// of the CA.
+	FileMountedCerts bool
+
 	// RequireCerts is set if the agent requires certificates:
 	// - if controlPlaneAuthEnabled is set
 	// - port of discovery server is not 15010 (the plain text default).
@@ -155,6 +236,13 @@ type SDSAgent struct {
 
 	// Expected SAN
 	SAN string
+
+	// LocalSDSPath is the path of the UDS of the in-process SDS server.
+	LocalSDSPath string
+
+	// OutputKeyCertToDir is the directory where the certs of the workload and the trust bundle are written,
+	// or empty if they are only served over SDS.
+	OutputKeyCertToDir string
 }
 
 // NewSDSAgent wraps the logic for a local SDS. It will check if the JWT token required for local SDS is
@@ -166,15 +254,25 @@ type SDSAgent struct {
 // If node agent and JWT are mounted: it indicates user injected a config using hostPath, and will be used.
 //
 func NewSDSAgent(discAddr string, tlsRequired bool) *SDSAgent {
-	ac := &SDSAgent{}
+	ac := &SDSAgent{
+		LocalSDSPath:       localSDSPathEnv,
+		OutputKeyCertToDir: outputKeyCertToDirEnv,
+	}
 
 	discHost, discPort, err := net.SplitHostPort(discAddr)
 	if err != nil {
 		log.Fatala("Invalid discovery address", discAddr, err)
 	}
 
+	// The mounted certs are checked first: serving them over SDS doesn't need a token, and the clusters
+	// without projected token are the ones relying on them.
+	_, err = os.Stat(path.Join(fileMountedCertsDirEnv, constants.KeyFilename))
+	certsMounted := err == nil
+
 	if _, err := os.Stat(JWTPath); err == nil {
 		ac.JWTPath = JWTPath
+	} else if certsMounted && fileMountedCertsEnv {
+		log.Infoa("Missing JWT token, serving the mounted certs in process SDS ", JWTPath)
 	} else {
 		// Can't use in-process SDS.
 		log.Warna("Missing JWT token, can't use in process SDS ", JWTPath, err)
@@ -185,10 +283,11 @@ func NewSDSAgent(discAddr string, tlsRequired bool) *SDSAgent {
 		return ac
 	}
 
-	ac.SDSAddress = "unix:" + LocalSDS
+	ac.SDSAddress = "unix:" + ac.LocalSDSPath
 
-	if _, err := os.Stat("/etc/certs/key.pem"); err == nil {
-		ac.CertsPath = "/etc/certs"
+	if certsMounted {
+		ac.CertsPath = fileMountedCertsDirEnv
+		ac.FileMountedCerts = fileMountedCertsEnv
 	}
 	if tlsRequired {
 		ac.RequireCerts = true
@@ -217,19 +316,17 @@ func NewSDSAgent(discAddr string, tlsRequired bool) *SDSAgent {
 //
 // 3. Monitor mode - watching secret in same namespace ( Ingress)
 //
-// 4. TODO: File watching, for backward compat/migration from mounted secrets.
+// 4. File watching, for backward compat/migration from mounted secrets: the certs of CertsPath are served
+//    and pushed again when rotated, if FILE_MOUNTED_CERTS is set.
 func (conf *SDSAgent) Start(isSidecar bool, podNamespace string) (*sds.Server, error) {
 	applyEnvVars()
 
 	gatewaySdsCacheOptions = workloadSdsCacheOptions
 
-	// Next to the envoy config, writeable dir (mounted as mem)
-	serverOptions.WorkloadUDSPath = LocalSDS
+	// Next to the envoy config by default, writeable dir (mounted as mem)
+	serverOptions.WorkloadUDSPath = conf.LocalSDSPath
 	serverOptions.UseLocalJWT = true
 
-	// TODO: remove the caching, workload has a single cert
-	workloadSecretCache, _ := newSecretCache(serverOptions)
-
 	var gatewaySecretCache *cache.SecretCache
 	if !isSidecar {
 		serverOptions.EnableIngressGatewaySDS = true
@@ -238,6 +335,34 @@ func (conf *SDSAgent) Start(isSidecar bool, podNamespace string) (*sds.Server, e
 		gatewaySecretCache = newIngressSecretCache(podNamespace)
 	}
 
+	if conf.FileMountedCerts {
+		log.Infoa("Using the certs mounted in ", conf.CertsPath, " for SDS")
+		// The mounted certs are served whatever the token, which may be missing.
+		serverOptions.SkipToken = true
+		fileSecretManager, err := cache.NewFileSecretManager(conf.CertsPath, sds.NotifyProxy)
+		if err != nil {
+			return nil, err
+		}
+		return sds.NewServer(serverOptions, fileSecretManager, gatewaySecretCache)
+	}
+
+	trustBundle, err := newTrustBundle(conf.OutputKeyCertToDir)
+	if err != nil {
+		return nil, err
+	}
+	workloadSdsCacheOptions.TrustBundle = trustBundle
+
+	// Kubernetes rotates the projected token, the CA requests use the current one.
+	tokenWatcher, err := cache.NewTokenWatcher(conf.JWTPath)
+	if err != nil {
+		log.Warna("Failed to watch the token, the CA requests use the token of the SDS requests ", err)
+	} else {
+		workloadSdsCacheOptions.TokenWatcher = tokenWatcher
+	}
+
+	// TODO: remove the caching, workload has a single cert
+	workloadSecretCache, _ := newSecretCache(&serverOptions)
+
 	// For sidecar and ingress we need to first get the certificates for the workload.
 	// We'll also save them in files, for backward compat with servers generating files
 	// TODO: use caClient.CSRSign() directly
@@ -250,45 +375,28 @@ func (conf *SDSAgent) Start(isSidecar bool, podNamespace string) (*sds.Server, e
 	if err != nil && fail {
 		log.Fatala("Failed to read token", err)
 	} else {
-		si, err := workloadSecretCache.GenerateSecret(context.Background(), "bootstrap", "default",
-			string(tok))
+		si, err := generateSecret(workloadSecretCache, cache.WorkloadKeyCertResourceName, string(tok), fail)
 		if err != nil {
-			if fail {
-				log.Fatala("Failed to get certificates", err)
-			} else {
-				log.Warna("Failed to get certificate from CA", err)
-			}
+			log.Warna("Failed to get certificate from CA", err)
 		}
-		if si != nil {
+		if si != nil && conf.OutputKeyCertToDir != "" {
 			// For debugging and backward compat - we may not need it long term
 			// The files can be used if an Pilot configured with SDS disabled is used, will generate
 			// file based XDS config instead of SDS.
-			err = ioutil.WriteFile("/etc/istio/proxy/key.pem", si.PrivateKey, 0700)
+			err = ioutil.WriteFile(path.Join(conf.OutputKeyCertToDir, constants.KeyFilename), si.PrivateKey, 0700)
 			if err != nil {
 				log.Fatalf("Failed to write certs: %v", err)
 			}
-			err = ioutil.WriteFile("/etc/istio/proxy/cert-chain.pem", si.CertificateChain, 0700)
+			err = ioutil.WriteFile(path.Join(conf.OutputKeyCertToDir, constants.CertChainFilename),
+				si.CertificateChain, 0700)
 			if err != nil {
 				log.Fatalf("Failed to write certs: %v", err)
 			}
 		}
-		sir, err := workloadSecretCache.GenerateSecret(context.Background(), "bootstrap", "ROOTCA",
-			string(tok))
+		// The trust bundle, including the root of the CA, is written to the output dir when it changes.
+		_, err = generateSecret(workloadSecretCache, cache.RootCertReqResourceName, string(tok), fail)
 		if err != nil {
-			if fail {
-				log.Fatala("Failed to get certificates", err)
-			} else {
-				log.Warna("Failed to get certificate from CA", err)
-			}
-		}
-		if sir != nil {
-			// For debugging and backward compat - we may not need it long term
-			// TODO: we should concatenate this file with the existing root-cert and possibly pilot-generated roots, for
-			// smooth transition across CAs.
-			err = ioutil.WriteFile("/etc/istio/proxy/root-cert.pem", sir.RootCert, 0700)
-			if err != nil {
-				log.Fatalf("Failed to write certs: %v", err)
-			}
+			log.Warna("Failed to get certificate from CA", err)
 		}
 	}
 
@@ -300,8 +408,59 @@ func (conf *SDSAgent) Start(isSidecar bool, podNamespace string) (*sds.Server, e
 	return server, nil
 }
 
+// generateSecret gets the secret of the resource for the bootstrap connection. If the certs are required,
+// it retries with a jittered exponential backoff until the CA issues them, rather than exiting, so that
+// a transient CA outage does not crash loop the proxy.
+func generateSecret(sc *cache.SecretCache, resourceName, token string, required bool) (*model.SecretItem, error) {
+	for retry := int64(1); ; retry++ {
+		si, err := sc.GenerateSecret(context.Background(), "bootstrap", resourceName, token)
+		if err == nil || !required {
+			return si, err
+		}
+		backoff := cache.JitteredBackoff(time.Second, bootstrapMaxRetryBackoff, retry)
+		log.Warnf("Failed to get the %s certificates, retrying in %v: %v", resourceName, backoff, err)
+		time.Sleep(backoff)
+	}
+}
+
+// newTrustBundle creates the trust bundle of the proxy, combining the mounted root, the additional roots
+// and the root of the CA. For debugging and backward compat, the bundle is written to the root-cert.pem
+// file of the output dir, if set, when it changes, for smooth transitions across CAs.
+func newTrustBundle(outputDir string) (*cache.TrustBundle, error) {
+	trustBundle := cache.NewTrustBundle()
+	if outputDir != "" {
+		rootCert := path.Join(outputDir, constants.RootCertFilename)
+		trustBundle.AddHandler(func(bundle []byte) {
+			if err := writeFileAtomically(rootCert, bundle); err != nil {
+				log.Errorf("Failed to write the trust bundle: %v", err)
+			}
+		})
+	}
+	if _, err := os.Stat(mountedRoot); err == nil {
+		if err := trustBundle.WatchFile(cache.MountedRootSource, mountedRoot); err != nil {
+			return nil, err
+		}
+	}
+	if additionalRootsFileEnv != "" {
+		log.Infoa("Trusting the additional roots of ", additionalRootsFileEnv)
+		if err := trustBundle.WatchFile(cache.AdditionalRootSource, additionalRootsFileEnv); err != nil {
+			return nil, err
+		}
+	}
+	return trustBundle, nil
+}
+
+// writeFileAtomically writes the file with a rename, so that its readers never see a partial file.
+func writeFileAtomically(file string, data []byte) error {
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0700); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
 // newSecretCache creates the cache for workload secrets and/or gateway secrets.
-func newSecretCache(serverOptions sds.Options) (workloadSecretCache *cache.SecretCache, caClient caClientInterface.Client) {
+func newSecretCache(serverOptions *sds.Options) (workloadSecretCache *cache.SecretCache, caClient caClientInterface.Client) {
 	ret := &secretfetcher.SecretFetcher{}
 
 	// TODO: get the MC public keys from pilot.
@@ -318,7 +477,8 @@ func newSecretCache(serverOptions sds.Options) (workloadSecretCache *cache.Secre
 		// Use a plugin to an external CA - this has direct support for the K8S JWT token
 		// This is only used if the proper env variables are injected - otherwise the existing Citadel or Istiod will be
 		// used.
-		caClient, err = gca.NewGoogleCAClient(serverOptions.CAEndpoint, true)
+		caClient, err = gca.NewGoogleCAClient(caclient.DialTarget(serverOptions.CAEndpoint), true,
+			caclient.DialOptions()...)
 		serverOptions.PluginNames = []string{"GoogleTokenExchange"}
 	} else {
 		// Determine the default CA.
@@ -343,6 +503,16 @@ func newSecretCache(serverOptions sds.Options) (workloadSecretCache *cache.Secre
 
 		tls := true
 
+		var endpoints []caclient.Endpoint
+		endpoints, err = caclient.ParseEndpoints(caEndpointsEnv)
+		if err != nil {
+			log.Fatala("Invalid "+caEndpoints, err)
+		}
+		if len(endpoints) > 0 {
+			caclient.SortByLocality(endpoints, caLocalityEnv)
+			serverOptions.CAEndpoint = endpoints[0].Address
+		}
+
 		if serverOptions.CAEndpoint == "" {
 			// Determine the default address, based on the presence of Citadel secrets
 			if explicitSecret {
@@ -376,7 +546,14 @@ func newSecretCache(serverOptions sds.Options) (workloadSecretCache *cache.Secre
 		// Will use TLS unless the reserved 15010 port is used ( istiod on an ipsec/secure VPC)
 		// rootCert may be nil - in which case the system roots are used, and the CA is expected to have public key
 		// Otherwise assume the injection has mounted /etc/certs/root-cert.pem
-		caClient, err = citadel.NewCitadelClient(serverOptions.CAEndpoint, tls, rootCert)
+		newClient := func(address string) (caClientInterface.Client, error) {
+			return citadel.NewCitadelClient(caclient.DialTarget(address), tls, rootCert, caclient.DialOptions()...)
+		}
+		if len(endpoints) > 0 {
+			caClient, err = caclient.NewFailoverClient(endpoints, caLocalityEnv, newClient)
+		} else {
+			caClient, err = newClient(serverOptions.CAEndpoint)
+		}
 	}
 
 	if err != nil {
@@ -406,7 +583,7 @@ func newIngressSecretCache(namespace string) (gatewaySecretCache *cache.SecretCa
 	}
 	gSecretFetcher.FallbackSecretName = "gateway-fallback"
 
-	gSecretFetcher.InitWithKubeClientAndNs(cs.CoreV1(), namespace)
+	gSecretFetcher.InitWithKubeClientAndNamespaces(cs, namespace)
 
 	gatewaySecretChan = make(chan struct{})
 	gSecretFetcher.Run(gatewaySecretChan)
@@ -428,6 +605,16 @@ func applyEnvVars() {
 	workloadSdsCacheOptions.RotationInterval = secretRotationIntervalEnv
 
 	serverOptions.RecycleInterval = staledConnectionRecycleIntervalEnv
+	serverOptions.DebugPort = debugPortEnv
 
 	workloadSdsCacheOptions.InitialBackoff = int64(initialBackoffEnv)
+	workloadSdsCacheOptions.MaxRetryBackoff = maxRetryBackoffEnv
+	workloadSdsCacheOptions.RetryTimeout = retryTimeoutEnv
+	workloadSdsCacheOptions.CircuitBreakerFailures = circuitBreakerFailuresEnv
+	workloadSdsCacheOptions.CircuitBreakerCooldown = circuitBreakerCooldownEnv
+	alg, err := util.ParseECSigAlg(eccSigAlgEnv)
+	if err != nil {
+		log.Errorf("Invalid %s, using RSA keys: %v", eccSigAlg, err)
+	}
+	workloadSdsCacheOptions.ECCSigAlg = alg
 }
@@ -0,0 +1,82 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioagent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"istio.io/istio/pkg/config/constants"
+)
+
+func TestNewSDSAgentWithoutJWT(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sds-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, constants.KeyFilename), []byte("fake key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	savedJWTPath, savedCertsDir, savedFileMountedCerts := JWTPath, fileMountedCertsDirEnv, fileMountedCertsEnv
+	defer func() {
+		JWTPath, fileMountedCertsDirEnv, fileMountedCertsEnv = savedJWTPath, savedCertsDir, savedFileMountedCerts
+	}()
+	JWTPath = filepath.Join(dir, "istio-token")
+	fileMountedCertsDirEnv = dir
+
+	cases := []struct {
+		name             string
+		discAddr         string
+		fileMountedCerts bool
+		certsDir         string
+		want             SDSAgent
+	}{
+		{
+			name:             "file-mounted certs",
+			discAddr:         "istiod.istio-system:15012",
+			fileMountedCerts: true,
+			certsDir:         dir,
+			want: SDSAgent{SDSAddress: "unix:" + localSDSPathEnv, CertsPath: dir, FileMountedCerts: true,
+				RequireCerts: true, SAN: "istiod.istio-system"},
+		},
+		{
+			name:     "mounted certs not served",
+			discAddr: "istio-pilot.istio-system:15010",
+			certsDir: dir,
+		},
+		{
+			name:             "no mounted certs",
+			discAddr:         "istio-pilot.istio-system:15010",
+			fileMountedCerts: true,
+			certsDir:         filepath.Join(dir, "missing"),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fileMountedCertsEnv = c.fileMountedCerts
+			fileMountedCertsDirEnv = c.certsDir
+			c.want.LocalSDSPath = localSDSPathEnv
+			c.want.OutputKeyCertToDir = outputKeyCertToDirEnv
+
+			if got := NewSDSAgent(c.discAddr, false); *got != c.want {
+				t.Errorf("NewSDSAgent() = %+v, want %+v", *got, c.want)
+			}
+		})
+	}
+}
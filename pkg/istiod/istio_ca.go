@@ -22,18 +22,30 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"sort"
 	"strings"
 	"time"
 
 	oidc "github.com/coreos/go-oidc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
 
+	envoyv2 "istio.io/istio/pilot/pkg/proxy/envoy/v2"
 	"istio.io/istio/pkg/spiffe"
 	"istio.io/istio/security/pkg/cmd"
+	"istio.io/istio/security/pkg/k8s/controller"
 	"istio.io/istio/security/pkg/pki/ca"
+	"istio.io/istio/security/pkg/pki/util"
 	caserver "istio.io/istio/security/pkg/server/ca"
 	"istio.io/istio/security/pkg/server/ca/authenticate"
 	"istio.io/pkg/env"
@@ -67,6 +79,10 @@ var (
 	localCertDir = env.RegisterStringVar("ROOT_CA_DIR", "./etc/cacerts",
 		"Location of a local or mounted CA root")
 
+	signingKeyURI = env.RegisterStringVar("CA_SIGNING_KEY", "",
+		"URI of the CA signing key in a key storage backend, e.g. k8s://istio-system/cacerts/ca-key.pem, "+
+			"used with the certificates of ROOT_CA_DIR instead of its ca-key.pem.")
+
 	workloadCertTTL = env.RegisterDurationVar("MAX_WORKLOAD_CERT_TTL",
 		cmd.DefaultWorkloadCertTTL,
 		"The TTL of issued workload certificates.")
@@ -79,6 +95,10 @@ var (
 		cmd.DefaultSelfSignedCACertTTL,
 		"The TTL of self-signed CA root certificate.")
 
+	selfSignedCAECSigAlg = env.RegisterStringVar("CITADEL_SELF_SIGNED_CA_EC_SIGNATURE_ALGORITHM", "",
+		"The EC signature algorithm of the key of the self-signed CA root certificate, when it is "+
+			"generated. Only ECDSA, with P-256 keys, is supported. RSA keys are generated if empty.")
+
 	selfSignedRootCertCheckInterval = env.RegisterDurationVar("CITADEL_SELF_SIGNED_ROOT_CERT_CHECK_INTERVAL",
 		cmd.DefaultSelfSignedRootCertCheckInterval,
 		"The interval that self-signed CA checks its root certificate "+
@@ -110,9 +130,73 @@ var (
 
 	audience = env.RegisterStringVar("AUDIENCE", "istio-ca",
 		"Expected audience in the tokens. For backward compat, default is istio-ca.")
+
+	trustedIssuers = env.RegisterStringVar("CA_TRUSTED_JWT_ISSUERS", "",
+		"JSON list of additional OIDC token issuers trusted by the CA, for example the service account issuers "+
+			"of the remote clusters of the mesh: "+
+			`[{"issuer": "https://example.com", "audiences": ["istio-ca"], "jwksUri": "https://example.com/keys"}]. `+
+			"The audiences default to AUDIENCE. The keys are discovered with OIDC, unless jwksUri is set. "+
+			"The identities of the tokens are in the trust domain of the CA, unless trustDomain is set to one "+
+			"of the trustDomainAliases of the mesh config.")
+
+	enableRootCertConfigMap = env.RegisterBoolVar("ENABLE_CA_ROOT_CERT_CONFIGMAP", true,
+		"If true, the root cert of the CA is written to the istio-ca-root-cert ConfigMap "+
+			"of the namespaces, and kept in sync through root cert rotations.")
+
+	rootCertNamespaceSelector = env.RegisterStringVar("CA_ROOT_CERT_NAMESPACE_SELECTOR", "",
+		"Label selector of the namespaces getting the istio-ca-root-cert ConfigMap. All the "+
+			"namespaces if empty.")
+
+	csrSANMatch = env.RegisterBoolVar("CA_CSR_SAN_MATCH", false,
+		"If true, the CSRs requesting identities other than the caller's ones are denied.")
+
+	csrAllowedNamespaces = env.RegisterStringVar("CA_CSR_ALLOWED_NAMESPACES", "",
+		"Comma separated namespaces whose workloads can get certificates from the CA. All the "+
+			"namespaces if empty.")
+
+	csrTTLLimits = env.RegisterStringVar("CA_CSR_TTL_LIMITS", "",
+		"Comma separated TTL limits of the certificates of the namespaces, e.g. foo=1h,bar=30m.")
+
+	csrPolicyAudit = env.RegisterBoolVar("CA_CSR_POLICY_AUDIT", false,
+		"If true, the CSRs denied by the CSR policies are only logged, to audit the policies "+
+			"before enforcing them.")
+
+	subordinateCAIdentities = env.RegisterStringVar("CA_SUBORDINATE_CA_IDENTITIES", "",
+		"Comma separated identities allowed to request subordinate CA certificates for the istiods "+
+			"of remote clusters, e.g. spiffe://cluster.local/ns/istio-system/sa/istio-remote-ca. "+
+			"Subordinate CAs are disabled if empty.")
+
+	subordinateCAMaxPathLen = env.RegisterIntVar("CA_SUBORDINATE_CA_MAX_PATH_LEN", 0,
+		"The number of intermediate CAs allowed below the subordinate CAs.")
+
+	subordinateCATTL = env.RegisterDurationVar("CA_SUBORDINATE_CA_TTL", 365*24*time.Hour,
+		"The TTL of the subordinate CA certificates, capped to the expiration of the CA certificate.")
+
+	subordinateCAConfigMap = env.RegisterStringVar("CA_SUBORDINATE_CA_CONFIGMAP", "istio-subordinate-ca",
+		"Name of the ConfigMap of the istiod namespace persisting the subordinate CA certificates issued "+
+			"and revoked, shared by the replicas of istiod.")
+
+	revocationConfigMap = env.RegisterStringVar("CA_REVOCATION_CONFIGMAP", "",
+		"Name of the ConfigMap of the istiod namespace revoking workload identities and certificates: "+
+			"its identities key lists the identities denied by the CA, its serials key the hexadecimal "+
+			"serial numbers of the revoked certificates. Revocation is disabled if empty.")
+
+	sanTemplatesConfigMap = env.RegisterStringVar("CA_SAN_TEMPLATES_CONFIGMAP", "istio-ca-san-templates",
+		"Name of the ConfigMap of the istiod namespace adding SANs to the certificates of service accounts: "+
+			"its templates key lists one <namespace>/<service account>, or <namespace>/*, per line followed "+
+			"by its DNS or URI SANs, which may use ${trustDomain}, ${namespace} and ${serviceAccount}. "+
+			"SAN templates are disabled if empty.")
+
+	rootRotationConfigMap = env.RegisterStringVar("CA_ROOT_ROTATION_CONFIGMAP", "istio-ca-root-rotation",
+		"Name of the ConfigMap of the istiod namespace driving the root rotation of the self-signed CA: "+
+			"its phase key is idle, distributing or switched. Set by istioctl x root-rotation. The root "+
+			"rotation is disabled if empty.")
 )
 
 const (
+	// rootRotationResyncPeriod is the resync period of the root rotation ConfigMap.
+	rootRotationResyncPeriod = time.Minute
+
 	bearerTokenPrefix = "Bearer "
 	httpAuthHeader    = "authorization"
 	identityTemplate  = "spiffe://%s/ns/%s/sa/%s"
@@ -121,12 +205,25 @@ const (
 type CAOptions struct {
 	// domain to use in SPIFFE identity URLs
 	TrustDomain string
+	// TrustDomainAliases are the trust domains of the other clusters of the mesh. The CA issues the
+	// certificates of their workloads, authenticated by their client certificates or the JWTs of their
+	// trusted issuers, under their trust domain, and denies the identities of any other trust domain.
+	TrustDomainAliases []string
 }
 
-// RunCA will start the cert signing GRPC service on an existing server.
-func RunCA(grpc *grpc.Server, cs kubernetes.Interface, opts *CAOptions) {
+// RunCA will start the cert signing GRPC service on an existing server. It returns nil if the CA
+// functionality is disabled. The root cert is propagated to the namespaces until stop is closed.
+func RunCA(stop <-chan struct{}, grpc *grpc.Server, cs kubernetes.Interface, opts *CAOptions) *caserver.Server {
 	ca := createCA(cs.CoreV1(), opts)
 
+	if enableRootCertConfigMap.Get() {
+		nc, err := controller.NewNamespaceController(ca, cs.CoreV1(), rootCertNamespaceSelector.Get())
+		if err != nil {
+			log.Fatalf("failed to create the root cert namespace controller: %v", err)
+		}
+		nc.Run(stop)
+	}
+
 	iss := trustedIssuer.Get()
 	aud := audience.Get()
 
@@ -136,7 +233,7 @@ func RunCA(grpc *grpc.Server, cs kubernetes.Interface, opts *CAOptions) {
 		if iss == "" {
 			log.Warna("istiod running without access to K8S tokens. Disable the CA functionality",
 				JWTPath)
-			return
+			return nil
 		}
 	} else {
 		tok, err := detectAuthEnv(string(token))
@@ -160,20 +257,63 @@ func RunCA(grpc *grpc.Server, cs kubernetes.Interface, opts *CAOptions) {
 	if startErr != nil {
 		log.Fatalf("failed to create istio ca server: %v", startErr)
 	}
+	var trustDomains []string
+	if len(opts.TrustDomainAliases) > 0 {
+		trustDomains = append([]string{spiffe.GetTrustDomain()}, opts.TrustDomainAliases...)
+	}
+	policies, err := csrPolicies(trustDomains)
+	if err != nil {
+		log.Fatalf("failed to create the CSR policies: %v", err)
+	}
+	caServer.CSRPolicies = append(caServer.CSRPolicies, policies...)
+	if ids := splitList(subordinateCAIdentities.Get()); len(ids) > 0 {
+		store := &configMapSubordinateCAStore{core: cs.CoreV1(), namespace: IstiodNamespace.Get(),
+			name: subordinateCAConfigMap.Get()}
+		caServer.EnableSubordinateCA(caserver.SubordinateCAOptions{
+			Identities: ids,
+			MaxPathLen: subordinateCAMaxPathLen.Get(),
+			TTL:        subordinateCATTL.Get(),
+			Store:      store,
+		})
+		watchSubordinateCAs(stop, store, caServer)
+		log.Infof("Subordinate CAs enabled for %v", ids)
+	}
+	if name := revocationConfigMap.Get(); name != "" {
+		revocations := caserver.NewRevocationList()
+		caServer.EnableRevocation(revocations)
+		watchRevocations(stop, cs.CoreV1(), IstiodNamespace.Get(), name, revocations)
+	}
+	if name := sanTemplatesConfigMap.Get(); name != "" {
+		templates := caserver.NewSANTemplates()
+		caServer.EnableSANTemplates(templates)
+		watchSANTemplates(stop, cs.CoreV1(), IstiodNamespace.Get(), name, templates)
+	}
+	if name := rootRotationConfigMap.Get(); name != "" {
+		watchRootRotation(stop, cs.CoreV1(), IstiodNamespace.Get(), name, ca)
+	}
 
 	// TODO: if not set, parse Istiod's own token (if present) and get the issuer. The same issuer is used
 	// for all tokens - no need to configure twice. The token may also include cluster info to auto-configure
 	// networking properties.
+	var issuers []TrustedIssuer
 	if iss != "" && // issuer set explicitly or extracted from our own JWT
 		(k8sInCluster.Get() != "" || trustedIssuer.Get() != "") { // either set explicitly, or not running in cluster.
 		// Add a custom authenticator using standard JWT validation, if not running in K8S
 		// When running inside K8S - we can use the built-in validator, which also check pod removal (invalidation).
-		oidcAuth, err := newJwtAuthenticator(iss, opts.TrustDomain, aud)
+		issuers = append(issuers, TrustedIssuer{Issuer: iss, Audiences: []string{aud}})
+	}
+	additionalIssuers, err := parseTrustedIssuers(trustedIssuers.Get(), aud, opts.TrustDomainAliases)
+	if err != nil {
+		log.Fatalf("invalid CA_TRUSTED_JWT_ISSUERS: %v", err)
+	}
+	issuers = append(issuers, additionalIssuers...)
+	if len(issuers) > 0 {
+		oidcAuth, err := newJwtAuthenticator(issuers, opts.TrustDomain)
 		if err == nil {
 			caServer.Authenticators = append(caServer.Authenticators, oidcAuth)
 			log.Infoa("Using out-of-cluster JWT authentication")
 		} else {
-			log.Infoa("K8S token doesn't support OIDC, using only in-cluster auth")
+			log.Infoa("K8S token doesn't support OIDC, using only in-cluster auth: ", err)
 		}
 	}
 
@@ -184,28 +324,356 @@ func RunCA(grpc *grpc.Server, cs kubernetes.Interface, opts *CAOptions) {
 		log.Warnf("Failed to start GRPC server with error: %v", serverErr)
 	}
 	log.Info("Istiod CA has started")
+	return caServer
+}
+
+// csrPolicies returns the CSR policies configured by the CA_CSR_* environment variables, and the
+// trust domain policy if the trust domains are restricted.
+func csrPolicies(trustDomains []string) ([]caserver.CSRPolicy, error) {
+	var policies []caserver.CSRPolicy
+	if len(trustDomains) > 0 {
+		policies = append(policies, caserver.NewTrustDomainCSRPolicy(trustDomains))
+	}
+	if csrSANMatch.Get() {
+		policies = append(policies, caserver.NewSANMatchCSRPolicy())
+	}
+	if namespaces := csrAllowedNamespaces.Get(); namespaces != "" {
+		policies = append(policies, caserver.NewNamespaceCSRPolicy(splitList(namespaces)))
+	}
+	if csrTTLLimits.Get() != "" {
+		limits, err := caserver.ParseTTLLimits(csrTTLLimits.Get())
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, caserver.NewTTLLimitCSRPolicy(limits))
+	}
+	if csrPolicyAudit.Get() {
+		for i, p := range policies {
+			policies[i] = caserver.NewAuditCSRPolicy(p)
+		}
+	}
+	return policies, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList returns the non-empty elements of a comma separated list.
+func splitList(list string) []string {
+	var out []string
+	for _, e := range strings.Split(list, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// AddCAHealthCheck serves the health of the CA on /ca/health, and makes istiod not ready while
+// the CA can't sign certificates.
+func (s *Server) AddCAHealthCheck(caServer *caserver.Server) {
+	s.mux.HandleFunc("/ca/health", caServer.HealthHandler)
+	s.EnvoyXdsServer.AddReadinessCheck("ca", caServer.CheckHealth)
+}
+
+// AddCATrustBundle serves the trust bundle of the CA, all its active roots, on /ca/trustbundle.
+func (s *Server) AddCATrustBundle(caServer *caserver.Server) {
+	s.mux.HandleFunc("/ca/trustbundle", caServer.TrustBundleHandler)
+}
+
+// AddCACRL serves the CRL of the CA on /ca/crl.
+func (s *Server) AddCACRL(caServer *caserver.Server) {
+	s.mux.HandleFunc("/ca/crl", caServer.CRLHandler)
+}
+
+// AddCARootRotation serves the root rotation report of the CA on /ca/rootrotation, with the proxies
+// connected to istiod, for istioctl to verify the distribution of the new root.
+func (s *Server) AddCARootRotation(caServer *caserver.Server) {
+	s.mux.HandleFunc("/ca/rootrotation", caServer.RootRotationHandler(func() []caserver.ConnectedProxy {
+		var proxies []caserver.ConnectedProxy
+		for id, addresses := range envoyv2.ProxyAddresses() {
+			proxies = append(proxies, caserver.ConnectedProxy{ID: id, Addresses: addresses})
+		}
+		sort.Slice(proxies, func(i, j int) bool {
+			return proxies[i].ID < proxies[j].ID
+		})
+		return proxies
+	}))
+}
+
+// watchRevocations keeps the revocation list in sync with the revocation ConfigMap until stop is
+// closed. Nothing is revoked while the ConfigMap doesn't exist.
+func watchRevocations(stop <-chan struct{}, core corev1.CoreV1Interface, namespace, name string,
+	revocations *caserver.RevocationList) {
+	watchConfigMap(stop, core, namespace, name, 0, func(cm *v1.ConfigMap) {
+		identities := strings.Fields(cm.Data["identities"])
+		if err := revocations.Update(identities, strings.Fields(cm.Data["serials"])); err != nil {
+			log.Errorf("invalid revocation ConfigMap %s/%s, keeping the previous revocations: %v", namespace, name, err)
+			return
+		}
+		log.Infof("Revoked identities updated from the ConfigMap %s/%s: %v", namespace, name, identities)
+	}, func() {
+		_ = revocations.Update(nil, nil)
+		log.Infof("Revocation ConfigMap %s/%s deleted, nothing is revoked", namespace, name)
+	})
+}
+
+// subordinateCARecordsKey is the key of the JSON subordinate CA records in their ConfigMap.
+const subordinateCARecordsKey = "records"
+
+// configMapSubordinateCAStore persists the subordinate CA records in a ConfigMap. The records saved
+// concurrently by the replicas of istiod are merged, the updates being retried on conflict.
+type configMapSubordinateCAStore struct {
+	core      corev1.CoreV1Interface
+	namespace string
+	name      string
+}
+
+// Save merges the record into the records of the ConfigMap, which is created if it doesn't exist.
+func (s *configMapSubordinateCAStore) Save(record caserver.SubordinateCARecord) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := s.core.ConfigMaps(s.namespace).Get(s.name, metav1.GetOptions{})
+		notFound := errors.IsNotFound(err)
+		if notFound {
+			cm = &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: s.name, Namespace: s.namespace}}
+		} else if err != nil {
+			return err
+		}
+		records, err := parseSubordinateCARecords(cm)
+		if err != nil {
+			return err
+		}
+		bySerial := make(map[string]*caserver.SubordinateCARecord, len(records)+1)
+		for _, r := range records {
+			caserver.MergeSubordinateCARecord(bySerial, r)
+		}
+		caserver.MergeSubordinateCARecord(bySerial, record)
+		records = make([]caserver.SubordinateCARecord, 0, len(bySerial))
+		for _, r := range bySerial {
+			records = append(records, *r)
+		}
+		sort.Slice(records, func(i, j int) bool {
+			return records[i].SerialNumber < records[j].SerialNumber
+		})
+		out, err := json.Marshal(records)
+		if err != nil {
+			return err
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[subordinateCARecordsKey] = string(out)
+
+		if !notFound {
+			_, err = s.core.ConfigMaps(s.namespace).Update(cm)
+			return err
+		}
+		_, err = s.core.ConfigMaps(s.namespace).Create(cm)
+		if errors.IsAlreadyExists(err) {
+			// Created by another replica in the meantime, merge into its records.
+			return errors.NewConflict(v1.Resource("configmaps"), s.name, err)
+		}
+		return err
+	})
+}
+
+// parseSubordinateCARecords returns the subordinate CA records of the ConfigMap.
+func parseSubordinateCARecords(cm *v1.ConfigMap) ([]caserver.SubordinateCARecord, error) {
+	var records []caserver.SubordinateCARecord
+	if data := cm.Data[subordinateCARecordsKey]; data != "" {
+		if err := json.Unmarshal([]byte(data), &records); err != nil {
+			return nil, fmt.Errorf("invalid subordinate CA records in the ConfigMap %s/%s: %v", cm.Namespace, cm.Name, err)
+		}
+	}
+	return records, nil
+}
+
+// watchSubordinateCAs loads the subordinate CA records persisted in the ConfigMap of the store, and
+// keeps merging the ones saved by the other replicas until stop is closed. Deleting the ConfigMap
+// doesn't undo the revocations known to the CA.
+func watchSubordinateCAs(stop <-chan struct{}, store *configMapSubordinateCAStore, caServer *caserver.Server) {
+	update := func(cm *v1.ConfigMap) {
+		records, err := parseSubordinateCARecords(cm)
+		if err != nil {
+			log.Errorf("%v, keeping the previous subordinate CAs", err)
+			return
+		}
+		caServer.UpdateSubordinateCAs(records)
+	}
+	// The revocations are loaded before the CA serves any request.
+	cm, err := store.core.ConfigMaps(store.namespace).Get(store.name, metav1.GetOptions{})
+	if err == nil {
+		update(cm)
+	} else if !errors.IsNotFound(err) {
+		log.Errorf("failed to load the subordinate CAs from the ConfigMap %s/%s: %v", store.namespace, store.name, err)
+	}
+	watchConfigMap(stop, store.core, store.namespace, store.name, 0, update, func() {
+		log.Warnf("Subordinate CA ConfigMap %s/%s deleted, the revocations are only kept in memory",
+			store.namespace, store.name)
+	})
+}
+
+// watchSANTemplates keeps the SAN templates in sync with the SAN templates ConfigMap until stop is
+// closed. No SAN is added while the ConfigMap doesn't exist.
+func watchSANTemplates(stop <-chan struct{}, core corev1.CoreV1Interface, namespace, name string,
+	templates *caserver.SANTemplates) {
+	watchConfigMap(stop, core, namespace, name, 0, func(cm *v1.ConfigMap) {
+		parsed, err := caserver.ParseSANTemplates(cm.Data["templates"])
+		if err == nil {
+			err = templates.Update(parsed)
+		}
+		if err != nil {
+			log.Errorf("invalid SAN templates ConfigMap %s/%s, keeping the previous templates: %v", namespace, name, err)
+			return
+		}
+		log.Infof("SAN templates of %d service accounts updated from the ConfigMap %s/%s", len(parsed), namespace, name)
+	}, func() {
+		_ = templates.Update(nil)
+		log.Infof("SAN templates ConfigMap %s/%s deleted, no SAN is added", namespace, name)
+	})
+}
+
+// watchRootRotation moves the root rotation of the CA to the phase of the root rotation ConfigMap
+// until stop is closed. The ConfigMap is resynced, to retry the phases failing to apply. Deleting
+// the ConfigMap doesn't change the phase.
+func watchRootRotation(stop <-chan struct{}, core corev1.CoreV1Interface, namespace, name string, istioCA *ca.IstioCA) {
+	watchConfigMap(stop, core, namespace, name, rootRotationResyncPeriod, func(cm *v1.ConfigMap) {
+		phase, err := ca.ParseRootRotationPhase(cm.Data["phase"])
+		if err != nil {
+			log.Errorf("invalid root rotation ConfigMap %s/%s: %v", namespace, name, err)
+			return
+		}
+		if err := istioCA.SetRootRotationPhase(phase); err != nil {
+			log.Errorf("failed to move the root rotation to the %s phase: %v", phase, err)
+		}
+	}, func() {})
+}
+
+// watchConfigMap calls update with the ConfigMap when it is added, updated or resynced every resync
+// period if not zero, and deleted when it is deleted, until stop is closed.
+func watchConfigMap(stop <-chan struct{}, core corev1.CoreV1Interface, namespace, name string,
+	resync time.Duration, update func(cm *v1.ConfigMap), deleted func()) {
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	_, informer := cache.NewInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return core.ConfigMaps(namespace).List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return core.ConfigMaps(namespace).Watch(options)
+		},
+	}, &v1.ConfigMap{}, resync, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if cm, ok := obj.(*v1.ConfigMap); ok {
+				update(cm)
+			}
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			if cm, ok := newObj.(*v1.ConfigMap); ok {
+				update(cm)
+			}
+		},
+		DeleteFunc: func(interface{}) {
+			deleted()
+		},
+	})
+	go informer.Run(stop)
+}
+
+// TrustedIssuer is an issuer of the JWTs authenticated by the CA.
+type TrustedIssuer struct {
+	// Issuer is the iss claim of the tokens, and the OIDC discovery URL of the keys.
+	Issuer string `json:"issuer"`
+	// Audiences are the accepted aud claims of the tokens, at least one is required.
+	Audiences []string `json:"audiences,omitempty"`
+	// JwksURI overrides the URL of the keys, which are not discovered with OIDC if set.
+	JwksURI string `json:"jwksUri,omitempty"`
+	// TrustDomain is the trust domain of the identities of the callers authenticated by the tokens,
+	// one of the trust domain aliases of the CA for the issuer of a remote cluster. Defaults to the
+	// trust domain of the CA.
+	TrustDomain string `json:"trustDomain,omitempty"`
+}
+
+// parseTrustedIssuers parses a JSON list of trusted issuers, whose audiences default to the given
+// audience. Their trust domain must be one of the trust domain aliases.
+func parseTrustedIssuers(issuers string, defaultAudience string, trustDomainAliases []string) ([]TrustedIssuer, error) {
+	if strings.TrimSpace(issuers) == "" {
+		return nil, nil
+	}
+	var out []TrustedIssuer
+	if err := json.Unmarshal([]byte(issuers), &out); err != nil {
+		return nil, fmt.Errorf("failed to parse the trusted issuers: %v", err)
+	}
+	seen := map[string]bool{}
+	for i, iss := range out {
+		if iss.Issuer == "" {
+			return nil, fmt.Errorf("trusted issuer %d has no issuer", i)
+		}
+		if seen[iss.Issuer] {
+			return nil, fmt.Errorf("duplicate trusted issuer %s", iss.Issuer)
+		}
+		seen[iss.Issuer] = true
+		if iss.TrustDomain != "" && !contains(trustDomainAliases, iss.TrustDomain) {
+			return nil, fmt.Errorf("the trust domain %s of trusted issuer %s is not a trust domain alias",
+				iss.TrustDomain, iss.Issuer)
+		}
+		if len(iss.Audiences) == 0 {
+			out[i].Audiences = []string{defaultAudience}
+		}
+	}
+	return out, nil
+}
+
+type issuerVerifier struct {
+	verifier  *oidc.IDTokenVerifier
+	audiences []string
+	// trustDomain is the trust domain of the identities of the tokens, the one of the authenticator if empty.
+	trustDomain string
 }
 
 type jwtAuthenticator struct {
-	provider    *oidc.Provider
-	verifier    *oidc.IDTokenVerifier
+	// verifiers verify the tokens of each trusted issuer, keyed by issuer.
+	verifiers   map[string]*issuerVerifier
 	trustDomain string
 }
 
 // newJwtAuthenticator is used when running istiod outside of a cluster, to validate the tokens using OIDC
 // K8S is created with --service-account-issuer, service-account-signing-key-file and service-account-api-audiences
-// which enable OIDC.
-func newJwtAuthenticator(iss string, trustDomain, audience string) (*jwtAuthenticator, error) {
-	provider, err := oidc.NewProvider(context.Background(), iss)
-	if err != nil {
-		return nil, fmt.Errorf("running in cluster with K8S tokens, but failed to initialize %s %s", iss, err)
-	}
-
-	return &jwtAuthenticator{
+// which enable OIDC. The tokens of several issuers are accepted, for meshes spanning clusters with different
+// service account issuers. The issuers failing to initialize are skipped.
+func newJwtAuthenticator(issuers []TrustedIssuer, trustDomain string) (*jwtAuthenticator, error) {
+	j := &jwtAuthenticator{
 		trustDomain: trustDomain,
-		provider:    provider,
-		verifier:    provider.Verifier(&oidc.Config{ClientID: audience}),
-	}, nil
+		verifiers:   map[string]*issuerVerifier{},
+	}
+	for _, iss := range issuers {
+		// The audiences are checked by Authenticate, the verifier only accepts a single client ID.
+		config := &oidc.Config{SkipClientIDCheck: true}
+		var verifier *oidc.IDTokenVerifier
+		if iss.JwksURI != "" {
+			verifier = oidc.NewVerifier(iss.Issuer, oidc.NewRemoteKeySet(context.Background(), iss.JwksURI), config)
+		} else {
+			provider, err := oidc.NewProvider(context.Background(), iss.Issuer)
+			if err != nil {
+				log.Warnf("failed to initialize the OIDC provider of the trusted issuer %s: %v", iss.Issuer, err)
+				continue
+			}
+			verifier = provider.Verifier(config)
+		}
+		j.verifiers[iss.Issuer] = &issuerVerifier{verifier: verifier, audiences: iss.Audiences, trustDomain: iss.TrustDomain}
+		log.Infof("Trusting the JWTs of %s for audiences %v", iss.Issuer, iss.Audiences)
+	}
+	if len(j.verifiers) == 0 {
+		return nil, fmt.Errorf("failed to initialize the trusted issuers %v", issuers)
+	}
+	return j, nil
 }
 
 // Authenticate - based on the old OIDC authenticator for mesh expansion.
@@ -215,10 +683,23 @@ func (j *jwtAuthenticator) Authenticate(ctx context.Context) (*authenticate.Call
 		return nil, fmt.Errorf("ID token extraction error: %v", err)
 	}
 
-	idToken, err := j.verifier.Verify(context.Background(), bearerToken)
+	// The issuer is verified by the verifier of the issuer.
+	iss, err := tokenIssuer(bearerToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the ID token (error %v)", err)
+	}
+	v, f := j.verifiers[iss]
+	if !f {
+		return nil, fmt.Errorf("untrusted issuer %q", iss)
+	}
+	idToken, err := v.verifier.Verify(context.Background(), bearerToken)
 	if err != nil {
 		return nil, fmt.Errorf("failed to verify the ID token (error %v)", err)
 	}
+	if !hasAudience(idToken.Audience, v.audiences) {
+		return nil, fmt.Errorf("audiences %v of the ID token don't match the expected audiences %v of issuer %s",
+			idToken.Audience, v.audiences, iss)
+	}
 
 	// for GCP-issued JWT, the service account is in the "email" field
 	sa := &jwtPayload{}
@@ -226,16 +707,20 @@ func (j *jwtAuthenticator) Authenticate(ctx context.Context) (*authenticate.Call
 	if err := idToken.Claims(&sa); err != nil {
 		return nil, fmt.Errorf("failed to extract email field from ID token: %v", err)
 	}
-	if !strings.HasPrefix(sa.Sub, "system:serviceaccount") {
+	parts := strings.Split(sa.Sub, ":")
+	if len(parts) != 4 || parts[0] != "system" || parts[1] != "serviceaccount" || parts[2] == "" || parts[3] == "" {
 		return nil, fmt.Errorf("invalid sub %v", sa.Sub)
 	}
-	parts := strings.Split(sa.Sub, ":")
 	ns := parts[2]
 	ksa := parts[3]
+	trustDomain := j.trustDomain
+	if v.trustDomain != "" {
+		trustDomain = v.trustDomain
+	}
 
 	return &authenticate.Caller{
 		AuthSource: authenticate.AuthSourceIDToken,
-		Identities: []string{fmt.Sprintf(identityTemplate, j.trustDomain, ns, ksa)},
+		Identities: []string{fmt.Sprintf(identityTemplate, trustDomain, ns, ksa)},
 	}, nil
 
 }
@@ -303,6 +788,37 @@ func detectAuthEnv(jwt string) (*jwtPayload, error) {
 	return structuredPayload, nil
 }
 
+// tokenIssuer returns the iss claim of a JWT, without verifying it.
+func tokenIssuer(jwt string) (string, error) {
+	jwtSplit := strings.Split(jwt, ".")
+	if len(jwtSplit) != 3 {
+		return "", fmt.Errorf("invalid JWT parts")
+	}
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(jwtSplit[1], "="))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode jwt: %v", err)
+	}
+	payload := struct {
+		Iss string `json:"iss"`
+	}{}
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		return "", fmt.Errorf("failed to unmarshal jwt: %v", err)
+	}
+	return payload.Iss, nil
+}
+
+// hasAudience returns true if one of the audiences of a token is expected.
+func hasAudience(audiences, expected []string) bool {
+	for _, a := range audiences {
+		for _, e := range expected {
+			if a == e {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (j jwtAuthenticator) AuthenticatorType() string {
 	return authenticate.IDTokenAuthenticatorType
 }
@@ -312,6 +828,12 @@ func createCA(client corev1.CoreV1Interface, opts *CAOptions) *ca.IstioCA {
 	var err error
 
 	signingKeyFile := path.Join(localCertDir.Get(), "ca-key.pem")
+	pluggedCert := true
+	if signingKeyURI.Get() != "" {
+		signingKeyFile = signingKeyURI.Get()
+	} else if _, err := os.Stat(signingKeyFile); err != nil {
+		pluggedCert = false
+	}
 
 	// If not found, will default to ca-cert.pem. May contain multiple roots.
 	rootCertFile := path.Join(localCertDir.Get(), "root-cert.pem")
@@ -321,7 +843,7 @@ func createCA(client corev1.CoreV1Interface, opts *CAOptions) *ca.IstioCA {
 		rootCertFile = ""
 	}
 
-	if _, err := os.Stat(signingKeyFile); err != nil {
+	if !pluggedCert {
 		// The user-provided certs are missing - create a self-signed cert.
 
 		log.Info("Use self-signed certificate as the CA certificate")
@@ -331,6 +853,10 @@ func createCA(client corev1.CoreV1Interface, opts *CAOptions) *ca.IstioCA {
 		defer cancel()
 		// rootCertFile will be added to "ca-cert.pem".
 
+		ecSigAlg, err := util.ParseECSigAlg(selfSignedCAECSigAlg.Get())
+		if err != nil {
+			log.Fatalf("invalid CITADEL_SELF_SIGNED_CA_EC_SIGNATURE_ALGORITHM: %v", err)
+		}
 		// readSigningCertOnly set to false - it doesn't seem to be used in Citadel, nor do we have a way
 		// to set it only for one job.
 		caOpts, err = ca.NewSelfSignedIstioCAOptions(ctx,
@@ -338,7 +864,7 @@ func createCA(client corev1.CoreV1Interface, opts *CAOptions) *ca.IstioCA {
 			selfSignedRootCertCheckInterval.Get(), workloadCertTTL.Get(),
 			maxWorkloadCertTTL.Get(), opts.TrustDomain, true,
 			IstiodNamespace.Get(), -1, client, rootCertFile,
-			enableJitterForRootCertRotator.Get())
+			enableJitterForRootCertRotator.Get(), ecSigAlg)
 		if err != nil {
 			log.Fatalf("Failed to create a self-signed Citadel (error: %v)", err)
 		}
@@ -363,7 +889,7 @@ func createCA(client corev1.CoreV1Interface, opts *CAOptions) *ca.IstioCA {
 		log.Errorf("Failed to create an Citadel (error: %v)", err)
 	}
 
-	// TODO: provide an endpoint returning all the roots. SDS can only pull a single root in current impl.
+	// All the roots, including the roots rotated out, are served by the CA server on /ca/trustbundle.
 	// ca.go saves or uses the secret, but also writes to the configmap "istio-security", under caTLSRootCert
 
 	// rootCertRotatorChan channel accepts signals to stop root cert rotator for
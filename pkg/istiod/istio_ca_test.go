@@ -0,0 +1,326 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istiod
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	caserver "istio.io/istio/security/pkg/server/ca"
+)
+
+func TestConfigMapSubordinateCAStore(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	store := &configMapSubordinateCAStore{core: client.CoreV1(), namespace: "istio-system", name: "istio-subordinate-ca"}
+
+	notAfter := time.Unix(2000, 0).UTC()
+	revocationTime := time.Unix(1000, 0).UTC()
+	for _, record := range []caserver.SubordinateCARecord{
+		{SerialNumber: "b2", Cluster: "remote-2", NotAfter: notAfter},
+		{SerialNumber: "a1", Cluster: "remote-1", Identities: []string{"spiffe://cluster.local/ns/istio-system/sa/ca"},
+			NotAfter: notAfter},
+		{SerialNumber: "a1", Revoked: true, RevocationTime: revocationTime},
+	} {
+		if err := store.Save(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("istio-system").Get("istio-subordinate-ca", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := parseSubordinateCARecords(cm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []caserver.SubordinateCARecord{
+		{SerialNumber: "a1", Cluster: "remote-1", Identities: []string{"spiffe://cluster.local/ns/istio-system/sa/ca"},
+			NotAfter: notAfter, Revoked: true, RevocationTime: revocationTime},
+		{SerialNumber: "b2", Cluster: "remote-2", NotAfter: notAfter},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("got persisted records %v, expected %v", records, expected)
+	}
+
+	cm.Data[subordinateCARecordsKey] = "not json"
+	if _, err := parseSubordinateCARecords(cm); err == nil {
+		t.Error("expected an error for invalid records")
+	}
+}
+
+func TestParseTrustedIssuers(t *testing.T) {
+	aliases := []string{"remote.example.com"}
+	cases := []struct {
+		name    string
+		issuers string
+		want    []TrustedIssuer
+		wantErr string
+	}{
+		{name: "none", issuers: " "},
+		{
+			name:    "default audience",
+			issuers: `[{"issuer": "https://a.example.com"}]`,
+			want:    []TrustedIssuer{{Issuer: "https://a.example.com", Audiences: []string{"istio-ca"}}},
+		},
+		{
+			name: "audiences, keys and trust domain",
+			issuers: `[{"issuer": "https://a.example.com", "audiences": ["aud-a"]},
+				{"issuer": "https://b.example.com", "jwksUri": "https://b.example.com/keys", "trustDomain": "remote.example.com"}]`,
+			want: []TrustedIssuer{
+				{Issuer: "https://a.example.com", Audiences: []string{"aud-a"}},
+				{Issuer: "https://b.example.com", Audiences: []string{"istio-ca"}, JwksURI: "https://b.example.com/keys",
+					TrustDomain: "remote.example.com"},
+			},
+		},
+		{name: "malformed", issuers: `{"issuer": "https://a.example.com"}`, wantErr: "failed to parse"},
+		{name: "no issuer", issuers: `[{"audiences": ["aud-a"]}]`, wantErr: "has no issuer"},
+		{
+			name:    "duplicate issuer",
+			issuers: `[{"issuer": "https://a.example.com"}, {"issuer": "https://a.example.com"}]`,
+			wantErr: "duplicate trusted issuer",
+		},
+		{
+			name:    "trust domain not an alias",
+			issuers: `[{"issuer": "https://a.example.com", "trustDomain": "other.example.com"}]`,
+			wantErr: "is not a trust domain alias",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseTrustedIssuers(c.issuers, "istio-ca", aliases)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("parseTrustedIssuers() => %v, want error %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("parseTrustedIssuers() => %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestTokenIssuer(t *testing.T) {
+	payload := func(claims string) string {
+		return "header." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".signature"
+	}
+	cases := []struct {
+		name    string
+		jwt     string
+		want    string
+		wantErr bool
+	}{
+		{name: "issuer", jwt: payload(`{"iss": "https://a.example.com", "sub": "s"}`), want: "https://a.example.com"},
+		{name: "padded", jwt: "header." + base64.URLEncoding.EncodeToString([]byte(`{"iss":"a"}`)) + ".signature", want: "a"},
+		{name: "no issuer", jwt: payload(`{"sub": "s"}`)},
+		{name: "parts", jwt: "header.payload", wantErr: true},
+		{name: "not base64", jwt: "header.!!.signature", wantErr: true},
+		{name: "not json", jwt: payload("iss"), wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := tokenIssuer(c.jwt)
+			if (err != nil) != c.wantErr || got != c.want {
+				t.Errorf("tokenIssuer() => %q, %v, want %q (error: %v)", got, err, c.want, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestHasAudience(t *testing.T) {
+	cases := []struct {
+		name      string
+		audiences []string
+		expected  []string
+		want      bool
+	}{
+		{"match", []string{"istio-ca"}, []string{"istio-ca"}, true},
+		{"one of several", []string{"api", "istio-ca"}, []string{"other", "istio-ca"}, true},
+		{"mismatch", []string{"api"}, []string{"istio-ca"}, false},
+		{"no audience", nil, []string{"istio-ca"}, false},
+		{"nothing expected", []string{"istio-ca"}, nil, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := hasAudience(c.audiences, c.expected); got != c.want {
+				t.Errorf("hasAudience(%v, %v) => %v, want %v", c.audiences, c.expected, got, c.want)
+			}
+		})
+	}
+}
+
+// testIssuer signs the JWTs of an issuer, and serves its keys.
+type testIssuer struct {
+	key    *rsa.PrivateKey
+	server *httptest.Server
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &key.PublicKey, KeyID: "key", Algorithm: string(jose.RS256), Use: "sig"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(keys)
+	}))
+	return &testIssuer{key: key, server: server}
+}
+
+// token returns a JWT of the issuer iss for the subject and audiences, signed with the key of the test issuer.
+func (i *testIssuer) token(t *testing.T, iss, sub string, aud ...string) string {
+	t.Helper()
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: i.key, KeyID: "key"}},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Signed(signer).Claims(jwt.Claims{
+		Issuer:   iss,
+		Subject:  sub,
+		Audience: aud,
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestJwtAuthenticator(t *testing.T) {
+	local, remote := newTestIssuer(t), newTestIssuer(t)
+	defer local.server.Close()
+	defer remote.server.Close()
+	const localIss, remoteIss = "https://local.example.com", "https://remote.example.com"
+
+	if _, err := newJwtAuthenticator([]TrustedIssuer{{Issuer: "http://127.0.0.1:0"}}, "cluster.local"); err == nil {
+		t.Fatal("newJwtAuthenticator() without any valid issuer => no error")
+	}
+	authenticator, err := newJwtAuthenticator([]TrustedIssuer{
+		{Issuer: localIss, Audiences: []string{"istio-ca"}, JwksURI: local.server.URL},
+		{Issuer: remoteIss, Audiences: []string{"remote-ca", "istio-ca"}, JwksURI: remote.server.URL,
+			TrustDomain: "remote.example.com"},
+		// The OIDC discovery of this issuer fails, so it is skipped.
+		{Issuer: "http://127.0.0.1:0", Audiences: []string{"istio-ca"}},
+	}, "cluster.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(authenticator.verifiers) != 2 {
+		t.Errorf("newJwtAuthenticator() => %d verifiers, want 2", len(authenticator.verifiers))
+	}
+
+	cases := []struct {
+		name    string
+		token   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "local issuer",
+			token: local.token(t, localIss, "system:serviceaccount:ns1:sa1", "istio-ca"),
+			want:  "spiffe://cluster.local/ns/ns1/sa/sa1",
+		},
+		{
+			name:  "remote issuer in a trust domain alias",
+			token: remote.token(t, remoteIss, "system:serviceaccount:ns2:sa2", "remote-ca"),
+			want:  "spiffe://remote.example.com/ns/ns2/sa/sa2",
+		},
+		{
+			name:    "audience mismatch",
+			token:   local.token(t, localIss, "system:serviceaccount:ns1:sa1", "remote-ca"),
+			wantErr: "don't match the expected audiences",
+		},
+		{
+			name:    "unknown issuer",
+			token:   local.token(t, "https://unknown.example.com", "system:serviceaccount:ns1:sa1", "istio-ca"),
+			wantErr: "untrusted issuer",
+		},
+		{
+			name:    "signed by another issuer",
+			token:   remote.token(t, localIss, "system:serviceaccount:ns1:sa1", "istio-ca"),
+			wantErr: "failed to verify the ID token",
+		},
+		{
+			name:    "not a service account",
+			token:   local.token(t, localIss, "system:node:node1", "istio-ca"),
+			wantErr: "invalid sub",
+		},
+		{
+			name:    "truncated subject",
+			token:   local.token(t, localIss, "system:serviceaccount:ns1", "istio-ca"),
+			wantErr: "invalid sub",
+		},
+		{
+			name:    "empty service account",
+			token:   local.token(t, localIss, "system:serviceaccount:ns1:", "istio-ca"),
+			wantErr: "invalid sub",
+		},
+		{
+			name:    "malformed token",
+			token:   "not-a-jwt",
+			wantErr: "failed to parse the ID token",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(),
+				metadata.MD{httpAuthHeader: []string{bearerTokenPrefix + c.token}})
+			caller, err := authenticator.Authenticate(ctx)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("Authenticate() => %v, want error %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(caller.Identities, []string{c.want}) {
+				t.Errorf("Authenticate() => identities %v, want %v", caller.Identities, c.want)
+			}
+		})
+	}
+
+	if _, err := authenticator.Authenticate(context.Background()); err == nil {
+		t.Error("Authenticate() without bearer token => no error")
+	}
+}
@@ -21,6 +21,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
@@ -33,6 +34,7 @@ import (
 	"istio.io/istio/pilot/pkg/config/clusterregistry"
 	"istio.io/istio/pilot/pkg/config/kube/crd/controller"
 	"istio.io/istio/pilot/pkg/config/kube/ingress"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/serviceregistry"
 	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
@@ -67,6 +69,8 @@ func InitK8S(is *istiod.Server, clientset kubernetes.Interface, config *rest.Con
 		ControllerOptions: controller2.Options{
 			DomainSuffix: args.DomainSuffix,
 			TrustDomain:  args.MeshConfig.TrustDomain,
+			// The informers are shared by the kube registry and the Ingress controller.
+			InformerFactory: informers.NewSharedInformerFactory(clientset, 0),
 		},
 	}
 
@@ -173,6 +177,7 @@ func (s *Controllers) createK8sServiceControllers(serviceControllers *aggregate.
 	clusterID := string(serviceregistry.KubernetesRegistry)
 	log.Infof("Primary Cluster name: %s", clusterID)
 	s.ControllerOptions.ClusterID = clusterID
+	s.ControllerOptions.RecordEvents = features.EnableRegistryEvents
 	kubectl := controller2.NewController(s.kubeClient, s.ControllerOptions)
 	s.kubeRegistry = kubectl
 	serviceControllers.AddRegistry(
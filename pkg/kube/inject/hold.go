@@ -0,0 +1,94 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file is focused on holding the application containers until the proxy is ready, so that
+// the applications don't make outbound calls before Envoy can route them.
+package inject
+
+import (
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// HoldApplicationUntilProxyStartsAnnotation overrides the holdApplicationUntilProxyStarts
+	// setting of the injection template for a pod.
+	HoldApplicationUntilProxyStartsAnnotation = "sidecar.istio.io/holdApplicationUntilProxyStarts"
+
+	// holdApplicationTimeoutSeconds is the time the application containers are held for at most.
+	// Kubernetes kills the proxy container when its postStart hook fails, so the pod is restarted
+	// rather than started without the proxy.
+	holdApplicationTimeoutSeconds = 120
+)
+
+// ShouldHoldApplicationUntilProxyStarts returns if the application containers should only be
+// started once the proxy is ready.
+func ShouldHoldApplicationUntilProxyStarts(annotations map[string]string, spec *SidecarInjectionSpec) bool {
+	if value, ok := annotations[HoldApplicationUntilProxyStartsAnnotation]; ok {
+		if hold, err := strconv.ParseBool(value); err == nil {
+			return hold
+		}
+	}
+	if spec == nil {
+		return false
+	}
+	return spec.HoldApplicationUntilProxyStarts
+}
+
+// addProxyStartedHook makes the postStart hook of the proxy container wait until the proxy is ready.
+// Kubernetes starts the containers in order, and only starts the next container once the postStart
+// hook of the previous one completed, so the proxy container must be the first container of the pod.
+// A postStart hook set by the template is kept.
+func addProxyStartedHook(sidecar *corev1.Container) {
+	if sidecar.Lifecycle != nil && sidecar.Lifecycle.PostStart != nil {
+		return
+	}
+	statusPort := extractStatusPort(sidecar)
+	if statusPort <= 0 {
+		statusPort = DefaultStatusPort
+	}
+	// The lifecycle may be shared with the template.
+	lifecycle := corev1.Lifecycle{}
+	if sidecar.Lifecycle != nil {
+		lifecycle = *sidecar.Lifecycle
+	}
+	sidecar.Lifecycle = &lifecycle
+	lifecycle.PostStart = &corev1.Handler{
+		Exec: &corev1.ExecAction{
+			Command: []string{
+				"pilot-agent",
+				"wait",
+				"--timeoutSeconds", strconv.Itoa(holdApplicationTimeoutSeconds),
+				"--url", fmt.Sprintf("http://localhost:%d/healthz/ready", statusPort),
+			},
+		},
+	}
+}
+
+// holdApplicationUntilProxyStarts moves the proxy container first, with a postStart hook waiting
+// until the proxy is ready.
+func holdApplicationUntilProxyStarts(containers []corev1.Container) []corev1.Container {
+	out := make([]corev1.Container, 0, len(containers))
+	for _, c := range containers {
+		if c.Name == ProxyContainerName {
+			addProxyStartedHook(&c)
+			out = append([]corev1.Container{c}, out...)
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
@@ -0,0 +1,120 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inject
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestShouldHoldApplicationUntilProxyStarts(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		spec        *SidecarInjectionSpec
+		want        bool
+	}{
+		{"default", nil, nil, false},
+		{"template", nil, &SidecarInjectionSpec{HoldApplicationUntilProxyStarts: true}, true},
+		{"annotation", map[string]string{HoldApplicationUntilProxyStartsAnnotation: "true"}, &SidecarInjectionSpec{}, true},
+		{"disabled by annotation", map[string]string{HoldApplicationUntilProxyStartsAnnotation: "false"},
+			&SidecarInjectionSpec{HoldApplicationUntilProxyStarts: true}, false},
+		{"invalid annotation", map[string]string{HoldApplicationUntilProxyStartsAnnotation: "yes please"},
+			&SidecarInjectionSpec{HoldApplicationUntilProxyStarts: true}, true},
+	}
+	for _, c := range cases {
+		if got := ShouldHoldApplicationUntilProxyStarts(c.annotations, c.spec); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestHoldApplicationUntilProxyStarts(t *testing.T) {
+	containers := holdApplicationUntilProxyStarts([]corev1.Container{
+		{Name: "app"},
+		{Name: ProxyContainerName, Args: []string{"proxy", "--statusPort", "15021"}},
+	})
+	if len(containers) != 2 || containers[0].Name != ProxyContainerName || containers[1].Name != "app" {
+		t.Fatalf("got containers %v, want the proxy first", containers)
+	}
+	want := []string{"pilot-agent", "wait", "--timeoutSeconds", "120", "--url", "http://localhost:15021/healthz/ready"}
+	if got := containers[0].Lifecycle.PostStart.Exec.Command; !reflect.DeepEqual(got, want) {
+		t.Errorf("got postStart hook %v, want %v", got, want)
+	}
+
+	// A postStart hook of the template is kept.
+	hook := &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"true"}}}
+	containers = holdApplicationUntilProxyStarts([]corev1.Container{
+		{Name: "app"},
+		{Name: ProxyContainerName, Lifecycle: &corev1.Lifecycle{PostStart: hook}},
+	})
+	if containers[0].Lifecycle.PostStart != hook {
+		t.Errorf("got postStart hook %v, want the hook of the template", containers[0].Lifecycle.PostStart)
+	}
+}
+
+func TestCreatePatchHoldApplication(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{HoldApplicationUntilProxyStartsAnnotation: "true"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name: "app",
+				VolumeMounts: []corev1.VolumeMount{{
+					Name:      "default-token",
+					MountPath: "/var/run/secrets/kubernetes.io/serviceaccount",
+				}},
+			}},
+		},
+	}
+	sic := &SidecarInjectionSpec{
+		Containers: []corev1.Container{{Name: ProxyContainerName}, {Name: "helper"}},
+	}
+	patch, err := createPatch(pod, &SidecarInjectionStatus{}, nil, sic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	podJSON, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var patched corev1.Pod
+	if err := json.Unmarshal(applyJSONPatch(podJSON, patch, t), &patched); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, c := range patched.Spec.Containers {
+		names = append(names, c.Name)
+	}
+	if want := []string{ProxyContainerName, "app", "helper"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("got containers %v, want %v", names, want)
+	}
+	proxy := patched.Spec.Containers[0]
+	if proxy.Lifecycle == nil || proxy.Lifecycle.PostStart == nil {
+		t.Error("the proxy should wait until it is ready in its postStart hook")
+	}
+	if len(proxy.VolumeMounts) != 1 || proxy.VolumeMounts[0].Name != "default-token" {
+		t.Errorf("got volume mounts %v, want the service account token", proxy.VolumeMounts)
+	}
+	if len(sic.Containers[0].VolumeMounts) != 0 || sic.Containers[0].Lifecycle != nil {
+		t.Error("the injection spec should not be modified")
+	}
+}
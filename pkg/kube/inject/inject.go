@@ -39,6 +39,7 @@ import (
 	"istio.io/api/annotation"
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/security/pkg/pki/util"
 	"istio.io/pkg/log"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -85,6 +86,11 @@ var (
 		annotation.SidecarTrafficExcludeInboundPorts.Name:         ValidateExcludeInboundPorts,
 		annotation.SidecarTrafficExcludeOutboundPorts.Name:        ValidateExcludeOutboundPorts,
 		annotation.SidecarTrafficKubevirtInterfaces.Name:          alwaysValidFunc,
+		HoldApplicationUntilProxyStartsAnnotation:                 validateBool,
+		ECCSignatureAlgorithmAnnotation:                           validateECSigAlg,
+		MaxClustersAnnotation:                                     validateUInt32,
+		MaxRoutesAnnotation:                                       validateUInt32,
+		MaxEndpointsAnnotation:                                    validateUInt32,
 	}
 )
 
@@ -138,6 +144,16 @@ const (
 const (
 	// ProxyContainerName is used by e2e integration tests for fetching logs
 	ProxyContainerName = "istio-proxy"
+
+	// ECCSignatureAlgorithmAnnotation overrides the EC signature algorithm of the keys of the workload
+	// certificates of a pod, e.g. ECDSA, set by the eccSignatureAlgorithm value of the proxy.
+	ECCSignatureAlgorithmAnnotation = "sidecar.istio.io/eccSignatureAlgorithm"
+
+	// MaxClustersAnnotation, MaxRoutesAnnotation and MaxEndpointsAnnotation limit the clusters, virtual
+	// hosts and endpoints Pilot pushes to the proxy of a pod, e.g. for small sidecars in large meshes.
+	MaxClustersAnnotation  = "sidecar.istio.io/maxClusters"
+	MaxRoutesAnnotation    = "sidecar.istio.io/maxRoutes"
+	MaxEndpointsAnnotation = "sidecar.istio.io/maxEndpoints"
 )
 
 // SidecarInjectionSpec collects all container types and volumes for
@@ -152,6 +168,10 @@ type SidecarInjectionSpec struct {
 	Volumes             []corev1.Volume               `yaml:"volumes"`
 	DNSConfig           *corev1.PodDNSConfig          `yaml:"dnsConfig"`
 	ImagePullSecrets    []corev1.LocalObjectReference `yaml:"imagePullSecrets"`
+
+	// HoldApplicationUntilProxyStarts indicates whether the application containers are only
+	// started once the proxy is ready.
+	HoldApplicationUntilProxyStarts bool `yaml:"holdApplicationUntilProxyStarts"`
 }
 
 // SidecarTemplateData is the data object to which the templated
@@ -387,6 +407,16 @@ func validateUInt32(value string) error {
 	return err
 }
 
+func validateBool(value string) error {
+	_, err := strconv.ParseBool(value)
+	return err
+}
+
+func validateECSigAlg(value string) error {
+	_, err := util.ParseECSigAlg(value)
+	return err
+}
+
 func injectRequired(ignored []string, config *Config, podSpec *corev1.PodSpec, metadata *metav1.ObjectMeta) bool { // nolint: lll
 	// Skip injection when host networking is enabled. The problem is
 	// that the iptable changes are assumed to be within the pod when,
@@ -564,6 +594,7 @@ func InjectionData(sidecarTemplate, valuesConfig, version string, typeMetadata *
 		"toJson":              toJSON, // Used by, e.g. Istio 1.0.5 template sidecar-injector-configmap.yaml
 		"fromJSON":            fromJSON,
 		"structToJSON":        structToJSON,
+		"podPortMap":          podPortMap,
 		"protoToJSON":         protoToJSON,
 		"toYaml":              toYaml,
 		"indent":              indent,
@@ -822,6 +853,10 @@ func IntoObject(sidecarTemplate string, valuesConfig string, meshconfig *meshcon
 	// Because we need to extract istio-proxy's statusPort.
 	rewriteAppHTTPProbe(metadata.Annotations, podSpec, spec)
 
+	if ShouldHoldApplicationUntilProxyStarts(metadata.Annotations, spec) {
+		podSpec.Containers = holdApplicationUntilProxyStarts(podSpec.Containers)
+	}
+
 	// due to bug https://github.com/kubernetes/kubernetes/issues/57923,
 	// k8s sa jwt token volume mount file is only accessible to root user, not istio-proxy(the user that istio proxy runs as).
 	// workaround by https://kubernetes.io/docs/tasks/configure-pod-container/security-context/#set-the-security-context-for-a-pod
@@ -885,6 +920,23 @@ func includeInboundPorts(containers []corev1.Container) string {
 	return getContainerPorts(containers, func(corev1.Container) bool { return true })
 }
 
+// podPortMap returns the numbers of the named ports of the containers by name, as a JSON object set in
+// the ISTIO_META_POD_PORT_MAP env variable of the proxy, or an empty string without named port.
+func podPortMap(containers []corev1.Container) string {
+	ports := make(map[string]int)
+	for _, c := range containers {
+		for _, p := range c.Ports {
+			if p.Name != "" {
+				ports[p.Name] = int(p.ContainerPort)
+			}
+		}
+	}
+	if len(ports) == 0 {
+		return ""
+	}
+	return structToJSON(ports)
+}
+
 func kubevirtInterfaces(s string) string {
 	return s
 }
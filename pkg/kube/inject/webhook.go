@@ -329,11 +329,13 @@ func removeImagePullSecrets(imagePullSecrets []corev1.LocalObjectReference, remo
 	return patch
 }
 
-func addContainer(target, added []corev1.Container, basePath string) (patch []rfc6902PatchOperation) {
+// serviceAccountJwtMount returns the service account secret volume mount
+// (/var/run/secrets/kubernetes.io/serviceaccount,
+// https://kubernetes.io/docs/reference/access-authn-authz/service-accounts-admin/#service-account-automation) of the
+// app containers, if any.
+func serviceAccountJwtMount(target []corev1.Container) (corev1.VolumeMount, bool) {
 	saJwtSecretMountName := ""
 	var saJwtSecretMount corev1.VolumeMount
-	// find service account secret volume mount(/var/run/secrets/kubernetes.io/serviceaccount,
-	// https://kubernetes.io/docs/reference/access-authn-authz/service-accounts-admin/#service-account-automation) from app container
 	for _, add := range target {
 		for _, vmount := range add.VolumeMounts {
 			if vmount.MountPath == "/var/run/secrets/kubernetes.io/serviceaccount" {
@@ -342,10 +344,15 @@ func addContainer(target, added []corev1.Container, basePath string) (patch []rf
 			}
 		}
 	}
+	return saJwtSecretMount, saJwtSecretMountName != ""
+}
+
+func addContainer(target, added []corev1.Container, basePath string) (patch []rfc6902PatchOperation) {
+	saJwtSecretMount, hasSaJwtSecretMount := serviceAccountJwtMount(target)
 	first := len(target) == 0
 	var value interface{}
 	for _, add := range added {
-		if add.Name == "istio-proxy" && saJwtSecretMountName != "" {
+		if add.Name == "istio-proxy" && hasSaJwtSecretMount {
 			// add service account secret volume mount(/var/run/secrets/kubernetes.io/serviceaccount,
 			// https://kubernetes.io/docs/reference/access-authn-authz/service-accounts-admin/#service-account-automation) to istio-proxy container,
 			// so that envoy could fetch/pass k8s sa jwt and pass to sds server, which will be used to request workload identity for the pod.
@@ -368,6 +375,18 @@ func addContainer(target, added []corev1.Container, basePath string) (patch []rf
 	return patch
 }
 
+// addContainerFirst inserts the container before the other containers, which must not be empty.
+func addContainerFirst(target []corev1.Container, added corev1.Container, basePath string) []rfc6902PatchOperation {
+	if saJwtSecretMount, ok := serviceAccountJwtMount(target); ok && added.Name == ProxyContainerName {
+		added.VolumeMounts = append(added.VolumeMounts, saJwtSecretMount)
+	}
+	return []rfc6902PatchOperation{{
+		Op:    "add",
+		Path:  basePath + "/0",
+		Value: added,
+	}}
+}
+
 func addSecurityContext(target *corev1.PodSecurityContext, basePath string) (patch []rfc6902PatchOperation) {
 	patch = append(patch, rfc6902PatchOperation{
 		Op:    "add",
@@ -521,8 +540,23 @@ func createPatch(pod *corev1.Pod, prevStatus *SidecarInjectionStatus, annotation
 	}
 	addAppProberCmd()
 
+	// The proxy is inserted before the app containers last, after the patches of the app containers.
+	containers := sic.Containers
+	var holdProxy *corev1.Container
+	if ShouldHoldApplicationUntilProxyStarts(pod.Annotations, sic) && len(pod.Spec.Containers) > 0 {
+		containers = nil
+		for _, c := range holdApplicationUntilProxyStarts(sic.Containers) {
+			c := c
+			if c.Name == ProxyContainerName && holdProxy == nil {
+				holdProxy = &c
+				continue
+			}
+			containers = append(containers, c)
+		}
+	}
+
 	patch = append(patch, addContainer(pod.Spec.InitContainers, sic.InitContainers, "/spec/initContainers")...)
-	patch = append(patch, addContainer(pod.Spec.Containers, sic.Containers, "/spec/containers")...)
+	patch = append(patch, addContainer(pod.Spec.Containers, containers, "/spec/containers")...)
 	patch = append(patch, addVolume(pod.Spec.Volumes, sic.Volumes, "/spec/volumes")...)
 	patch = append(patch, addImagePullSecrets(pod.Spec.ImagePullSecrets, sic.ImagePullSecrets, "/spec/imagePullSecrets")...)
 
@@ -542,6 +576,10 @@ func createPatch(pod *corev1.Pod, prevStatus *SidecarInjectionStatus, annotation
 		patch = append(patch, createProbeRewritePatch(pod.Annotations, &pod.Spec, sic)...)
 	}
 
+	if holdProxy != nil {
+		patch = append(patch, addContainerFirst(pod.Spec.Containers, *holdProxy, "/spec/containers")...)
+	}
+
 	return json.Marshal(patch)
 }
 
@@ -0,0 +1,63 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secretcontroller
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	clusterIDTag = monitoring.MustCreateLabel("cluster_id")
+
+	remoteClusters = monitoring.NewGauge(
+		"pilot_remote_clusters",
+		"The number of remote clusters of the multicluster secrets.",
+	)
+
+	remoteClusterReloads = monitoring.NewSum(
+		"pilot_remote_cluster_reloads_total",
+		"The number of times the controller of a remote cluster was rebuilt after its secret was updated.",
+		monitoring.WithLabels(clusterIDTag),
+	)
+
+	remoteClusterLastListSuccess = monitoring.NewGauge(
+		"pilot_remote_cluster_last_list_success_timestamp_seconds",
+		"The time of the last successful list from the API server of a remote cluster, in seconds since epoch. "+
+			"Zero once the remote cluster is removed.",
+		monitoring.WithLabels(clusterIDTag),
+	)
+
+	remoteClusterListErrors = monitoring.NewSum(
+		"pilot_remote_cluster_list_errors_total",
+		"The number of failed health checks listing from the API server of a remote cluster.",
+		monitoring.WithLabels(clusterIDTag),
+	)
+
+	remoteClusterWatchErrors = monitoring.NewSum(
+		"pilot_remote_cluster_watch_errors_total",
+		"The number of failed watches of the informers on the API server of a remote cluster.",
+		monitoring.WithLabels(clusterIDTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(
+		remoteClusters,
+		remoteClusterReloads,
+		remoteClusterLastListSuccess,
+		remoteClusterListErrors,
+		remoteClusterWatchErrors,
+	)
+}
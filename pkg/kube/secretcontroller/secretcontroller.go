@@ -15,7 +15,11 @@
 package secretcontroller
 
 import (
+	"bytes"
 	"fmt"
+	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -27,18 +31,31 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"k8s.io/client-go/util/workqueue"
 
-	"istio.io/istio/pkg/kube"
 	"istio.io/pkg/log"
 )
 
 const (
 	MultiClusterSecretLabel = "istio/multiCluster"
 
+	// ClusterIDAnnotation overrides the cluster IDs of the kubeconfigs of a secret, which default
+	// to their data keys, as a comma separated list of <data key>=<cluster ID> pairs. It lets
+	// clusters with conflicting names join the mesh without renaming them.
+	ClusterIDAnnotation = "istio/clusterID"
+
+	// NetworkAnnotation sets the network of the clusters of a secret, overriding the network
+	// of their registry in the MeshNetworks.
+	NetworkAnnotation = "istio/network"
+
 	maxRetries = 5
 )
 
+// HealthCheckInterval is the interval at which the API servers of the remote clusters are listed,
+// reported in the pilot_remote_cluster_* metrics. Zero disables the health checks.
+var HealthCheckInterval = 30 * time.Second
+
 // LoadKubeConfig is a unit test override variable for loading the k8s config.
 // DO NOT USE - TEST ONLY.
 var LoadKubeConfig = clientcmd.Load
@@ -47,13 +64,14 @@ var ValidateClientConfig = clientcmd.Validate
 
 // CreateInterfaceFromClusterConfig is a unit test override variable for interface create.
 // DO NOT USE - TEST ONLY.
-var CreateInterfaceFromClusterConfig = kube.CreateInterfaceFromClusterConfig
+var CreateInterfaceFromClusterConfig = createRemoteInterface
 
-// addSecretCallback prototype for the add secret callback function.
-type addSecretCallback func(clientset kubernetes.Interface, dataKey string) error
+// addSecretCallback prototype for the add secret callback function. The network is empty unless
+// set with NetworkAnnotation.
+type addSecretCallback func(clientset kubernetes.Interface, clusterID string, network string) error
 
 // removeSecretCallback prototype for the remove secret callback function.
-type removeSecretCallback func(dataKey string) error
+type removeSecretCallback func(clusterID string) error
 
 // Controller is the controller implementation for Secret resources
 type Controller struct {
@@ -69,10 +87,16 @@ type Controller struct {
 // RemoteCluster defines cluster structZZ
 type RemoteCluster struct {
 	secretName string
+	// kubeConfig and network the cluster was added with, so that it is rebuilt when they change.
+	kubeConfig []byte
+	network    string
+	client     kubernetes.Interface
 }
 
 // ClusterStore is a collection of clusters
 type ClusterStore struct {
+	// protects remoteClusters, read by the health checks
+	mu             sync.RWMutex
 	remoteClusters map[string]*RemoteCluster
 }
 
@@ -127,6 +151,16 @@ func NewController(
 				queue.Add(key)
 			}
 		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if oldObj.(*corev1.Secret).ResourceVersion == newObj.(*corev1.Secret).ResourceVersion {
+				return
+			}
+			key, err := cache.MetaNamespaceKeyFunc(newObj)
+			log.Infof("Processing update: %s", key)
+			if err == nil {
+				queue.Add(key)
+			}
+		},
 		DeleteFunc: func(obj interface{}) {
 			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
 			log.Infof("Processing delete: %s", key)
@@ -154,6 +188,9 @@ func (c *Controller) Run(stopCh <-chan struct{}) {
 		utilruntime.HandleError(fmt.Errorf("timed out waiting for caches to sync"))
 		return
 	}
+	if HealthCheckInterval > 0 {
+		go wait.Until(c.checkRemoteClusters, HealthCheckInterval, stopCh)
+	}
 	wait.Until(c.runWorker, 5*time.Second, stopCh)
 }
 
@@ -215,59 +252,220 @@ func (c *Controller) processItem(secretName string) error {
 	return nil
 }
 
+// clusterIDAliases returns the cluster IDs of the data keys of the secret set with ClusterIDAnnotation.
+func clusterIDAliases(secretName string, s *corev1.Secret) map[string]string {
+	aliases := map[string]string{}
+	value := strings.TrimSpace(s.Annotations[ClusterIDAnnotation])
+	if value == "" {
+		return aliases
+	}
+	for _, pair := range strings.Split(value, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" || strings.TrimSpace(kv[1]) == "" {
+			log.Warnf("Ignoring invalid cluster ID %q in the annotation %s of the secret %s in namespace %s",
+				pair, ClusterIDAnnotation, secretName, s.Namespace)
+			continue
+		}
+		aliases[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return aliases
+}
+
+// addMemberCluster adds the clusters of the secret, rebuilds the clusters whose kubeconfig or network
+// changed since they were added, and deletes the clusters removed from the secret.
 func (c *Controller) addMemberCluster(secretName string, s *corev1.Secret) {
-	for clusterID, kubeConfig := range s.Data {
+	aliases := clusterIDAliases(secretName, s)
+	network := strings.TrimSpace(s.Annotations[NetworkAnnotation])
+	inSecret := make(map[string]bool, len(s.Data))
+	for dataKey, kubeConfig := range s.Data {
+		clusterID := dataKey
+		if alias, ok := aliases[dataKey]; ok {
+			clusterID = alias
+		}
 		// clusterID must be unique even across multiple secrets
-		if _, ok := c.cs.remoteClusters[clusterID]; !ok {
-			if len(kubeConfig) == 0 {
-				log.Infof("Data '%s' in the secret %s in namespace %s is empty, and disregarded ",
-					clusterID, secretName, s.Namespace)
-				continue
-			}
+		existing, found := c.cs.get(clusterID)
+		if found && existing.secretName != secretName {
+			log.Infof("Cluster %s in the secret %s in namespace %s already exists",
+				clusterID, existing.secretName, s.Namespace)
+			continue
+		}
+		inSecret[clusterID] = true
+		if found && bytes.Equal(existing.kubeConfig, kubeConfig) && existing.network == network {
+			continue
+		}
 
-			clientConfig, err := LoadKubeConfig(kubeConfig)
-			if err != nil {
-				log.Infof("Data '%s' in the secret %s in namespace %s is not a kubeconfig: %v",
-					clusterID, secretName, s.Namespace, err)
-				continue
-			}
+		// An invalid kubeconfig is disregarded, and a cluster already added keeps its previous one.
+		if len(kubeConfig) == 0 {
+			log.Infof("Data '%s' in the secret %s in namespace %s is empty, and disregarded ",
+				dataKey, secretName, s.Namespace)
+			continue
+		}
 
-			if err := ValidateClientConfig(*clientConfig); err != nil {
-				log.Errorf("Data '%s' in the secret %s in namespace %s is not a valid kubeconfig: %v",
-					clusterID, secretName, s.Namespace, err)
-				continue
-			}
+		clientConfig, err := LoadKubeConfig(kubeConfig)
+		if err != nil {
+			log.Infof("Data '%s' in the secret %s in namespace %s is not a kubeconfig: %v",
+				dataKey, secretName, s.Namespace, err)
+			continue
+		}
 
-			log.Infof("Adding new cluster member: %s", clusterID)
-			c.cs.remoteClusters[clusterID] = &RemoteCluster{}
-			c.cs.remoteClusters[clusterID].secretName = secretName
-			client, err := CreateInterfaceFromClusterConfig(clientConfig)
-			if err != nil {
-				log.Errorf("error during create of kubernetes client interface for cluster: %s %v", clusterID, err)
-				continue
-			}
-			err = c.addCallback(client, clusterID)
-			if err != nil {
-				log.Errorf("error during create of clusterID: %s %v", clusterID, err)
-			}
+		if err := ValidateClientConfig(*clientConfig); err != nil {
+			log.Errorf("Data '%s' in the secret %s in namespace %s is not a valid kubeconfig: %v",
+				dataKey, secretName, s.Namespace, err)
+			continue
+		}
+
+		client, err := CreateInterfaceFromClusterConfig(clusterID, clientConfig)
+		if err != nil {
+			log.Errorf("error during create of kubernetes client interface for cluster: %s %v", clusterID, err)
+			continue
+		}
+
+		if found {
+			log.Infof("Reloading cluster member: %s, its kubeconfig or network changed in the secret %s",
+				clusterID, secretName)
+			c.removeCluster(clusterID)
+			remoteClusterReloads.With(clusterIDTag.Value(clusterID)).Increment()
+		} else if clusterID != dataKey {
+			log.Infof("Adding new cluster member: %s (data '%s', network %q)", clusterID, dataKey, network)
 		} else {
-			log.Infof("Cluster %s in the secret %s in namespace %s already exists",
-				clusterID, c.cs.remoteClusters[clusterID].secretName, s.Namespace)
+			log.Infof("Adding new cluster member: %s (network %q)", clusterID, network)
+		}
+		c.cs.set(clusterID, &RemoteCluster{
+			secretName: secretName,
+			kubeConfig: kubeConfig,
+			network:    network,
+			client:     client,
+		})
+		err = c.addCallback(client, clusterID, network)
+		if err != nil {
+			log.Errorf("error during create of clusterID: %s %v", clusterID, err)
 		}
 	}
-	log.Infof("Number of remote clusters: %d", len(c.cs.remoteClusters))
+
+	for _, clusterID := range c.cs.clustersOf(secretName) {
+		if !inSecret[clusterID] {
+			log.Infof("Cluster member %s was removed from the secret %s", clusterID, secretName)
+			c.removeCluster(clusterID)
+		}
+	}
+	log.Infof("Number of remote clusters: %d", c.cs.len())
 }
 
 func (c *Controller) deleteMemberCluster(secretName string) {
-	for clusterID, cluster := range c.cs.remoteClusters {
+	for _, clusterID := range c.cs.clustersOf(secretName) {
+		c.removeCluster(clusterID)
+	}
+	log.Infof("Number of remote clusters: %d", c.cs.len())
+}
+
+func (c *Controller) removeCluster(clusterID string) {
+	log.Infof("Deleting cluster member: %s", clusterID)
+	err := c.removeCallback(clusterID)
+	if err != nil {
+		log.Errorf("error during cluster delete: %s %v", clusterID, err)
+	}
+	c.cs.delete(clusterID)
+	remoteClusterLastListSuccess.With(clusterIDTag.Value(clusterID)).Record(0)
+}
+
+// checkRemoteClusters lists from the API server of each remote cluster, and records the result in the
+// pilot_remote_cluster_* metrics.
+func (c *Controller) checkRemoteClusters() {
+	for clusterID, client := range c.cs.clients() {
+		checkRemoteCluster(clusterID, client)
+	}
+}
+
+// checkRemoteCluster lists a service, which the registries of the remote clusters are allowed to.
+func checkRemoteCluster(clusterID string, client kubernetes.Interface) {
+	tag := clusterIDTag.Value(clusterID)
+	if _, err := client.CoreV1().Services("").List(meta_v1.ListOptions{Limit: 1}); err != nil {
+		log.Warnf("Health check of remote cluster %s failed, could not list: %v", clusterID, err)
+		remoteClusterListErrors.With(tag).Increment()
+		return
+	}
+	remoteClusterLastListSuccess.With(tag).Record(float64(time.Now().Unix()))
+}
+
+// createRemoteInterface creates the client of a remote cluster, counting the failed watches of the
+// informers using it, which their reflectors retry without reporting them.
+func createRemoteInterface(clusterID string, clusterConfig *clientcmdapi.Config) (kubernetes.Interface, error) {
+	restConfig, err := clientcmd.NewDefaultClientConfig(*clusterConfig, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	restConfig.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &watchErrorCounter{clusterID: clusterID, rt: rt}
+	})
+	return kubernetes.NewForConfig(restConfig)
+}
+
+// watchErrorCounter counts the watch requests to the API server of a remote cluster which fail, in
+// the pilot_remote_cluster_watch_errors_total metric.
+type watchErrorCounter struct {
+	clusterID string
+	rt        http.RoundTripper
+}
+
+func (w *watchErrorCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := w.rt.RoundTrip(req)
+	if req.URL.Query().Get("watch") == "true" && (err != nil || resp.StatusCode >= http.StatusBadRequest) {
+		remoteClusterWatchErrors.With(clusterIDTag.Value(w.clusterID)).Increment()
+	}
+	return resp, err
+}
+
+func (cs *ClusterStore) get(clusterID string) (*RemoteCluster, bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	cluster, ok := cs.remoteClusters[clusterID]
+	return cluster, ok
+}
+
+func (cs *ClusterStore) set(clusterID string, cluster *RemoteCluster) {
+	cs.mu.Lock()
+	cs.remoteClusters[clusterID] = cluster
+	n := len(cs.remoteClusters)
+	cs.mu.Unlock()
+	remoteClusters.Record(float64(n))
+}
+
+func (cs *ClusterStore) delete(clusterID string) {
+	cs.mu.Lock()
+	delete(cs.remoteClusters, clusterID)
+	n := len(cs.remoteClusters)
+	cs.mu.Unlock()
+	remoteClusters.Record(float64(n))
+}
+
+func (cs *ClusterStore) len() int {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return len(cs.remoteClusters)
+}
+
+// clustersOf returns the IDs of the clusters of the secret.
+func (cs *ClusterStore) clustersOf(secretName string) []string {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	var out []string
+	for clusterID, cluster := range cs.remoteClusters {
 		if cluster.secretName == secretName {
-			log.Infof("Deleting cluster member: %s", clusterID)
-			err := c.removeCallback(clusterID)
-			if err != nil {
-				log.Errorf("error during cluster delete: %s %v", clusterID, err)
-			}
-			delete(c.cs.remoteClusters, clusterID)
+			out = append(out, clusterID)
+		}
+	}
+	return out
+}
+
+// clients returns the clients of the clusters by ID.
+func (cs *ClusterStore) clients() map[string]kubernetes.Interface {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	out := make(map[string]kubernetes.Interface, len(cs.remoteClusters))
+	for clusterID, cluster := range cs.remoteClusters {
+		if cluster.client != nil {
+			out[clusterID] = cluster.client
 		}
 	}
-	log.Infof("Number of remote clusters: %d", len(c.cs.remoteClusters))
+	return out
 }
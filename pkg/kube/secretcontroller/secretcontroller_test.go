@@ -15,10 +15,14 @@
 package secretcontroller
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"go.opencensus.io/stats/view"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -34,7 +38,7 @@ const secretNamespace string = "istio-system"
 var testCreateControllerCalled int32
 var testDeleteControllerCalled int32
 
-func testCreateController(_ kubernetes.Interface, _ string) error {
+func testCreateController(_ kubernetes.Interface, _, _ string) error {
 	atomic.StoreInt32(&testCreateControllerCalled, 1)
 	return nil
 }
@@ -78,7 +82,7 @@ func mockValidateClientConfig(_ clientcmdapi.Config) error {
 	return nil
 }
 
-func mockCreateInterfaceFromClusterConfig(_ *clientcmdapi.Config) (kubernetes.Interface, error) {
+func mockCreateInterfaceFromClusterConfig(_ string, _ *clientcmdapi.Config) (kubernetes.Interface, error) {
 	return fake.NewSimpleClientset(), nil
 }
 
@@ -138,3 +142,175 @@ func Test_SecretController(t *testing.T) {
 		t.Fatalf("Test failed on delete secret, create callback function called")
 	}
 }
+
+func Test_AddMemberClusterOverrides(t *testing.T) {
+	LoadKubeConfig = mockLoadKubeConfig
+	ValidateClientConfig = mockValidateClientConfig
+	CreateInterfaceFromClusterConfig = mockCreateInterfaceFromClusterConfig
+
+	added := map[string]string{}
+	c := &Controller{
+		cs: newClustersStore(),
+		addCallback: func(_ kubernetes.Interface, clusterID string, network string) error {
+			added[clusterID] = network
+			return nil
+		},
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: secretNamespace,
+			Annotations: map[string]string{
+				ClusterIDAnnotation: "kubernetes=cluster2, invalid",
+				NetworkAnnotation:   "network2",
+			},
+		},
+		Data: map[string][]byte{
+			"kubernetes": []byte("Test"),
+			"cluster3":   []byte("Test"),
+		},
+	}
+	c.addMemberCluster(secretName, secret)
+
+	expected := map[string]string{"cluster2": "network2", "cluster3": "network2"}
+	if !reflect.DeepEqual(added, expected) {
+		t.Errorf("got clusters %v, want %v", added, expected)
+	}
+	if _, ok := c.cs.remoteClusters["kubernetes"]; ok {
+		t.Errorf("the cluster was stored by data key instead of its cluster ID")
+	}
+}
+
+func Test_ReloadMemberCluster(t *testing.T) {
+	LoadKubeConfig = mockLoadKubeConfig
+	ValidateClientConfig = mockValidateClientConfig
+	CreateInterfaceFromClusterConfig = mockCreateInterfaceFromClusterConfig
+
+	var events []string
+	c := &Controller{
+		cs: newClustersStore(),
+		addCallback: func(_ kubernetes.Interface, clusterID string, network string) error {
+			events = append(events, "add "+clusterID+" "+network)
+			return nil
+		},
+		removeCallback: func(clusterID string) error {
+			events = append(events, "remove "+clusterID)
+			return nil
+		},
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: secretNamespace},
+		Data:       map[string][]byte{"cluster1": []byte("kubeconfig1")},
+	}
+	expectEvents := func(step string, want ...string) {
+		t.Helper()
+		if len(want) == 0 {
+			want = nil
+		}
+		if !reflect.DeepEqual(events, want) {
+			t.Errorf("%s: got %v, want %v", step, events, want)
+		}
+		events = nil
+	}
+
+	c.addMemberCluster(secretName, secret)
+	expectEvents("add", "add cluster1 ")
+
+	// An update of another key of the secret doesn't rebuild the cluster.
+	c.addMemberCluster(secretName, secret.DeepCopy())
+	expectEvents("unchanged")
+
+	updated := secret.DeepCopy()
+	updated.Data["cluster1"] = []byte("kubeconfig2")
+	c.addMemberCluster(secretName, updated)
+	expectEvents("new kubeconfig", "remove cluster1", "add cluster1 ")
+
+	updated = updated.DeepCopy()
+	updated.Annotations = map[string]string{NetworkAnnotation: "network2"}
+	c.addMemberCluster(secretName, updated)
+	expectEvents("new network", "remove cluster1", "add cluster1 network2")
+
+	// An invalid kubeconfig keeps the cluster with its previous kubeconfig.
+	invalid := updated.DeepCopy()
+	invalid.Data["cluster1"] = []byte{}
+	c.addMemberCluster(secretName, invalid)
+	expectEvents("invalid kubeconfig")
+
+	// A cluster removed from the secret is deleted.
+	replaced := updated.DeepCopy()
+	replaced.Data = map[string][]byte{"cluster2": []byte("kubeconfig1")}
+	c.addMemberCluster(secretName, replaced)
+	expectEvents("replaced cluster", "add cluster2 network2", "remove cluster1")
+
+	if got := c.cs.clustersOf(secretName); !reflect.DeepEqual(got, []string{"cluster2"}) {
+		t.Errorf("got clusters %v, want [cluster2]", got)
+	}
+}
+
+func Test_CheckRemoteClusters(t *testing.T) {
+	c := &Controller{cs: newClustersStore()}
+	c.cs.set("cluster1", &RemoteCluster{secretName: secretName, client: fake.NewSimpleClientset()})
+	c.cs.set("cluster2", &RemoteCluster{secretName: secretName})
+
+	if got := c.cs.clients(); len(got) != 1 || got["cluster1"] == nil {
+		t.Errorf("got clients %v, want the client of cluster1", got)
+	}
+	// The fake client lists and watches successfully.
+	c.checkRemoteClusters()
+}
+
+func Test_CreateRemoteInterfaceWatchErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") == "true" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"kind":"ServiceList","apiVersion":"v1","items":[]}`))
+	}))
+	defer server.Close()
+
+	config := clientcmdapi.NewConfig()
+	config.Clusters["remote"] = &clientcmdapi.Cluster{Server: server.URL}
+	config.AuthInfos["remote"] = &clientcmdapi.AuthInfo{}
+	config.Contexts["remote"] = &clientcmdapi.Context{Cluster: "remote", AuthInfo: "remote"}
+	config.CurrentContext = "remote"
+	client, err := createRemoteInterface("watch-errors", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRemoteCluster("watch-errors", client)
+	if got := metricValue(t, "pilot_remote_cluster_last_list_success_timestamp_seconds", "watch-errors"); got == 0 {
+		t.Error("got no successful list")
+	}
+	if _, err := client.CoreV1().Services("").Watch(metav1.ListOptions{}); err == nil {
+		t.Fatal("expected the watch to fail")
+	}
+	if got := metricValue(t, "pilot_remote_cluster_watch_errors_total", "watch-errors"); got != 1 {
+		t.Errorf("got %v watch errors, want 1", got)
+	}
+}
+
+// metricValue returns the value of the metric for the cluster, 0 if it was not recorded.
+func metricValue(t *testing.T, name, clusterID string) float64 {
+	t.Helper()
+	rows, err := view.RetrieveData(name)
+	if err != nil {
+		t.Fatalf("failed to get the value of %s: %v", name, err)
+	}
+	for _, row := range rows {
+		for _, tag := range row.Tags {
+			if tag.Value != clusterID {
+				continue
+			}
+			switch data := row.Data.(type) {
+			case *view.SumData:
+				return data.Value
+			case *view.LastValueData:
+				return data.Value
+			}
+		}
+	}
+	return 0
+}
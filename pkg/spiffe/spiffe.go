@@ -88,3 +88,45 @@ func GenCustomSpiffe(identity string) string {
 
 	return URIPrefix + GetTrustDomain() + "/" + identity
 }
+
+// ExpandWithTrustDomains returns the given SPIFFE identities, together with the same identities in each
+// of the trust domain aliases. Only identities in the local trust domain or one of its aliases are expanded,
+// so that peers still presenting an identity from a trust domain being migrated are accepted.
+func ExpandWithTrustDomains(spiffeIdentities []string, trustDomainAliases []string) []string {
+	if len(trustDomainAliases) == 0 {
+		return spiffeIdentities
+	}
+	trustDomains := append([]string{GetTrustDomain()}, trustDomainAliases...)
+
+	seen := make(map[string]struct{})
+	out := make([]string, 0, len(spiffeIdentities)*len(trustDomains))
+	add := func(id string) {
+		if _, f := seen[id]; !f {
+			seen[id] = struct{}{}
+			out = append(out, id)
+		}
+	}
+	for _, id := range spiffeIdentities {
+		add(id)
+		if !strings.HasPrefix(id, URIPrefix) {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(id, URIPrefix), "/", 2)
+		if len(parts) != 2 || !containsString(trustDomains, parts[0]) {
+			continue
+		}
+		for _, td := range trustDomains {
+			add(URIPrefix + td + "/" + parts[1])
+		}
+	}
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -141,3 +141,53 @@ func TestGenCustomSpiffe(t *testing.T) {
 		}
 	}
 }
+
+func TestExpandWithTrustDomains(t *testing.T) {
+	oldTrustDomain := GetTrustDomain()
+	defer SetTrustDomain(oldTrustDomain)
+	SetTrustDomain("new-td")
+
+	testCases := []struct {
+		name     string
+		ids      []string
+		aliases  []string
+		expected []string
+	}{
+		{
+			name:     "no aliases",
+			ids:      []string{"spiffe://new-td/ns/foo/sa/bar"},
+			expected: []string{"spiffe://new-td/ns/foo/sa/bar"},
+		},
+		{
+			name:    "local trust domain",
+			ids:     []string{"spiffe://new-td/ns/foo/sa/bar"},
+			aliases: []string{"old-td", "older-td"},
+			expected: []string{
+				"spiffe://new-td/ns/foo/sa/bar",
+				"spiffe://old-td/ns/foo/sa/bar",
+				"spiffe://older-td/ns/foo/sa/bar",
+			},
+		},
+		{
+			name:     "alias trust domain",
+			ids:      []string{"spiffe://old-td/ns/foo/sa/bar", "spiffe://new-td/ns/foo/sa/bar"},
+			aliases:  []string{"old-td"},
+			expected: []string{"spiffe://old-td/ns/foo/sa/bar", "spiffe://new-td/ns/foo/sa/bar"},
+		},
+		{
+			name:     "foreign trust domain and non spiffe identity",
+			ids:      []string{"spiffe://other-td/ns/foo/sa/bar", "bar"},
+			aliases:  []string{"old-td"},
+			expected: []string{"spiffe://other-td/ns/foo/sa/bar", "bar"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ExpandWithTrustDomains(tc.ids, tc.aliases)
+			if strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("ExpandWithTrustDomains(%v, %v) => %v, want %v", tc.ids, tc.aliases, got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package wasm fetches the remote Wasm modules of the proxy, so that Envoy doesn't have to reach
+// the internet to load them.
+package wasm
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/pkg/log"
+)
+
+const (
+	// DefaultFetchTimeout is the default timeout of the download of a module or its signature.
+	DefaultFetchTimeout = 30 * time.Second
+
+	// DefaultMaxModuleSize is the default maximum size of a module.
+	DefaultMaxModuleSize = 64 * 1024 * 1024
+
+	// SignatureSuffix is appended to the URL of a module to fetch its signature.
+	SignatureSuffix = ".sig"
+
+	// maxSignatureSize is the maximum size of a signature.
+	maxSignatureSize = 4096
+
+	moduleExtension = ".wasm"
+)
+
+var wasmLog = log.RegisterScope("wasm", "Wasm module fetching", 0)
+
+// Options configures the fetching of the modules.
+type Options struct {
+	// PublicKey verifies the signatures of the modules, fetched from the URL of the module with
+	// SignatureSuffix. The signatures are not verified if PublicKey is nil.
+	PublicKey crypto.PublicKey
+
+	// FetchTimeout is the timeout of the download of a module or its signature,
+	// DefaultFetchTimeout if not set.
+	FetchTimeout time.Duration
+
+	// MaxModuleSize is the maximum size of a module, DefaultMaxModuleSize if not set.
+	MaxModuleSize int64
+}
+
+// LocalFileCache fetches the remote modules and caches them in a local directory, by checksum.
+// The modules cached on disk are reused across restarts of the agent.
+type LocalFileCache struct {
+	dir     string
+	options Options
+	client  *http.Client
+
+	// mutex serializes the fetches, so that a module is only fetched once.
+	mutex sync.Mutex
+	// modules are the paths of the cached modules, by checksum
+	modules map[string]string
+}
+
+// NewLocalFileCache creates a cache of the modules in the directory, creating it if needed.
+func NewLocalFileCache(dir string, options Options) (*LocalFileCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create the Wasm module cache directory %s: %v", dir, err)
+	}
+	if options.FetchTimeout == 0 {
+		options.FetchTimeout = DefaultFetchTimeout
+	}
+	if options.MaxModuleSize == 0 {
+		options.MaxModuleSize = DefaultMaxModuleSize
+	}
+	return &LocalFileCache{
+		dir:     dir,
+		options: options,
+		client:  &http.Client{Timeout: options.FetchTimeout},
+		modules: make(map[string]string),
+	}, nil
+}
+
+// Get returns the path of the module with the given SHA-256 checksum, fetching it from the URL
+// if it is not cached yet.
+func (c *LocalFileCache) Get(url, checksum string) (string, error) {
+	checksum = strings.ToLower(checksum)
+	if b, err := hex.DecodeString(checksum); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid SHA-256 checksum %q", checksum)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if path, f := c.modules[checksum]; f {
+		cacheLookups.With(hitTag.Value("true")).Increment()
+		return path, nil
+	}
+
+	// The module may have been cached before a restart of the agent.
+	path := filepath.Join(c.dir, checksum+moduleExtension)
+	if module, err := ioutil.ReadFile(path); err == nil && sha256Hex(module) == checksum {
+		cacheLookups.With(hitTag.Value("true")).Increment()
+		c.addModule(checksum, path)
+		return path, nil
+	}
+	cacheLookups.With(hitTag.Value("false")).Increment()
+
+	module, err := c.fetch(url, c.options.MaxModuleSize)
+	if err != nil {
+		remoteFetches.With(resultTag.Value(downloadFailure)).Increment()
+		return "", fmt.Errorf("failed to fetch Wasm module from %s: %v", url, err)
+	}
+	if got := sha256Hex(module); got != checksum {
+		remoteFetches.With(resultTag.Value(checksumMismatch)).Increment()
+		return "", fmt.Errorf("unexpected checksum %s of Wasm module fetched from %s, want %s", got, url, checksum)
+	}
+	if c.options.PublicKey != nil {
+		signature, err := c.fetch(url+SignatureSuffix, maxSignatureSize)
+		if err != nil {
+			remoteFetches.With(resultTag.Value(downloadFailure)).Increment()
+			return "", fmt.Errorf("failed to fetch Wasm module signature from %s: %v", url+SignatureSuffix, err)
+		}
+		if err := verifySignature(c.options.PublicKey, module, signature); err != nil {
+			remoteFetches.With(resultTag.Value(signatureFailure)).Increment()
+			return "", fmt.Errorf("invalid signature of Wasm module fetched from %s: %v", url, err)
+		}
+	}
+
+	if err := writeFileAtomically(path, module); err != nil {
+		return "", fmt.Errorf("failed to cache Wasm module fetched from %s: %v", url, err)
+	}
+	remoteFetches.With(resultTag.Value(fetchSuccess)).Increment()
+	wasmLog.Infof("fetched Wasm module %s from %s", checksum, url)
+	c.addModule(checksum, path)
+	return path, nil
+}
+
+func (c *LocalFileCache) addModule(checksum, path string) {
+	c.modules[checksum] = path
+	cacheEntries.Record(float64(len(c.modules)))
+}
+
+// fetch downloads the content of the URL, up to maxSize bytes.
+func (c *LocalFileCache) fetch(url string, maxSize int64) ([]byte, error) {
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxSize {
+		return nil, fmt.Errorf("content larger than %d bytes", maxSize)
+	}
+	return body, nil
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeFileAtomically writes the file through a temporary file, so that Envoy never reads a
+// partially written module.
+func writeFileAtomically(path string, content []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // nolint: errcheck
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,201 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+var testModule = []byte("\x00asm\x01\x00\x00\x00test module")
+
+type moduleServer struct {
+	*httptest.Server
+	files    map[string][]byte
+	requests int32
+}
+
+func newModuleServer(files map[string][]byte) *moduleServer {
+	s := &moduleServer{files: files}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.requests, 1)
+		content, f := s.files[r.URL.Path]
+		if !f {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	return s
+}
+
+func newCache(t *testing.T, options Options) (*LocalFileCache, string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "wasm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewLocalFileCache(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, dir
+}
+
+func TestLocalFileCache(t *testing.T) {
+	srv := newModuleServer(map[string][]byte{"/module.wasm": testModule})
+	defer srv.Close()
+	c, dir := newCache(t, Options{})
+	defer os.RemoveAll(dir)
+	checksum := sha256Hex(testModule)
+
+	path, err := c.Get(srv.URL+"/module.wasm", strings.ToUpper(checksum))
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if got, err := ioutil.ReadFile(path); err != nil || !bytes.Equal(got, testModule) {
+		t.Fatalf("Get() cached %q (%v), want the module", got, err)
+	}
+
+	// The module is cached, and reused by a new cache in the same directory.
+	if _, err := c.Get(srv.URL+"/module.wasm", checksum); err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	restarted, err := NewLocalFileCache(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := restarted.Get(srv.URL+"/module.wasm", checksum); err != nil || got != path {
+		t.Fatalf("Get() => %q (%v), want %q", got, err, path)
+	}
+	if n := atomic.LoadInt32(&srv.requests); n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+}
+
+func TestLocalFileCacheErrors(t *testing.T) {
+	srv := newModuleServer(map[string][]byte{"/module.wasm": testModule})
+	defer srv.Close()
+	c, dir := newCache(t, Options{MaxModuleSize: 8})
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name     string
+		url      string
+		checksum string
+		wantErr  string
+	}{
+		{
+			name:     "invalid checksum",
+			url:      srv.URL + "/module.wasm",
+			checksum: "1234",
+			wantErr:  "invalid SHA-256 checksum",
+		},
+		{
+			name:     "not found",
+			url:      srv.URL + "/missing.wasm",
+			checksum: sha256Hex(testModule),
+			wantErr:  "unexpected status code 404",
+		},
+		{
+			name:     "too large",
+			url:      srv.URL + "/module.wasm",
+			checksum: sha256Hex(testModule),
+			wantErr:  "content larger than 8 bytes",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := c.Get(tc.url, tc.checksum)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Get() => error %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+
+	c, dir = newCache(t, Options{})
+	defer os.RemoveAll(dir)
+	_, err := c.Get(srv.URL+"/module.wasm", sha256Hex([]byte("other module")))
+	if err == nil || !strings.Contains(err.Error(), "unexpected checksum") {
+		t.Errorf("Get() => error %v, want checksum mismatch", err)
+	}
+}
+
+func TestLocalFileCacheSignature(t *testing.T) {
+	digest := sha256.Sum256(testModule)
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecSig, _ := ecKey.Sign(rand.Reader, digest[:], crypto.SHA256)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsaSig, _ := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	edSig := ed25519.Sign(edKey, testModule)
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	cases := []struct {
+		name      string
+		publicKey crypto.PublicKey
+		signature []byte
+		wantErr   bool
+	}{
+		{name: "ecdsa", publicKey: &ecKey.PublicKey, signature: ecSig},
+		{name: "rsa", publicKey: &rsaKey.PublicKey, signature: rsaSig},
+		{name: "ed25519", publicKey: edPub, signature: edSig},
+		{name: "wrong key", publicKey: otherPub, signature: edSig, wantErr: true},
+		{name: "missing signature", publicKey: edPub, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			files := map[string][]byte{"/module.wasm": testModule}
+			if tc.signature != nil {
+				files["/module.wasm"+SignatureSuffix] = tc.signature
+			}
+			srv := newModuleServer(files)
+			defer srv.Close()
+
+			// The public key goes through its PEM encoding.
+			der, err := x509.MarshalPKIXPublicKey(tc.publicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			publicKey, err := ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+			if err != nil {
+				t.Fatalf("ParsePublicKey() failed: %v", err)
+			}
+			c, dir := newCache(t, Options{PublicKey: publicKey})
+			defer os.RemoveAll(dir)
+
+			_, err = c.Get(srv.URL+"/module.wasm", sha256Hex(testModule))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("Get() => error %v, want error: %v", err, tc.wantErr)
+			}
+		})
+	}
+}
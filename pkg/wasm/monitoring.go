@@ -0,0 +1,56 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+const (
+	fetchSuccess     = "success"
+	downloadFailure  = "download_failure"
+	checksumMismatch = "checksum_mismatch"
+	signatureFailure = "signature_failure"
+)
+
+var (
+	resultTag = monitoring.MustCreateLabel("result")
+	hitTag    = monitoring.MustCreateLabel("hit")
+
+	remoteFetches = monitoring.NewSum(
+		"wasm_remote_fetch_count",
+		"The number of remote fetches of Wasm modules, by result.",
+		monitoring.WithLabels(resultTag),
+	)
+
+	cacheLookups = monitoring.NewSum(
+		"wasm_cache_lookup_count",
+		"The number of lookups of Wasm modules in the local cache, by hit.",
+		monitoring.WithLabels(hitTag),
+	)
+
+	cacheEntries = monitoring.NewGauge(
+		"wasm_cache_entries",
+		"The number of Wasm modules in the local cache.",
+	)
+)
+
+func init() {
+	monitoring.MustRegister(
+		remoteFetches,
+		cacheLookups,
+		cacheEntries,
+	)
+}
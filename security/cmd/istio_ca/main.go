@@ -213,7 +213,8 @@ func initCLI() {
 	// Configuration if Citadel accepts key/cert configured through arguments.
 	flags.StringVar(&opts.certChainFile, "cert-chain", "", "Path to the certificate chain file.")
 	flags.StringVar(&opts.signingCertFile, "signing-cert", "", "Path to the CA signing certificate file.")
-	flags.StringVar(&opts.signingKeyFile, "signing-key", "", "Path to the CA signing key file.")
+	flags.StringVar(&opts.signingKeyFile, "signing-key", "", "Path to the CA signing key file, or the URI of the signing key in a key storage "+
+		"backend, e.g. k8s://istio-system/cacerts/ca-key.pem.")
 
 	// Both self-signed or non-self-signed Citadel may take a root certificate file with a list of root certificates.
 	flags.StringVar(&opts.rootCertFile, "root-cert", "", "Path to the root certificate file.")
@@ -469,7 +470,7 @@ func createCA(client corev1.CoreV1Interface) *ca.IstioCA {
 			opts.selfSignedRootCertCheckInterval, opts.workloadCertTTL,
 			opts.maxWorkloadCertTTL, spiffe.GetTrustDomain(), opts.dualUse,
 			opts.istioCaStorageNamespace, checkInterval, client, opts.rootCertFile,
-			opts.enableJitterForRootCertRotator)
+			opts.enableJitterForRootCertRotator, "")
 		if err != nil {
 			fatalf("Failed to create a self-signed Citadel (error: %v)", err)
 		}
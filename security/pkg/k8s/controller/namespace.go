@@ -0,0 +1,204 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// CACertNamespaceConfigMap is the name of the ConfigMap holding the CA root cert in each namespace.
+	CACertNamespaceConfigMap = "istio-ca-root-cert"
+	// CACertNamespaceConfigMapDataName is the key of the root cert in the ConfigMap.
+	CACertNamespaceConfigMapDataName = "root-cert.pem"
+
+	// The namespaces are resynced periodically, to pick up the rotations of the root cert.
+	rootCertResyncPeriod = time.Minute
+)
+
+// NamespaceController maintains the CACertNamespaceConfigMap ConfigMap in the namespaces, with the
+// current root cert of the CA. Workloads and off-mesh clients can mount it to trust the mesh.
+type NamespaceController struct {
+	ca   certificateAuthority
+	core corev1.CoreV1Interface
+
+	// Controller and store for namespace objects.
+	namespaceController cache.Controller
+	namespaceStore      cache.Store
+
+	// Controller and store for the root cert ConfigMaps.
+	configMapController cache.Controller
+	configMapStore      cache.Store
+}
+
+// NewNamespaceController returns a pointer to a newly constructed NamespaceController instance. Only the
+// namespaces matching the label selector get the ConfigMap, all of them if it is empty.
+func NewNamespaceController(ca certificateAuthority, core corev1.CoreV1Interface,
+	namespaceSelector string) (*NamespaceController, error) {
+	selector, err := labels.Parse(namespaceSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespace selector %q: %v", namespaceSelector, err)
+	}
+
+	c := &NamespaceController{
+		ca:   ca,
+		core: core,
+	}
+
+	c.namespaceStore, c.namespaceController =
+		cache.NewInformer(&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.LabelSelector = selector.String()
+				return core.Namespaces().List(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.LabelSelector = selector.String()
+				return core.Namespaces().Watch(options)
+			},
+		}, &v1.Namespace{}, rootCertResyncPeriod, cache.ResourceEventHandlerFuncs{
+			AddFunc: c.namespaceAdded,
+			UpdateFunc: func(_, newObj interface{}) {
+				c.namespaceAdded(newObj)
+			},
+		})
+
+	configMapSelector := fields.OneTermEqualSelector("metadata.name", CACertNamespaceConfigMap).String()
+	c.configMapStore, c.configMapController =
+		cache.NewInformer(&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.FieldSelector = configMapSelector
+				return core.ConfigMaps(metav1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.FieldSelector = configMapSelector
+				return core.ConfigMaps(metav1.NamespaceAll).Watch(options)
+			},
+		}, &v1.ConfigMap{}, 0, cache.ResourceEventHandlerFuncs{
+			UpdateFunc: c.configMapChanged,
+			DeleteFunc: c.configMapDeleted,
+		})
+
+	return c, nil
+}
+
+// Run starts the NamespaceController until a value is sent to stopCh.
+func (nc *NamespaceController) Run(stopCh <-chan struct{}) {
+	go nc.configMapController.Run(stopCh)
+
+	// The ConfigMaps already created are looked up in the store, to only update the stale ones.
+	cache.WaitForCacheSync(stopCh, nc.configMapController.HasSynced)
+
+	go nc.namespaceController.Run(stopCh)
+}
+
+// Handles the event where a namespace is added, or resynced.
+func (nc *NamespaceController) namespaceAdded(obj interface{}) {
+	ns, ok := obj.(*v1.Namespace)
+	if !ok || ns.Status.Phase == v1.NamespaceTerminating {
+		return
+	}
+	if err := nc.upsertConfigMap(ns.GetName()); err != nil {
+		k8sControllerLog.Errorf("Failed to write the root cert ConfigMap in namespace %s (error %v)",
+			ns.GetName(), err)
+	}
+}
+
+// Handles the event where a root cert ConfigMap is modified, e.g. by a user, restoring it.
+func (nc *NamespaceController) configMapChanged(_, newObj interface{}) {
+	cm := newObj.(*v1.ConfigMap)
+	if cm.Data[CACertNamespaceConfigMapDataName] == string(nc.ca.GetCAKeyCertBundle().GetRootCertPem()) {
+		return
+	}
+	nc.namespaceAdded(nc.managedNamespace(cm.GetNamespace()))
+}
+
+// Handles the event where a root cert ConfigMap is deleted, recreating it.
+func (nc *NamespaceController) configMapDeleted(obj interface{}) {
+	cm, ok := obj.(*v1.ConfigMap)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		if cm, ok = tombstone.Obj.(*v1.ConfigMap); !ok {
+			return
+		}
+	}
+	nc.namespaceAdded(nc.managedNamespace(cm.GetNamespace()))
+}
+
+// managedNamespace returns the namespace if it matches the selector, nil otherwise.
+func (nc *NamespaceController) managedNamespace(name string) interface{} {
+	ns, exists, err := nc.namespaceStore.GetByKey(name)
+	if err != nil || !exists {
+		return nil
+	}
+	return ns
+}
+
+// upsertConfigMap writes the current root cert to the ConfigMap of the namespace, if it is missing
+// or stale.
+func (nc *NamespaceController) upsertConfigMap(namespace string) error {
+	rootCert := string(nc.ca.GetCAKeyCertBundle().GetRootCertPem())
+
+	obj, exists, err := nc.configMapStore.GetByKey(namespace + "/" + CACertNamespaceConfigMap)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		cm := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      CACertNamespaceConfigMap,
+				Namespace: namespace,
+			},
+			Data: map[string]string{
+				CACertNamespaceConfigMapDataName: rootCert,
+			},
+		}
+		if _, err = nc.core.ConfigMaps(namespace).Create(cm); err == nil || !errors.IsAlreadyExists(err) {
+			return err
+		}
+		// Created since the store was synced, update it instead.
+		if obj, err = nc.core.ConfigMaps(namespace).Get(CACertNamespaceConfigMap, metav1.GetOptions{}); err != nil {
+			return err
+		}
+	}
+
+	cm := obj.(*v1.ConfigMap)
+	if cm.Data[CACertNamespaceConfigMapDataName] == rootCert {
+		return nil
+	}
+	cm = cm.DeepCopy()
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[CACertNamespaceConfigMapDataName] = rootCert
+	if _, err = nc.core.ConfigMaps(namespace).Update(cm); err != nil {
+		return err
+	}
+	k8sControllerLog.Infof("Updated the root cert ConfigMap in namespace %s", namespace)
+	return nil
+}
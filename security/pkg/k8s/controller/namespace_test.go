@@ -0,0 +1,100 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	mockutil "istio.io/istio/security/pkg/pki/util/mock"
+)
+
+func TestNamespaceController(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		createNS("selected", map[string]string{"istio-injection": "enabled"}),
+		createNS("other", nil),
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: CACertNamespaceConfigMap, Namespace: "stale"},
+			Data:       map[string]string{CACertNamespaceConfigMapDataName: "stale root cert"},
+		},
+		createNS("stale", map[string]string{"istio-injection": "enabled"}),
+	)
+	ca := createFakeCA()
+
+	if _, err := NewNamespaceController(ca, client.CoreV1(), "invalid selector="); err == nil {
+		t.Fatal("expected an error for an invalid selector")
+	}
+	nc, err := NewNamespaceController(ca, client.CoreV1(), "istio-injection=enabled")
+	if err != nil {
+		t.Fatalf("failed to create the namespace controller: %v", err)
+	}
+	stop := make(chan struct{})
+	nc.Run(stop)
+
+	expectRootCert := func(namespace, want string) {
+		t.Helper()
+		var got string
+		for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+			if got, err = rootCertInConfigMap(client, namespace); err == nil && got == want {
+				return
+			}
+		}
+		t.Fatalf("unexpected root cert in namespace %s: got %q (error %v), want %q", namespace, got, err, want)
+	}
+
+	expectRootCert("selected", string(rootCert))
+	expectRootCert("stale", string(rootCert))
+	if _, err := rootCertInConfigMap(client, "other"); err == nil {
+		t.Error("unexpected root cert ConfigMap in a namespace not matching the selector")
+	}
+
+	// A new namespace gets the ConfigMap.
+	if _, err := client.CoreV1().Namespaces().Create(
+		createNS("new", map[string]string{"istio-injection": "enabled"})); err != nil {
+		t.Fatal(err)
+	}
+	expectRootCert("new", string(rootCert))
+
+	// A deleted ConfigMap is recreated.
+	if err := client.CoreV1().ConfigMaps("selected").Delete(CACertNamespaceConfigMap, &metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expectRootCert("selected", string(rootCert))
+
+	// A rotated root cert is propagated on the next resync, simulated with the controller stopped.
+	close(stop)
+	ca.KeyCertBundle = &mockutil.FakeKeyCertBundle{RootCertBytes: []byte("rotated root cert")}
+	for _, ns := range []string{"selected", "stale", "new"} {
+		nc.namespaceAdded(nc.managedNamespace(ns))
+		expectRootCert(ns, "rotated root cert")
+	}
+}
+
+func rootCertInConfigMap(client *fake.Clientset, namespace string) (string, error) {
+	cm, err := client.CoreV1().ConfigMaps(namespace).Get(CACertNamespaceConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	rootCert, ok := cm.Data[CACertNamespaceConfigMapDataName]
+	if !ok {
+		return "", fmt.Errorf("no %s in the ConfigMap", CACertNamespaceConfigMapDataName)
+	}
+	return rootCert, nil
+}
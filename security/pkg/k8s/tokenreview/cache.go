@@ -0,0 +1,117 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tokenreview
+
+import (
+	"crypto/sha256"
+	"time"
+
+	"istio.io/pkg/cache"
+)
+
+// CacheOptions configures the caching of the token reviews.
+type CacheOptions struct {
+	// TTL is the duration for which an authenticated token is cached, capped by the expiration of
+	// the token. The token reviews are not cached if TTL is zero.
+	TTL time.Duration
+
+	// NegativeTTL is the duration for which a rejected token is cached. The rejected tokens are not
+	// cached if NegativeTTL is zero. The failures to review a token are never cached.
+	NegativeTTL time.Duration
+
+	// MaxEntries is the maximum number of cached tokens, the least recently used tokens being
+	// evicted first. DefaultCacheMaxEntries is used if not set.
+	MaxEntries int32
+}
+
+// DefaultCacheMaxEntries is the default maximum number of cached tokens.
+const DefaultCacheMaxEntries = 10000
+
+type jwtValidator interface {
+	ValidateK8sJwt(targetToken string) ([]string, error)
+}
+
+// cachedReview is the result of the review of a token.
+type cachedReview struct {
+	id        []string
+	err       error
+	expiresAt time.Time
+}
+
+// CachedK8sSvcAcctAuthn caches the k8s JWT validations of a K8sSvcAcctAuthn, so that the proxies
+// reconnecting with the same token don't hit the TokenReview API of the API server.
+type CachedK8sSvcAcctAuthn struct {
+	validator jwtValidator
+	options   CacheOptions
+	reviews   cache.ExpiringCache
+
+	// now is mocked in the tests
+	now func() time.Time
+}
+
+// NewCachedK8sSvcAcctAuthn creates a cache of the token reviews done by the validator.
+func NewCachedK8sSvcAcctAuthn(validator jwtValidator, options CacheOptions) *CachedK8sSvcAcctAuthn {
+	if options.MaxEntries <= 0 {
+		options.MaxEntries = DefaultCacheMaxEntries
+	}
+	return &CachedK8sSvcAcctAuthn{
+		validator: validator,
+		options:   options,
+		reviews:   cache.NewLRU(options.TTL, options.TTL, options.MaxEntries),
+		now:       time.Now,
+	}
+}
+
+// ValidateK8sJwt validates a k8s JWT, using the cached review of the token if any.
+// Return {<namespace>, <serviceaccountname>} in the targetToken when the validation passes.
+// Otherwise, return the error.
+func (c *CachedK8sSvcAcctAuthn) ValidateK8sJwt(targetToken string) ([]string, error) {
+	// The tokens are credentials, only their hashes are kept.
+	key := sha256.Sum256([]byte(targetToken))
+	now := c.now()
+	if v, ok := c.reviews.Get(key); ok {
+		review := v.(*cachedReview)
+		// The cache only evicts the expired entries periodically.
+		if now.Before(review.expiresAt) {
+			if review.err != nil {
+				tokenReviewCacheHits.With(resultTag.Value(rejectedResult)).Increment()
+			} else {
+				tokenReviewCacheHits.With(resultTag.Value(authenticatedResult)).Increment()
+			}
+			return review.id, review.err
+		}
+		c.reviews.Remove(key)
+	}
+	tokenReviewCacheMisses.Increment()
+
+	id, err := c.validator.ValidateK8sJwt(targetToken)
+	var ttl time.Duration
+	switch {
+	case err == nil:
+		ttl = c.options.TTL
+		// Don't authenticate a token past its expiration.
+		if payload, perr := parseJwtPayload(targetToken); perr == nil {
+			if untilExp := time.Unix(payload.Exp, 0).Sub(now); untilExp < ttl {
+				ttl = untilExp
+			}
+		}
+	case isRejection(err):
+		ttl = c.options.NegativeTTL
+	}
+	if ttl > 0 {
+		c.reviews.SetWithExpiration(key, &cachedReview{id: id, err: err, expiresAt: now.Add(ttl)}, ttl)
+	}
+	return id, err
+}
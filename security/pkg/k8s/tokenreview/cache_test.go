@@ -0,0 +1,97 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tokenreview
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type mockValidator struct {
+	results map[string]error
+	calls   int
+}
+
+func (v *mockValidator) ValidateK8sJwt(targetToken string) ([]string, error) {
+	v.calls++
+	if err := v.results[targetToken]; err != nil {
+		return nil, err
+	}
+	return []string{"default", "sa"}, nil
+}
+
+func testJwt(name string, exp time.Time) string {
+	payload := fmt.Sprintf(`{"aud":["istio-ca"],"exp":%d,"sub":%q}`, exp.Unix(), name)
+	return "header." + base64.RawStdEncoding.EncodeToString([]byte(payload)) + ".signature"
+}
+
+func TestCachedK8sSvcAcctAuthn(t *testing.T) {
+	start := time.Now()
+	valid := testJwt("valid", start.Add(time.Hour))
+	expiring := testJwt("expiring", start.Add(2*time.Minute+10*time.Second))
+	rejected := testJwt("rejected", start.Add(time.Hour))
+	failed := testJwt("failed", start.Add(time.Hour))
+
+	validator := &mockValidator{
+		results: map[string]error{
+			rejected: rejectf("the token is not authenticated"),
+			failed:   fmt.Errorf("failed to get a token review response"),
+		},
+	}
+	c := NewCachedK8sSvcAcctAuthn(validator, CacheOptions{
+		TTL:         time.Minute,
+		NegativeTTL: 5 * time.Second,
+	})
+
+	// The cases run in order, at the given time since start.
+	cases := []struct {
+		name      string
+		token     string
+		at        time.Duration
+		wantErr   bool
+		wantCalls int
+	}{
+		{name: "valid token reviewed", token: valid, wantCalls: 1},
+		{name: "valid token cached", token: valid, at: 30 * time.Second, wantCalls: 1},
+		{name: "valid token cache expired", token: valid, at: 2 * time.Minute, wantCalls: 2},
+		{name: "expiring token reviewed", token: expiring, at: 2 * time.Minute, wantCalls: 3},
+		{name: "expiring token cached until expiration", token: expiring, at: 2*time.Minute + 5*time.Second, wantCalls: 3},
+		{name: "expiring token reviewed after expiration", token: expiring, at: 2*time.Minute + 15*time.Second, wantCalls: 4},
+		{name: "rejected token reviewed", token: rejected, at: 3 * time.Minute, wantErr: true, wantCalls: 5},
+		{name: "rejected token cached", token: rejected, at: 3*time.Minute + 2*time.Second, wantErr: true, wantCalls: 5},
+		{name: "rejected token cache expired", token: rejected, at: 3*time.Minute + 10*time.Second, wantErr: true, wantCalls: 6},
+		{name: "review failure reviewed", token: failed, at: 4 * time.Minute, wantErr: true, wantCalls: 7},
+		{name: "review failure not cached", token: failed, at: 4 * time.Minute, wantErr: true, wantCalls: 8},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c.now = func() time.Time { return start.Add(tc.at) }
+
+			id, err := c.ValidateK8sJwt(tc.token)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(id, []string{"default", "sa"}) {
+				t.Errorf("got id %v, want [default sa]", id)
+			}
+			if validator.calls != tc.wantCalls {
+				t.Errorf("got %d token reviews, want %d", validator.calls, tc.wantCalls)
+			}
+		})
+	}
+}
@@ -45,6 +45,22 @@ type saValidationRequest struct {
 	Spec       specForSaValidationRequest `json:"spec"`
 }
 
+// rejectionError is returned by ValidateK8sJwt when the token is rejected, as opposed to the
+// failures to review the token.
+type rejectionError struct {
+	error
+}
+
+func rejectf(format string, a ...interface{}) error {
+	return rejectionError{fmt.Errorf(format, a...)}
+}
+
+// isRejection returns true if the error reports the rejection of a token.
+func isRejection(err error) bool {
+	_, ok := err.(rejectionError)
+	return ok
+}
+
 // K8sSvcAcctAuthn authenticates a k8s service account (JWT) through the k8s TokenReview API.
 type K8sSvcAcctAuthn struct {
 	apiServerAddr string
@@ -120,10 +136,10 @@ func (authn *K8sSvcAcctAuthn) ValidateK8sJwt(targetToken string) ([]string, erro
 	// SDS requires JWT to be trustworthy (has aud, exp, and mounted to the pod).
 	isTrustworthyJwt, err := isTrustworthyJwt(targetToken)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check if jwt is trustworthy: %v", err)
+		return nil, rejectf("failed to check if jwt is trustworthy: %v", err)
 	}
 	if !isTrustworthyJwt {
-		return nil, fmt.Errorf("legacy JWTs are not allowed and the provided jwt is not trustworthy")
+		return nil, rejectf("legacy JWTs are not allowed and the provided jwt is not trustworthy")
 	}
 
 	resp, err := authn.reviewServiceAccountAtK8sAPIServer(targetToken)
@@ -147,7 +163,7 @@ func (authn *K8sSvcAcctAuthn) ValidateK8sJwt(targetToken string) ([]string, erro
 		return nil, fmt.Errorf("unmarshal response body returns an error: %v", err)
 	}
 	if tokenReview.Status.Error != "" {
-		return nil, fmt.Errorf("the service account authentication returns an error: %v", tokenReview.Status.Error)
+		return nil, rejectf("the service account authentication returns an error: %v", tokenReview.Status.Error)
 	}
 	// An example SA token:
 	// {"alg":"RS256","typ":"JWT"}
@@ -170,7 +186,7 @@ func (authn *K8sSvcAcctAuthn) ValidateK8sJwt(targetToken string) ([]string, erro
 	// }
 
 	if !tokenReview.Status.Authenticated {
-		return nil, fmt.Errorf("the token is not authenticated")
+		return nil, rejectf("the token is not authenticated")
 	}
 	inServiceAccountGroup := false
 	for _, group := range tokenReview.Status.User.Groups {
@@ -180,13 +196,13 @@ func (authn *K8sSvcAcctAuthn) ValidateK8sJwt(targetToken string) ([]string, erro
 		}
 	}
 	if !inServiceAccountGroup {
-		return nil, fmt.Errorf("the token is not a service account")
+		return nil, rejectf("the token is not a service account")
 	}
 	// "username" is in the form of system:serviceaccount:{namespace}:{service account name}",
 	// e.g., "username":"system:serviceaccount:default:example-pod-sa"
 	subStrings := strings.Split(tokenReview.Status.User.Username, ":")
 	if len(subStrings) != 4 {
-		return nil, fmt.Errorf("invalid username field in the token review result")
+		return nil, rejectf("invalid username field in the token review result")
 	}
 	namespace := subStrings[2]
 	saName := subStrings[3]
@@ -194,30 +210,38 @@ func (authn *K8sSvcAcctAuthn) ValidateK8sJwt(targetToken string) ([]string, erro
 	return []string{namespace, saName}, nil
 }
 
+type trustWorthyJwtPayload struct {
+	Aud []string `json:"aud"`
+	Exp int64    `json:"exp"`
+}
+
 // isTrustworthyJwt checks if a jwt is a trustworthy jwt type.
 func isTrustworthyJwt(jwt string) (bool, error) {
-	type trustWorthyJwtPayload struct {
-		Aud []string `json:"aud"`
-		Exp int      `json:"exp"`
+	structuredPayload, err := parseJwtPayload(jwt)
+	if err != nil {
+		return false, err
 	}
+	// Trustworthy JWTs are JWTs with expiration and audiences, whereas legacy JWTs do not have these
+	// fields.
+	return structuredPayload.Aud != nil && structuredPayload.Exp > 0, nil
+}
 
+func parseJwtPayload(jwt string) (*trustWorthyJwtPayload, error) {
 	jwtSplit := strings.Split(jwt, ".")
 	if len(jwtSplit) != 3 {
-		return false, fmt.Errorf("jwt may be invalid: %s", jwt)
+		return nil, fmt.Errorf("jwt may be invalid: %s", jwt)
 	}
 	payload := jwtSplit[1]
 
 	payloadBytes, err := base64.RawStdEncoding.DecodeString(payload)
 	if err != nil {
-		return false, fmt.Errorf("failed to decode jwt: %v", err.Error())
+		return nil, fmt.Errorf("failed to decode jwt: %v", err.Error())
 	}
 
 	structuredPayload := &trustWorthyJwtPayload{}
 	err = json.Unmarshal(payloadBytes, &structuredPayload)
 	if err != nil {
-		return false, fmt.Errorf("failed to unmarshal jwt: %v", err.Error())
+		return nil, fmt.Errorf("failed to unmarshal jwt: %v", err.Error())
 	}
-	// Trustworthy JWTs are JWTs with expiration and audiences, whereas legacy JWTs do not have these
-	// fields.
-	return structuredPayload.Aud != nil && structuredPayload.Exp > 0, nil
+	return structuredPayload, nil
 }
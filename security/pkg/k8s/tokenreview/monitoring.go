@@ -0,0 +1,46 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tokenreview
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+const (
+	authenticatedResult = "authenticated"
+	rejectedResult      = "rejected"
+)
+
+var (
+	resultTag = monitoring.MustCreateLabel("result")
+
+	tokenReviewCacheHits = monitoring.NewSum(
+		"citadel_server_token_review_cache_hits",
+		"The number of k8s JWT validations served from the token review cache, by result.",
+		monitoring.WithLabels(resultTag),
+	)
+
+	tokenReviewCacheMisses = monitoring.NewSum(
+		"citadel_server_token_review_cache_misses",
+		"The number of k8s JWT validations reviewed at the k8s API server.",
+	)
+)
+
+func init() {
+	monitoring.MustRegister(
+		tokenReviewCacheHits,
+		tokenReviewCacheMisses,
+	)
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// JitteredBackoff returns a random duration up to the exponential backoff of the retry, which is
+// initial * 2^(retry-1) capped at max. A max of 0 does not cap it.
+func JitteredBackoff(initial, max time.Duration, retry int64) time.Duration {
+	backoff := initial
+	for i := int64(1); i < retry && (max <= 0 || backoff < max); i++ {
+		backoff *= 2
+	}
+	if max > 0 && backoff > max {
+		backoff = max
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(backoff)))
+}
+
+// circuitBreaker stops sending requests to the CA after consecutive failures, until a cooldown
+// elapsed, so that an unavailable CA fails the requests fast rather than on their timeout.
+// After the cooldown, a request is let through: its success closes the breaker again.
+type circuitBreaker struct {
+	// failures is the number of consecutive failures opening the breaker, 0 disables it.
+	failures int
+	cooldown time.Duration
+
+	mutex               sync.Mutex
+	consecutiveFailures int
+	openUntil           time.Time
+}
+
+// allow returns an error if the breaker is open at time t.
+func (cb *circuitBreaker) allow(t time.Time) error {
+	if cb == nil || cb.failures <= 0 {
+		return nil
+	}
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	if t.Before(cb.openUntil) {
+		return fmt.Errorf("CA circuit breaker open after %d consecutive failures, retrying after %v",
+			cb.consecutiveFailures, cb.openUntil)
+	}
+	return nil
+}
+
+// success records a successful request, closing the breaker.
+func (cb *circuitBreaker) success() {
+	if cb == nil || cb.failures <= 0 {
+		return
+	}
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.consecutiveFailures = 0
+	cb.openUntil = time.Time{}
+}
+
+// failure records a failed request at time t, opening the breaker if there are too many consecutive
+// failures.
+func (cb *circuitBreaker) failure(t time.Time) {
+	if cb == nil || cb.failures <= 0 {
+		return
+	}
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.consecutiveFailures++
+	if cb.consecutiveFailures >= cb.failures {
+		if t.After(cb.openUntil) {
+			cacheLog.Warnf("CA circuit breaker open after %d consecutive failures, for %v",
+				cb.consecutiveFailures, cb.cooldown)
+		}
+		cb.openUntil = t.Add(cb.cooldown)
+	}
+}
+
+// open returns true if the breaker is open at time t.
+func (cb *circuitBreaker) open(t time.Time) bool {
+	return cb.allow(t) != nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"istio.io/istio/security/pkg/nodeagent/secretfetcher"
+)
+
+func TestJitteredBackoff(t *testing.T) {
+	cases := []struct {
+		retry int64
+		max   time.Duration
+		want  time.Duration
+	}{
+		{retry: 1, want: 100 * time.Millisecond},
+		{retry: 3, want: 400 * time.Millisecond},
+		{retry: 3, max: 250 * time.Millisecond, want: 250 * time.Millisecond},
+		{retry: 100, max: time.Second, want: time.Second},
+	}
+	for _, c := range cases {
+		for i := 0; i < 100; i++ {
+			if got := JitteredBackoff(100*time.Millisecond, c.max, c.retry); got < 0 || got >= c.want {
+				t.Fatalf("JitteredBackoff(retry %d, max %v) => %v, want in [0, %v)", c.retry, c.max, got, c.want)
+			}
+		}
+	}
+	if got := JitteredBackoff(0, 0, 1); got != 0 {
+		t.Errorf("JitteredBackoff() without backoff => %v, want 0", got)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	cb := &circuitBreaker{failures: 2, cooldown: time.Minute}
+
+	cb.failure(now)
+	if err := cb.allow(now); err != nil {
+		t.Fatalf("unexpected open breaker after a failure: %v", err)
+	}
+	cb.failure(now)
+	if err := cb.allow(now); err == nil {
+		t.Fatal("expected the breaker to open after 2 consecutive failures")
+	}
+	if !cb.open(now.Add(30 * time.Second)) {
+		t.Error("expected the breaker to stay open during the cooldown")
+	}
+
+	// After the cooldown, a request is let through, its failure opens the breaker again.
+	later := now.Add(time.Minute + time.Second)
+	if err := cb.allow(later); err != nil {
+		t.Fatalf("unexpected open breaker after the cooldown: %v", err)
+	}
+	cb.failure(later)
+	if !cb.open(later) {
+		t.Error("expected the breaker to open again")
+	}
+	cb.success()
+	if cb.open(later) {
+		t.Error("expected a success to close the breaker")
+	}
+
+	var disabled *circuitBreaker
+	disabled.failure(now)
+	if disabled.open(now) {
+		t.Error("unexpected open disabled breaker")
+	}
+}
+
+type unavailableCAClient struct {
+	calls int32
+}
+
+func (c *unavailableCAClient) CSRSign(context.Context, []byte, string, int64) ([]string, error) {
+	atomic.AddInt32(&c.calls, 1)
+	return nil, status.Error(codes.Unavailable, "CA is unavailable")
+}
+
+func TestCSRCircuitBreaker(t *testing.T) {
+	caClient := &unavailableCAClient{}
+	fetcher := &secretfetcher.SecretFetcher{
+		UseCaClient: true,
+		CaClient:    caClient,
+	}
+	sc := NewSecretCache(fetcher, notifyCb, Options{
+		RotationInterval:       time.Hour,
+		InitialBackoff:         1,
+		MaxRetryBackoff:        10 * time.Millisecond,
+		RetryTimeout:           time.Second,
+		CircuitBreakerFailures: 3,
+		CircuitBreakerCooldown: time.Minute,
+	})
+	defer sc.Close()
+
+	if _, err := sc.GenerateSecret(context.Background(), "conn", testResourceName, "token"); err == nil {
+		t.Fatal("expected an error with an unavailable CA")
+	}
+	if calls := atomic.LoadInt32(&caClient.calls); calls != 3 {
+		t.Errorf("got %d CSRs sent, want 3 before the breaker opens", calls)
+	}
+	status := sc.CertStatus()
+	if !status.CACircuitOpen || status.CSRFailures != 1 || status.Check(time.Now()) == nil {
+		t.Errorf("unexpected status with an unavailable CA %+v", status)
+	}
+
+	// The breaker is open: the CSR is failed without being sent.
+	if _, err := sc.GenerateSecret(context.Background(), "conn", testResourceName, "token"); err == nil {
+		t.Fatal("expected an error with an open breaker")
+	}
+	if calls := atomic.LoadInt32(&caClient.calls); calls != 3 {
+		t.Errorf("got %d CSRs sent, want no CSR sent with an open breaker", calls)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"istio.io/istio/security/pkg/nodeagent/model"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// CertStatus is the state of the workload certificate of a secret manager, for the debug endpoint.
+type CertStatus struct {
+	// SerialNumber of the workload certificate, empty if none was issued yet.
+	SerialNumber string    `json:"serial_number"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+
+	// NextRotation is when the rotation job renews the certificate.
+	NextRotation       time.Time `json:"next_rotation"`
+	TimeToNextRotation string    `json:"time_to_next_rotation"`
+
+	// Number of CSRs signed by the CA, and of CSRs which failed.
+	CSRSuccesses uint64 `json:"csr_successes"`
+	CSRFailures  uint64 `json:"csr_failures"`
+
+	// CACircuitOpen is set while the requests to the CA are failed fast, after consecutive failures.
+	CACircuitOpen bool `json:"ca_circuit_open"`
+}
+
+// CertStatusReporter is implemented by the secret managers reporting the state of the workload certificate.
+type CertStatusReporter interface {
+	CertStatus() *CertStatus
+}
+
+// Check returns an error if there is no valid workload certificate at time t.
+func (s *CertStatus) Check(t time.Time) error {
+	if s.SerialNumber == "" {
+		if s.CACircuitOpen {
+			return fmt.Errorf("no workload certificate, the CA is unavailable (%d CSR failures)", s.CSRFailures)
+		}
+		return fmt.Errorf("no workload certificate (%d CSR failures)", s.CSRFailures)
+	}
+	if t.Before(s.NotBefore) || t.After(s.NotAfter) {
+		return fmt.Errorf("workload certificate %s is only valid from %v to %v", s.SerialNumber,
+			s.NotBefore, s.NotAfter)
+	}
+	return nil
+}
+
+var _ CertStatusReporter = &SecretCache{}
+
+// CertStatus returns the state of the most recent workload certificate of the cache.
+func (sc *SecretCache) CertStatus() *CertStatus {
+	status := &CertStatus{
+		CSRSuccesses:  atomic.LoadUint64(&sc.csrSuccessCount),
+		CSRFailures:   atomic.LoadUint64(&sc.csrFailureCount),
+		CACircuitOpen: sc.caBreaker.open(time.Now()),
+	}
+
+	var latest *model.SecretItem
+	sc.secrets.Range(func(k interface{}, v interface{}) bool {
+		secret := v.(model.SecretItem)
+		if k.(ConnKey).ResourceName == RootCertReqResourceName || secret.CertificateChain == nil {
+			return true
+		}
+		if latest == nil || secret.CreatedTime.After(latest.CreatedTime) {
+			latest = &secret
+		}
+		return true
+	})
+	if latest == nil {
+		return status
+	}
+
+	cert, err := util.ParsePemEncodedCertificate(latest.CertificateChain)
+	if err != nil {
+		cacheLog.Warnf("failed to parse the workload certificate: %v", err)
+		return status
+	}
+	status.SerialNumber = cert.SerialNumber.String()
+	status.NotBefore = cert.NotBefore
+	status.NotAfter = cert.NotAfter
+	status.NextRotation = latest.ExpireTime.Add(-sc.configOptions.SecretRefreshGraceDuration)
+	status.TimeToNextRotation = time.Until(status.NextRotation).Round(time.Second).String()
+	return status
+}
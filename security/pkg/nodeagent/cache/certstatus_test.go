@@ -0,0 +1,80 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"io/ioutil"
+	"math/big"
+	"testing"
+	"time"
+
+	"istio.io/istio/security/pkg/nodeagent/model"
+)
+
+func TestCertStatus(t *testing.T) {
+	cert, err := ioutil.ReadFile("./testdata/cert-chain.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := &SecretCache{
+		configOptions:   Options{SecretRefreshGraceDuration: time.Hour},
+		csrSuccessCount: 2,
+		csrFailureCount: 1,
+	}
+
+	status := sc.CertStatus()
+	if status.SerialNumber != "" || status.CSRSuccesses != 2 || status.CSRFailures != 1 {
+		t.Errorf("unexpected status without certificate %+v", status)
+	}
+	if err := status.Check(time.Now()); err == nil {
+		t.Error("expected an error without certificate")
+	}
+
+	expireTime := time.Now().Add(24 * time.Hour)
+	sc.secrets.Store(ConnKey{ConnectionID: "conn1", ResourceName: RootCertReqResourceName}, model.SecretItem{
+		RootCert:    cert,
+		CreatedTime: time.Now(),
+	})
+	sc.secrets.Store(ConnKey{ConnectionID: "conn1", ResourceName: testResourceName}, model.SecretItem{
+		CertificateChain: []byte("old certificate"),
+		CreatedTime:      time.Now().Add(-time.Hour),
+	})
+	sc.secrets.Store(ConnKey{ConnectionID: "conn2", ResourceName: testResourceName}, model.SecretItem{
+		CertificateChain: cert,
+		CreatedTime:      time.Now(),
+		ExpireTime:       expireTime,
+	})
+
+	status = sc.CertStatus()
+	serial, _ := new(big.Int).SetString("E37589FAC1676FC1", 16)
+	if status.SerialNumber != serial.String() {
+		t.Errorf("SerialNumber: got %s, want %s", status.SerialNumber, serial)
+	}
+	if status.NotBefore.Year() != 2018 || status.NotAfter.Year() != 2117 {
+		t.Errorf("unexpected validity from %v to %v", status.NotBefore, status.NotAfter)
+	}
+	if want := expireTime.Add(-time.Hour); !status.NextRotation.Equal(want) {
+		t.Errorf("NextRotation: got %v, want %v", status.NextRotation, want)
+	}
+	if status.TimeToNextRotation == "" {
+		t.Error("expected the time to the next rotation")
+	}
+	if err := status.Check(time.Now()); err != nil {
+		t.Errorf("unexpected error for a valid certificate: %v", err)
+	}
+	if err := status.Check(status.NotAfter.Add(time.Second)); err == nil {
+		t.Error("expected an error for an expired certificate")
+	}
+}
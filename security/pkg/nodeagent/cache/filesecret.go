@@ -0,0 +1,247 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/security/pkg/nodeagent/model"
+	nodeagentutil "istio.io/istio/security/pkg/nodeagent/util"
+	"istio.io/pkg/filewatcher"
+)
+
+// FileSecretManager serves the key/cert and root cert mounted in a directory, for example from the
+// Secrets created by Citadel in /etc/certs, rather than requesting them from a CA. The files are watched,
+// and the proxies are pushed the new secrets when they change.
+type FileSecretManager struct {
+	certChainFile string
+	keyFile       string
+	rootCertFile  string
+
+	watcher filewatcher.FileWatcher
+
+	// callback function to invoke when detecting secret change.
+	notifyCallback func(connKey ConnKey, secret *model.SecretItem) error
+
+	// mutex protects the loaded files and the secrets.
+	mutex     sync.Mutex
+	certChain []byte
+	key       []byte
+	rootCert  []byte
+	loadTime  time.Time
+
+	// secrets map is the secrets pushed to the proxies, by connection.
+	secrets map[ConnKey]model.SecretItem
+}
+
+var _ SecretManager = &FileSecretManager{}
+
+// NewFileSecretManager loads the cert-chain.pem, key.pem and root-cert.pem files of dir, and watches
+// them until Close is called. It returns an error if they can't be loaded.
+func NewFileSecretManager(dir string, notifyCb func(ConnKey, *model.SecretItem) error) (*FileSecretManager, error) {
+	sm := &FileSecretManager{
+		certChainFile:  filepath.Join(dir, constants.CertChainFilename),
+		keyFile:        filepath.Join(dir, constants.KeyFilename),
+		rootCertFile:   filepath.Join(dir, constants.RootCertFilename),
+		notifyCallback: notifyCb,
+		secrets:        map[ConnKey]model.SecretItem{},
+	}
+	if _, err := sm.load(); err != nil {
+		return nil, err
+	}
+
+	sm.watcher = filewatcher.NewWatcher()
+	for _, file := range []string{sm.certChainFile, sm.keyFile, sm.rootCertFile} {
+		if err := sm.watcher.Add(file); err != nil {
+			_ = sm.watcher.Close()
+			return nil, fmt.Errorf("failed to watch %s: %v", file, err)
+		}
+		go sm.watch(file)
+	}
+	return sm, nil
+}
+
+// GenerateSecret returns the mounted root cert for the ROOTCA resource, the mounted key/cert otherwise.
+func (sm *FileSecretManager) GenerateSecret(_ context.Context, connectionID, resourceName, token string) (*model.SecretItem, error) {
+	connKey := ConnKey{
+		ConnectionID: connectionID,
+		ResourceName: resourceName,
+	}
+
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	secret, err := sm.secretItem(resourceName, token)
+	if err != nil {
+		cacheLog.Errorf("%s failed to generate secret for proxy: %v",
+			cacheLogPrefix(connectionID, resourceName), err)
+		return nil, err
+	}
+	sm.secrets[connKey] = *secret
+	return secret, nil
+}
+
+// ShouldWaitForIngressGatewaySecret returns false, the mounted secrets are available on start.
+func (sm *FileSecretManager) ShouldWaitForIngressGatewaySecret(connectionID, resourceName, token string) bool {
+	return false
+}
+
+// SecretExist checks if secret already existed.
+func (sm *FileSecretManager) SecretExist(connectionID, resourceName, token, version string) bool {
+	connKey := ConnKey{
+		ConnectionID: connectionID,
+		ResourceName: resourceName,
+	}
+
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	e, exist := sm.secrets[connKey]
+	return exist && e.ResourceName == resourceName && e.Token == token && e.Version == version
+}
+
+// DeleteSecret deletes a secret by its key.
+func (sm *FileSecretManager) DeleteSecret(connectionID, resourceName string) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	delete(sm.secrets, ConnKey{
+		ConnectionID: connectionID,
+		ResourceName: resourceName,
+	})
+}
+
+// Close stops watching the files.
+func (sm *FileSecretManager) Close() {
+	if err := sm.watcher.Close(); err != nil {
+		cacheLog.Warnf("failed to stop watching the mounted certs: %v", err)
+	}
+}
+
+// watch reloads the files when file changes, until the watcher is closed.
+func (sm *FileSecretManager) watch(file string) {
+	for {
+		select {
+		case _, more := <-sm.watcher.Events(file):
+			if !more {
+				return
+			}
+			sm.reload()
+		case err, more := <-sm.watcher.Errors(file):
+			if !more {
+				return
+			}
+			cacheLog.Errorf("failed to watch %s: %v", file, err)
+		}
+	}
+}
+
+// reload loads the files, and pushes the new secrets to the proxies if they changed.
+func (sm *FileSecretManager) reload() {
+	changed, err := sm.load()
+	if err != nil {
+		// The files may be in the middle of an update, the next event loads them again.
+		cacheLog.Warnf("failed to reload the mounted certs: %v", err)
+		return
+	}
+	if !changed {
+		return
+	}
+	cacheLog.Info("mounted certs changed, pushing them to the proxies")
+
+	sm.mutex.Lock()
+	updates := make(map[ConnKey]*model.SecretItem, len(sm.secrets))
+	for connKey, old := range sm.secrets {
+		secret, err := sm.secretItem(connKey.ResourceName, old.Token)
+		if err != nil {
+			cacheLog.Errorf("%s failed to generate secret for proxy: %v",
+				cacheLogPrefix(connKey.ConnectionID, connKey.ResourceName), err)
+			continue
+		}
+		sm.secrets[connKey] = *secret
+		updates[connKey] = secret
+	}
+	sm.mutex.Unlock()
+
+	// The callback pushes the secret to the SDS stream of the connection, it is called without holding the
+	// mutex since the stream looks up the secret.
+	for connKey, secret := range updates {
+		if sm.notifyCallback == nil {
+			break
+		}
+		if err := sm.notifyCallback(connKey, secret); err != nil {
+			cacheLog.Errorf("%s failed to notify secret change for proxy: %v",
+				cacheLogPrefix(connKey.ConnectionID, connKey.ResourceName), err)
+		}
+	}
+}
+
+// load reads the files, and returns whether they changed since they were last loaded.
+func (sm *FileSecretManager) load() (bool, error) {
+	certChain, err := ioutil.ReadFile(sm.certChainFile)
+	if err != nil {
+		return false, err
+	}
+	key, err := ioutil.ReadFile(sm.keyFile)
+	if err != nil {
+		return false, err
+	}
+	rootCert, err := ioutil.ReadFile(sm.rootCertFile)
+	if err != nil {
+		return false, err
+	}
+	if _, err := nodeagentutil.ParseCertAndGetExpiryTimestamp(certChain); err != nil {
+		return false, fmt.Errorf("invalid %s: %v", sm.certChainFile, err)
+	}
+	if _, err := nodeagentutil.ParseCertAndGetExpiryTimestamp(rootCert); err != nil {
+		return false, fmt.Errorf("invalid %s: %v", sm.rootCertFile, err)
+	}
+
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	if bytes.Equal(certChain, sm.certChain) && bytes.Equal(key, sm.key) && bytes.Equal(rootCert, sm.rootCert) {
+		return false, nil
+	}
+	sm.certChain, sm.key, sm.rootCert = certChain, key, rootCert
+	sm.loadTime = time.Now()
+	return true, nil
+}
+
+// secretItem returns the secret of the resource from the loaded files. It requires the mutex.
+func (sm *FileSecretManager) secretItem(resourceName, token string) (*model.SecretItem, error) {
+	secret := &model.SecretItem{
+		ResourceName: resourceName,
+		Token:        token,
+		CreatedTime:  sm.loadTime,
+		Version:      sm.loadTime.String(),
+	}
+	var err error
+	if resourceName == RootCertReqResourceName {
+		secret.RootCert = sm.rootCert
+		secret.ExpireTime, err = nodeagentutil.ParseCertAndGetExpiryTimestamp(sm.rootCert)
+	} else {
+		secret.CertificateChain = sm.certChain
+		secret.PrivateKey = sm.key
+		secret.ExpireTime, err = nodeagentutil.ParseCertAndGetExpiryTimestamp(sm.certChain)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
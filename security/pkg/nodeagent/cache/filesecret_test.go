@@ -0,0 +1,104 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"istio.io/istio/security/pkg/nodeagent/model"
+)
+
+func TestFileSecretManager(t *testing.T) {
+	cert, err := ioutil.ReadFile("./testdata/cert-chain.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "file-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFile := func(name string, data []byte) {
+		t.Helper()
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("cert-chain.pem", cert)
+	writeFile("root-cert.pem", cert)
+
+	if _, err := NewFileSecretManager(dir, nil); err == nil {
+		t.Fatal("expected an error with key.pem missing")
+	}
+	writeFile("key.pem", []byte("fake key"))
+
+	notified := make(chan *model.SecretItem, 2)
+	sm, err := NewFileSecretManager(dir, func(_ ConnKey, secret *model.SecretItem) error {
+		notified <- secret
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to create the file secret manager: %v", err)
+	}
+	defer sm.Close()
+
+	secret, err := sm.GenerateSecret(context.Background(), "conn", WorkloadKeyCertResourceName, "token")
+	if err != nil {
+		t.Fatalf("failed to generate the workload secret: %v", err)
+	}
+	if !bytes.Equal(secret.CertificateChain, cert) || string(secret.PrivateKey) != "fake key" || secret.ExpireTime.IsZero() {
+		t.Errorf("unexpected workload secret %+v", secret)
+	}
+	if !sm.SecretExist("conn", WorkloadKeyCertResourceName, "token", secret.Version) {
+		t.Error("expected the workload secret to exist")
+	}
+	root, err := sm.GenerateSecret(context.Background(), "conn", RootCertReqResourceName, "token")
+	if err != nil {
+		t.Fatalf("failed to generate the root secret: %v", err)
+	}
+	if !bytes.Equal(root.RootCert, cert) || root.CertificateChain != nil {
+		t.Errorf("unexpected root secret %+v", root)
+	}
+	if sm.ShouldWaitForIngressGatewaySecret("conn", WorkloadKeyCertResourceName, "token") {
+		t.Error("unexpected wait for the ingress gateway secret")
+	}
+
+	// A rotated key is pushed to the connections.
+	writeFile("key.pem", []byte("rotated key"))
+	for i := 0; i < 2; i++ {
+		select {
+		case s := <-notified:
+			if s.ResourceName == WorkloadKeyCertResourceName && string(s.PrivateKey) != "rotated key" {
+				t.Errorf("unexpected rotated workload secret %+v", s)
+			}
+			if sm.SecretExist("conn", WorkloadKeyCertResourceName, "token", secret.Version) {
+				t.Error("expected the version of the workload secret to change")
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for the rotated secrets to be pushed")
+		}
+	}
+
+	sm.DeleteSecret("conn", RootCertReqResourceName)
+	if sm.SecretExist("conn", RootCertReqResourceName, "token", root.Version) {
+		t.Error("expected the root secret to be deleted")
+	}
+}
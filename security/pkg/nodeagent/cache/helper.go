@@ -25,23 +25,9 @@ import (
 
 func constructCSRHostName(trustDomain, token string) (string, error) {
 	// If token is jwt format, construct host name from jwt with format like spiffe://cluster.local/ns/foo/sa/sleep,
-	strs := strings.Split(token, ".")
-	if len(strs) != 3 {
-		return "", fmt.Errorf("invalid k8s jwt token")
-	}
-
-	payload := strs[1]
-	if l := len(payload) % 4; l > 0 {
-		payload += strings.Repeat("=", 4-l)
-	}
-	dp, err := base64.URLEncoding.DecodeString(payload)
+	jp, err := parseJwtPayload(token)
 	if err != nil {
-		return "", fmt.Errorf("invalid k8s jwt token: %v", err)
-	}
-
-	var jp k8sJwtPayload
-	if err = json.Unmarshal(dp, &jp); err != nil {
-		return "", fmt.Errorf("invalid k8s jwt token: %v", err)
+		return "", err
 	}
 
 	// sub field in jwt should be in format like: system:serviceaccount:foo:bar
@@ -60,6 +46,29 @@ func constructCSRHostName(trustDomain, token string) (string, error) {
 	return fmt.Sprintf(identityTemplate, domain, ns, sa), nil
 }
 
+// parseJwtPayload decodes the payload of the k8s jwt token, without verifying it.
+func parseJwtPayload(token string) (*k8sJwtPayload, error) {
+	strs := strings.Split(token, ".")
+	if len(strs) != 3 {
+		return nil, fmt.Errorf("invalid k8s jwt token")
+	}
+
+	payload := strs[1]
+	if l := len(payload) % 4; l > 0 {
+		payload += strings.Repeat("=", 4-l)
+	}
+	dp, err := base64.URLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid k8s jwt token: %v", err)
+	}
+
+	var jp k8sJwtPayload
+	if err = json.Unmarshal(dp, &jp); err != nil {
+		return nil, fmt.Errorf("invalid k8s jwt token: %v", err)
+	}
+	return &jp, nil
+}
+
 // isRetryableErr checks if a failed request should be retry based on gRPC resp code or http status code.
 func isRetryableErr(c codes.Code, httpRespCode int, isGrpc bool) bool {
 	if isGrpc {
@@ -48,6 +48,17 @@ var (
 		"num_failed_outgoing_requests",
 		"Number of failed outgoing requests (e.g. to a token exchange server, CA, etc.)",
 		monitoring.WithLabels(RequestType))
+
+	certExpiryTimestamp = monitoring.NewGauge(
+		"cert_expiry_timestamp",
+		"The unix timestamp, in seconds, when the last workload certificate signed by the CA expires.",
+		monitoring.WithUnit(monitoring.Seconds))
+
+	tokenAge = monitoring.NewGauge(
+		"token_age",
+		"The time, in seconds, since the JWT token used to authenticate with the CA was issued. "+
+			"Updated when the token is rotated and by the key rotation job.",
+		monitoring.WithUnit(monitoring.Seconds))
 )
 
 func init() {
@@ -56,5 +67,7 @@ func init() {
 		numOutgoingRequests,
 		numOutgoingRetries,
 		numFailedOutgoingRequests,
+		certExpiryTimestamp,
+		tokenAge,
 	)
 }
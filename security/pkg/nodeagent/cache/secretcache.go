@@ -66,6 +66,9 @@ const (
 	// initialBackOffIntervalInMilliSec is the initial backoff time interval when hitting non-retryable error in CSR request.
 	initialBackOffIntervalInMilliSec = 50
 
+	// defaultMaxRetryBackoff caps the backoff between retries, for several retries within the Envoy timeout.
+	defaultMaxRetryBackoff = 200 * time.Millisecond
+
 	// Timeout the K8s update/delete notification threads. This is to make sure to unblock the
 	// secret watch main thread in case those child threads got stuck due to any reason.
 	notifyK8sSecretTimeout = 30 * time.Second
@@ -73,6 +76,7 @@ const (
 
 type k8sJwtPayload struct {
 	Sub string `json:"sub"`
+	Iat int64  `json:"iat"`
 }
 
 // Options provides all of the configuration parameters for secret cache.
@@ -83,6 +87,21 @@ type Options struct {
 	// The initial backoff time in millisecond to avoid the thundering herd problem.
 	InitialBackoff int64
 
+	// The maximum backoff between the retries of a failed request to the CA, doubling from one retry
+	// to the next with a random jitter. defaultMaxRetryBackoff if 0.
+	MaxRetryBackoff time.Duration
+
+	// The budget for the retries of a failed request to the CA. The Envoy timeout of SDS requests,
+	// 1s, if 0.
+	RetryTimeout time.Duration
+
+	// Consecutive failed requests to the CA opening the circuit breaker, which fails the requests
+	// without sending them until CircuitBreakerCooldown elapsed. Disabled if 0.
+	CircuitBreakerFailures int
+
+	// Time the circuit breaker stays open.
+	CircuitBreakerCooldown time.Duration
+
 	// secret should be refreshed before it expired, SecretRefreshGraceDuration is the grace period;
 	// secret should be refreshed if time.Now.After(secret.CreateTime + SecretTTL - SecretRefreshGraceDuration)
 	SecretRefreshGraceDuration time.Duration
@@ -105,6 +124,18 @@ type Options struct {
 
 	// set this flag to true if skip validate format for certificate chain returned from CA.
 	SkipValidateCert bool
+
+	// TrustBundle, if set, combines the root cert of the CA with other roots for the ROOTCA resource.
+	// The proxies are pushed the new bundle when it changes.
+	TrustBundle *TrustBundle
+
+	// TokenWatcher, if set, provides the token of the CA requests instead of the token of the SDS
+	// requests, so that the retries and the rotations use the token rotated by Kubernetes.
+	TokenWatcher *TokenWatcher
+
+	// ECCSigAlg is the EC signature algorithm of the keys of the workload certificates, e.g. ECDSA.
+	// RSA keys are generated if empty.
+	ECCSigAlg util.SupportedECSignatureAlgorithms
 }
 
 // SecretManager defines secrets management interface which is used by SDS.
@@ -123,6 +154,12 @@ type SecretManager interface {
 	DeleteSecret(connectionID, resourceName string)
 }
 
+// SecretRefresher is implemented by the secret managers of the ingress gateway, which reload a kubernetes
+// secret on demand and push it to the proxies using it.
+type SecretRefresher interface {
+	RefreshK8sSecret(secretName string) error
+}
+
 // ConnKey is the key of one SDS connection.
 type ConnKey struct {
 	ConnectionID string
@@ -149,6 +186,13 @@ type SecretCache struct {
 	// How may times that key rotation job has detected root cert change happened, used in unit test.
 	rootCertChangedCount uint64
 
+	// Number of CSRs signed by the CA, and of CSRs which failed, reported in the CertStatus.
+	csrSuccessCount uint64
+	csrFailureCount uint64
+
+	// caBreaker fails the requests to the CA fast while it is unavailable.
+	caBreaker *circuitBreaker
+
 	// callback function to invoke when detecting secret change.
 	notifyCallback func(connKey ConnKey, secret *model.SecretItem) error
 
@@ -179,10 +223,21 @@ func NewSecretCache(fetcher *secretfetcher.SecretFetcher, notifyCb func(ConnKey,
 		rootCertMutex:  &sync.Mutex{},
 		configOptions:  options,
 		randMutex:      &sync.Mutex{},
+		caBreaker: &circuitBreaker{
+			failures: options.CircuitBreakerFailures,
+			cooldown: options.CircuitBreakerCooldown,
+		},
 	}
 	randSource := rand.NewSource(time.Now().UnixNano())
 	ret.rand = rand.New(randSource)
 
+	if options.TrustBundle != nil {
+		options.TrustBundle.AddHandler(func([]byte) {
+			cacheLog.Info("Trust bundle has changed, start rotating root cert for SDS clients")
+			ret.rotate(true /*updateRootFlag*/)
+		})
+	}
+
 	fetcher.AddCache = ret.UpdateK8sSecret
 	fetcher.DeleteCache = ret.DeleteK8sSecret
 	fetcher.UpdateCache = ret.UpdateK8sSecret
@@ -222,12 +277,13 @@ func (sc *SecretCache) GenerateSecret(ctx context.Context, connectionID, resourc
 
 	// If request is for root certificate,
 	// retry since rootCert may be empty until there is CSR response returned from CA.
-	if sc.rootCert == nil {
+	rootCert, rootCertExpireTime := sc.rootCertificate()
+	if rootCert == nil {
 		wait := retryWaitDuration
 		retryNum := 0
 		for ; retryNum < maxRetryNum; retryNum++ {
 			time.Sleep(retryWaitDuration)
-			if sc.rootCert != nil {
+			if rootCert, rootCertExpireTime = sc.rootCertificate(); rootCert != nil {
 				break
 			}
 
@@ -235,7 +291,7 @@ func (sc *SecretCache) GenerateSecret(ctx context.Context, connectionID, resourc
 		}
 	}
 
-	if sc.rootCert == nil {
+	if rootCert == nil {
 		cacheLog.Errorf("%s failed to get root cert for proxy", conIDresourceNamePrefix)
 		return nil, errors.New("failed to get root cert")
 
@@ -244,8 +300,8 @@ func (sc *SecretCache) GenerateSecret(ctx context.Context, connectionID, resourc
 	t := time.Now()
 	ns = &model.SecretItem{
 		ResourceName: resourceName,
-		RootCert:     sc.rootCert,
-		ExpireTime:   sc.rootCertExpireTime,
+		RootCert:     rootCert,
+		ExpireTime:   rootCertExpireTime,
 		Token:        token,
 		CreatedTime:  t,
 		Version:      t.String(),
@@ -354,6 +410,9 @@ func (sc *SecretCache) keyCertRotationJob() {
 	for {
 		select {
 		case <-sc.rotationTicker.C:
+			if sc.configOptions.TokenWatcher != nil {
+				sc.configOptions.TokenWatcher.recordAge()
+			}
 			sc.rotate(false /*updateRootFlag*/)
 		case <-sc.closing:
 			if sc.rotationTicker != nil {
@@ -442,6 +501,17 @@ func (sc *SecretCache) UpdateK8sSecret(secretName string, ns model.SecretItem) {
 	})
 }
 
+var _ SecretRefresher = &SecretCache{}
+
+// RefreshK8sSecret reloads the K8s secret of secretName from the API server, and pushes it to the proxies
+// using it, even if it did not change.
+func (sc *SecretCache) RefreshK8sSecret(secretName string) error {
+	if sc.fetcher.UseCaClient {
+		return errors.New("only the secrets of the ingress gateway can be refreshed")
+	}
+	return sc.fetcher.RefreshSecret(secretName)
+}
+
 func (sc *SecretCache) rotate(updateRootFlag bool) {
 	// Skip secret rotation for kubernetes secrets.
 	if !sc.fetcher.UseCaClient {
@@ -465,10 +535,11 @@ func (sc *SecretCache) rotate(updateRootFlag bool) {
 
 			atomic.AddUint64(&sc.rootCertChangedCount, 1)
 			t := time.Now()
+			rootCert, rootCertExpireTime := sc.rootCertificate()
 			ns := &model.SecretItem{
 				ResourceName: connKey.ResourceName,
-				RootCert:     sc.rootCert,
-				ExpireTime:   sc.rootCertExpireTime,
+				RootCert:     rootCert,
+				ExpireTime:   rootCertExpireTime,
 				Token:        e.Token,
 				CreatedTime:  t,
 				Version:      t.String(),
@@ -574,10 +645,15 @@ func (sc *SecretCache) generateSecret(ctx context.Context, token string, connKey
 		return sc.generateGatewaySecret(token, connKey, t)
 	}
 	conIDresourceNamePrefix := cacheLogPrefix(connKey.ConnectionID, connKey.ResourceName)
+	// The token of the SDS request is kept in the cache, the CA requests use the current token.
+	caToken := token
+	if sc.configOptions.TokenWatcher != nil {
+		caToken = sc.configOptions.TokenWatcher.Token()
+	}
 	// call authentication provider specific plugins to exchange token if necessary.
 	numOutgoingRequests.With(RequestType.Value(TokenExchange)).Increment()
 	timeBeforeTokenExchange := time.Now()
-	exchangedToken, err := sc.getExchangedToken(ctx, token, connKey)
+	exchangedToken, err := sc.getExchangedToken(ctx, caToken, connKey)
 	tokenExchangeLatency := float64(time.Since(timeBeforeTokenExchange).Nanoseconds()) / float64(time.Millisecond)
 	outgoingLatency.With(RequestType.Value(TokenExchange)).Record(tokenExchangeLatency)
 	if err != nil {
@@ -587,15 +663,16 @@ func (sc *SecretCache) generateSecret(ctx context.Context, token string, connKey
 
 	// If token is jwt format, construct host name from jwt with format like spiffe://cluster.local/ns/foo/sa/sleep
 	// otherwise just use sdsrequest.resourceName as csr host name.
-	csrHostName, err := constructCSRHostName(sc.configOptions.TrustDomain, token)
+	csrHostName, err := constructCSRHostName(sc.configOptions.TrustDomain, caToken)
 	if err != nil {
 		cacheLog.Warnf("%s failed to extract host name from jwt: %v, fallback to SDS request"+
-			" resource name. The failed jwt above is: %s", conIDresourceNamePrefix, err, token)
+			" resource name. The failed jwt above is: %s", conIDresourceNamePrefix, err, caToken)
 		csrHostName = connKey.ResourceName
 	}
 	options := util.CertOptions{
 		Host:       csrHostName,
 		RSAKeySize: keySize,
+		ECSigAlg:   sc.configOptions.ECCSigAlg,
 	}
 
 	// Generate the cert/key, send CSR to CA.
@@ -612,8 +689,10 @@ func (sc *SecretCache) generateSecret(ctx context.Context, token string, connKey
 	outgoingLatency.With(RequestType.Value(CSR)).Record(csrLatency)
 	if err != nil {
 		numFailedOutgoingRequests.With(RequestType.Value(CSR)).Increment()
+		atomic.AddUint64(&sc.csrFailureCount, 1)
 		return nil, err
 	}
+	atomic.AddUint64(&sc.csrSuccessCount, 1)
 
 	cacheLog.Debugf("%s received CSR response with certificate chain %+v \n",
 		conIDresourceNamePrefix, certChainPEM)
@@ -651,9 +730,20 @@ func (sc *SecretCache) generateSecret(ctx context.Context, token string, connKey
 	}
 	sc.rootCertMutex.Unlock()
 
+	if connKey.ResourceName != RootCertReqResourceName {
+		certExpiryTimestamp.Record(float64(expireTime.Unix()))
+	}
+
 	if rootCertChanged {
-		cacheLog.Info("Root cert has changed, start rotating root cert for SDS clients")
-		sc.rotate(true /*updateRootFlag*/)
+		if sc.configOptions.TrustBundle != nil {
+			// The proxies are pushed the new bundle by the handler, if it changed.
+			if err := sc.configOptions.TrustBundle.Update(CARootSource, []byte(certChainPEM[length-1])); err != nil {
+				cacheLog.Errorf("%s failed to update the trust bundle: %v", conIDresourceNamePrefix, err)
+			}
+		} else {
+			cacheLog.Info("Root cert has changed, start rotating root cert for SDS clients")
+			sc.rotate(true /*updateRootFlag*/)
+		}
 	}
 
 	return &model.SecretItem{
@@ -667,6 +757,17 @@ func (sc *SecretCache) generateSecret(ctx context.Context, token string, connKey
 	}, nil
 }
 
+// rootCertificate returns the root cert served for the ROOTCA resource, and its expiration time: the
+// trust bundle if it is configured, the root cert of the CA otherwise.
+func (sc *SecretCache) rootCertificate() ([]byte, time.Time) {
+	if sc.configOptions.TrustBundle != nil {
+		return sc.configOptions.TrustBundle.Roots()
+	}
+	sc.rootCertMutex.Lock()
+	defer sc.rootCertMutex.Unlock()
+	return sc.rootCert, sc.rootCertExpireTime
+}
+
 func (sc *SecretCache) shouldRefresh(s *model.SecretItem) bool {
 	// secret should be refreshed before it expired, SecretRefreshGraceDuration is the grace period;
 	return time.Now().After(s.ExpireTime.Add(-sc.configOptions.SecretRefreshGraceDuration))
@@ -677,6 +778,10 @@ func (sc *SecretCache) isTokenExpired() bool {
 	if sc.configOptions.AlwaysValidTokenFlag {
 		return false
 	}
+	// the token of the TokenWatcher is kept valid by Kubernetes.
+	if sc.configOptions.TokenWatcher != nil {
+		return false
+	}
 
 	if atomic.LoadUint32(&sc.skipTokenExpireCheck) == 1 {
 		return true
@@ -698,6 +803,14 @@ func (sc *SecretCache) sendRetriableRequest(ctx context.Context, csrPEM []byte,
 
 	conIDresourceNamePrefix := cacheLogPrefix(connKey.ConnectionID, connKey.ResourceName)
 	startTime := time.Now()
+	retryTimeout := sc.configOptions.RetryTimeout
+	if retryTimeout == 0 {
+		retryTimeout = time.Millisecond * envoyDefaultTimeoutInMilliSec
+	}
+	maxRetryBackoff := sc.configOptions.MaxRetryBackoff
+	if maxRetryBackoff == 0 {
+		maxRetryBackoff = defaultMaxRetryBackoff
+	}
 	var retry int64
 	var certChainPEM []string
 	exchangedToken := providedExchangedToken
@@ -709,8 +822,18 @@ func (sc *SecretCache) sendRetriableRequest(ctx context.Context, csrPEM []byte,
 		var httpRespCode int
 		if isCSR {
 			requestErrorString = fmt.Sprintf("%s CSR", conIDresourceNamePrefix)
+			if err = sc.caBreaker.allow(time.Now()); err != nil {
+				cacheLog.Errorf("%s not sent: %v", requestErrorString, err)
+				return nil, err
+			}
 			certChainPEM, err = sc.fetcher.CaClient.CSRSign(
 				ctx, csrPEM, exchangedToken, int64(sc.configOptions.SecretTTL.Seconds()))
+			if err == nil {
+				sc.caBreaker.success()
+			} else if isRetryableErr(status.Code(err), httpRespCode, isCSR) {
+				// Only the retryable errors are caused by an unavailable CA.
+				sc.caBreaker.failure(time.Now())
+			}
 		} else {
 			requestErrorString = fmt.Sprintf("%s token exchange", conIDresourceNamePrefix)
 			p := sc.configOptions.Plugins[0]
@@ -727,16 +850,21 @@ func (sc *SecretCache) sendRetriableRequest(ctx context.Context, csrPEM []byte,
 			return nil, err
 		}
 
-		// If reach envoy timeout, fail the request by returning err
-		if startTime.Add(time.Millisecond * envoyDefaultTimeoutInMilliSec).Before(time.Now()) {
+		// If the retry budget is spent, fail the request by returning err
+		if startTime.Add(retryTimeout).Before(time.Now()) {
 			cacheLog.Errorf("%s retry timed out %v", requestErrorString, err)
 			return nil, err
 		}
 
 		retry++
-		backOffInMilliSec = rand.Int63n(retry * initialBackOffIntervalInMilliSec)
-		time.Sleep(time.Duration(backOffInMilliSec) * time.Millisecond)
-		cacheLog.Warnf("%s failed with error: %v, retry in %d millisec", requestErrorString, err, backOffInMilliSec)
+		backOff := JitteredBackoff(time.Millisecond*initialBackOffIntervalInMilliSec, maxRetryBackoff, retry)
+		cacheLog.Warnf("%s failed with error: %v, retry in %v", requestErrorString, err, backOff)
+		time.Sleep(backOff)
+
+		// Without token exchange, the retries of the CSR authenticate with the token rotated meanwhile.
+		if isCSR && sc.configOptions.TokenWatcher != nil && len(sc.configOptions.Plugins) == 0 {
+			exchangedToken = sc.configOptions.TokenWatcher.Token()
+		}
 
 		// Record retry metrics.
 		if isCSR {
@@ -0,0 +1,131 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/pkg/filewatcher"
+)
+
+// TokenWatcher keeps the JWT token of a file, reloading it when the file changes. Kubernetes rotates
+// the projected service account tokens before they expire, so the CA requests of long-lived proxies
+// must use the token of the file rather than the token read when they started.
+type TokenWatcher struct {
+	file string
+
+	// mutex protects the token and its issue time.
+	mutex    sync.RWMutex
+	token    string
+	issuedAt time.Time
+
+	watcher filewatcher.FileWatcher
+}
+
+// NewTokenWatcher loads the token of the file, and reloads it when the file changes until Close is
+// called.
+func NewTokenWatcher(file string) (*TokenWatcher, error) {
+	tw := &TokenWatcher{
+		file:    file,
+		watcher: filewatcher.NewWatcher(),
+	}
+	if err := tw.load(); err != nil {
+		return nil, err
+	}
+	if err := tw.watcher.Add(file); err != nil {
+		return nil, fmt.Errorf("failed to watch %s: %v", file, err)
+	}
+	go func() {
+		for {
+			select {
+			case _, more := <-tw.watcher.Events(file):
+				if !more {
+					return
+				}
+				if err := tw.load(); err != nil {
+					// The file may be in the middle of an update, the next event loads it again.
+					cacheLog.Warnf("failed to reload the token: %v", err)
+				}
+			case err, more := <-tw.watcher.Errors(file):
+				if !more {
+					return
+				}
+				cacheLog.Errorf("failed to watch %s: %v", file, err)
+			}
+		}
+	}()
+	return tw, nil
+}
+
+// Token returns the current token of the file.
+func (tw *TokenWatcher) Token() string {
+	tw.mutex.RLock()
+	defer tw.mutex.RUnlock()
+	return tw.token
+}
+
+// Age returns the time since the current token was issued.
+func (tw *TokenWatcher) Age() time.Duration {
+	tw.mutex.RLock()
+	defer tw.mutex.RUnlock()
+	return time.Since(tw.issuedAt)
+}
+
+// Close stops watching the file.
+func (tw *TokenWatcher) Close() {
+	if err := tw.watcher.Close(); err != nil {
+		cacheLog.Warnf("failed to stop watching the token: %v", err)
+	}
+}
+
+// recordAge updates the token age metric.
+func (tw *TokenWatcher) recordAge() {
+	tokenAge.Record(tw.Age().Seconds())
+}
+
+func (tw *TokenWatcher) load() error {
+	content, err := ioutil.ReadFile(tw.file)
+	if err != nil {
+		return err
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return fmt.Errorf("empty token in %s", tw.file)
+	}
+
+	// The issue time of the JWT, or the modification time of the file for other tokens.
+	issuedAt := time.Time{}
+	if jp, err := parseJwtPayload(token); err == nil && jp.Iat != 0 {
+		issuedAt = time.Unix(jp.Iat, 0)
+	} else if fi, err := os.Stat(tw.file); err == nil {
+		issuedAt = fi.ModTime()
+	}
+
+	tw.mutex.Lock()
+	changed := tw.token != "" && tw.token != token
+	tw.token, tw.issuedAt = token, issuedAt
+	tw.mutex.Unlock()
+
+	if changed {
+		cacheLog.Infof("token of %s rotated", tw.file)
+	}
+	tw.recordAge()
+	return nil
+}
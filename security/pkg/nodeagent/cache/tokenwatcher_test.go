@@ -0,0 +1,168 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"istio.io/istio/security/pkg/nodeagent/secretfetcher"
+)
+
+// tokenRecordingCAClient records the tokens of the CSRs, which fail.
+type tokenRecordingCAClient struct {
+	mutex  sync.Mutex
+	tokens []string
+}
+
+func (c *tokenRecordingCAClient) CSRSign(_ context.Context, _ []byte, token string, _ int64) ([]string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.tokens = append(c.tokens, token)
+	return nil, status.Error(codes.Unavailable, "CA is unavailable")
+}
+
+func (c *tokenRecordingCAClient) lastToken() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.tokens) == 0 {
+		return ""
+	}
+	return c.tokens[len(c.tokens)-1]
+}
+
+// fakeJwt returns an unsigned JWT issued at iat for the service account.
+func fakeJwt(sa string, iat time.Time) string {
+	payload := fmt.Sprintf(`{"sub":"system:serviceaccount:default:%s","iat":%d}`, sa, iat.Unix())
+	return "e30." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".sig"
+}
+
+func writeToken(t *testing.T, file, token string) {
+	t.Helper()
+	if err := ioutil.WriteFile(file, []byte(token), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitForToken(t *testing.T, tw *TokenWatcher, want string) {
+	t.Helper()
+	for start := time.Now(); tw.Token() != want; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("timed out waiting for the token, got %q, want %q", tw.Token(), want)
+		}
+	}
+}
+
+func TestTokenWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token-watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "istio-token")
+
+	if _, err := NewTokenWatcher(file); err == nil {
+		t.Error("expected an error for a missing token")
+	}
+
+	token := fakeJwt("sleep", time.Now().Add(-time.Hour))
+	writeToken(t, file, token+"\n")
+	tw, err := NewTokenWatcher(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tw.Close()
+	if got := tw.Token(); got != token {
+		t.Errorf("Token() => %q, want %q", got, token)
+	}
+	if age := tw.Age(); age < time.Hour || age > 2*time.Hour {
+		t.Errorf("Age() => %v, want the time since the token was issued", age)
+	}
+
+	// The rotated token is reloaded.
+	rotated := fakeJwt("sleep", time.Now())
+	writeToken(t, file, rotated)
+	waitForToken(t, tw, rotated)
+	if age := tw.Age(); age > time.Hour {
+		t.Errorf("Age() => %v, want the age of the rotated token", age)
+	}
+
+	// An invalid update keeps the token.
+	writeToken(t, file, "")
+	time.Sleep(100 * time.Millisecond)
+	if got := tw.Token(); got != rotated {
+		t.Errorf("Token() => %q after an empty update, want %q", got, rotated)
+	}
+}
+
+func TestSecretCacheTokenWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token-watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "istio-token")
+	token := fakeJwt("sleep", time.Now())
+	writeToken(t, file, token)
+	tw, err := NewTokenWatcher(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tw.Close()
+
+	caClient := &tokenRecordingCAClient{}
+	fetcher := &secretfetcher.SecretFetcher{
+		UseCaClient: true,
+		CaClient:    caClient,
+	}
+	sc := NewSecretCache(fetcher, notifyCb, Options{
+		RotationInterval: time.Hour,
+		InitialBackoff:   1,
+		RetryTimeout:     time.Millisecond,
+		TokenWatcher:     tw,
+	})
+	defer sc.Close()
+
+	// The CSRs authenticate with the token of the file rather than the stale token of the request.
+	if _, err := sc.GenerateSecret(context.Background(), "conn", testResourceName, "stale"); err == nil {
+		t.Fatal("expected an error from the unavailable CA")
+	}
+	if got := caClient.lastToken(); got != token {
+		t.Errorf("CSR token => %q, want %q", got, token)
+	}
+
+	rotated := fakeJwt("sleep", time.Now().Add(time.Second))
+	writeToken(t, file, rotated)
+	waitForToken(t, tw, rotated)
+	if _, err := sc.GenerateSecret(context.Background(), "conn", testResourceName, "stale"); err == nil {
+		t.Fatal("expected an error from the unavailable CA")
+	}
+	if got := caClient.lastToken(); got != rotated {
+		t.Errorf("CSR token => %q, want the rotated token %q", got, rotated)
+	}
+	if sc.isTokenExpired() {
+		t.Error("the token of the watcher should never be considered expired")
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"istio.io/pkg/filewatcher"
+)
+
+// Sources of the roots of a TrustBundle.
+const (
+	// MountedRootSource is the root cert mounted in the pod, for example by Citadel.
+	MountedRootSource = "mounted"
+
+	// CARootSource is the root cert returned by the CA with the workload certificate.
+	CARootSource = "ca"
+
+	// AdditionalRootSource are the roots configured for the mesh, for example the root of the new CA
+	// during a CA migration.
+	AdditionalRootSource = "additional"
+)
+
+// TrustBundle combines the roots of several sources, so that the proxies trust the certificates of
+// both the old and the new CA during a CA migration. The roots of each source are replaced as a whole,
+// and the handlers are called with the new bundle when it changes.
+type TrustBundle struct {
+	// updateMutex serializes the updates, so that the handlers are called in the order of the bundles.
+	updateMutex sync.Mutex
+
+	// mutex protects the roots and the bundle.
+	mutex      sync.Mutex
+	roots      map[string][]byte
+	bundle     []byte
+	expireTime time.Time
+
+	handlers []func(bundle []byte)
+
+	watcher filewatcher.FileWatcher
+}
+
+// NewTrustBundle creates an empty trust bundle.
+func NewTrustBundle() *TrustBundle {
+	return &TrustBundle{
+		roots:   map[string][]byte{},
+		watcher: filewatcher.NewWatcher(),
+	}
+}
+
+// AddHandler registers a handler called with the new bundle when it changes. It must be called before
+// the roots are updated.
+func (tb *TrustBundle) AddHandler(handler func(bundle []byte)) {
+	tb.handlers = append(tb.handlers, handler)
+}
+
+// Roots returns the PEM encoded roots of all the sources, nil if there is none, and the expiration
+// time of the first root to expire.
+func (tb *TrustBundle) Roots() ([]byte, time.Time) {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	return tb.bundle, tb.expireTime
+}
+
+// Update replaces the PEM encoded roots of the source, empty roots removing the source. It returns an
+// error, keeping the previous roots, if a root can't be parsed.
+func (tb *TrustBundle) Update(source string, roots []byte) error {
+	if len(roots) != 0 {
+		if _, err := parseRoots(roots); err != nil {
+			return fmt.Errorf("invalid %s roots: %v", source, err)
+		}
+	}
+
+	tb.updateMutex.Lock()
+	defer tb.updateMutex.Unlock()
+	tb.mutex.Lock()
+	if len(roots) == 0 {
+		delete(tb.roots, source)
+	} else {
+		tb.roots[source] = roots
+	}
+	bundle, expireTime := tb.combine()
+	if bytes.Equal(bundle, tb.bundle) {
+		tb.mutex.Unlock()
+		return nil
+	}
+	tb.bundle, tb.expireTime = bundle, expireTime
+	tb.mutex.Unlock()
+
+	cacheLog.Infof("trust bundle updated with the %s roots", source)
+	for _, handler := range tb.handlers {
+		handler(bundle)
+	}
+	return nil
+}
+
+// WatchFile loads the roots of the source from the file, and updates them when the file changes until
+// Close is called. A missing file has no roots.
+func (tb *TrustBundle) WatchFile(source, file string) error {
+	if err := tb.loadFile(source, file); err != nil {
+		return err
+	}
+	if err := tb.watcher.Add(file); err != nil {
+		return fmt.Errorf("failed to watch %s: %v", file, err)
+	}
+	go func() {
+		for {
+			select {
+			case _, more := <-tb.watcher.Events(file):
+				if !more {
+					return
+				}
+				if err := tb.loadFile(source, file); err != nil {
+					// The file may be in the middle of an update, the next event loads it again.
+					cacheLog.Warnf("failed to reload the %s roots: %v", source, err)
+				}
+			case err, more := <-tb.watcher.Errors(file):
+				if !more {
+					return
+				}
+				cacheLog.Errorf("failed to watch %s: %v", file, err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops watching the files.
+func (tb *TrustBundle) Close() {
+	if err := tb.watcher.Close(); err != nil {
+		cacheLog.Warnf("failed to stop watching the roots: %v", err)
+	}
+}
+
+func (tb *TrustBundle) loadFile(source, file string) error {
+	roots, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return tb.Update(source, roots)
+}
+
+// combine concatenates the roots of the sources, in the order of the source names, without duplicates.
+// It requires the mutex.
+func (tb *TrustBundle) combine() ([]byte, time.Time) {
+	sources := make([]string, 0, len(tb.roots))
+	for source := range tb.roots {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	var bundle []byte
+	var expireTime time.Time
+	seen := map[string]bool{}
+	for _, source := range sources {
+		// The roots were validated by Update.
+		certs, _ := parseRoots(tb.roots[source])
+		for _, cert := range certs {
+			if seen[string(cert.Raw)] {
+				continue
+			}
+			seen[string(cert.Raw)] = true
+			bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+			if expireTime.IsZero() || cert.NotAfter.Before(expireTime) {
+				expireTime = cert.NotAfter
+			}
+		}
+	}
+	return bundle, expireTime
+}
+
+// parseRoots parses the PEM encoded certificates, it returns an error if there is none.
+func parseRoots(roots []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(roots); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM encoded certificate")
+	}
+	return certs, nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"istio.io/istio/security/pkg/nodeagent/model"
+	"istio.io/istio/security/pkg/nodeagent/secretfetcher"
+)
+
+func TestTrustBundle(t *testing.T) {
+	cert, err := ioutil.ReadFile("./testdata/cert-chain.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tb := NewTrustBundle()
+	defer tb.Close()
+	bundles := make(chan []byte, 10)
+	tb.AddHandler(func(bundle []byte) {
+		bundles <- bundle
+	})
+	expectBundle := func(want int) []byte {
+		t.Helper()
+		select {
+		case bundle := <-bundles:
+			if certs, err := parseRoots(bundle); err != nil || len(certs) != want {
+				t.Fatalf("got bundle %q, want %d roots", bundle, want)
+			}
+			if roots, _ := tb.Roots(); !bytes.Equal(roots, bundle) {
+				t.Errorf("Roots() => %q, want %q", roots, bundle)
+			}
+			return bundle
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for the bundle to change")
+		}
+		return nil
+	}
+
+	if err := tb.Update(CARootSource, cert); err != nil {
+		t.Fatal(err)
+	}
+	expectBundle(1)
+	// The roots are concatenated without duplicates.
+	if err := tb.Update(AdditionalRootSource, append(append(append([]byte{}, k8sCaCert...), '\n'), cert...)); err != nil {
+		t.Fatal(err)
+	}
+	expectBundle(2)
+	if _, expireTime := tb.Roots(); expireTime.IsZero() || expireTime.Year() == 2117 {
+		t.Errorf("got expiration time %v, want the one of the first root to expire", expireTime)
+	}
+
+	// An invalid update keeps the roots, an update with the same roots is not notified.
+	if err := tb.Update(CARootSource, []byte("invalid")); err == nil {
+		t.Error("expected an error for invalid roots")
+	}
+	if err := tb.Update(CARootSource, cert); err != nil {
+		t.Fatal(err)
+	}
+	if err := tb.Update(AdditionalRootSource, nil); err != nil {
+		t.Fatal(err)
+	}
+	expectBundle(1)
+
+	// The roots of a file are reloaded when it changes.
+	dir, err := ioutil.TempDir("", "trust-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "roots.pem")
+	if err := tb.WatchFile(AdditionalRootSource, file); err != nil {
+		t.Fatalf("failed to watch a missing file: %v", err)
+	}
+	if err := ioutil.WriteFile(file, k8sCaCert, 0600); err != nil {
+		t.Fatal(err)
+	}
+	expectBundle(2)
+}
+
+func TestSecretCacheTrustBundle(t *testing.T) {
+	cert, err := ioutil.ReadFile("./testdata/cert-chain.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tb := NewTrustBundle()
+	defer tb.Close()
+	fetcher := &secretfetcher.SecretFetcher{
+		UseCaClient: true,
+		CaClient:    &unavailableCAClient{},
+	}
+	sc := NewSecretCache(fetcher, notifyCb, Options{RotationInterval: time.Hour, TrustBundle: tb})
+	defer sc.Close()
+
+	// The mounted roots are served without waiting for the CA.
+	if err := tb.Update(MountedRootSource, cert); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := sc.GenerateSecret(context.Background(), "conn", RootCertReqResourceName, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roots, err := parseRoots(secret.RootCert); err != nil || len(roots) != 1 || secret.ExpireTime.Year() != 2117 {
+		t.Errorf("unexpected root secret %+v", secret)
+	}
+
+	// The proxies are pushed the new bundle.
+	if err := tb.Update(AdditionalRootSource, k8sCaCert); err != nil {
+		t.Fatal(err)
+	}
+	v, found := sc.secrets.Load(ConnKey{ConnectionID: "conn", ResourceName: RootCertReqResourceName})
+	if !found {
+		t.Fatal("root secret not found")
+	}
+	rotated := v.(model.SecretItem)
+	if roots, err := parseRoots(rotated.RootCert); err != nil || len(roots) != 2 || rotated.Version == secret.Version {
+		t.Errorf("unexpected root secret after the bundle changed %+v", rotated)
+	}
+}
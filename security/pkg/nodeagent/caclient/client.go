@@ -49,7 +49,7 @@ func NewCAClient(endpoint, caProviderName string, tlsFlag bool, tlsRootCert []by
 	vaultAuthPath, vaultSignCsrPath string) (caClientInterface.Client, error) {
 	switch caProviderName {
 	case googleCAName:
-		return gca.NewGoogleCAClient(endpoint, tlsFlag)
+		return gca.NewGoogleCAClient(DialTarget(endpoint), tlsFlag, DialOptions()...)
 	case vaultCAName:
 		return vault.NewVaultClient(tlsFlag, tlsRootCert, vaultAddr, vaultRole, vaultAuthPath, vaultSignCsrPath)
 	case citadelName:
@@ -62,7 +62,7 @@ func NewCAClient(endpoint, caProviderName string, tlsFlag bool, tlsRootCert []by
 		if err != nil {
 			return nil, err
 		}
-		return citadel.NewCitadelClient(endpoint, tlsFlag, rootCert)
+		return citadel.NewCitadelClient(DialTarget(endpoint), tlsFlag, rootCert, DialOptions()...)
 	default:
 		return nil, fmt.Errorf(
 			"CA provider %q isn't supported. Currently Istio supports %q", caProviderName, strings.Join([]string{googleCAName, citadelName, vaultCAName}, ","))
@@ -0,0 +1,76 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caclient
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+
+	"istio.io/pkg/env"
+)
+
+var (
+	keepaliveTime = env.RegisterDurationVar("CA_KEEPALIVE_TIME", 0,
+		"Idle time of the CA connection after which a keepalive ping is sent, so that NAT timeouts and "+
+			"broken connections are detected. Disabled if 0. The CA closes the connections pinging "+
+			"more often than its keepalive enforcement policy allows, 5m by default.").Get()
+	keepaliveTimeout = env.RegisterDurationVar("CA_KEEPALIVE_TIMEOUT", 20*time.Second,
+		"Time to wait for the keepalive ping ack of the CA before the connection is closed and "+
+			"re-established.").Get()
+	keepalivePermitWithoutStream = env.RegisterBoolVar("CA_KEEPALIVE_PERMIT_WITHOUT_STREAM", false,
+		"Send keepalive pings to the CA between the certificate requests. The CA must permit it.").Get()
+	dnsReresolve = env.RegisterBoolVar("CA_DNS_RERESOLVE", true,
+		"Resolve the CA address with the gRPC DNS resolver, so that the connection follows the "+
+			"changes of the CA IP addresses.").Get()
+)
+
+// DialOptions returns the options of the connections to the CA, in addition to the transport ones.
+func DialOptions() []grpc.DialOption {
+	if keepaliveTime <= 0 {
+		return nil
+	}
+	return []grpc.DialOption{grpc.WithKeepaliveParams(keepalive.ClientParameters{
+		Time:                keepaliveTime,
+		Timeout:             keepaliveTimeout,
+		PermitWithoutStream: keepalivePermitWithoutStream,
+	})}
+}
+
+// DialTarget returns the gRPC target of the CA endpoint. A host name is re-resolved by the DNS
+// resolver when the connection fails, instead of being resolved once per connection attempt.
+func DialTarget(endpoint string) string {
+	if !dnsReresolve {
+		return endpoint
+	}
+	return dnsTarget(endpoint)
+}
+
+func dnsTarget(endpoint string) string {
+	if strings.Contains(endpoint, "://") {
+		return endpoint
+	}
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		host = endpoint
+	}
+	if host == "" || net.ParseIP(host) != nil {
+		return endpoint
+	}
+	return "dns:///" + endpoint
+}
@@ -0,0 +1,33 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caclient
+
+import "testing"
+
+func TestDNSTarget(t *testing.T) {
+	testCases := map[string]string{
+		"istiod.istio-system.svc:15012": "dns:///istiod.istio-system.svc:15012",
+		"meshca.googleapis.com:443":     "dns:///meshca.googleapis.com:443",
+		"10.1.2.3:15012":                "10.1.2.3:15012",
+		"[::1]:15012":                   "[::1]:15012",
+		"dns:///istiod:15012":           "dns:///istiod:15012",
+		"unix:///var/run/ca.sock":       "unix:///var/run/ca.sock",
+	}
+	for endpoint, want := range testCases {
+		if got := dnsTarget(endpoint); got != want {
+			t.Errorf("dnsTarget(%q) = %q, want %q", endpoint, got, want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caclient
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	caClientInterface "istio.io/istio/security/pkg/nodeagent/caclient/interface"
+	"istio.io/pkg/log"
+)
+
+// Endpoint is a CA endpoint of a locality, e.g. the istiod of a cluster of the mesh.
+type Endpoint struct {
+	Address string
+	// Locality is the region/zone/subzone of the endpoint, empty if it serves all the localities alike.
+	Locality string
+}
+
+// ParseEndpoints parses the comma separated CA endpoints, prefixed with their locality, e.g.
+// "us-east1=istiod.east.example.com:15012,us-west1/us-west1-a=istiod.west.example.com:15012".
+func ParseEndpoints(endpoints string) ([]Endpoint, error) {
+	var ret []Endpoint
+	for _, e := range strings.Split(endpoints, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		var ep Endpoint
+		if parts := strings.SplitN(e, "=", 2); len(parts) == 2 {
+			ep = Endpoint{Locality: strings.Trim(parts[0], "/"), Address: parts[1]}
+		} else {
+			ep = Endpoint{Address: e}
+		}
+		if ep.Address == "" {
+			return nil, fmt.Errorf("invalid CA endpoint %q, expecting [<locality>=]<address>", e)
+		}
+		ret = append(ret, ep)
+	}
+	return ret, nil
+}
+
+// SortByLocality sorts the endpoints by preference for a workload of the locality: the ones of the
+// same region, zone and subzone first, then of the same region and zone, then of the same region.
+// The endpoints of the same preference keep their configured order.
+func SortByLocality(endpoints []Endpoint, locality string) {
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		return localityMatch(locality, endpoints[i].Locality) > localityMatch(locality, endpoints[j].Locality)
+	})
+}
+
+// localityMatch returns the number of leading region, zone and subzone of the endpoint locality
+// matching the workload locality, 0 if either is unknown.
+func localityMatch(locality, endpointLocality string) int {
+	if locality == "" || endpointLocality == "" {
+		return 0
+	}
+	l := strings.Split(locality, "/")
+	el := strings.Split(endpointLocality, "/")
+	n := 0
+	for n < len(l) && n < len(el) && l[n] == el[n] {
+		n++
+	}
+	return n
+}
+
+// failoverClient sends the CSRs to the first of its CAs able to sign them.
+type failoverClient struct {
+	addresses []string
+	clients   []caClientInterface.Client
+}
+
+// NewFailoverClient returns a client sending the CSRs to the CA endpoints closest to the locality, and
+// failing over to the next ones while they are unavailable. Every CSR is sent to the closest endpoint
+// first, so that the client fails back as soon as it recovers.
+func NewFailoverClient(endpoints []Endpoint, locality string,
+	newClient func(address string) (caClientInterface.Client, error)) (caClientInterface.Client, error) {
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no CA endpoint")
+	}
+	sorted := append([]Endpoint{}, endpoints...)
+	SortByLocality(sorted, locality)
+
+	c := &failoverClient{}
+	for _, ep := range sorted {
+		client, err := newClient(ep.Address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the client of the CA %s: %v", ep.Address, err)
+		}
+		c.addresses = append(c.addresses, ep.Address)
+		c.clients = append(c.clients, client)
+	}
+	log.Infof("Using the CA endpoints %v, in this order, for locality %q", c.addresses, locality)
+	if len(c.clients) == 1 {
+		return c.clients[0], nil
+	}
+	return c, nil
+}
+
+func (c *failoverClient) CSRSign(ctx context.Context, csrPEM []byte, subjectID string,
+	certValidTTLInSec int64) ([]string /*PEM-encoded certificate chain*/, error) {
+	var err error
+	for i, client := range c.clients {
+		var certs []string
+		certs, err = client.CSRSign(ctx, csrPEM, subjectID, certValidTTLInSec)
+		if err == nil {
+			if i > 0 {
+				log.Warnf("CSR signed by the CA %s, failed over from %s", c.addresses[i], c.addresses[0])
+			}
+			return certs, nil
+		}
+		if !shouldFailover(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		if i < len(c.clients)-1 {
+			log.Warnf("CA %s unavailable, failing over to %s: %v", c.addresses[i], c.addresses[i+1], err)
+		}
+	}
+	// The error of the last CA is returned as is, so that the caller retries it the same way.
+	return nil, err
+}
+
+// shouldFailover returns true if the CSR could be signed by another CA, rather than being denied by
+// any of them.
+func shouldFailover(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Internal,
+		codes.Unknown:
+		return true
+	}
+	return false
+}
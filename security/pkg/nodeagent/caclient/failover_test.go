@@ -0,0 +1,130 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caclient
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	caClientInterface "istio.io/istio/security/pkg/nodeagent/caclient/interface"
+)
+
+func TestParseEndpoints(t *testing.T) {
+	got, err := ParseEndpoints("us-east1=istiod.east:15012, us-west1/us-west1-a/=istiod.west:15012,istiod:15012")
+	if err != nil {
+		t.Fatalf("ParseEndpoints() failed: %v", err)
+	}
+	want := []Endpoint{
+		{Address: "istiod.east:15012", Locality: "us-east1"},
+		{Address: "istiod.west:15012", Locality: "us-west1/us-west1-a"},
+		{Address: "istiod:15012"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseEndpoints() = %v, want %v", got, want)
+	}
+
+	if _, err := ParseEndpoints("us-east1="); err == nil {
+		t.Error("ParseEndpoints() should fail without address")
+	}
+}
+
+func TestSortByLocality(t *testing.T) {
+	endpoints := []Endpoint{
+		{Address: "global", Locality: ""},
+		{Address: "east", Locality: "us-east1"},
+		{Address: "west-b", Locality: "us-west1/us-west1-b"},
+		{Address: "west-a", Locality: "us-west1/us-west1-a"},
+		{Address: "west", Locality: "us-west1"},
+	}
+	SortByLocality(endpoints, "us-west1/us-west1-a/subzone")
+	var got []string
+	for _, ep := range endpoints {
+		got = append(got, ep.Address)
+	}
+	want := []string{"west-a", "west-b", "west", "global", "east"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SortByLocality() = %v, want %v", got, want)
+	}
+}
+
+type mockCAClient struct {
+	err   error
+	calls int
+}
+
+func (c *mockCAClient) CSRSign(context.Context, []byte, string, int64) ([]string, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return []string{"cert", "root"}, nil
+}
+
+func TestFailoverClient(t *testing.T) {
+	testCases := map[string]struct {
+		east, west       error
+		expectedErr      error
+		expectedFailover bool
+	}{
+		"closest CA available": {},
+		"failover to the next CA": {
+			west:             status.Error(codes.Unavailable, "connection refused"),
+			expectedFailover: true,
+		},
+		"all CAs unavailable": {
+			west:             status.Error(codes.Unavailable, "connection refused"),
+			east:             status.Error(codes.DeadlineExceeded, "timeout"),
+			expectedErr:      status.Error(codes.DeadlineExceeded, "timeout"),
+			expectedFailover: true,
+		},
+		"denied by the closest CA": {
+			west:        status.Error(codes.PermissionDenied, "CSR denied"),
+			expectedErr: status.Error(codes.PermissionDenied, "CSR denied"),
+		},
+	}
+	for id, tc := range testCases {
+		clients := map[string]*mockCAClient{
+			"istiod.east:15012": {err: tc.east},
+			"istiod.west:15012": {err: tc.west},
+		}
+		client, err := NewFailoverClient([]Endpoint{
+			{Address: "istiod.east:15012", Locality: "us-east1"},
+			{Address: "istiod.west:15012", Locality: "us-west1"},
+		}, "us-west1/us-west1-a", func(address string) (caClientInterface.Client, error) {
+			return clients[address], nil
+		})
+		if err != nil {
+			t.Fatalf("%s: NewFailoverClient() failed: %v", id, err)
+		}
+		_, err = client.CSRSign(context.Background(), nil, "token", 3600)
+		if !reflect.DeepEqual(err, tc.expectedErr) {
+			t.Errorf("%s: CSRSign() error = %v, want %v", id, err, tc.expectedErr)
+		}
+		if clients["istiod.west:15012"].calls != 1 {
+			t.Errorf("%s: the closest CA should be called first", id)
+		}
+		expectedEast := 0
+		if tc.expectedFailover {
+			expectedEast = 1
+		}
+		if clients["istiod.east:15012"].calls != expectedEast {
+			t.Errorf("%s: the next CA got %d calls, want %d", id, clients["istiod.east:15012"].calls, expectedEast)
+		}
+	}
+}
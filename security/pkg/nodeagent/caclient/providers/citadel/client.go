@@ -48,7 +48,8 @@ type citadelClient struct {
 }
 
 // NewCitadelClient create a CA client for Citadel.
-func NewCitadelClient(endpoint string, tls bool, rootCert []byte) (caClientInterface.Client, error) {
+func NewCitadelClient(endpoint string, tls bool, rootCert []byte,
+	dialOpts ...grpc.DialOption) (caClientInterface.Client, error) {
 	c := &citadelClient{
 		caEndpoint:    endpoint,
 		enableTLS:     tls,
@@ -66,9 +67,9 @@ func NewCitadelClient(endpoint string, tls bool, rootCert []byte) (caClientInter
 		opts = grpc.WithInsecure()
 	}
 
-	// TODO(JimmyCYJ): This connection is create at construction time. If conn is broken at anytime,
-	//  need a way to reconnect.
-	conn, err := grpc.Dial(endpoint, opts)
+	// The connection is created at construction time and re-established by gRPC when it breaks, the
+	// dialOpts may add keepalive pings to detect the broken connections.
+	conn, err := grpc.Dial(endpoint, append([]grpc.DialOption{opts}, dialOpts...)...)
 	if err != nil {
 		citadelClientLog.Errorf("Failed to connect to endpoint %s: %v", endpoint, err)
 		return nil, fmt.Errorf("failed to connect to endpoint %s", endpoint)
@@ -46,7 +46,8 @@ type googleCAClient struct {
 }
 
 // NewGoogleCAClient create a CA client for Google CA.
-func NewGoogleCAClient(endpoint string, tls bool) (caClientInterface.Client, error) {
+func NewGoogleCAClient(endpoint string, tls bool,
+	dialOpts ...grpc.DialOption) (caClientInterface.Client, error) {
 	c := &googleCAClient{
 		caEndpoint: endpoint,
 		enableTLS:  tls,
@@ -63,9 +64,9 @@ func NewGoogleCAClient(endpoint string, tls bool) (caClientInterface.Client, err
 		opts = grpc.WithInsecure()
 	}
 
-	// TODO(JimmyCYJ): This connection is create at construction time. If conn is broken at anytime,
-	//  need a way to reconnect.
-	conn, err := grpc.Dial(endpoint, opts)
+	// The connection is created at construction time and re-established by gRPC when it breaks, the
+	// dialOpts may add keepalive pings to detect the broken connections.
+	conn, err := grpc.Dial(endpoint, append([]grpc.DialOption{opts}, dialOpts...)...)
 	if err != nil {
 		googleCAClientLog.Errorf("Failed to connect to endpoint %s: %v", endpoint, err)
 		return nil, fmt.Errorf("failed to connect to endpoint %s", endpoint)
@@ -0,0 +1,329 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sds
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	sds "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v2"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"istio.io/istio/security/pkg/nodeagent/cache"
+)
+
+type deltaDiscoveryStream interface {
+	Send(*xdsapi.DeltaDiscoveryResponse) error
+	Recv() (*xdsapi.DeltaDiscoveryRequest, error)
+	grpc.ServerStream
+}
+
+// deltaSDSConnection is a delta SDS stream, which subscribes to any number of resources and only
+// receives the secrets which changed. Each subscribed resource has its own sdsConnection in the
+// connection table, queuing its pushes in the push queue of the stream, so that the secret cache
+// notifies the stream of the changes of any of its resources without blocking on the others.
+type deltaSDSConnection struct {
+	// SDS streams implement this interface.
+	stream deltaDiscoveryStream
+
+	// pushes are the resources whose secret changed since the last push.
+	pushes *deltaPushQueue
+
+	// The ID of proxy from which the connection comes from.
+	proxyID string
+
+	// ConID is the connection identifier of all the resources of the stream.
+	conID string
+
+	// The connections of the subscribed resources, keyed by ResourceName. Only accessed by the
+	// goroutine serving the stream.
+	resources map[string]*sdsConnection
+
+	// The number of responses sent on the stream, used as the response nonce.
+	nonce int64
+}
+
+func newDeltaSDSConnection(stream deltaDiscoveryStream) *deltaSDSConnection {
+	return &deltaSDSConnection{
+		stream:    stream,
+		pushes:    newDeltaPushQueue(),
+		resources: map[string]*sdsConnection{},
+	}
+}
+
+// deltaPushQueue is the queue of the resources of a delta stream to push. A resource is queued once
+// however many times its secret changes before the stream pushes it, and queuing never blocks.
+type deltaPushQueue struct {
+	mutex   sync.Mutex
+	pending map[string]struct{}
+	// ready has a value when pending isn't empty.
+	ready chan struct{}
+}
+
+func newDeltaPushQueue() *deltaPushQueue {
+	return &deltaPushQueue{
+		pending: map[string]struct{}{},
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+// push queues the push of the resource.
+func (q *deltaPushQueue) push(resourceName string) {
+	q.mutex.Lock()
+	q.pending[resourceName] = struct{}{}
+	q.mutex.Unlock()
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// dequeue returns the queued resources, sorted, and empties the queue.
+func (q *deltaPushQueue) dequeue() []string {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	out := make([]string, 0, len(q.pending))
+	for resourceName := range q.pending {
+		out = append(out, resourceName)
+	}
+	q.pending = map[string]struct{}{}
+	sort.Strings(out)
+	return out
+}
+
+// DeltaSecrets serves delta SDS discovery requests and SDS push requests. Unlike StreamSecrets, a
+// single stream subscribes to many resources, and only the secrets which changed are pushed, which
+// matters to the ingress gateways watching many Kubernetes secrets.
+func (s *sdsservice) DeltaSecrets(stream sds.SecretDiscoveryService_DeltaSecretsServer) error {
+	token := ""
+	ctx := context.Background()
+
+	var receiveError error
+	reqChannel := make(chan *xdsapi.DeltaDiscoveryRequest, 1)
+	con := newDeltaSDSConnection(stream)
+
+	go receiveDeltaThread(con, reqChannel, &receiveError)
+	defer func() {
+		for resourceName := range con.resources {
+			s.unsubscribe(con, resourceName)
+		}
+	}()
+
+	for {
+		// Block until a request is received.
+		select {
+		case discReq, ok := <-reqChannel:
+			if !ok {
+				// Remote side closed connection.
+				sdsServiceLog.Errorf("Remote side closed delta connection")
+				return receiveError
+			}
+
+			if con.conID == "" {
+				// first request, the node is only set in the first request of delta streams.
+				if discReq.Node == nil || discReq.Node.Id == "" {
+					sdsServiceLog.Errorf("Close connection. Delta discovery request %+v missing node id", discReq)
+					return fmt.Errorf("delta discovery request %+v missing node id", discReq)
+				}
+				con.conID = constructConnectionID(discReq.Node.Id)
+				con.proxyID = discReq.Node.Id
+
+				if s.localJWT {
+					// Running in-process, no need to pass the token from envoy to agent as in-context - use the file
+					tok, err := ioutil.ReadFile(JWTPath)
+					if err != nil {
+						sdsServiceLog.Errorf("Failed to get credential token: %v", err)
+						return err
+					}
+					token = string(tok)
+				} else if !s.skipToken {
+					ctx = stream.Context()
+					t, err := getCredentialToken(ctx)
+					if err != nil {
+						sdsServiceLog.Errorf("%s Close connection. Failed to get credential token from "+
+							"incoming request: %v", sdsLogPrefix(con.conID, ""), err)
+						return err
+					}
+					token = t
+				}
+				sdsServiceLog.Debugf("%s received first delta SDS request from proxy %q",
+					sdsLogPrefix(con.conID, ""), con.proxyID)
+			}
+
+			if discReq.ErrorDetail != nil {
+				totalSecretUpdateFailureCounts.Increment()
+				sdsServiceLog.Warnf("%s received delta SDS NACK from proxy %q, nonce %q, error details %s",
+					sdsLogPrefix(con.conID, ""), con.proxyID, discReq.ResponseNonce, discReq.ErrorDetail)
+			}
+
+			for _, resourceName := range discReq.ResourceNamesUnsubscribe {
+				if _, found := con.resources[resourceName]; found {
+					s.unsubscribe(con, resourceName)
+				}
+			}
+
+			resourceNames := make([]string, 0, len(discReq.ResourceNamesSubscribe))
+			for _, resourceName := range discReq.ResourceNamesSubscribe {
+				if resourceName == "" {
+					continue
+				}
+				if _, found := con.resources[resourceName]; found {
+					continue
+				}
+				conIDresourceNamePrefix := sdsLogPrefix(con.conID, resourceName)
+				sdsServiceLog.Debugf("%s proxy %q subscribed to the resource", conIDresourceNamePrefix, con.proxyID)
+
+				resourceCon := &sdsConnection{
+					Connect:      time.Now(),
+					proxyID:      con.proxyID,
+					ResourceName: resourceName,
+					notify:       con.pushes.push,
+					conID:        con.conID,
+				}
+				con.resources[resourceName] = resourceCon
+				addConn(cache.ConnKey{ConnectionID: con.conID, ResourceName: resourceName}, resourceCon)
+				totalActiveConnCounts.Increment()
+
+				// The secret of the ingress gateway is pushed once the kubernetes secret is ready.
+				if s.st.ShouldWaitForIngressGatewaySecret(con.conID, resourceName, token) {
+					sdsServiceLog.Warnf("%s waiting for ingress gateway secret for proxy %q\n",
+						conIDresourceNamePrefix, con.proxyID)
+					continue
+				}
+
+				secret, err := s.st.GenerateSecret(ctx, con.conID, resourceName, token)
+				if err != nil {
+					sdsServiceLog.Errorf("%s Close connection. Failed to get secret for proxy %q from "+
+						"secret cache: %v", conIDresourceNamePrefix, con.proxyID, err)
+					return err
+				}
+				resourceCon.mutex.Lock()
+				resourceCon.secret = secret
+				resourceCon.mutex.Unlock()
+				resourceNames = append(resourceNames, resourceName)
+			}
+
+			if len(resourceNames) == 0 {
+				// ACK, NACK or unsubscription.
+				continue
+			}
+			if err := pushDeltaSDS(con, resourceNames); err != nil {
+				sdsServiceLog.Errorf("%s Close connection. Failed to push key/cert to proxy %q: %v",
+					sdsLogPrefix(con.conID, ""), con.proxyID, err)
+				return err
+			}
+		case <-con.pushes.ready:
+			resourceNames := make([]string, 0)
+			for _, resourceName := range con.pushes.dequeue() {
+				if _, found := con.resources[resourceName]; !found {
+					sdsServiceLog.Debugf("%s skip push for the unsubscribed resource", sdsLogPrefix(con.conID, resourceName))
+					continue
+				}
+				resourceNames = append(resourceNames, resourceName)
+			}
+			if len(resourceNames) == 0 {
+				continue
+			}
+			sdsServiceLog.Debugf("%s received push requests of %v for proxy %q", sdsLogPrefix(con.conID, ""),
+				resourceNames, con.proxyID)
+
+			if err := pushDeltaSDS(con, resourceNames); err != nil {
+				sdsServiceLog.Errorf("%s Close connection. Failed to push key/cert to proxy %q: %v",
+					sdsLogPrefix(con.conID, ""), con.proxyID, err)
+				return err
+			}
+		}
+	}
+}
+
+// unsubscribe removes the resource of the delta SDS stream.
+func (s *sdsservice) unsubscribe(con *deltaSDSConnection, resourceName string) {
+	sdsServiceLog.Debugf("%s proxy %q unsubscribed from the resource",
+		sdsLogPrefix(con.conID, resourceName), con.proxyID)
+	delete(con.resources, resourceName)
+	recycleConnection(con.conID, resourceName)
+	s.st.DeleteSecret(con.conID, resourceName)
+}
+
+// pushDeltaSDS pushes the secrets of the resources of the delta SDS stream in a single response. The
+// resources without secret, e.g. whose Kubernetes secret was deleted, are removed from the proxy, and
+// stay subscribed so that their secret is pushed again when it is back.
+func pushDeltaSDS(con *deltaSDSConnection, resourceNames []string) error {
+	con.nonce++
+	response := &xdsapi.DeltaDiscoveryResponse{
+		TypeUrl: SecretType,
+		Nonce:   strconv.FormatInt(con.nonce, 10),
+	}
+	for _, resourceName := range resourceNames {
+		resourceCon := con.resources[resourceName]
+		resourceCon.mutex.RLock()
+		secret := resourceCon.secret
+		resourceCon.mutex.RUnlock()
+		if secret == nil {
+			response.RemovedResources = append(response.RemovedResources, resourceName)
+			continue
+		}
+
+		ms, err := ptypes.MarshalAny(sdsSecret(secret))
+		if err != nil {
+			sdsServiceLog.Errorf("%s failed to mashal secret for proxy: %v", sdsLogPrefix(con.conID, resourceName), err)
+			return err
+		}
+		response.Resources = append(response.Resources, &xdsapi.Resource{
+			Name:     resourceName,
+			Version:  secret.Version,
+			Resource: ms,
+		})
+	}
+
+	if err := con.stream.Send(response); err != nil {
+		sdsServiceLog.Errorf("%s failed to send delta response: %v", sdsLogPrefix(con.conID, ""), err)
+		totalPushErrorCounts.Increment()
+		return err
+	}
+
+	// Update metrics after push to avoid adding latency to SDS push.
+	sdsServiceLog.Infof("%s pushed %d secrets and removed %v from proxy\n", sdsLogPrefix(con.conID, ""),
+		len(response.Resources), response.RemovedResources)
+	totalPushCounts.Increment()
+	return nil
+}
+
+func receiveDeltaThread(con *deltaSDSConnection, reqChannel chan *xdsapi.DeltaDiscoveryRequest, errP *error) {
+	defer close(reqChannel) // indicates close of the remote side.
+	for {
+		req, err := con.stream.Recv()
+		if err != nil {
+			if status.Code(err) == codes.Canceled || err == io.EOF {
+				sdsServiceLog.Infof("delta connection is terminated: %v", err)
+				return
+			}
+			*errP = err
+			sdsServiceLog.Errorf("delta connection is terminated with errors %v", err)
+			return
+		}
+		reqChannel <- req
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sds
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	api "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	authapi "github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
+	core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	sds "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v2"
+	"github.com/golang/protobuf/ptypes"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	"istio.io/istio/security/pkg/nodeagent/cache"
+	"istio.io/istio/security/pkg/nodeagent/model"
+)
+
+func createDeltaSDSStream(t *testing.T, socket string) sds.SecretDiscoveryService_DeltaSecretsClient {
+	conn, err := setupConnection(socket)
+	if err != nil {
+		t.Fatalf("failed to setup connection to socket %q", socket)
+	}
+	sdsClient := sds.NewSecretDiscoveryServiceClient(conn)
+	header := metadata.Pairs(credentialTokenHeaderKey, "")
+	ctx := metadata.NewOutgoingContext(context.Background(), header)
+	stream, err := sdsClient.DeltaSecrets(ctx)
+	if err != nil {
+		t.Fatalf("DeltaSecrets failed: %v", err)
+	}
+	return stream
+}
+
+// deltaSecrets returns the secrets of the delta response, keyed by resource name.
+func deltaSecrets(t *testing.T, resp *api.DeltaDiscoveryResponse) map[string]*authapi.Secret {
+	t.Helper()
+	if resp.TypeUrl != SecretType {
+		t.Errorf("unexpected type url %q", resp.TypeUrl)
+	}
+	secrets := map[string]*authapi.Secret{}
+	for _, r := range resp.Resources {
+		secret := &authapi.Secret{}
+		if err := ptypes.UnmarshalAny(r.Resource, secret); err != nil {
+			t.Fatalf("failed to unmarshal the secret of %q: %v", r.Name, err)
+		}
+		if secret.Name != r.Name {
+			t.Errorf("secret name %q does not match the resource name %q", secret.Name, r.Name)
+		}
+		secrets[r.Name] = secret
+	}
+	return secrets
+}
+
+func waitForSecretDeleted(t *testing.T, st *mockSecretStore, key cache.ConnKey) {
+	t.Helper()
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if _, found := st.secrets.Load(key); !found {
+			return
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("secret %+v is not deleted", key)
+		}
+	}
+}
+
+func TestDeltaSecrets(t *testing.T) {
+	socket := fmt.Sprintf("/tmp/gotest%s.sock", string(uuid.NewUUID()))
+	server, st := createSDSServer(t, socket)
+	defer server.Stop()
+
+	stream := createDeltaSDSStream(t, socket)
+	proxyID := "router~127.0.0.1~DeltaSecrets~local"
+
+	// A single request subscribes to both resources.
+	if err := stream.Send(&api.DeltaDiscoveryRequest{
+		Node:                   &core.Node{Id: proxyID},
+		TypeUrl:                SecretType,
+		ResourceNamesSubscribe: []string{testResourceName, cache.RootCertReqResourceName},
+	}); err != nil {
+		t.Fatalf("stream.Send failed: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("stream.Recv failed: %v", err)
+	}
+	secrets := deltaSecrets(t, resp)
+	if len(secrets) != 2 {
+		t.Fatalf("got %d secrets, expected 2", len(secrets))
+	}
+	tlsCert := secrets[testResourceName].GetTlsCertificate()
+	if !bytes.Equal(tlsCert.GetCertificateChain().GetInlineBytes(), fakeCertificateChain) ||
+		!bytes.Equal(tlsCert.GetPrivateKey().GetInlineBytes(), fakePrivateKey) {
+		t.Errorf("unexpected key/cert %+v", tlsCert)
+	}
+	rootCert := secrets[cache.RootCertReqResourceName].GetValidationContext()
+	if !bytes.Equal(rootCert.GetTrustedCa().GetInlineBytes(), fakeRootCert) {
+		t.Errorf("unexpected root cert %+v", rootCert)
+	}
+
+	// ACK.
+	if err := stream.Send(&api.DeltaDiscoveryRequest{TypeUrl: SecretType, ResponseNonce: resp.Nonce}); err != nil {
+		t.Fatalf("stream.Send failed: %v", err)
+	}
+
+	// Only the pushed secret is sent.
+	conID := getClientConID(proxyID)
+	pushSecret := &model.SecretItem{
+		CertificateChain: fakePushCertificateChain,
+		PrivateKey:       fakePushPrivateKey,
+		ResourceName:     testResourceName,
+		Version:          time.Now().String(),
+	}
+	if err := NotifyProxy(cache.ConnKey{ConnectionID: conID, ResourceName: testResourceName}, pushSecret); err != nil {
+		t.Fatalf("failed to send push notification to proxy %q: %v", conID, err)
+	}
+	pushResp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("stream.Recv failed: %v", err)
+	}
+	if pushResp.Nonce == resp.Nonce {
+		t.Errorf("the nonce %q of the push should be new", pushResp.Nonce)
+	}
+	secrets = deltaSecrets(t, pushResp)
+	if len(secrets) != 1 {
+		t.Fatalf("got %d secrets, expected only the pushed secret", len(secrets))
+	}
+	tlsCert = secrets[testResourceName].GetTlsCertificate()
+	if !bytes.Equal(tlsCert.GetCertificateChain().GetInlineBytes(), fakePushCertificateChain) ||
+		!bytes.Equal(tlsCert.GetPrivateKey().GetInlineBytes(), fakePushPrivateKey) {
+		t.Errorf("unexpected pushed key/cert %+v", tlsCert)
+	}
+
+	// Unsubscribing removes the secret of the resource.
+	if err := stream.Send(&api.DeltaDiscoveryRequest{
+		TypeUrl:                  SecretType,
+		ResponseNonce:            pushResp.Nonce,
+		ResourceNamesUnsubscribe: []string{cache.RootCertReqResourceName},
+	}); err != nil {
+		t.Fatalf("stream.Send failed: %v", err)
+	}
+	waitForSecretDeleted(t, st, cache.ConnKey{ConnectionID: conID, ResourceName: cache.RootCertReqResourceName})
+	if _, found := st.secrets.Load(cache.ConnKey{ConnectionID: conID, ResourceName: testResourceName}); !found {
+		t.Errorf("the secret of the subscribed resource should be kept")
+	}
+
+	// A nil secret removes the resource from the proxy, without closing the stream.
+	if err := NotifyProxy(cache.ConnKey{ConnectionID: conID, ResourceName: testResourceName}, nil); err != nil {
+		t.Fatalf("failed to send push notification to proxy %q: %v", conID, err)
+	}
+	removeResp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("stream.Recv failed: %v", err)
+	}
+	if len(removeResp.Resources) != 0 || len(removeResp.RemovedResources) != 1 ||
+		removeResp.RemovedResources[0] != testResourceName {
+		t.Errorf("got resources %v and removed resources %v, expected only %q removed",
+			removeResp.Resources, removeResp.RemovedResources, testResourceName)
+	}
+
+	// The resource is still subscribed, its secret is pushed again when it is back.
+	if err := NotifyProxy(cache.ConnKey{ConnectionID: conID, ResourceName: testResourceName}, pushSecret); err != nil {
+		t.Fatalf("failed to send push notification to proxy %q: %v", conID, err)
+	}
+	pushResp, err = stream.Recv()
+	if err != nil {
+		t.Fatalf("stream.Recv failed: %v", err)
+	}
+	if secrets = deltaSecrets(t, pushResp); len(secrets) != 1 || secrets[testResourceName] == nil {
+		t.Errorf("got secrets %v, expected the secret of %q", secrets, testResourceName)
+	}
+
+	// Closing the stream removes the secrets of its resources.
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("stream.CloseSend failed: %v", err)
+	}
+	waitForSecretDeleted(t, st, cache.ConnKey{ConnectionID: conID, ResourceName: testResourceName})
+	checkStaledConnCount(t)
+}
+
+func TestDeltaPushQueue(t *testing.T) {
+	q := newDeltaPushQueue()
+	// Queuing never blocks, and the resources are coalesced.
+	q.push("b")
+	q.push("a")
+	q.push("b")
+	select {
+	case <-q.ready:
+	default:
+		t.Fatal("the queue should be ready")
+	}
+	if got := q.dequeue(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("dequeue() => %v, want [a b]", got)
+	}
+	select {
+	case <-q.ready:
+		t.Error("the queue should not be ready once dequeued")
+	default:
+	}
+	if got := q.dequeue(); len(got) != 0 {
+		t.Errorf("dequeue() => %v, want none", got)
+	}
+}
@@ -52,6 +52,15 @@ var (
 		"total_secret_update_failures",
 		"The total number of dynamic secret update failures reported by proxy.",
 	)
+
+	// gatewaySecretPushLatency records the time from the update of a kubernetes secret of the ingress gateway,
+	// as observed by the watch or forced by a refresh, to its push to the proxy.
+	gatewaySecretPushLatency = monitoring.NewDistribution(
+		"gateway_secret_push_latency",
+		"The time from the update of a kubernetes secret of the ingress gateway to its SDS push, in seconds.",
+		[]float64{.01, .1, .5, 1, 3, 5, 10, 30, 60},
+		monitoring.WithUnit(monitoring.Seconds),
+	)
 )
 
 func init() {
@@ -61,5 +70,6 @@ func init() {
 		totalActiveConnCounts,
 		totalStaleConnCounts,
 		totalSecretUpdateFailureCounts,
+		gatewaySecretPushLatency,
 	)
 }
@@ -77,7 +77,10 @@ type discoveryStream interface {
 }
 
 // sdsEvent represents a secret event that results in a push.
-type sdsEvent struct{}
+type sdsEvent struct {
+	// The ResourceName of the changed secret, used by the delta SDS streams of many resources.
+	resourceName string
+}
 
 type sdsConnection struct {
 	// Time of connection, for debugging.
@@ -92,6 +95,10 @@ type sdsConnection struct {
 	// Sending on this channel results in  push.
 	pushChannel chan *sdsEvent
 
+	// notify, if set, is called instead of sending on the push channel, for the connections of the
+	// resources of the delta streams, which queue the pushes of all their resources.
+	notify func(resourceName string)
+
 	// SDS streams implement this interface.
 	stream discoveryStream
 
@@ -203,10 +210,6 @@ func (s *sdsservice) DebugInfo() (string, error) {
 	return string(debugJSON), nil
 }
 
-func (s *sdsservice) DeltaSecrets(stream sds.SecretDiscoveryService_DeltaSecretsServer) error {
-	return status.Error(codes.Unimplemented, "DeltaSecrets not implemented")
-}
-
 // StreamSecrets serves SDS discovery requests and SDS push requests
 func (s *sdsservice) StreamSecrets(stream sds.SecretDiscoveryService_StreamSecretsServer) error {
 	token := ""
@@ -369,10 +372,26 @@ func (s *sdsservice) StreamSecrets(stream sds.SecretDiscoveryService_StreamSecre
 					conIDresourceNamePrefix, proxyID, err)
 				return err
 			}
+			if s.skipToken {
+				// The ingress gateway is only pushed the updates of its kubernetes secrets, whose secret items
+				// are created when the updates are observed.
+				recordGatewaySecretPush(con, secret)
+			}
 		}
 	}
 }
 
+// recordGatewaySecretPush records the latency of the push of the updated secret, unless the push was skipped.
+func recordGatewaySecretPush(con *sdsConnection, secret *model.SecretItem) {
+	con.mutex.RLock()
+	sdsPushTime := con.sdsPushTime
+	con.mutex.RUnlock()
+	if sdsPushTime.Before(secret.CreatedTime) {
+		return
+	}
+	gatewaySecretPushLatency.Record(sdsPushTime.Sub(secret.CreatedTime).Seconds())
+}
+
 // FetchSecrets generates and returns secret from SecretManager in response to DiscoveryRequest
 func (s *sdsservice) FetchSecrets(ctx context.Context, discReq *xdsapi.DiscoveryRequest) (*xdsapi.DiscoveryResponse, error) {
 	token := ""
@@ -457,7 +476,11 @@ func NotifyProxy(connKey cache.ConnKey, secret *model.SecretItem) error {
 	conn.mutex.Unlock()
 	sdsClientsMutex.Unlock()
 
-	conn.pushChannel <- &sdsEvent{}
+	if conn.notify != nil {
+		conn.notify(connKey.ResourceName)
+		return nil
+	}
+	conn.pushChannel <- &sdsEvent{resourceName: connKey.ResourceName}
 	return nil
 }
 
@@ -598,6 +621,18 @@ func sdsDiscoveryResponse(s *model.SecretItem, conID, resourceName string) (*xds
 
 	resp.VersionInfo = s.Version
 	resp.Nonce = s.Version
+	ms, err := ptypes.MarshalAny(sdsSecret(s))
+	if err != nil {
+		sdsServiceLog.Errorf("%s failed to mashal secret for proxy: %v", conIDresourceNamePrefix, err)
+		return nil, err
+	}
+	resp.Resources = append(resp.Resources, ms)
+
+	return resp, nil
+}
+
+// sdsSecret converts the secret item to the SDS secret.
+func sdsSecret(s *model.SecretItem) *authapi.Secret {
 	secret := &authapi.Secret{
 		Name: s.ResourceName,
 	}
@@ -627,15 +662,7 @@ func sdsDiscoveryResponse(s *model.SecretItem, conID, resourceName string) (*xds
 			},
 		}
 	}
-
-	ms, err := ptypes.MarshalAny(secret)
-	if err != nil {
-		sdsServiceLog.Errorf("%s failed to mashal secret for proxy: %v", conIDresourceNamePrefix, err)
-		return nil, err
-	}
-	resp.Resources = append(resp.Resources, ms)
-
-	return resp, nil
+	return secret
 }
 
 func newSDSConnection(stream discoveryStream) *sdsConnection {
@@ -36,6 +36,8 @@ import (
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/util/uuid"
 
 	rpc "istio.io/gogo-genproto/googleapis/google/rpc"
@@ -1029,6 +1031,87 @@ func TestDebugEndpoints(t *testing.T) {
 	}
 }
 
+type mockCertStatusStore struct {
+	mockSecretStore
+	status *cache.CertStatus
+}
+
+func (ms *mockCertStatusStore) CertStatus() *cache.CertStatus {
+	return ms.status
+}
+
+func TestCertsDebugEndpoint(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		st         cache.SecretManager
+		wantStatus int
+	}{
+		{
+			name:       "not reported",
+			st:         &mockSecretStore{},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "no certificate",
+			st:         &mockCertStatusStore{status: &cache.CertStatus{CSRFailures: 3}},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "expired certificate",
+			st: &mockCertStatusStore{status: &cache.CertStatus{
+				SerialNumber: "1",
+				NotBefore:    now.Add(-2 * time.Hour),
+				NotAfter:     now.Add(-time.Hour),
+			}},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "valid certificate",
+			st: &mockCertStatusStore{status: &cache.CertStatus{
+				SerialNumber: "1",
+				NotBefore:    now.Add(-time.Hour),
+				NotAfter:     now.Add(time.Hour),
+				CSRSuccesses: 1,
+			}},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &Server{
+				workloadSds: &sdsservice{st: tc.st},
+				caEndpoint:  "istiod.istio-system:15012",
+			}
+			response := httptest.NewRecorder()
+			server.certsDebugHTTPHandler(response, httptest.NewRequest(http.MethodGet, "/debug/certs", nil))
+			if response.Code != tc.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", response.Code, tc.wantStatus, response.Body.String())
+			}
+			if tc.wantStatus == http.StatusNotFound {
+				return
+			}
+
+			debug := &CertDebug{}
+			if err := json.Unmarshal(response.Body.Bytes(), debug); err != nil {
+				t.Fatalf("debug JSON unmarshalling failed: %v", err)
+			}
+			if debug.CAEndpoint != "istiod.istio-system:15012" {
+				t.Errorf("unexpected CA endpoint %q", debug.CAEndpoint)
+			}
+			if (debug.Error == "") != (tc.wantStatus == http.StatusOK) {
+				t.Errorf("unexpected error %q", debug.Error)
+			}
+			want := tc.st.(*mockCertStatusStore).status
+			if debug.SerialNumber != want.SerialNumber || debug.CSRSuccesses != want.CSRSuccesses ||
+				debug.CSRFailures != want.CSRFailures || !debug.NotAfter.Equal(want.NotAfter) {
+				t.Errorf("got certificate status %+v, want %+v", debug.CertStatus, want)
+			}
+		})
+	}
+}
+
 func checkStaledConnCount(t *testing.T) {
 	// Manually clear staled clients instead of waiting for ticker.
 	clearStaledClients()
@@ -1041,3 +1124,78 @@ func checkStaledConnCount(t *testing.T) {
 		t.Errorf("expect %q to be 0, got %f", metricName, staleConnections)
 	}
 }
+
+type mockRefresherStore struct {
+	mockSecretStore
+	refreshed []string
+}
+
+func (ms *mockRefresherStore) RefreshK8sSecret(secretName string) error {
+	if secretName != "gateway-cert" {
+		return kerrors.NewNotFound(v1.Resource("secrets"), secretName)
+	}
+	ms.refreshed = append(ms.refreshed, secretName)
+	return nil
+}
+
+func TestGatewaySecretRefreshEndpoint(t *testing.T) {
+	tests := []struct {
+		name       string
+		st         cache.SecretManager
+		method     string
+		query      string
+		wantStatus int
+	}{
+		{
+			name:       "refreshed",
+			st:         &mockRefresherStore{},
+			method:     http.MethodPost,
+			query:      "name=gateway-cert",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "not found",
+			st:         &mockRefresherStore{},
+			method:     http.MethodPost,
+			query:      "name=missing-cert",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "no name",
+			st:         &mockRefresherStore{},
+			method:     http.MethodPost,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "get",
+			st:         &mockRefresherStore{},
+			method:     http.MethodGet,
+			query:      "name=gateway-cert",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "not supported",
+			st:         &mockSecretStore{},
+			method:     http.MethodPost,
+			query:      "name=gateway-cert",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &Server{gatewaySds: &sdsservice{st: tc.st}}
+			response := httptest.NewRecorder()
+			server.gatewaySecretRefreshHTTPHandler(response,
+				httptest.NewRequest(tc.method, "/debug/sds/gateway/refresh?"+tc.query, nil))
+			if response.Code != tc.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", response.Code, tc.wantStatus, response.Body.String())
+			}
+			if refresher, ok := tc.st.(*mockRefresherStore); ok {
+				if refreshed := len(refresher.refreshed) == 1; refreshed != (tc.wantStatus == http.StatusOK) {
+					t.Errorf("unexpected refreshes %v", refresher.refreshed)
+				}
+			}
+		})
+	}
+}
@@ -16,6 +16,7 @@ package sds
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
@@ -24,6 +25,7 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 
 	"istio.io/istio/security/pkg/nodeagent/cache"
 	"istio.io/istio/security/pkg/nodeagent/plugin"
@@ -101,6 +103,10 @@ type Options struct {
 	// UseLocalJWT is set when the sds server should use its own local JWT, and not expect one
 	// from the UDS caller. Used when it runs in the same container with Envoy.
 	UseLocalJWT bool
+
+	// SkipToken is set when the workload secrets are not fetched with a token, for example when the
+	// file-mounted certs are served. It takes precedence over UseLocalJWT.
+	SkipToken bool
 }
 
 // Server is the gPRC server that exposes SDS through UDS.
@@ -114,13 +120,26 @@ type Server struct {
 	grpcWorkloadServer *grpc.Server
 	grpcGatewayServer  *grpc.Server
 	debugServer        *http.Server
+
+	// caEndpoint is the CA the workload certificates are requested from, reported by the debug server.
+	caEndpoint string
+}
+
+// CertDebug is the state of the workload certificate, served by the debug server.
+type CertDebug struct {
+	*cache.CertStatus
+	CAEndpoint string `json:"ca_endpoint"`
+	// Error is set if there is no valid workload certificate.
+	Error string `json:"error,omitempty"`
 }
 
 // NewServer creates and starts the Grpc server for SDS.
 func NewServer(options Options, workloadSecretCache, gatewaySecretCache cache.SecretManager) (*Server, error) {
+	workloadLocalJWT := options.UseLocalJWT && !options.SkipToken
 	s := &Server{
-		workloadSds: newSDSService(workloadSecretCache, false, options.UseLocalJWT, options.RecycleInterval),
+		workloadSds: newSDSService(workloadSecretCache, options.SkipToken, workloadLocalJWT, options.RecycleInterval),
 		gatewaySds:  newSDSService(gatewaySecretCache, true, options.UseLocalJWT, options.RecycleInterval),
+		caEndpoint:  options.CAEndpoint,
 	}
 	if options.EnableWorkloadSDS {
 		if err := s.initWorkloadSdsService(&options); err != nil {
@@ -193,6 +212,8 @@ func (s *Server) initDebugServer(port int) {
 	mux := http.NewServeMux()
 	mux.HandleFunc(fmt.Sprintf("%s/sds/workload", debugBase), s.workloadSds.debugHTTPHandler)
 	mux.HandleFunc(fmt.Sprintf("%s/sds/gateway", debugBase), s.gatewaySds.debugHTTPHandler)
+	mux.HandleFunc(fmt.Sprintf("%s/sds/gateway/refresh", debugBase), s.gatewaySecretRefreshHTTPHandler)
+	mux.HandleFunc(fmt.Sprintf("%s/certs", debugBase), s.certsDebugHTTPHandler)
 	s.debugServer = &http.Server{
 		Addr:    fmt.Sprintf("127.0.0.1:%d", port),
 		Handler: mux,
@@ -221,6 +242,74 @@ func (s *sdsservice) debugHTTPHandler(w http.ResponseWriter, req *http.Request)
 	}
 }
 
+// certsDebugHTTPHandler serves the state of the workload certificate. The status is 503 if there is no
+// valid workload certificate, for the endpoint to be used as a probe.
+func (s *Server) certsDebugHTTPHandler(w http.ResponseWriter, req *http.Request) {
+	var reporter cache.CertStatusReporter
+	if s.workloadSds != nil {
+		reporter, _ = s.workloadSds.st.(cache.CertStatusReporter)
+	}
+	if reporter == nil {
+		http.Error(w, "the workload secrets do not report a certificate status", http.StatusNotFound)
+		return
+	}
+
+	debug := CertDebug{
+		CertStatus: reporter.CertStatus(),
+		CAEndpoint: s.caEndpoint,
+	}
+	status := http.StatusOK
+	if err := debug.Check(time.Now()); err != nil {
+		debug.Error = err.Error()
+		status = http.StatusServiceUnavailable
+	}
+	certsJSON, err := json.MarshalIndent(debug, " ", "	")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("debug endpoint failure: %s", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(certsJSON); err != nil {
+		sdsServiceLog.Errorf("debug endpoint failed to write response: %s", err)
+	}
+}
+
+// gatewaySecretRefreshHTTPHandler reloads the kubernetes secret of the name parameter and pushes it to the
+// ingress gateway, for when a certificate was rotated but the gateway still serves the old one. The debug
+// server only listens on localhost, so the callers are the ones authorized to exec into the pod.
+func (s *Server) gatewaySecretRefreshHTTPHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	name := req.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "the name of the secret is required", http.StatusBadRequest)
+		return
+	}
+	var refresher cache.SecretRefresher
+	if s.gatewaySds != nil {
+		refresher, _ = s.gatewaySds.st.(cache.SecretRefresher)
+	}
+	if refresher == nil {
+		http.Error(w, "the gateway secrets can not be refreshed", http.StatusNotFound)
+		return
+	}
+	if err := refresher.RefreshK8sSecret(name); err != nil {
+		status := http.StatusInternalServerError
+		if kerrors.IsNotFound(err) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, fmt.Sprintf("failed to refresh secret %s: %v", name, err), status)
+		return
+	}
+	sdsServiceLog.Infof("secret %s is refreshed on request", name)
+	if _, err := fmt.Fprintf(w, "secret %s refreshed\n", name); err != nil {
+		sdsServiceLog.Errorf("debug endpoint failed to write response: %s", err)
+	}
+}
+
 func (s *Server) initWorkloadSdsService(options *Options) error { //nolint: unparam
 	if options.GrpcServer != nil {
 		s.grpcWorkloadServer = options.GrpcServer
@@ -22,11 +22,14 @@ import (
 	"sync"
 	"time"
 
+	authorizationapi "k8s.io/api/authorization/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
 
@@ -55,6 +58,16 @@ const (
 	// IngressSecretNamespace the namespace of kubernetes secrets to watch.
 	ingressSecretNamespace = "INGRESS_GATEWAY_NAMESPACE"
 
+	// ingressSecretNamespaces the comma separated list of the other namespaces of kubernetes secrets
+	// to watch, or "*" for all namespaces.
+	ingressSecretNamespaces = "INGRESS_GATEWAY_SECRET_NAMESPACES"
+
+	// ingressSecretSelector the label selector of kubernetes secrets to watch in the other namespaces.
+	ingressSecretSelector = "INGRESS_GATEWAY_SECRET_SELECTOR"
+
+	// AllNamespaces watches the secrets of all namespaces.
+	AllNamespaces = "*"
+
 	// IngressGatewaySdsCaSuffix is the suffix of the sds resource name for root CA. All resource
 	// names for ingress gateway root certs end with "-cacert".
 	IngressGatewaySdsCaSuffix = "-cacert"
@@ -106,6 +119,11 @@ type SecretFetcher struct {
 
 	secretNamespace string
 	coreV1          corev1.CoreV1Interface
+
+	// Controllers of the secrets of the other namespaces, whose resource names are <namespace>/<name>.
+	nsControllers []cache.Controller
+	// The label selectors of the other namespaces whose secrets are watched, "" for all namespaces.
+	watchedNamespaces map[string]labels.Selector
 }
 
 func fatalf(template string, args ...interface{}) {
@@ -130,7 +148,7 @@ func NewSecretFetcher(ingressGatewayAgent bool, endpoint, caProviderName string,
 		}
 		ret.FallbackSecretName = ingressFallbackSecret
 		secretFetcherLog.Debugf("SecretFetcher set fallback secret name %s", ret.FallbackSecretName)
-		ret.InitWithKubeClient(cs.CoreV1())
+		ret.InitWithKubeClientAndNamespaces(cs, namespaceVar.Get())
 	} else {
 		caClient, err := ca.NewCAClient(endpoint, caProviderName, tlsFlag, tlsRootCert,
 			vaultAddr, vaultRole, vaultAuthPath, vaultSignCsrPath)
@@ -149,10 +167,23 @@ func NewSecretFetcher(ingressGatewayAgent bool, endpoint, caProviderName string,
 // Only used when watching kubernetes gateway secrets.
 func (sf *SecretFetcher) Run(ch chan struct{}) {
 	go sf.scrtController.Run(ch)
-	cache.WaitForCacheSync(ch, sf.scrtController.HasSynced)
+	synced := []cache.InformerSynced{sf.scrtController.HasSynced}
+	for _, c := range sf.nsControllers {
+		go c.Run(ch)
+		synced = append(synced, c.HasSynced)
+	}
+	cache.WaitForCacheSync(ch, synced...)
 }
 
-var namespaceVar = env.RegisterStringVar(ingressSecretNamespace, "", "")
+var (
+	namespaceVar  = env.RegisterStringVar(ingressSecretNamespace, "", "")
+	namespacesVar = env.RegisterStringVar(ingressSecretNamespaces, "",
+		"Comma separated list of the other namespaces whose secrets are served by the ingress gateway "+
+			"as <namespace>/<name>, or * for all namespaces. Only the namespaces where the gateway is "+
+			"authorized to list and watch secrets are watched.")
+	selectorVar = env.RegisterStringVar(ingressSecretSelector, "",
+		"Label selector of the secrets served by the ingress gateway in the other namespaces.")
+)
 
 // InitWithKubeClient initializes SecretFetcher to watch kubernetes secrets.
 func (sf *SecretFetcher) InitWithKubeClient(core corev1.CoreV1Interface) { // nolint:interfacer
@@ -161,14 +192,104 @@ func (sf *SecretFetcher) InitWithKubeClient(core corev1.CoreV1Interface) { // no
 
 // InitWithKubeClientAndNs initializes SecretFetcher to watch kubernetes secrets.
 func (sf *SecretFetcher) InitWithKubeClientAndNs(core corev1.CoreV1Interface, namespace string) { // nolint:interfacer
+	sf.scrtStore, sf.scrtController = newSecretInformer(core, namespace, "", cache.ResourceEventHandlerFuncs{
+		AddFunc:    sf.scrtAdded,
+		DeleteFunc: sf.scrtDeleted,
+		UpdateFunc: sf.scrtUpdated,
+	})
+
+	sf.secretNamespace = namespace
+	sf.coreV1 = core
+
+}
+
+// InitWithKubeClientAndNamespaces initializes SecretFetcher to watch kubernetes secrets of the namespace,
+// and of the other namespaces set at env variable INGRESS_GATEWAY_SECRET_NAMESPACES.
+func (sf *SecretFetcher) InitWithKubeClientAndNamespaces(client kubernetes.Interface, namespace string) {
+	sf.InitWithKubeClientAndNs(client.CoreV1(), namespace)
+	if namespaces := namespacesVar.Get(); namespaces != "" {
+		sf.WatchNamespaces(client, strings.Split(namespaces, ","), selectorVar.Get())
+	}
+}
+
+// WatchNamespaces watches the secrets matching the label selector in the other namespaces, or in all
+// namespaces for AllNamespaces, so that a shared ingress gateway serves the secrets of the applications
+// in their own namespaces. The secrets of the other namespaces are served as <namespace>/<name>. Only
+// the namespaces where the gateway is authorized to list and watch secrets are watched. Must be called
+// after InitWithKubeClientAndNs, and before Run.
+func (sf *SecretFetcher) WatchNamespaces(client kubernetes.Interface, namespaces []string, selector string) {
+	if sf.secretNamespace == "" {
+		secretFetcherLog.Warnf("secrets of all namespaces are already watched, skip watching namespaces %v", namespaces)
+		return
+	}
+	labelSelector, err := labels.Parse(selector)
+	if err != nil {
+		secretFetcherLog.Errorf("skip watching namespaces %v, invalid selector %q: %v", namespaces, selector, err)
+		return
+	}
+	if sf.watchedNamespaces == nil {
+		sf.watchedNamespaces = map[string]labels.Selector{}
+	}
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    sf.nsScrtAdded,
+		DeleteFunc: sf.nsScrtDeleted,
+		UpdateFunc: sf.nsScrtUpdated,
+	}
+	for _, ns := range namespaces {
+		ns = strings.TrimSpace(ns)
+		if ns == AllNamespaces {
+			ns = metav1.NamespaceAll
+		} else if ns == "" || ns == sf.secretNamespace {
+			continue
+		}
+		if _, found := sf.watchedNamespaces[ns]; found {
+			continue
+		}
+		if err := canWatchSecrets(client, ns); err != nil {
+			secretFetcherLog.Warnf("skip watching secrets of namespace %q: %v", ns, err)
+			continue
+		}
+		secretFetcherLog.Infof("watching secrets of namespace %q with selector %q", ns, selector)
+		_, c := newSecretInformer(client.CoreV1(), ns, selector, handler)
+		sf.nsControllers = append(sf.nsControllers, c)
+		sf.watchedNamespaces[ns] = labelSelector
+	}
+}
+
+// canWatchSecrets checks that the gateway is authorized to list and watch the secrets of the namespace.
+func canWatchSecrets(client kubernetes.Interface, namespace string) error {
+	for _, verb := range []string{"list", "watch"} {
+		review, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(&authorizationapi.SelfSubjectAccessReview{
+			Spec: authorizationapi.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationapi.ResourceAttributes{
+					Namespace: namespace,
+					Verb:      verb,
+					Resource:  "secrets",
+				},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to review the access to secrets: %v", err)
+		}
+		if !review.Status.Allowed {
+			return fmt.Errorf("not authorized to %s secrets: %s", verb, review.Status.Reason)
+		}
+	}
+	return nil
+}
+
+func newSecretInformer(core corev1.CoreV1Interface, namespace, labelSelector string,
+	handler cache.ResourceEventHandler) (cache.Store, cache.Controller) {
 	istioSecretSelector := fields.SelectorFromSet(nil).String()
 	scrtLW := &cache.ListWatch{
 		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
 			options.FieldSelector = istioSecretSelector
+			options.LabelSelector = labelSelector
 			return core.Secrets(namespace).List(options)
 		},
 		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
 			options.FieldSelector = istioSecretSelector
+			options.LabelSelector = labelSelector
 			return core.Secrets(namespace).Watch(options)
 		},
 	}
@@ -178,16 +299,12 @@ func (sf *SecretFetcher) InitWithKubeClientAndNs(core corev1.CoreV1Interface, na
 		resyncPeriod = e
 	}
 
-	sf.scrtStore, sf.scrtController =
-		cache.NewInformer(scrtLW, &v1.Secret{}, resyncPeriod, cache.ResourceEventHandlerFuncs{
-			AddFunc:    sf.scrtAdded,
-			DeleteFunc: sf.scrtDeleted,
-			UpdateFunc: sf.scrtUpdated,
-		})
-
-	sf.secretNamespace = namespace
-	sf.coreV1 = core
+	return cache.NewInformer(scrtLW, &v1.Secret{}, resyncPeriod, handler)
+}
 
+// namespacedResourceName returns the resource name of a secret of the other namespaces.
+func namespacedResourceName(scrt *v1.Secret) string {
+	return scrt.GetNamespace() + "/" + scrt.GetName()
 }
 
 // isIngressGatewaySecret checks secret and decides whether this is a secret generated for ingress
@@ -243,8 +360,8 @@ func extractCACert(scrt *v1.Secret, fromCompoundSecret bool) (caCert []byte, exi
 // Otherwise the Secret can hold a server cert/key pair in `tls.crt`/`tls.key`,
 // or a server cert/key pair in `cert`/`key` and an optional client CA cert in
 // `-cacert`. A Secret with server cert/key and client CA cert is considered as a compound secret.
-func extractK8sSecretIntoSecretItem(scrt *v1.Secret, t time.Time) (serverItem, clientCAItem *model.SecretItem, isCAOnlySecret bool) {
-	resourceName := scrt.GetName()
+func extractK8sSecretIntoSecretItem(scrt *v1.Secret, resourceName string, t time.Time) (serverItem,
+	clientCAItem *model.SecretItem, isCAOnlySecret bool) {
 	isCAOnlySecret = strings.HasSuffix(resourceName, IngressGatewaySdsCaSuffix)
 
 	// Extract CA cert from CA only k8s secret.
@@ -323,15 +440,31 @@ func (sf *SecretFetcher) scrtAdded(obj interface{}) {
 		secretFetcherLog.Warnf("Failed to convert to secret object: %v", obj)
 		return
 	}
+	sf.secretAdded(scrt, scrt.GetName())
+}
+
+// nsScrtAdded is called when a secret of the other namespaces is added.
+func (sf *SecretFetcher) nsScrtAdded(obj interface{}) {
+	scrt, ok := obj.(*v1.Secret)
+	if !ok {
+		secretFetcherLog.Warnf("Failed to convert to secret object: %v", obj)
+		return
+	}
+	// The secrets of the namespace of the gateway are served by their names.
+	if scrt.GetNamespace() == sf.secretNamespace {
+		return
+	}
+	sf.secretAdded(scrt, namespacedResourceName(scrt))
+}
 
-	resourceName := scrt.GetName()
+func (sf *SecretFetcher) secretAdded(scrt *v1.Secret, resourceName string) {
 	if !isIngressGatewaySecret(scrt) {
 		secretFetcherLog.Debugf("secret %s is not an ingress gateway secret, skip adding secret", resourceName)
 		return
 	}
 
 	t := time.Now()
-	newSecret, certificateAuthorityNewSecret, isCaOnly := extractK8sSecretIntoSecretItem(scrt, t)
+	newSecret, certificateAuthorityNewSecret, isCaOnly := extractK8sSecretIntoSecretItem(scrt, resourceName, t)
 
 	// Load CA cert from CA only k8s secret and update cache.
 	if isCaOnly && certificateAuthorityNewSecret != nil {
@@ -370,8 +503,23 @@ func (sf *SecretFetcher) scrtDeleted(obj interface{}) {
 		secretFetcherLog.Warnf("Failed to convert to secret object: %v", obj)
 		return
 	}
+	sf.secretDeleted(scrt.GetName())
+}
+
+// nsScrtDeleted is called when a secret of the other namespaces is deleted.
+func (sf *SecretFetcher) nsScrtDeleted(obj interface{}) {
+	scrt, ok := obj.(*v1.Secret)
+	if !ok {
+		secretFetcherLog.Warnf("Failed to convert to secret object: %v", obj)
+		return
+	}
+	if scrt.GetNamespace() == sf.secretNamespace {
+		return
+	}
+	sf.secretDeleted(namespacedResourceName(scrt))
+}
 
-	key := scrt.GetName()
+func (sf *SecretFetcher) secretDeleted(key string) {
 	sf.secrets.Delete(key)
 	secretFetcherLog.Infof("secret %s is deleted", key)
 	// Delete all cache entries that match the deleted key.
@@ -405,7 +553,28 @@ func (sf *SecretFetcher) scrtUpdated(oldObj, newObj interface{}) {
 		secretFetcherLog.Warnf("Failed to convert to new secret object: %v", newObj)
 		return
 	}
+	sf.secretUpdated(oscrt, nscrt, nscrt.GetName())
+}
+
+// nsScrtUpdated is called when a secret of the other namespaces is updated.
+func (sf *SecretFetcher) nsScrtUpdated(oldObj, newObj interface{}) {
+	oscrt, ok := oldObj.(*v1.Secret)
+	if !ok {
+		secretFetcherLog.Warnf("Failed to convert to old secret object: %v", oldObj)
+		return
+	}
+	nscrt, ok := newObj.(*v1.Secret)
+	if !ok {
+		secretFetcherLog.Warnf("Failed to convert to new secret object: %v", newObj)
+		return
+	}
+	if nscrt.GetNamespace() == sf.secretNamespace {
+		return
+	}
+	sf.secretUpdated(oscrt, nscrt, namespacedResourceName(nscrt))
+}
 
+func (sf *SecretFetcher) secretUpdated(oscrt, nscrt *v1.Secret, resourceName string) {
 	oldScrtName := oscrt.GetName()
 	newScrtName := nscrt.GetName()
 	if oldScrtName != newScrtName {
@@ -422,8 +591,8 @@ func (sf *SecretFetcher) scrtUpdated(oldObj, newObj interface{}) {
 	// Kubernetes secret update is done by deleting first and creating a new one with the same name.
 	// Accordingly scrtDeleted and scrtAdded are called. When scrtUpdated is called, secret should remain unchanged.
 	t := time.Now()
-	oldScrt, oldCaScrt, _ := extractK8sSecretIntoSecretItem(oscrt, t)
-	newScrt, newCaScrt, isCaOnlyNew := extractK8sSecretIntoSecretItem(nscrt, t)
+	oldScrt, oldCaScrt, _ := extractK8sSecretIntoSecretItem(oscrt, resourceName, t)
+	newScrt, newCaScrt, isCaOnlyNew := extractK8sSecretIntoSecretItem(nscrt, resourceName, t)
 	updateSecret := shouldUpdateSecret(oldScrt, oldCaScrt, newScrt, newCaScrt)
 	if !updateSecret {
 		secretFetcherLog.Infof("secret %s does not change, skip update", newScrtName)
@@ -504,9 +673,10 @@ func (sf *SecretFetcher) FindIngressGatewaySecret(key string) (secret model.Secr
 		// the secret from API call. Since this is a rare case, to avoid complication, we don't add
 		// the secret back to cache as it is not a normal codepath. When watcher recovers, those secret
 		// shall be added back. Note that this approach only covers the TLS server key/cert fetching.
-		if sf.coreV1 != nil {
-			if secret, err := sf.coreV1.Secrets(sf.secretNamespace).Get(key, metav1.GetOptions{}); err == nil {
-				secretItem, _, _ := extractK8sSecretIntoSecretItem(secret, time.Now())
+		if namespace, name, selector, ok := sf.secretNamespaceAndName(key); ok {
+			secret, err := sf.coreV1.Secrets(namespace).Get(name, metav1.GetOptions{})
+			if err == nil && selector.Matches(labels.Set(secret.GetLabels())) {
+				secretItem, _, _ := extractK8sSecretIntoSecretItem(secret, key, time.Now())
 				if secretItem != nil {
 					secretFetcherLog.Infof("Return secret %s found by direct api call", key)
 					return *secretItem, true
@@ -532,6 +702,61 @@ func (sf *SecretFetcher) FindIngressGatewaySecret(key string) (secret model.Secr
 	return e, true
 }
 
+// RefreshSecret reloads the kubernetes secret of the resource name from the API server, and updates the
+// cache with its server cert/key pair and client CA cert even if they did not change, so that the proxies
+// are pushed the current secret when the watch missed its update.
+func (sf *SecretFetcher) RefreshSecret(resourceName string) error {
+	namespace, name, selector, ok := sf.secretNamespaceAndName(resourceName)
+	if !ok {
+		return fmt.Errorf("secret %s is not watched", resourceName)
+	}
+	scrt, err := sf.coreV1.Secrets(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if !selector.Matches(labels.Set(scrt.GetLabels())) || !isIngressGatewaySecret(scrt) {
+		return fmt.Errorf("kubernetes secret %s/%s is not an ingress gateway secret", namespace, name)
+	}
+
+	newSecret, certificateAuthorityNewSecret, _ := extractK8sSecretIntoSecretItem(scrt, resourceName, time.Now())
+	if newSecret == nil && certificateAuthorityNewSecret == nil {
+		return fmt.Errorf("kubernetes secret %s/%s has no valid certificate", namespace, name)
+	}
+	for _, secret := range []*model.SecretItem{newSecret, certificateAuthorityNewSecret} {
+		if secret == nil {
+			continue
+		}
+		sf.secrets.Store(secret.ResourceName, *secret)
+		secretFetcherLog.Infof("secret %s is refreshed", secret.ResourceName)
+		if sf.UpdateCache != nil {
+			sf.UpdateCache(secret.ResourceName, *secret)
+		}
+	}
+	return nil
+}
+
+// secretNamespaceAndName returns the namespace, the name and the label selector of the kubernetes secret
+// of the resource name, and whether the secret is watched.
+func (sf *SecretFetcher) secretNamespaceAndName(resourceName string) (string, string, labels.Selector, bool) {
+	if sf.coreV1 == nil {
+		return "", "", nil, false
+	}
+	parts := strings.SplitN(resourceName, "/", 2)
+	if len(parts) == 1 {
+		return sf.secretNamespace, resourceName, labels.Everything(), true
+	}
+	if parts[0] == sf.secretNamespace {
+		return "", "", nil, false
+	}
+	selector, found := sf.watchedNamespaces[parts[0]]
+	if !found {
+		if selector, found = sf.watchedNamespaces[metav1.NamespaceAll]; !found {
+			return "", "", nil, false
+		}
+	}
+	return parts[0], parts[1], selector, true
+}
+
 // AddSecret adds obj into local store. Only used for testing.
 func (sf *SecretFetcher) AddSecret(obj interface{}) {
 	sf.scrtAdded(obj)
@@ -18,9 +18,12 @@ import (
 	"bytes"
 	"testing"
 
+	authorizationapi "k8s.io/api/authorization/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
 
 	"istio.io/istio/security/pkg/nodeagent/model"
 	nodeagentutil "istio.io/istio/security/pkg/nodeagent/util"
@@ -771,6 +774,125 @@ func TestSecretFetcherUsingFallbackIngressSecret(t *testing.T) {
 	}
 }
 
+func newNamespacedTLSSecret(namespace, name string, labels map[string]string) *v1.Secret {
+	return &v1.Secret{
+		Data: map[string][]byte{
+			tlsScrtCert: k8sCertChainC,
+			tlsScrtKey:  k8sKeyC,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Type: "test-tls-secret",
+	}
+}
+
+// TestSecretFetcherWatchNamespaces verifies that secret fetcher serves the secrets of the other
+// namespaces where it is authorized to watch secrets, and which match the label selector.
+func TestSecretFetcherWatchNamespaces(t *testing.T) {
+	selected := map[string]string{"istio.io/gateway": "shared"}
+	client := fake.NewSimpleClientset(
+		newNamespacedTLSSecret("istio-system", "gateway-cert", nil),
+		newNamespacedTLSSecret("istio-system", "selected-gateway-cert", selected),
+		newNamespacedTLSSecret("bookinfo", "bookinfo-cert", selected),
+		newNamespacedTLSSecret("bookinfo", "unselected-cert", nil),
+		newNamespacedTLSSecret("denied", "denied-cert", selected))
+	client.PrependReactor("create", "selfsubjectaccessreviews",
+		func(action ktesting.Action) (bool, runtime.Object, error) {
+			review := action.(ktesting.CreateAction).GetObject().(*authorizationapi.SelfSubjectAccessReview)
+			review.Status.Allowed = review.Spec.ResourceAttributes.Namespace != "denied"
+			return true, review, nil
+		})
+
+	testCases := map[string]struct {
+		namespaces []string
+		found      []string
+		notFound   []string
+	}{
+		"namespace list": {
+			namespaces: []string{"bookinfo", "denied"},
+			found:      []string{"gateway-cert", "bookinfo/bookinfo-cert"},
+			notFound:   []string{"bookinfo/unselected-cert", "denied/denied-cert", "istio-system/selected-gateway-cert"},
+		},
+		"all namespaces": {
+			namespaces: []string{AllNamespaces},
+			found:      []string{"gateway-cert", "bookinfo/bookinfo-cert", "denied/denied-cert"},
+			notFound:   []string{"bookinfo/unselected-cert", "istio-system/selected-gateway-cert"},
+		},
+	}
+
+	for id, tc := range testCases {
+		sf := &SecretFetcher{}
+		sf.InitWithKubeClientAndNs(client.CoreV1(), "istio-system")
+		sf.WatchNamespaces(client, tc.namespaces, "istio.io/gateway=shared")
+		ch := make(chan struct{})
+		sf.Run(ch)
+
+		for _, name := range tc.found {
+			secret, ok := sf.FindIngressGatewaySecret(name)
+			if !ok {
+				t.Errorf("%s: secret %s should be found", id, name)
+				continue
+			}
+			compareSecret(t, &secret, &model.SecretItem{
+				ResourceName:     name,
+				CertificateChain: k8sCertChainC,
+				PrivateKey:       k8sKeyC,
+			})
+		}
+		for _, name := range tc.notFound {
+			if _, ok := sf.FindIngressGatewaySecret(name); ok {
+				t.Errorf("%s: secret %s should not be found", id, name)
+			}
+		}
+
+		// Deleting the secret of the other namespace deletes the namespaced resource.
+		sf.nsScrtDeleted(newNamespacedTLSSecret("bookinfo", "bookinfo-cert", selected))
+		if _, ok := sf.secrets.Load("bookinfo/bookinfo-cert"); ok {
+			t.Errorf("%s: deleted secret bookinfo/bookinfo-cert should not be found", id)
+		}
+		close(ch)
+	}
+}
+
+// TestSecretFetcherRefreshSecret verifies that a refresh reloads the secret from the API server and
+// updates the cache with it, even if the watch missed its update.
+func TestSecretFetcherRefreshSecret(t *testing.T) {
+	client := fake.NewSimpleClientset(newNamespacedTLSSecret("istio-system", "gateway-cert", nil))
+	sf := &SecretFetcher{}
+	sf.InitWithKubeClientAndNs(client.CoreV1(), "istio-system")
+	var updated []string
+	sf.UpdateCache = func(secretName string, ns model.SecretItem) {
+		updated = append(updated, secretName)
+	}
+
+	if err := sf.RefreshSecret("gateway-cert"); err != nil {
+		t.Fatalf("failed to refresh secret: %v", err)
+	}
+	if len(updated) != 1 || updated[0] != "gateway-cert" {
+		t.Errorf("got cache updates %v, want [gateway-cert]", updated)
+	}
+	secret, ok := sf.secrets.Load("gateway-cert")
+	if !ok {
+		t.Fatal("refreshed secret gateway-cert should be found")
+	}
+	item := secret.(model.SecretItem)
+	compareSecret(t, &item, &model.SecretItem{
+		ResourceName:     "gateway-cert",
+		CertificateChain: k8sCertChainC,
+		PrivateKey:       k8sKeyC,
+	})
+
+	if err := sf.RefreshSecret("missing-cert"); err == nil {
+		t.Error("refreshing a missing secret should fail")
+	}
+	if err := sf.RefreshSecret("bookinfo/bookinfo-cert"); err == nil {
+		t.Error("refreshing a secret of a namespace which is not watched should fail")
+	}
+}
+
 func compareSecret(t *testing.T, secret, expectedSecret *model.SecretItem) {
 	if expectedSecret.ResourceName != secret.ResourceName {
 		t.Errorf("resource name verification error: expected %s but got %s", expectedSecret.ResourceName, secret.ResourceName)
@@ -90,11 +90,12 @@ type IstioCAOptions struct {
 }
 
 // NewSelfSignedIstioCAOptions returns a new IstioCAOptions instance using self-signed certificate.
+// The key of the generated root cert is an EC key if ecSigAlg is set, an RSA key otherwise.
 func NewSelfSignedIstioCAOptions(ctx context.Context,
 	rootCertGracePeriodPercentile int, caCertTTL, rootCertCheckInverval, certTTL,
 	maxCertTTL time.Duration, org string, dualUse bool, namespace string,
 	readCertRetryInterval time.Duration, client corev1.CoreV1Interface,
-	rootCertFile string, enableJitter bool) (caOpts *IstioCAOptions, err error) {
+	rootCertFile string, enableJitter bool, ecSigAlg util.SupportedECSignatureAlgorithms) (caOpts *IstioCAOptions, err error) {
 	// For the first time the CA is up, if readSigningCertOnly is unset,
 	// it generates a self-signed key/cert pair and write it to CASecret.
 	// For subsequent restart, CA will reads key/cert from CASecret.
@@ -141,6 +142,7 @@ func NewSelfSignedIstioCAOptions(ctx context.Context,
 			IsCA:         true,
 			IsSelfSigned: true,
 			RSAKeySize:   caKeySize,
+			ECSigAlg:     ecSigAlg,
 			IsDualUse:    dualUse,
 		}
 		pemCert, pemKey, ckErr := util.GenCertKeyFromOptions(options)
@@ -166,7 +168,8 @@ func NewSelfSignedIstioCAOptions(ctx context.Context,
 		pkiCaLog.Infof("Using self-generated public key: %v", string(rootCerts))
 	} else {
 		pkiCaLog.Infof("Load signing key and cert from existing secret %s:%s", caSecret.Namespace, caSecret.Name)
-		rootCerts, err := util.AppendRootCerts(caSecret.Data[caCertID], rootCertFile)
+		// The roots include the roots of a root rotation in progress.
+		rootCerts, err := rootCertsFromSecret(caSecret, rootCertFile)
 		if err != nil {
 			return nil, fmt.Errorf("failed to append root certificates (%v)", err)
 		}
@@ -193,8 +196,15 @@ func NewPluggedCertIstioCAOptions(certChainFile, signingCertFile, signingKeyFile
 		CertTTL:    certTTL,
 		MaxCertTTL: maxCertTTL,
 	}
-	if caOpts.KeyCertBundle, err = util.NewVerifiedKeyCertBundleFromFile(
-		signingCertFile, signingKeyFile, certChainFile, rootCertFile); err != nil {
+	if IsSigningKeyURI(signingKeyFile) {
+		// The signing key is in a key storage backend rather than a PEM file.
+		caOpts.KeyCertBundle, err = newKeyCertBundleWithSigningKeyURI(
+			signingCertFile, signingKeyFile, certChainFile, rootCertFile, client)
+	} else {
+		caOpts.KeyCertBundle, err = util.NewVerifiedKeyCertBundleFromFile(
+			signingCertFile, signingKeyFile, certChainFile, rootCertFile)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to create CA KeyCertBundle (%v)", err)
 	}
 
@@ -227,6 +237,34 @@ func NewPluggedCertIstioCAOptions(certChainFile, signingCertFile, signingKeyFile
 	return caOpts, nil
 }
 
+// newKeyCertBundleWithSigningKeyURI returns the KeyCertBundle signing with the signer of the signing key URI.
+func newKeyCertBundleWithSigningKeyURI(signingCertFile, signingKeyURI, certChainFile, rootCertFile string,
+	client corev1.CoreV1Interface) (*util.KeyCertBundleImpl, error) {
+	provider, err := NewSigningKeyProvider(signingKeyURI, client)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := provider.Signer()
+	if err != nil {
+		return nil, err
+	}
+	certBytes, err := ioutil.ReadFile(signingCertFile)
+	if err != nil {
+		return nil, err
+	}
+	certChainBytes := []byte{}
+	if len(certChainFile) != 0 {
+		if certChainBytes, err = ioutil.ReadFile(certChainFile); err != nil {
+			return nil, err
+		}
+	}
+	rootCertBytes, err := ioutil.ReadFile(rootCertFile)
+	if err != nil {
+		return nil, err
+	}
+	return util.NewVerifiedKeyCertBundleWithSigner(certBytes, signer, certChainBytes, rootCertBytes)
+}
+
 // IstioCA generates keys and certificates for Istio identities.
 type IstioCA struct {
 	certTTL    time.Duration
@@ -302,6 +340,45 @@ func (ca *IstioCA) Sign(csrPEM []byte, subjectIDs []string, requestedLifetime ti
 	return cert, nil
 }
 
+// SignSubordinateCA takes a PEM-encoded CSR, subject IDs and lifetime, and returns a subordinate CA
+// certificate allowing at most maxPathLen intermediate CAs below it, for another CA to sign certificates
+// without the signing key of this CA. The path length is lowered to fit the path length constraint of
+// the signing cert.
+func (ca *IstioCA) SignSubordinateCA(csrPEM []byte, subjectIDs []string, lifetime time.Duration, maxPathLen int) ([]byte, error) {
+	signingCert, signingKey, _, _ := ca.keyCertBundle.GetAll()
+	if signingCert == nil {
+		return nil, caerror.NewError(caerror.CANotReady, fmt.Errorf("Istio CA is not ready")) // nolint
+	}
+
+	csr, err := util.ParsePemEncodedCSR(csrPEM)
+	if err != nil {
+		return nil, caerror.NewError(caerror.CSRError, err)
+	}
+
+	if lifetime <= 0 {
+		return nil, caerror.NewError(caerror.TTLError, fmt.Errorf("invalid TTL %s of the subordinate CA", lifetime))
+	}
+	if signingCert.MaxPathLenZero {
+		return nil, caerror.NewError(caerror.CertGenError, fmt.Errorf(
+			"the path length constraint of the signing cert doesn't allow subordinate CAs"))
+	}
+	if signingCert.MaxPathLen > 0 && maxPathLen >= signingCert.MaxPathLen {
+		maxPathLen = signingCert.MaxPathLen - 1
+	}
+
+	certBytes, err := util.GenSubordinateCACertFromCSR(csr, signingCert, csr.PublicKey, *signingKey, subjectIDs,
+		lifetime, maxPathLen)
+	if err != nil {
+		return nil, caerror.NewError(caerror.CertGenError, err)
+	}
+
+	block := &pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: certBytes,
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
 // SignWithCertChain is similar to Sign but returns the leaf cert and the entire cert chain.
 func (ca *IstioCA) SignWithCertChain(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) ([]byte, error) {
 	cert, err := ca.Sign(csrPEM, subjectIDs, ttl, forCA)
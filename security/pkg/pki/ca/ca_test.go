@@ -101,7 +101,7 @@ func TestCreateSelfSignedIstioCAWithoutSecret(t *testing.T) {
 	caopts, err := NewSelfSignedIstioCAOptions(context.Background(),
 		0, caCertTTL, rootCertCheckInverval, defaultCertTTL,
 		maxCertTTL, org, false, caNamespace, -1, client.CoreV1(),
-		rootCertFile, false)
+		rootCertFile, false, "")
 	if err != nil {
 		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
 	}
@@ -192,7 +192,7 @@ func TestCreateSelfSignedIstioCAWithSecret(t *testing.T) {
 	caopts, err := NewSelfSignedIstioCAOptions(context.Background(),
 		0, caCertTTL, rootCertCheckInverval, certTTL, maxCertTTL,
 		org, false, caNamespace, -1, client.CoreV1(),
-		rootCertFile, false)
+		rootCertFile, false, "")
 	if err != nil {
 		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
 	}
@@ -262,7 +262,7 @@ func TestCreateSelfSignedIstioCAReadSigningCertOnly(t *testing.T) {
 	defer cancel0()
 	_, err := NewSelfSignedIstioCAOptions(ctx0, 0,
 		caCertTTL, certTTL, rootCertCheckInverval, maxCertTTL, org, false,
-		caNamespace, time.Millisecond*10, client.CoreV1(), rootCertFile, false)
+		caNamespace, time.Millisecond*10, client.CoreV1(), rootCertFile, false, "")
 	if err == nil {
 		t.Errorf("Expected error, but succeeded.")
 	} else if err.Error() != expectedErr {
@@ -281,7 +281,7 @@ func TestCreateSelfSignedIstioCAReadSigningCertOnly(t *testing.T) {
 	defer cancel1()
 	caopts, err := NewSelfSignedIstioCAOptions(ctx1, 0,
 		caCertTTL, certTTL, rootCertCheckInverval, maxCertTTL, org, false,
-		caNamespace, time.Millisecond*10, client.CoreV1(), rootCertFile, false)
+		caNamespace, time.Millisecond*10, client.CoreV1(), rootCertFile, false, "")
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -451,7 +451,7 @@ func TestSignCSRForCA(t *testing.T) {
 	}
 
 	fields := &util.VerifyFields{
-		KeyUsage: x509.KeyUsageCertSign,
+		KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 		IsCA:     true,
 		Host:     subjectID,
 	}
@@ -510,6 +510,51 @@ func TestSignCSRTTLError(t *testing.T) {
 	}
 }
 
+func TestSignSubordinateCA(t *testing.T) {
+	subjectID := "spiffe://example.com/ns/istio-system/sa/istiod"
+	csrPEM, keyPEM, err := util.GenCSR(util.CertOptions{RSAKeySize: 2048, IsCA: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca, err := createCA(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The TTL of subordinate CAs is not limited by the max workload cert TTL, but by the signing cert.
+	certPEM, signErr := ca.SignSubordinateCA(csrPEM, []string{subjectID}, 365*24*time.Hour, 1)
+	if signErr != nil {
+		t.Fatal(signErr)
+	}
+
+	fields := &util.VerifyFields{
+		KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		IsCA:     true,
+		Host:     subjectID,
+	}
+	signingCert, _, certChainBytes, rootCertBytes := ca.GetCAKeyCertBundle().GetAll()
+	if err = util.VerifyCertificate(
+		keyPEM, append(certPEM, certChainBytes...), rootCertBytes, fields); err != nil {
+		t.Error(err)
+	}
+
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.MaxPathLen != 1 {
+		t.Errorf("Unexpected path length constraint (expecting 1, actual %d)", cert.MaxPathLen)
+	}
+	if !cert.NotAfter.Equal(signingCert.NotAfter) {
+		t.Errorf("Unexpected expiration (expecting %v, actual %v)", signingCert.NotAfter, cert.NotAfter)
+	}
+
+	if _, signErr := ca.SignSubordinateCA(csrPEM, []string{subjectID}, 0, 0); signErr == nil {
+		t.Errorf("Expected a TTL error")
+	}
+}
+
 func TestAppendRootCerts(t *testing.T) {
 	root1 := "root-cert-1"
 	expRootCerts := `root-cert-1
@@ -28,11 +28,13 @@ type FakeCA struct {
 	SignErr       *caerror.Error
 	KeyCertBundle util.KeyCertBundle
 	ReceivedIDs   []string
+	ReceivedTTL   time.Duration
 }
 
 // Sign returns the SignErr if SignErr is not nil, otherwise, it returns SignedCert.
 func (ca *FakeCA) Sign(csr []byte, identities []string, lifetime time.Duration, forCA bool) ([]byte, error) {
 	ca.ReceivedIDs = identities
+	ca.ReceivedTTL = lifetime
 	if ca.SignErr != nil {
 		return nil, ca.SignErr
 	}
@@ -0,0 +1,283 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// RootRotationPhase is the phase of a graceful rotation of the root of the self-signed CA. The new
+// root is distributed to the proxies before the CA signs with its key, and the previous root is
+// trusted until the proxies got certificates signed by the new key.
+type RootRotationPhase string
+
+const (
+	// RootRotationIdle is the phase without rotation in progress.
+	RootRotationIdle RootRotationPhase = "idle"
+	// RootRotationDistributing adds a new root to the trust bundle, the certificates are still signed
+	// by the current root.
+	RootRotationDistributing RootRotationPhase = "distributing"
+	// RootRotationSwitched signs the certificates with the key of the new root, the previous root is
+	// still in the trust bundle.
+	RootRotationSwitched RootRotationPhase = "switched"
+
+	// pendingCACertID and pendingCAPrivateKeyID are the root being distributed and its key, in the CA secret.
+	pendingCACertID       = "pending-ca-cert.pem"
+	pendingCAPrivateKeyID = "pending-ca-key.pem"
+	// previousCACertID is the root replaced by the switch, in the CA secret.
+	previousCACertID = "previous-ca-cert.pem"
+
+	// rootRotationUpdateAttempts is the number of attempts to update the CA secret, which the replicas
+	// of the CA may update concurrently.
+	rootRotationUpdateAttempts = 3
+)
+
+// RootRotationStatus is the state of the root rotation of the CA. The roots are identified by the
+// SHA-256 fingerprint of their DER encoding.
+type RootRotationStatus struct {
+	Phase RootRotationPhase `json:"phase"`
+	// SigningRoot is the root signing the certificates.
+	SigningRoot string `json:"signingRoot"`
+	// PendingRoot is the root being distributed, in the distributing phase.
+	PendingRoot string `json:"pendingRoot,omitempty"`
+	// PreviousRoot is the root replaced by the switch, in the switched phase.
+	PreviousRoot string `json:"previousRoot,omitempty"`
+}
+
+// ParseRootRotationPhase returns the root rotation phase of its name, idle if empty.
+func ParseRootRotationPhase(phase string) (RootRotationPhase, error) {
+	switch p := RootRotationPhase(strings.ToLower(strings.TrimSpace(phase))); p {
+	case "":
+		return RootRotationIdle, nil
+	case RootRotationIdle, RootRotationDistributing, RootRotationSwitched:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown root rotation phase %q, expecting %s, %s or %s", phase,
+		RootRotationIdle, RootRotationDistributing, RootRotationSwitched)
+}
+
+// CertFingerprint returns the hexadecimal SHA-256 fingerprint of a certificate.
+func CertFingerprint(cert *x509.Certificate) string {
+	fp := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(fp[:])
+}
+
+func pemFingerprint(certPEM []byte) (string, error) {
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		return "", err
+	}
+	return CertFingerprint(cert), nil
+}
+
+// rootRotationStatus returns the root rotation status recorded in the CA secret.
+func rootRotationStatus(caSecret *v1.Secret) (RootRotationStatus, error) {
+	status := RootRotationStatus{Phase: RootRotationIdle}
+	var err error
+	if status.SigningRoot, err = pemFingerprint(caSecret.Data[caCertID]); err != nil {
+		return status, fmt.Errorf("invalid CA cert: %v", err)
+	}
+	if cert := caSecret.Data[pendingCACertID]; len(cert) > 0 {
+		status.Phase = RootRotationDistributing
+		if status.PendingRoot, err = pemFingerprint(cert); err != nil {
+			return status, fmt.Errorf("invalid pending CA cert: %v", err)
+		}
+	}
+	if cert := caSecret.Data[previousCACertID]; len(cert) > 0 {
+		status.Phase = RootRotationSwitched
+		if status.PreviousRoot, err = pemFingerprint(cert); err != nil {
+			return status, fmt.Errorf("invalid previous CA cert: %v", err)
+		}
+	}
+	return status, nil
+}
+
+// rootCertsFromSecret returns the roots of the CA secret: the CA cert followed by the roots of the
+// root cert file, and the pending and previous roots of a root rotation.
+func rootCertsFromSecret(caSecret *v1.Secret, rootCertFile string) ([]byte, error) {
+	rootCerts, err := util.AppendRootCerts(caSecret.Data[caCertID], rootCertFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range []string{pendingCACertID, previousCACertID} {
+		if cert := caSecret.Data[id]; len(cert) > 0 {
+			rootCerts = append([]byte(strings.TrimSuffix(string(rootCerts), "\n")+"\n"), cert...)
+		}
+	}
+	return rootCerts, nil
+}
+
+// RootRotationStatus returns the status of the root rotation of the self-signed CA, from the CA secret
+// shared by its replicas.
+func (ca *IstioCA) RootRotationStatus() (RootRotationStatus, error) {
+	if ca.rootCertRotator == nil {
+		return RootRotationStatus{}, fmt.Errorf("root rotation is only supported by the self-signed CA " +
+			"with root cert rotation enabled")
+	}
+	rotator := ca.rootCertRotator
+	caSecret, err := rotator.config.client.Secrets(rotator.config.caStorageNamespace).Get(CASecret, metav1.GetOptions{})
+	if err != nil {
+		return RootRotationStatus{}, fmt.Errorf("failed to load CA secret %s:%s (%v)",
+			rotator.config.caStorageNamespace, CASecret, err)
+	}
+	return rootRotationStatus(caSecret)
+}
+
+// SetRootRotationPhase moves the root rotation of the self-signed CA to the given phase, and loads
+// the roots and signing key of the phase. It is idempotent, so that all the replicas of the CA can
+// apply the same phase: the first one updates the CA secret, the others load it.
+//
+// A rotation goes through the distributing phase, generating the new root, then the switched
+// phase, and back to the idle phase, which drops the previous root. Going back to the idle phase
+// from the distributing phase aborts the rotation.
+func (ca *IstioCA) SetRootRotationPhase(phase RootRotationPhase) error {
+	if ca.rootCertRotator == nil {
+		return fmt.Errorf("root rotation is only supported by the self-signed CA " +
+			"with root cert rotation enabled")
+	}
+	return ca.rootCertRotator.setRootRotationPhase(phase)
+}
+
+func (rotator *SelfSignedCARootCertRotator) setRootRotationPhase(phase RootRotationPhase) error {
+	rotator.mutex.Lock()
+	defer rotator.mutex.Unlock()
+
+	secrets := rotator.config.client.Secrets(rotator.config.caStorageNamespace)
+	for attempt := 1; ; attempt++ {
+		caSecret, err := secrets.Get(CASecret, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to load CA secret %s:%s (%v)", rotator.config.caStorageNamespace, CASecret, err)
+		}
+		changed, err := rotator.applyRootRotationPhase(caSecret, phase)
+		if err != nil {
+			return err
+		}
+		if changed {
+			updated, err := secrets.Update(caSecret)
+			if errors.IsConflict(err) && attempt < rootRotationUpdateAttempts {
+				// Another replica updated the secret, apply the phase to its latest version.
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to update CA secret (%v)", err)
+			}
+			caSecret = updated
+			rootCertRotatorLog.Infof("Root rotation moved to the %s phase", phase)
+		}
+		return rotator.loadRootRotation(caSecret)
+	}
+}
+
+// applyRootRotationPhase updates the CA secret for the phase, and returns whether it is changed.
+func (rotator *SelfSignedCARootCertRotator) applyRootRotationPhase(caSecret *v1.Secret,
+	phase RootRotationPhase) (bool, error) {
+	current, err := rootRotationStatus(caSecret)
+	if err != nil {
+		return false, err
+	}
+	if current.Phase == phase {
+		return false, nil
+	}
+	switch phase {
+	case RootRotationDistributing:
+		if current.Phase != RootRotationIdle {
+			return false, fmt.Errorf("the root rotation is in the %s phase, it must be completed before "+
+				"starting a new one", current.Phase)
+		}
+		pemCert, pemKey, err := rotator.genRoot(caSecret.Data[caPrivateKeyID])
+		if err != nil {
+			return false, err
+		}
+		caSecret.Data[pendingCACertID] = pemCert
+		caSecret.Data[pendingCAPrivateKeyID] = pemKey
+	case RootRotationSwitched:
+		if current.Phase != RootRotationDistributing {
+			return false, fmt.Errorf("the root rotation is in the %s phase, the new root must be "+
+				"distributed before switching to it", current.Phase)
+		}
+		caSecret.Data[previousCACertID] = caSecret.Data[caCertID]
+		caSecret.Data[caCertID] = caSecret.Data[pendingCACertID]
+		caSecret.Data[caPrivateKeyID] = caSecret.Data[pendingCAPrivateKeyID]
+		delete(caSecret.Data, pendingCACertID)
+		delete(caSecret.Data, pendingCAPrivateKeyID)
+	case RootRotationIdle:
+		delete(caSecret.Data, pendingCACertID)
+		delete(caSecret.Data, pendingCAPrivateKeyID)
+		delete(caSecret.Data, previousCACertID)
+	default:
+		return false, fmt.Errorf("unknown root rotation phase %q", phase)
+	}
+	return true, nil
+}
+
+// genRoot generates a new root and key, with the key type of the current key.
+func (rotator *SelfSignedCARootCertRotator) genRoot(currentKey []byte) ([]byte, []byte, error) {
+	options := util.CertOptions{
+		TTL:          rotator.config.caCertTTL,
+		Org:          rotator.config.org,
+		IsCA:         true,
+		IsSelfSigned: true,
+		RSAKeySize:   caKeySize,
+		IsDualUse:    rotator.config.dualUse,
+	}
+	if key, err := util.ParsePemEncodedKey(currentKey); err == nil {
+		if _, ok := key.(*ecdsa.PrivateKey); ok {
+			options.ECSigAlg = util.EcdsaSigAlg
+		}
+	}
+	pemCert, pemKey, err := util.GenCertKeyFromOptions(options)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to generate the new root cert and key (%v)", err)
+	}
+	return pemCert, pemKey, nil
+}
+
+// loadRootRotation loads the signing key and roots of the CA secret in the KeyCertBundle, and the
+// roots in the istio-security ConfigMap, if they changed.
+func (rotator *SelfSignedCARootCertRotator) loadRootRotation(caSecret *v1.Secret) error {
+	rootCerts, err := rootCertsFromSecret(caSecret, rotator.config.rootCertFile)
+	if err != nil {
+		return fmt.Errorf("failed to append root certificates (%v)", err)
+	}
+	bundle := rotator.ca.GetCAKeyCertBundle()
+	cert, _, _, roots := bundle.GetAllPem()
+	if bytes.Equal(cert, caSecret.Data[caCertID]) && bytes.Equal(roots, rootCerts) {
+		return nil
+	}
+	if err := bundle.VerifyAndSetAll(caSecret.Data[caCertID], caSecret.Data[caPrivateKeyID], nil, rootCerts); err != nil {
+		return fmt.Errorf("failed to update CA KeyCertBundle (%v)", err)
+	}
+	rootCertRotatorLog.Infof("Root certificates are updated in CA KeyCertBundle: %v", string(rootCerts))
+	certEncoded := base64.StdEncoding.EncodeToString(rootCerts)
+	if err := rotator.configMapController.InsertCATLSRootCertWithRetry(
+		certEncoded, rotator.config.retryInterval, 30*time.Second); err != nil {
+		return fmt.Errorf("failed to write root certificates into configmap (%v)", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// rootFingerprints returns the fingerprints of the roots of the KeyCertBundle of the CA.
+func rootFingerprints(ca *IstioCA) []string {
+	var fps []string
+	for rest := ca.GetCAKeyCertBundle().GetRootCertPem(); ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			return fps
+		}
+		fp := sha256.Sum256(block.Bytes)
+		fps = append(fps, hex.EncodeToString(fp[:]))
+	}
+}
+
+func TestRootRotation(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	caopts, err := NewSelfSignedIstioCAOptions(context.Background(),
+		0, time.Hour, time.Hour, 30*time.Minute, time.Hour, "test.ca.Org", false, "default", -1,
+		client.CoreV1(), "", false, "")
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
+	}
+	ca, err := NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Got error while creating self-signed CA: %v", err)
+	}
+
+	status, err := ca.RootRotationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldRoot := status.SigningRoot
+	if status.Phase != RootRotationIdle || !reflect.DeepEqual(rootFingerprints(ca), []string{oldRoot}) {
+		t.Fatalf("unexpected initial status %+v with roots %v", status, rootFingerprints(ca))
+	}
+	if err := ca.SetRootRotationPhase(RootRotationSwitched); err == nil {
+		t.Error("switching without a new root should fail")
+	}
+
+	// The new root is trusted, the certificates are still signed by the old root.
+	if err := ca.SetRootRotationPhase(RootRotationDistributing); err != nil {
+		t.Fatal(err)
+	}
+	if status, err = ca.RootRotationStatus(); err != nil {
+		t.Fatal(err)
+	}
+	newRoot := status.PendingRoot
+	if status.Phase != RootRotationDistributing || status.SigningRoot != oldRoot || newRoot == "" || newRoot == oldRoot {
+		t.Fatalf("unexpected distributing status %+v", status)
+	}
+	if got, want := rootFingerprints(ca), []string{oldRoot, newRoot}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got roots %v, want %v", got, want)
+	}
+	// The phase is idempotent, for the replicas of the CA.
+	if err := ca.SetRootRotationPhase(RootRotationDistributing); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ = ca.RootRotationStatus(); status.PendingRoot != newRoot {
+		t.Errorf("got pending root %s, want %s", status.PendingRoot, newRoot)
+	}
+
+	// The certificates are signed by the new root, the old root is still trusted.
+	if err := ca.SetRootRotationPhase(RootRotationSwitched); err != nil {
+		t.Fatal(err)
+	}
+	if status, err = ca.RootRotationStatus(); err != nil {
+		t.Fatal(err)
+	}
+	want := RootRotationStatus{Phase: RootRotationSwitched, SigningRoot: newRoot, PreviousRoot: oldRoot}
+	if status != want {
+		t.Errorf("got status %+v, want %+v", status, want)
+	}
+	if got, want := rootFingerprints(ca), []string{newRoot, oldRoot}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got roots %v, want %v", got, want)
+	}
+	signingCert, _, _, _ := ca.GetCAKeyCertBundle().GetAll()
+	if CertFingerprint(signingCert) != newRoot {
+		t.Errorf("the CA should sign with the new root")
+	}
+	if err := ca.SetRootRotationPhase(RootRotationDistributing); err == nil {
+		t.Error("starting a rotation before completing the previous one should fail")
+	}
+
+	if err := ca.SetRootRotationPhase(RootRotationIdle); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rootFingerprints(ca), []string{newRoot}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got roots %v, want %v", got, want)
+	}
+}
+
+func TestAbortRootRotation(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	caopts, err := NewSelfSignedIstioCAOptions(context.Background(),
+		0, time.Hour, time.Hour, 30*time.Minute, time.Hour, "test.ca.Org", false, "default", -1,
+		client.CoreV1(), "", false, "")
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
+	}
+	ca, err := NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Got error while creating self-signed CA: %v", err)
+	}
+	before := rootFingerprints(ca)
+	if err := ca.SetRootRotationPhase(RootRotationDistributing); err != nil {
+		t.Fatal(err)
+	}
+	if err := ca.SetRootRotationPhase(RootRotationIdle); err != nil {
+		t.Fatal(err)
+	}
+	if got := rootFingerprints(ca); !reflect.DeepEqual(got, before) {
+		t.Errorf("got roots %v after abort, want %v", got, before)
+	}
+
+	// A restarted CA loads the roots of the rotation in progress.
+	if err := ca.SetRootRotationPhase(RootRotationDistributing); err != nil {
+		t.Fatal(err)
+	}
+	caopts, err = NewSelfSignedIstioCAOptions(context.Background(),
+		0, time.Hour, time.Hour, 30*time.Minute, time.Hour, "test.ca.Org", false, "default", -1,
+		client.CoreV1(), "", false, "")
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
+	}
+	if got := caopts.KeyCertBundle.GetRootCertPem(); string(got) != string(ca.GetCAKeyCertBundle().GetRootCertPem()) {
+		t.Errorf("got roots %s after restart, want %s", got, ca.GetCAKeyCertBundle().GetRootCertPem())
+	}
+}
+
+func TestParseRootRotationPhase(t *testing.T) {
+	for phase, want := range map[string]RootRotationPhase{
+		"":              RootRotationIdle,
+		"idle":          RootRotationIdle,
+		" Distributing": RootRotationDistributing,
+		"switched":      RootRotationSwitched,
+	} {
+		if got, err := ParseRootRotationPhase(phase); err != nil || got != want {
+			t.Errorf("ParseRootRotationPhase(%q) = %v, %v, want %v", phase, got, err, want)
+		}
+	}
+	if _, err := ParseRootRotationPhase("done"); err == nil {
+		t.Error("expected an unknown phase error")
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -55,6 +56,8 @@ type SelfSignedCARootCertRotator struct {
 	config              *SelfSignedCARootCertRotatorConfig
 	backOffTime         time.Duration
 	ca                  *IstioCA
+	// mutex serializes the root cert checks and the phases of the root rotations.
+	mutex sync.Mutex
 }
 
 // NewSelfSignedCARootCertRotator returns a new root cert rotator instance that
@@ -108,6 +111,8 @@ func (rotator *SelfSignedCARootCertRotator) Run(rootCertRotatorChan chan struct{
 // checkAndRotateRootCert decides whether root cert should be refreshed, and rotates
 // root cert for self-signed Citadel.
 func (rotator *SelfSignedCARootCertRotator) checkAndRotateRootCert() {
+	rotator.mutex.Lock()
+	defer rotator.mutex.Unlock()
 	caSecret, scrtErr := rotator.caSecretController.LoadCASecretWithRetry(CASecret,
 		rotator.config.caStorageNamespace, rotator.config.retryInterval, 30*time.Second)
 
@@ -129,22 +134,31 @@ func (rotator *SelfSignedCARootCertRotator) checkAndRotateRootCertForSigningCert
 			CASecret)
 		return
 	}
+	if status, err := rootRotationStatus(caSecret); err == nil && status.Phase != RootRotationIdle {
+		// The root is rotated in phases, only load the roots of the phase, which may be updated by other Citadels.
+		rootCertRotatorLog.Infof("Root rotation in the %s phase, skipping root cert rotation.", status.Phase)
+		if err := rotator.loadRootRotation(caSecret); err != nil {
+			rootCertRotatorLog.Errorf("Failed to load the roots of the root rotation: %v", err)
+		}
+		return
+	}
 	// Check root certificate expiration time in CA secret
 	waitTime, err := rotator.config.certInspector.GetWaitTime(caSecret.Data[caCertID], time.Now(), time.Duration(0))
 	if err == nil && waitTime > 0 {
 		rootCertRotatorLog.Info("Root cert is not about to expire, skipping root cert rotation.")
-		caCertInMem, _, _, _ := rotator.ca.GetCAKeyCertBundle().GetAllPem()
+		caCertInMem, _, _, rootCertsInMem := rotator.ca.GetCAKeyCertBundle().GetAllPem()
+		rootCerts, err := rootCertsFromSecret(caSecret, rotator.config.rootCertFile)
+		if err != nil {
+			rootCertRotatorLog.Errorf("failed to append root certificates from file: %s", err.Error())
+			return
+		}
 		// If CA certificate is different from the CA certificate in local key
 		// cert bundle, it implies that other Citadels have updated istio-ca-secret.
+		// The roots differ when other Citadels completed or aborted a root rotation.
 		// Reload root certificate into key cert bundle.
-		if !bytes.Equal(caCertInMem, caSecret.Data[caCertID]) {
+		if !bytes.Equal(caCertInMem, caSecret.Data[caCertID]) || !bytes.Equal(rootCertsInMem, rootCerts) {
 			rootCertRotatorLog.Warn("CA cert in KeyCertBundle does not match CA cert in " +
 				"istio-ca-secret. Start to reload root cert into KeyCertBundle")
-			rootCerts, err := util.AppendRootCerts(caSecret.Data[caCertID], rotator.config.rootCertFile)
-			if err != nil {
-				rootCertRotatorLog.Errorf("failed to append root certificates from file: %s", err.Error())
-				return
-			}
 			if err := rotator.ca.GetCAKeyCertBundle().VerifyAndSetAll(caSecret.Data[caCertID],
 				caSecret.Data[caPrivateKeyID], nil, rootCerts); err != nil {
 				rootCertRotatorLog.Errorf("failed to reload root cert into KeyCertBundle (%v)", err)
@@ -0,0 +1,155 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const (
+	// FileSigningKeyScheme is the scheme of the signing keys in PEM files, e.g. file:///etc/cacerts/ca-key.pem.
+	FileSigningKeyScheme = "file"
+	// K8sSigningKeyScheme is the scheme of the signing keys in Kubernetes secrets, with the namespace,
+	// the secret name and the data key, e.g. k8s://istio-system/cacerts/ca-key.pem.
+	K8sSigningKeyScheme = "k8s"
+)
+
+// SigningKeyProvider provides the signer of the CA signing key. The private key may never leave its
+// storage, e.g. a KMS or an HSM, as long as the signer can sign the certificates with it.
+type SigningKeyProvider interface {
+	// Signer returns the signer of the signing key.
+	Signer() (crypto.Signer, error)
+}
+
+// SigningKeyProviderFactory creates the SigningKeyProvider of a signing key URI.
+type SigningKeyProviderFactory func(uri *url.URL, client corev1.CoreV1Interface) (SigningKeyProvider, error)
+
+var (
+	signingKeyProvidersMutex sync.RWMutex
+	signingKeyProviders      = map[string]SigningKeyProviderFactory{
+		FileSigningKeyScheme: newFileSigningKeyProvider,
+		K8sSigningKeyScheme:  newK8sSigningKeyProvider,
+	}
+)
+
+// RegisterSigningKeyProvider registers the factory of the signing keys with the URI scheme, e.g. pkcs11
+// for the keys in an HSM. It is the extension point of the key storage backends not built in Citadel.
+func RegisterSigningKeyProvider(scheme string, factory SigningKeyProviderFactory) {
+	signingKeyProvidersMutex.Lock()
+	defer signingKeyProvidersMutex.Unlock()
+	signingKeyProviders[strings.ToLower(scheme)] = factory
+}
+
+// IsSigningKeyURI returns whether the signing key is a URI of a registered scheme, rather than the path
+// of a PEM file.
+func IsSigningKeyURI(key string) bool {
+	_, ok := signingKeyProviderFactory(key)
+	return ok
+}
+
+// NewSigningKeyProvider returns the SigningKeyProvider of the signing key URI. The client is only used by
+// the providers reading the key from Kubernetes.
+func NewSigningKeyProvider(key string, client corev1.CoreV1Interface) (SigningKeyProvider, error) {
+	factory, ok := signingKeyProviderFactory(key)
+	if !ok {
+		return nil, fmt.Errorf("unsupported signing key URI %q", key)
+	}
+	uri, err := url.Parse(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key URI %q: %v", key, err)
+	}
+	return factory(uri, client)
+}
+
+func signingKeyProviderFactory(key string) (SigningKeyProviderFactory, bool) {
+	i := strings.Index(key, "://")
+	if i <= 0 {
+		return nil, false
+	}
+	signingKeyProvidersMutex.RLock()
+	defer signingKeyProvidersMutex.RUnlock()
+	factory, ok := signingKeyProviders[strings.ToLower(key[:i])]
+	return factory, ok
+}
+
+// pemSigningKeyProvider provides the signer of a PEM encoded private key.
+type pemSigningKeyProvider struct {
+	source string
+	load   func() ([]byte, error)
+}
+
+func (p *pemSigningKeyProvider) Signer() (crypto.Signer, error) {
+	keyBytes, err := p.load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the signing key from %s: %v", p.source, err)
+	}
+	key, err := util.ParsePemEncodedKey(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the signing key from %s: %v", p.source, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("the signing key from %s cannot sign", p.source)
+	}
+	return signer, nil
+}
+
+func newFileSigningKeyProvider(uri *url.URL, _ corev1.CoreV1Interface) (SigningKeyProvider, error) {
+	if uri.Path == "" {
+		return nil, fmt.Errorf("missing the path of the signing key URI %q", uri)
+	}
+	return &pemSigningKeyProvider{
+		source: uri.Path,
+		load: func() ([]byte, error) {
+			return ioutil.ReadFile(uri.Path)
+		},
+	}, nil
+}
+
+func newK8sSigningKeyProvider(uri *url.URL, client corev1.CoreV1Interface) (SigningKeyProvider, error) {
+	namespace := uri.Host
+	parts := strings.Split(strings.TrimPrefix(uri.Path, "/"), "/")
+	if namespace == "" || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid signing key URI %q, expecting k8s://<namespace>/<secret>/<key>", uri)
+	}
+	if client == nil {
+		return nil, fmt.Errorf("no Kubernetes client to read the signing key URI %q", uri)
+	}
+	name, key := parts[0], parts[1]
+	return &pemSigningKeyProvider{
+		source: fmt.Sprintf("secret %s/%s", namespace, name),
+		load: func() ([]byte, error) {
+			secret, err := client.Secrets(namespace).Get(name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			keyBytes, ok := secret.Data[key]
+			if !ok {
+				return nil, fmt.Errorf("missing %s in the secret", key)
+			}
+			return keyBytes, nil
+		},
+	}, nil
+}
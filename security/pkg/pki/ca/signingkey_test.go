@@ -0,0 +1,193 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// countingSigner counts the signatures of the signer, as an HSM would sign them without exposing the key.
+type countingSigner struct {
+	crypto.Signer
+	signatures int32
+}
+
+func (s *countingSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	atomic.AddInt32(&s.signatures, 1)
+	return s.Signer.Sign(rand, digest, opts)
+}
+
+type fakeSigningKeyProvider struct {
+	signer crypto.Signer
+}
+
+func (p *fakeSigningKeyProvider) Signer() (crypto.Signer, error) {
+	return p.signer, nil
+}
+
+func TestCreatePluggedCertCAWithSigningKeyURI(t *testing.T) {
+	rootCertFile := "../testdata/multilevelpki/root-cert.pem"
+	certChainFile := "../testdata/multilevelpki/int-cert-chain.pem"
+	signingCertFile := "../testdata/multilevelpki/int-cert.pem"
+	signingKeyFile := "../testdata/multilevelpki/int-key.pem"
+	caNamespace := "default"
+
+	keyBytes, err := ioutil.ReadFile(signingKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	absKeyFile, err := filepath.Abs(signingKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := util.ParsePemEncodedKey(keyBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hsmSigner := &countingSigner{Signer: key.(crypto.Signer)}
+	RegisterSigningKeyProvider("fakehsm", func(uri *url.URL, _ corev1.CoreV1Interface) (SigningKeyProvider, error) {
+		return &fakeSigningKeyProvider{signer: hsmSigner}, nil
+	})
+
+	testCases := map[string]struct {
+		signingKeyURI string
+		expectedErr   bool
+	}{
+		"file": {
+			signingKeyURI: "file://" + absKeyFile,
+		},
+		"k8s secret": {
+			signingKeyURI: "k8s://istio-system/cacerts/ca-key.pem",
+		},
+		"registered provider": {
+			signingKeyURI: "fakehsm://slot/1",
+		},
+		"missing file": {
+			signingKeyURI: "file:///missing/ca-key.pem",
+			expectedErr:   true,
+		},
+		"missing secret": {
+			signingKeyURI: "k8s://istio-system/missing/ca-key.pem",
+			expectedErr:   true,
+		},
+		"missing secret key": {
+			signingKeyURI: "k8s://istio-system/cacerts/missing.pem",
+			expectedErr:   true,
+		},
+		"invalid k8s URI": {
+			signingKeyURI: "k8s://istio-system/cacerts",
+			expectedErr:   true,
+		},
+		"mismatched key": {
+			signingKeyURI: "k8s://istio-system/other/ca-key.pem",
+			expectedErr:   true,
+		},
+	}
+
+	otherKeyBytes, err := ioutil.ReadFile("../testdata/multilevelpki/int2-key.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "cacerts", Namespace: "istio-system"},
+			Data:       map[string][]byte{"ca-key.pem": keyBytes},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "istio-system"},
+			Data:       map[string][]byte{"ca-key.pem": otherKeyBytes},
+		})
+
+	for id, tc := range testCases {
+		if !IsSigningKeyURI(tc.signingKeyURI) {
+			t.Errorf("%s: expected %q to be a signing key URI", id, tc.signingKeyURI)
+		}
+		caopts, err := NewPluggedCertIstioCAOptions(certChainFile, signingCertFile, tc.signingKeyURI, rootCertFile,
+			30*time.Minute, time.Hour, caNamespace, client.CoreV1())
+		if tc.expectedErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: failed to create a plugged-cert CA Options: %v", id, err)
+			continue
+		}
+		ca, err := NewIstioCA(caopts)
+		if err != nil {
+			t.Errorf("%s: failed to create a plugged-cert CA: %v", id, err)
+			continue
+		}
+		if _, privKeyBytes, _, _ := ca.GetCAKeyCertBundle().GetAllPem(); len(privKeyBytes) != 0 {
+			t.Errorf("%s: the signing key should not be in the key cert bundle", id)
+		}
+
+		csrPEM, privPEM, err := util.GenCSR(util.CertOptions{
+			Host:       "spiffe://example.com/ns/foo/sa/bar",
+			RSAKeySize: 2048,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		certPEM, err := ca.SignWithCertChain(csrPEM, []string{"spiffe://example.com/ns/foo/sa/bar"}, time.Hour, false)
+		if err != nil {
+			t.Errorf("%s: failed to sign the CSR: %v", id, err)
+			continue
+		}
+		cert, err := tls.X509KeyPair(certPEM, privPEM)
+		if err != nil {
+			t.Errorf("%s: %v", id, err)
+			continue
+		}
+		if len(cert.Certificate) != 3 {
+			t.Errorf("%s: unexpected number of certificates returned: %d (expected 3)", id, len(cert.Certificate))
+		}
+	}
+
+	if atomic.LoadInt32(&hsmSigner.signatures) == 0 {
+		t.Error("the registered provider should sign the certificates")
+	}
+}
+
+func TestIsSigningKeyURI(t *testing.T) {
+	testCases := map[string]bool{
+		"/etc/cacerts/ca-key.pem":               false,
+		"./etc/cacerts/ca-key.pem":              false,
+		"file:///etc/cacerts/ca-key.pem":        true,
+		"k8s://istio-system/cacerts/ca-key.pem": true,
+		"unknown://slot/1":                      false,
+	}
+	for key, expected := range testCases {
+		if got := IsSigningKeyURI(key); got != expected {
+			t.Errorf("IsSigningKeyURI(%q) => %v, want %v", key, got, expected)
+		}
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -36,6 +37,26 @@ import (
 	"istio.io/pkg/log"
 )
 
+// SupportedECSignatureAlgorithms are the types of EC signature algorithms used for the generated keys.
+type SupportedECSignatureAlgorithms string
+
+const (
+	// EcdsaSigAlg is the ECDSA signature algorithm, with P-256 keys.
+	EcdsaSigAlg SupportedECSignatureAlgorithms = "ECDSA"
+)
+
+// ParseECSigAlg parses the name of an EC signature algorithm, empty for RSA keys.
+func ParseECSigAlg(alg string) (SupportedECSignatureAlgorithms, error) {
+	switch strings.ToUpper(alg) {
+	case "":
+		return "", nil
+	case string(EcdsaSigAlg):
+		return EcdsaSigAlg, nil
+	default:
+		return "", fmt.Errorf("unsupported EC signature algorithm %q, only %s is supported", alg, EcdsaSigAlg)
+	}
+}
+
 // CertOptions contains options for generating a new certificate.
 type CertOptions struct {
 	// Comma-separated hostnames and IPs to generate a certificate for.
@@ -64,6 +85,10 @@ type CertOptions struct {
 	// The size of RSA private key to be generated.
 	RSAKeySize int
 
+	// The EC signature algorithm of the private key to be generated. An RSA key of RSAKeySize is
+	// generated if empty.
+	ECSigAlg SupportedECSignatureAlgorithms
+
 	// Whether this certificate is used as signing cert for CA.
 	IsCA bool
 
@@ -85,14 +110,14 @@ type CertOptions struct {
 
 // GenCertKeyFromOptions generates a X.509 certificate and a private key with the given options.
 func GenCertKeyFromOptions(options CertOptions) (pemCert []byte, pemKey []byte, err error) {
-	// Generate a RSA private&public key pair.
+	// Generate a RSA or EC private&public key pair.
 	// The public key will be bound to the certificate generated below. The
 	// private key will be used to sign this certificate in the self-signed
 	// case, otherwise the certificate is signed by the signer private key
 	// as specified in the CertOptions.
-	priv, err := rsa.GenerateKey(rand.Reader, options.RSAKeySize)
+	priv, err := genKey(options)
 	if err != nil {
-		return nil, nil, fmt.Errorf("cert generation fails at RSA key generation (%v)", err)
+		return nil, nil, fmt.Errorf("cert generation fails at key generation (%v)", err)
 	}
 	template, err := genCertTemplateFromOptions(options)
 	if err != nil {
@@ -102,7 +127,7 @@ func GenCertKeyFromOptions(options CertOptions) (pemCert []byte, pemKey []byte,
 	if !options.IsSelfSigned {
 		signerCert, signerKey = options.SignerCert, options.SignerPriv
 	}
-	certBytes, err := x509.CreateCertificate(rand.Reader, template, signerCert, &priv.PublicKey, signerKey)
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, signerCert, publicKey(priv), signerKey)
 	if err != nil {
 		return nil, nil, fmt.Errorf("cert generation fails at X509 cert creation (%v)", err)
 	}
@@ -114,6 +139,19 @@ func GenCertKeyFromOptions(options CertOptions) (pemCert []byte, pemKey []byte,
 	return
 }
 
+// genKey generates the private key of the options: an ECDSA P-256 key if ECSigAlg is set, an RSA key
+// otherwise.
+func genKey(options CertOptions) (crypto.PrivateKey, error) {
+	switch options.ECSigAlg {
+	case "":
+		return rsa.GenerateKey(rand.Reader, options.RSAKeySize)
+	case EcdsaSigAlg:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported EC signature algorithm %q", options.ECSigAlg)
+	}
+}
+
 func publicKey(priv interface{}) interface{} {
 	switch k := priv.(type) {
 	case *rsa.PrivateKey:
@@ -161,9 +199,51 @@ func GenCertFromCSR(csr *x509.CertificateRequest, signingCert *x509.Certificate,
 	if err != nil {
 		return nil, err
 	}
+	tmpl.SignatureAlgorithm = signatureAlgorithm(signingKey, csr.SignatureAlgorithm)
 	return x509.CreateCertificate(rand.Reader, tmpl, signingCert, publicKey, signingKey)
 }
 
+// GenSubordinateCACertFromCSR generates a subordinate CA certificate with the given CSR, allowing at
+// most maxPathLen intermediate CAs below it. The certificate doesn't outlive the signing cert.
+func GenSubordinateCACertFromCSR(csr *x509.CertificateRequest, signingCert *x509.Certificate, publicKey interface{},
+	signingKey crypto.PrivateKey, subjectIDs []string, ttl time.Duration, maxPathLen int) (cert []byte, err error) {
+	tmpl, err := genCertTemplateFromCSR(csr, subjectIDs, ttl, true)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.SignatureAlgorithm = signatureAlgorithm(signingKey, csr.SignatureAlgorithm)
+	tmpl.MaxPathLen = maxPathLen
+	tmpl.MaxPathLenZero = maxPathLen == 0
+	if tmpl.NotAfter.After(signingCert.NotAfter) {
+		tmpl.NotAfter = signingCert.NotAfter
+	}
+	return x509.CreateCertificate(rand.Reader, tmpl, signingCert, publicKey, signingKey)
+}
+
+// signatureAlgorithm returns the signature algorithm of the CSR if the signing key signs with it, or
+// the default algorithm of the signing key otherwise, for example when an RSA CA signs the CSR of an
+// ECDSA key.
+func signatureAlgorithm(signingKey crypto.PrivateKey, csrAlg x509.SignatureAlgorithm) x509.SignatureAlgorithm {
+	signer, ok := signingKey.(crypto.Signer)
+	if !ok {
+		return csrAlg
+	}
+	switch signer.Public().(type) {
+	case *rsa.PublicKey:
+		switch csrAlg {
+		case x509.SHA256WithRSA, x509.SHA384WithRSA, x509.SHA512WithRSA,
+			x509.SHA256WithRSAPSS, x509.SHA384WithRSAPSS, x509.SHA512WithRSAPSS:
+			return csrAlg
+		}
+	case *ecdsa.PublicKey:
+		switch csrAlg {
+		case x509.ECDSAWithSHA256, x509.ECDSAWithSHA384, x509.ECDSAWithSHA512:
+			return csrAlg
+		}
+	}
+	return x509.UnknownSignatureAlgorithm
+}
+
 // LoadSignerCredsFromFiles loads the signer cert&key from the given files.
 //   signerCertFile: cert file name
 //   signerPrivFile: private key file name
@@ -199,8 +279,8 @@ func genCertTemplateFromCSR(csr *x509.CertificateRequest, subjectIDs []string, t
 	var keyUsage x509.KeyUsage
 	extKeyUsages := []x509.ExtKeyUsage{}
 	if isCA {
-		// If the cert is a CA cert, the private key is allowed to sign other certificates.
-		keyUsage = x509.KeyUsageCertSign
+		// If the cert is a CA cert, the private key is allowed to sign other certificates and CRLs.
+		keyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
 	} else {
 		// Otherwise the private key is allowed for digital signature and key encipherment.
 		keyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
@@ -251,8 +331,8 @@ func genCertTemplateFromCSR(csr *x509.CertificateRequest, subjectIDs []string, t
 func genCertTemplateFromOptions(options CertOptions) (*x509.Certificate, error) {
 	var keyUsage x509.KeyUsage
 	if options.IsCA {
-		// If the cert is a CA cert, the private key is allowed to sign other certificates.
-		keyUsage = x509.KeyUsageCertSign
+		// If the cert is a CA cert, the private key is allowed to sign other certificates and CRLs.
+		keyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
 	} else {
 		// Otherwise the private key is allowed for digital signature and key encipherment.
 		keyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
@@ -319,7 +399,7 @@ func genSerialNum() (*big.Int, error) {
 	return serialNum, nil
 }
 
-func encodePem(isCSR bool, csrOrCert []byte, priv *rsa.PrivateKey, pkcs8 bool) (
+func encodePem(isCSR bool, csrOrCert []byte, priv crypto.PrivateKey, pkcs8 bool) (
 	csrOrCertPem []byte, privPem []byte, err error) {
 	encodeMsg := "CERTIFICATE"
 	if isCSR {
@@ -334,8 +414,18 @@ func encodePem(isCSR bool, csrOrCert []byte, priv *rsa.PrivateKey, pkcs8 bool) (
 		}
 		privPem = pem.EncodeToMemory(&pem.Block{Type: blockTypePKCS8PrivateKey, Bytes: encodedKey})
 	} else {
-		encodedKey = x509.MarshalPKCS1PrivateKey(priv)
-		privPem = pem.EncodeToMemory(&pem.Block{Type: blockTypeRSAPrivateKey, Bytes: encodedKey})
+		switch k := priv.(type) {
+		case *rsa.PrivateKey:
+			encodedKey = x509.MarshalPKCS1PrivateKey(k)
+			privPem = pem.EncodeToMemory(&pem.Block{Type: blockTypeRSAPrivateKey, Bytes: encodedKey})
+		case *ecdsa.PrivateKey:
+			if encodedKey, err = x509.MarshalECPrivateKey(k); err != nil {
+				return nil, nil, err
+			}
+			privPem = pem.EncodeToMemory(&pem.Block{Type: blockTypeECPrivateKey, Bytes: encodedKey})
+		default:
+			return nil, nil, fmt.Errorf("unsupported private key type %T", priv)
+		}
 	}
 	err = nil
 	return
@@ -77,7 +77,7 @@ func TestGenCertKeyFromOptions(t *testing.T) {
 		NotBefore:   caCertNotBefore,
 		TTL:         caCertTTL,
 		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		KeyUsage:    x509.KeyUsageCertSign,
+		KeyUsage:    x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 		IsCA:        true,
 		Org:         "MyOrg",
 		Host:        caCertOptions.Host,
@@ -440,6 +440,131 @@ func TestGenCertFromCSR(t *testing.T) {
 	}
 }
 
+func TestGenCertFromECDSACSR(t *testing.T) {
+	keycert, err := NewVerifiedKeyCertBundleFromFile("../testdata/cert.pem", "../testdata/key.pem", "", "../testdata/cert.pem")
+	if err != nil {
+		t.Fatalf("Failed to load CA key and cert from files: %v", err)
+	}
+	signingCert, signingKey, _, _ := keycert.GetAll()
+
+	// The RSA CA signs the CSR of an ECDSA key, signed with an ECDSA signature algorithm.
+	csrPEM, keyPEM, err := GenCSR(CertOptions{Host: "spiffe://test.com/abc/def", ECSigAlg: EcdsaSigAlg})
+	if err != nil {
+		t.Fatalf("failed to generate the CSR: %v", err)
+	}
+	if _, err := ParsePemEncodedKey(keyPEM); err != nil {
+		t.Fatalf("failed to parse the ECDSA key: %v", err)
+	}
+	csr, err := ParsePemEncodedCSR(csrPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if csr.SignatureAlgorithm != x509.ECDSAWithSHA256 {
+		t.Fatalf("got CSR signature algorithm %v, expected %v", csr.SignatureAlgorithm, x509.ECDSAWithSHA256)
+	}
+	derBytes, err := GenCertFromCSR(csr, signingCert, csr.PublicKey, *signingKey, []string{"spiffe://test.com/abc/def"},
+		time.Hour, false)
+	if err != nil {
+		t.Fatalf("failed to GenCertFromCSR, error %v", err)
+	}
+	out, err := x509.ParseCertificate(derBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.PublicKeyAlgorithm != x509.ECDSA || out.SignatureAlgorithm != x509.SHA256WithRSA {
+		t.Errorf("got public key algorithm %v and signature algorithm %v, expected ECDSA and SHA256-RSA",
+			out.PublicKeyAlgorithm, out.SignatureAlgorithm)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(signingCert)
+	if _, err := out.Verify(x509.VerifyOptions{Roots: pool}); err != nil {
+		t.Errorf("verification of the signed certificate failed %v", err)
+	}
+}
+
+func TestGenECDSACertKeyFromOptions(t *testing.T) {
+	for _, pkcs8 := range []bool{false, true} {
+		certPEM, keyPEM, err := GenCertKeyFromOptions(CertOptions{
+			Host:         "test_ca.com",
+			TTL:          time.Hour,
+			Org:          "MyOrg",
+			IsCA:         true,
+			IsSelfSigned: true,
+			ECSigAlg:     EcdsaSigAlg,
+			PKCS8Key:     pkcs8,
+		})
+		if err != nil {
+			t.Fatalf("failed to generate the ECDSA cert: %v", err)
+		}
+		if _, err := NewVerifiedKeyCertBundleFromPem(certPEM, keyPEM, nil, certPEM); err != nil {
+			t.Errorf("PKCS#8 %v: invalid ECDSA key and cert: %v", pkcs8, err)
+		}
+		cert, err := ParsePemEncodedCertificate(certPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cert.SignatureAlgorithm != x509.ECDSAWithSHA256 {
+			t.Errorf("got signature algorithm %v, expected %v", cert.SignatureAlgorithm, x509.ECDSAWithSHA256)
+		}
+	}
+}
+
+func TestParseECSigAlg(t *testing.T) {
+	for alg, expected := range map[string]SupportedECSignatureAlgorithms{"": "", "ECDSA": EcdsaSigAlg, "ecdsa": EcdsaSigAlg} {
+		if got, err := ParseECSigAlg(alg); err != nil || got != expected {
+			t.Errorf("%q: got %q (%v), expected %q", alg, got, err, expected)
+		}
+	}
+	if _, err := ParseECSigAlg("ED25519"); err == nil {
+		t.Error("expected an unsupported algorithm error")
+	}
+}
+
+func TestGenSubordinateCACertFromCSR(t *testing.T) {
+	keycert, err := NewVerifiedKeyCertBundleFromFile("../testdata/cert.pem", "../testdata/key.pem", "", "../testdata/cert.pem")
+	if err != nil {
+		t.Fatalf("Failed to load CA key and cert from files: %v", err)
+	}
+	signingCert, signingKey, _, _ := keycert.GetAll()
+
+	signeeKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to generate signee key pair %v", err)
+	}
+	derBytes, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{SignatureAlgorithm: x509.SHA256WithRSA}, signeeKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate request %v", err)
+	}
+	csr, err := x509.ParseCertificateRequest(derBytes)
+	if err != nil {
+		t.Fatalf("failed to parse certificate request %v", err)
+	}
+
+	for _, maxPathLen := range []int{0, 1} {
+		derBytes, err = GenSubordinateCACertFromCSR(csr, signingCert, &signeeKey.PublicKey, *signingKey,
+			[]string{"spiffe://cluster.local/ns/istio-system/sa/istiod"}, 100*365*24*time.Hour, maxPathLen)
+		if err != nil {
+			t.Fatalf("failed to GenSubordinateCACertFromCSR, error %v", err)
+		}
+		out, err := x509.ParseCertificate(derBytes)
+		if err != nil {
+			t.Fatalf("failed to parse generated certificate %v", err)
+		}
+		if !out.IsCA || out.KeyUsage&x509.KeyUsageCertSign == 0 {
+			t.Errorf("the certificate should be a CA certificate")
+		}
+		if out.KeyUsage&x509.KeyUsageCRLSign == 0 {
+			t.Errorf("the CA certificate should be allowed to sign CRLs")
+		}
+		if out.MaxPathLen != maxPathLen || out.MaxPathLenZero != (maxPathLen == 0) {
+			t.Errorf("got path length %d (zero %v), expected %d", out.MaxPathLen, out.MaxPathLenZero, maxPathLen)
+		}
+		if out.NotAfter.After(signingCert.NotAfter) {
+			t.Errorf("the certificate expires at %v, after the signing cert at %v", out.NotAfter, signingCert.NotAfter)
+		}
+	}
+}
+
 func TestLoadSignerCredsFromFiles(t *testing.T) {
 	testCases := map[string]struct {
 		certFile    string
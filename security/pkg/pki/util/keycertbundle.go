@@ -19,6 +19,7 @@
 package util
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/tls"
 	"crypto/x509"
@@ -101,6 +102,40 @@ func NewVerifiedKeyCertBundleFromFile(certFile, privKeyFile, certChainFile, root
 	return NewVerifiedKeyCertBundleFromPem(certBytes, privKeyBytes, certChainBytes, rootCertBytes)
 }
 
+// NewVerifiedKeyCertBundleWithSigner returns a new KeyCertBundle signing with the signer, or error if the
+// provided certs failed the verification. The private key of the signer may not be available, for
+// example in a KMS or an HSM, so the PEM of the private key of the bundle is empty.
+func NewVerifiedKeyCertBundleWithSigner(certBytes []byte, signer crypto.Signer, certChainBytes,
+	rootCertBytes []byte) (*KeyCertBundleImpl, error) {
+	cert, err := verifyCertChain(certBytes, certChainBytes, rootCertBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the cert and signer match.
+	certPub, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the public key of the cert: %v", err)
+	}
+	signerPub, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the public key of the signer: %v", err)
+	}
+	if !bytes.Equal(certPub, signerPub) {
+		return nil, fmt.Errorf("the cert does not match the signer")
+	}
+
+	privKey := crypto.PrivateKey(signer)
+	return &KeyCertBundleImpl{
+		certBytes:      copyBytes(certBytes),
+		cert:           cert,
+		privKeyBytes:   []byte{},
+		privKey:        &privKey,
+		certChainBytes: copyBytes(certChainBytes),
+		rootCertBytes:  copyBytes(rootCertBytes),
+	}, nil
+}
+
 // NewKeyCertBundleWithRootCertFromFile returns a new KeyCertBundle with the root cert without verification.
 func NewKeyCertBundleWithRootCertFromFile(rootCertFile string) (*KeyCertBundleImpl, error) {
 	rootCertBytes, err := ioutil.ReadFile(rootCertFile)
@@ -202,7 +237,26 @@ func (b *KeyCertBundleImpl) CertOptions() (*CertOptions, error) {
 
 // Verify that the cert chain, root cert and key/cert match.
 func Verify(certBytes, privKeyBytes, certChainBytes, rootCertBytes []byte) error {
-	// Verify the cert can be verified from the root cert through the cert chain.
+	if _, err := verifyCertChain(certBytes, certChainBytes, rootCertBytes); err != nil {
+		return err
+	}
+
+	// Verify that the key can be correctly parsed.
+	if _, err := ParsePemEncodedKey(privKeyBytes); err != nil {
+		return fmt.Errorf("failed to parse private key PEM: %v", err)
+	}
+
+	// Verify the cert and key match.
+	if _, err := tls.X509KeyPair(certBytes, privKeyBytes); err != nil {
+		return fmt.Errorf("the cert does not match the key")
+	}
+
+	return nil
+}
+
+// verifyCertChain verifies the cert can be verified from the root cert through the cert chain, and
+// returns the parsed cert.
+func verifyCertChain(certBytes, certChainBytes, rootCertBytes []byte) (*x509.Certificate, error) {
 	rcp := x509.NewCertPool()
 	rcp.AppendCertsFromPEM(rootCertBytes)
 
@@ -215,27 +269,16 @@ func Verify(certBytes, privKeyBytes, certChainBytes, rootCertBytes []byte) error
 	}
 	cert, err := ParsePemEncodedCertificate(certBytes)
 	if err != nil {
-		return fmt.Errorf("failed to parse cert PEM: %v", err)
+		return nil, fmt.Errorf("failed to parse cert PEM: %v", err)
 	}
 	chains, err := cert.Verify(opts)
 
 	if len(chains) == 0 || err != nil {
-		return fmt.Errorf(
+		return nil, fmt.Errorf(
 			"cannot verify the cert with the provided root chain and cert "+
 				"pool with error: %v", err)
 	}
-
-	// Verify that the key can be correctly parsed.
-	if _, err = ParsePemEncodedKey(privKeyBytes); err != nil {
-		return fmt.Errorf("failed to parse private key PEM: %v", err)
-	}
-
-	// Verify the cert and key match.
-	if _, err := tls.X509KeyPair(certBytes, privKeyBytes); err != nil {
-		return fmt.Errorf("the cert does not match the key")
-	}
-
-	return nil
+	return cert, nil
 }
 
 func copyBytes(src []byte) []byte {
@@ -72,7 +72,8 @@ type Identity struct {
 	Value []byte
 }
 
-// BuildSubjectAltNameExtension builds the SAN extension for the certificate.
+// BuildSubjectAltNameExtension builds the SAN extension for the certificate. The hosts are IP addresses,
+// URIs, e.g. SPIFFE identities, or DNS names otherwise.
 func BuildSubjectAltNameExtension(hosts string) (*pkix.Extension, error) {
 	ids := []Identity{}
 	for _, host := range strings.Split(hosts, ",") {
@@ -82,7 +83,7 @@ func BuildSubjectAltNameExtension(hosts string) (*pkix.Extension, error) {
 				ip = eip
 			}
 			ids = append(ids, Identity{Type: TypeIP, Value: ip})
-		} else if strings.HasPrefix(host, spiffe.URIPrefix) {
+		} else if strings.HasPrefix(host, spiffe.URIPrefix) || strings.Contains(host, "://") {
 			ids = append(ids, Identity{Type: TypeURI, Value: []byte(host)})
 		} else {
 			ids = append(ids, Identity{Type: TypeDNS, Value: []byte(host)})
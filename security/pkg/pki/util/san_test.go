@@ -51,6 +51,10 @@ func TestBuildSubjectAltNameExtension(t *testing.T) {
 			hosts:       "test.domain.com",
 			expectedExt: getSANExtension([]Identity{dnsIdentity}, t),
 		},
+		"non SPIFFE URI host": {
+			hosts:       "https://db.example.com/tenant/foo",
+			expectedExt: getSANExtension([]Identity{{Type: TypeURI, Value: []byte("https://db.example.com/tenant/foo")}}, t),
+		},
 		"URI, IP and DNS hosts": {
 			hosts:       "spiffe://test.domain.com/ns/default/sa/default,10.0.0.1,test.domain.com",
 			expectedExt: getSANExtension([]Identity{uriIdentity, ipIdentity, dnsIdentity}, t),
@@ -20,6 +20,8 @@ import (
 
 	"golang.org/x/net/context"
 
+	"istio.io/pkg/env"
+
 	"istio.io/istio/security/pkg/k8s/tokenreview"
 )
 
@@ -29,6 +31,28 @@ const (
 	KubeJWTAuthenticatorType = "KubeJWTAuthenticator"
 )
 
+var (
+	tokenReviewCacheTTL = env.RegisterDurationVar(
+		"TOKEN_REVIEW_CACHE_TTL",
+		0,
+		"The duration for which the k8s JWTs authenticated by the TokenReview API are cached, "+
+			"capped by their expiration. The token reviews are not cached if zero.",
+	).Get()
+
+	tokenReviewCacheNegativeTTL = env.RegisterDurationVar(
+		"TOKEN_REVIEW_CACHE_NEGATIVE_TTL",
+		0,
+		"The duration for which the k8s JWTs rejected by the TokenReview API are cached, "+
+			"when TOKEN_REVIEW_CACHE_TTL is set. The rejected JWTs are not cached if zero.",
+	).Get()
+
+	tokenReviewCacheMaxEntries = env.RegisterIntVar(
+		"TOKEN_REVIEW_CACHE_MAX_ENTRIES",
+		tokenreview.DefaultCacheMaxEntries,
+		"The maximum number of k8s JWTs in the token review cache.",
+	).Get()
+)
+
 type tokenReviewClient interface {
 	ValidateK8sJwt(targetJWT string) ([]string, error)
 }
@@ -50,8 +74,16 @@ func NewKubeJWTAuthenticator(k8sAPIServerURL, caCertPath, jwtPath, trustDomain s
 	if err != nil {
 		return nil, fmt.Errorf("failed to read Citadel JWT: %v", err)
 	}
+	var client tokenReviewClient = tokenreview.NewK8sSvcAcctAuthn(k8sAPIServerURL, caCert, string(reviewerJWT))
+	if tokenReviewCacheTTL > 0 {
+		client = tokenreview.NewCachedK8sSvcAcctAuthn(client, tokenreview.CacheOptions{
+			TTL:         tokenReviewCacheTTL,
+			NegativeTTL: tokenReviewCacheNegativeTTL,
+			MaxEntries:  int32(tokenReviewCacheMaxEntries),
+		})
+	}
 	return &KubeJWTAuthenticator{
-		client:      tokenreview.NewK8sSvcAcctAuthn(k8sAPIServerURL, caCert, string(reviewerJWT)),
+		client:      client,
 		trustDomain: trustDomain,
 	}, nil
 }
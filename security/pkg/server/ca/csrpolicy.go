@@ -0,0 +1,268 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/security/pkg/pki/util"
+	"istio.io/istio/security/pkg/server/ca/authenticate"
+)
+
+const (
+	SANMatchCSRPolicyType    = "SANMatchCSRPolicy"
+	NamespaceCSRPolicyType   = "NamespaceCSRPolicy"
+	TTLLimitCSRPolicyType    = "TTLLimitCSRPolicy"
+	TrustDomainCSRPolicyType = "TrustDomainCSRPolicy"
+)
+
+// CSRRequest is a certificate signing request evaluated by the CSR policies, once the caller is
+// authenticated and before the certificate is signed.
+type CSRRequest struct {
+	// Caller is the authenticated caller of the request.
+	Caller *authenticate.Caller
+	// CSRPEM is the PEM encoded CSR, as sent by the caller.
+	CSRPEM []byte
+	// Identities are the identities of the certificate to be signed.
+	Identities []string
+	// TTL is the requested TTL of the certificate, zero for the default TTL of the CA. Policies may
+	// lower it.
+	TTL time.Duration
+	// ForCA is whether the certificate is for a CA.
+	ForCA bool
+}
+
+// CSRPolicy approves or denies the certificate signing requests. The CSR is denied if any of the
+// policies of the server returns an error.
+type CSRPolicy interface {
+	Evaluate(req *CSRRequest) error
+	PolicyType() string
+}
+
+// sanMatchCSRPolicy denies the CSRs requesting identities other than the caller's ones in their SAN
+// extension, which the CA would silently replace with the caller's identities otherwise.
+type sanMatchCSRPolicy struct{}
+
+// NewSANMatchCSRPolicy returns the policy denying the CSRs whose SAN identities do not match the
+// identities of the caller.
+func NewSANMatchCSRPolicy() CSRPolicy {
+	return &sanMatchCSRPolicy{}
+}
+
+func (p *sanMatchCSRPolicy) PolicyType() string {
+	return SANMatchCSRPolicyType
+}
+
+func (p *sanMatchCSRPolicy) Evaluate(req *CSRRequest) error {
+	if req.Caller.AuthSource == authenticate.AuthSourceIDToken {
+		// The identities of ID tokens are obfuscated, see sameIDAuthorizer.
+		return nil
+	}
+	csr, err := util.ParsePemEncodedCSR(req.CSRPEM)
+	if err != nil {
+		return fmt.Errorf("failed to parse the CSR: %v", err)
+	}
+	if util.ExtractSANExtension(csr.Extensions) == nil {
+		// CSRs without a SAN extension get the identities of the caller.
+		return nil
+	}
+	ids, err := util.ExtractIDs(csr.Extensions)
+	if err != nil {
+		return err
+	}
+	callerIDs := make(map[string]bool, len(req.Caller.Identities))
+	for _, id := range req.Caller.Identities {
+		callerIDs[id] = true
+	}
+	for _, id := range ids {
+		if !callerIDs[id] {
+			return fmt.Errorf("the identity %q of the CSR does not match the caller's identities %v",
+				id, req.Caller.Identities)
+		}
+	}
+	return nil
+}
+
+// namespaceCSRPolicy only approves the CSRs of the identities of its namespaces.
+type namespaceCSRPolicy struct {
+	namespaces map[string]bool
+}
+
+// NewNamespaceCSRPolicy returns the policy only approving the CSRs of the SPIFFE identities of the
+// given namespaces.
+func NewNamespaceCSRPolicy(namespaces []string) CSRPolicy {
+	p := &namespaceCSRPolicy{namespaces: make(map[string]bool, len(namespaces))}
+	for _, ns := range namespaces {
+		p.namespaces[ns] = true
+	}
+	return p
+}
+
+func (p *namespaceCSRPolicy) PolicyType() string {
+	return NamespaceCSRPolicyType
+}
+
+func (p *namespaceCSRPolicy) Evaluate(req *CSRRequest) error {
+	for _, id := range req.Identities {
+		ns, ok := identityNamespace(id)
+		if !ok {
+			return fmt.Errorf("the identity %q is not the identity of a namespace", id)
+		}
+		if !p.namespaces[ns] {
+			return fmt.Errorf("the namespace %q of the identity %q is not allowed", ns, id)
+		}
+	}
+	return nil
+}
+
+// trustDomainCSRPolicy only approves the CSRs of the identities of its trust domains.
+type trustDomainCSRPolicy struct {
+	trustDomains map[string]bool
+}
+
+// NewTrustDomainCSRPolicy returns the policy only approving the CSRs of the SPIFFE identities of the
+// given trust domains, the trust domain of the CA and its aliases, so that the workloads of the other
+// clusters of the mesh get certificates of their trust domain without the CA issuing any other.
+func NewTrustDomainCSRPolicy(trustDomains []string) CSRPolicy {
+	p := &trustDomainCSRPolicy{trustDomains: make(map[string]bool, len(trustDomains))}
+	for _, td := range trustDomains {
+		p.trustDomains[td] = true
+	}
+	return p
+}
+
+func (p *trustDomainCSRPolicy) PolicyType() string {
+	return TrustDomainCSRPolicyType
+}
+
+func (p *trustDomainCSRPolicy) Evaluate(req *CSRRequest) error {
+	for _, id := range req.Identities {
+		td, ok := identityTrustDomain(id)
+		if !ok {
+			return fmt.Errorf("the identity %q is not a SPIFFE identity", id)
+		}
+		if !p.trustDomains[td] {
+			return fmt.Errorf("the trust domain %q of the identity %q is not allowed", td, id)
+		}
+	}
+	return nil
+}
+
+// ttlLimitCSRPolicy lowers the TTL of the certificates of the namespaces with a TTL limit.
+type ttlLimitCSRPolicy struct {
+	limits map[string]time.Duration
+}
+
+// NewTTLLimitCSRPolicy returns the policy limiting the TTL of the certificates of the namespaces. The
+// requested TTL is lowered to the limit rather than denied, so that the workloads still get a
+// certificate.
+func NewTTLLimitCSRPolicy(limits map[string]time.Duration) CSRPolicy {
+	return &ttlLimitCSRPolicy{limits: limits}
+}
+
+func (p *ttlLimitCSRPolicy) PolicyType() string {
+	return TTLLimitCSRPolicyType
+}
+
+func (p *ttlLimitCSRPolicy) Evaluate(req *CSRRequest) error {
+	for _, id := range req.Identities {
+		ns, ok := identityNamespace(id)
+		if !ok {
+			continue
+		}
+		if limit, found := p.limits[ns]; found && (req.TTL == 0 || req.TTL > limit) {
+			req.TTL = limit
+		}
+	}
+	return nil
+}
+
+// auditCSRPolicy logs the CSRs the policy would deny, without denying them.
+type auditCSRPolicy struct {
+	CSRPolicy
+}
+
+// NewAuditCSRPolicy returns the policy logging the CSRs denied by the given policy instead of denying
+// them, to find out the anomalous requests before enforcing the policy.
+func NewAuditCSRPolicy(policy CSRPolicy) CSRPolicy {
+	return &auditCSRPolicy{CSRPolicy: policy}
+}
+
+func (p *auditCSRPolicy) Evaluate(req *CSRRequest) error {
+	// The policy evaluates a copy of the request, so that it doesn't change the request either.
+	audited := *req
+	if err := p.CSRPolicy.Evaluate(&audited); err != nil {
+		serverCaLog.Warnf("CSR of %v would be denied by %s: %v", req.Caller.Identities, p.PolicyType(), err)
+	}
+	return nil
+}
+
+// ParseTTLLimits parses the comma separated namespace=TTL limits, e.g. "foo=1h,bar=30m".
+func ParseTTLLimits(limits string) (map[string]time.Duration, error) {
+	ret := map[string]time.Duration{}
+	for _, limit := range strings.Split(limits, ",") {
+		limit = strings.TrimSpace(limit)
+		if limit == "" {
+			continue
+		}
+		parts := strings.SplitN(limit, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid TTL limit %q, expecting <namespace>=<TTL>", limit)
+		}
+		ttl, err := time.ParseDuration(parts[1])
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid TTL of the TTL limit %q", limit)
+		}
+		ret[parts[0]] = ttl
+	}
+	return ret, nil
+}
+
+// identityNamespace returns the namespace of a SPIFFE identity, e.g. spiffe://cluster.local/ns/foo/sa/bar.
+func identityNamespace(id string) (string, bool) {
+	if !strings.HasPrefix(id, spiffe.URIPrefix) {
+		return "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(id, spiffe.URIPrefix), "/")
+	if len(parts) != 5 || parts[1] != "ns" || parts[2] == "" || parts[3] != "sa" {
+		return "", false
+	}
+	return parts[2], true
+}
+
+// identityTrustDomain returns the trust domain of a SPIFFE identity, e.g. cluster.local for
+// spiffe://cluster.local/ns/foo/sa/bar.
+func identityTrustDomain(id string) (string, bool) {
+	if !strings.HasPrefix(id, spiffe.URIPrefix) {
+		return "", false
+	}
+	td := strings.SplitN(strings.TrimPrefix(id, spiffe.URIPrefix), "/", 2)[0]
+	return td, td != ""
+}
+
+// evaluateCSRPolicies evaluates the CSR policies of the server, returning the error of the first
+// policy denying the request.
+func (s *Server) evaluateCSRPolicies(req *CSRRequest) error {
+	for _, policy := range s.CSRPolicies {
+		if err := policy.Evaluate(req); err != nil {
+			s.monitoring.GetCSRPolicyDenial(policy.PolicyType()).Increment()
+			return fmt.Errorf("denied by %s: %v", policy.PolicyType(), err)
+		}
+	}
+	return nil
+}
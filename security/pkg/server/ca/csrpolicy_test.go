@@ -0,0 +1,309 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	mockca "istio.io/istio/security/pkg/pki/ca/mock"
+	"istio.io/istio/security/pkg/pki/util"
+	mockutil "istio.io/istio/security/pkg/pki/util/mock"
+	"istio.io/istio/security/pkg/server/ca/authenticate"
+	pb "istio.io/istio/security/proto"
+)
+
+const (
+	fooID = "spiffe://cluster.local/ns/foo/sa/default"
+	barID = "spiffe://cluster.local/ns/bar/sa/default"
+)
+
+func genCSR(t *testing.T, host string) []byte {
+	t.Helper()
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Host: host, RSAKeySize: 1024})
+	if err != nil {
+		t.Fatalf("failed to generate the CSR: %v", err)
+	}
+	return csrPEM
+}
+
+func TestSANMatchCSRPolicy(t *testing.T) {
+	testCases := map[string]struct {
+		authSource  authenticate.AuthSource
+		csrPEM      []byte
+		expectedErr bool
+	}{
+		"matching SAN": {
+			csrPEM: genCSR(t, fooID),
+		},
+		"no SAN": {
+			csrPEM: genCSR(t, ""),
+		},
+		"other identity": {
+			csrPEM:      genCSR(t, barID),
+			expectedErr: true,
+		},
+		"one of the identities is not the caller's": {
+			csrPEM:      genCSR(t, fooID+","+barID),
+			expectedErr: true,
+		},
+		"ID token": {
+			authSource: authenticate.AuthSourceIDToken,
+			csrPEM:     genCSR(t, barID),
+		},
+		"invalid CSR": {
+			csrPEM:      []byte("dumb CSR"),
+			expectedErr: true,
+		},
+	}
+
+	policy := NewSANMatchCSRPolicy()
+	for id, tc := range testCases {
+		err := policy.Evaluate(&CSRRequest{
+			Caller:     &authenticate.Caller{AuthSource: tc.authSource, Identities: []string{fooID}},
+			CSRPEM:     tc.csrPEM,
+			Identities: []string{fooID},
+		})
+		if tc.expectedErr != (err != nil) {
+			t.Errorf("%s: unexpected error %v", id, err)
+		}
+	}
+}
+
+func TestNamespaceCSRPolicy(t *testing.T) {
+	testCases := map[string]struct {
+		identities  []string
+		expectedErr bool
+	}{
+		"allowed namespace": {
+			identities: []string{fooID},
+		},
+		"denied namespace": {
+			identities:  []string{fooID, barID},
+			expectedErr: true,
+		},
+		"not a namespace identity": {
+			identities:  []string{"spiffe://cluster.local/foo"},
+			expectedErr: true,
+		},
+	}
+
+	policy := NewNamespaceCSRPolicy([]string{"foo"})
+	for id, tc := range testCases {
+		err := policy.Evaluate(&CSRRequest{
+			Caller:     &authenticate.Caller{Identities: tc.identities},
+			Identities: tc.identities,
+		})
+		if tc.expectedErr != (err != nil) {
+			t.Errorf("%s: unexpected error %v", id, err)
+		}
+	}
+}
+
+func TestTrustDomainCSRPolicy(t *testing.T) {
+	testCases := map[string]struct {
+		identities  []string
+		expectedErr bool
+	}{
+		"trust domain of the CA": {
+			identities: []string{fooID},
+		},
+		"trust domain alias": {
+			identities: []string{fooID, "spiffe://remote.example.com/ns/foo/sa/foo"},
+		},
+		"denied trust domain": {
+			identities:  []string{fooID, "spiffe://other.example.com/ns/foo/sa/foo"},
+			expectedErr: true,
+		},
+		"not a SPIFFE identity": {
+			identities:  []string{"foo.example.com"},
+			expectedErr: true,
+		},
+	}
+
+	policy := NewTrustDomainCSRPolicy([]string{"cluster.local", "remote.example.com"})
+	for id, tc := range testCases {
+		err := policy.Evaluate(&CSRRequest{
+			Caller:     &authenticate.Caller{Identities: tc.identities},
+			Identities: tc.identities,
+		})
+		if tc.expectedErr != (err != nil) {
+			t.Errorf("%s: unexpected error %v", id, err)
+		}
+	}
+}
+
+func TestTTLLimitCSRPolicy(t *testing.T) {
+	testCases := map[string]struct {
+		identities  []string
+		ttl         time.Duration
+		expectedTTL time.Duration
+	}{
+		"lowered TTL": {
+			identities:  []string{fooID},
+			ttl:         24 * time.Hour,
+			expectedTTL: time.Hour,
+		},
+		"default TTL": {
+			identities:  []string{fooID},
+			expectedTTL: time.Hour,
+		},
+		"lower TTL": {
+			identities:  []string{fooID},
+			ttl:         time.Minute,
+			expectedTTL: time.Minute,
+		},
+		"lowest limit": {
+			identities:  []string{fooID, barID},
+			ttl:         24 * time.Hour,
+			expectedTTL: 30 * time.Minute,
+		},
+		"no limit": {
+			identities:  []string{"spiffe://cluster.local/ns/baz/sa/default"},
+			ttl:         24 * time.Hour,
+			expectedTTL: 24 * time.Hour,
+		},
+	}
+
+	policy := NewTTLLimitCSRPolicy(map[string]time.Duration{"foo": time.Hour, "bar": 30 * time.Minute})
+	for id, tc := range testCases {
+		req := &CSRRequest{
+			Caller:     &authenticate.Caller{Identities: tc.identities},
+			Identities: tc.identities,
+			TTL:        tc.ttl,
+		}
+		if err := policy.Evaluate(req); err != nil {
+			t.Errorf("%s: unexpected error %v", id, err)
+		}
+		if req.TTL != tc.expectedTTL {
+			t.Errorf("%s: got TTL %v, expected %v", id, req.TTL, tc.expectedTTL)
+		}
+	}
+}
+
+func TestAuditCSRPolicy(t *testing.T) {
+	for _, policy := range []CSRPolicy{
+		NewAuditCSRPolicy(NewNamespaceCSRPolicy([]string{"foo"})),
+		NewAuditCSRPolicy(NewTTLLimitCSRPolicy(map[string]time.Duration{"bar": time.Minute})),
+	} {
+		req := &CSRRequest{
+			Caller:     &authenticate.Caller{Identities: []string{barID}},
+			Identities: []string{barID},
+			TTL:        time.Hour,
+		}
+		if err := policy.Evaluate(req); err != nil {
+			t.Errorf("%s: the audited policy should not deny the request: %v", policy.PolicyType(), err)
+		}
+		if req.TTL != time.Hour {
+			t.Errorf("%s: the audited policy should not change the request", policy.PolicyType())
+		}
+	}
+}
+
+func TestParseTTLLimits(t *testing.T) {
+	testCases := map[string]struct {
+		limits      string
+		expected    map[string]time.Duration
+		expectedErr bool
+	}{
+		"empty": {
+			expected: map[string]time.Duration{},
+		},
+		"limits": {
+			limits:   "foo=1h, bar=30m,",
+			expected: map[string]time.Duration{"foo": time.Hour, "bar": 30 * time.Minute},
+		},
+		"missing TTL": {
+			limits:      "foo",
+			expectedErr: true,
+		},
+		"invalid TTL": {
+			limits:      "foo=1 hour",
+			expectedErr: true,
+		},
+		"negative TTL": {
+			limits:      "foo=-1h",
+			expectedErr: true,
+		},
+	}
+
+	for id, tc := range testCases {
+		limits, err := ParseTTLLimits(tc.limits)
+		if tc.expectedErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", id, err)
+		} else if !reflect.DeepEqual(limits, tc.expected) {
+			t.Errorf("%s: got %v, expected %v", id, limits, tc.expected)
+		}
+	}
+}
+
+func TestCreateCertificateWithCSRPolicies(t *testing.T) {
+	testCases := map[string]struct {
+		policies    []CSRPolicy
+		code        codes.Code
+		expectedTTL time.Duration
+	}{
+		"approved": {
+			policies:    []CSRPolicy{NewSANMatchCSRPolicy(), NewNamespaceCSRPolicy([]string{"foo"})},
+			code:        codes.OK,
+			expectedTTL: time.Hour,
+		},
+		"denied": {
+			policies: []CSRPolicy{NewNamespaceCSRPolicy([]string{"bar"})},
+			code:     codes.PermissionDenied,
+		},
+		"lowered TTL": {
+			policies:    []CSRPolicy{NewTTLLimitCSRPolicy(map[string]time.Duration{"foo": time.Minute})},
+			code:        codes.OK,
+			expectedTTL: time.Minute,
+		},
+	}
+
+	for id, tc := range testCases {
+		ca := &mockca.FakeCA{
+			SignedCert:    []byte("cert"),
+			KeyCertBundle: &mockutil.FakeKeyCertBundle{RootCertBytes: []byte("root_cert")},
+		}
+		server := &Server{
+			ca:             ca,
+			hostnames:      []string{"hostname"},
+			port:           8080,
+			Authenticators: []authenticator{&mockAuthenticator{identities: []string{fooID}}},
+			CSRPolicies:    tc.policies,
+			monitoring:     newMonitoringMetrics(),
+		}
+		request := &pb.IstioCertificateRequest{Csr: string(genCSR(t, fooID)), ValidityDuration: 3600}
+
+		_, err := server.CreateCertificate(context.Background(), request)
+		if code := status.Code(err); code != tc.code {
+			t.Errorf("%s: got code %v, expected %v: %v", id, code, tc.code, err)
+			continue
+		}
+		if tc.code == codes.OK && ca.ReceivedTTL != tc.expectedTTL {
+			t.Errorf("%s: the certificate is signed with TTL %v, expected %v", id, ca.ReceivedTTL, tc.expectedTTL)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// healthServiceName is the service checked by the gRPC health Check RPC. The empty service,
+	// checking the whole server, is accepted too.
+	healthServiceName = "istio.v1.auth.IstioCertificateService"
+
+	// caCertExpiryBuffer is the minimal remaining lifetime of the signing cert of a healthy CA.
+	caCertExpiryBuffer = time.Hour
+)
+
+// CheckHealth returns an error if the CA can't sign certificates: the signing key isn't loaded, or
+// the signing cert is expired or about to expire.
+func (s *Server) CheckHealth() error {
+	cert, privKey, _, _ := s.ca.GetCAKeyCertBundle().GetAll()
+	if privKey == nil || cert == nil {
+		return fmt.Errorf("the CA signing key is not loaded")
+	}
+	if remaining := time.Until(cert.NotAfter); remaining < caCertExpiryBuffer {
+		return fmt.Errorf("the CA signing cert expires at %v, in less than %v", cert.NotAfter, caCertExpiryBuffer)
+	}
+	return nil
+}
+
+// HealthHandler serves the health of the CA over HTTP: 200 if the CA can sign certificates, 503
+// otherwise.
+func (s *Server) HealthHandler(w http.ResponseWriter, _ *http.Request) {
+	if err := s.CheckHealth(); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// healthServer implements the gRPC health service for the CA, so that load balancers stop sending
+// CSRs to a broken CA.
+type healthServer struct {
+	s *Server
+}
+
+// Check returns SERVING if the CA can sign certificates.
+func (h *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if req.Service != "" && req.Service != healthServiceName {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.Service)
+	}
+	if err := h.s.CheckHealth(); err != nil {
+		serverCaLog.Warnf("CA health check failure: %v", err)
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING}, nil
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+// Watch is not supported, the clients poll with Check.
+func (h *healthServer) Watch(*healthpb.HealthCheckRequest, healthpb.Health_WatchServer) error {
+	return status.Error(codes.Unimplemented, "watching the CA health is not supported")
+}
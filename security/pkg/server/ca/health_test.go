@@ -0,0 +1,106 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	mockca "istio.io/istio/security/pkg/pki/ca/mock"
+	pkiutil "istio.io/istio/security/pkg/pki/util"
+)
+
+func caWithSigningCert(t *testing.T, ttl time.Duration) CertificateAuthority {
+	t.Helper()
+	cert, key, err := pkiutil.GenCertKeyFromOptions(pkiutil.CertOptions{
+		Host:         "citadel.testing.istio.io",
+		NotBefore:    time.Now(),
+		TTL:          ttl,
+		Org:          "MyOrg",
+		IsCA:         true,
+		IsSelfSigned: true,
+		RSAKeySize:   512,
+	})
+	if err != nil {
+		t.Fatalf("failed to gen cert for Citadel self signed cert %v", err)
+	}
+	kb, err := pkiutil.NewVerifiedKeyCertBundleFromPem(cert, key, nil, cert)
+	if err != nil {
+		t.Fatalf("failed to create key cert bundle %v", err)
+	}
+	return &mockca.FakeCA{KeyCertBundle: kb}
+}
+
+func TestCheckHealth(t *testing.T) {
+	testCases := map[string]struct {
+		ca      CertificateAuthority
+		healthy bool
+	}{
+		"healthy CA": {
+			ca:      caWithSigningCert(t, 24*time.Hour),
+			healthy: true,
+		},
+		"signing key not loaded": {
+			ca: &mockca.FakeCA{},
+		},
+		"signing cert near expiry": {
+			ca: caWithSigningCert(t, 10*time.Minute),
+		},
+	}
+
+	for id, c := range testCases {
+		s := &Server{ca: c.ca}
+		if err := s.CheckHealth(); (err == nil) != c.healthy {
+			t.Errorf("%s: got health error %v, want healthy %v", id, err, c.healthy)
+		}
+
+		rec := httptest.NewRecorder()
+		s.HealthHandler(rec, httptest.NewRequest("GET", "/ca/health", nil))
+		wantCode := http.StatusServiceUnavailable
+		if c.healthy {
+			wantCode = http.StatusOK
+		}
+		if rec.Code != wantCode {
+			t.Errorf("%s: got HTTP status %d, want %d", id, rec.Code, wantCode)
+		}
+
+		for _, service := range []string{"", healthServiceName} {
+			resp, err := (&healthServer{s: s}).Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+			if err != nil {
+				t.Fatalf("%s: Check(%q) failed: %v", id, service, err)
+			}
+			wantStatus := healthpb.HealthCheckResponse_NOT_SERVING
+			if c.healthy {
+				wantStatus = healthpb.HealthCheckResponse_SERVING
+			}
+			if resp.Status != wantStatus {
+				t.Errorf("%s: Check(%q) got status %v, want %v", id, service, resp.Status, wantStatus)
+			}
+		}
+	}
+
+	s := &Server{ca: caWithSigningCert(t, 24*time.Hour)}
+	_, err := (&healthServer{s: s}).Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("got error %v for unknown service, want NotFound", err)
+	}
+}
@@ -19,11 +19,13 @@ import (
 )
 
 const (
-	errorlabel = "error"
+	errorlabel  = "error"
+	policylabel = "policy"
 )
 
 var (
-	errorTag = monitoring.MustCreateLabel(errorlabel)
+	errorTag  = monitoring.MustCreateLabel(errorlabel)
+	policyTag = monitoring.MustCreateLabel(policylabel)
 
 	csrCounts = monitoring.NewSum(
 		"citadel_server_csr_count",
@@ -51,6 +53,12 @@ var (
 		monitoring.WithLabels(errorTag),
 	)
 
+	csrPolicyDenialCounts = monitoring.NewSum(
+		"citadel_server_csr_policy_denial_count",
+		"The number of CSRs denied by the CSR policies.",
+		monitoring.WithLabels(policyTag),
+	)
+
 	successCounts = monitoring.NewSum(
 		"citadel_server_success_cert_issuance_count",
 		"The number of certificates issuances that have succeeded.",
@@ -70,6 +78,7 @@ func init() {
 		csrParsingErrorCounts,
 		idExtractionErrorCounts,
 		certSignErrorCounts,
+		csrPolicyDenialCounts,
 		successCounts,
 		rootCertExpiryTimestamp,
 	)
@@ -83,6 +92,7 @@ type monitoringMetrics struct {
 	CSRError          monitoring.Metric
 	IDExtractionError monitoring.Metric
 	certSignErrors    monitoring.Metric
+	csrPolicyDenials  monitoring.Metric
 }
 
 // newMonitoringMetrics creates a new monitoringMetrics.
@@ -94,9 +104,14 @@ func newMonitoringMetrics() monitoringMetrics {
 		CSRError:          csrParsingErrorCounts,
 		IDExtractionError: idExtractionErrorCounts,
 		certSignErrors:    certSignErrorCounts,
+		csrPolicyDenials:  csrPolicyDenialCounts,
 	}
 }
 
 func (m *monitoringMetrics) GetCertSignError(err string) monitoring.Metric {
 	return m.certSignErrors.With(errorTag.Value(err))
 }
+
+func (m *monitoringMetrics) GetCSRPolicyDenial(policy string) monitoring.Metric {
+	return m.csrPolicyDenials.With(policyTag.Value(policy))
+}
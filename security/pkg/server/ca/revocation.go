@@ -0,0 +1,202 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RevocationCSRPolicyType = "RevocationCSRPolicy"
+
+	// crlValidity is the time the CRL is valid for, its next update. The CRL is signed again on each
+	// request, so that the relying parties fetch the revocations at least as often.
+	crlValidity = time.Hour
+)
+
+// RevocationList is the list of the revoked workload identities, which can't get certificates from
+// the CA, and of the serial numbers of the revoked workload certificates, which are listed in the CRL
+// of the CA and can't be used to renew the certificates. Workload certificates are short-lived, so
+// denying the identity is usually enough: its certificates expire within their TTL.
+type RevocationList struct {
+	mutex      sync.RWMutex
+	identities map[string]bool
+	// serials are the revocation times of the revoked certificates, keyed by hexadecimal serial number.
+	serials map[string]time.Time
+}
+
+// NewRevocationList returns an empty revocation list.
+func NewRevocationList() *RevocationList {
+	return &RevocationList{
+		identities: map[string]bool{},
+		serials:    map[string]time.Time{},
+	}
+}
+
+// Update replaces the revoked identities and certificate serial numbers. The revocation time of the
+// certificates which were already revoked is kept.
+func (r *RevocationList) Update(identities []string, serials []string) error {
+	ids := make(map[string]bool, len(identities))
+	for _, id := range identities {
+		ids[id] = true
+	}
+	now := time.Now()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	revoked := make(map[string]time.Time, len(serials))
+	for _, serial := range serials {
+		n, ok := new(big.Int).SetString(serial, 16)
+		if !ok {
+			return fmt.Errorf("invalid serial number %q, expecting a hexadecimal number", serial)
+		}
+		serial = n.Text(16)
+		if t, found := r.serials[serial]; found {
+			revoked[serial] = t
+		} else {
+			revoked[serial] = now
+		}
+	}
+	r.identities = ids
+	r.serials = revoked
+	return nil
+}
+
+// IsIdentityRevoked returns whether the identity is revoked.
+func (r *RevocationList) IsIdentityRevoked(id string) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.identities[id]
+}
+
+// IsCertificateRevoked returns whether the certificate with the given hexadecimal serial number is
+// revoked.
+func (r *RevocationList) IsCertificateRevoked(serial string) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	_, found := r.serials[strings.ToLower(serial)]
+	return found
+}
+
+// RevokedIdentities returns the sorted revoked identities.
+func (r *RevocationList) RevokedIdentities() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	out := make([]string, 0, len(r.identities))
+	for id := range r.identities {
+		out = append(out, id)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (r *RevocationList) revokedCertificates() []pkix.RevokedCertificate {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	out := make([]pkix.RevokedCertificate, 0, len(r.serials))
+	for serial, t := range r.serials {
+		n, _ := new(big.Int).SetString(serial, 16)
+		out = append(out, pkix.RevokedCertificate{SerialNumber: n, RevocationTime: t})
+	}
+	return out
+}
+
+// revocationCSRPolicy denies the CSRs of the revoked identities, and of the callers with a revoked
+// identity.
+type revocationCSRPolicy struct {
+	revocations *RevocationList
+}
+
+// NewRevocationCSRPolicy returns the policy denying the CSRs of the identities of the revocation list.
+func NewRevocationCSRPolicy(revocations *RevocationList) CSRPolicy {
+	return &revocationCSRPolicy{revocations: revocations}
+}
+
+func (p *revocationCSRPolicy) PolicyType() string {
+	return RevocationCSRPolicyType
+}
+
+func (p *revocationCSRPolicy) Evaluate(req *CSRRequest) error {
+	for _, ids := range [][]string{req.Identities, req.Caller.Identities} {
+		for _, id := range ids {
+			if p.revocations.IsIdentityRevoked(id) {
+				return fmt.Errorf("the identity %q is revoked", id)
+			}
+		}
+	}
+	return nil
+}
+
+// EnableRevocation denies the certificates of the identities of the revocation list, and the client
+// certificates it revokes. The revocations are enforced before the other CSR policies of the server,
+// and are never audited only.
+func (s *Server) EnableRevocation(revocations *RevocationList) {
+	s.revocations = revocations
+	s.CSRPolicies = append([]CSRPolicy{NewRevocationCSRPolicy(revocations)}, s.CSRPolicies...)
+}
+
+// CRL returns the DER encoded CRL of the certificates revoked by the revocation list and of the
+// revoked subordinate CA certificates, signed by the CA.
+func (s *Server) CRL() ([]byte, error) {
+	var revoked []pkix.RevokedCertificate
+	if s.revocations != nil {
+		revoked = append(revoked, s.revocations.revokedCertificates()...)
+	}
+	for _, record := range s.SubordinateCAs() {
+		if !record.Revoked {
+			continue
+		}
+		n, ok := new(big.Int).SetString(record.SerialNumber, 16)
+		if !ok {
+			continue
+		}
+		revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: n, RevocationTime: record.RevocationTime})
+	}
+	sort.Slice(revoked, func(i, j int) bool {
+		return revoked[i].SerialNumber.Cmp(revoked[j].SerialNumber) < 0
+	})
+
+	cert, key, _, _ := s.ca.GetCAKeyCertBundle().GetAll()
+	if cert == nil || key == nil {
+		return nil, fmt.Errorf("the CA has no signing certificate")
+	}
+	// Unlike Go, OpenSSL and BoringSSL reject the CRLs signed by a certificate without the cRLSign
+	// key usage.
+	if cert.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		return nil, fmt.Errorf("the CA signing certificate is not allowed to sign CRLs, it lacks the cRLSign key usage")
+	}
+	now := time.Now()
+	return cert.CreateCRL(rand.Reader, *key, revoked, now, now.Add(crlValidity))
+}
+
+// CRLHandler serves the DER encoded CRL of the CA.
+func (s *Server) CRLHandler(w http.ResponseWriter, _ *http.Request) {
+	crl, err := s.CRL()
+	if err != nil {
+		serverCaLog.Errorf("failed to create the CRL: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pkix-crl")
+	_, _ = w.Write(crl)
+}
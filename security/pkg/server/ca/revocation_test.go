@@ -0,0 +1,209 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	mockca "istio.io/istio/security/pkg/pki/ca/mock"
+	"istio.io/istio/security/pkg/pki/util"
+	mockutil "istio.io/istio/security/pkg/pki/util/mock"
+	pb "istio.io/istio/security/proto"
+)
+
+func TestRevocationListUpdate(t *testing.T) {
+	r := NewRevocationList()
+	if err := r.Update([]string{fooID}, []string{"0A1B"}); err != nil {
+		t.Fatal(err)
+	}
+	if !r.IsIdentityRevoked(fooID) || r.IsIdentityRevoked(barID) {
+		t.Errorf("got revoked identities %v, expected %v", r.RevokedIdentities(), []string{fooID})
+	}
+	if !r.IsCertificateRevoked("a1b") {
+		t.Error("the certificate a1b should be revoked")
+	}
+	revocationTime := r.revokedCertificates()[0].RevocationTime
+
+	// The revocation time of the certificates already revoked is kept.
+	if err := r.Update([]string{barID}, []string{"a1b", "ff"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.RevokedIdentities(); !reflect.DeepEqual(got, []string{barID}) {
+		t.Errorf("got revoked identities %v, expected %v", got, []string{barID})
+	}
+	for _, revoked := range r.revokedCertificates() {
+		if revoked.SerialNumber.Text(16) == "a1b" && !revoked.RevocationTime.Equal(revocationTime) {
+			t.Errorf("got revocation time %v, expected %v", revoked.RevocationTime, revocationTime)
+		}
+	}
+
+	if err := r.Update(nil, []string{"not a serial"}); err == nil {
+		t.Error("expected an invalid serial number error")
+	}
+	if !r.IsCertificateRevoked("ff") {
+		t.Error("the revocation list should not be changed by an invalid update")
+	}
+}
+
+func TestCreateCertificateWithRevokedIdentity(t *testing.T) {
+	revocations := NewRevocationList()
+	server := &Server{
+		ca: &mockca.FakeCA{
+			SignedCert:    []byte("cert"),
+			KeyCertBundle: &mockutil.FakeKeyCertBundle{RootCertBytes: []byte("root_cert")},
+		},
+		Authenticators: []authenticator{&mockAuthenticator{identities: []string{fooID}}},
+		// The revocations are enforced even if the other policies are audited only.
+		CSRPolicies: []CSRPolicy{NewAuditCSRPolicy(NewNamespaceCSRPolicy([]string{"bar"}))},
+		monitoring:  newMonitoringMetrics(),
+	}
+	server.EnableRevocation(revocations)
+	request := &pb.IstioCertificateRequest{Csr: string(genCSR(t, fooID)), ValidityDuration: 3600}
+
+	if _, err := server.CreateCertificate(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+	if err := revocations.Update([]string{fooID}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.CreateCertificate(context.Background(), request); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("got %v, expected the CSR of the revoked identity to be denied", err)
+	}
+}
+
+func TestAuthenticateRevokedCertificate(t *testing.T) {
+	revocations := NewRevocationList()
+	server := &Server{
+		Authenticators: []authenticator{&mockAuthenticator{identities: []string{fooID}}},
+		monitoring:     newMonitoringMetrics(),
+	}
+	server.EnableRevocation(revocations)
+
+	certPEM, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Host:         fooID,
+		TTL:          time.Hour,
+		IsSelfSigned: true,
+		RSAKeySize:   512,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{cert}},
+		}},
+	})
+	if server.authenticate(ctx) == nil {
+		t.Fatal("the client should be authenticated")
+	}
+	if err := revocations.Update(nil, []string{serialNumber(cert)}); err != nil {
+		t.Fatal(err)
+	}
+	if server.authenticate(ctx) != nil {
+		t.Error("the client should not be authenticated once its certificate is revoked")
+	}
+}
+
+func TestCRLHandler(t *testing.T) {
+	ca := caWithSigningCert(t, time.Hour)
+	revocations := NewRevocationList()
+	if err := revocations.Update(nil, []string{"2a", "1f"}); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{ca: ca, monitoring: newMonitoringMetrics()}
+	server.EnableRevocation(revocations)
+
+	rw := httptest.NewRecorder()
+	server.CRLHandler(rw, httptest.NewRequest(http.MethodGet, "/ca/crl", nil))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("got status %d, expected %d", rw.Code, http.StatusOK)
+	}
+	if ct := rw.Header().Get("Content-Type"); ct != "application/pkix-crl" {
+		t.Errorf("got content type %q", ct)
+	}
+	crl, err := x509.ParseDERCRL(rw.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingCert, _, _, _ := ca.GetCAKeyCertBundle().GetAll()
+	if err := signingCert.CheckCRLSignature(crl); err != nil {
+		t.Errorf("the CRL is not signed by the CA: %v", err)
+	}
+	var serials []string
+	for _, revoked := range crl.TBSCertList.RevokedCertificates {
+		serials = append(serials, revoked.SerialNumber.Text(16))
+	}
+	if expected := []string{"1f", "2a"}; !reflect.DeepEqual(serials, expected) {
+		t.Errorf("got revoked serial numbers %v, expected %v", serials, expected)
+	}
+}
+
+func TestCRLRequiresCRLSignKeyUsage(t *testing.T) {
+	signingCert, _, _, _ := caWithSigningCert(t, time.Hour).GetCAKeyCertBundle().GetAll()
+	if signingCert.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		t.Errorf("the generated CA certificate should have the cRLSign key usage, got %v", signingCert.KeyUsage)
+	}
+
+	// A CA certificate only allowed to sign certificates, as generated by older versions.
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"MyOrg"}},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	kb, err := util.NewVerifiedKeyCertBundleFromPem(certPem, keyPem, nil, certPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{ca: &mockca.FakeCA{KeyCertBundle: kb}, monitoring: newMonitoringMetrics()}
+	server.EnableRevocation(NewRevocationList())
+	if _, err := server.CRL(); err == nil {
+		t.Error("the CRL should not be signed by a certificate without the cRLSign key usage")
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/peer"
+
+	"istio.io/istio/security/pkg/pki/ca"
+)
+
+// rootDeliveryRetention is how long the roots delivered to a peer are tracked without new certificate.
+const rootDeliveryRetention = 48 * time.Hour
+
+// RootDelivery is the trust bundle and the signing root delivered with the last certificate issued
+// to a peer. The roots are identified by their SHA-256 fingerprint.
+type RootDelivery struct {
+	// Roots are the roots of the trust bundle.
+	Roots []string `json:"roots"`
+	// SigningRoot is the root of the chain of the certificate.
+	SigningRoot string `json:"signingRoot,omitempty"`
+	// Time is when the certificate was issued.
+	Time time.Time `json:"time"`
+}
+
+// HasRoot returns whether the root is in the delivered trust bundle.
+func (d RootDelivery) HasRoot(root string) bool {
+	for _, r := range d.Roots {
+		if r == root {
+			return true
+		}
+	}
+	return false
+}
+
+// rootDeliveries tracks the roots delivered to the peers by IP address, to verify that a new root is
+// distributed to the proxies before switching to it. The zero value tracks no delivery.
+type rootDeliveries struct {
+	mutex     sync.Mutex
+	byAddress map[string]RootDelivery
+}
+
+func (rd *rootDeliveries) record(address string, delivery RootDelivery) {
+	rd.mutex.Lock()
+	defer rd.mutex.Unlock()
+	if rd.byAddress == nil {
+		rd.byAddress = map[string]RootDelivery{}
+	}
+	for a, d := range rd.byAddress {
+		if delivery.Time.Sub(d.Time) > rootDeliveryRetention {
+			delete(rd.byAddress, a)
+		}
+	}
+	rd.byAddress[address] = delivery
+}
+
+func (rd *rootDeliveries) all() map[string]RootDelivery {
+	rd.mutex.Lock()
+	defer rd.mutex.Unlock()
+	out := make(map[string]RootDelivery, len(rd.byAddress))
+	for a, d := range rd.byAddress {
+		out[a] = d
+	}
+	return out
+}
+
+// recordRootDelivery records the trust bundle and signing root delivered to the peer of the request.
+func (s *Server) recordRootDelivery(ctx context.Context, trustBundle []byte, now time.Time) {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return
+	}
+	address := p.Addr.String()
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	roots, err := parseRoots(trustBundle)
+	if err != nil {
+		return
+	}
+	delivery := RootDelivery{Time: now}
+	for _, root := range roots {
+		delivery.Roots = append(delivery.Roots, ca.CertFingerprint(root))
+	}
+	if root := s.signingRoot(roots); root != nil {
+		delivery.SigningRoot = ca.CertFingerprint(root)
+	}
+	s.rootDeliveries.record(address, delivery)
+}
+
+// signingRoot returns the root of the chain of the signing cert of the CA, among the given roots.
+func (s *Server) signingRoot(roots []*x509.Certificate) *x509.Certificate {
+	signingCert, _, certChainBytes, _ := s.ca.GetCAKeyCertBundle().GetAll()
+	if signingCert == nil {
+		return nil
+	}
+	top := signingCert
+	if len(certChainBytes) > 0 {
+		if chain, err := parseRoots(certChainBytes); err == nil {
+			top = chain[len(chain)-1]
+		}
+	}
+	for _, root := range roots {
+		if top.Equal(root) || top.CheckSignatureFrom(root) == nil {
+			return root
+		}
+	}
+	return nil
+}
+
+// ConnectedProxy is a proxy connected to the discovery server of the CA.
+type ConnectedProxy struct {
+	ID        string   `json:"id"`
+	Addresses []string `json:"addresses"`
+}
+
+// RootRotationReport is the root rotation status of a CA replica, with the proxies connected to its
+// discovery server and the roots it delivered, by peer address. The proxies may get their
+// certificates from another replica, so the reports of all the replicas are combined to find the
+// proxies which got a root.
+type RootRotationReport struct {
+	Status     ca.RootRotationStatus   `json:"status"`
+	Proxies    []ConnectedProxy        `json:"proxies"`
+	Deliveries map[string]RootDelivery `json:"deliveries"`
+}
+
+type rootRotator interface {
+	RootRotationStatus() (ca.RootRotationStatus, error)
+}
+
+// RootRotationHandler returns the handler serving the RootRotationReport of the CA, as JSON, with the
+// proxies returned by the given function.
+func (s *Server) RootRotationHandler(proxies func() []ConnectedProxy) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		rotator, ok := s.ca.(rootRotator)
+		if !ok {
+			w.WriteHeader(http.StatusNotImplemented)
+			_, _ = fmt.Fprintln(w, "root rotation is only supported by the self-signed CA")
+			return
+		}
+		status, err := rotator.RootRotationStatus()
+		if err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = fmt.Fprintf(w, "%v\n", err)
+			return
+		}
+		report := RootRotationReport{
+			Status:     status,
+			Proxies:    proxies(),
+			Deliveries: s.rootDeliveries.all(),
+		}
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = fmt.Fprintf(w, "unable to marshal the root rotation report: %v\n", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(out)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/peer"
+
+	"istio.io/istio/security/pkg/pki/ca"
+	pkiutil "istio.io/istio/security/pkg/pki/util"
+)
+
+type rootRotatorCA struct {
+	CertificateAuthority
+	status ca.RootRotationStatus
+}
+
+func (r *rootRotatorCA) RootRotationStatus() (ca.RootRotationStatus, error) {
+	return r.status, nil
+}
+
+func TestRootRotationReport(t *testing.T) {
+	signingCA := caWithSigningCert(t, time.Hour)
+	signingCert, _, _, _ := signingCA.GetCAKeyCertBundle().GetAll()
+	signingCertPEM, signingKeyPEM, _, _ := signingCA.GetCAKeyCertBundle().GetAllPem()
+	pendingPEM, _, err := pkiutil.GenCertKeyFromOptions(pkiutil.CertOptions{
+		Org:          "MyOrg",
+		TTL:          time.Hour,
+		IsCA:         true,
+		IsSelfSigned: true,
+		RSAKeySize:   512,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := pkiutil.ParsePemEncodedCertificate(pendingPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The pending root is distributed, the certificates are still signed by the signing cert.
+	if err := signingCA.GetCAKeyCertBundle().VerifyAndSetAll(signingCertPEM, signingKeyPEM, nil,
+		[]byte(strings.TrimSuffix(string(signingCertPEM), "\n")+"\n"+string(pendingPEM))); err != nil {
+		t.Fatal(err)
+	}
+	status := ca.RootRotationStatus{
+		Phase:       ca.RootRotationDistributing,
+		SigningRoot: ca.CertFingerprint(signingCert),
+		PendingRoot: ca.CertFingerprint(pending),
+	}
+	server := &Server{ca: &rootRotatorCA{CertificateAuthority: signingCA, status: status}}
+
+	now := time.Now()
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4321}})
+	server.recordRootDelivery(ctx, server.TrustBundle(), now)
+
+	rw := httptest.NewRecorder()
+	server.RootRotationHandler(func() []ConnectedProxy {
+		return []ConnectedProxy{{ID: "a.default", Addresses: []string{"10.0.0.1"}}}
+	})(rw, httptest.NewRequest(http.MethodGet, "/ca/rootrotation", nil))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rw.Code, rw.Body.String())
+	}
+	var report RootRotationReport
+	if err := json.Unmarshal(rw.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != status {
+		t.Errorf("got status %+v, want %+v", report.Status, status)
+	}
+	if want := []ConnectedProxy{{ID: "a.default", Addresses: []string{"10.0.0.1"}}}; !reflect.DeepEqual(report.Proxies, want) {
+		t.Errorf("got proxies %v, want %v", report.Proxies, want)
+	}
+	delivery, f := report.Deliveries["10.0.0.1"]
+	if !f {
+		t.Fatalf("no delivery to 10.0.0.1 in %v", report.Deliveries)
+	}
+	if !delivery.HasRoot(status.PendingRoot) || !delivery.HasRoot(status.SigningRoot) {
+		t.Errorf("got delivered roots %v, want %s and %s", delivery.Roots, status.SigningRoot, status.PendingRoot)
+	}
+	if delivery.SigningRoot != status.SigningRoot {
+		t.Errorf("got signing root %s, want %s", delivery.SigningRoot, status.SigningRoot)
+	}
+
+	// The deliveries without certificate for too long are dropped.
+	server.recordRootDelivery(peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 4321},
+	}), server.TrustBundle(), now.Add(rootDeliveryRetention+time.Minute))
+	if _, f := server.rootDeliveries.all()["10.0.0.1"]; f {
+		t.Error("the delivery to 10.0.0.1 should be dropped")
+	}
+}
+
+func TestRootRotationHandlerUnsupported(t *testing.T) {
+	server := &Server{ca: caWithSigningCert(t, time.Hour)}
+	rw := httptest.NewRecorder()
+	server.RootRotationHandler(func() []ConnectedProxy { return nil })(rw,
+		httptest.NewRequest(http.MethodGet, "/ca/rootrotation", nil))
+	if rw.Code != http.StatusNotImplemented {
+		t.Errorf("got status %d, want %d", rw.Code, http.StatusNotImplemented)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"istio.io/istio/pkg/spiffe"
+)
+
+const (
+	SANTemplateCSRPolicyType = "SANTemplateCSRPolicy"
+
+	// anyServiceAccount is the service account of the SAN templates of all the service accounts of a
+	// namespace.
+	anyServiceAccount = "*"
+)
+
+// SANTemplates are the additional SANs of the certificates of the service accounts, e.g. the legacy DNS
+// names of databases validating the hostname of their clients, keyed by <namespace>/<service account>,
+// or <namespace>/* for all the service accounts of the namespace. The SANs are templates expanding
+// ${trustDomain}, ${namespace} and ${serviceAccount} to the ones of the identity of the certificate.
+type SANTemplates struct {
+	mutex     sync.RWMutex
+	templates map[string][]string
+}
+
+// NewSANTemplates returns empty SAN templates.
+func NewSANTemplates() *SANTemplates {
+	return &SANTemplates{templates: map[string][]string{}}
+}
+
+// ParseSANTemplates parses the SAN templates, one <namespace>/<service account> per line followed by
+// its space separated SANs. Empty lines and lines starting with # are ignored, e.g.
+//
+//	# The clients of the legacy database.
+//	billing/payments payments.billing.db.example.com spiffe://legacy.example.com/payments
+//	reporting/* ${serviceAccount}.reporting.db.example.com
+func ParseSANTemplates(text string) (map[string][]string, error) {
+	ret := map[string][]string{}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		parts := strings.Split(fields[0], "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" || len(fields) < 2 {
+			return nil, fmt.Errorf("invalid SAN template %q, expecting <namespace>/<service account> <SAN>...",
+				scanner.Text())
+		}
+		ret[fields[0]] = append(ret[fields[0]], fields[1:]...)
+	}
+	return ret, scanner.Err()
+}
+
+// Update replaces the SAN templates, keyed by <namespace>/<service account>. The templates are kept
+// if any of the new ones is invalid.
+func (t *SANTemplates) Update(templates map[string][]string) error {
+	for key, sans := range templates {
+		parts := strings.Split(key, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid service account %q, expecting <namespace>/<service account>", key)
+		}
+		for _, san := range sans {
+			if _, err := expandSANTemplate(san, "cluster.local", parts[0], parts[1]); err != nil {
+				return err
+			}
+		}
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.templates = templates
+	return nil
+}
+
+// SANs returns the additional SANs of the certificates of the SPIFFE identity: the ones of its service
+// account, then the ones of all the service accounts of its namespace.
+func (t *SANTemplates) SANs(id string) []string {
+	trustDomain, ns, sa, ok := parseIdentity(id)
+	if !ok {
+		return nil
+	}
+	t.mutex.RLock()
+	templates := append(append([]string(nil), t.templates[ns+"/"+sa]...), t.templates[ns+"/"+anyServiceAccount]...)
+	t.mutex.RUnlock()
+
+	var sans []string
+	for _, template := range templates {
+		// The templates are validated by Update.
+		san, _ := expandSANTemplate(template, trustDomain, ns, sa)
+		sans = append(sans, san)
+	}
+	return sans
+}
+
+// expandSANTemplate expands the variables of the SAN template, which must be a DNS name or a URI.
+func expandSANTemplate(template, trustDomain, ns, sa string) (string, error) {
+	var unknown []string
+	san := os.Expand(template, func(name string) string {
+		switch name {
+		case "trustDomain":
+			return trustDomain
+		case "namespace":
+			return ns
+		case "serviceAccount":
+			return sa
+		}
+		unknown = append(unknown, name)
+		return ""
+	})
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("unknown variables %v in the SAN template %q", unknown, template)
+	}
+	if strings.Contains(san, "://") {
+		if u, err := url.Parse(san); err != nil || u.Scheme == "" {
+			return "", fmt.Errorf("invalid URI %q of the SAN template %q", san, template)
+		}
+		return san, nil
+	}
+	if net.ParseIP(san) != nil || strings.ContainsAny(san, "/:,") {
+		return "", fmt.Errorf("invalid DNS name %q of the SAN template %q", san, template)
+	}
+	return san, nil
+}
+
+// parseIdentity returns the trust domain, namespace and service account of a SPIFFE identity, e.g.
+// spiffe://cluster.local/ns/foo/sa/bar.
+func parseIdentity(id string) (string, string, string, bool) {
+	ns, ok := identityNamespace(id)
+	if !ok {
+		return "", "", "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(id, spiffe.URIPrefix), "/")
+	if parts[4] == "" {
+		return "", "", "", false
+	}
+	return parts[0], ns, parts[4], true
+}
+
+// sanTemplateCSRPolicy adds the SANs of the templates of the identities to the certificates.
+type sanTemplateCSRPolicy struct {
+	templates *SANTemplates
+}
+
+// NewSANTemplateCSRPolicy returns the policy adding the SANs of the templates of their identities to the
+// certificates. It never denies a CSR.
+func NewSANTemplateCSRPolicy(templates *SANTemplates) CSRPolicy {
+	return &sanTemplateCSRPolicy{templates: templates}
+}
+
+func (p *sanTemplateCSRPolicy) PolicyType() string {
+	return SANTemplateCSRPolicyType
+}
+
+func (p *sanTemplateCSRPolicy) Evaluate(req *CSRRequest) error {
+	if req.ForCA {
+		return nil
+	}
+	ids := make(map[string]bool, len(req.Identities))
+	for _, id := range req.Identities {
+		ids[id] = true
+	}
+	// The identities may be the ones of the caller, which are not changed.
+	identities := append([]string(nil), req.Identities...)
+	for _, id := range req.Identities {
+		for _, san := range p.templates.SANs(id) {
+			if !ids[san] {
+				ids[san] = true
+				identities = append(identities, san)
+			}
+		}
+	}
+	req.Identities = identities
+	return nil
+}
+
+// EnableSANTemplates adds the SANs of the templates to the workload certificates. The SANs are added
+// after the other CSR policies of the server, which evaluate the identities of the caller only.
+func (s *Server) EnableSANTemplates(templates *SANTemplates) {
+	s.CSRPolicies = append(s.CSRPolicies, NewSANTemplateCSRPolicy(templates))
+}
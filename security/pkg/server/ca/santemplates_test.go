@@ -0,0 +1,111 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	mockca "istio.io/istio/security/pkg/pki/ca/mock"
+	mockutil "istio.io/istio/security/pkg/pki/util/mock"
+	pb "istio.io/istio/security/proto"
+)
+
+func TestParseSANTemplates(t *testing.T) {
+	got, err := ParseSANTemplates(`
+# The clients of the legacy database.
+foo/default db-client.foo.example.com
+foo/* ${serviceAccount}.foo.example.com  spiffe://legacy.example.com/${namespace}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"foo/default": {"db-client.foo.example.com"},
+		"foo/*":       {"${serviceAccount}.foo.example.com", "spiffe://legacy.example.com/${namespace}"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got SAN templates %v, want %v", got, want)
+	}
+	for _, invalid := range []string{"foo db.example.com", "foo/default", "/default db.example.com"} {
+		if _, err := ParseSANTemplates(invalid); err == nil {
+			t.Errorf("expected an error parsing %q", invalid)
+		}
+	}
+}
+
+func TestSANTemplates(t *testing.T) {
+	templates := NewSANTemplates()
+	if err := templates.Update(map[string][]string{
+		"foo/default": {"db-client.foo.example.com"},
+		"foo/*":       {"${serviceAccount}.${namespace}.example.com", "spiffe://legacy.${trustDomain}/app"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"db-client.foo.example.com", "default.foo.example.com", "spiffe://legacy.cluster.local/app"}
+	if got := templates.SANs(fooID); !reflect.DeepEqual(got, want) {
+		t.Errorf("got SANs %v, want %v", got, want)
+	}
+	if got := templates.SANs(barID); len(got) != 0 {
+		t.Errorf("got SANs %v for %s, want none", got, barID)
+	}
+
+	for _, invalid := range []map[string][]string{
+		{"foo": {"db.example.com"}},
+		{"foo/default": {"${pod}.example.com"}},
+		{"foo/default": {"10.0.0.1"}},
+		{"foo/default": {"db.example.com:5432"}},
+	} {
+		if err := templates.Update(invalid); err == nil {
+			t.Errorf("expected an error updating the templates to %v", invalid)
+		}
+	}
+	if got := templates.SANs(fooID); !reflect.DeepEqual(got, want) {
+		t.Errorf("the templates should not be changed by an invalid update, got SANs %v", got)
+	}
+}
+
+func TestCreateCertificateWithSANTemplates(t *testing.T) {
+	ca := &mockca.FakeCA{
+		SignedCert:    []byte("cert"),
+		KeyCertBundle: &mockutil.FakeKeyCertBundle{RootCertBytes: []byte("root_cert")},
+	}
+	callerIDs := []string{fooID}
+	server := &Server{
+		ca:             ca,
+		Authenticators: []authenticator{&mockAuthenticator{identities: callerIDs}},
+		// The SANs are added after the namespace policy, which only approves SPIFFE identities.
+		CSRPolicies: []CSRPolicy{NewNamespaceCSRPolicy([]string{"foo"})},
+		monitoring:  newMonitoringMetrics(),
+	}
+	templates := NewSANTemplates()
+	server.EnableSANTemplates(templates)
+	if err := templates.Update(map[string][]string{"foo/default": {"db-client.foo.example.com", fooID}}); err != nil {
+		t.Fatal(err)
+	}
+
+	request := &pb.IstioCertificateRequest{Csr: string(genCSR(t, fooID)), ValidityDuration: 3600}
+	if _, err := server.CreateCertificate(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{fooID, "db-client.foo.example.com"}; !reflect.DeepEqual(ca.ReceivedIDs, want) {
+		t.Errorf("got signed identities %v, want %v", ca.ReceivedIDs, want)
+	}
+	if !reflect.DeepEqual(callerIDs, []string{fooID}) {
+		t.Errorf("the identities of the caller should not be changed, got %v", callerIDs)
+	}
+}
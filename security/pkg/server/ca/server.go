@@ -26,6 +26,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
 
 	caerror "istio.io/istio/security/pkg/pki/error"
@@ -68,6 +69,7 @@ type CertificateAuthority interface {
 type Server struct {
 	monitoring     monitoringMetrics
 	Authenticators []authenticator
+	CSRPolicies    []CSRPolicy
 	hostnames      []string
 	authorizer     authorizer
 	ca             CertificateAuthority
@@ -76,6 +78,11 @@ type Server struct {
 	port           int
 	forCA          bool
 	grpcServer     *grpc.Server
+	subordinateCA  *SubordinateCAOptions
+	subordinates   *subordinateCARegistry
+	revocations    *RevocationList
+	trustBundle    trustBundle
+	rootDeliveries rootDeliveries
 }
 
 // CreateCertificate handles an incoming certificate signing request (CSR). It does
@@ -95,9 +102,22 @@ func (s *Server) CreateCertificate(ctx context.Context, request *pb.IstioCertifi
 
 	// TODO: Call authorizer.
 
-	_, _, certChainBytes, rootCertBytes := s.ca.GetCAKeyCertBundle().GetAll()
-	cert, signErr := s.ca.Sign(
-		[]byte(request.Csr), caller.Identities, time.Duration(request.ValidityDuration)*time.Second, false)
+	csrReq := &CSRRequest{
+		Caller:     caller,
+		CSRPEM:     []byte(request.Csr),
+		Identities: caller.Identities,
+		TTL:        time.Duration(request.ValidityDuration) * time.Second,
+	}
+	if err := s.evaluateCSRPolicies(csrReq); err != nil {
+		serverCaLog.Warnf("CSR of %v denied: %v", caller.Identities, err)
+		return nil, status.Errorf(codes.PermissionDenied, "CSR denied (%v)", err)
+	}
+
+	_, _, certChainBytes, _ := s.ca.GetCAKeyCertBundle().GetAll()
+	// The last element is the whole trust bundle, so that the SDS clients trust the roots being
+	// rotated in and out too.
+	rootCertBytes := s.TrustBundle()
+	cert, signErr := s.ca.Sign(csrReq.CSRPEM, csrReq.Identities, csrReq.TTL, false)
 	if signErr != nil {
 		serverCaLog.Errorf("CSR signing error (%v)", signErr.Error())
 		s.monitoring.GetCertSignError(signErr.(*caerror.Error).ErrorType()).Increment()
@@ -108,6 +128,7 @@ func (s *Server) CreateCertificate(ctx context.Context, request *pb.IstioCertifi
 		respCertChain = append(respCertChain, string(certChainBytes))
 	}
 	respCertChain = append(respCertChain, string(rootCertBytes))
+	s.recordRootDelivery(ctx, rootCertBytes, time.Now())
 	response := &pb.IstioCertificateResponse{
 		CertChain: respCertChain,
 	}
@@ -161,9 +182,20 @@ func (s *Server) HandleCSR(ctx context.Context, request *pb.CsrRequest) (*pb.Csr
 
 	// TODO: Call authorizer.
 
+	csrReq := &CSRRequest{
+		Caller:     caller,
+		CSRPEM:     request.CsrPem,
+		Identities: caller.Identities,
+		TTL:        time.Duration(request.RequestedTtlMinutes) * time.Minute,
+		ForCA:      s.forCA,
+	}
+	if err := s.evaluateCSRPolicies(csrReq); err != nil {
+		serverCaLog.Warnf("CSR of %v denied: %v", caller.Identities, err)
+		return nil, status.Errorf(codes.PermissionDenied, "CSR denied (%v)", err)
+	}
+
 	_, _, certChainBytes, _ := s.ca.GetCAKeyCertBundle().GetAll()
-	cert, signErr := s.ca.Sign(
-		request.CsrPem, caller.Identities, time.Duration(request.RequestedTtlMinutes)*time.Minute, s.forCA)
+	cert, signErr := s.ca.Sign(csrReq.CSRPEM, csrReq.Identities, csrReq.TTL, csrReq.ForCA)
 	if signErr != nil {
 		serverCaLog.Errorf("CSR signing error (%v)", signErr.Error())
 		s.monitoring.GetCertSignError(signErr.(*caerror.Error).ErrorType()).Increment()
@@ -200,6 +232,11 @@ func (s *Server) Run() error {
 	}
 	pb.RegisterIstioCAServiceServer(grpcServer, s)
 	pb.RegisterIstioCertificateServiceServer(grpcServer, s)
+	if s.subordinateCA != nil {
+		pb.RegisterIstioSubordinateCAServiceServer(grpcServer, s)
+	}
+	pb.RegisterIstioTrustBundleServiceServer(grpcServer, s)
+	healthpb.RegisterHealthServer(grpcServer, &healthServer{s: s})
 
 	grpc_prometheus.EnableHandlingTimeHistogram()
 	grpc_prometheus.Register(grpcServer)
@@ -288,6 +325,13 @@ func (s *Server) createTLSServerOption() grpc.ServerOption {
 	config := &tls.Config{
 		ClientCAs:  cp,
 		ClientAuth: tls.VerifyClientCertIfGiven,
+		// The handshakes of the client certificate chains including a revoked certificate fail.
+		VerifyPeerCertificate: func(_ [][]byte, chains [][]*x509.Certificate) error {
+			if serial, revoked := s.revokedCert(chains); revoked {
+				return fmt.Errorf("the client certificate chain contains the revoked certificate %s", serial)
+			}
+			return nil
+		},
 		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
 			if s.certificate == nil || shouldRefresh(s.certificate) {
 				// Apply new certificate if there isn't one yet, or the one has become invalid.
@@ -331,6 +375,12 @@ func (s *Server) getServerCertificate() (*tls.Certificate, error) {
 // and authenticates if one of them is valid.
 func (s *Server) authenticate(ctx context.Context) *authenticate.Caller {
 	// TODO: apply different authenticators in specific order / according to configuration.
+	// A client certificate chain including a revoked certificate is rejected, whatever the credentials
+	// the caller authenticates with.
+	if serial, revoked := s.revokedPeerCert(ctx); revoked {
+		serverCaLog.Warnf("Authentication failed: the client certificate chain contains the revoked certificate %s", serial)
+		return nil
+	}
 	var errMsg string
 	for id, authn := range s.Authenticators {
 		u, err := authn.Authenticate(ctx)
@@ -0,0 +1,311 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto/x509"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/util"
+	"istio.io/istio/security/pkg/server/ca/authenticate"
+	pb "istio.io/istio/security/proto"
+)
+
+// SubordinateCAOptions configures the issuance of subordinate CA certificates to the istiods of remote
+// clusters, which sign the certificates of their workloads without sharing the root key.
+type SubordinateCAOptions struct {
+	// Identities are the identities allowed to request and revoke subordinate CA certificates.
+	Identities []string
+	// MaxPathLen is the path length constraint of the subordinate CA certificates, the number of
+	// intermediate CAs allowed below them.
+	MaxPathLen int
+	// TTL is the TTL of the subordinate CA certificates. Requests may ask for a lower TTL.
+	TTL time.Duration
+	// Store persists the subordinate CA certificates issued and revoked. They are only kept in memory,
+	// and the revocations don't survive the restarts of the CA, if nil.
+	Store SubordinateCAStore
+}
+
+// SubordinateCARecord is a subordinate CA certificate issued by the CA.
+type SubordinateCARecord struct {
+	// SerialNumber is the hexadecimal serial number of the certificate.
+	SerialNumber string `json:"serialNumber"`
+	// Cluster is the cluster the certificate was requested for.
+	Cluster string `json:"cluster,omitempty"`
+	// Identities are the identities of the caller which requested the certificate.
+	Identities []string  `json:"identities,omitempty"`
+	NotAfter   time.Time `json:"notAfter,omitempty"`
+	Revoked    bool      `json:"revoked,omitempty"`
+	// RevocationTime is the time the certificate was revoked at, listed in the CRL of the CA.
+	RevocationTime time.Time `json:"revocationTime,omitempty"`
+}
+
+// SubordinateCAStore persists the subordinate CA records, so that the revocations survive the restarts
+// of the CA and are shared by its replicas.
+type SubordinateCAStore interface {
+	// Save merges the record into the persisted records, as MergeSubordinateCARecord.
+	Save(record SubordinateCARecord) error
+}
+
+// MergeSubordinateCARecord merges the record into the records, keyed by serial number. A revocation is
+// never undone, and the earliest revocation time is kept.
+func MergeSubordinateCARecord(records map[string]*SubordinateCARecord, record SubordinateCARecord) *SubordinateCARecord {
+	merged := record
+	if existing, found := records[record.SerialNumber]; found {
+		if merged.Cluster == "" && len(merged.Identities) == 0 {
+			merged.Cluster, merged.Identities, merged.NotAfter = existing.Cluster, existing.Identities, existing.NotAfter
+		}
+		if existing.Revoked && (!merged.Revoked || existing.RevocationTime.Before(merged.RevocationTime)) {
+			merged.Revoked, merged.RevocationTime = true, existing.RevocationTime
+		}
+	}
+	records[record.SerialNumber] = &merged
+	return &merged
+}
+
+// subordinateCARegistry tracks the subordinate CA certificates issued by the CA and the revoked ones.
+// It is kept in memory, and updated with the records persisted by the SubordinateCAStore, which may be
+// issued or revoked by the other replicas of the CA.
+type subordinateCARegistry struct {
+	mutex   sync.RWMutex
+	records map[string]*SubordinateCARecord
+}
+
+func newSubordinateCARegistry() *subordinateCARegistry {
+	return &subordinateCARegistry{records: map[string]*SubordinateCARecord{}}
+}
+
+// merge merges the records into the registry, and returns the last one as merged.
+func (r *subordinateCARegistry) merge(records ...SubordinateCARecord) SubordinateCARecord {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var merged *SubordinateCARecord
+	for _, record := range records {
+		merged = MergeSubordinateCARecord(r.records, record)
+	}
+	return *merged
+}
+
+// revoke revokes the certificate with the given serial number, even if it wasn't issued since the
+// CA started, and returns its record.
+func (r *subordinateCARegistry) revoke(serial string, now time.Time) SubordinateCARecord {
+	return r.merge(SubordinateCARecord{SerialNumber: serial, Revoked: true, RevocationTime: now})
+}
+
+func (r *subordinateCARegistry) isRevoked(serial string) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	record, found := r.records[serial]
+	return found && record.Revoked
+}
+
+func (r *subordinateCARegistry) list() []SubordinateCARecord {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	out := make([]SubordinateCARecord, 0, len(r.records))
+	for _, record := range r.records {
+		out = append(out, *record)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].SerialNumber < out[j].SerialNumber
+	})
+	return out
+}
+
+// subordinateCASigner is implemented by the CAs able to sign subordinate CA certificates.
+type subordinateCASigner interface {
+	SignSubordinateCA(csrPEM []byte, subjectIDs []string, ttl time.Duration, maxPathLen int) ([]byte, error)
+}
+
+// serialNumber returns the hexadecimal serial number of a certificate, as tracked by the registry.
+func serialNumber(cert *x509.Certificate) string {
+	return cert.SerialNumber.Text(16)
+}
+
+// EnableSubordinateCA enables the issuance of subordinate CA certificates, served by Run with the
+// other services of the server.
+func (s *Server) EnableSubordinateCA(opts SubordinateCAOptions) {
+	s.subordinateCA = &opts
+	s.subordinates = newSubordinateCARegistry()
+}
+
+// UpdateSubordinateCAs merges the subordinate CA certificates persisted by the SubordinateCAStore,
+// issued or revoked by any replica of the CA, into the ones known to the server.
+func (s *Server) UpdateSubordinateCAs(records []SubordinateCARecord) {
+	if s.subordinates == nil || len(records) == 0 {
+		return
+	}
+	s.subordinates.merge(records...)
+}
+
+// SubordinateCAs returns the subordinate CA certificates issued or revoked, including the persisted ones.
+func (s *Server) SubordinateCAs() []SubordinateCARecord {
+	if s.subordinates == nil {
+		return nil
+	}
+	return s.subordinates.list()
+}
+
+// saveSubordinateCA persists the record with the store of the options, if any.
+func (s *Server) saveSubordinateCA(record SubordinateCARecord) error {
+	if s.subordinateCA.Store == nil {
+		return nil
+	}
+	return s.subordinateCA.Store.Save(record)
+}
+
+// authorizeSubordinateCA authenticates the caller of a subordinate CA request, which must have one of
+// the identities allowed by the SubordinateCAOptions.
+func (s *Server) authorizeSubordinateCA(ctx context.Context) (*authenticate.Caller, error) {
+	if s.subordinateCA == nil {
+		return nil, status.Error(codes.Unimplemented, "subordinate CAs are not enabled")
+	}
+	caller := s.authenticate(ctx)
+	if caller == nil {
+		s.monitoring.AuthnError.Increment()
+		return nil, status.Error(codes.Unauthenticated, "request authenticate failure")
+	}
+	for _, id := range caller.Identities {
+		for _, allowed := range s.subordinateCA.Identities {
+			if id == allowed {
+				return caller, nil
+			}
+		}
+	}
+	serverCaLog.Warnf("%v is not allowed to manage subordinate CAs", caller.Identities)
+	return nil, status.Errorf(codes.PermissionDenied, "%v is not allowed to manage subordinate CAs", caller.Identities)
+}
+
+// CreateSubordinateCA signs a subordinate CA certificate from the CSR of the request, for the istiod of
+// the cluster named by the SubjectId of the request. The response holds the certificate chain, the
+// root cert being the last element.
+func (s *Server) CreateSubordinateCA(ctx context.Context, request *pb.IstioCertificateRequest) (
+	*pb.IstioCertificateResponse, error) {
+	caller, err := s.authorizeSubordinateCA(ctx)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := s.ca.(subordinateCASigner)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "the CA doesn't sign subordinate CA certificates")
+	}
+
+	ttl := s.subordinateCA.TTL
+	if requested := time.Duration(request.ValidityDuration) * time.Second; requested > 0 && requested < ttl {
+		ttl = requested
+	}
+	_, _, certChainBytes, rootCertBytes := s.ca.GetCAKeyCertBundle().GetAll()
+	certPEM, signErr := signer.SignSubordinateCA([]byte(request.Csr), caller.Identities, ttl, s.subordinateCA.MaxPathLen)
+	if signErr != nil {
+		serverCaLog.Errorf("subordinate CA signing error (%v)", signErr.Error())
+		s.monitoring.GetCertSignError(signErr.(*caerror.Error).ErrorType()).Increment()
+		return nil, status.Errorf(signErr.(*caerror.Error).HTTPErrorCode(), "CSR signing error (%v)", signErr.(*caerror.Error))
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to parse the subordinate CA certificate (%v)", err)
+	}
+
+	record := SubordinateCARecord{
+		SerialNumber: serialNumber(cert),
+		Cluster:      request.SubjectId,
+		Identities:   caller.Identities,
+		NotAfter:     cert.NotAfter,
+	}
+	// The certificate is not returned unless it is persisted, so that it can be revoked after a restart.
+	if err := s.saveSubordinateCA(record); err != nil {
+		serverCaLog.Errorf("failed to persist the subordinate CA certificate %s: %v", record.SerialNumber, err)
+		return nil, status.Errorf(codes.Unavailable, "failed to persist the subordinate CA certificate (%v)", err)
+	}
+	s.subordinates.merge(record)
+	serverCaLog.Infof("Issued subordinate CA certificate %s for cluster %q to %v, valid until %v",
+		record.SerialNumber, record.Cluster, record.Identities, record.NotAfter)
+
+	respCertChain := []string{string(certPEM)}
+	if len(certChainBytes) != 0 {
+		respCertChain = append(respCertChain, string(certChainBytes))
+	}
+	respCertChain = append(respCertChain, string(rootCertBytes))
+	return &pb.IstioCertificateResponse{CertChain: respCertChain}, nil
+}
+
+// RevokeSubordinateCA revokes the subordinate CA certificate with the given hexadecimal serial number:
+// the CA server rejects the client certificate chains including it.
+func (s *Server) RevokeSubordinateCA(ctx context.Context, request *pb.RevokeSubordinateCARequest) (
+	*pb.RevokeSubordinateCAResponse, error) {
+	caller, err := s.authorizeSubordinateCA(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n, ok := new(big.Int).SetString(request.SerialNumber, 16)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid serial number %q, expecting a hexadecimal number",
+			request.SerialNumber)
+	}
+	// The revocation is enforced by this replica right away, even if it fails to be persisted.
+	record := s.subordinates.revoke(n.Text(16), time.Now())
+	if err := s.saveSubordinateCA(record); err != nil {
+		serverCaLog.Errorf("failed to persist the revocation of the subordinate CA certificate %s: %v",
+			record.SerialNumber, err)
+		return nil, status.Errorf(codes.Unavailable, "failed to persist the revocation (%v)", err)
+	}
+	serverCaLog.Infof("Subordinate CA certificate %s revoked by %v", record.SerialNumber, caller.Identities)
+	return &pb.RevokeSubordinateCAResponse{}, nil
+}
+
+// revokedPeerCert returns the serial number of the revoked certificate in the verified client
+// certificate chain of the request, either a subordinate CA certificate or a workload certificate, if
+// any.
+func (s *Server) revokedPeerCert(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.AuthInfo == nil {
+		return "", false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return "", false
+	}
+	return s.revokedCert(tlsInfo.State.VerifiedChains)
+}
+
+// revokedCert returns the serial number of the revoked certificate in the verified certificate chains,
+// if any.
+func (s *Server) revokedCert(chains [][]*x509.Certificate) (string, bool) {
+	if s.subordinates == nil && s.revocations == nil {
+		return "", false
+	}
+	for _, chain := range chains {
+		for _, cert := range chain {
+			serial := serialNumber(cert)
+			if s.subordinates != nil && s.subordinates.isRevoked(serial) {
+				return serial, true
+			}
+			if s.revocations != nil && s.revocations.IsCertificateRevoked(serial) {
+				return serial, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,367 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	mockca "istio.io/istio/security/pkg/pki/ca/mock"
+	"istio.io/istio/security/pkg/pki/util"
+	mockutil "istio.io/istio/security/pkg/pki/util/mock"
+	"istio.io/istio/security/pkg/server/ca/authenticate"
+	pb "istio.io/istio/security/proto"
+)
+
+const remoteIstiodID = "spiffe://cluster.local/ns/istio-system/sa/istio-remote-ca"
+
+// fakeSubordinateCA signs subordinate CA certificates with a self-signed root.
+type fakeSubordinateCA struct {
+	mockca.FakeCA
+	rootCert           *x509.Certificate
+	rootKey            interface{}
+	receivedTTL        time.Duration
+	receivedMaxPathLen int
+}
+
+func newFakeSubordinateCA(t *testing.T) *fakeSubordinateCA {
+	t.Helper()
+	rootCertPEM, rootKeyPEM, err := util.GenCertKeyFromOptions(util.CertOptions{
+		IsCA:         true,
+		IsSelfSigned: true,
+		TTL:          time.Hour,
+		Org:          "Root CA",
+		RSAKeySize:   1024,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootCert, err := util.ParsePemEncodedCertificate(rootCertPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootKey, err := util.ParsePemEncodedKey(rootKeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeSubordinateCA{
+		FakeCA: mockca.FakeCA{
+			KeyCertBundle: &mockutil.FakeKeyCertBundle{RootCertBytes: rootCertPEM},
+		},
+		rootCert: rootCert,
+		rootKey:  rootKey,
+	}
+}
+
+func (ca *fakeSubordinateCA) SignSubordinateCA(csrPEM []byte, subjectIDs []string, ttl time.Duration,
+	maxPathLen int) ([]byte, error) {
+	ca.receivedTTL = ttl
+	ca.receivedMaxPathLen = maxPathLen
+	csr, err := util.ParsePemEncodedCSR(csrPEM)
+	if err != nil {
+		return nil, err
+	}
+	der, err := util.GenSubordinateCACertFromCSR(csr, ca.rootCert, csr.PublicKey, ca.rootKey, subjectIDs, ttl, maxPathLen)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+func TestCreateSubordinateCA(t *testing.T) {
+	testCases := map[string]struct {
+		identities     []string
+		validity       int64
+		code           codes.Code
+		expectedTTL    time.Duration
+		expectedRecord bool
+	}{
+		"allowed": {
+			identities:     []string{remoteIstiodID},
+			code:           codes.OK,
+			expectedTTL:    time.Hour,
+			expectedRecord: true,
+		},
+		"lower TTL": {
+			identities:     []string{remoteIstiodID},
+			validity:       60,
+			code:           codes.OK,
+			expectedTTL:    time.Minute,
+			expectedRecord: true,
+		},
+		"not allowed": {
+			identities: []string{fooID},
+			code:       codes.PermissionDenied,
+		},
+	}
+
+	for id, tc := range testCases {
+		ca := newFakeSubordinateCA(t)
+		server := &Server{
+			ca:             ca,
+			Authenticators: []authenticator{&mockAuthenticator{identities: tc.identities}},
+			monitoring:     newMonitoringMetrics(),
+		}
+		server.EnableSubordinateCA(SubordinateCAOptions{
+			Identities: []string{remoteIstiodID},
+			MaxPathLen: 1,
+			TTL:        time.Hour,
+		})
+
+		csr, _, err := util.GenCSR(util.CertOptions{RSAKeySize: 1024, IsCA: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := server.CreateSubordinateCA(context.Background(),
+			&pb.IstioCertificateRequest{Csr: string(csr), SubjectId: "remote", ValidityDuration: tc.validity})
+		if code := status.Code(err); code != tc.code {
+			t.Errorf("%s: got code %v, expected %v: %v", id, code, tc.code, err)
+			continue
+		}
+		if !tc.expectedRecord {
+			if len(server.SubordinateCAs()) != 0 {
+				t.Errorf("%s: unexpected subordinate CAs %v", id, server.SubordinateCAs())
+			}
+			continue
+		}
+
+		if len(resp.CertChain) != 2 {
+			t.Fatalf("%s: got %d certs, expected the subordinate CA cert and the root cert", id, len(resp.CertChain))
+		}
+		if ca.receivedTTL != tc.expectedTTL || ca.receivedMaxPathLen != 1 {
+			t.Errorf("%s: signed with TTL %v and path length %d, expected %v and 1",
+				id, ca.receivedTTL, ca.receivedMaxPathLen, tc.expectedTTL)
+		}
+		records := server.SubordinateCAs()
+		if len(records) != 1 || records[0].Cluster != "remote" || records[0].Revoked {
+			t.Errorf("%s: unexpected subordinate CAs %v", id, records)
+		}
+	}
+}
+
+// fakeSubordinateCAStore records the saved subordinate CA records, or fails to save them.
+type fakeSubordinateCAStore struct {
+	records map[string]*SubordinateCARecord
+	err     error
+}
+
+func (s *fakeSubordinateCAStore) Save(record SubordinateCARecord) error {
+	if s.err != nil {
+		return s.err
+	}
+	MergeSubordinateCARecord(s.records, record)
+	return nil
+}
+
+func TestRevokeSubordinateCA(t *testing.T) {
+	ca := newFakeSubordinateCA(t)
+	store := &fakeSubordinateCAStore{records: map[string]*SubordinateCARecord{}}
+	server := &Server{
+		ca: ca,
+		// The revocations are enforced whatever the credentials of the caller, not only the client certificates.
+		Authenticators: []authenticator{&mockAuthenticator{identities: []string{remoteIstiodID},
+			authSource: authenticate.AuthSourceIDToken}},
+		monitoring: newMonitoringMetrics(),
+	}
+	server.EnableSubordinateCA(SubordinateCAOptions{Identities: []string{remoteIstiodID}, TTL: time.Hour, Store: store})
+
+	csr, _, err := util.GenCSR(util.CertOptions{RSAKeySize: 1024, IsCA: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := server.CreateSubordinateCA(context.Background(), &pb.IstioCertificateRequest{Csr: string(csr)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subordinateCert, err := util.ParsePemEncodedCertificate([]byte(resp.CertChain[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The clients presenting a chain of the subordinate CA are authenticated until it is revoked.
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{subordinateCert, ca.rootCert}},
+		}},
+	})
+	if server.authenticate(ctx) == nil {
+		t.Fatal("the client should be authenticated")
+	}
+
+	serial := serialNumber(subordinateCert)
+	if _, err := server.RevokeSubordinateCA(context.Background(),
+		&pb.RevokeSubordinateCARequest{SerialNumber: "0" + serial}); err != nil {
+		t.Fatal(err)
+	}
+	records := server.SubordinateCAs()
+	if len(records) != 1 || !records[0].Revoked || records[0].SerialNumber != serial {
+		t.Errorf("the subordinate CA should be revoked: %v", records)
+	}
+	if persisted := store.records[serial]; persisted == nil || !reflect.DeepEqual(*persisted, records[0]) {
+		t.Errorf("got persisted record %v, expected %v", persisted, records[0])
+	}
+	if server.authenticate(ctx) != nil {
+		t.Error("the client should not be authenticated once the subordinate CA is revoked")
+	}
+	if _, revoked := server.revokedCert([][]*x509.Certificate{{subordinateCert, ca.rootCert}}); !revoked {
+		t.Error("the TLS handshakes of the chains of the subordinate CA should fail once it is revoked")
+	}
+
+	for _, serial := range []string{"", "xyz"} {
+		if _, err := server.RevokeSubordinateCA(context.Background(),
+			&pb.RevokeSubordinateCARequest{SerialNumber: serial}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("got %v for serial number %q, expected an invalid argument error", err, serial)
+		}
+	}
+
+	// A revocation which fails to be persisted is still enforced by the replica.
+	store.err = fmt.Errorf("conflict")
+	if _, err := server.RevokeSubordinateCA(context.Background(),
+		&pb.RevokeSubordinateCARequest{SerialNumber: "a1b"}); status.Code(err) != codes.Unavailable {
+		t.Errorf("got %v, expected an unavailable error", err)
+	}
+	if _, revoked := server.revokedCert([][]*x509.Certificate{{{SerialNumber: big.NewInt(0xa1b)}}}); !revoked {
+		t.Error("the certificate a1b should be revoked")
+	}
+}
+
+func TestSubordinateCAPersistence(t *testing.T) {
+	ca := newFakeSubordinateCA(t)
+	store := &fakeSubordinateCAStore{records: map[string]*SubordinateCARecord{}}
+	server := &Server{
+		ca:             ca,
+		Authenticators: []authenticator{&mockAuthenticator{identities: []string{remoteIstiodID}}},
+		monitoring:     newMonitoringMetrics(),
+	}
+	server.EnableSubordinateCA(SubordinateCAOptions{Identities: []string{remoteIstiodID}, TTL: time.Hour, Store: store})
+
+	csr, _, err := util.GenCSR(util.CertOptions{RSAKeySize: 1024, IsCA: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.CreateSubordinateCA(context.Background(),
+		&pb.IstioCertificateRequest{Csr: string(csr), SubjectId: "remote"}); err != nil {
+		t.Fatal(err)
+	}
+	records := server.SubordinateCAs()
+	if len(records) != 1 || store.records[records[0].SerialNumber] == nil {
+		t.Fatalf("got persisted records %v, expected %v", store.records, records)
+	}
+
+	// The certificates which can't be persisted are not issued.
+	store.err = fmt.Errorf("conflict")
+	if _, err := server.CreateSubordinateCA(context.Background(),
+		&pb.IstioCertificateRequest{Csr: string(csr), SubjectId: "other"}); status.Code(err) != codes.Unavailable {
+		t.Errorf("got %v, expected an unavailable error", err)
+	}
+	if got := server.SubordinateCAs(); len(got) != 1 {
+		t.Errorf("got subordinate CAs %v, expected only %v", got, records)
+	}
+
+	// The records persisted by the other replicas are merged, their revocations are enforced.
+	revocationTime := time.Now()
+	server.UpdateSubordinateCAs([]SubordinateCARecord{
+		{SerialNumber: records[0].SerialNumber, Revoked: true, RevocationTime: revocationTime},
+		{SerialNumber: "a1b", Cluster: "other", Revoked: true, RevocationTime: revocationTime},
+	})
+	got := map[string]SubordinateCARecord{}
+	for _, record := range server.SubordinateCAs() {
+		got[record.SerialNumber] = record
+	}
+	if issued := got[records[0].SerialNumber]; len(got) != 2 || !issued.Revoked || issued.Cluster != "remote" ||
+		!got["a1b"].Revoked {
+		t.Errorf("got subordinate CAs %v, expected both to be revoked", got)
+	}
+}
+
+func TestMergeSubordinateCARecord(t *testing.T) {
+	t1 := time.Unix(1000, 0)
+	t2 := time.Unix(2000, 0)
+	records := map[string]*SubordinateCARecord{}
+	MergeSubordinateCARecord(records, SubordinateCARecord{SerialNumber: "a1", Cluster: "remote", NotAfter: t2})
+	MergeSubordinateCARecord(records, SubordinateCARecord{SerialNumber: "a1", Revoked: true, RevocationTime: t2})
+	MergeSubordinateCARecord(records, SubordinateCARecord{SerialNumber: "a1", Revoked: true, RevocationTime: t1})
+	// A revocation is never undone.
+	MergeSubordinateCARecord(records, SubordinateCARecord{SerialNumber: "a1", Cluster: "remote", NotAfter: t2})
+
+	expected := SubordinateCARecord{SerialNumber: "a1", Cluster: "remote", NotAfter: t2, Revoked: true, RevocationTime: t1}
+	if !reflect.DeepEqual(*records["a1"], expected) {
+		t.Errorf("got %v, expected %v", *records["a1"], expected)
+	}
+}
+
+func TestSubordinateCAService(t *testing.T) {
+	ca := newFakeSubordinateCA(t)
+	grpcServer := grpc.NewServer()
+	server := &Server{
+		ca:             ca,
+		Authenticators: []authenticator{&mockAuthenticator{identities: []string{remoteIstiodID}}},
+		monitoring:     newMonitoringMetrics(),
+		grpcServer:     grpcServer,
+	}
+	server.EnableSubordinateCA(SubordinateCAOptions{Identities: []string{remoteIstiodID}, TTL: time.Hour})
+	if err := server.Run(); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = grpcServer.Serve(listener)
+	}()
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewIstioSubordinateCAServiceClient(conn)
+
+	csr, _, err := util.GenCSR(util.CertOptions{RSAKeySize: 1024, IsCA: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.CreateSubordinateCA(context.Background(), &pb.IstioCertificateRequest{Csr: string(csr), SubjectId: "remote"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := util.ParsePemEncodedCertificate([]byte(resp.CertChain[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.RevokeSubordinateCA(context.Background(),
+		&pb.RevokeSubordinateCARequest{SerialNumber: serialNumber(cert)}); err != nil {
+		t.Fatal(err)
+	}
+	if records := server.SubordinateCAs(); len(records) != 1 || records[0].Cluster != "remote" || !records[0].Revoked {
+		t.Errorf("unexpected subordinate CAs %v", records)
+	}
+}
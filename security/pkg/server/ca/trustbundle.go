@@ -0,0 +1,155 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "istio.io/istio/security/proto"
+)
+
+// trustBundle tracks the roots of the CA. The roots replaced by a root cert rotation are kept until
+// they expire, so that the certificates they signed are still trusted while the workloads rotate.
+// The zero value is an empty trust bundle.
+type trustBundle struct {
+	mutex sync.Mutex
+	// previous are the roots no longer in the KeyCertBundle of the CA, keyed by SHA-256 fingerprint.
+	previous map[[sha256.Size]byte]*x509.Certificate
+	// current are the roots last seen in the KeyCertBundle, keyed by SHA-256 fingerprint.
+	current map[[sha256.Size]byte]*x509.Certificate
+}
+
+// parseRoots returns the certificates of a PEM bundle.
+func parseRoots(rootPEM []byte) ([]*x509.Certificate, error) {
+	var roots []*x509.Certificate
+	for rest := rootPEM; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse root cert: %v", err)
+		}
+		roots = append(roots, cert)
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("no root cert found")
+	}
+	return roots, nil
+}
+
+// update records the current roots of the CA, and returns the previous roots still valid.
+func (tb *trustBundle) update(rootPEM []byte, now time.Time) []*x509.Certificate {
+	roots, err := parseRoots(rootPEM)
+	if err != nil {
+		serverCaLog.Errorf("Failed to parse the roots of the CA: %v", err)
+	}
+	current := make(map[[sha256.Size]byte]*x509.Certificate, len(roots))
+	for _, root := range roots {
+		current[sha256.Sum256(root.Raw)] = root
+	}
+
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	if tb.previous == nil {
+		tb.previous = map[[sha256.Size]byte]*x509.Certificate{}
+	}
+	for fp, root := range tb.current {
+		if _, f := current[fp]; !f {
+			serverCaLog.Infof("Root cert %s rotated out, trusted until %v", serialNumber(root), root.NotAfter)
+			tb.previous[fp] = root
+		}
+	}
+	// A root may be rotated in again.
+	for fp := range current {
+		delete(tb.previous, fp)
+	}
+	tb.current = current
+
+	var previous []*x509.Certificate
+	for fp, root := range tb.previous {
+		if now.After(root.NotAfter) {
+			delete(tb.previous, fp)
+			continue
+		}
+		previous = append(previous, root)
+	}
+	// Keep a stable order, the oldest first.
+	sort.Slice(previous, func(i, j int) bool {
+		return previous[i].NotBefore.Before(previous[j].NotBefore)
+	})
+	return previous
+}
+
+// TrustBundle returns the PEM encoded roots trusted by the workloads of the CA: the roots of its
+// KeyCertBundle, including the roots being rotated in, followed by the roots rotated out which are
+// not expired yet.
+func (s *Server) TrustBundle() []byte {
+	rootPEM := s.ca.GetCAKeyCertBundle().GetRootCertPem()
+	previous := s.trustBundle.update(rootPEM, time.Now())
+	if len(previous) == 0 {
+		return rootPEM
+	}
+	bundle := bytes.NewBufferString(strings.TrimSuffix(string(rootPEM), "\n") + "\n")
+	for _, root := range previous {
+		_ = pem.Encode(bundle, &pem.Block{Type: "CERTIFICATE", Bytes: root.Raw})
+	}
+	return bundle.Bytes()
+}
+
+// TrustBundleHandler serves the trust bundle of the CA over HTTP, as PEM.
+func (s *Server) TrustBundleHandler(w http.ResponseWriter, _ *http.Request) {
+	bundle := s.TrustBundle()
+	if len(bundle) == 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("the root cert of the CA is not loaded\n"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(bundle)
+}
+
+// GetTrustBundle returns the roots of the trust bundle of the CA, one PEM certificate per element.
+// The trust bundle is public, the requests aren't authenticated.
+func (s *Server) GetTrustBundle(ctx context.Context, _ *pb.IstioTrustBundleRequest) (*pb.IstioTrustBundleResponse, error) {
+	roots, err := parseRoots(s.TrustBundle())
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "invalid trust bundle (%v)", err)
+	}
+	resp := &pb.IstioTrustBundleResponse{}
+	for _, root := range roots {
+		resp.Roots = append(resp.Roots, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw})))
+	}
+	return resp, nil
+}
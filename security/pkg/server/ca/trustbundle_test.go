@@ -0,0 +1,153 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	mockca "istio.io/istio/security/pkg/pki/ca/mock"
+	"istio.io/istio/security/pkg/pki/util"
+	mockutil "istio.io/istio/security/pkg/pki/util/mock"
+	pb "istio.io/istio/security/proto"
+)
+
+func genRootCert(t *testing.T, org string, ttl time.Duration) []byte {
+	t.Helper()
+	rootCertPEM, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+		IsCA:         true,
+		IsSelfSigned: true,
+		TTL:          ttl,
+		Org:          org,
+		RSAKeySize:   1024,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rootCertPEM
+}
+
+func TestTrustBundle(t *testing.T) {
+	oldRoot := genRootCert(t, "old", 2*time.Hour)
+	expiringRoot := genRootCert(t, "expiring", 30*time.Minute)
+	newRoot := genRootCert(t, "new", time.Hour)
+	bundle := &mockutil.FakeKeyCertBundle{RootCertBytes: append(append([]byte{}, oldRoot...), expiringRoot...)}
+	server := &Server{ca: &mockca.FakeCA{KeyCertBundle: bundle}}
+
+	if got := server.TrustBundle(); string(got) != string(oldRoot)+string(expiringRoot) {
+		t.Errorf("got trust bundle %s, expected the roots of the CA", got)
+	}
+
+	// The roots rotated out are kept until they expire.
+	bundle.RootCertBytes = newRoot
+	if got := server.TrustBundle(); string(got) != string(newRoot)+string(oldRoot)+string(expiringRoot) &&
+		string(got) != string(newRoot)+string(expiringRoot)+string(oldRoot) {
+		t.Errorf("got trust bundle %s, expected the new root followed by the old roots", got)
+	}
+	roots, err := parseRoots(server.TrustBundle())
+	if err != nil || len(roots) != 3 {
+		t.Fatalf("got %d roots (%v), expected 3", len(roots), err)
+	}
+
+	if previous := server.trustBundle.update(newRoot, time.Now().Add(time.Hour)); len(previous) != 1 ||
+		previous[0].Subject.Organization[0] != "old" {
+		t.Errorf("got previous roots %v, expected the old root only", previous)
+	}
+
+	// A root rotated in again is current.
+	bundle.RootCertBytes = oldRoot
+	if got := server.TrustBundle(); string(got) != string(oldRoot)+string(newRoot) {
+		t.Errorf("got trust bundle %s, expected the old root followed by the new root", got)
+	}
+}
+
+func TestTrustBundleHandler(t *testing.T) {
+	root := genRootCert(t, "root", time.Hour)
+	testCases := map[string]struct {
+		root         []byte
+		expectedCode int
+		expectedBody string
+	}{
+		"served": {
+			root:         root,
+			expectedCode: http.StatusOK,
+			expectedBody: string(root),
+		},
+		"no root": {
+			expectedCode: http.StatusServiceUnavailable,
+			expectedBody: "the root cert of the CA is not loaded\n",
+		},
+	}
+
+	for id, tc := range testCases {
+		server := &Server{ca: &mockca.FakeCA{KeyCertBundle: &mockutil.FakeKeyCertBundle{RootCertBytes: tc.root}}}
+		w := httptest.NewRecorder()
+		server.TrustBundleHandler(w, httptest.NewRequest("GET", "/ca/trustbundle", nil))
+		resp := w.Result()
+		body, _ := ioutil.ReadAll(resp.Body)
+		if resp.StatusCode != tc.expectedCode || string(body) != tc.expectedBody {
+			t.Errorf("%s: got %d %q, expected %d %q", id, resp.StatusCode, body, tc.expectedCode, tc.expectedBody)
+		}
+	}
+}
+
+func TestTrustBundleService(t *testing.T) {
+	oldRoot := genRootCert(t, "old", time.Hour)
+	newRoot := genRootCert(t, "new", time.Hour)
+	bundle := &mockutil.FakeKeyCertBundle{RootCertBytes: oldRoot}
+	grpcServer := grpc.NewServer()
+	server := &Server{
+		ca:         &mockca.FakeCA{KeyCertBundle: bundle},
+		monitoring: newMonitoringMetrics(),
+		grpcServer: grpcServer,
+	}
+	if err := server.Run(); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = grpcServer.Serve(listener)
+	}()
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewIstioTrustBundleServiceClient(conn)
+
+	if _, err := client.GetTrustBundle(context.Background(), &pb.IstioTrustBundleRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	bundle.RootCertBytes = newRoot
+	resp, err := client.GetTrustBundle(context.Background(), &pb.IstioTrustBundleRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roots := resp.Roots; len(roots) != 2 || roots[0] != string(newRoot) || roots[1] != string(oldRoot) {
+		t.Errorf("got roots %v, expected the new root and the old root", resp.Roots)
+	}
+}
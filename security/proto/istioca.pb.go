@@ -137,34 +137,210 @@ func (m *IstioCertificateResponse) GetCertChain() []string {
 	return nil
 }
 
+// Request to revoke a subordinate CA certificate.
+type RevokeSubordinateCARequest struct {
+	// Hexadecimal serial number of the subordinate CA certificate.
+	SerialNumber string `protobuf:"bytes,1,opt,name=serial_number,json=serialNumber,proto3" json:"serial_number,omitempty"`
+}
+
+func (m *RevokeSubordinateCARequest) Reset()      { *m = RevokeSubordinateCARequest{} }
+func (*RevokeSubordinateCARequest) ProtoMessage() {}
+func (*RevokeSubordinateCARequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9eff2d2b4471d6ff, []int{2}
+}
+func (m *RevokeSubordinateCARequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *RevokeSubordinateCARequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_RevokeSubordinateCARequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *RevokeSubordinateCARequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevokeSubordinateCARequest.Merge(m, src)
+}
+func (m *RevokeSubordinateCARequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *RevokeSubordinateCARequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevokeSubordinateCARequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevokeSubordinateCARequest proto.InternalMessageInfo
+
+func (m *RevokeSubordinateCARequest) GetSerialNumber() string {
+	if m != nil {
+		return m.SerialNumber
+	}
+	return ""
+}
+
+// Response to the revocation of a subordinate CA certificate.
+type RevokeSubordinateCAResponse struct {
+}
+
+func (m *RevokeSubordinateCAResponse) Reset()      { *m = RevokeSubordinateCAResponse{} }
+func (*RevokeSubordinateCAResponse) ProtoMessage() {}
+func (*RevokeSubordinateCAResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9eff2d2b4471d6ff, []int{3}
+}
+func (m *RevokeSubordinateCAResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *RevokeSubordinateCAResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_RevokeSubordinateCAResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *RevokeSubordinateCAResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevokeSubordinateCAResponse.Merge(m, src)
+}
+func (m *RevokeSubordinateCAResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *RevokeSubordinateCAResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevokeSubordinateCAResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevokeSubordinateCAResponse proto.InternalMessageInfo
+
+// Trust bundle request message.
+type IstioTrustBundleRequest struct {
+}
+
+func (m *IstioTrustBundleRequest) Reset()      { *m = IstioTrustBundleRequest{} }
+func (*IstioTrustBundleRequest) ProtoMessage() {}
+func (*IstioTrustBundleRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9eff2d2b4471d6ff, []int{4}
+}
+func (m *IstioTrustBundleRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *IstioTrustBundleRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_IstioTrustBundleRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *IstioTrustBundleRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_IstioTrustBundleRequest.Merge(m, src)
+}
+func (m *IstioTrustBundleRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *IstioTrustBundleRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_IstioTrustBundleRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_IstioTrustBundleRequest proto.InternalMessageInfo
+
+// Trust bundle response message.
+type IstioTrustBundleResponse struct {
+	// PEM-encoded roots trusted by the workloads of the CA, one certificate per
+	// element.
+	Roots []string `protobuf:"bytes,1,rep,name=roots,proto3" json:"roots,omitempty"`
+}
+
+func (m *IstioTrustBundleResponse) Reset()      { *m = IstioTrustBundleResponse{} }
+func (*IstioTrustBundleResponse) ProtoMessage() {}
+func (*IstioTrustBundleResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9eff2d2b4471d6ff, []int{5}
+}
+func (m *IstioTrustBundleResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *IstioTrustBundleResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_IstioTrustBundleResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *IstioTrustBundleResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_IstioTrustBundleResponse.Merge(m, src)
+}
+func (m *IstioTrustBundleResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *IstioTrustBundleResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_IstioTrustBundleResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_IstioTrustBundleResponse proto.InternalMessageInfo
+
+func (m *IstioTrustBundleResponse) GetRoots() []string {
+	if m != nil {
+		return m.Roots
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*IstioCertificateRequest)(nil), "istio.v1.auth.IstioCertificateRequest")
 	proto.RegisterType((*IstioCertificateResponse)(nil), "istio.v1.auth.IstioCertificateResponse")
+	proto.RegisterType((*RevokeSubordinateCARequest)(nil), "istio.v1.auth.RevokeSubordinateCARequest")
+	proto.RegisterType((*RevokeSubordinateCAResponse)(nil), "istio.v1.auth.RevokeSubordinateCAResponse")
+	proto.RegisterType((*IstioTrustBundleRequest)(nil), "istio.v1.auth.IstioTrustBundleRequest")
+	proto.RegisterType((*IstioTrustBundleResponse)(nil), "istio.v1.auth.IstioTrustBundleResponse")
 }
 
 func init() { proto.RegisterFile("security/proto/istioca.proto", fileDescriptor_9eff2d2b4471d6ff) }
 
 var fileDescriptor_9eff2d2b4471d6ff = []byte{
-	// 294 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x91, 0xbd, 0x4a, 0x03, 0x41,
-	0x14, 0x85, 0xe7, 0xba, 0x20, 0x64, 0x40, 0x48, 0xb6, 0x71, 0x11, 0xbd, 0x84, 0x14, 0x1a, 0x10,
-	0x36, 0xf8, 0xd3, 0xd8, 0x1a, 0x9b, 0xb4, 0xf1, 0x01, 0xc2, 0x64, 0xf6, 0x86, 0x8c, 0x48, 0x36,
-	0xce, 0xdc, 0x8d, 0xa4, 0xd3, 0x37, 0xf0, 0x31, 0x7c, 0x14, 0xcb, 0x94, 0x29, 0xcd, 0x6c, 0x63,
-	0x99, 0x47, 0x90, 0x59, 0x23, 0x28, 0x41, 0xec, 0xe6, 0x7c, 0x67, 0xe0, 0x9c, 0xc3, 0x95, 0x87,
-	0x8e, 0x74, 0x61, 0x0d, 0xcf, 0x3b, 0x53, 0x9b, 0x73, 0xde, 0x31, 0x8e, 0x4d, 0xae, 0x55, 0x5a,
-	0xa9, 0x78, 0xaf, 0x92, 0xe9, 0xec, 0x2c, 0x55, 0x05, 0x8f, 0x5b, 0x8f, 0x72, 0xbf, 0x17, 0x40,
-	0x97, 0x2c, 0x9b, 0x91, 0xd1, 0x8a, 0xa9, 0x4f, 0x0f, 0x05, 0x39, 0x8e, 0xeb, 0x32, 0xd2, 0xce,
-	0x26, 0xd0, 0x84, 0x76, 0xad, 0x1f, 0x9e, 0xf1, 0x91, 0x94, 0xae, 0x18, 0xde, 0x91, 0xe6, 0x81,
-	0xc9, 0x92, 0x9d, 0xca, 0xa8, 0x6d, 0x48, 0x2f, 0x8b, 0x4f, 0x65, 0x63, 0xa6, 0xee, 0x4d, 0x66,
-	0x78, 0x3e, 0xc8, 0x0a, 0xab, 0xd8, 0xe4, 0x93, 0x24, 0x6a, 0x42, 0x3b, 0xea, 0xd7, 0xbf, 0x8d,
-	0x9b, 0x0d, 0x6f, 0x5d, 0xc9, 0x64, 0x3b, 0xd8, 0x4d, 0xf3, 0x89, 0xa3, 0x90, 0xa3, 0xc9, 0xf2,
-	0x40, 0x8f, 0x95, 0x99, 0x24, 0xd0, 0x8c, 0x42, 0x4e, 0x20, 0xdd, 0x00, 0xce, 0x9f, 0x61, 0xbb,
-	0xf4, 0x2d, 0xd9, 0x99, 0xd1, 0x14, 0x8f, 0x64, 0xa3, 0x6b, 0x49, 0x31, 0xfd, 0xf0, 0xe2, 0xe3,
-	0xf4, 0xd7, 0xe8, 0xf4, 0x8f, 0xc5, 0x07, 0x27, 0xff, 0xfe, 0xfb, 0x2a, 0xd8, 0x12, 0xd7, 0x97,
-	0x8b, 0x15, 0x8a, 0xe5, 0x0a, 0xc5, 0x7a, 0x85, 0xf0, 0xe4, 0x11, 0x5e, 0x3d, 0xc2, 0x9b, 0x47,
-	0x58, 0x78, 0x84, 0x77, 0x8f, 0xf0, 0xe1, 0x51, 0xac, 0x3d, 0xc2, 0x4b, 0x89, 0x62, 0x51, 0xa2,
-	0x58, 0x96, 0x28, 0x86, 0xbb, 0xd5, 0x0d, 0x2e, 0x3e, 0x03, 0x00, 0x00, 0xff, 0xff, 0x23, 0xaa,
-	0x00, 0x39, 0xa3, 0x01, 0x00, 0x00,
+	// 439 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x53, 0x3d, 0x6f, 0xd4, 0x40,
+	0x10, 0xf5, 0x72, 0x02, 0x29, 0x23, 0x82, 0x92, 0x0d, 0x12, 0xce, 0x41, 0x56, 0x27, 0x23, 0x91,
+	0x00, 0x92, 0x03, 0x81, 0x86, 0x32, 0x31, 0x12, 0x4a, 0x43, 0xe1, 0xd0, 0x5b, 0xeb, 0xf5, 0x44,
+	0xb7, 0x70, 0x78, 0xc3, 0x7e, 0x18, 0xa5, 0x41, 0xf0, 0x0f, 0xf8, 0x19, 0xfc, 0x14, 0xca, 0x2b,
+	0x53, 0x72, 0xbe, 0x86, 0x32, 0x0d, 0x3d, 0xf2, 0x17, 0x70, 0xf8, 0xa2, 0xd0, 0xd0, 0x79, 0xde,
+	0x1b, 0xcd, 0x7b, 0x6f, 0xc6, 0x0b, 0x77, 0x0c, 0x0a, 0xa7, 0xa5, 0x3d, 0xdd, 0x3d, 0xd1, 0xca,
+	0xaa, 0x5d, 0x69, 0xac, 0x54, 0x82, 0x87, 0x75, 0x45, 0x57, 0xeb, 0x32, 0x2c, 0x1e, 0x87, 0xdc,
+	0xd9, 0x71, 0xf0, 0x1e, 0x6e, 0x1d, 0x56, 0x40, 0x84, 0xda, 0xca, 0x63, 0x29, 0xb8, 0xc5, 0x18,
+	0xdf, 0x39, 0x34, 0x96, 0xae, 0xc1, 0x40, 0x18, 0xed, 0x93, 0x11, 0xd9, 0x59, 0x89, 0xab, 0x4f,
+	0xba, 0x05, 0x60, 0x5c, 0xfa, 0x1a, 0x85, 0x4d, 0x64, 0xe6, 0x5f, 0xa9, 0x89, 0x95, 0x16, 0x39,
+	0xcc, 0xe8, 0x43, 0x58, 0x2f, 0xf8, 0x44, 0x66, 0xd2, 0x9e, 0x26, 0x99, 0xd3, 0xdc, 0x4a, 0x95,
+	0xfb, 0x83, 0x11, 0xd9, 0x19, 0xc4, 0x6b, 0x1d, 0xf1, 0xbc, 0xc5, 0x83, 0x67, 0xe0, 0xf7, 0x85,
+	0xcd, 0x89, 0xca, 0x0d, 0x56, 0x3a, 0x02, 0xb5, 0x4d, 0xc4, 0x98, 0xcb, 0xdc, 0x27, 0xa3, 0x41,
+	0xa5, 0x53, 0x21, 0x51, 0x05, 0x04, 0xfb, 0x30, 0x8c, 0xb1, 0x50, 0x6f, 0xf0, 0xc8, 0xa5, 0x4a,
+	0x67, 0x32, 0xe7, 0x16, 0xa3, 0xfd, 0xce, 0xf6, 0x5d, 0x58, 0x35, 0xa8, 0x25, 0x9f, 0x24, 0xb9,
+	0x7b, 0x9b, 0x62, 0x17, 0xe0, 0x7a, 0x03, 0xbe, 0xac, 0xb1, 0x60, 0x0b, 0x6e, 0x2f, 0x1d, 0xd1,
+	0x18, 0x08, 0x36, 0xdb, 0xad, 0xbc, 0xd2, 0xce, 0xd8, 0x03, 0x97, 0x67, 0x93, 0x6e, 0x2b, 0xc1,
+	0x23, 0xf0, 0xfb, 0x54, 0xeb, 0xfb, 0x26, 0x5c, 0xd5, 0x4a, 0x59, 0xd3, 0x5a, 0x6e, 0x8a, 0xbd,
+	0x4f, 0xa4, 0xbf, 0xe3, 0x23, 0xd4, 0x85, 0x14, 0x48, 0x8f, 0x61, 0x3d, 0xd2, 0x58, 0x89, 0xff,
+	0xe6, 0xe8, 0xbd, 0x70, 0xe1, 0x46, 0xe1, 0x05, 0x07, 0x1a, 0x6e, 0x5f, 0xda, 0xd7, 0xc6, 0xf1,
+	0xf6, 0x7e, 0x10, 0xd8, 0xac, 0xe9, 0x85, 0xbc, 0x9d, 0x8b, 0x31, 0x6c, 0x34, 0x2e, 0x16, 0xd8,
+	0xff, 0xe0, 0x83, 0xe6, 0xb0, 0xb1, 0x64, 0xef, 0xf4, 0xfe, 0x5f, 0x13, 0x2e, 0x3e, 0xef, 0xf0,
+	0xc1, 0xbf, 0xb4, 0xfe, 0xca, 0xfd, 0xa1, 0x7f, 0xc8, 0x2e, 0xb4, 0x80, 0x1b, 0x2f, 0xd0, 0xfe,
+	0x41, 0x2c, 0xcf, 0xdb, 0xff, 0x05, 0x86, 0xdb, 0x97, 0xf6, 0x75, 0xfa, 0x07, 0x4f, 0xa7, 0x33,
+	0xe6, 0x9d, 0xcd, 0x98, 0x77, 0x3e, 0x63, 0xe4, 0x63, 0xc9, 0xc8, 0x97, 0x92, 0x91, 0xaf, 0x25,
+	0x23, 0xd3, 0x92, 0x91, 0x6f, 0x25, 0x23, 0xdf, 0x4b, 0xe6, 0x9d, 0x97, 0x8c, 0x7c, 0x9e, 0x33,
+	0x6f, 0x3a, 0x67, 0xde, 0xd9, 0x9c, 0x79, 0xe9, 0xb5, 0xfa, 0xa9, 0x3e, 0xf9, 0x39, 0x00, 0x23,
+	0xe8, 0x6e, 0x6e, 0xca, 0x03, 0x00, 0x00,
 }
 
 func (this *IstioCertificateRequest) Equal(that interface{}) bool {
@@ -226,6 +402,101 @@ func (this *IstioCertificateResponse) Equal(that interface{}) bool {
 	}
 	return true
 }
+func (this *RevokeSubordinateCARequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*RevokeSubordinateCARequest)
+	if !ok {
+		that2, ok := that.(RevokeSubordinateCARequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.SerialNumber != that1.SerialNumber {
+		return false
+	}
+	return true
+}
+func (this *RevokeSubordinateCAResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*RevokeSubordinateCAResponse)
+	if !ok {
+		that2, ok := that.(RevokeSubordinateCAResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	return true
+}
+func (this *IstioTrustBundleRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*IstioTrustBundleRequest)
+	if !ok {
+		that2, ok := that.(IstioTrustBundleRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	return true
+}
+func (this *IstioTrustBundleResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*IstioTrustBundleResponse)
+	if !ok {
+		that2, ok := that.(IstioTrustBundleResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Roots) != len(that1.Roots) {
+		return false
+	}
+	for i := range this.Roots {
+		if this.Roots[i] != that1.Roots[i] {
+			return false
+		}
+	}
+	return true
+}
 func (this *IstioCertificateRequest) GoString() string {
 	if this == nil {
 		return "nil"
@@ -248,6 +519,44 @@ func (this *IstioCertificateResponse) GoString() string {
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
+func (this *RevokeSubordinateCARequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&istio_v1_auth.RevokeSubordinateCARequest{")
+	s = append(s, "SerialNumber: "+fmt.Sprintf("%#v", this.SerialNumber)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *RevokeSubordinateCAResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 4)
+	s = append(s, "&istio_v1_auth.RevokeSubordinateCAResponse{")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *IstioTrustBundleRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 4)
+	s = append(s, "&istio_v1_auth.IstioTrustBundleRequest{")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *IstioTrustBundleResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&istio_v1_auth.IstioTrustBundleResponse{")
+	s = append(s, "Roots: "+fmt.Sprintf("%#v", this.Roots)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
 func valueToGoStringIstioca(v interface{}, typ string) string {
 	rv := reflect.ValueOf(v)
 	if rv.IsNil() {
@@ -339,67 +648,259 @@ var _IstioCertificateService_serviceDesc = grpc.ServiceDesc{
 	Metadata: "security/proto/istioca.proto",
 }
 
-func (m *IstioCertificateRequest) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
+// IstioSubordinateCAServiceClient is the client API for IstioSubordinateCAService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type IstioSubordinateCAServiceClient interface {
+	// Using provided CSR, returns a subordinate CA certificate for the cluster
+	// named by the subject ID of the request.
+	CreateSubordinateCA(ctx context.Context, in *IstioCertificateRequest, opts ...grpc.CallOption) (*IstioCertificateResponse, error)
+	// Revokes the subordinate CA certificate with the given serial number. The CA
+	// rejects the client certificate chains including it.
+	RevokeSubordinateCA(ctx context.Context, in *RevokeSubordinateCARequest, opts ...grpc.CallOption) (*RevokeSubordinateCAResponse, error)
 }
 
-func (m *IstioCertificateRequest) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type istioSubordinateCAServiceClient struct {
+	cc *grpc.ClientConn
 }
 
-func (m *IstioCertificateRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.ValidityDuration != 0 {
-		i = encodeVarintIstioca(dAtA, i, uint64(m.ValidityDuration))
-		i--
-		dAtA[i] = 0x18
-	}
-	if len(m.SubjectId) > 0 {
-		i -= len(m.SubjectId)
-		copy(dAtA[i:], m.SubjectId)
-		i = encodeVarintIstioca(dAtA, i, uint64(len(m.SubjectId)))
-		i--
-		dAtA[i] = 0x12
-	}
-	if len(m.Csr) > 0 {
-		i -= len(m.Csr)
-		copy(dAtA[i:], m.Csr)
-		i = encodeVarintIstioca(dAtA, i, uint64(len(m.Csr)))
-		i--
-		dAtA[i] = 0xa
+func NewIstioSubordinateCAServiceClient(cc *grpc.ClientConn) IstioSubordinateCAServiceClient {
+	return &istioSubordinateCAServiceClient{cc}
+}
+
+func (c *istioSubordinateCAServiceClient) CreateSubordinateCA(ctx context.Context, in *IstioCertificateRequest, opts ...grpc.CallOption) (*IstioCertificateResponse, error) {
+	out := new(IstioCertificateResponse)
+	err := c.cc.Invoke(ctx, "/istio.v1.auth.IstioSubordinateCAService/CreateSubordinateCA", in, out, opts...)
+	if err != nil {
+		return nil, err
 	}
-	return len(dAtA) - i, nil
+	return out, nil
 }
 
-func (m *IstioCertificateResponse) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+func (c *istioSubordinateCAServiceClient) RevokeSubordinateCA(ctx context.Context, in *RevokeSubordinateCARequest, opts ...grpc.CallOption) (*RevokeSubordinateCAResponse, error) {
+	out := new(RevokeSubordinateCAResponse)
+	err := c.cc.Invoke(ctx, "/istio.v1.auth.IstioSubordinateCAService/RevokeSubordinateCA", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
-	return dAtA[:n], nil
+	return out, nil
 }
 
-func (m *IstioCertificateResponse) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+// IstioSubordinateCAServiceServer is the server API for IstioSubordinateCAService service.
+type IstioSubordinateCAServiceServer interface {
+	// Using provided CSR, returns a subordinate CA certificate for the cluster
+	// named by the subject ID of the request.
+	CreateSubordinateCA(context.Context, *IstioCertificateRequest) (*IstioCertificateResponse, error)
+	// Revokes the subordinate CA certificate with the given serial number. The CA
+	// rejects the client certificate chains including it.
+	RevokeSubordinateCA(context.Context, *RevokeSubordinateCARequest) (*RevokeSubordinateCAResponse, error)
 }
 
-func (m *IstioCertificateResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
+// UnimplementedIstioSubordinateCAServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedIstioSubordinateCAServiceServer struct {
+}
+
+func (*UnimplementedIstioSubordinateCAServiceServer) CreateSubordinateCA(ctx context.Context, req *IstioCertificateRequest) (*IstioCertificateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSubordinateCA not implemented")
+}
+func (*UnimplementedIstioSubordinateCAServiceServer) RevokeSubordinateCA(ctx context.Context, req *RevokeSubordinateCARequest) (*RevokeSubordinateCAResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeSubordinateCA not implemented")
+}
+
+func RegisterIstioSubordinateCAServiceServer(s *grpc.Server, srv IstioSubordinateCAServiceServer) {
+	s.RegisterService(&_IstioSubordinateCAService_serviceDesc, srv)
+}
+
+func _IstioSubordinateCAService_CreateSubordinateCA_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IstioCertificateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IstioSubordinateCAServiceServer).CreateSubordinateCA(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/istio.v1.auth.IstioSubordinateCAService/CreateSubordinateCA",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IstioSubordinateCAServiceServer).CreateSubordinateCA(ctx, req.(*IstioCertificateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IstioSubordinateCAService_RevokeSubordinateCA_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeSubordinateCARequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IstioSubordinateCAServiceServer).RevokeSubordinateCA(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/istio.v1.auth.IstioSubordinateCAService/RevokeSubordinateCA",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IstioSubordinateCAServiceServer).RevokeSubordinateCA(ctx, req.(*RevokeSubordinateCARequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _IstioSubordinateCAService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "istio.v1.auth.IstioSubordinateCAService",
+	HandlerType: (*IstioSubordinateCAServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateSubordinateCA",
+			Handler:    _IstioSubordinateCAService_CreateSubordinateCA_Handler,
+		},
+		{
+			MethodName: "RevokeSubordinateCA",
+			Handler:    _IstioSubordinateCAService_RevokeSubordinateCA_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "security/proto/istioca.proto",
+}
+
+// IstioTrustBundleServiceClient is the client API for IstioTrustBundleService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type IstioTrustBundleServiceClient interface {
+	// Returns the roots of the trust bundle of the CA: the current roots followed
+	// by the roots rotated out which are not expired yet.
+	GetTrustBundle(ctx context.Context, in *IstioTrustBundleRequest, opts ...grpc.CallOption) (*IstioTrustBundleResponse, error)
+}
+
+type istioTrustBundleServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewIstioTrustBundleServiceClient(cc *grpc.ClientConn) IstioTrustBundleServiceClient {
+	return &istioTrustBundleServiceClient{cc}
+}
+
+func (c *istioTrustBundleServiceClient) GetTrustBundle(ctx context.Context, in *IstioTrustBundleRequest, opts ...grpc.CallOption) (*IstioTrustBundleResponse, error) {
+	out := new(IstioTrustBundleResponse)
+	err := c.cc.Invoke(ctx, "/istio.v1.auth.IstioTrustBundleService/GetTrustBundle", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// IstioTrustBundleServiceServer is the server API for IstioTrustBundleService service.
+type IstioTrustBundleServiceServer interface {
+	// Returns the roots of the trust bundle of the CA: the current roots followed
+	// by the roots rotated out which are not expired yet.
+	GetTrustBundle(context.Context, *IstioTrustBundleRequest) (*IstioTrustBundleResponse, error)
+}
+
+// UnimplementedIstioTrustBundleServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedIstioTrustBundleServiceServer struct {
+}
+
+func (*UnimplementedIstioTrustBundleServiceServer) GetTrustBundle(ctx context.Context, req *IstioTrustBundleRequest) (*IstioTrustBundleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTrustBundle not implemented")
+}
+
+func RegisterIstioTrustBundleServiceServer(s *grpc.Server, srv IstioTrustBundleServiceServer) {
+	s.RegisterService(&_IstioTrustBundleService_serviceDesc, srv)
+}
+
+func _IstioTrustBundleService_GetTrustBundle_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IstioTrustBundleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IstioTrustBundleServiceServer).GetTrustBundle(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/istio.v1.auth.IstioTrustBundleService/GetTrustBundle",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IstioTrustBundleServiceServer).GetTrustBundle(ctx, req.(*IstioTrustBundleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _IstioTrustBundleService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "istio.v1.auth.IstioTrustBundleService",
+	HandlerType: (*IstioTrustBundleServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetTrustBundle",
+			Handler:    _IstioTrustBundleService_GetTrustBundle_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "security/proto/istioca.proto",
+}
+
+func (m *IstioCertificateRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *IstioCertificateRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *IstioCertificateRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.ValidityDuration != 0 {
+		i = encodeVarintIstioca(dAtA, i, uint64(m.ValidityDuration))
+		i--
+		dAtA[i] = 0x18
+	}
+	if len(m.SubjectId) > 0 {
+		i -= len(m.SubjectId)
+		copy(dAtA[i:], m.SubjectId)
+		i = encodeVarintIstioca(dAtA, i, uint64(len(m.SubjectId)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.Csr) > 0 {
+		i -= len(m.Csr)
+		copy(dAtA[i:], m.Csr)
+		i = encodeVarintIstioca(dAtA, i, uint64(len(m.Csr)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *IstioCertificateResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *IstioCertificateResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *IstioCertificateResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
 	_ = l
 	if len(m.CertChain) > 0 {
 		for iNdEx := len(m.CertChain) - 1; iNdEx >= 0; iNdEx-- {
@@ -413,6 +914,114 @@ func (m *IstioCertificateResponse) MarshalToSizedBuffer(dAtA []byte) (int, error
 	return len(dAtA) - i, nil
 }
 
+func (m *RevokeSubordinateCARequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *RevokeSubordinateCARequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *RevokeSubordinateCARequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.SerialNumber) > 0 {
+		i -= len(m.SerialNumber)
+		copy(dAtA[i:], m.SerialNumber)
+		i = encodeVarintIstioca(dAtA, i, uint64(len(m.SerialNumber)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *RevokeSubordinateCAResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *RevokeSubordinateCAResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *RevokeSubordinateCAResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
+func (m *IstioTrustBundleRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *IstioTrustBundleRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *IstioTrustBundleRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
+func (m *IstioTrustBundleResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *IstioTrustBundleResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *IstioTrustBundleResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Roots) > 0 {
+		for iNdEx := len(m.Roots) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Roots[iNdEx])
+			copy(dAtA[i:], m.Roots[iNdEx])
+			i = encodeVarintIstioca(dAtA, i, uint64(len(m.Roots[iNdEx])))
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
 func encodeVarintIstioca(dAtA []byte, offset int, v uint64) int {
 	offset -= sovIstioca(v)
 	base := offset
@@ -456,46 +1065,266 @@ func (m *IstioCertificateResponse) Size() (n int) {
 			n += 1 + l + sovIstioca(uint64(l))
 		}
 	}
-	return n
-}
+	return n
+}
+
+func (m *RevokeSubordinateCARequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.SerialNumber)
+	if l > 0 {
+		n += 1 + l + sovIstioca(uint64(l))
+	}
+	return n
+}
+
+func (m *RevokeSubordinateCAResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *IstioTrustBundleRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *IstioTrustBundleResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Roots) > 0 {
+		for _, s := range m.Roots {
+			l = len(s)
+			n += 1 + l + sovIstioca(uint64(l))
+		}
+	}
+	return n
+}
+
+func sovIstioca(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozIstioca(x uint64) (n int) {
+	return sovIstioca(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (this *IstioCertificateRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&IstioCertificateRequest{`,
+		`Csr:` + fmt.Sprintf("%v", this.Csr) + `,`,
+		`SubjectId:` + fmt.Sprintf("%v", this.SubjectId) + `,`,
+		`ValidityDuration:` + fmt.Sprintf("%v", this.ValidityDuration) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *IstioCertificateResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&IstioCertificateResponse{`,
+		`CertChain:` + fmt.Sprintf("%v", this.CertChain) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *RevokeSubordinateCARequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&RevokeSubordinateCARequest{`,
+		`SerialNumber:` + fmt.Sprintf("%v", this.SerialNumber) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *RevokeSubordinateCAResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&RevokeSubordinateCAResponse{`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *IstioTrustBundleRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&IstioTrustBundleRequest{`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *IstioTrustBundleResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&IstioTrustBundleResponse{`,
+		`Roots:` + fmt.Sprintf("%v", this.Roots) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func valueToStringIstioca(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("*%v", pv)
+}
+func (m *IstioCertificateRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIstioca
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: IstioCertificateRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: IstioCertificateRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Csr", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIstioca
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Csr = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SubjectId", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIstioca
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SubjectId = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ValidityDuration", wireType)
+			}
+			m.ValidityDuration = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIstioca
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ValidityDuration |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIstioca(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
 
-func sovIstioca(x uint64) (n int) {
-	return (math_bits.Len64(x|1) + 6) / 7
-}
-func sozIstioca(x uint64) (n int) {
-	return sovIstioca(uint64((x << 1) ^ uint64((int64(x) >> 63))))
-}
-func (this *IstioCertificateRequest) String() string {
-	if this == nil {
-		return "nil"
-	}
-	s := strings.Join([]string{`&IstioCertificateRequest{`,
-		`Csr:` + fmt.Sprintf("%v", this.Csr) + `,`,
-		`SubjectId:` + fmt.Sprintf("%v", this.SubjectId) + `,`,
-		`ValidityDuration:` + fmt.Sprintf("%v", this.ValidityDuration) + `,`,
-		`}`,
-	}, "")
-	return s
-}
-func (this *IstioCertificateResponse) String() string {
-	if this == nil {
-		return "nil"
-	}
-	s := strings.Join([]string{`&IstioCertificateResponse{`,
-		`CertChain:` + fmt.Sprintf("%v", this.CertChain) + `,`,
-		`}`,
-	}, "")
-	return s
-}
-func valueToStringIstioca(v interface{}) string {
-	rv := reflect.ValueOf(v)
-	if rv.IsNil() {
-		return "nil"
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
 	}
-	pv := reflect.Indirect(rv).Interface()
-	return fmt.Sprintf("*%v", pv)
+	return nil
 }
-func (m *IstioCertificateRequest) Unmarshal(dAtA []byte) error {
+func (m *IstioCertificateResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -518,15 +1347,15 @@ func (m *IstioCertificateRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: IstioCertificateRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: IstioCertificateResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: IstioCertificateRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: IstioCertificateResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Csr", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field CertChain", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -554,11 +1383,64 @@ func (m *IstioCertificateRequest) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Csr = string(dAtA[iNdEx:postIndex])
+			m.CertChain = append(m.CertChain, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
-		case 2:
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIstioca(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *RevokeSubordinateCARequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIstioca
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: RevokeSubordinateCARequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: RevokeSubordinateCARequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field SubjectId", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field SerialNumber", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -586,27 +1468,61 @@ func (m *IstioCertificateRequest) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.SubjectId = string(dAtA[iNdEx:postIndex])
+			m.SerialNumber = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ValidityDuration", wireType)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIstioca(dAtA[iNdEx:])
+			if err != nil {
+				return err
 			}
-			m.ValidityDuration = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowIstioca
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.ValidityDuration |= int64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+			if skippy < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *RevokeSubordinateCAResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIstioca
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
 			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: RevokeSubordinateCAResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: RevokeSubordinateCAResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
 		default:
 			iNdEx = preIndex
 			skippy, err := skipIstioca(dAtA[iNdEx:])
@@ -631,7 +1547,7 @@ func (m *IstioCertificateRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *IstioCertificateResponse) Unmarshal(dAtA []byte) error {
+func (m *IstioTrustBundleRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -654,15 +1570,68 @@ func (m *IstioCertificateResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: IstioCertificateResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: IstioTrustBundleRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: IstioCertificateResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: IstioTrustBundleRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIstioca(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthIstioca
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *IstioTrustBundleResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIstioca
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: IstioTrustBundleResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: IstioTrustBundleResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field CertChain", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Roots", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -690,7 +1659,7 @@ func (m *IstioCertificateResponse) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.CertChain = append(m.CertChain, string(dAtA[iNdEx:postIndex]))
+			m.Roots = append(m.Roots, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
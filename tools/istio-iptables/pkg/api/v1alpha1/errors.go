@@ -0,0 +1,59 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// commandErrorTypeURL is the type URL of the CommandError details.
+const commandErrorTypeURL = "type.googleapis.com/istio.iptables.v1alpha1.CommandError"
+
+// CommandErrorStatus returns the INTERNAL error status of a command which failed, with the
+// command as detail.
+func CommandErrorStatus(e *CommandError) *status.Status {
+	s := &spb.Status{
+		Code:    int32(codes.Internal),
+		Message: e.Message,
+	}
+	// The details are encoded with gogo, which the grpc status helpers don't support.
+	if value, err := proto.Marshal(e); err == nil {
+		s.Details = []*any.Any{{TypeUrl: commandErrorTypeURL, Value: value}}
+	}
+	return status.FromProto(s)
+}
+
+// CommandErrorFromError returns the failed command of an error returned by Program, or nil if the
+// error is not the error of a command.
+func CommandErrorFromError(err error) *CommandError {
+	s, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	for _, detail := range s.Proto().Details {
+		if detail.TypeUrl != commandErrorTypeURL {
+			continue
+		}
+		e := &CommandError{}
+		if proto.Unmarshal(detail.Value, e) == nil {
+			return e
+		}
+	}
+	return nil
+}
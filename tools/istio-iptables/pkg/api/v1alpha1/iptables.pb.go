@@ -0,0 +1,2937 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: tools/istio-iptables/pkg/api/v1alpha1/iptables.proto
+
+package v1alpha1
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/gogo/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	io "io"
+	math "math"
+	math_bits "math/bits"
+	reflect "reflect"
+	strings "strings"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+type ProgramRequest struct {
+	// The config of the rules, as with the flags of istio-iptables.
+	Config *Config `protobuf:"bytes,1,opt,name=config,proto3" json:"config,omitempty"`
+	// The path of the network namespace the rules are programmed in, e.g. /proc/<pid>/ns/net.
+	// The rules are programmed in the namespace of the server if empty.
+	Netns string `protobuf:"bytes,2,opt,name=netns,proto3" json:"netns,omitempty"`
+	// The IP of the pod, required with netns since the local IP is not looked up in the namespace.
+	PodIp string `protobuf:"bytes,3,opt,name=pod_ip,json=podIp,proto3" json:"pod_ip,omitempty"`
+}
+
+func (m *ProgramRequest) Reset()      { *m = ProgramRequest{} }
+func (*ProgramRequest) ProtoMessage() {}
+func (*ProgramRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f0bdf654e77663ab, []int{0}
+}
+func (m *ProgramRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ProgramRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ProgramRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *ProgramRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProgramRequest.Merge(m, src)
+}
+func (m *ProgramRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *ProgramRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProgramRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProgramRequest proto.InternalMessageInfo
+
+func (m *ProgramRequest) GetConfig() *Config {
+	if m != nil {
+		return m.Config
+	}
+	return nil
+}
+
+func (m *ProgramRequest) GetNetns() string {
+	if m != nil {
+		return m.Netns
+	}
+	return ""
+}
+
+func (m *ProgramRequest) GetPodIp() string {
+	if m != nil {
+		return m.PodIp
+	}
+	return ""
+}
+
+type ProgramResponse struct {
+	// The programmed IPv4 rules, by table. They are empty if the rules were already programmed
+	// and skipped with the skip reconcile mode.
+	Ipv4Tables []*Table `protobuf:"bytes,1,rep,name=ipv4_tables,json=ipv4Tables,proto3" json:"ipv4_tables,omitempty"`
+	// The programmed IPv6 rules, by table.
+	Ipv6Tables []*Table `protobuf:"bytes,2,rep,name=ipv6_tables,json=ipv6Tables,proto3" json:"ipv6_tables,omitempty"`
+}
+
+func (m *ProgramResponse) Reset()      { *m = ProgramResponse{} }
+func (*ProgramResponse) ProtoMessage() {}
+func (*ProgramResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f0bdf654e77663ab, []int{1}
+}
+func (m *ProgramResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ProgramResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ProgramResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *ProgramResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProgramResponse.Merge(m, src)
+}
+func (m *ProgramResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *ProgramResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProgramResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProgramResponse proto.InternalMessageInfo
+
+func (m *ProgramResponse) GetIpv4Tables() []*Table {
+	if m != nil {
+		return m.Ipv4Tables
+	}
+	return nil
+}
+
+func (m *ProgramResponse) GetIpv6Tables() []*Table {
+	if m != nil {
+		return m.Ipv6Tables
+	}
+	return nil
+}
+
+// Config is the config of the rules. The fields match the flags of istio-iptables, and the
+// defaults of the empty fields are the flags of the server.
+type Config struct {
+	ProxyPort               string `protobuf:"bytes,1,opt,name=proxy_port,json=proxyPort,proto3" json:"proxy_port,omitempty"`
+	InboundCapturePort      string `protobuf:"bytes,2,opt,name=inbound_capture_port,json=inboundCapturePort,proto3" json:"inbound_capture_port,omitempty"`
+	ProxyUid                string `protobuf:"bytes,3,opt,name=proxy_uid,json=proxyUid,proto3" json:"proxy_uid,omitempty"`
+	ProxyGid                string `protobuf:"bytes,4,opt,name=proxy_gid,json=proxyGid,proto3" json:"proxy_gid,omitempty"`
+	ExcludeOwners           string `protobuf:"bytes,5,opt,name=exclude_owners,json=excludeOwners,proto3" json:"exclude_owners,omitempty"`
+	InboundInterceptionMode string `protobuf:"bytes,6,opt,name=inbound_interception_mode,json=inboundInterceptionMode,proto3" json:"inbound_interception_mode,omitempty"`
+	InboundTproxyMark       string `protobuf:"bytes,7,opt,name=inbound_tproxy_mark,json=inboundTproxyMark,proto3" json:"inbound_tproxy_mark,omitempty"`
+	InboundTproxyRouteTable string `protobuf:"bytes,8,opt,name=inbound_tproxy_route_table,json=inboundTproxyRouteTable,proto3" json:"inbound_tproxy_route_table,omitempty"`
+	InboundPortsInclude     string `protobuf:"bytes,9,opt,name=inbound_ports_include,json=inboundPortsInclude,proto3" json:"inbound_ports_include,omitempty"`
+	InboundPortsExclude     string `protobuf:"bytes,10,opt,name=inbound_ports_exclude,json=inboundPortsExclude,proto3" json:"inbound_ports_exclude,omitempty"`
+	OutboundPortsInclude    string `protobuf:"bytes,11,opt,name=outbound_ports_include,json=outboundPortsInclude,proto3" json:"outbound_ports_include,omitempty"`
+	OutboundPortsExclude    string `protobuf:"bytes,12,opt,name=outbound_ports_exclude,json=outboundPortsExclude,proto3" json:"outbound_ports_exclude,omitempty"`
+	OutboundIpRangesInclude string `protobuf:"bytes,13,opt,name=outbound_ip_ranges_include,json=outboundIpRangesInclude,proto3" json:"outbound_ip_ranges_include,omitempty"`
+	OutboundIpRangesExclude string `protobuf:"bytes,14,opt,name=outbound_ip_ranges_exclude,json=outboundIpRangesExclude,proto3" json:"outbound_ip_ranges_exclude,omitempty"`
+	KubevirtInterfaces      string `protobuf:"bytes,15,opt,name=kubevirt_interfaces,json=kubevirtInterfaces,proto3" json:"kubevirt_interfaces,omitempty"`
+	// The rules are built and returned, but not programmed.
+	DryRun bool `protobuf:"varint,16,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	// How to handle the rules already programmed: "skip", "replace" or "fail".
+	Reconcile string `protobuf:"bytes,17,opt,name=reconcile,proto3" json:"reconcile,omitempty"`
+	// The rules are programmed with iptables-restore rather than one command per rule. Unlike
+	// the flag, it defaults to false.
+	RestoreFormat  bool   `protobuf:"varint,18,opt,name=restore_format,json=restoreFormat,proto3" json:"restore_format,omitempty"`
+	RedirectDns    bool   `protobuf:"varint,19,opt,name=redirect_dns,json=redirectDns,proto3" json:"redirect_dns,omitempty"`
+	DnsCapturePort string `protobuf:"bytes,20,opt,name=dns_capture_port,json=dnsCapturePort,proto3" json:"dns_capture_port,omitempty"`
+}
+
+func (m *Config) Reset()      { *m = Config{} }
+func (*Config) ProtoMessage() {}
+func (*Config) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f0bdf654e77663ab, []int{2}
+}
+func (m *Config) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Config) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Config.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Config) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Config.Merge(m, src)
+}
+func (m *Config) XXX_Size() int {
+	return m.Size()
+}
+func (m *Config) XXX_DiscardUnknown() {
+	xxx_messageInfo_Config.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Config proto.InternalMessageInfo
+
+func (m *Config) GetProxyPort() string {
+	if m != nil {
+		return m.ProxyPort
+	}
+	return ""
+}
+
+func (m *Config) GetInboundCapturePort() string {
+	if m != nil {
+		return m.InboundCapturePort
+	}
+	return ""
+}
+
+func (m *Config) GetProxyUid() string {
+	if m != nil {
+		return m.ProxyUid
+	}
+	return ""
+}
+
+func (m *Config) GetProxyGid() string {
+	if m != nil {
+		return m.ProxyGid
+	}
+	return ""
+}
+
+func (m *Config) GetExcludeOwners() string {
+	if m != nil {
+		return m.ExcludeOwners
+	}
+	return ""
+}
+
+func (m *Config) GetInboundInterceptionMode() string {
+	if m != nil {
+		return m.InboundInterceptionMode
+	}
+	return ""
+}
+
+func (m *Config) GetInboundTproxyMark() string {
+	if m != nil {
+		return m.InboundTproxyMark
+	}
+	return ""
+}
+
+func (m *Config) GetInboundTproxyRouteTable() string {
+	if m != nil {
+		return m.InboundTproxyRouteTable
+	}
+	return ""
+}
+
+func (m *Config) GetInboundPortsInclude() string {
+	if m != nil {
+		return m.InboundPortsInclude
+	}
+	return ""
+}
+
+func (m *Config) GetInboundPortsExclude() string {
+	if m != nil {
+		return m.InboundPortsExclude
+	}
+	return ""
+}
+
+func (m *Config) GetOutboundPortsInclude() string {
+	if m != nil {
+		return m.OutboundPortsInclude
+	}
+	return ""
+}
+
+func (m *Config) GetOutboundPortsExclude() string {
+	if m != nil {
+		return m.OutboundPortsExclude
+	}
+	return ""
+}
+
+func (m *Config) GetOutboundIpRangesInclude() string {
+	if m != nil {
+		return m.OutboundIpRangesInclude
+	}
+	return ""
+}
+
+func (m *Config) GetOutboundIpRangesExclude() string {
+	if m != nil {
+		return m.OutboundIpRangesExclude
+	}
+	return ""
+}
+
+func (m *Config) GetKubevirtInterfaces() string {
+	if m != nil {
+		return m.KubevirtInterfaces
+	}
+	return ""
+}
+
+func (m *Config) GetDryRun() bool {
+	if m != nil {
+		return m.DryRun
+	}
+	return false
+}
+
+func (m *Config) GetReconcile() string {
+	if m != nil {
+		return m.Reconcile
+	}
+	return ""
+}
+
+func (m *Config) GetRestoreFormat() bool {
+	if m != nil {
+		return m.RestoreFormat
+	}
+	return false
+}
+
+func (m *Config) GetRedirectDns() bool {
+	if m != nil {
+		return m.RedirectDns
+	}
+	return false
+}
+
+func (m *Config) GetDnsCapturePort() string {
+	if m != nil {
+		return m.DnsCapturePort
+	}
+	return ""
+}
+
+// Table is the rules of a table, in the iptables-save format.
+type Table struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// The chains created by istio-iptables.
+	Chains []string `protobuf:"bytes,2,rep,name=chains,proto3" json:"chains,omitempty"`
+	Rules  []string `protobuf:"bytes,3,rep,name=rules,proto3" json:"rules,omitempty"`
+}
+
+func (m *Table) Reset()      { *m = Table{} }
+func (*Table) ProtoMessage() {}
+func (*Table) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f0bdf654e77663ab, []int{3}
+}
+func (m *Table) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Table) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Table.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Table) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Table.Merge(m, src)
+}
+func (m *Table) XXX_Size() int {
+	return m.Size()
+}
+func (m *Table) XXX_DiscardUnknown() {
+	xxx_messageInfo_Table.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Table proto.InternalMessageInfo
+
+func (m *Table) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Table) GetChains() []string {
+	if m != nil {
+		return m.Chains
+	}
+	return nil
+}
+
+func (m *Table) GetRules() []string {
+	if m != nil {
+		return m.Rules
+	}
+	return nil
+}
+
+// CommandError is the detail of the error of a command which failed.
+type CommandError struct {
+	Command string   `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+	Args    []string `protobuf:"bytes,2,rep,name=args,proto3" json:"args,omitempty"`
+	Message string   `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (m *CommandError) Reset()      { *m = CommandError{} }
+func (*CommandError) ProtoMessage() {}
+func (*CommandError) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f0bdf654e77663ab, []int{4}
+}
+func (m *CommandError) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *CommandError) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_CommandError.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *CommandError) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CommandError.Merge(m, src)
+}
+func (m *CommandError) XXX_Size() int {
+	return m.Size()
+}
+func (m *CommandError) XXX_DiscardUnknown() {
+	xxx_messageInfo_CommandError.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CommandError proto.InternalMessageInfo
+
+func (m *CommandError) GetCommand() string {
+	if m != nil {
+		return m.Command
+	}
+	return ""
+}
+
+func (m *CommandError) GetArgs() []string {
+	if m != nil {
+		return m.Args
+	}
+	return nil
+}
+
+func (m *CommandError) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*ProgramRequest)(nil), "istio.iptables.v1alpha1.ProgramRequest")
+	proto.RegisterType((*ProgramResponse)(nil), "istio.iptables.v1alpha1.ProgramResponse")
+	proto.RegisterType((*Config)(nil), "istio.iptables.v1alpha1.Config")
+	proto.RegisterType((*Table)(nil), "istio.iptables.v1alpha1.Table")
+	proto.RegisterType((*CommandError)(nil), "istio.iptables.v1alpha1.CommandError")
+}
+
+func init() {
+	proto.RegisterFile("tools/istio-iptables/pkg/api/v1alpha1/iptables.proto", fileDescriptor_f0bdf654e77663ab)
+}
+
+var fileDescriptor_f0bdf654e77663ab = []byte{
+	// 790 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x55, 0xcd, 0x6e, 0x1c, 0x45,
+	0x10, 0xde, 0x89, 0xe3, 0x5d, 0x6f, 0xad, 0x7f, 0x92, 0xb6, 0x13, 0x37, 0x06, 0x06, 0xb3, 0x12,
+	0x62, 0x2f, 0xec, 0x12, 0x63, 0x05, 0x89, 0x1c, 0x90, 0x30, 0x01, 0xcd, 0x21, 0x22, 0x1a, 0x02,
+	0x07, 0x84, 0x34, 0x6a, 0x4f, 0xb7, 0x37, 0x8d, 0x77, 0xba, 0x9b, 0xee, 0x9e, 0x25, 0xbe, 0xf1,
+	0x02, 0x48, 0x48, 0xbc, 0x04, 0x8f, 0xc2, 0xd1, 0xc7, 0x1c, 0xf1, 0xfa, 0xc2, 0x31, 0x8f, 0x80,
+	0xa6, 0x7f, 0x36, 0x5e, 0x6c, 0x13, 0xdf, 0xa6, 0xea, 0xfb, 0xbe, 0xfa, 0xa6, 0x6b, 0xba, 0x6a,
+	0x60, 0xdf, 0x4a, 0x39, 0x31, 0x23, 0x6e, 0x2c, 0x97, 0x1f, 0x71, 0x65, 0xc9, 0xe1, 0x84, 0x99,
+	0x91, 0x3a, 0x1e, 0x8f, 0x88, 0xe2, 0xa3, 0xe9, 0x03, 0x32, 0x51, 0xcf, 0xc9, 0x83, 0x51, 0x44,
+	0x86, 0x4a, 0x4b, 0x2b, 0xd1, 0xb6, 0xe3, 0x0f, 0xe7, 0xd9, 0xc8, 0xeb, 0x4f, 0x61, 0xfd, 0xa9,
+	0x96, 0x63, 0x4d, 0xaa, 0x9c, 0xfd, 0x5c, 0x33, 0x63, 0xd1, 0xa7, 0xd0, 0x2e, 0xa5, 0x38, 0xe2,
+	0x63, 0x9c, 0xec, 0x26, 0x83, 0xde, 0xde, 0x7b, 0xc3, 0x6b, 0xb4, 0xc3, 0x03, 0x47, 0xcb, 0x03,
+	0x1d, 0x6d, 0xc1, 0xb2, 0x60, 0x56, 0x18, 0x7c, 0x6b, 0x37, 0x19, 0x74, 0x73, 0x1f, 0xa0, 0x7b,
+	0xd0, 0x56, 0x92, 0x16, 0x5c, 0xe1, 0x25, 0x9f, 0x56, 0x92, 0x66, 0xaa, 0xff, 0x47, 0x02, 0x1b,
+	0x73, 0x63, 0xa3, 0xa4, 0x30, 0x0c, 0x7d, 0x0e, 0x3d, 0xae, 0xa6, 0xfb, 0x85, 0xf7, 0xc1, 0xc9,
+	0xee, 0xd2, 0xa0, 0xb7, 0x97, 0x5e, 0x6b, 0xff, 0xac, 0x89, 0x73, 0x68, 0x24, 0xee, 0xd1, 0x84,
+	0x02, 0x0f, 0x63, 0x81, 0x5b, 0x37, 0x2e, 0xf0, 0xd0, 0x17, 0xe8, 0xff, 0xd6, 0x81, 0xb6, 0x3f,
+	0x15, 0x7a, 0x17, 0x40, 0x69, 0xf9, 0xe2, 0xa4, 0x50, 0x52, 0x5b, 0xd7, 0x8a, 0x6e, 0xde, 0x75,
+	0x99, 0xa7, 0x52, 0x5b, 0xf4, 0x31, 0x6c, 0x71, 0x71, 0x28, 0x6b, 0x41, 0x8b, 0x92, 0x28, 0x5b,
+	0x6b, 0xe6, 0x89, 0xfe, 0xec, 0x28, 0x60, 0x07, 0x1e, 0x72, 0x8a, 0xb7, 0xc1, 0xcb, 0x8b, 0x9a,
+	0xd3, 0xd0, 0x8b, 0x15, 0x97, 0xf8, 0x8e, 0xd3, 0xd7, 0xe0, 0x98, 0x53, 0x7c, 0xfb, 0x02, 0xf8,
+	0x35, 0xa7, 0xe8, 0x03, 0x58, 0x67, 0x2f, 0xca, 0x49, 0x4d, 0x59, 0x21, 0x7f, 0x11, 0x4c, 0x1b,
+	0xbc, 0xec, 0x18, 0x6b, 0x21, 0xfb, 0x8d, 0x4b, 0xa2, 0xcf, 0xe0, 0xad, 0xf8, 0x4a, 0x5c, 0x58,
+	0xa6, 0x4b, 0xa6, 0x2c, 0x97, 0xa2, 0xa8, 0x24, 0x65, 0xb8, 0xed, 0x14, 0xdb, 0x81, 0x90, 0x5d,
+	0xc0, 0x9f, 0x48, 0xca, 0xd0, 0x10, 0x36, 0xa3, 0xd6, 0xfa, 0x17, 0xa9, 0x88, 0x3e, 0xc6, 0x1d,
+	0xa7, 0xba, 0x1b, 0xa0, 0x67, 0x0e, 0x79, 0x42, 0xf4, 0x31, 0x7a, 0x04, 0x3b, 0xff, 0xe1, 0x6b,
+	0x59, 0x5b, 0xe6, 0x3b, 0x8f, 0x57, 0x16, 0xcc, 0xbc, 0x2c, 0x6f, 0x70, 0xd7, 0x66, 0xb4, 0x07,
+	0xf7, 0xa2, 0xb8, 0xe9, 0x99, 0x29, 0xb8, 0x70, 0xe7, 0xc0, 0x5d, 0xa7, 0x8b, 0x6f, 0xd2, 0x74,
+	0xcd, 0x64, 0x1e, 0xba, 0xac, 0x09, 0x67, 0xc7, 0x70, 0x59, 0xf3, 0xd8, 0x43, 0x68, 0x1f, 0xee,
+	0xcb, 0xda, 0x5e, 0x65, 0xd4, 0x73, 0xa2, 0xad, 0x88, 0x2e, 0x38, 0x5d, 0x56, 0x45, 0xab, 0xd5,
+	0x2b, 0x54, 0xd1, 0xeb, 0x11, 0xec, 0xcc, 0x55, 0x5c, 0x15, 0x9a, 0x88, 0x31, 0x7b, 0xed, 0xb7,
+	0xe6, 0x1b, 0x12, 0x19, 0x99, 0xca, 0x1d, 0x9e, 0x89, 0xff, 0x13, 0x47, 0xdb, 0xf5, 0xab, 0xc5,
+	0xd1, 0x79, 0x04, 0x9b, 0xc7, 0xf5, 0x21, 0x9b, 0x72, 0x6d, 0xfd, 0x77, 0x3f, 0x22, 0x25, 0x33,
+	0x78, 0xc3, 0x5f, 0xc4, 0x08, 0x65, 0x73, 0x04, 0x6d, 0x43, 0x87, 0xea, 0x93, 0x42, 0xd7, 0x02,
+	0xdf, 0xd9, 0x4d, 0x06, 0x2b, 0x79, 0x9b, 0xea, 0x93, 0xbc, 0x16, 0xe8, 0x1d, 0xe8, 0x6a, 0x56,
+	0x4a, 0x51, 0xf2, 0x09, 0xc3, 0x77, 0xfd, 0x8d, 0x9f, 0x27, 0x9a, 0x5b, 0xa8, 0x99, 0xb1, 0x52,
+	0xb3, 0xe2, 0x48, 0xea, 0x8a, 0x58, 0x8c, 0x9c, 0x7a, 0x2d, 0x64, 0xbf, 0x72, 0x49, 0xf4, 0x3e,
+	0xac, 0x6a, 0x46, 0xb9, 0x66, 0xa5, 0x2d, 0xa8, 0x30, 0x78, 0xd3, 0x91, 0x7a, 0x31, 0xf7, 0xa5,
+	0x30, 0x68, 0x00, 0x77, 0xa8, 0x30, 0x8b, 0x73, 0xb3, 0xe5, 0xec, 0xd6, 0xa9, 0x30, 0x17, 0x66,
+	0xa6, 0x9f, 0xc1, 0xb2, 0xbf, 0x32, 0x08, 0x6e, 0x0b, 0x52, 0xb1, 0x30, 0x87, 0xee, 0x19, 0xdd,
+	0x87, 0x76, 0xf9, 0x9c, 0x70, 0xe1, 0x07, 0xbd, 0x9b, 0x87, 0xa8, 0xd9, 0x43, 0xba, 0x6e, 0xe6,
+	0x7f, 0xc9, 0xa5, 0x7d, 0xd0, 0xff, 0x1e, 0x56, 0x0f, 0x64, 0x55, 0x11, 0x41, 0x1f, 0x6b, 0x2d,
+	0x35, 0xc2, 0xd0, 0x29, 0x7d, 0x1c, 0x8a, 0xc6, 0xb0, 0xf1, 0x22, 0x7a, 0x1c, 0xab, 0xba, 0xe7,
+	0x86, 0x5d, 0x31, 0x63, 0xc8, 0x98, 0x85, 0xd1, 0x8d, 0xe1, 0x9e, 0x84, 0x8d, 0x2c, 0x6c, 0x96,
+	0x6f, 0x99, 0x9e, 0xf2, 0x92, 0xa1, 0x1f, 0xa1, 0x13, 0x56, 0x1b, 0xfa, 0xf0, 0xda, 0xe5, 0xb3,
+	0xb8, 0x75, 0x77, 0x06, 0x6f, 0x26, 0xfa, 0x2d, 0xf9, 0xc5, 0x4f, 0xa7, 0x67, 0x69, 0xeb, 0xe5,
+	0x59, 0xda, 0x7a, 0x75, 0x96, 0x26, 0xbf, 0xce, 0xd2, 0xe4, 0xcf, 0x59, 0x9a, 0xfc, 0x35, 0x4b,
+	0x93, 0xd3, 0x59, 0x9a, 0xfc, 0x3d, 0x4b, 0x93, 0x7f, 0x66, 0x69, 0xeb, 0xd5, 0x2c, 0x4d, 0x7e,
+	0x3f, 0x4f, 0x5b, 0xa7, 0xe7, 0x69, 0xeb, 0xe5, 0x79, 0xda, 0xfa, 0x61, 0x3f, 0x94, 0x97, 0xfe,
+	0xcf, 0x31, 0xba, 0xd1, 0x5f, 0xe4, 0xb0, 0xed, 0xfe, 0x1e, 0x9f, 0xfc, 0x3b, 0x00, 0x70, 0x4d,
+	0x0f, 0x6d, 0x75, 0x06, 0x00, 0x00,
+}
+
+func (this *ProgramRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*ProgramRequest)
+	if !ok {
+		that2, ok := that.(ProgramRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !this.Config.Equal(that1.Config) {
+		return false
+	}
+	if this.Netns != that1.Netns {
+		return false
+	}
+	if this.PodIp != that1.PodIp {
+		return false
+	}
+	return true
+}
+func (this *ProgramResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*ProgramResponse)
+	if !ok {
+		that2, ok := that.(ProgramResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Ipv4Tables) != len(that1.Ipv4Tables) {
+		return false
+	}
+	for i := range this.Ipv4Tables {
+		if !this.Ipv4Tables[i].Equal(that1.Ipv4Tables[i]) {
+			return false
+		}
+	}
+	if len(this.Ipv6Tables) != len(that1.Ipv6Tables) {
+		return false
+	}
+	for i := range this.Ipv6Tables {
+		if !this.Ipv6Tables[i].Equal(that1.Ipv6Tables[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *Config) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Config)
+	if !ok {
+		that2, ok := that.(Config)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.ProxyPort != that1.ProxyPort {
+		return false
+	}
+	if this.InboundCapturePort != that1.InboundCapturePort {
+		return false
+	}
+	if this.ProxyUid != that1.ProxyUid {
+		return false
+	}
+	if this.ProxyGid != that1.ProxyGid {
+		return false
+	}
+	if this.ExcludeOwners != that1.ExcludeOwners {
+		return false
+	}
+	if this.InboundInterceptionMode != that1.InboundInterceptionMode {
+		return false
+	}
+	if this.InboundTproxyMark != that1.InboundTproxyMark {
+		return false
+	}
+	if this.InboundTproxyRouteTable != that1.InboundTproxyRouteTable {
+		return false
+	}
+	if this.InboundPortsInclude != that1.InboundPortsInclude {
+		return false
+	}
+	if this.InboundPortsExclude != that1.InboundPortsExclude {
+		return false
+	}
+	if this.OutboundPortsInclude != that1.OutboundPortsInclude {
+		return false
+	}
+	if this.OutboundPortsExclude != that1.OutboundPortsExclude {
+		return false
+	}
+	if this.OutboundIpRangesInclude != that1.OutboundIpRangesInclude {
+		return false
+	}
+	if this.OutboundIpRangesExclude != that1.OutboundIpRangesExclude {
+		return false
+	}
+	if this.KubevirtInterfaces != that1.KubevirtInterfaces {
+		return false
+	}
+	if this.DryRun != that1.DryRun {
+		return false
+	}
+	if this.Reconcile != that1.Reconcile {
+		return false
+	}
+	if this.RestoreFormat != that1.RestoreFormat {
+		return false
+	}
+	if this.RedirectDns != that1.RedirectDns {
+		return false
+	}
+	if this.DnsCapturePort != that1.DnsCapturePort {
+		return false
+	}
+	return true
+}
+func (this *Table) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Table)
+	if !ok {
+		that2, ok := that.(Table)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Name != that1.Name {
+		return false
+	}
+	if len(this.Chains) != len(that1.Chains) {
+		return false
+	}
+	for i := range this.Chains {
+		if this.Chains[i] != that1.Chains[i] {
+			return false
+		}
+	}
+	if len(this.Rules) != len(that1.Rules) {
+		return false
+	}
+	for i := range this.Rules {
+		if this.Rules[i] != that1.Rules[i] {
+			return false
+		}
+	}
+	return true
+}
+func (this *CommandError) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*CommandError)
+	if !ok {
+		that2, ok := that.(CommandError)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Command != that1.Command {
+		return false
+	}
+	if len(this.Args) != len(that1.Args) {
+		return false
+	}
+	for i := range this.Args {
+		if this.Args[i] != that1.Args[i] {
+			return false
+		}
+	}
+	if this.Message != that1.Message {
+		return false
+	}
+	return true
+}
+func (this *ProgramRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&v1alpha1.ProgramRequest{")
+	if this.Config != nil {
+		s = append(s, "Config: "+fmt.Sprintf("%#v", this.Config)+",\n")
+	}
+	s = append(s, "Netns: "+fmt.Sprintf("%#v", this.Netns)+",\n")
+	s = append(s, "PodIp: "+fmt.Sprintf("%#v", this.PodIp)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *ProgramResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&v1alpha1.ProgramResponse{")
+	if this.Ipv4Tables != nil {
+		s = append(s, "Ipv4Tables: "+fmt.Sprintf("%#v", this.Ipv4Tables)+",\n")
+	}
+	if this.Ipv6Tables != nil {
+		s = append(s, "Ipv6Tables: "+fmt.Sprintf("%#v", this.Ipv6Tables)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Config) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 24)
+	s = append(s, "&v1alpha1.Config{")
+	s = append(s, "ProxyPort: "+fmt.Sprintf("%#v", this.ProxyPort)+",\n")
+	s = append(s, "InboundCapturePort: "+fmt.Sprintf("%#v", this.InboundCapturePort)+",\n")
+	s = append(s, "ProxyUid: "+fmt.Sprintf("%#v", this.ProxyUid)+",\n")
+	s = append(s, "ProxyGid: "+fmt.Sprintf("%#v", this.ProxyGid)+",\n")
+	s = append(s, "ExcludeOwners: "+fmt.Sprintf("%#v", this.ExcludeOwners)+",\n")
+	s = append(s, "InboundInterceptionMode: "+fmt.Sprintf("%#v", this.InboundInterceptionMode)+",\n")
+	s = append(s, "InboundTproxyMark: "+fmt.Sprintf("%#v", this.InboundTproxyMark)+",\n")
+	s = append(s, "InboundTproxyRouteTable: "+fmt.Sprintf("%#v", this.InboundTproxyRouteTable)+",\n")
+	s = append(s, "InboundPortsInclude: "+fmt.Sprintf("%#v", this.InboundPortsInclude)+",\n")
+	s = append(s, "InboundPortsExclude: "+fmt.Sprintf("%#v", this.InboundPortsExclude)+",\n")
+	s = append(s, "OutboundPortsInclude: "+fmt.Sprintf("%#v", this.OutboundPortsInclude)+",\n")
+	s = append(s, "OutboundPortsExclude: "+fmt.Sprintf("%#v", this.OutboundPortsExclude)+",\n")
+	s = append(s, "OutboundIpRangesInclude: "+fmt.Sprintf("%#v", this.OutboundIpRangesInclude)+",\n")
+	s = append(s, "OutboundIpRangesExclude: "+fmt.Sprintf("%#v", this.OutboundIpRangesExclude)+",\n")
+	s = append(s, "KubevirtInterfaces: "+fmt.Sprintf("%#v", this.KubevirtInterfaces)+",\n")
+	s = append(s, "DryRun: "+fmt.Sprintf("%#v", this.DryRun)+",\n")
+	s = append(s, "Reconcile: "+fmt.Sprintf("%#v", this.Reconcile)+",\n")
+	s = append(s, "RestoreFormat: "+fmt.Sprintf("%#v", this.RestoreFormat)+",\n")
+	s = append(s, "RedirectDns: "+fmt.Sprintf("%#v", this.RedirectDns)+",\n")
+	s = append(s, "DnsCapturePort: "+fmt.Sprintf("%#v", this.DnsCapturePort)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Table) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&v1alpha1.Table{")
+	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
+	s = append(s, "Chains: "+fmt.Sprintf("%#v", this.Chains)+",\n")
+	s = append(s, "Rules: "+fmt.Sprintf("%#v", this.Rules)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *CommandError) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&v1alpha1.CommandError{")
+	s = append(s, "Command: "+fmt.Sprintf("%#v", this.Command)+",\n")
+	s = append(s, "Args: "+fmt.Sprintf("%#v", this.Args)+",\n")
+	s = append(s, "Message: "+fmt.Sprintf("%#v", this.Message)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func valueToGoStringIptables(v interface{}, typ string) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("func(v %v) *%v { return &v } ( %#v )", typ, typ, pv)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// IptablesServiceClient is the client API for IptablesService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type IptablesServiceClient interface {
+	// Program programs the rules of the config in the network namespace of the request. A command
+	// which failed is returned as an INTERNAL error with a CommandError detail, an invalid request
+	// as an INVALID_ARGUMENT error.
+	Program(ctx context.Context, in *ProgramRequest, opts ...grpc.CallOption) (*ProgramResponse, error)
+}
+
+type iptablesServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewIptablesServiceClient(cc *grpc.ClientConn) IptablesServiceClient {
+	return &iptablesServiceClient{cc}
+}
+
+func (c *iptablesServiceClient) Program(ctx context.Context, in *ProgramRequest, opts ...grpc.CallOption) (*ProgramResponse, error) {
+	out := new(ProgramResponse)
+	err := c.cc.Invoke(ctx, "/istio.iptables.v1alpha1.IptablesService/Program", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// IptablesServiceServer is the server API for IptablesService service.
+type IptablesServiceServer interface {
+	// Program programs the rules of the config in the network namespace of the request. A command
+	// which failed is returned as an INTERNAL error with a CommandError detail, an invalid request
+	// as an INVALID_ARGUMENT error.
+	Program(context.Context, *ProgramRequest) (*ProgramResponse, error)
+}
+
+// UnimplementedIptablesServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedIptablesServiceServer struct {
+}
+
+func (*UnimplementedIptablesServiceServer) Program(ctx context.Context, req *ProgramRequest) (*ProgramResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Program not implemented")
+}
+
+func RegisterIptablesServiceServer(s *grpc.Server, srv IptablesServiceServer) {
+	s.RegisterService(&_IptablesService_serviceDesc, srv)
+}
+
+func _IptablesService_Program_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProgramRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IptablesServiceServer).Program(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/istio.iptables.v1alpha1.IptablesService/Program",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IptablesServiceServer).Program(ctx, req.(*ProgramRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _IptablesService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "istio.iptables.v1alpha1.IptablesService",
+	HandlerType: (*IptablesServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Program",
+			Handler:    _IptablesService_Program_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "tools/istio-iptables/pkg/api/v1alpha1/iptables.proto",
+}
+
+func (m *ProgramRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ProgramRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ProgramRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.PodIp) > 0 {
+		i -= len(m.PodIp)
+		copy(dAtA[i:], m.PodIp)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.PodIp)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.Netns) > 0 {
+		i -= len(m.Netns)
+		copy(dAtA[i:], m.Netns)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.Netns)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.Config != nil {
+		{
+			size, err := m.Config.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintIptables(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *ProgramResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ProgramResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ProgramResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Ipv6Tables) > 0 {
+		for iNdEx := len(m.Ipv6Tables) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Ipv6Tables[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintIptables(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if len(m.Ipv4Tables) > 0 {
+		for iNdEx := len(m.Ipv4Tables) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Ipv4Tables[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintIptables(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *Config) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Config) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Config) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.DnsCapturePort) > 0 {
+		i -= len(m.DnsCapturePort)
+		copy(dAtA[i:], m.DnsCapturePort)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.DnsCapturePort)))
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0xa2
+	}
+	if m.RedirectDns {
+		i--
+		if m.RedirectDns {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0x98
+	}
+	if m.RestoreFormat {
+		i--
+		if m.RestoreFormat {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0x90
+	}
+	if len(m.Reconcile) > 0 {
+		i -= len(m.Reconcile)
+		copy(dAtA[i:], m.Reconcile)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.Reconcile)))
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0x8a
+	}
+	if m.DryRun {
+		i--
+		if m.DryRun {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0x80
+	}
+	if len(m.KubevirtInterfaces) > 0 {
+		i -= len(m.KubevirtInterfaces)
+		copy(dAtA[i:], m.KubevirtInterfaces)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.KubevirtInterfaces)))
+		i--
+		dAtA[i] = 0x7a
+	}
+	if len(m.OutboundIpRangesExclude) > 0 {
+		i -= len(m.OutboundIpRangesExclude)
+		copy(dAtA[i:], m.OutboundIpRangesExclude)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.OutboundIpRangesExclude)))
+		i--
+		dAtA[i] = 0x72
+	}
+	if len(m.OutboundIpRangesInclude) > 0 {
+		i -= len(m.OutboundIpRangesInclude)
+		copy(dAtA[i:], m.OutboundIpRangesInclude)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.OutboundIpRangesInclude)))
+		i--
+		dAtA[i] = 0x6a
+	}
+	if len(m.OutboundPortsExclude) > 0 {
+		i -= len(m.OutboundPortsExclude)
+		copy(dAtA[i:], m.OutboundPortsExclude)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.OutboundPortsExclude)))
+		i--
+		dAtA[i] = 0x62
+	}
+	if len(m.OutboundPortsInclude) > 0 {
+		i -= len(m.OutboundPortsInclude)
+		copy(dAtA[i:], m.OutboundPortsInclude)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.OutboundPortsInclude)))
+		i--
+		dAtA[i] = 0x5a
+	}
+	if len(m.InboundPortsExclude) > 0 {
+		i -= len(m.InboundPortsExclude)
+		copy(dAtA[i:], m.InboundPortsExclude)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.InboundPortsExclude)))
+		i--
+		dAtA[i] = 0x52
+	}
+	if len(m.InboundPortsInclude) > 0 {
+		i -= len(m.InboundPortsInclude)
+		copy(dAtA[i:], m.InboundPortsInclude)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.InboundPortsInclude)))
+		i--
+		dAtA[i] = 0x4a
+	}
+	if len(m.InboundTproxyRouteTable) > 0 {
+		i -= len(m.InboundTproxyRouteTable)
+		copy(dAtA[i:], m.InboundTproxyRouteTable)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.InboundTproxyRouteTable)))
+		i--
+		dAtA[i] = 0x42
+	}
+	if len(m.InboundTproxyMark) > 0 {
+		i -= len(m.InboundTproxyMark)
+		copy(dAtA[i:], m.InboundTproxyMark)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.InboundTproxyMark)))
+		i--
+		dAtA[i] = 0x3a
+	}
+	if len(m.InboundInterceptionMode) > 0 {
+		i -= len(m.InboundInterceptionMode)
+		copy(dAtA[i:], m.InboundInterceptionMode)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.InboundInterceptionMode)))
+		i--
+		dAtA[i] = 0x32
+	}
+	if len(m.ExcludeOwners) > 0 {
+		i -= len(m.ExcludeOwners)
+		copy(dAtA[i:], m.ExcludeOwners)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.ExcludeOwners)))
+		i--
+		dAtA[i] = 0x2a
+	}
+	if len(m.ProxyGid) > 0 {
+		i -= len(m.ProxyGid)
+		copy(dAtA[i:], m.ProxyGid)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.ProxyGid)))
+		i--
+		dAtA[i] = 0x22
+	}
+	if len(m.ProxyUid) > 0 {
+		i -= len(m.ProxyUid)
+		copy(dAtA[i:], m.ProxyUid)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.ProxyUid)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.InboundCapturePort) > 0 {
+		i -= len(m.InboundCapturePort)
+		copy(dAtA[i:], m.InboundCapturePort)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.InboundCapturePort)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.ProxyPort) > 0 {
+		i -= len(m.ProxyPort)
+		copy(dAtA[i:], m.ProxyPort)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.ProxyPort)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *Table) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Table) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Table) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Rules) > 0 {
+		for iNdEx := len(m.Rules) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Rules[iNdEx])
+			copy(dAtA[i:], m.Rules[iNdEx])
+			i = encodeVarintIptables(dAtA, i, uint64(len(m.Rules[iNdEx])))
+			i--
+			dAtA[i] = 0x1a
+		}
+	}
+	if len(m.Chains) > 0 {
+		for iNdEx := len(m.Chains) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Chains[iNdEx])
+			copy(dAtA[i:], m.Chains[iNdEx])
+			i = encodeVarintIptables(dAtA, i, uint64(len(m.Chains[iNdEx])))
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if len(m.Name) > 0 {
+		i -= len(m.Name)
+		copy(dAtA[i:], m.Name)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.Name)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *CommandError) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *CommandError) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *CommandError) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Message) > 0 {
+		i -= len(m.Message)
+		copy(dAtA[i:], m.Message)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.Message)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.Args) > 0 {
+		for iNdEx := len(m.Args) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Args[iNdEx])
+			copy(dAtA[i:], m.Args[iNdEx])
+			i = encodeVarintIptables(dAtA, i, uint64(len(m.Args[iNdEx])))
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if len(m.Command) > 0 {
+		i -= len(m.Command)
+		copy(dAtA[i:], m.Command)
+		i = encodeVarintIptables(dAtA, i, uint64(len(m.Command)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintIptables(dAtA []byte, offset int, v uint64) int {
+	offset -= sovIptables(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *ProgramRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Config != nil {
+		l = m.Config.Size()
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	l = len(m.Netns)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	l = len(m.PodIp)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	return n
+}
+
+func (m *ProgramResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Ipv4Tables) > 0 {
+		for _, e := range m.Ipv4Tables {
+			l = e.Size()
+			n += 1 + l + sovIptables(uint64(l))
+		}
+	}
+	if len(m.Ipv6Tables) > 0 {
+		for _, e := range m.Ipv6Tables {
+			l = e.Size()
+			n += 1 + l + sovIptables(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *Config) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.ProxyPort)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	l = len(m.InboundCapturePort)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	l = len(m.ProxyUid)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	l = len(m.ProxyGid)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	l = len(m.ExcludeOwners)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	l = len(m.InboundInterceptionMode)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	l = len(m.InboundTproxyMark)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	l = len(m.InboundTproxyRouteTable)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	l = len(m.InboundPortsInclude)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	l = len(m.InboundPortsExclude)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	l = len(m.OutboundPortsInclude)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	l = len(m.OutboundPortsExclude)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	l = len(m.OutboundIpRangesInclude)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	l = len(m.OutboundIpRangesExclude)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	l = len(m.KubevirtInterfaces)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	if m.DryRun {
+		n += 3
+	}
+	l = len(m.Reconcile)
+	if l > 0 {
+		n += 2 + l + sovIptables(uint64(l))
+	}
+	if m.RestoreFormat {
+		n += 3
+	}
+	if m.RedirectDns {
+		n += 3
+	}
+	l = len(m.DnsCapturePort)
+	if l > 0 {
+		n += 2 + l + sovIptables(uint64(l))
+	}
+	return n
+}
+
+func (m *Table) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Name)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	if len(m.Chains) > 0 {
+		for _, s := range m.Chains {
+			l = len(s)
+			n += 1 + l + sovIptables(uint64(l))
+		}
+	}
+	if len(m.Rules) > 0 {
+		for _, s := range m.Rules {
+			l = len(s)
+			n += 1 + l + sovIptables(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *CommandError) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Command)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	if len(m.Args) > 0 {
+		for _, s := range m.Args {
+			l = len(s)
+			n += 1 + l + sovIptables(uint64(l))
+		}
+	}
+	l = len(m.Message)
+	if l > 0 {
+		n += 1 + l + sovIptables(uint64(l))
+	}
+	return n
+}
+
+func sovIptables(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozIptables(x uint64) (n int) {
+	return sovIptables(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (this *ProgramRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ProgramRequest{`,
+		`Config:` + strings.Replace(this.Config.String(), "Config", "Config", 1) + `,`,
+		`Netns:` + fmt.Sprintf("%v", this.Netns) + `,`,
+		`PodIp:` + fmt.Sprintf("%v", this.PodIp) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ProgramResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForIpv4Tables := "[]*Table{"
+	for _, f := range this.Ipv4Tables {
+		repeatedStringForIpv4Tables += strings.Replace(f.String(), "Table", "Table", 1) + ","
+	}
+	repeatedStringForIpv4Tables += "}"
+	repeatedStringForIpv6Tables := "[]*Table{"
+	for _, f := range this.Ipv6Tables {
+		repeatedStringForIpv6Tables += strings.Replace(f.String(), "Table", "Table", 1) + ","
+	}
+	repeatedStringForIpv6Tables += "}"
+	s := strings.Join([]string{`&ProgramResponse{`,
+		`Ipv4Tables:` + repeatedStringForIpv4Tables + `,`,
+		`Ipv6Tables:` + repeatedStringForIpv6Tables + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Config) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Config{`,
+		`ProxyPort:` + fmt.Sprintf("%v", this.ProxyPort) + `,`,
+		`InboundCapturePort:` + fmt.Sprintf("%v", this.InboundCapturePort) + `,`,
+		`ProxyUid:` + fmt.Sprintf("%v", this.ProxyUid) + `,`,
+		`ProxyGid:` + fmt.Sprintf("%v", this.ProxyGid) + `,`,
+		`ExcludeOwners:` + fmt.Sprintf("%v", this.ExcludeOwners) + `,`,
+		`InboundInterceptionMode:` + fmt.Sprintf("%v", this.InboundInterceptionMode) + `,`,
+		`InboundTproxyMark:` + fmt.Sprintf("%v", this.InboundTproxyMark) + `,`,
+		`InboundTproxyRouteTable:` + fmt.Sprintf("%v", this.InboundTproxyRouteTable) + `,`,
+		`InboundPortsInclude:` + fmt.Sprintf("%v", this.InboundPortsInclude) + `,`,
+		`InboundPortsExclude:` + fmt.Sprintf("%v", this.InboundPortsExclude) + `,`,
+		`OutboundPortsInclude:` + fmt.Sprintf("%v", this.OutboundPortsInclude) + `,`,
+		`OutboundPortsExclude:` + fmt.Sprintf("%v", this.OutboundPortsExclude) + `,`,
+		`OutboundIpRangesInclude:` + fmt.Sprintf("%v", this.OutboundIpRangesInclude) + `,`,
+		`OutboundIpRangesExclude:` + fmt.Sprintf("%v", this.OutboundIpRangesExclude) + `,`,
+		`KubevirtInterfaces:` + fmt.Sprintf("%v", this.KubevirtInterfaces) + `,`,
+		`DryRun:` + fmt.Sprintf("%v", this.DryRun) + `,`,
+		`Reconcile:` + fmt.Sprintf("%v", this.Reconcile) + `,`,
+		`RestoreFormat:` + fmt.Sprintf("%v", this.RestoreFormat) + `,`,
+		`RedirectDns:` + fmt.Sprintf("%v", this.RedirectDns) + `,`,
+		`DnsCapturePort:` + fmt.Sprintf("%v", this.DnsCapturePort) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Table) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Table{`,
+		`Name:` + fmt.Sprintf("%v", this.Name) + `,`,
+		`Chains:` + fmt.Sprintf("%v", this.Chains) + `,`,
+		`Rules:` + fmt.Sprintf("%v", this.Rules) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *CommandError) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&CommandError{`,
+		`Command:` + fmt.Sprintf("%v", this.Command) + `,`,
+		`Args:` + fmt.Sprintf("%v", this.Args) + `,`,
+		`Message:` + fmt.Sprintf("%v", this.Message) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func valueToStringIptables(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("*%v", pv)
+}
+func (m *ProgramRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIptables
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ProgramRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ProgramRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Config", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Config == nil {
+				m.Config = &Config{}
+			}
+			if err := m.Config.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Netns", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Netns = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PodIp", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.PodIp = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIptables(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ProgramResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIptables
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ProgramResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ProgramResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Ipv4Tables", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Ipv4Tables = append(m.Ipv4Tables, &Table{})
+			if err := m.Ipv4Tables[len(m.Ipv4Tables)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Ipv6Tables", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Ipv6Tables = append(m.Ipv6Tables, &Table{})
+			if err := m.Ipv6Tables[len(m.Ipv6Tables)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIptables(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Config) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIptables
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Config: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Config: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ProxyPort", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ProxyPort = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field InboundCapturePort", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.InboundCapturePort = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ProxyUid", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ProxyUid = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ProxyGid", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ProxyGid = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ExcludeOwners", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ExcludeOwners = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field InboundInterceptionMode", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.InboundInterceptionMode = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field InboundTproxyMark", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.InboundTproxyMark = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field InboundTproxyRouteTable", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.InboundTproxyRouteTable = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field InboundPortsInclude", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.InboundPortsInclude = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field InboundPortsExclude", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.InboundPortsExclude = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 11:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field OutboundPortsInclude", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.OutboundPortsInclude = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 12:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field OutboundPortsExclude", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.OutboundPortsExclude = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 13:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field OutboundIpRangesInclude", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.OutboundIpRangesInclude = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 14:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field OutboundIpRangesExclude", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.OutboundIpRangesExclude = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 15:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field KubevirtInterfaces", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.KubevirtInterfaces = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 16:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DryRun", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.DryRun = bool(v != 0)
+		case 17:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Reconcile", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Reconcile = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 18:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RestoreFormat", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.RestoreFormat = bool(v != 0)
+		case 19:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RedirectDns", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.RedirectDns = bool(v != 0)
+		case 20:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DnsCapturePort", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.DnsCapturePort = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIptables(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Table) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIptables
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Table: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Table: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Chains", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Chains = append(m.Chains, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Rules", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Rules = append(m.Rules, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIptables(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *CommandError) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIptables
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: CommandError: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: CommandError: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Command", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Command = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Args", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Args = append(m.Args, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Message", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIptables
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Message = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIptables(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthIptables
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func skipIptables(dAtA []byte) (n int, err error) {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return 0, ErrIntOverflowIptables
+			}
+			if iNdEx >= l {
+				return 0, io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		wireType := int(wire & 0x7)
+		switch wireType {
+		case 0:
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				iNdEx++
+				if dAtA[iNdEx-1] < 0x80 {
+					break
+				}
+			}
+			return iNdEx, nil
+		case 1:
+			iNdEx += 8
+			return iNdEx, nil
+		case 2:
+			var length int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowIptables
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				length |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if length < 0 {
+				return 0, ErrInvalidLengthIptables
+			}
+			iNdEx += length
+			if iNdEx < 0 {
+				return 0, ErrInvalidLengthIptables
+			}
+			return iNdEx, nil
+		case 3:
+			for {
+				var innerWire uint64
+				var start int = iNdEx
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return 0, ErrIntOverflowIptables
+					}
+					if iNdEx >= l {
+						return 0, io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					innerWire |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				innerWireType := int(innerWire & 0x7)
+				if innerWireType == 4 {
+					break
+				}
+				next, err := skipIptables(dAtA[start:])
+				if err != nil {
+					return 0, err
+				}
+				iNdEx = start + next
+				if iNdEx < 0 {
+					return 0, ErrInvalidLengthIptables
+				}
+			}
+			return iNdEx, nil
+		case 4:
+			return iNdEx, nil
+		case 5:
+			iNdEx += 4
+			return iNdEx, nil
+		default:
+			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
+		}
+	}
+	panic("unreachable")
+}
+
+var (
+	ErrInvalidLengthIptables = fmt.Errorf("proto: negative length found during unmarshaling")
+	ErrIntOverflowIptables   = fmt.Errorf("proto: integer overflow")
+)
@@ -36,6 +36,10 @@ type IptablesConsumer interface {
 	BuildV4Restore() string
 	// BuildV6Restore creates ip6tables-restore input format
 	BuildV6Restore() string
+	// BuildV4Tables creates the final IPv4 rules of each table
+	BuildV4Tables() map[string]*Table
+	// BuildV6Tables creates the final IPv6 rules of each table
+	BuildV6Tables() map[string]*Table
 }
 
 // IptablesBuilder is a higher level interface based on builder pattern.
@@ -16,6 +16,7 @@ package builder
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"istio.io/istio/tools/istio-iptables/pkg/dependencies"
@@ -164,3 +165,56 @@ func (rb *IptablesBuilderImpl) BuildV4Restore() string {
 func (rb *IptablesBuilderImpl) BuildV6Restore() string {
 	return rb.buildRestore(rb.rules.rulesv6)
 }
+
+// Table represents the rules of an iptables table: the chains created by the rules, and the rules
+// in the order they end up in the chains, in iptables-save format.
+type Table struct {
+	Chains []string `json:"chains"`
+	Rules  []string `json:"rules"`
+}
+
+func (rb *IptablesBuilderImpl) buildTables(rules []*Rule) map[string]*Table {
+	type chain struct {
+		table string
+		name  string
+	}
+	chains := []chain{}
+	chainRules := map[chain][]string{}
+	tables := map[string]*Table{}
+	for _, r := range rules {
+		c := chain{table: r.table, name: r.chain}
+		if _, present := chainRules[c]; !present {
+			chains = append(chains, c)
+			chainRules[c] = []string{}
+			if tables[r.table] == nil {
+				tables[r.table] = &Table{Chains: []string{}, Rules: []string{}}
+			}
+			if _, present := constants.BuiltInChainsMap[r.chain]; !present {
+				tables[r.table].Chains = append(tables[r.table].Chains, r.chain)
+			}
+		}
+		if r.params[0] == "-I" {
+			// The params of the inserted rules start with: -I chain position.
+			rule := strings.Join(append([]string{"-A", r.chain}, r.params[3:]...), " ")
+			position, err := strconv.Atoi(r.params[2])
+			if err != nil || position < 1 || position > len(chainRules[c]) {
+				position = len(chainRules[c]) + 1
+			}
+			chainRules[c] = append(chainRules[c][:position-1], append([]string{rule}, chainRules[c][position-1:]...)...)
+		} else {
+			chainRules[c] = append(chainRules[c], strings.Join(r.params, " "))
+		}
+	}
+	for _, c := range chains {
+		tables[c.table].Rules = append(tables[c.table].Rules, chainRules[c]...)
+	}
+	return tables
+}
+
+func (rb *IptablesBuilderImpl) BuildV4Tables() map[string]*Table {
+	return rb.buildTables(rb.rules.rulesv4)
+}
+
+func (rb *IptablesBuilderImpl) BuildV6Tables() map[string]*Table {
+	return rb.buildTables(rb.rules.rulesv6)
+}
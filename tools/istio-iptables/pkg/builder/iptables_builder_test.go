@@ -306,3 +306,40 @@ func TestBuildV4V6MultipleRulesWithNewChain(t *testing.T) {
 		t.Errorf("Actual and expected output mismatch; but instead got Actual: %#v ; Expected: %#v", actualV6, expectedV6)
 	}
 }
+
+func TestBuildV4Tables(t *testing.T) {
+	iptables := NewIptablesBuilder()
+	iptables.AppendRuleV4("chain", "nat", "-f", "foo")
+	iptables.AppendRuleV4(constants.PREROUTING, "nat", "-j", "chain")
+	iptables.AppendRuleV4("chain", "nat", "-f", "bar")
+	iptables.InsertRuleV4("chain", "nat", 1, "-f", "first")
+	iptables.InsertRuleV4(constants.PREROUTING, "nat", 1, "-i", "eth1", "-j", "RETURN")
+	iptables.AppendRuleV4("chain", "mangle", "-f", "baz")
+	iptables.AppendRuleV6("chain", "nat", "-f", "v6")
+
+	expected := map[string]*Table{
+		"nat": {
+			Chains: []string{"chain"},
+			Rules: []string{
+				"-A chain -f first",
+				"-A chain -f foo",
+				"-A chain -f bar",
+				"-A PREROUTING -i eth1 -j RETURN",
+				"-A PREROUTING -j chain",
+			},
+		},
+		"mangle": {
+			Chains: []string{"chain"},
+			Rules:  []string{"-A chain -f baz"},
+		},
+	}
+	if actual := iptables.BuildV4Tables(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Actual and expected output mismatch; but instead got Actual: %#v ; Expected: %#v", actual, expected)
+	}
+	expected = map[string]*Table{
+		"nat": {Chains: []string{"chain"}, Rules: []string{"-A chain -f v6"}},
+	}
+	if actual := iptables.BuildV6Tables(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Actual and expected output mismatch; but instead got Actual: %#v ; Expected: %#v", actual, expected)
+	}
+}
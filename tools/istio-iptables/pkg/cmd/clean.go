@@ -0,0 +1,135 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/tools/istio-iptables/pkg/config"
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+)
+
+var cleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove the iptables rules and the TPROXY routing set up for the Istio sidecar",
+	Long: "Remove the iptables rules and the TPROXY routing set up for the Istio sidecar. Use the flags used to " +
+		"set up the rules. Cleaning is idempotent: the rules which are not found are ignored.",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := constructConfig()
+		var ext dep.Dependencies
+		if cfg.DryRun {
+			ext = &dep.StdoutStubDependencies{}
+		} else {
+			ext = &dep.RealDependencies{}
+		}
+		NewIptablesCleaner(cfg, ext).run()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cleanCmd)
+}
+
+// IptablesCleaner reverts the rules programmed by IptablesConfigurator.
+type IptablesCleaner struct {
+	ext    dep.Dependencies
+	cfg    *config.Config
+	owners *ownerResolver
+}
+
+func NewIptablesCleaner(cfg *config.Config, ext dep.Dependencies) *IptablesCleaner {
+	return &IptablesCleaner{
+		ext:    ext,
+		cfg:    cfg,
+		owners: newOwnerResolver(ext),
+	}
+}
+
+func (c *IptablesCleaner) run() {
+	for _, cmd := range []string{dep.IPTABLES, dep.IP6TABLES} {
+		c.removeJumps(cmd)
+		c.removeChains(cmd)
+	}
+
+	// Drop the restriction of IPv6 inbound traffic, when IPv6 is not enabled.
+	c.ext.RunQuietlyAndIgnore(dep.IP6TABLES, "-t", constants.FILTER, "-D", constants.INPUT,
+		"-m", "state", "--state", "ESTABLISHED", "-j", constants.ACCEPT)
+	c.ext.RunQuietlyAndIgnore(dep.IP6TABLES, "-t", constants.FILTER, "-D", constants.INPUT,
+		"-i", "lo", "-d", "::1", "-j", constants.ACCEPT)
+	c.ext.RunQuietlyAndIgnore(dep.IP6TABLES, "-t", constants.FILTER, "-D", constants.INPUT, "-j", constants.REJECT)
+
+	// Remove the routing of the packets marked for TPROXY, and the IPv6 address of the outbound
+	// traffic of Envoy.
+	c.ext.RunQuietlyAndIgnore(dep.IP, "-f", "inet", "rule", "del", "fwmark", c.cfg.InboundTProxyMark,
+		"lookup", c.cfg.InboundTProxyRouteTable)
+	c.ext.RunQuietlyAndIgnore(dep.IP, "-f", "inet", "route", "del", "local", "default", "dev", "lo",
+		"table", c.cfg.InboundTProxyRouteTable)
+	c.ext.RunQuietlyAndIgnore(dep.IP, "-6", "addr", "del", "::6/128", "dev", "lo")
+}
+
+// removeJumps removes the rules of the built-in chains jumping to the istio chains, and the rules
+// of the virtual interfaces.
+func (c *IptablesCleaner) removeJumps(cmd string) {
+	for _, table := range []string{constants.NAT, constants.MANGLE} {
+		c.ext.RunQuietlyAndIgnore(cmd, "-t", table, "-D", constants.PREROUTING, "-p", constants.TCP, "-j", constants.ISTIOINBOUND)
+	}
+	c.ext.RunQuietlyAndIgnore(cmd, "-t", constants.NAT, "-D", constants.OUTPUT, "-p", constants.TCP, "-j", constants.ISTIOOUTPUT)
+	for _, port := range outboundPortsInclude(c.cfg) {
+		c.ext.RunQuietlyAndIgnore(cmd, "-t", constants.NAT, "-D", constants.OUTPUT, "-p", constants.TCP, "--dport", port, "-j", constants.ISTIOOUTPUT)
+	}
+
+	// The DNS rules of the UDP traffic are in the OUTPUT chain.
+	for _, owner := range c.owners.ownerMatches(c.cfg) {
+		c.ext.RunQuietlyAndIgnore(cmd, ownerRule([]string{"-t", constants.NAT, "-D", constants.OUTPUT, "-p", constants.UDP, "--dport", constants.DNSPort},
+			owner, "-j", constants.RETURN)...)
+	}
+	c.ext.RunQuietlyAndIgnore(cmd, "-t", constants.NAT, "-D", constants.OUTPUT, "-p", constants.UDP, "--dport", constants.DNSPort,
+		"-j", constants.REDIRECT, "--to-port", c.cfg.DNSCapturePort)
+
+	for _, internalInterface := range split(c.cfg.KubevirtInterfaces) {
+		c.ext.RunQuietlyAndIgnore(cmd, "-t", constants.NAT, "-D", constants.PREROUTING, "-i", internalInterface, "-j", constants.RETURN)
+		c.ext.RunQuietlyAndIgnore(cmd, "-t", constants.NAT, "-D", constants.PREROUTING, "-i", internalInterface, "-j", constants.ISTIOREDIRECT)
+		for _, cidr := range split(c.cfg.OutboundIPRangesInclude) {
+			if cidr == "*" {
+				continue
+			}
+			c.ext.RunQuietlyAndIgnore(cmd, "-t", constants.NAT, "-D", constants.PREROUTING, "-i", internalInterface,
+				"-d", cidr, "-j", constants.ISTIOREDIRECT)
+		}
+	}
+}
+
+// removeChains flushes and deletes the istio chains. All the chains are flushed before being
+// deleted, since they refer to each other.
+func (c *IptablesCleaner) removeChains(cmd string) {
+	tableChains := []struct {
+		table  string
+		chains []string
+	}{
+		{constants.NAT, []string{constants.ISTIOOUTPUT, constants.ISTIOINBOUND, constants.ISTIOREDIRECT, constants.ISTIOINREDIRECT}},
+		{constants.MANGLE, []string{constants.ISTIOINBOUND, constants.ISTIODIVERT, constants.ISTIOTPROXY}},
+	}
+	for _, tc := range tableChains {
+		for _, chain := range tc.chains {
+			c.ext.RunQuietlyAndIgnore(cmd, "-t", tc.table, "-F", chain)
+		}
+	}
+	for _, tc := range tableChains {
+		for _, chain := range tc.chains {
+			c.ext.RunQuietlyAndIgnore(cmd, "-t", tc.table, "-X", chain)
+		}
+	}
+}
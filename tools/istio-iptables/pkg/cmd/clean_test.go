@@ -0,0 +1,100 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os/user"
+	"strings"
+	"testing"
+)
+
+// recordingDependencies records the commands run quietly, and fails the others.
+type recordingDependencies struct {
+	commands []string
+}
+
+func (r *recordingDependencies) GetLocalIP() (net.IP, error) {
+	return net.ParseIP("127.0.0.1"), nil
+}
+
+func (r *recordingDependencies) LookupUser() (*user.User, error) {
+	return &user.User{Uid: "0"}, nil
+}
+
+func (r *recordingDependencies) LookupUserID(name string) (string, error) {
+	return "", fmt.Errorf("unknown user %s", name)
+}
+
+func (r *recordingDependencies) LookupGroupID(name string) (string, error) {
+	return "", fmt.Errorf("unknown group %s", name)
+}
+
+func (r *recordingDependencies) RunOrFail(cmd string, args ...string) {
+	panic(fmt.Sprintf("unexpected RunOrFail: %s %s", cmd, strings.Join(args, " ")))
+}
+
+func (r *recordingDependencies) Run(cmd string, args ...string) error {
+	return fmt.Errorf("unexpected Run: %s %s", cmd, strings.Join(args, " "))
+}
+
+func (r *recordingDependencies) RunQuietlyAndIgnore(cmd string, args ...string) {
+	r.commands = append(r.commands, fmt.Sprintf("%s %s", cmd, strings.Join(args, " ")))
+}
+
+func (r *recordingDependencies) RunWithOutput(cmd string, args ...string) ([]byte, error) {
+	return nil, fmt.Errorf("unexpected RunWithOutput: %s %s", cmd, strings.Join(args, " "))
+}
+
+func TestClean(t *testing.T) {
+	cfg := constructConfig()
+	cfg.KubevirtInterfaces = "eth1"
+	cfg.OutboundIPRangesInclude = "10.0.0.0/8"
+	ext := &recordingDependencies{}
+	NewIptablesCleaner(cfg, ext).run()
+
+	index := make(map[string]int, len(ext.commands))
+	for i, c := range ext.commands {
+		index[c] = i
+	}
+	expected := []string{
+		"iptables -t nat -D PREROUTING -p tcp -j ISTIO_INBOUND",
+		"iptables -t mangle -D PREROUTING -p tcp -j ISTIO_INBOUND",
+		"iptables -t nat -D OUTPUT -p tcp -j ISTIO_OUTPUT",
+		"iptables -t nat -D PREROUTING -i eth1 -d 10.0.0.0/8 -j ISTIO_REDIRECT",
+		"ip6tables -t nat -D OUTPUT -p tcp -j ISTIO_OUTPUT",
+		"iptables -t nat -F ISTIO_REDIRECT",
+		"iptables -t nat -X ISTIO_REDIRECT",
+		"iptables -t mangle -X ISTIO_TPROXY",
+		"ip6tables -t filter -D INPUT -j REJECT",
+		"ip -f inet rule del fwmark 1337 lookup 133",
+		"ip -f inet route del local default dev lo table 133",
+		"ip -6 addr del ::6/128 dev lo",
+	}
+	for _, c := range expected {
+		if _, f := index[c]; !f {
+			t.Errorf("missing command %q in %#v", c, ext.commands)
+		}
+	}
+
+	// The jumps are removed before the chains, which are all flushed before being deleted.
+	if index["iptables -t nat -D OUTPUT -p tcp -j ISTIO_OUTPUT"] > index["iptables -t nat -X ISTIO_OUTPUT"] {
+		t.Errorf("ISTIO_OUTPUT deleted before the jump of OUTPUT")
+	}
+	if index["iptables -t mangle -F ISTIO_TPROXY"] > index["iptables -t nat -X ISTIO_REDIRECT"] {
+		t.Errorf("ISTIO_REDIRECT deleted before all chains are flushed")
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+)
+
+// istioChainPrefix is the prefix of the chains created by istio-iptables. The live rules of these
+// chains, or jumping to them, are expected to be created by istio-iptables.
+const istioChainPrefix = "ISTIO_"
+
+// errRulesDrifted is returned with --diff when the rules drifted from the live ones.
+var errRulesDrifted = errors.New("the rules drifted from the live rules")
+
+// Ruleset is the JSON rendering of the rules, with --dry-run=json.
+type Ruleset struct {
+	IPv4 map[string]*builder.Table `json:"iptables"`
+	IPv6 map[string]*builder.Table `json:"ip6tables"`
+}
+
+func (iptConfigurator *IptablesConfigurator) printJSON(w io.Writer) error {
+	out, err := json.MarshalIndent(Ruleset{
+		IPv4: iptConfigurator.iptables.BuildV4Tables(),
+		IPv6: iptConfigurator.iptables.BuildV6Tables(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal the rules: %v", err)
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
+
+// diff compares the rules with the live rules listed by iptables-save and ip6tables-save, writes
+// the drift to w, and returns whether there is any.
+func (iptConfigurator *IptablesConfigurator) diff(w io.Writer) (bool, error) {
+	drift := false
+	for _, family := range []struct {
+		iptables string
+		save     string
+		tables   map[string]*builder.Table
+	}{
+		{dep.IPTABLES, dep.IPTABLESSAVE, iptConfigurator.iptables.BuildV4Tables()},
+		{dep.IP6TABLES, dep.IP6TABLESSAVE, iptConfigurator.iptables.BuildV6Tables()},
+	} {
+		out, err := iptConfigurator.ext.RunWithOutput(family.save)
+		if err != nil {
+			if len(family.tables) == 0 {
+				// Hosts without IPv6 support may lack ip6tables-save, and no rules are expected anyway.
+				continue
+			}
+			return false, fmt.Errorf("unable to list the live rules with %s: %v", family.save, err)
+		}
+		for _, line := range diffTables(family.tables, parseIptablesSave(out)) {
+			drift = true
+			if _, err := fmt.Fprintf(w, "%s %s\n", family.iptables, line); err != nil {
+				return drift, err
+			}
+		}
+	}
+	return drift, nil
+}
+
+// parseIptablesSave parses the tables of the output of iptables-save.
+func parseIptablesSave(out []byte) map[string]*builder.Table {
+	tables := map[string]*builder.Table{}
+	var table *builder.Table
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "*"):
+			table = &builder.Table{Chains: []string{}, Rules: []string{}}
+			tables[strings.TrimSpace(line[1:])] = table
+		case table == nil:
+			// Comments before the first table.
+		case strings.HasPrefix(line, ":"):
+			if fields := strings.Fields(line[1:]); len(fields) > 0 {
+				table.Chains = append(table.Chains, fields[0])
+			}
+		case strings.HasPrefix(line, "-A "):
+			table.Rules = append(table.Rules, line)
+		}
+	}
+	return tables
+}
+
+// diffTables returns the expected chains and rules missing from the live tables, and the live chains
+// and rules managed by istio-iptables which are not expected. Each rule is compared regardless of its
+// position in the chain.
+func diffTables(expected, live map[string]*builder.Table) []string {
+	names := []string{}
+	for name := range expected {
+		names = append(names, name)
+	}
+	for name := range live {
+		if _, f := expected[name]; !f {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	empty := &builder.Table{}
+	drift := []string{}
+	for _, name := range names {
+		exp, act := expected[name], live[name]
+		if exp == nil {
+			exp = empty
+		}
+		if act == nil {
+			act = empty
+		}
+
+		managed := map[string]bool{}
+		for _, chain := range exp.Chains {
+			managed[chain] = true
+		}
+		isManaged := func(chain string) bool {
+			return managed[chain] || strings.HasPrefix(chain, istioChainPrefix)
+		}
+
+		liveChains := map[string]bool{}
+		for _, chain := range act.Chains {
+			liveChains[chain] = true
+		}
+		for _, chain := range exp.Chains {
+			if !liveChains[chain] {
+				drift = append(drift, fmt.Sprintf("-t %s: missing chain %s", name, chain))
+			}
+		}
+		for _, chain := range act.Chains {
+			if isManaged(chain) && !managed[chain] {
+				drift = append(drift, fmt.Sprintf("-t %s: unexpected chain %s", name, chain))
+			}
+		}
+
+		liveRules := map[string]int{}
+		liveManaged := []string{}
+		for _, rule := range act.Rules {
+			chain, target := ruleChainAndTarget(rule)
+			if isManaged(chain) || isManaged(target) {
+				liveRules[normalizeRule(rule)]++
+				liveManaged = append(liveManaged, rule)
+			}
+		}
+		expectedRules := map[string]int{}
+		for _, rule := range exp.Rules {
+			expectedRules[normalizeRule(rule)]++
+		}
+		for _, rule := range exp.Rules {
+			if n := normalizeRule(rule); liveRules[n] > 0 {
+				liveRules[n]--
+			} else {
+				drift = append(drift, fmt.Sprintf("-t %s: missing rule %s", name, rule))
+			}
+		}
+		for _, rule := range liveManaged {
+			if n := normalizeRule(rule); expectedRules[n] > 0 {
+				expectedRules[n]--
+			} else {
+				drift = append(drift, fmt.Sprintf("-t %s: unexpected rule %s", name, rule))
+			}
+		}
+	}
+	return drift
+}
+
+// ruleChainAndTarget returns the chain of a rule in iptables-save format, and the target it jumps to.
+func ruleChainAndTarget(rule string) (string, string) {
+	fields := strings.Fields(rule)
+	chain, target := "", ""
+	if len(fields) > 1 {
+		chain = fields[1]
+	}
+	for i := 2; i < len(fields)-1; i++ {
+		if fields[i] == "-j" || fields[i] == "-g" {
+			target = fields[i+1]
+		}
+	}
+	return chain, target
+}
+
+// normalizeRule returns a canonical form of a rule in iptables-save format, so that the rules built
+// by istio-iptables can be compared with the ones listed by iptables-save, which reorders the options,
+// adds the implicit matches and masks, and prints the marks in hexadecimal.
+func normalizeRule(rule string) string {
+	fields := strings.Fields(rule)
+	if len(fields) < 2 {
+		return rule
+	}
+	// Split the options, with their negation and values.
+	options := [][]string{}
+	for _, field := range fields[2:] {
+		last := len(options) - 1
+		startsOption := strings.HasPrefix(field, "-") || field == "!"
+		if last < 0 || (startsOption && !(len(options[last]) == 1 && options[last][0] == "!")) {
+			options = append(options, []string{field})
+		} else {
+			options[last] = append(options[last], field)
+		}
+	}
+
+	normalized := []string{}
+	for _, option := range options {
+		name := option[0]
+		if name == "!" && len(option) > 1 {
+			name = option[1]
+		}
+		values := option[len(option)-1:]
+		switch name {
+		case "-m":
+			if values[0] == "tcp" || values[0] == "udp" {
+				// Implicit match of the protocol.
+				continue
+			}
+		case "--on-ip":
+			if values[0] == "0.0.0.0" || values[0] == "::" {
+				continue
+			}
+		case "--to-port":
+			option[len(option)-2] = "--to-ports"
+		case "-s", "-d", "--source", "--destination":
+			if ip := net.ParseIP(values[0]); ip != nil {
+				if ip.To4() != nil {
+					values[0] += "/32"
+				} else {
+					values[0] += "/128"
+				}
+			}
+		case "--set-mark", "--set-xmark", "--tproxy-mark":
+			if name == "--set-mark" {
+				option[len(option)-2] = "--set-xmark"
+			}
+			values[0] = normalizeMark(values[0])
+		}
+		normalized = append(normalized, strings.Join(option, " "))
+	}
+	sort.Strings(normalized)
+	return strings.Join(append(fields[:2], normalized...), " ")
+}
+
+// normalizeMark returns the hexadecimal value/mask form of a mark.
+func normalizeMark(mark string) string {
+	parts := strings.SplitN(mark, "/", 2)
+	value, err := strconv.ParseUint(parts[0], 0, 32)
+	if err != nil {
+		return mark
+	}
+	mask := uint64(0xffffffff)
+	if len(parts) == 2 {
+		if mask, err = strconv.ParseUint(parts[1], 0, 32); err != nil {
+			return mark
+		}
+	}
+	return fmt.Sprintf("0x%x/0x%x", value, mask)
+}
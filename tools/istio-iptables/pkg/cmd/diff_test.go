@@ -0,0 +1,202 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+)
+
+// saveDependencies returns the configured outputs of iptables-save and ip6tables-save.
+type saveDependencies struct {
+	recordingDependencies
+	outputs map[string]string
+}
+
+func (s *saveDependencies) RunWithOutput(cmd string, args ...string) ([]byte, error) {
+	out, f := s.outputs[cmd]
+	if !f {
+		return nil, fmt.Errorf("%s: command not found", cmd)
+	}
+	return []byte(out), nil
+}
+
+const liveNat = `# Generated by iptables-save v1.6.1
+*nat
+:PREROUTING ACCEPT [0:0]
+:INPUT ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:POSTROUTING ACCEPT [0:0]
+:ISTIO_OUTPUT - [0:0]
+:ISTIO_REDIRECT - [0:0]
+:ISTIO_STALE - [0:0]
+-A OUTPUT -p tcp -j ISTIO_OUTPUT
+-A OUTPUT -p tcp -m tcp --dport 8080 -j KUBE-SERVICES
+-A ISTIO_OUTPUT -s 127.0.0.6/32 -o lo -j RETURN
+-A ISTIO_OUTPUT -m owner --uid-owner 1337 -j RETURN
+-A ISTIO_OUTPUT -p tcp -m tcp --dport 3306 -j RETURN
+-A ISTIO_REDIRECT -p tcp -j REDIRECT --to-ports 15001
+COMMIT
+# Completed
+`
+
+func diffConfigurator() *IptablesConfigurator {
+	cfg := constructConfig()
+	cfg.Diff = true
+	iptConfigurator := NewIptablesConfigurator(cfg)
+	iptConfigurator.iptables.AppendRuleV4(constants.ISTIOREDIRECT, constants.NAT, "-p", constants.TCP, "-j", constants.REDIRECT, "--to-port", "15001")
+	iptConfigurator.iptables.AppendRuleV4(constants.OUTPUT, constants.NAT, "-p", constants.TCP, "-j", constants.ISTIOOUTPUT)
+	iptConfigurator.iptables.AppendRuleV4(constants.ISTIOOUTPUT, constants.NAT, "-o", "lo", "-s", "127.0.0.6/32", "-j", constants.RETURN)
+	iptConfigurator.iptables.AppendRuleV4(constants.ISTIOOUTPUT, constants.NAT, "-m", "owner", "--uid-owner", "1337", "-j", constants.RETURN)
+	return iptConfigurator
+}
+
+func TestParseDryRun(t *testing.T) {
+	cases := []struct {
+		value  string
+		dryRun bool
+		format string
+		err    bool
+	}{
+		{value: "false"},
+		{value: "true", dryRun: true},
+		{value: "json", dryRun: true, format: constants.DryRunJSON},
+		{value: "yaml", err: true},
+	}
+	for _, c := range cases {
+		dryRun, format, err := parseDryRun(c.value)
+		if dryRun != c.dryRun || format != c.format || (err != nil) != c.err {
+			t.Errorf("parseDryRun(%q) => %v, %q, %v; want %v, %q, error %v", c.value, dryRun, format, err, c.dryRun, c.format, c.err)
+		}
+	}
+}
+
+func TestNewIptablesConfiguratorDependencies(t *testing.T) {
+	cfg := constructConfig()
+	cfg.DryRun = true
+	cfg.DryRunFormat = constants.DryRunJSON
+	if _, ok := NewIptablesConfigurator(cfg).ext.(*dep.SilentStubDependencies); !ok {
+		t.Errorf("got %T dependencies for --dry-run=json, want silent stub", NewIptablesConfigurator(cfg).ext)
+	}
+	cfg.Diff = true
+	if _, ok := NewIptablesConfigurator(cfg).ext.(*dep.ReadOnlyDependencies); !ok {
+		t.Errorf("got %T dependencies for --diff, want read only", NewIptablesConfigurator(cfg).ext)
+	}
+}
+
+func TestPrintJSON(t *testing.T) {
+	iptConfigurator := diffConfigurator()
+	iptConfigurator.iptables.InsertRuleV4(constants.PREROUTING, constants.NAT, 1, "-i", "eth1", "-j", constants.RETURN)
+	var out bytes.Buffer
+	if err := iptConfigurator.printJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var ruleset Ruleset
+	if err := json.Unmarshal(out.Bytes(), &ruleset); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", out.String(), err)
+	}
+	expected := Ruleset{
+		IPv4: map[string]*builder.Table{
+			constants.NAT: {
+				Chains: []string{constants.ISTIOREDIRECT, constants.ISTIOOUTPUT},
+				Rules: []string{
+					"-A ISTIO_REDIRECT -p tcp -j REDIRECT --to-port 15001",
+					"-A OUTPUT -p tcp -j ISTIO_OUTPUT",
+					"-A ISTIO_OUTPUT -o lo -s 127.0.0.6/32 -j RETURN",
+					"-A ISTIO_OUTPUT -m owner --uid-owner 1337 -j RETURN",
+					"-A PREROUTING -i eth1 -j RETURN",
+				},
+			},
+		},
+		IPv6: map[string]*builder.Table{},
+	}
+	if !reflect.DeepEqual(ruleset, expected) {
+		t.Errorf("Output mismatch\nExpected: %#v\nActual: %#v", expected, ruleset)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	iptConfigurator := diffConfigurator()
+	iptConfigurator.iptables.AppendRuleV4(constants.ISTIOOUTPUT, constants.NAT, "-o", "lo", "!", "-d", "127.0.0.1", "-j", constants.ISTIOINREDIRECT)
+	iptConfigurator.ext = &saveDependencies{outputs: map[string]string{dep.IPTABLESSAVE: liveNat}}
+
+	var out bytes.Buffer
+	drift, err := iptConfigurator.diff(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"iptables -t nat: unexpected chain ISTIO_STALE",
+		"iptables -t nat: missing rule -A ISTIO_OUTPUT -o lo ! -d 127.0.0.1 -j ISTIO_IN_REDIRECT",
+		"iptables -t nat: unexpected rule -A ISTIO_OUTPUT -p tcp -m tcp --dport 3306 -j RETURN",
+	}
+	if actual := strings.Split(strings.TrimSpace(out.String()), "\n"); !drift || !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Output mismatch (drift %v)\nExpected: %#v\nActual: %#v", drift, expected, actual)
+	}
+
+	// The rules match once the live rules are fixed.
+	live := strings.Replace(liveNat, ":ISTIO_STALE - [0:0]\n", "", 1)
+	live = strings.Replace(live, "-A ISTIO_OUTPUT -p tcp -m tcp --dport 3306 -j RETURN",
+		"-A ISTIO_OUTPUT ! -d 127.0.0.1/32 -o lo -j ISTIO_IN_REDIRECT", 1)
+	iptConfigurator.ext = &saveDependencies{outputs: map[string]string{dep.IPTABLESSAVE: live, dep.IP6TABLESSAVE: ""}}
+	out.Reset()
+	if drift, err = iptConfigurator.diff(&out); err != nil || drift {
+		t.Errorf("got drift %v, error %v, output %q; want no drift", drift, err, out.String())
+	}
+}
+
+func TestNormalizeRule(t *testing.T) {
+	cases := []struct {
+		built string
+		saved string
+	}{
+		{
+			"-A ISTIO_OUTPUT -o lo ! -d 127.0.0.1/32 -j ISTIO_IN_REDIRECT",
+			"-A ISTIO_OUTPUT ! -d 127.0.0.1/32 -o lo -j ISTIO_IN_REDIRECT",
+		},
+		{
+			"-A INPUT -i lo -d ::1 -j ACCEPT",
+			"-A INPUT -d ::1/128 -i lo -j ACCEPT",
+		},
+		{
+			"-A ISTIO_DIVERT -j MARK --set-mark 1337",
+			"-A ISTIO_DIVERT -j MARK --set-xmark 0x539/0xffffffff",
+		},
+		{
+			"-A ISTIO_TPROXY ! -d 127.0.0.1/32 -p tcp -j TPROXY --tproxy-mark 1337/0xffffffff --on-port 15001",
+			"-A ISTIO_TPROXY ! -d 127.0.0.1/32 -p tcp -j TPROXY --on-port 15001 --on-ip 0.0.0.0 --tproxy-mark 0x539/0xffffffff",
+		},
+		{
+			"-A ISTIO_INBOUND -p tcp --dport 22 -j RETURN",
+			"-A ISTIO_INBOUND -p tcp -m tcp --dport 22 -j RETURN",
+		},
+	}
+	for _, c := range cases {
+		if built, saved := normalizeRule(c.built), normalizeRule(c.saved); built != saved {
+			t.Errorf("normalized rules differ: %q from %q, %q from %q", built, c.built, saved, c.saved)
+		}
+	}
+	if normalizeRule("-A ISTIO_OUTPUT -d 10.0.0.0/8 -j RETURN") == normalizeRule("-A ISTIO_OUTPUT ! -d 10.0.0.0/8 -j RETURN") {
+		t.Errorf("negated rules are normalized to the same rule")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strconv"
+	"strings"
+
+	"istio.io/pkg/log"
+
+	"istio.io/istio/tools/istio-iptables/pkg/config"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+)
+
+// excludeOwnersGroupPrefix is the prefix of the groups in the excluded owners.
+const excludeOwnersGroupPrefix = "group:"
+
+// ownerResolver resolves the names of the users and groups of the owner matches to their IDs,
+// caching the results. A name which can't be resolved is kept, so that iptables resolves it.
+type ownerResolver struct {
+	ext    dep.Dependencies
+	uids   map[string]string
+	gids   map[string]string
+	failed map[string]bool
+}
+
+func newOwnerResolver(ext dep.Dependencies) *ownerResolver {
+	return &ownerResolver{
+		ext:    ext,
+		uids:   map[string]string{},
+		gids:   map[string]string{},
+		failed: map[string]bool{},
+	}
+}
+
+func (r *ownerResolver) resolve(name string, cache map[string]string, lookup func(string) (string, error), kind string) string {
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return name
+	}
+	if id, f := cache[name]; f {
+		return id
+	}
+	id, err := lookup(name)
+	if err != nil || id == "" {
+		if !r.failed[kind+name] {
+			log.Warnf("Unable to resolve the %s %s, leaving it to iptables: %v", kind, name, err)
+			r.failed[kind+name] = true
+		}
+		id = name
+	}
+	cache[name] = id
+	return id
+}
+
+// uid returns the UID of a user, by name or UID.
+func (r *ownerResolver) uid(user string) string {
+	return r.resolve(user, r.uids, r.ext.LookupUserID, "user")
+}
+
+// gid returns the GID of a group, by name or GID.
+func (r *ownerResolver) gid(group string) string {
+	return r.resolve(group, r.gids, r.ext.LookupGroupID, "group")
+}
+
+// ownerMatches returns the owner matches of the traffic bypassing Envoy: the traffic of the proxy
+// users and groups, then the traffic of the excluded owners.
+func (r *ownerResolver) ownerMatches(cfg *config.Config) [][]string {
+	matches := [][]string{}
+	for _, uid := range split(cfg.ProxyUID) {
+		matches = append(matches, []string{"-m", "owner", "--uid-owner", r.uid(uid)})
+	}
+	for _, gid := range split(cfg.ProxyGID) {
+		matches = append(matches, []string{"-m", "owner", "--gid-owner", r.gid(gid)})
+	}
+	for _, owner := range split(cfg.ExcludeOwners) {
+		if strings.HasPrefix(owner, excludeOwnersGroupPrefix) {
+			matches = append(matches, []string{"-m", "owner", "--gid-owner", r.gid(strings.TrimPrefix(owner, excludeOwnersGroupPrefix))})
+		} else {
+			matches = append(matches, []string{"-m", "owner", "--uid-owner", r.uid(owner)})
+		}
+	}
+	return matches
+}
+
+// ownerRule returns the params of a rule matching the owner, between the prefix and suffix params.
+func ownerRule(prefix []string, owner []string, suffix ...string) []string {
+	params := append([]string{}, prefix...)
+	params = append(params, owner...)
+	return append(params, suffix...)
+}
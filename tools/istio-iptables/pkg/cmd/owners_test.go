@@ -0,0 +1,105 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// lookupDependencies resolves the users and groups from maps, counting the lookups.
+type lookupDependencies struct {
+	recordingDependencies
+	users   map[string]string
+	groups  map[string]string
+	lookups int
+}
+
+func (l *lookupDependencies) LookupUserID(name string) (string, error) {
+	l.lookups++
+	if id, f := l.users[name]; f {
+		return id, nil
+	}
+	return "", fmt.Errorf("unknown user %s", name)
+}
+
+func (l *lookupDependencies) LookupGroupID(name string) (string, error) {
+	l.lookups++
+	if id, f := l.groups[name]; f {
+		return id, nil
+	}
+	return "", fmt.Errorf("unknown group %s", name)
+}
+
+func TestOwnerMatches(t *testing.T) {
+	ext := &lookupDependencies{
+		users:  map[string]string{"istio-proxy": "1337", "backup": "2000"},
+		groups: map[string]string{"istio-proxy": "1337", "backup": "3000"},
+	}
+	r := newOwnerResolver(ext)
+	cfg := constructConfig()
+	cfg.ProxyUID = "istio-proxy,0"
+	cfg.ProxyGID = "istio-proxy"
+	cfg.ExcludeOwners = "backup,1001,unknown,group:backup,group:unknown"
+
+	expected := [][]string{
+		{"-m", "owner", "--uid-owner", "1337"},
+		{"-m", "owner", "--uid-owner", "0"},
+		{"-m", "owner", "--gid-owner", "1337"},
+		{"-m", "owner", "--uid-owner", "2000"},
+		{"-m", "owner", "--uid-owner", "1001"},
+		// The names which can't be resolved are left to iptables.
+		{"-m", "owner", "--uid-owner", "unknown"},
+		{"-m", "owner", "--gid-owner", "3000"},
+		{"-m", "owner", "--gid-owner", "unknown"},
+	}
+	if actual := r.ownerMatches(cfg); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Output mismatch\nExpected: %#v\nActual: %#v", expected, actual)
+	}
+	lookups := ext.lookups
+	if lookups != 6 {
+		t.Errorf("got %d lookups, want 6: the IDs are not looked up", lookups)
+	}
+	r.ownerMatches(cfg)
+	if ext.lookups != lookups {
+		t.Errorf("got %d lookups after resolving the owners again, want %d cached", ext.lookups, lookups)
+	}
+}
+
+func TestHandleDNSUDPWithExcludeOwners(t *testing.T) {
+	cfg := constructConfig()
+	cfg.ProxyUID = "1337"
+	cfg.ProxyGID = "1337"
+	cfg.ExcludeOwners = "backup,group:backup"
+	cfg.DNSCapturePort = "15053"
+	iptConfigurator := NewIptablesConfigurator(cfg)
+	iptConfigurator.owners = newOwnerResolver(&lookupDependencies{
+		users:  map[string]string{"backup": "2000"},
+		groups: map[string]string{"backup": "3000"},
+	})
+	iptConfigurator.handleDNSUDP(iptConfigurator.iptables.AppendRuleV4)
+
+	expected := []string{
+		"iptables -t nat -A OUTPUT -p udp --dport 53 -m owner --uid-owner 1337 -j RETURN",
+		"iptables -t nat -A OUTPUT -p udp --dport 53 -m owner --gid-owner 1337 -j RETURN",
+		"iptables -t nat -A OUTPUT -p udp --dport 53 -m owner --uid-owner 2000 -j RETURN",
+		"iptables -t nat -A OUTPUT -p udp --dport 53 -m owner --gid-owner 3000 -j RETURN",
+		"iptables -t nat -A OUTPUT -p udp --dport 53 -j REDIRECT --to-port 15053",
+	}
+	if actual := FormatIptablesCommands(iptConfigurator.iptables.BuildV4()); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Output mismatch\nExpected: %#v\nActual: %#v", expected, actual)
+	}
+}
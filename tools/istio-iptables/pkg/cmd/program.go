@@ -0,0 +1,51 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"istio.io/istio/tools/istio-iptables/pkg/config"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+)
+
+// CommandError is the error of a command which failed while programming the rules.
+type CommandError struct {
+	Command string
+	Args    []string
+	Err     error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Command, strings.Join(e.Args, " "), e.Err)
+}
+
+// Program programs the rules of the config in-process, and returns the programmed rules. The commands
+// are run with ext, or with the dependencies selected by the config if nil. Unlike istio-iptables,
+// it returns the errors instead of exiting: the error of a command which failed is a *CommandError.
+func Program(cfg *config.Config, ext dep.Dependencies) (*Ruleset, error) {
+	if ext == nil {
+		ext = dependencies(cfg)
+	}
+	iptConfigurator := newIptablesConfigurator(cfg, ext)
+	if err := iptConfigurator.run(); err != nil {
+		return nil, err
+	}
+	return &Ruleset{
+		IPv4: iptConfigurator.iptables.BuildV4Tables(),
+		IPv6: iptConfigurator.iptables.BuildV6Tables(),
+	}, nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+)
+
+// parseReconcile validates the value of the reconcile flag.
+func parseReconcile(value string) (string, error) {
+	switch value {
+	case "", constants.ReconcileSkip, constants.ReconcileReplace, constants.ReconcileFail:
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid value %q for --%s: must be %q, %q or %q", value, constants.Reconcile,
+		constants.ReconcileSkip, constants.ReconcileReplace, constants.ReconcileFail)
+}
+
+// liveRules lists the live rules with iptables-save and ip6tables-save. The tables of a command
+// which fails are empty, since the host may lack IPv6 support.
+func (iptConfigurator *IptablesConfigurator) liveRules() {
+	for _, family := range []struct {
+		save string
+		live *map[string]*builder.Table
+	}{
+		{dep.IPTABLESSAVE, &iptConfigurator.liveV4},
+		{dep.IP6TABLESSAVE, &iptConfigurator.liveV6},
+	} {
+		out, err := iptConfigurator.ext.RunWithOutput(family.save)
+		if err != nil {
+			*family.live = map[string]*builder.Table{}
+			continue
+		}
+		*family.live = parseIptablesSave(out)
+	}
+}
+
+// existingIstioChains returns the ISTIO_* chains of the live rules.
+func (iptConfigurator *IptablesConfigurator) existingIstioChains() []string {
+	chains := []string{}
+	for _, family := range []struct {
+		iptables string
+		live     map[string]*builder.Table
+	}{
+		{dep.IPTABLES, iptConfigurator.liveV4},
+		{dep.IP6TABLES, iptConfigurator.liveV6},
+	} {
+		for name, table := range family.live {
+			for _, chain := range table.Chains {
+				if strings.HasPrefix(chain, istioChainPrefix) {
+					chains = append(chains, fmt.Sprintf("%s (%s %s)", chain, family.iptables, name))
+				}
+			}
+		}
+	}
+	sort.Strings(chains)
+	return chains
+}
+
+// reconcile checks whether the rules were already programmed, before any change. It returns
+// false if the rules must not be programmed again.
+func (iptConfigurator *IptablesConfigurator) reconcile() (bool, error) {
+	if iptConfigurator.cfg.Reconcile == "" || iptConfigurator.cfg.Diff {
+		return true, nil
+	}
+	iptConfigurator.liveRules()
+	existing := iptConfigurator.existingIstioChains()
+	if len(existing) == 0 {
+		return true, nil
+	}
+	switch iptConfigurator.cfg.Reconcile {
+	case constants.ReconcileSkip:
+		fmt.Printf("iptables already configured, found chains %s\n", strings.Join(existing, ", "))
+		return false, nil
+	case constants.ReconcileFail:
+		return false, fmt.Errorf("iptables already configured, found chains %s: "+
+			"run with --%s=%s to program the rules again, or --%s=%s to keep them",
+			strings.Join(existing, ", "), constants.Reconcile, constants.ReconcileReplace, constants.Reconcile, constants.ReconcileSkip)
+	}
+	return true, nil
+}
+
+// buildReplaceRestore creates the iptables-restore input replacing the live rules of istio-iptables
+// by the expected ones in a single transaction: the rules of the built-in chains jumping to the
+// ISTIO_* chains or expected again are deleted, the ISTIO_* chains are flushed, the stale ones are
+// deleted, and the expected rules are added.
+func buildReplaceRestore(expected, live map[string]*builder.Table) string {
+	names := []string{}
+	for name := range expected {
+		names = append(names, name)
+	}
+	for name := range live {
+		if _, f := expected[name]; !f {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		exp, act := expected[name], live[name]
+		if exp == nil {
+			exp = &builder.Table{}
+		}
+		if act == nil {
+			act = &builder.Table{}
+		}
+
+		expectedChains := map[string]bool{}
+		for _, chain := range exp.Chains {
+			expectedChains[chain] = true
+		}
+		isManaged := func(chain string) bool {
+			return expectedChains[chain] || strings.HasPrefix(chain, istioChainPrefix)
+		}
+		expectedBuiltIn := map[string]bool{}
+		for _, rule := range exp.Rules {
+			if chain, _ := ruleChainAndTarget(rule); !isManaged(chain) {
+				expectedBuiltIn[normalizeRule(rule)] = true
+			}
+		}
+
+		lines := []string{}
+		for _, rule := range act.Rules {
+			chain, target := ruleChainAndTarget(rule)
+			if !isManaged(chain) && (isManaged(target) || expectedBuiltIn[normalizeRule(rule)]) {
+				lines = append(lines, "-D"+strings.TrimPrefix(rule, "-A"))
+			}
+		}
+		stale := []string{}
+		for _, chain := range act.Chains {
+			if isManaged(chain) && !expectedChains[chain] {
+				stale = append(stale, chain)
+			}
+		}
+		// Declaring a chain creates it, or flushes it if it exists.
+		for _, chain := range append(append([]string{}, exp.Chains...), stale...) {
+			lines = append(lines, fmt.Sprintf(":%s - [0:0]", chain))
+		}
+		for _, chain := range stale {
+			lines = append(lines, "-X "+chain)
+		}
+		lines = append(lines, exp.Rules...)
+
+		if len(lines) > 0 {
+			fmt.Fprintln(&b, "*"+name)
+			for _, line := range lines {
+				fmt.Fprintln(&b, line)
+			}
+			fmt.Fprintln(&b, "COMMIT")
+		}
+	}
+	return b.String()
+}
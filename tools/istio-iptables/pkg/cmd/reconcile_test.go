@@ -0,0 +1,87 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+)
+
+func TestParseReconcile(t *testing.T) {
+	for _, value := range []string{"", constants.ReconcileSkip, constants.ReconcileReplace, constants.ReconcileFail} {
+		if _, err := parseReconcile(value); err != nil {
+			t.Errorf("parseReconcile(%q) failed: %v", value, err)
+		}
+	}
+	if _, err := parseReconcile("flush"); err == nil {
+		t.Errorf("parseReconcile(%q) succeeded, want error", "flush")
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	cases := []struct {
+		reconcile string
+		live      string
+		configure bool
+		err       string
+	}{
+		{reconcile: "", live: liveNat, configure: true},
+		{reconcile: constants.ReconcileSkip, live: "*nat\n:OUTPUT ACCEPT [0:0]\nCOMMIT\n", configure: true},
+		{reconcile: constants.ReconcileSkip, live: liveNat},
+		{reconcile: constants.ReconcileReplace, live: liveNat, configure: true},
+		{reconcile: constants.ReconcileFail, live: liveNat, err: "found chains ISTIO_OUTPUT (iptables nat), ISTIO_REDIRECT (iptables nat)"},
+	}
+	for _, c := range cases {
+		cfg := constructConfig()
+		cfg.Reconcile = c.reconcile
+		iptConfigurator := NewIptablesConfigurator(cfg)
+		// ip6tables-save fails, as on hosts without IPv6 support.
+		iptConfigurator.ext = &saveDependencies{outputs: map[string]string{dep.IPTABLESSAVE: c.live}}
+		configure, err := iptConfigurator.reconcile()
+		if configure != c.configure || (err == nil) != (c.err == "") || (err != nil && !strings.Contains(err.Error(), c.err)) {
+			t.Errorf("reconcile %q: got %v, %v; want %v, error %q", c.reconcile, configure, err, c.configure, c.err)
+		}
+	}
+}
+
+func TestBuildReplaceRestore(t *testing.T) {
+	iptConfigurator := diffConfigurator()
+	iptConfigurator.iptables.AppendRuleV4(constants.OUTPUT, constants.NAT, "-p", constants.UDP, "--dport", "53", "-j", constants.RETURN)
+	live := strings.Replace(liveNat, "-A OUTPUT -p tcp -j ISTIO_OUTPUT\n",
+		"-A OUTPUT -p tcp -j ISTIO_OUTPUT\n-A OUTPUT -p udp -m udp --dport 53 -j RETURN\n-A OUTPUT -p tcp -j ISTIO_OUTPUT\n", 1)
+
+	actual := buildReplaceRestore(iptConfigurator.iptables.BuildV4Tables(), parseIptablesSave([]byte(live)))
+	expected := `*nat
+-D OUTPUT -p tcp -j ISTIO_OUTPUT
+-D OUTPUT -p udp -m udp --dport 53 -j RETURN
+-D OUTPUT -p tcp -j ISTIO_OUTPUT
+:ISTIO_REDIRECT - [0:0]
+:ISTIO_OUTPUT - [0:0]
+:ISTIO_STALE - [0:0]
+-X ISTIO_STALE
+-A ISTIO_REDIRECT -p tcp -j REDIRECT --to-port 15001
+-A OUTPUT -p tcp -j ISTIO_OUTPUT
+-A OUTPUT -p udp --dport 53 -j RETURN
+-A ISTIO_OUTPUT -o lo -s 127.0.0.6/32 -j RETURN
+-A ISTIO_OUTPUT -m owner --uid-owner 1337 -j RETURN
+COMMIT
+`
+	if actual != expected {
+		t.Errorf("Output mismatch\nExpected:\n%s\nActual:\n%s", expected, actual)
+	}
+}
@@ -15,7 +15,9 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"istio.io/istio/tools/istio-iptables/pkg/config"
@@ -24,6 +26,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"istio.io/pkg/env"
 	"istio.io/pkg/log"
 )
 
@@ -33,29 +36,67 @@ var rootCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		config := constructConfig()
 		iptConfigurator := NewIptablesConfigurator(config)
-		iptConfigurator.run()
+		if err := iptConfigurator.run(); err != nil {
+			// The drift has already been reported.
+			if err != errRulesDrifted {
+				fmt.Println(err)
+			}
+			os.Exit(1)
+		}
 	},
 }
 
+// dnsCaptureByAgent is set by the agent when its DNS proxy is enabled.
+var dnsCaptureByAgent = env.RegisterBoolVar("ISTIO_META_DNS_CAPTURE", false,
+	"If set, the DNS queries of the application are redirected to the DNS proxy of the agent").Get()
+
 func constructConfig() *config.Config {
+	dryRun, dryRunFormat, err := parseDryRun(viper.GetString(constants.DryRun))
+	if err != nil {
+		handleError(err)
+	}
+	reconcile, err := parseReconcile(viper.GetString(constants.Reconcile))
+	if err != nil {
+		handleError(err)
+	}
 	return &config.Config{
 		ProxyPort:               viper.GetString(constants.EnvoyPort),
 		InboundCapturePort:      viper.GetString(constants.InboundCapturePort),
 		ProxyUID:                viper.GetString(constants.ProxyUID),
 		ProxyGID:                viper.GetString(constants.ProxyGID),
+		ExcludeOwners:           viper.GetString(constants.ExcludeOwners),
 		InboundInterceptionMode: viper.GetString(constants.InboundInterceptionMode),
 		InboundTProxyMark:       viper.GetString(constants.InboundTProxyMark),
 		InboundTProxyRouteTable: viper.GetString(constants.InboundTProxyRouteTable),
 		InboundPortsInclude:     viper.GetString(constants.InboundPorts),
 		InboundPortsExclude:     viper.GetString(constants.LocalExcludePorts),
 		OutboundPortsExclude:    viper.GetString(constants.LocalOutboundPortsExclude),
+		OutboundPortsInclude:    viper.GetString(constants.OutboundPorts),
 		OutboundIPRangesInclude: viper.GetString(constants.ServiceCidr),
 		OutboundIPRangesExclude: viper.GetString(constants.ServiceExcludeCidr),
 		KubevirtInterfaces:      viper.GetString(constants.KubeVirtInterfaces),
-		DryRun:                  viper.GetBool(constants.DryRun),
+		DryRun:                  dryRun,
+		DryRunFormat:            dryRunFormat,
+		Diff:                    viper.GetBool(constants.Diff),
+		Reconcile:               reconcile,
 		EnableInboundIPv6s:      nil,
 		RestoreFormat:           viper.GetBool(constants.RestoreFormat),
+		RedirectDNS:             viper.GetBool(constants.RedirectDNS),
+		DNSCapturePort:          viper.GetString(constants.DNSCapturePort),
+	}
+}
+
+// parseDryRun parses the value of the dry-run flag: either a boolean, or the format the rules are
+// rendered in instead of the commands.
+func parseDryRun(value string) (bool, string, error) {
+	if value == constants.DryRunJSON {
+		return true, constants.DryRunJSON, nil
+	}
+	dryRun, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, "", fmt.Errorf("invalid value %q for --%s: must be a boolean or %q", value, constants.DryRun, constants.DryRunJSON)
 	}
+	return dryRun, "", nil
 }
 
 func handleError(err error) {
@@ -72,109 +113,158 @@ func init() {
 	var envoyPort = "15001"
 	var inboundPort = "15006"
 
-	rootCmd.Flags().StringP(constants.EnvoyPort, "p", "", "Specify the envoy port to which redirect all TCP traffic (default $ENVOY_PORT = 15001)")
-	if err := viper.BindPFlag(constants.EnvoyPort, rootCmd.Flags().Lookup(constants.EnvoyPort)); err != nil {
+	rootCmd.PersistentFlags().StringP(constants.EnvoyPort, "p", "", "Specify the envoy port to which redirect all TCP traffic (default $ENVOY_PORT = 15001)")
+	if err := viper.BindPFlag(constants.EnvoyPort, rootCmd.PersistentFlags().Lookup(constants.EnvoyPort)); err != nil {
 		handleError(err)
 	}
 	viper.SetDefault(constants.EnvoyPort, envoyPort)
 
-	rootCmd.Flags().StringP(constants.InboundCapturePort, "z", "",
+	rootCmd.PersistentFlags().StringP(constants.InboundCapturePort, "z", "",
 		"Port to which all inbound TCP traffic to the pod/VM should be redirected to (default $INBOUND_CAPTURE_PORT = 15006)")
-	if err := viper.BindPFlag(constants.InboundCapturePort, rootCmd.Flags().Lookup(constants.InboundCapturePort)); err != nil {
+	if err := viper.BindPFlag(constants.InboundCapturePort, rootCmd.PersistentFlags().Lookup(constants.InboundCapturePort)); err != nil {
 		handleError(err)
 	}
 	viper.SetDefault(constants.InboundCapturePort, inboundPort)
 
-	rootCmd.Flags().StringP(constants.ProxyUID, "u", "",
-		"Specify the UID of the user for which the redirection is not applied. Typically, this is the UID of the proxy container")
-	if err := viper.BindPFlag(constants.ProxyUID, rootCmd.Flags().Lookup(constants.ProxyUID)); err != nil {
+	rootCmd.PersistentFlags().StringP(constants.ProxyUID, "u", "",
+		"Specify the UID or name of the user for which the redirection is not applied. Typically, this is the UID of the proxy container")
+	if err := viper.BindPFlag(constants.ProxyUID, rootCmd.PersistentFlags().Lookup(constants.ProxyUID)); err != nil {
 		handleError(err)
 	}
 	viper.SetDefault(constants.ProxyUID, "")
 
-	rootCmd.Flags().StringP(constants.ProxyGID, "g", "",
-		"Specify the GID of the user for which the redirection is not applied. (same default value as -u param)")
-	if err := viper.BindPFlag(constants.ProxyGID, rootCmd.Flags().Lookup(constants.ProxyGID)); err != nil {
+	rootCmd.PersistentFlags().StringP(constants.ProxyGID, "g", "",
+		"Specify the GID or name of the group for which the redirection is not applied. (same default value as -u param)")
+	if err := viper.BindPFlag(constants.ProxyGID, rootCmd.PersistentFlags().Lookup(constants.ProxyGID)); err != nil {
 		handleError(err)
 	}
 	viper.SetDefault(constants.ProxyGID, "")
 
-	rootCmd.Flags().StringP(constants.InboundInterceptionMode, "m", "",
+	rootCmd.PersistentFlags().String(constants.ExcludeOwners, "",
+		"Comma separated list of the users, by UID or name, whose outbound traffic bypasses Envoy, e.g. backup agents. "+
+			"The entries prefixed with \"group:\" are groups, by GID or name")
+	if err := viper.BindPFlag(constants.ExcludeOwners, rootCmd.PersistentFlags().Lookup(constants.ExcludeOwners)); err != nil {
+		handleError(err)
+	}
+	viper.SetDefault(constants.ExcludeOwners, "")
+
+	rootCmd.PersistentFlags().StringP(constants.InboundInterceptionMode, "m", "",
 		"The mode used to redirect inbound connections to Envoy, either \"REDIRECT\" or \"TPROXY\"")
-	if err := viper.BindPFlag(constants.InboundInterceptionMode, rootCmd.Flags().Lookup(constants.InboundInterceptionMode)); err != nil {
+	if err := viper.BindPFlag(constants.InboundInterceptionMode, rootCmd.PersistentFlags().Lookup(constants.InboundInterceptionMode)); err != nil {
 		handleError(err)
 	}
 	viper.SetDefault(constants.InboundInterceptionMode, "")
 
-	rootCmd.Flags().StringP(constants.InboundPorts, "b", "",
+	rootCmd.PersistentFlags().StringP(constants.InboundPorts, "b", "",
 		"Comma separated list of inbound ports for which traffic is to be redirected to Envoy (optional). "+
 			"The wildcard character \"*\" can be used to configure redirection for all ports. An empty list will disable")
-	if err := viper.BindPFlag(constants.InboundPorts, rootCmd.Flags().Lookup(constants.InboundPorts)); err != nil {
+	if err := viper.BindPFlag(constants.InboundPorts, rootCmd.PersistentFlags().Lookup(constants.InboundPorts)); err != nil {
 		handleError(err)
 	}
 	viper.SetDefault(constants.InboundPorts, "")
 
-	rootCmd.Flags().StringP(constants.LocalExcludePorts, "d", "",
+	rootCmd.PersistentFlags().StringP(constants.LocalExcludePorts, "d", "",
 		"Comma separated list of inbound ports to be excluded from redirection to Envoy (optional). "+
 			"Only applies  when all inbound traffic (i.e. \"*\") is being redirected (default to $ISTIO_LOCAL_EXCLUDE_PORTS)")
-	if err := viper.BindPFlag(constants.LocalExcludePorts, rootCmd.Flags().Lookup(constants.LocalExcludePorts)); err != nil {
+	if err := viper.BindPFlag(constants.LocalExcludePorts, rootCmd.PersistentFlags().Lookup(constants.LocalExcludePorts)); err != nil {
 		handleError(err)
 	}
 	viper.SetDefault(constants.LocalExcludePorts, "")
 
-	rootCmd.Flags().StringP(constants.ServiceCidr, "i", "",
+	rootCmd.PersistentFlags().StringP(constants.ServiceCidr, "i", "",
 		"Comma separated list of IP ranges in CIDR form to redirect to envoy (optional). "+
 			"The wildcard character \"*\" can be used to redirect all outbound traffic. An empty list will disable all outbound")
-	if err := viper.BindPFlag(constants.ServiceCidr, rootCmd.Flags().Lookup(constants.ServiceCidr)); err != nil {
+	if err := viper.BindPFlag(constants.ServiceCidr, rootCmd.PersistentFlags().Lookup(constants.ServiceCidr)); err != nil {
 		handleError(err)
 	}
 	viper.SetDefault(constants.ServiceCidr, "")
 
-	rootCmd.Flags().StringP(constants.ServiceExcludeCidr, "x", "",
+	rootCmd.PersistentFlags().StringP(constants.ServiceExcludeCidr, "x", "",
 		"Comma separated list of IP ranges in CIDR form to be excluded from redirection. "+
 			"Only applies when all  outbound traffic (i.e. \"*\") is being redirected (default to $ISTIO_SERVICE_EXCLUDE_CIDR)")
-	if err := viper.BindPFlag(constants.ServiceExcludeCidr, rootCmd.Flags().Lookup(constants.ServiceExcludeCidr)); err != nil {
+	if err := viper.BindPFlag(constants.ServiceExcludeCidr, rootCmd.PersistentFlags().Lookup(constants.ServiceExcludeCidr)); err != nil {
 		handleError(err)
 	}
 	viper.SetDefault(constants.ServiceExcludeCidr, "")
 
-	rootCmd.Flags().StringP(constants.LocalOutboundPortsExclude, "o", "",
+	rootCmd.PersistentFlags().StringP(constants.LocalOutboundPortsExclude, "o", "",
 		"Comma separated list of outbound ports to be excluded from redirection to Envoy")
-	if err := viper.BindPFlag(constants.LocalOutboundPortsExclude, rootCmd.Flags().Lookup(constants.LocalOutboundPortsExclude)); err != nil {
+	if err := viper.BindPFlag(constants.LocalOutboundPortsExclude, rootCmd.PersistentFlags().Lookup(constants.LocalOutboundPortsExclude)); err != nil {
 		handleError(err)
 	}
 	viper.SetDefault(constants.LocalOutboundPortsExclude, "")
 
-	rootCmd.Flags().StringP(constants.KubeVirtInterfaces, "k", "",
+	rootCmd.PersistentFlags().String(constants.OutboundPorts, "",
+		"Comma separated list of outbound ports for which traffic is to be redirected to Envoy. "+
+			"If set, the traffic to the other ports bypasses Envoy (default $ISTIO_OUTBOUND_PORTS)")
+	if err := viper.BindPFlag(constants.OutboundPorts, rootCmd.PersistentFlags().Lookup(constants.OutboundPorts)); err != nil {
+		handleError(err)
+	}
+	viper.SetDefault(constants.OutboundPorts, "")
+
+	rootCmd.PersistentFlags().StringP(constants.KubeVirtInterfaces, "k", "",
 		"Comma separated list of virtual interfaces whose inbound traffic (from VM) will be treated as outbound")
-	if err := viper.BindPFlag(constants.KubeVirtInterfaces, rootCmd.Flags().Lookup(constants.KubeVirtInterfaces)); err != nil {
+	if err := viper.BindPFlag(constants.KubeVirtInterfaces, rootCmd.PersistentFlags().Lookup(constants.KubeVirtInterfaces)); err != nil {
 		handleError(err)
 	}
 	viper.SetDefault(constants.KubeVirtInterfaces, "")
 
-	rootCmd.Flags().StringP(constants.InboundTProxyMark, "t", "", "")
-	if err := viper.BindPFlag(constants.InboundTProxyMark, rootCmd.Flags().Lookup(constants.InboundTProxyMark)); err != nil {
+	rootCmd.PersistentFlags().StringP(constants.InboundTProxyMark, "t", "", "")
+	if err := viper.BindPFlag(constants.InboundTProxyMark, rootCmd.PersistentFlags().Lookup(constants.InboundTProxyMark)); err != nil {
 		handleError(err)
 	}
 	viper.SetDefault(constants.InboundTProxyMark, "1337")
 
-	rootCmd.Flags().StringP(constants.InboundTProxyRouteTable, "r", "", "")
-	if err := viper.BindPFlag(constants.InboundTProxyRouteTable, rootCmd.Flags().Lookup(constants.InboundTProxyRouteTable)); err != nil {
+	rootCmd.PersistentFlags().StringP(constants.InboundTProxyRouteTable, "r", "", "")
+	if err := viper.BindPFlag(constants.InboundTProxyRouteTable, rootCmd.PersistentFlags().Lookup(constants.InboundTProxyRouteTable)); err != nil {
 		handleError(err)
 	}
 	viper.SetDefault(constants.InboundTProxyRouteTable, "133")
 
-	rootCmd.Flags().BoolP(constants.DryRun, "n", false, "Do not call any external dependencies like iptables")
-	if err := viper.BindPFlag(constants.DryRun, rootCmd.Flags().Lookup(constants.DryRun)); err != nil {
+	rootCmd.PersistentFlags().StringP(constants.DryRun, "n", "false",
+		"Do not call any external dependencies like iptables. With --dry-run=json, print the final rules as JSON instead of the commands")
+	rootCmd.PersistentFlags().Lookup(constants.DryRun).NoOptDefVal = "true"
+	if err := viper.BindPFlag(constants.DryRun, rootCmd.PersistentFlags().Lookup(constants.DryRun)); err != nil {
 		handleError(err)
 	}
 	viper.SetDefault(constants.DryRun, false)
 
-	rootCmd.Flags().BoolP(constants.RestoreFormat, "f", true, "Print iptables rules in iptables-restore interpretable format")
-	if err := viper.BindPFlag(constants.RestoreFormat, rootCmd.Flags().Lookup(constants.RestoreFormat)); err != nil {
+	rootCmd.PersistentFlags().Bool(constants.Diff, false,
+		"Compare the rules with the live iptables-save output and report the drift, without changing the rules")
+	if err := viper.BindPFlag(constants.Diff, rootCmd.PersistentFlags().Lookup(constants.Diff)); err != nil {
+		handleError(err)
+	}
+	viper.SetDefault(constants.Diff, false)
+
+	rootCmd.PersistentFlags().String(constants.Reconcile, "",
+		fmt.Sprintf("How to handle the ISTIO_* chains already present: %q exits successfully, %q flushes and reprograms "+
+			"the rules atomically, %q exits with an error. By default the rules are added regardless",
+			constants.ReconcileSkip, constants.ReconcileReplace, constants.ReconcileFail))
+	if err := viper.BindPFlag(constants.Reconcile, rootCmd.PersistentFlags().Lookup(constants.Reconcile)); err != nil {
+		handleError(err)
+	}
+	viper.SetDefault(constants.Reconcile, "")
+
+	rootCmd.PersistentFlags().BoolP(constants.RestoreFormat, "f", true, "Print iptables rules in iptables-restore interpretable format")
+	if err := viper.BindPFlag(constants.RestoreFormat, rootCmd.PersistentFlags().Lookup(constants.RestoreFormat)); err != nil {
 		handleError(err)
 	}
 	viper.SetDefault(constants.RestoreFormat, true)
+
+	rootCmd.PersistentFlags().Bool(constants.RedirectDNS, false,
+		"Redirect the DNS queries of the application, over UDP and TCP, to the DNS proxy of the agent "+
+			"(default $ISTIO_META_DNS_CAPTURE)")
+	if err := viper.BindPFlag(constants.RedirectDNS, rootCmd.PersistentFlags().Lookup(constants.RedirectDNS)); err != nil {
+		handleError(err)
+	}
+	viper.SetDefault(constants.RedirectDNS, dnsCaptureByAgent)
+
+	rootCmd.PersistentFlags().String(constants.DNSCapturePort, "",
+		"Port to which the DNS queries are redirected with --redirect-dns")
+	if err := viper.BindPFlag(constants.DNSCapturePort, rootCmd.PersistentFlags().Lookup(constants.DNSCapturePort)); err != nil {
+		handleError(err)
+	}
+	viper.SetDefault(constants.DNSCapturePort, "15053")
 }
 
 func Execute() {
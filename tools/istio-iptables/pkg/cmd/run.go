@@ -36,19 +36,39 @@ type IptablesConfigurator struct {
 	//TODO(abhide): Fix dep.Dependencies with better interface
 	ext dep.Dependencies
 	cfg *config.Config
+	// owners resolves the users and groups bypassing Envoy.
+	owners *ownerResolver
+	// liveV4 and liveV6 are the live rules, listed when reconciling.
+	liveV4 map[string]*builder.Table
+	liveV6 map[string]*builder.Table
 }
 
 func NewIptablesConfigurator(cfg *config.Config) *IptablesConfigurator {
-	var ext dep.Dependencies
-	if cfg.DryRun {
-		ext = &dep.StdoutStubDependencies{}
-	} else {
-		ext = &dep.RealDependencies{}
+	return newIptablesConfigurator(cfg, dependencies(cfg))
+}
+
+// dependencies returns the dependencies running the commands in the mode of the config.
+func dependencies(cfg *config.Config) dep.Dependencies {
+	switch {
+	case cfg.Diff:
+		// The rules are compared with the live ones, nothing is changed.
+		return &dep.ReadOnlyDependencies{}
+	case cfg.DryRunFormat != "":
+		// Only the rules are printed, not the commands.
+		return &dep.SilentStubDependencies{}
+	case cfg.DryRun:
+		return &dep.StdoutStubDependencies{}
+	default:
+		return &dep.RealDependencies{}
 	}
+}
+
+func newIptablesConfigurator(cfg *config.Config, ext dep.Dependencies) *IptablesConfigurator {
 	return &IptablesConfigurator{
 		iptables: builder.NewIptablesBuilder(),
 		ext:      ext,
 		cfg:      cfg,
+		owners:   newOwnerResolver(ext),
 	}
 }
 
@@ -105,7 +125,7 @@ func (iptConfigurator *IptablesConfigurator) logConfig() {
 	iptConfigurator.cfg.Print()
 }
 
-func (iptConfigurator *IptablesConfigurator) handleInboundPortsInclude() {
+func (iptConfigurator *IptablesConfigurator) handleInboundPortsInclude() error {
 	// Handling of inbound ports. Traffic will be redirected to Envoy, which will process and forward
 	// to the local service. If not set, no inbound port will be intercepted by istio iptablesOrFail.
 	var table string
@@ -121,15 +141,19 @@ func (iptConfigurator *IptablesConfigurator) handleInboundPortsInclude() {
 			iptConfigurator.iptables.AppendRuleV4(constants.ISTIODIVERT, constants.MANGLE, "-j", constants.ACCEPT)
 			// Route all packets marked in chain ISTIODIVERT using routing table ${INBOUND_TPROXY_ROUTE_TABLE}.
 			//TODO: (abhide): Move this out of this method
-			iptConfigurator.ext.RunOrFail(
-				dep.IP, "-f", "inet", "rule", "add", "fwmark", iptConfigurator.cfg.InboundTProxyMark, "lookup", iptConfigurator.cfg.InboundTProxyRouteTable)
+			if err := iptConfigurator.runOrFail(
+				dep.IP, "-f", "inet", "rule", "add", "fwmark", iptConfigurator.cfg.InboundTProxyMark, "lookup", iptConfigurator.cfg.InboundTProxyRouteTable); err != nil {
+				return err
+			}
 			// In routing table ${INBOUND_TPROXY_ROUTE_TABLE}, create a single default rule to route all traffic to
 			// the loopback interface.
 			//TODO: (abhide): Move this out of this method
 			err := iptConfigurator.ext.Run(dep.IP, "-f", "inet", "route", "add", "local", "default", "dev", "lo", "table", iptConfigurator.cfg.InboundTProxyRouteTable)
 			if err != nil {
 				//TODO: (abhide): Move this out of this method
-				iptConfigurator.ext.RunOrFail(dep.IP, "route", "show", "table", "all")
+				if err := iptConfigurator.runOrFail(dep.IP, "route", "show", "table", "all"); err != nil {
+					return err
+				}
 			}
 			// Create a new chain for redirecting inbound traffic to the common Envoy
 			// port.
@@ -179,6 +203,7 @@ func (iptConfigurator *IptablesConfigurator) handleInboundPortsInclude() {
 			}
 		}
 	}
+	return nil
 }
 
 func (iptConfigurator *IptablesConfigurator) handleInboundIpv6Rules(ipv6RangesExclude NetworkRange, ipv6RangesInclude NetworkRange) {
@@ -224,8 +249,8 @@ func (iptConfigurator *IptablesConfigurator) handleInboundIpv6Rules(ipv6RangesEx
 			}
 		}
 		// Create a new chain for selectively redirecting outbound packets to Envoy.
-		// Jump to the ISTIOOUTPUT chain from OUTPUT chain for all tcp traffic.
-		iptConfigurator.iptables.AppendRuleV6(constants.OUTPUT, constants.NAT, "-p", constants.TCP, "-j", constants.ISTIOOUTPUT)
+		// Jump to the ISTIOOUTPUT chain from OUTPUT chain for the captured tcp traffic.
+		iptConfigurator.handleOutboundPortsInclude(iptConfigurator.iptables.AppendRuleV6)
 		// Apply port based exclusions. Must be applied before connections back to self are redirected.
 		if iptConfigurator.cfg.OutboundPortsExclude != "" {
 			for _, port := range split(iptConfigurator.cfg.OutboundPortsExclude) {
@@ -240,16 +265,15 @@ func (iptConfigurator *IptablesConfigurator) handleInboundIpv6Rules(ipv6RangesEx
 		// address, e.g. appN => Envoy (client) => Envoy (server) => appN.
 		iptConfigurator.iptables.AppendRuleV6(constants.ISTIOOUTPUT, constants.NAT, "-o", "lo", "!", "-d", "::1/128", "-j", constants.ISTIOINREDIRECT)
 
-		for _, uid := range split(iptConfigurator.cfg.ProxyUID) {
+		for _, owner := range iptConfigurator.owners.ownerMatches(iptConfigurator.cfg) {
 			// Avoid infinite loops. Don't redirect Envoy traffic directly back to
-			// Envoy for non-loopback traffic.
-			iptConfigurator.iptables.AppendRuleV6(constants.ISTIOOUTPUT, constants.NAT, "-m", "owner", "--uid-owner", uid, "-j", constants.RETURN)
+			// Envoy for non-loopback traffic. The traffic of the excluded owners bypasses Envoy too.
+			iptConfigurator.iptables.AppendRuleV6(constants.ISTIOOUTPUT, constants.NAT, ownerRule(nil, owner, "-j", constants.RETURN)...)
 		}
-
-		for _, gid := range split(iptConfigurator.cfg.ProxyGID) {
-			// Avoid infinite loops. Don't redirect Envoy traffic directly back to
-			// Envoy for non-loopback traffic.
-			iptConfigurator.iptables.AppendRuleV6(constants.ISTIOOUTPUT, constants.NAT, "-m", "owner", "--gid-owner", gid, "-j", constants.RETURN)
+		if iptConfigurator.cfg.RedirectDNS {
+			iptConfigurator.iptables.AppendRuleV6(constants.ISTIOOUTPUT, constants.NAT, "-p", constants.TCP, "--dport", constants.DNSPort,
+				"-j", constants.REDIRECT, "--to-port", iptConfigurator.cfg.DNSCapturePort)
+			iptConfigurator.handleDNSUDP(iptConfigurator.iptables.AppendRuleV6)
 		}
 		// Skip redirection for Envoy-aware applications and
 		// container-to-container traffic both of which explicitly use
@@ -286,6 +310,49 @@ func (iptConfigurator *IptablesConfigurator) handleInboundIpv6Rules(ipv6RangesEx
 	}
 }
 
+// handleOutboundPortsInclude jumps from the OUTPUT chain to the ISTIOOUTPUT chain for all the tcp
+// traffic, or only for the traffic to the included ports when OUTBOUND_PORTS_INCLUDE is set. The
+// traffic to the other ports bypasses Envoy.
+func (iptConfigurator *IptablesConfigurator) handleOutboundPortsInclude(
+	appendRule func(chain string, table string, params ...string) builder.IptablesProducer) {
+	ports := outboundPortsInclude(iptConfigurator.cfg)
+	if len(ports) == 0 {
+		appendRule(constants.OUTPUT, constants.NAT, "-p", constants.TCP, "-j", constants.ISTIOOUTPUT)
+		return
+	}
+	for _, port := range ports {
+		appendRule(constants.OUTPUT, constants.NAT, "-p", constants.TCP, "--dport", port, "-j", constants.ISTIOOUTPUT)
+	}
+}
+
+// outboundPortsInclude returns the included outbound ports, empty if all the ports are included.
+// The DNS port is included when the DNS queries are redirected, since they are redirected from the
+// ISTIOOUTPUT chain.
+func outboundPortsInclude(cfg *config.Config) []string {
+	ports := split(cfg.OutboundPortsInclude)
+	if len(ports) == 0 || !cfg.RedirectDNS {
+		return ports
+	}
+	for _, port := range ports {
+		if port == constants.DNSPort {
+			return ports
+		}
+	}
+	return append(ports, constants.DNSPort)
+}
+
+// handleDNSUDP redirects the DNS queries over UDP to the DNS proxy of the agent, except the queries
+// of Envoy, the agent and the excluded owners. The DNS queries over TCP are redirected from the
+// ISTIOOUTPUT chain.
+func (iptConfigurator *IptablesConfigurator) handleDNSUDP(appendRule func(chain string, table string, params ...string) builder.IptablesProducer) {
+	for _, owner := range iptConfigurator.owners.ownerMatches(iptConfigurator.cfg) {
+		appendRule(constants.OUTPUT, constants.NAT,
+			ownerRule([]string{"-p", constants.UDP, "--dport", constants.DNSPort}, owner, "-j", constants.RETURN)...)
+	}
+	appendRule(constants.OUTPUT, constants.NAT, "-p", constants.UDP, "--dport", constants.DNSPort,
+		"-j", constants.REDIRECT, "--to-port", iptConfigurator.cfg.DNSCapturePort)
+}
+
 func (iptConfigurator *IptablesConfigurator) handleInboundIpv4Rules(ipv4RangesInclude NetworkRange) {
 	// Apply outbound IP inclusions.
 	if ipv4RangesInclude.IsWildcard {
@@ -310,12 +377,27 @@ func (iptConfigurator *IptablesConfigurator) handleInboundIpv4Rules(ipv4RangesIn
 	}
 }
 
-func (iptConfigurator *IptablesConfigurator) run() {
+// run programs the rules. It returns errRulesDrifted if the rules drifted from the live ones with --diff.
+func (iptConfigurator *IptablesConfigurator) run() (err error) {
 	defer func() {
-		iptConfigurator.ext.RunOrFail(dep.IPTABLESSAVE)
-		iptConfigurator.ext.RunOrFail(dep.IP6TABLESSAVE)
+		// The rules are dumped once programmed. After a failure, they are skipped so that the
+		// command which failed is the one reported.
+		if err != nil {
+			return
+		}
+		if err = iptConfigurator.runOrFail(dep.IPTABLESSAVE); err == nil {
+			err = iptConfigurator.runOrFail(dep.IP6TABLESSAVE)
+		}
 	}()
 
+	configure, err := iptConfigurator.reconcile()
+	if err != nil {
+		return err
+	}
+	if !configure {
+		return nil
+	}
+
 	// TODO: more flexibility - maybe a whitelist of users to be captured for output instead of a blacklist.
 	if iptConfigurator.cfg.ProxyUID == "" {
 		usr, err := iptConfigurator.ext.LookupUser()
@@ -338,7 +420,7 @@ func (iptConfigurator *IptablesConfigurator) run() {
 
 	podIP, err := iptConfigurator.ext.GetLocalIP()
 	if err != nil {
-		panic(err)
+		return err
 	}
 	// Check if pod's ip is ipv4 or ipv6, in case of ipv6 set variable
 	// to program ip6tablesOrFail
@@ -352,23 +434,27 @@ func (iptConfigurator *IptablesConfigurator) run() {
 	// in order to not to fail
 	ipv4RangesExclude, ipv6RangesExclude, err := iptConfigurator.separateV4V6(iptConfigurator.cfg.OutboundIPRangesExclude)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if ipv4RangesExclude.IsWildcard {
-		panic("Invalid value for OUTBOUND_IP_RANGES_EXCLUDE")
+		return fmt.Errorf("invalid value for OUTBOUND_IP_RANGES_EXCLUDE")
 	}
 	// FixMe: Do we need similar check for ipv6RangesExclude as well ??
 
 	ipv4RangesInclude, ipv6RangesInclude, err := iptConfigurator.separateV4V6(iptConfigurator.cfg.OutboundIPRangesInclude)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	iptConfigurator.logConfig()
+	if !iptConfigurator.cfg.Diff && iptConfigurator.cfg.DryRunFormat == "" {
+		iptConfigurator.logConfig()
+	}
 
 	if iptConfigurator.cfg.EnableInboundIPv6s != nil {
 		//TODO: (abhide): Move this out of this method
-		iptConfigurator.ext.RunOrFail(dep.IP, "-6", "addr", "add", "::6/128", "dev", "lo")
+		if err := iptConfigurator.runOrFail(dep.IP, "-6", "addr", "add", "::6/128", "dev", "lo"); err != nil {
+			return err
+		}
 	}
 
 	// Create a new chain for redirecting outbound traffic to the common Envoy port.
@@ -388,12 +474,14 @@ func (iptConfigurator *IptablesConfigurator) run() {
 			"--to-port", iptConfigurator.cfg.ProxyPort)
 	}
 
-	iptConfigurator.handleInboundPortsInclude()
+	if err := iptConfigurator.handleInboundPortsInclude(); err != nil {
+		return err
+	}
 
 	// TODO: change the default behavior to not intercept any output - user may use http_proxy or another
 	// iptablesOrFail wrapper (like ufw). Current default is similar with 0.1
-	// Jump to the ISTIOOUTPUT chain from OUTPUT chain for all tcp traffic.
-	iptConfigurator.iptables.AppendRuleV4(constants.OUTPUT, constants.NAT, "-p", constants.TCP, "-j", constants.ISTIOOUTPUT)
+	// Jump to the ISTIOOUTPUT chain from OUTPUT chain for the captured tcp traffic.
+	iptConfigurator.handleOutboundPortsInclude(iptConfigurator.iptables.AppendRuleV4)
 	// Apply port based exclusions. Must be applied before connections back to self are redirected.
 	if iptConfigurator.cfg.OutboundPortsExclude != "" {
 		for _, port := range split(iptConfigurator.cfg.OutboundPortsExclude) {
@@ -410,16 +498,16 @@ func (iptConfigurator *IptablesConfigurator) run() {
 		iptConfigurator.iptables.AppendRuleV4(constants.ISTIOOUTPUT, constants.NAT, "-o", "lo", "!", "-d", "127.0.0.1/32", "-j", constants.ISTIOINREDIRECT)
 	}
 
-	for _, uid := range split(iptConfigurator.cfg.ProxyUID) {
+	for _, owner := range iptConfigurator.owners.ownerMatches(iptConfigurator.cfg) {
 		// Avoid infinite loops. Don't redirect Envoy traffic directly back to
-		// Envoy for non-loopback traffic.
-		iptConfigurator.iptables.AppendRuleV4(constants.ISTIOOUTPUT, constants.NAT, "-m", "owner", "--uid-owner", uid, "-j", constants.RETURN)
+		// Envoy for non-loopback traffic. The traffic of the excluded owners bypasses Envoy too.
+		iptConfigurator.iptables.AppendRuleV4(constants.ISTIOOUTPUT, constants.NAT, ownerRule(nil, owner, "-j", constants.RETURN)...)
 	}
-
-	for _, gid := range split(iptConfigurator.cfg.ProxyGID) {
-		// Avoid infinite loops. Don't redirect Envoy traffic directly back to
-		// Envoy for non-loopback traffic.
-		iptConfigurator.iptables.AppendRuleV4(constants.ISTIOOUTPUT, constants.NAT, "-m", "owner", "--gid-owner", gid, "-j", constants.RETURN)
+	if iptConfigurator.cfg.RedirectDNS {
+		// The DNS queries of Envoy and the agent have been returned above.
+		iptConfigurator.iptables.AppendRuleV4(constants.ISTIOOUTPUT, constants.NAT, "-p", constants.TCP, "--dport", constants.DNSPort,
+			"-j", constants.REDIRECT, "--to-port", iptConfigurator.cfg.DNSCapturePort)
+		iptConfigurator.handleDNSUDP(iptConfigurator.iptables.AppendRuleV4)
 	}
 	// Skip redirection for Envoy-aware applications and
 	// container-to-container traffic both of which explicitly use
@@ -437,7 +525,7 @@ func (iptConfigurator *IptablesConfigurator) run() {
 	iptConfigurator.handleInboundIpv4Rules(ipv4RangesInclude)
 	iptConfigurator.handleInboundIpv6Rules(ipv6RangesExclude, ipv6RangesInclude)
 
-	iptConfigurator.executeCommands()
+	return iptConfigurator.executeCommands()
 }
 
 func (iptConfigurator *IptablesConfigurator) createRulesFile(f *os.File, contents string) error {
@@ -451,14 +539,21 @@ func (iptConfigurator *IptablesConfigurator) createRulesFile(f *os.File, content
 	return err
 }
 
-func (iptConfigurator *IptablesConfigurator) executeIptablesCommands(commands [][]string) {
+// runOrFail runs a command, and returns a *CommandError if it fails.
+func (iptConfigurator *IptablesConfigurator) runOrFail(cmd string, args ...string) error {
+	if err := iptConfigurator.ext.Run(cmd, args...); err != nil {
+		return &CommandError{Command: cmd, Args: args, Err: err}
+	}
+	return nil
+}
+
+func (iptConfigurator *IptablesConfigurator) executeIptablesCommands(commands [][]string) error {
 	for _, cmd := range commands {
-		if len(cmd) > 1 {
-			iptConfigurator.ext.RunOrFail(cmd[0], cmd[1:]...)
-		} else {
-			iptConfigurator.ext.RunOrFail(cmd[0])
+		if err := iptConfigurator.runOrFail(cmd[0], cmd[1:]...); err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
 func (iptConfigurator *IptablesConfigurator) executeIptablesRestoreCommand(isIpv4 bool) error {
@@ -472,38 +567,72 @@ func (iptConfigurator *IptablesConfigurator) executeIptablesRestoreCommand(isIpv
 		filename = fmt.Sprintf("ip6tables-rules-%d.txt", time.Now().UnixNano())
 		cmd = constants.IP6TABLESRESTORE
 	}
+	return iptConfigurator.restore(cmd, filename, data)
+}
+
+// executeReplaceRestoreCommand replaces the live rules by the expected ones in a single transaction.
+func (iptConfigurator *IptablesConfigurator) executeReplaceRestoreCommand(isIpv4 bool) error {
+	var data, filename, cmd string
+	if isIpv4 {
+		data = buildReplaceRestore(iptConfigurator.iptables.BuildV4Tables(), iptConfigurator.liveV4)
+		filename = fmt.Sprintf("iptables-rules-%d.txt", time.Now().UnixNano())
+		cmd = constants.IPTABLESRESTORE
+	} else {
+		data = buildReplaceRestore(iptConfigurator.iptables.BuildV6Tables(), iptConfigurator.liveV6)
+		filename = fmt.Sprintf("ip6tables-rules-%d.txt", time.Now().UnixNano())
+		cmd = constants.IP6TABLESRESTORE
+	}
+	if data == "" {
+		return nil
+	}
+	return iptConfigurator.restore(cmd, filename, data)
+}
+
+func (iptConfigurator *IptablesConfigurator) restore(cmd, filename, data string) error {
 	rulesFile, err := ioutil.TempFile("", filename)
-	defer os.Remove(rulesFile.Name())
 	if err != nil {
 		return fmt.Errorf("unable to create iptables-restore file: %v", err)
 	}
+	defer os.Remove(rulesFile.Name())
 	if err := iptConfigurator.createRulesFile(rulesFile, data); err != nil {
 		return err
 	}
 	// --noflush to prevent flushing/deleting previous contents from table
-	iptConfigurator.ext.RunOrFail(cmd, "--noflush", rulesFile.Name())
-	return nil
+	return iptConfigurator.runOrFail(cmd, "--noflush", rulesFile.Name())
 }
 
-func (iptConfigurator *IptablesConfigurator) executeCommands() {
-	if iptConfigurator.cfg.RestoreFormat {
-		// Execute iptables-restore
-		err := iptConfigurator.executeIptablesRestoreCommand(true)
+func (iptConfigurator *IptablesConfigurator) executeCommands() error {
+	if iptConfigurator.cfg.Diff {
+		drift, err := iptConfigurator.diff(os.Stdout)
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			return err
 		}
-		// Execute ip6tables-restore
-		err = iptConfigurator.executeIptablesRestoreCommand(false)
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+		if drift {
+			return errRulesDrifted
+		}
+	} else if iptConfigurator.cfg.DryRunFormat == constants.DryRunJSON {
+		return iptConfigurator.printJSON(os.Stdout)
+	} else if iptConfigurator.cfg.Reconcile == constants.ReconcileReplace {
+		// The rules are replaced atomically with iptables-restore, regardless of the restore format.
+		for _, isIpv4 := range []bool{true, false} {
+			if err := iptConfigurator.executeReplaceRestoreCommand(isIpv4); err != nil {
+				return err
+			}
+		}
+	} else if iptConfigurator.cfg.RestoreFormat {
+		// Execute iptables-restore
+		if err := iptConfigurator.executeIptablesRestoreCommand(true); err != nil {
+			return err
 		}
+		// Execute ip6tables-restore
+		return iptConfigurator.executeIptablesRestoreCommand(false)
 	} else {
 		// Execute iptables commands
-		iptConfigurator.executeIptablesCommands(iptConfigurator.iptables.BuildV4())
+		if err := iptConfigurator.executeIptablesCommands(iptConfigurator.iptables.BuildV4()); err != nil {
+			return err
+		}
 		// Execute ip6tables commands
-		iptConfigurator.executeIptablesCommands(iptConfigurator.iptables.BuildV6())
-
+		return iptConfigurator.executeIptablesCommands(iptConfigurator.iptables.BuildV6())
 	}
+	return nil
 }
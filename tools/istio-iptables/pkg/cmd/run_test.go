@@ -18,6 +18,8 @@ import (
 	"net"
 	"reflect"
 	"testing"
+
+	"istio.io/istio/tools/istio-iptables/pkg/config"
 )
 
 func TestHandleInboundIpv6RulesWithoutEnableInboundIpv6s(t *testing.T) {
@@ -470,3 +472,88 @@ func TestHandleInboundPortsIncludeWithWildcardInboundPortsAndTproxy(t *testing.T
 		t.Errorf("Output mismatch\nExpected: %#v\nActual: %#v", expectedIpv4Rules, ip4Rules)
 	}
 }
+
+func TestHandleInboundIpv6RulesWithRedirectDNS(t *testing.T) {
+	cfg := constructConfig()
+	iptConfigurator := NewIptablesConfigurator(cfg)
+	iptConfigurator.cfg.EnableInboundIPv6s = net.IPv6loopback
+	iptConfigurator.cfg.ProxyUID = "1337"
+	iptConfigurator.cfg.ProxyGID = "1337"
+	iptConfigurator.cfg.RedirectDNS = true
+	iptConfigurator.cfg.DNSCapturePort = "15053"
+	ipv6Range := NetworkRange{
+		IsWildcard: false,
+		IPNets:     nil,
+	}
+	iptConfigurator.cfg.InboundPortsInclude = ""
+	iptConfigurator.handleInboundIpv6Rules(ipv6Range, ipv6Range)
+	actual := FormatIptablesCommands(iptConfigurator.iptables.BuildV6())
+	expected := []string{
+		"ip6tables -t nat -N ISTIO_REDIRECT",
+		"ip6tables -t nat -N ISTIO_IN_REDIRECT",
+		"ip6tables -t nat -N ISTIO_OUTPUT",
+		"ip6tables -t nat -A ISTIO_REDIRECT -p tcp -j REDIRECT --to-port 15001",
+		"ip6tables -t nat -A ISTIO_IN_REDIRECT -p tcp -j REDIRECT --to-port 15001",
+		"ip6tables -t nat -A OUTPUT -p tcp -j ISTIO_OUTPUT",
+		"ip6tables -t nat -A ISTIO_OUTPUT -o lo -s ::6/128 -j RETURN",
+		"ip6tables -t nat -A ISTIO_OUTPUT -o lo ! -d ::1/128 -j ISTIO_IN_REDIRECT",
+		"ip6tables -t nat -A ISTIO_OUTPUT -m owner --uid-owner 1337 -j RETURN",
+		"ip6tables -t nat -A ISTIO_OUTPUT -m owner --gid-owner 1337 -j RETURN",
+		"ip6tables -t nat -A ISTIO_OUTPUT -p tcp --dport 53 -j REDIRECT --to-port 15053",
+		"ip6tables -t nat -A OUTPUT -p udp --dport 53 -m owner --uid-owner 1337 -j RETURN",
+		"ip6tables -t nat -A OUTPUT -p udp --dport 53 -m owner --gid-owner 1337 -j RETURN",
+		"ip6tables -t nat -A OUTPUT -p udp --dport 53 -j REDIRECT --to-port 15053",
+		"ip6tables -t nat -A ISTIO_OUTPUT -d ::1/128 -j RETURN",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Output mismatch.\nExpected: %#v\nActual: %#v", expected, actual)
+	}
+}
+
+func TestHandleInboundIpv6RulesWithOutboundPortsInclude(t *testing.T) {
+	cfg := constructConfig()
+	iptConfigurator := NewIptablesConfigurator(cfg)
+	iptConfigurator.cfg.EnableInboundIPv6s = net.IPv6loopback
+	iptConfigurator.cfg.OutboundPortsInclude = "80,8080"
+	ipv6Range := NetworkRange{
+		IsWildcard: false,
+		IPNets:     nil,
+	}
+	iptConfigurator.cfg.InboundPortsInclude = ""
+	iptConfigurator.handleInboundIpv6Rules(ipv6Range, ipv6Range)
+	actual := FormatIptablesCommands(iptConfigurator.iptables.BuildV6())
+	expected := []string{
+		"ip6tables -t nat -N ISTIO_REDIRECT",
+		"ip6tables -t nat -N ISTIO_IN_REDIRECT",
+		"ip6tables -t nat -N ISTIO_OUTPUT",
+		"ip6tables -t nat -A ISTIO_REDIRECT -p tcp -j REDIRECT --to-port 15001",
+		"ip6tables -t nat -A ISTIO_IN_REDIRECT -p tcp -j REDIRECT --to-port 15001",
+		"ip6tables -t nat -A OUTPUT -p tcp --dport 80 -j ISTIO_OUTPUT",
+		"ip6tables -t nat -A OUTPUT -p tcp --dport 8080 -j ISTIO_OUTPUT",
+		"ip6tables -t nat -A ISTIO_OUTPUT -o lo -s ::6/128 -j RETURN",
+		"ip6tables -t nat -A ISTIO_OUTPUT -o lo ! -d ::1/128 -j ISTIO_IN_REDIRECT",
+		"ip6tables -t nat -A ISTIO_OUTPUT -d ::1/128 -j RETURN",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Output mismatch.\nExpected: %#v\nActual: %#v", expected, actual)
+	}
+}
+
+func TestOutboundPortsInclude(t *testing.T) {
+	cases := []struct {
+		include     string
+		redirectDNS bool
+		expected    []string
+	}{
+		{include: "", redirectDNS: true, expected: nil},
+		{include: "80,443", expected: []string{"80", "443"}},
+		{include: "80,443", redirectDNS: true, expected: []string{"80", "443", "53"}},
+		{include: "53,80", redirectDNS: true, expected: []string{"53", "80"}},
+	}
+	for _, c := range cases {
+		cfg := &config.Config{OutboundPortsInclude: c.include, RedirectDNS: c.redirectDNS}
+		if actual := outboundPortsInclude(cfg); !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("outboundPortsInclude(%q, %v) => %v, want %v", c.include, c.redirectDNS, actual, c.expected)
+		}
+	}
+}
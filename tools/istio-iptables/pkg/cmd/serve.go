@@ -0,0 +1,200 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"istio.io/pkg/log"
+
+	api "istio.io/istio/tools/istio-iptables/pkg/api/v1alpha1"
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
+	"istio.io/istio/tools/istio-iptables/pkg/config"
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+)
+
+var socket string
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the API programming the iptables rules on a unix socket",
+	Long: "Serve the API programming the iptables rules on a unix socket, for the clients which can't exec " +
+		"istio-iptables, e.g. the istio-cni plugin. The flags are the defaults of the empty fields of the requests.",
+	Run: func(cmd *cobra.Command, args []string) {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+		if err := serve(socket, newServer(constructConfig()), stop); err != nil {
+			handleError(err)
+		}
+	},
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&socket, constants.Socket, constants.DefaultSocket, "The unix socket the API is served on")
+	rootCmd.AddCommand(serveCmd)
+}
+
+// serve serves the API on the unix socket until stopped.
+func serve(socket string, srv *server, stop <-chan os.Signal) error {
+	if err := os.MkdirAll(filepath.Dir(socket), 0755); err != nil {
+		return err
+	}
+	// Remove the socket of a previous server.
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove unix://%s: %v", socket, err)
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on unix://%s: %v", socket, err)
+	}
+	// Only root is allowed to program the rules.
+	if err := os.Chmod(socket, 0600); err != nil {
+		_ = listener.Close()
+		return fmt.Errorf("failed to update the permissions of unix://%s: %v", socket, err)
+	}
+
+	grpcServer := grpc.NewServer()
+	api.RegisterIptablesServiceServer(grpcServer, srv)
+	go func() {
+		<-stop
+		grpcServer.GracefulStop()
+	}()
+	log.Infof("Serving on unix://%s", socket)
+	return grpcServer.Serve(listener)
+}
+
+// server implements api.IptablesServiceServer with Program.
+type server struct {
+	// defaults is the config of the server, the defaults of the empty fields of the requests.
+	defaults config.Config
+	// program programs the rules, overridden in the tests.
+	program func(cfg *config.Config, ext dep.Dependencies) (*Ruleset, error)
+	// mu serializes the requests, which may program the rules of the same namespace.
+	mu sync.Mutex
+}
+
+func newServer(defaults *config.Config) *server {
+	return &server{
+		defaults: *defaults,
+		program:  Program,
+	}
+}
+
+// Program implements api.IptablesServiceServer.
+func (s *server) Program(_ context.Context, req *api.ProgramRequest) (*api.ProgramResponse, error) {
+	cfg, err := s.config(req.Config)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	var ext dep.Dependencies
+	if req.Netns != "" && !cfg.DryRun {
+		podIP := net.ParseIP(req.PodIp)
+		if podIP == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid pod IP %q for the network namespace %s", req.PodIp, req.Netns)
+		}
+		ext = &dep.NsenterDependencies{Netns: req.Netns, LocalIP: podIP}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ruleset, err := s.program(cfg, ext)
+	if err != nil {
+		log.Errorf("Failed to program the rules of the network namespace %q: %v", req.Netns, err)
+		if cmdErr, ok := err.(*CommandError); ok {
+			return nil, api.CommandErrorStatus(&api.CommandError{
+				Command: cmdErr.Command,
+				Args:    cmdErr.Args,
+				Message: cmdErr.Error(),
+			}).Err()
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &api.ProgramResponse{
+		Ipv4Tables: apiTables(ruleset.IPv4),
+		Ipv6Tables: apiTables(ruleset.IPv6),
+	}, nil
+}
+
+// config returns the config of a request, the empty fields defaulting to the config of the server.
+func (s *server) config(req *api.Config) (*config.Config, error) {
+	cfg := s.defaults
+	if req == nil {
+		return &cfg, nil
+	}
+	for _, field := range []struct {
+		value string
+		cfg   *string
+	}{
+		{req.ProxyPort, &cfg.ProxyPort},
+		{req.InboundCapturePort, &cfg.InboundCapturePort},
+		{req.ProxyUid, &cfg.ProxyUID},
+		{req.ProxyGid, &cfg.ProxyGID},
+		{req.ExcludeOwners, &cfg.ExcludeOwners},
+		{req.InboundInterceptionMode, &cfg.InboundInterceptionMode},
+		{req.InboundTproxyMark, &cfg.InboundTProxyMark},
+		{req.InboundTproxyRouteTable, &cfg.InboundTProxyRouteTable},
+		{req.InboundPortsInclude, &cfg.InboundPortsInclude},
+		{req.InboundPortsExclude, &cfg.InboundPortsExclude},
+		{req.OutboundPortsInclude, &cfg.OutboundPortsInclude},
+		{req.OutboundPortsExclude, &cfg.OutboundPortsExclude},
+		{req.OutboundIpRangesInclude, &cfg.OutboundIPRangesInclude},
+		{req.OutboundIpRangesExclude, &cfg.OutboundIPRangesExclude},
+		{req.KubevirtInterfaces, &cfg.KubevirtInterfaces},
+		{req.DnsCapturePort, &cfg.DNSCapturePort},
+	} {
+		if field.value != "" {
+			*field.cfg = field.value
+		}
+	}
+	if req.Reconcile != "" {
+		reconcile, err := parseReconcile(req.Reconcile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Reconcile = reconcile
+	}
+	cfg.DryRun = req.DryRun
+	cfg.RestoreFormat = req.RestoreFormat
+	cfg.RedirectDNS = req.RedirectDns
+	// The rules are returned rather than printed or compared.
+	cfg.DryRunFormat = ""
+	cfg.Diff = false
+	cfg.EnableInboundIPv6s = nil
+	return &cfg, nil
+}
+
+// apiTables returns the tables sorted by name.
+func apiTables(tables map[string]*builder.Table) []*api.Table {
+	out := make([]*api.Table, 0, len(tables))
+	for name, table := range tables {
+		out = append(out, &api.Table{Name: name, Chains: table.Chains, Rules: table.Rules})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
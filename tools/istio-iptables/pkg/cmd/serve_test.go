@@ -0,0 +1,130 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api "istio.io/istio/tools/istio-iptables/pkg/api/v1alpha1"
+	"istio.io/istio/tools/istio-iptables/pkg/config"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+)
+
+func TestProgram(t *testing.T) {
+	cfg := constructConfig()
+	cfg.DryRun = true
+	ruleset, err := Program(cfg, &dep.SilentStubDependencies{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nat := ruleset.IPv4["nat"]; nat == nil || len(nat.Rules) == 0 {
+		t.Errorf("got IPv4 rules %v, want the nat rules", ruleset.IPv4)
+	}
+
+	// The commands fail with recordingDependencies.
+	_, err = Program(constructConfig(), &recordingDependencies{})
+	cmdErr, ok := err.(*CommandError)
+	if !ok {
+		t.Fatalf("got error %v, want a command error", err)
+	}
+	if cmdErr.Command != "iptables-restore" {
+		t.Errorf("got failed command %q, want iptables-restore", cmdErr.Command)
+	}
+}
+
+func TestServe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	socket := filepath.Join(dir, "iptables.sock")
+
+	srv := newServer(constructConfig())
+	var programmed *config.Config
+	srv.program = func(cfg *config.Config, ext dep.Dependencies) (*Ruleset, error) {
+		programmed = cfg
+		if cfg.ProxyPort == "fail" {
+			return nil, &CommandError{Command: "iptables-restore", Args: []string{"--noflush"}, Err: errors.New("exit status 1")}
+		}
+		return Program(cfg, &dep.SilentStubDependencies{})
+	}
+	stop := make(chan os.Signal)
+	done := make(chan error)
+	go func() { done <- serve(socket, srv, stop) }()
+	defer func() {
+		close(stop)
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	conn, err := grpc.Dial(socket, grpc.WithInsecure(), grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+		return net.DialTimeout("unix", addr, timeout)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	client := api.NewIptablesServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := client.Program(ctx, &api.ProgramRequest{Config: &api.Config{ProxyPort: "15002"}}, grpc.WaitForReady(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if programmed.ProxyPort != "15002" || programmed.InboundCapturePort != "15006" {
+		t.Errorf("got ports %s and %s, want the port of the request and the default inbound capture port",
+			programmed.ProxyPort, programmed.InboundCapturePort)
+	}
+	var tables []string
+	for _, table := range resp.Ipv4Tables {
+		tables = append(tables, table.Name)
+	}
+	if !reflect.DeepEqual(tables, []string{"nat"}) {
+		t.Errorf("got IPv4 tables %v, want nat", tables)
+	}
+
+	_, err = client.Program(ctx, &api.ProgramRequest{Config: &api.Config{ProxyPort: "fail"}})
+	cmdErr := api.CommandErrorFromError(err)
+	if status.Code(err) != codes.Internal || cmdErr == nil {
+		t.Fatalf("got error %v, want a command error", err)
+	}
+	if cmdErr.Command != "iptables-restore" || !reflect.DeepEqual(cmdErr.Args, []string{"--noflush"}) {
+		t.Errorf("got failed command %s %v, want iptables-restore --noflush", cmdErr.Command, cmdErr.Args)
+	}
+
+	for _, req := range []*api.ProgramRequest{
+		{Config: &api.Config{Reconcile: "ignore"}},
+		{Netns: "/proc/1/ns/net", PodIp: "pod"},
+	} {
+		if _, err = client.Program(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("got error %v for %v, want an invalid argument", err, req)
+		}
+	}
+}
@@ -25,21 +25,28 @@ import (
 // Command line options
 type Config struct {
 	DryRun                  bool   `json:"DRY_RUN"`
+	DryRunFormat            string `json:"DRY_RUN_FORMAT"`
+	Diff                    bool   `json:"DIFF"`
+	Reconcile               string `json:"RECONCILE"`
 	RestoreFormat           bool   `json:"RESTORE_FORMAT"`
 	ProxyPort               string `json:"PROXY_PORT"`
 	InboundCapturePort      string `json:"INBOUND_CAPTURE_PORT"`
 	ProxyUID                string `json:"PROXY_UID"`
 	ProxyGID                string `json:"PROXY_GID"`
+	ExcludeOwners           string `json:"EXCLUDE_OWNERS"`
 	InboundInterceptionMode string `json:"INBOUND_INTERCEPTION_MODE"`
 	InboundTProxyMark       string `json:"INBOUND_TPROXY_MARK"`
 	InboundTProxyRouteTable string `json:"INBOUND_TPROXY_ROUTE_TABLE"`
 	InboundPortsInclude     string `json:"INBOUND_PORTS_INCLUDE"`
 	InboundPortsExclude     string `json:"INBOUND_PORTS_EXCLUDE"`
 	OutboundPortsExclude    string `json:"OUTBOUND_PORTS_EXCLUDE"`
+	OutboundPortsInclude    string `json:"OUTBOUND_PORTS_INCLUDE"`
 	OutboundIPRangesInclude string `json:"OUTBOUND_IPRANGES_INCLUDE"`
 	OutboundIPRangesExclude string `json:"OUTBOUND_IPRANGES_EXCLUDE"`
 	KubevirtInterfaces      string `json:"KUBEVIRT_INTERFACES"`
 	EnableInboundIPv6s      net.IP `json:"ENABLE_INBOUND_IPV6"`
+	RedirectDNS             bool   `json:"REDIRECT_DNS"`
+	DNSCapturePort          string `json:"DNS_CAPTURE_PORT"`
 }
 
 func (c *Config) String() string {
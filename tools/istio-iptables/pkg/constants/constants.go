@@ -41,6 +41,7 @@ var BuiltInChainsMap = map[string]struct{}{
 // Constants used for generating iptables commands
 const (
 	TCP = "tcp"
+	UDP = "udp"
 
 	TPROXY   = "TPROXY"
 	RETURN   = "RETURN"
@@ -70,6 +71,7 @@ const (
 	ServiceCidr               = "istio-service-cidr"
 	ServiceExcludeCidr        = "istio-service-exclude-cidr"
 	LocalOutboundPortsExclude = "istio-local-outbound-ports-exclude"
+	OutboundPorts             = "istio-outbound-ports"
 	EnvoyPort                 = "envoy-port"
 	InboundCapturePort        = "inbound-capture-port"
 	ProxyUID                  = "proxy-uid"
@@ -78,8 +80,30 @@ const (
 	DryRun                    = "dry-run"
 	Clean                     = "clean"
 	RestoreFormat             = "restore-format"
+	RedirectDNS               = "redirect-dns"
+	DNSCapturePort            = "dns-capture-port"
+	Diff                      = "diff"
+	Reconcile                 = "reconcile"
+	ExcludeOwners             = "exclude-owners"
+	Socket                    = "socket"
 )
 
+// Values of the reconcile flag, handling the rules already programmed.
+const (
+	ReconcileSkip    = "skip"
+	ReconcileReplace = "replace"
+	ReconcileFail    = "fail"
+)
+
+// DryRunJSON is the value of the dry-run flag rendering the rules as JSON.
+const DryRunJSON = "json"
+
+// DefaultSocket is the default unix socket of the API served with `istio-iptables serve`.
+const DefaultSocket = "/var/run/istio-iptables/iptables.sock"
+
+// DNSPort is the port of the DNS queries captured with RedirectDNS.
+const DNSPort = "53"
+
 // Constants for iptables commands
 const (
 	IPTABLESRESTORE  = "iptables-restore"
@@ -51,6 +51,24 @@ func (r *RealDependencies) LookupUser() (*user.User, error) {
 	return user.Lookup(username)
 }
 
+// LookupUserID returns the UID of the user with the name
+func (r *RealDependencies) LookupUserID(name string) (string, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", err
+	}
+	return u.Uid, nil
+}
+
+// LookupGroupID returns the GID of the group with the name
+func (r *RealDependencies) LookupGroupID(name string) (string, error) {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return "", err
+	}
+	return g.Gid, nil
+}
+
 func (r *RealDependencies) execute(cmd string, redirectStdout bool, args ...string) error {
 	fmt.Printf("%s %s\n", cmd, strings.Join(args, " "))
 	externalCommand := exec.Command(cmd, args...)
@@ -80,3 +98,69 @@ func (r *RealDependencies) Run(cmd string, args ...string) error {
 func (r *RealDependencies) RunQuietlyAndIgnore(cmd string, args ...string) {
 	_ = r.execute(cmd, true, args...)
 }
+
+// RunWithOutput runs a command quietly and returns its standard output
+func (r *RealDependencies) RunWithOutput(cmd string, args ...string) ([]byte, error) {
+	return exec.Command(cmd, args...).Output()
+}
+
+// ReadOnlyDependencies implementation of interface Dependencies, which reads the state of the host
+// but doesn't change it: the commands are neither run nor printed, except with RunWithOutput
+type ReadOnlyDependencies struct {
+	RealDependencies
+}
+
+// RunOrFail ignores the command
+func (r *ReadOnlyDependencies) RunOrFail(cmd string, args ...string) {
+}
+
+// Run ignores the command
+func (r *ReadOnlyDependencies) Run(cmd string, args ...string) error {
+	return nil
+}
+
+// RunQuietlyAndIgnore ignores the command
+func (r *ReadOnlyDependencies) RunQuietlyAndIgnore(cmd string, args ...string) {
+}
+
+// NsenterDependencies implementation of interface Dependencies, which runs the commands in the network
+// namespace at the path with nsenter, e.g. the namespace of a pod set up by the CNI plugin
+type NsenterDependencies struct {
+	RealDependencies
+	// Netns is the path of the network namespace
+	Netns string
+	// LocalIP is the IP address of the network namespace, which isn't looked up
+	LocalIP net.IP
+}
+
+func (n *NsenterDependencies) nsenter(cmd string, args []string) []string {
+	return append([]string{"--net=" + n.Netns, cmd}, args...)
+}
+
+// GetLocalIP returns the IP address of the network namespace
+func (n *NsenterDependencies) GetLocalIP() (net.IP, error) {
+	if n.LocalIP == nil {
+		return nil, fmt.Errorf("no local IP address set for the network namespace %s", n.Netns)
+	}
+	return n.LocalIP, nil
+}
+
+// RunOrFail runs a command in the network namespace and panics, if it fails
+func (n *NsenterDependencies) RunOrFail(cmd string, args ...string) {
+	n.RealDependencies.RunOrFail(NSENTER, n.nsenter(cmd, args)...)
+}
+
+// Run runs a command in the network namespace
+func (n *NsenterDependencies) Run(cmd string, args ...string) error {
+	return n.RealDependencies.Run(NSENTER, n.nsenter(cmd, args)...)
+}
+
+// RunQuietlyAndIgnore runs a command in the network namespace quietly and ignores errors
+func (n *NsenterDependencies) RunQuietlyAndIgnore(cmd string, args ...string) {
+	n.RealDependencies.RunQuietlyAndIgnore(NSENTER, n.nsenter(cmd, args)...)
+}
+
+// RunWithOutput runs a command in the network namespace quietly and returns its standard output
+func (n *NsenterDependencies) RunWithOutput(cmd string, args ...string) ([]byte, error) {
+	return n.RealDependencies.RunWithOutput(NSENTER, n.nsenter(cmd, args)...)
+}
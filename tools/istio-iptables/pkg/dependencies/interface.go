@@ -25,6 +25,7 @@ const (
 	IP6TABLES     = "ip6tables"
 	IP6TABLESSAVE = "ip6tables-save"
 	IP            = "ip"
+	NSENTER       = "nsenter"
 )
 
 // Dependencies is used as abstraction for the commands used from the operating system
@@ -33,10 +34,16 @@ type Dependencies interface {
 	GetLocalIP() (net.IP, error)
 	// LookupUser returns user, which runs this executable
 	LookupUser() (*user.User, error)
+	// LookupUserID returns the UID of the user with the name
+	LookupUserID(name string) (string, error)
+	// LookupGroupID returns the GID of the group with the name
+	LookupGroupID(name string) (string, error)
 	// RunOrFail runs a command and panics, if it fails
 	RunOrFail(cmd string, args ...string)
 	// Run runs a command
 	Run(cmd string, args ...string) error
 	// RunQuietlyAndIgnore runs a command quietly and ignores errors
 	RunQuietlyAndIgnore(cmd string, args ...string)
+	// RunWithOutput runs a command quietly and returns its standard output
+	RunWithOutput(cmd string, args ...string) ([]byte, error)
 }
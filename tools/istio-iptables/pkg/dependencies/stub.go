@@ -38,6 +38,16 @@ func (s *StdoutStubDependencies) LookupUser() (*user.User, error) {
 	return &user.User{Uid: "0"}, nil
 }
 
+// LookupUserID returns the name of the user, which is resolved by iptables
+func (s *StdoutStubDependencies) LookupUserID(name string) (string, error) {
+	return name, nil
+}
+
+// LookupGroupID returns the name of the group, which is resolved by iptables
+func (s *StdoutStubDependencies) LookupGroupID(name string) (string, error) {
+	return name, nil
+}
+
 // RunOrFail runs a command and panics, if it fails
 func (s *StdoutStubDependencies) RunOrFail(cmd string, args ...string) {
 	fmt.Println(fmt.Sprintf("%s %s", cmd, strings.Join(args, " ")))
@@ -53,3 +63,33 @@ func (s *StdoutStubDependencies) Run(cmd string, args ...string) error {
 func (s *StdoutStubDependencies) RunQuietlyAndIgnore(cmd string, args ...string) {
 	fmt.Println(fmt.Sprintf("%s %s", cmd, strings.Join(args, " ")))
 }
+
+// RunWithOutput runs a command quietly and returns its standard output
+func (s *StdoutStubDependencies) RunWithOutput(cmd string, args ...string) ([]byte, error) {
+	fmt.Println(fmt.Sprintf("%s %s", cmd, strings.Join(args, " ")))
+	return nil, nil
+}
+
+// SilentStubDependencies implementation of interface Dependencies, which ignores the commands instead
+// of printing them, for the dry runs rendering the rules only
+type SilentStubDependencies struct {
+	StdoutStubDependencies
+}
+
+// RunOrFail ignores the command
+func (s *SilentStubDependencies) RunOrFail(cmd string, args ...string) {
+}
+
+// Run ignores the command
+func (s *SilentStubDependencies) Run(cmd string, args ...string) error {
+	return nil
+}
+
+// RunQuietlyAndIgnore ignores the command
+func (s *SilentStubDependencies) RunQuietlyAndIgnore(cmd string, args ...string) {
+}
+
+// RunWithOutput ignores the command
+func (s *SilentStubDependencies) RunWithOutput(cmd string, args ...string) ([]byte, error) {
+	return nil, nil
+}